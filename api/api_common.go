@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -46,11 +47,47 @@ type Common interface {
 	// usage and current rate per protocol
 	NetBandwidthStatsByProtocol(ctx context.Context) (map[protocol.ID]metrics.Stats, error)
 
+	// NetBandwidthHistory returns the bandwidth usage samples recorded by the
+	// node which ended after the given time, oldest first
+	NetBandwidthHistory(ctx context.Context, since time.Time) ([]NetBandwidthSample, error)
+
 	// ConnectionGater API
 	NetBlockAdd(ctx context.Context, acl NetBlockList) error
 	NetBlockRemove(ctx context.Context, acl NetBlockList) error
 	NetBlockList(ctx context.Context) (NetBlockList, error)
 
+	// MethodGroup: Bus
+
+	// BusTopics returns the topics of events published on the internal event
+	// bus of the node
+	BusTopics(context.Context) ([]string, error)
+	// BusSubscribe streams events published on the internal event bus of the
+	// node, of the given topics or all topics if none are given. Events are
+	// dropped when the subscriber doesn't keep up
+	BusSubscribe(ctx context.Context, topics []string) (<-chan BusEvent, error)
+
+	// MethodGroup: Profile
+
+	// ProfileCapture captures the named runtime profile of the process in the
+	// pprof format. The cpu profile is sampled for the given duration, other
+	// profiles are snapshots
+	ProfileCapture(ctx context.Context, name string, duration time.Duration) ([]byte, error)
+	// ProfileInfo returns metadata about the process, to be bundled with
+	// captured profiles
+	ProfileInfo(context.Context) (ProfileInfo, error)
+
+	// MethodGroup: Subsystem
+
+	// SubsystemList returns the status of the subsystems of the node which
+	// can be stopped and started while the node is running
+	SubsystemList(context.Context) ([]SubsystemStatus, error)
+	// SubsystemStart starts a stopped subsystem. It fails when a subsystem
+	// it depends on isn't running.
+	SubsystemStart(ctx context.Context, name string) error
+	// SubsystemStop stops a subsystem until it's started again, or the node
+	// restarts. It fails when a running subsystem depends on it.
+	SubsystemStop(ctx context.Context, name string) error
+
 	// MethodGroup: Common
 
 	// ID returns peerID of libp2p node backing this API
@@ -91,6 +128,37 @@ func (v Version) String() string {
 	return fmt.Sprintf("%s+api%s", v.Version, v.APIVersion.String())
 }
 
+// BusEvent is an event published on the internal event bus of the node
+type BusEvent struct {
+	Topic string
+	Time  time.Time
+	Event interface{}
+}
+
+// ProfileInfo describes the process profiles were captured from
+type ProfileInfo struct {
+	Version   string
+	GoVersion string
+	OS        string
+	Arch      string
+	NumCPU    int
+
+	// Config is the TOML encoded config of the node, with credentials redacted
+	Config string
+}
+
+type SubsystemStatus struct {
+	Name        string
+	Description string
+	DependsOn   []string
+
+	Running bool
+	// when the subsystem was last started or stopped
+	Since time.Time
+	// error of the last failed start or stop
+	Error string
+}
+
 type NatInfo struct {
 	Reachability network.Reachability
 	PublicAddr   string
@@ -10,6 +10,7 @@ import (
 	"github.com/filecoin-project/go-state-types/network"
 
 	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/peer"
 
 	"github.com/filecoin-project/go-address"
@@ -21,6 +22,7 @@ import (
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/go-state-types/dline"
+	"github.com/filecoin-project/go-state-types/exitcode"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/market"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/paych"
@@ -44,6 +46,11 @@ type FullNode interface {
 	// First message is guaranteed to be of len == 1, and type == 'current'.
 	ChainNotify(context.Context) (<-chan []*HeadChange, error)
 
+	// ChainNotifyMessages returns channel with messages matching the filter,
+	// and their receipts, which were executed in tipsets applied to, or
+	// reverted from the chain head
+	ChainNotifyMessages(ctx context.Context, filter MessageFilter) (<-chan []MessageEvent, error)
+
 	// ChainHead returns the current head of the chain.
 	ChainHead(context.Context) (*types.TipSet, error)
 
@@ -69,11 +76,28 @@ type FullNode interface {
 	// specified block.
 	ChainGetParentMessages(ctx context.Context, blockCid cid.Cid) ([]Message, error)
 
+	// ChainGetParentMessagesStream streams messages stored in parent tipset of
+	// the specified block in chunks, starting at the message with the cursor
+	// index. The Cursor of the last received chunk can be passed to resume an
+	// interrupted stream.
+	ChainGetParentMessagesStream(ctx context.Context, blockCid cid.Cid, cursor uint64) (<-chan ParentMessagesChunk, error)
+
+	// ChainGetNetworkStats returns per-epoch base fee, gas usage, message
+	// count and network power of tipsets in the [from, to] height range of
+	// the current chain as a time series. Null rounds are omitted.
+	ChainGetNetworkStats(ctx context.Context, from, to abi.ChainEpoch) (*NetworkStats, error)
+
 	// ChainGetTipSetByHeight looks back for a tipset at the specified epoch.
 	// If there are no blocks at the specified epoch, a tipset at an earlier epoch
 	// will be returned.
 	ChainGetTipSetByHeight(context.Context, abi.ChainEpoch, types.TipSetKey) (*types.TipSet, error)
 
+	// ChainGetTipSetAtHeight looks back for a tipset at the specified epoch.
+	// If there are no blocks at the specified epoch, the anchor selects the
+	// result: the previous, or the next non-null tipset, or an error. The
+	// result tells whether the epoch was a null round.
+	ChainGetTipSetAtHeight(ctx context.Context, h abi.ChainEpoch, anchor HeightAnchor, tsk types.TipSetKey) (*TipSetAtHeight, error)
+
 	// ChainReadObj reads ipld nodes referenced by the specified CID from chain
 	// blockstore and returns raw bytes.
 	ChainReadObj(context.Context, cid.Cid) ([]byte, error)
@@ -125,6 +149,17 @@ type FullNode interface {
 	// If oldmsgskip is set, messages from before the requested roots are also not included.
 	ChainExport(ctx context.Context, nroots abi.ChainEpoch, oldmsgskip bool, tsk types.TipSetKey) (<-chan []byte, error)
 
+	// ChainPrune compacts the hot store of the splitstore, moving the chain
+	// data older than the configured retention out of it. It fails when the
+	// splitstore isn't enabled.
+	ChainPrune(ctx context.Context) error
+
+	// ChainGCBlockstore reclaims the disk space of the objects deleted from
+	// the hot store of the splitstore by compactions. It can run while the
+	// node is in use, though writes to the hot store are paused while its LSM
+	// tree is flattened, and fails when the splitstore isn't enabled.
+	ChainGCBlockstore(ctx context.Context) ([]BlockstoreGCStats, error)
+
 	// MethodGroup: Beacon
 	// The Beacon method group contains methods for interacting with the random beacon (DRAND)
 
@@ -167,6 +202,10 @@ type FullNode interface {
 	// SyncCheckpoint marks a blocks as checkpointed, meaning that it won't ever fork away from it.
 	SyncCheckpoint(ctx context.Context, tsk types.TipSetKey) error
 
+	// SyncGetCheckpoint returns the key of the checkpointed tipset, empty if
+	// no tipset was checkpointed.
+	SyncGetCheckpoint(ctx context.Context) (types.TipSetKey, error)
+
 	// SyncMarkBad marks a blocks as bad, meaning that it won't ever by synced.
 	// Use with extreme caution.
 	SyncMarkBad(ctx context.Context, bcid cid.Cid) error
@@ -181,6 +220,14 @@ type FullNode interface {
 	// the reason.
 	SyncCheckBad(ctx context.Context, bcid cid.Cid) (string, error)
 
+	// SyncPeers returns the track record of the peers the node requests chain
+	// data from, including the temporarily banned ones.
+	SyncPeers(ctx context.Context) ([]SyncPeer, error)
+
+	// SyncUnbanPeer lifts the ban of a peer which misbehaved during sync, and
+	// resets its score.
+	SyncUnbanPeer(ctx context.Context, p peer.ID) error
+
 	// SyncValidateTipset indicates whether the provided tipset is valid or not
 	SyncValidateTipset(ctx context.Context, tsk types.TipSetKey) (bool, error)
 
@@ -281,10 +328,25 @@ type FullNode interface {
 	ClientRemoveImport(ctx context.Context, importID multistore.StoreID) error
 	// ClientStartDeal proposes a deal with a miner.
 	ClientStartDeal(ctx context.Context, params *StartDealParams) (*cid.Cid, error)
+	// ClientCreateDealAllowance authorizes the delegate key to propose deals
+	// paid for by the client wallet, up to amount in total deal price, until
+	// the expiration epoch. The returned allowance is signed by the client key.
+	ClientCreateDealAllowance(ctx context.Context, client, delegate address.Address, amount types.BigInt, expiration abi.ChainEpoch) (*SignedDealAllowance, error)
+	// ClientListDealAllowances lists deal allowances created by the local client.
+	ClientListDealAllowances(ctx context.Context) ([]DealAllowanceInfo, error)
+	// ClientRevokeDealAllowance prevents any further deals from being proposed
+	// with the given allowance.
+	ClientRevokeDealAllowance(ctx context.Context, id uint64) error
+	// ClientStartDelegatedDeal proposes a deal made by the delegate of a deal
+	// allowance, deducting the total deal price from the allowance.
+	ClientStartDelegatedDeal(ctx context.Context, req *DelegatedDealRequest) (*cid.Cid, error)
 	// ClientGetDealInfo returns the latest information about a given deal.
 	ClientGetDealInfo(context.Context, cid.Cid) (*DealInfo, error)
 	// ClientListDeals returns information about the deals made by the local client.
 	ClientListDeals(ctx context.Context) ([]DealInfo, error)
+	// ClientProviderScores scores the storage providers the client dealt with,
+	// from the local history of storage deals and retrievals, best first
+	ClientProviderScores(ctx context.Context) ([]ProviderScore, error)
 	// ClientGetDealUpdates returns the status of updated deals
 	ClientGetDealUpdates(ctx context.Context) (<-chan DealInfo, error)
 	// ClientGetDealStatus returns status given a code
@@ -336,6 +398,10 @@ type FullNode interface {
 
 	// StateCall runs the given message and returns its result without any persisted changes.
 	StateCall(context.Context, *types.Message, types.TipSetKey) (*InvocResult, error)
+	// StateCallMany runs each of the given messages on top of the state of the
+	// tipset, like StateCall, loading the state only once. Messages are run
+	// independently, and don't see state changes of each other.
+	StateCallMany(context.Context, []*types.Message, types.TipSetKey) ([]*InvocResult, error)
 	// StateReplay replays a given message, assuming it was included in a block in the specified tipset.
 	// If no tipset key is provided, the appropriate tipset is looked up.
 	StateReplay(context.Context, types.TipSetKey, cid.Cid) (*InvocResult, error)
@@ -352,11 +418,21 @@ type FullNode interface {
 	StateNetworkName(context.Context) (dtypes.NetworkName, error)
 	// StateMinerSectors returns info about the given miner's sectors. If the filter bitfield is nil, all sectors are included.
 	StateMinerSectors(context.Context, address.Address, *bitfield.BitField, types.TipSetKey) ([]*miner.SectorOnChainInfo, error)
+	// StateMinerSectorsStream streams info about the given miner's sectors in
+	// chunks, in ascending sector number order, starting at the cursor sector
+	// number. The Cursor of the last received chunk can be passed to resume an
+	// interrupted stream.
+	StateMinerSectorsStream(ctx context.Context, addr address.Address, sectorNos *bitfield.BitField, cursor abi.SectorNumber, tsk types.TipSetKey) (<-chan MinerSectorsChunk, error)
 	// StateMinerActiveSectors returns info about sectors that a given miner is actively proving.
 	StateMinerActiveSectors(context.Context, address.Address, types.TipSetKey) ([]*miner.SectorOnChainInfo, error)
 	// StateMinerProvingDeadline calculates the deadline at some epoch for a proving period
 	// and returns the deadline-related calculations.
 	StateMinerProvingDeadline(context.Context, address.Address, types.TipSetKey) (*dline.Info, error)
+	// StateMinerDeadlineNotify returns a channel which receives an event each
+	// time one of the miner's deadlines opens, shifted by offset epochs; a
+	// negative offset notifies before the deadline opens. When deadlines is
+	// not empty, only the listed deadline indexes are notified.
+	StateMinerDeadlineNotify(ctx context.Context, maddr address.Address, offset abi.ChainEpoch, deadlines []uint64) (<-chan *DeadlineEvent, error)
 	// StateMinerPower returns the power of the indicated miner
 	StateMinerPower(context.Context, address.Address, types.TipSetKey) (*MinerPower, error)
 	// StateMinerInfo returns info about the indicated miner
@@ -421,6 +497,9 @@ type FullNode interface {
 	StateChangedActors(context.Context, cid.Cid, cid.Cid) (map[string]types.Actor, error)
 	// StateGetReceipt returns the message receipt for the given message
 	StateGetReceipt(context.Context, cid.Cid, types.TipSetKey) (*types.MessageReceipt, error)
+	// StateGetReceipts returns the receipts of all messages included in the
+	// given tipset, in execution order
+	StateGetReceipts(context.Context, types.TipSetKey) ([]MsgReceipt, error)
 	// StateMinerSectorCount returns the number of sectors in a miner's sector set and proving set
 	StateMinerSectorCount(context.Context, address.Address, types.TipSetKey) (MinerSectors, error)
 	// StateCompute is a flexible command that applies the given messages on the given tipset.
@@ -548,6 +627,21 @@ type FullNode interface {
 	PaychVoucherList(context.Context, address.Address) ([]*paych.SignedVoucher, error)
 	PaychVoucherSubmit(context.Context, address.Address, *paych.SignedVoucher, []byte, []byte) (cid.Cid, error)
 
+	// MethodGroup: Epoch
+	// The Epoch methods manage tasks which the node runs when the chain
+	// reaches an epoch, or an offset from a miner's proving deadline
+
+	// EpochTaskAdd schedules a task, returning its ID
+	EpochTaskAdd(context.Context, EpochTask) (uint64, error)
+	// EpochTaskList lists the scheduled tasks with the result of their last run
+	EpochTaskList(context.Context) ([]EpochTask, error)
+	// EpochTaskRemove removes a scheduled task
+	EpochTaskRemove(ctx context.Context, id uint64) error
+
+	// NetDiag collects connectivity diagnostics of the node: dials the
+	// bootstrap peers, and times fetching headers with chain exchange
+	NetDiag(context.Context) (NetDiagnostics, error)
+
 	// CreateBackup creates node backup onder the specified file name. The
 	// method requires that the lotus daemon is running with the
 	// LOTUS_BACKUP_BASE_PATH environment variable set to some path, and that
@@ -605,6 +699,42 @@ type DealInfo struct {
 	DataTransfer      *DataTransferChannel
 }
 
+// NeutralProviderScore is the score of providers without any finished deals
+// or retrievals
+const NeutralProviderScore = 0.5
+
+// ProviderScore summarizes the local history of deals with a storage provider
+type ProviderScore struct {
+	Provider address.Address
+
+	Deals         int
+	RejectedDeals int
+	FailedDeals   int
+	ActiveDeals   int
+	// AvgTimeToActivation is the average time from proposing a deal to its
+	// sector being activated on chain
+	AvgTimeToActivation time.Duration
+
+	Retrievals       int
+	FailedRetrievals int
+	// AvgRetrievalSpeed is the average speed of successful retrievals, in
+	// bytes per second
+	AvgRetrievalSpeed uint64
+
+	// Score is the share of deals and retrievals which succeeded, from 0 to 1
+	Score float64
+}
+
+// RetrievalRecord is the outcome of a retrieval made by the client
+type RetrievalRecord struct {
+	Miner         address.Address
+	Root          cid.Cid
+	Start         time.Time
+	Duration      time.Duration
+	BytesReceived uint64
+	Error         string
+}
+
 type MsgLookup struct {
 	Message   cid.Cid // Can be different than requested, in case it was replaced, but only gas values changed
 	Receipt   types.MessageReceipt
@@ -613,6 +743,12 @@ type MsgLookup struct {
 	Height    abi.ChainEpoch
 }
 
+// MsgReceipt is the receipt of a message executed in a tipset
+type MsgReceipt struct {
+	Message cid.Cid
+	Receipt types.MessageReceipt
+}
+
 type MsgGasCost struct {
 	Message            cid.Cid // Can be different than requested, in case it was replaced, but only gas values changed
 	GasUsed            abi.TokenAmount
@@ -638,6 +774,43 @@ type Message struct {
 	Message *types.Message
 }
 
+// ParentMessagesChunk is a part of the response of ChainGetParentMessagesStream
+type ParentMessagesChunk struct {
+	Messages []Message
+	// index of the next message to stream
+	Cursor uint64
+	// set if the stream failed; Cursor can be used to resume it
+	Err string
+}
+
+// NetworkStats is a time series of per-epoch chain statistics; values at index
+// i of each slice describe the tipset at Heights[i]
+type NetworkStats struct {
+	Heights    []abi.ChainEpoch
+	Timestamps []uint64
+
+	// base fee paid by messages included in the tipset
+	BaseFee []abi.TokenAmount
+	// gas used when executing messages included in the tipset
+	GasUsed  []int64
+	GasLimit []int64
+	// number of unique messages included in the tipset
+	Messages []uint64
+
+	// network power after executing the tipset
+	NetworkQAP      []abi.StoragePower
+	NetworkRawPower []abi.StoragePower
+}
+
+// MinerSectorsChunk is a part of the response of StateMinerSectorsStream
+type MinerSectorsChunk struct {
+	Sectors []*miner.SectorOnChainInfo
+	// sector number the next chunk starts at
+	Cursor abi.SectorNumber
+	// set if the stream failed; Cursor can be used to resume it
+	Err string
+}
+
 type ActorState struct {
 	Balance types.BigInt
 	State   interface{}
@@ -768,6 +941,13 @@ type RetrievalOrder struct {
 	Client                  address.Address
 	Miner                   address.Address
 	MinerPeer               retrievalmarket.RetrievalPeer
+	// Transport the data is retrieved over: graphsync (the default), http,
+	// or auto for http when the provider offers it and graphsync otherwise.
+	// Data retrieved over http is paid for upfront, with a voucher for Total
+	// on a new lane of the payment channel to the provider, and needs a token
+	// issued by the provider
+	Transport string
+	HTTPToken string
 }
 
 type InvocResult struct {
@@ -797,6 +977,42 @@ type StartDealParams struct {
 	VerifiedDeal       bool
 }
 
+// DealAllowance authorizes Delegate to propose storage deals paid for by
+// Client, up to Amount in total deal price, until the Expiration epoch
+type DealAllowance struct {
+	ID         uint64
+	Client     address.Address
+	Delegate   address.Address
+	Amount     types.BigInt
+	Expiration abi.ChainEpoch
+}
+
+type SignedDealAllowance struct {
+	Allowance DealAllowance
+	// signed by the Client key
+	Signature *crypto.Signature
+}
+
+type DealAllowanceInfo struct {
+	SignedDealAllowance
+
+	Spent     types.BigInt
+	LastNonce uint64
+	Revoked   bool
+}
+
+// DelegatedDealRequest is a deal proposal made under a deal allowance, signed
+// by the allowance Delegate key
+type DelegatedDealRequest struct {
+	Allowance SignedDealAllowance
+	Params    StartDealParams
+
+	// must be greater than the nonce of all previous requests made with the
+	// allowance, which prevents requests from being replayed
+	Nonce     uint64
+	Signature *crypto.Signature
+}
+
 func (s *StartDealParams) UnmarshalJSON(raw []byte) (err error) {
 	type sdpAlias StartDealParams
 
@@ -868,6 +1084,26 @@ func (v SyncStateStage) String() string {
 	}
 }
 
+// SyncPeer is the track record of a peer the node requests chain data from
+type SyncPeer struct {
+	ID peer.ID
+
+	Successes int
+	// Failures counts the requests which timed out or broke off
+	Failures         int
+	InvalidResponses int
+	SlowResponses    int
+	BadBlocks        int
+
+	// AverageTime is the average time it took the peer to send a tipset
+	AverageTime time.Duration
+	// Score is the decaying sum of the penalties of the peer; peers are
+	// banned when it gets too high
+	Score float64
+	// BannedUntil is zero for peers which aren't banned
+	BannedUntil time.Time
+}
+
 type MpoolChange int
 
 const (
@@ -941,6 +1177,56 @@ type HeadChange struct {
 	Val  *types.TipSet
 }
 
+// HeightAnchor selects the tipset returned by ChainGetTipSetAtHeight when the
+// requested epoch is a null round
+type HeightAnchor string
+
+const (
+	// HeightAnchorPrev selects the last tipset before the null round
+	HeightAnchorPrev HeightAnchor = "prev"
+	// HeightAnchorNext selects the first tipset after the null round
+	HeightAnchorNext HeightAnchor = "next"
+	// HeightAnchorExact fails the lookup on null rounds
+	HeightAnchorExact HeightAnchor = "exact"
+)
+
+type TipSetAtHeight struct {
+	TipSet *types.TipSet
+	// NullRound is set when the requested epoch was a null round, and TipSet
+	// is the tipset selected by the anchor
+	NullRound bool
+}
+
+// MessageFilter selects executed messages by recipient, method number and
+// exit code. Empty fields match all messages. The recipient matches messages
+// sent to either its ID or its robust address
+type MessageFilter struct {
+	To        address.Address
+	Methods   []abi.MethodNum
+	ExitCodes []exitcode.ExitCode
+}
+
+// MessageEvent is a message matching a MessageFilter, executed in the parent
+// of TipSet
+type MessageEvent struct {
+	// "apply" or "revert"
+	Type    string
+	TipSet  types.TipSetKey
+	Height  abi.ChainEpoch
+	Cid     cid.Cid
+	Message *types.Message
+	Receipt types.MessageReceipt
+}
+
+type DeadlineEvent struct {
+	// Epoch of the head which triggered the event, the deadline opens at
+	// Epoch-offset
+	Epoch  abi.ChainEpoch
+	TipSet types.TipSetKey
+
+	Deadline *dline.Info
+}
+
 type MsigProposeResponse int
 
 const (
@@ -981,3 +1267,88 @@ type MessageMatch struct {
 	To   address.Address
 	From address.Address
 }
+
+// EpochTask is an action run by the node at a chain epoch. Either Epoch or
+// Deadline is set.
+type EpochTask struct {
+	ID   uint64
+	Name string
+
+	// Epoch is the epoch at which the task runs once
+	Epoch abi.ChainEpoch `json:",omitempty"`
+	// Deadline makes the task run every proving period, relative to the
+	// opening of a proving deadline of a miner
+	Deadline *EpochTaskDeadline `json:",omitempty"`
+
+	Action EpochTaskAction
+
+	// set by the node
+	Created    abi.ChainEpoch
+	NextRun    abi.ChainEpoch
+	LastRun    abi.ChainEpoch
+	LastResult string
+	LastError  string
+}
+
+type EpochTaskDeadline struct {
+	Miner address.Address
+	Index uint64
+	// Offset is the number of epochs after the deadline opens, can be
+	// negative to run before it opens
+	Offset abi.ChainEpoch
+}
+
+// EpochTaskAction is what the task does, exactly one of the fields is set
+type EpochTaskAction struct {
+	// Message is pushed to the mpool, with gas and nonce set by the node
+	Message *types.Message `json:",omitempty"`
+	// Command is run with sh -c, with the task run as JSON on stdin
+	Command string `json:",omitempty"`
+	// Webhook is an URL to which the task run is POSTed as JSON
+	Webhook string `json:",omitempty"`
+}
+
+// NetDiagnostics is a snapshot of the connectivity of a node
+type NetDiagnostics struct {
+	Time      time.Time
+	Self      peer.AddrInfo
+	Nat       NatInfo
+	Peers     int
+	Bandwidth metrics.Stats
+
+	Bootstrap     []NetDiagDial
+	Topics        []NetDiagTopic
+	ChainExchange []NetDiagFetch
+	Scores        []PubsubScore
+}
+
+// NetDiagDial is the result of connecting to a peer
+type NetDiagDial struct {
+	Peer peer.ID
+	// WasConnected is set if the node was connected to the peer before
+	WasConnected bool
+	Took         time.Duration
+	Error        string
+}
+
+// NetDiagTopic is the number of peers known to be subscribed to a pubsub topic
+type NetDiagTopic struct {
+	Topic string
+	Peers int
+}
+
+// NetDiagFetch is the result of fetching block headers with chain exchange
+type NetDiagFetch struct {
+	Tipsets int
+	Took    time.Duration
+	Error   string
+}
+
+// BlockstoreGCStats is the outcome of the garbage collection of a blockstore
+type BlockstoreGCStats struct {
+	// hot, for the hot store of the splitstore
+	Store string
+	// size on disk, in bytes
+	SizeBefore int64
+	SizeAfter  int64
+}
@@ -10,15 +10,18 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
@@ -31,8 +34,20 @@ type StorageMiner interface {
 
 	ActorSectorSize(context.Context, address.Address) (abi.SectorSize, error)
 	ActorAddressConfig(ctx context.Context) (AddressConfig, error)
+	// ActorFundsPolicy returns the policy of the funds manager, which maintains
+	// the balance of the miner actor
+	ActorFundsPolicy(ctx context.Context) (FundsPolicy, error)
+	// ActorSetFundsPolicy sets, and persists in the config, the policy of the
+	// funds manager
+	ActorSetFundsPolicy(ctx context.Context, p FundsPolicy) error
 
 	MiningBase(context.Context) (*types.TipSet, error)
+	// MiningBlockCandidate returns the block the miner would produce if it won
+	// the next round right now, with a single win and the best possible ticket
+	MiningBlockCandidate(context.Context) (*BlockCandidate, error)
+	// MiningBlockHistory returns the most recent blocks produced by the miner,
+	// with the time spent in each block production step
+	MiningBlockHistory(context.Context) ([]MinedBlock, error)
 
 	// Temp api for testing
 	PledgeSector(context.Context) error
@@ -46,16 +61,24 @@ type StorageMiner interface {
 	// Get summary info of sectors
 	SectorsSummary(ctx context.Context) (map[SectorState]int, error)
 
+	// SectorsStateSummary returns the number of sectors, how long the oldest
+	// one has been there, and the size of deal data, for each sector state
+	SectorsStateSummary(ctx context.Context) (map[SectorState]SectorStateSummary, error)
+
 	// List sectors in particular states
 	SectorsListInStates(context.Context, []SectorState) ([]abi.SectorNumber, error)
 
 	SectorsRefs(context.Context) (map[string][]SealedRef, error)
 
+	// SectorsHistory returns the state transitions of a sector, oldest first
+	SectorsHistory(context.Context, abi.SectorNumber) ([]SectorStateTransition, error)
+
 	// SectorStartSealing can be called on sectors in Empty or WaitDeals states
 	// to trigger sealing early
 	SectorStartSealing(context.Context, abi.SectorNumber) error
 	// SectorSetSealDelay sets the time that a newly-created sector
-	// waits for more deals before it starts sealing
+	// waits for more deals before it starts sealing. It also applies to
+	// sectors already waiting for deals
 	SectorSetSealDelay(context.Context, time.Duration) error
 	// SectorGetSealDelay gets the time that a newly-created sector
 	// waits for more deals before it starts sealing
@@ -68,6 +91,14 @@ type StorageMiner interface {
 	// SectorRemove removes the sector from storage. It doesn't terminate it on-chain, which can
 	// be done with SectorTerminate. Removing and not terminating live sectors will cause additional penalties.
 	SectorRemove(context.Context, abi.SectorNumber) error
+	// SectorAbort aborts a sector which didn't start sealing yet (in the Empty,
+	// WaitDeals or Packing states). Deal pieces in the sector are added to
+	// other sectors, and the sector is removed.
+	SectorAbort(context.Context, abi.SectorNumber) error
+	// SectorRegenerateCache rebuilds lost or corrupted cache files of a sealed
+	// sector by sealing its data again. Sectors with deals need a complete
+	// unsealed copy. Blocks until the cache is regenerated.
+	SectorRegenerateCache(context.Context, abi.SectorNumber) error
 	// SectorTerminate terminates the sector on-chain (adding it to a termination batch first), then
 	// automatically removes it from storage
 	SectorTerminate(context.Context, abi.SectorNumber) error
@@ -77,20 +108,69 @@ type StorageMiner interface {
 	// SectorTerminatePending returns a list of pending sector terminations to be sent in the next batch message
 	SectorTerminatePending(ctx context.Context) ([]abi.SectorID, error)
 	SectorMarkForUpgrade(ctx context.Context, id abi.SectorNumber) error
+	// SectorAddPieceToAny adds a deal piece to any sector accepting deals. The
+	// piece data is streamed over HTTP from the caller, so that a markets
+	// process can hand off deal data without it being stored on the miner node
+	SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d PieceDealInfo) (SectorOffset, error)
+	// SectorReservePiece reserves space for a deal piece in a sector accepting
+	// deals, without transferring the piece data. The returned lease has to be
+	// committed with SectorCommitPiece before it expires, otherwise the space
+	// is released
+	SectorReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d PieceDealInfo) (PieceLease, error)
+	// SectorCommitPiece writes the piece data into the space reserved by a
+	// lease. If writing fails the lease is released, and the piece has to be
+	// reserved again
+	SectorCommitPiece(ctx context.Context, lease uint64, r storage.Data) (SectorOffset, error)
 
 	StorageList(ctx context.Context) (map[stores.ID][]stores.Decl, error)
 	StorageLocal(ctx context.Context) (map[stores.ID]string, error)
 	StorageStat(ctx context.Context, id stores.ID) (fsutil.FsStat, error)
+	// StorageHealth returns the result of the last health check of a storage
+	// path. Unhealthy paths aren't used for new sector allocations
+	StorageHealth(ctx context.Context, id stores.ID) (stores.HealthState, error)
+	// StorageTransfers returns running and queued fetches of sector files into
+	// storage paths of the miner, e.g. when moving finalized sectors from
+	// workers to long-term storage
+	StorageTransfers(ctx context.Context) ([]stores.TransferInfo, error)
 
 	// WorkerConnect tells the node to connect to workers RPC
 	WorkerConnect(context.Context, string) error
 	WorkerStats(context.Context) (map[uuid.UUID]storiface.WorkerStats, error)
 	WorkerJobs(context.Context) (map[uuid.UUID][]storiface.WorkerJob, error)
+	// WorkerDrain puts a worker into (or out of) drain mode. Draining workers
+	// finish their running tasks, but don't get any new ones; WorkerStats
+	// reports when a draining worker is empty
+	WorkerDrain(ctx context.Context, worker uuid.UUID, drain bool) error
+	// WorkerJoinTokenCreate creates a single-use token, which a worker can use
+	// within the given duration to join the miner with WorkerJoin
+	WorkerJoinTokenCreate(ctx context.Context, ttl time.Duration) (string, error)
+	// WorkerJoin exchanges a join token for a worker identity certificate,
+	// which the worker uses to authenticate to the miner API
+	WorkerJoin(ctx context.Context, token string, name string) (WorkerCredential, error)
+	// WorkerRenew extends the validity of a worker identity certificate which
+	// hasn't expired yet, and returns when it expires
+	WorkerRenew(ctx context.Context, certificate string) (time.Time, error)
+	// WorkerIdentities lists the identities of workers which joined the miner
+	WorkerIdentities(ctx context.Context) ([]WorkerIdentity, error)
+	// WorkerIdentityRevoke revokes the certificate of a worker identity
+	WorkerIdentityRevoke(ctx context.Context, id uuid.UUID) error
+	// WorkerProfileCapture captures the named runtime profile of a connected
+	// worker process
+	WorkerProfileCapture(ctx context.Context, worker uuid.UUID, name string, duration time.Duration) ([]byte, error)
 	storiface.WorkerReturn
 
 	// SealingSchedDiag dumps internal sealing scheduler state
 	SealingSchedDiag(ctx context.Context, doSched bool) (interface{}, error)
 	SealingAbort(ctx context.Context, call storiface.CallID) error
+	// SealingSetTaskPriority sets the scheduling priority of sealing tasks of
+	// the given type which don't have an explicit priority set. Larger values
+	// are more important; setting 0 restores the default priority
+	SealingSetTaskPriority(ctx context.Context, tt sealtasks.TaskType, priority int) error
+	// SealingTaskPriorities returns the task priorities set with SealingSetTaskPriority
+	SealingTaskPriorities(ctx context.Context) (map[sealtasks.TaskType]int, error)
+	// SealingAddPieceQueue returns the state of the queue of pieces waiting
+	// to be added to a sector
+	SealingAddPieceQueue(ctx context.Context) (AddPieceQueueInfo, error)
 
 	stores.SectorIndex
 
@@ -99,19 +179,38 @@ type StorageMiner interface {
 	MarketListRetrievalDeals(ctx context.Context) ([]retrievalmarket.ProviderDealState, error)
 	MarketGetDealUpdates(ctx context.Context) (<-chan storagemarket.MinerDeal, error)
 	MarketListIncompleteDeals(ctx context.Context) ([]storagemarket.MinerDeal, error)
+	// MarketClientQuotas returns the quota consumption of clients with active
+	// deals or pieces waiting to be added to a sector
+	MarketClientQuotas(ctx context.Context) ([]ClientQuotaUsage, error)
 	MarketSetAsk(ctx context.Context, price types.BigInt, verifiedPrice types.BigInt, duration abi.ChainEpoch, minPieceSize abi.PaddedPieceSize, maxPieceSize abi.PaddedPieceSize) error
 	MarketGetAsk(ctx context.Context) (*storagemarket.SignedStorageAsk, error)
+	// MarketGetAskHistory returns up to limit of the latest signed storage
+	// asks, newest first; 0 for all recorded asks
+	MarketGetAskHistory(ctx context.Context, limit int) ([]*storagemarket.SignedStorageAsk, error)
 	MarketSetRetrievalAsk(ctx context.Context, rask *retrievalmarket.Ask) error
 	MarketGetRetrievalAsk(ctx context.Context) (*retrievalmarket.Ask, error)
+	// MarketHTTPRetrievalToken issues a token for the HTTP retrieval
+	// transport, which clients retrieving over HTTP send with their requests.
+	// It doesn't give access to the API of the miner.
+	MarketHTTPRetrievalToken(ctx context.Context) (string, error)
 	MarketListDataTransfers(ctx context.Context) ([]DataTransferChannel, error)
 	MarketDataTransferUpdates(ctx context.Context) (<-chan DataTransferChannel, error)
-	// MinerRestartDataTransfer attempts to restart a data transfer with the given transfer ID and other peer
+	// MarketRestartDataTransfer attempts to restart a data transfer with the given transfer ID and other peer
 	MarketRestartDataTransfer(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error
-	// ClientCancelDataTransfer cancels a data transfer with the given transfer ID and other peer
+	// MarketCancelDataTransfer cancels a data transfer with the given transfer ID and other peer.
+	// The storage or retrieval deal the transfer belongs to fails with it
 	MarketCancelDataTransfer(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error
+	// MarketGetTransferLimits returns the bandwidth limits of data transfers
+	MarketGetTransferLimits(ctx context.Context) (TransferLimits, error)
+	// MarketSetTransferLimits changes the bandwidth limits of data transfers,
+	// including of the ongoing ones, and saves them to the config
+	MarketSetTransferLimits(ctx context.Context, limits TransferLimits) error
 
 	DealsImportData(ctx context.Context, dealPropCid cid.Cid, file string) error
 	DealsList(ctx context.Context) ([]MarketDeal, error)
+	// DealsCommitEstimates returns when the sectors of deals which aren't
+	// committed yet are expected to be, based on recent sealing durations
+	DealsCommitEstimates(ctx context.Context) ([]DealCommitEstimate, error)
 	DealsConsiderOnlineStorageDeals(context.Context) (bool, error)
 	DealsSetConsiderOnlineStorageDeals(context.Context, bool) error
 	DealsConsiderOnlineRetrievalDeals(context.Context) (bool, error)
@@ -133,6 +232,13 @@ type StorageMiner interface {
 	PiecesListCidInfos(ctx context.Context) ([]cid.Cid, error)
 	PiecesGetPieceInfo(ctx context.Context, pieceCid cid.Cid) (*piecestore.PieceInfo, error)
 	PiecesGetCIDInfo(ctx context.Context, payloadCid cid.Cid) (*piecestore.CIDInfo, error)
+	// PiecesLocateBlock returns the locations of a payload block in the
+	// sectors of the miner
+	PiecesLocateBlock(ctx context.Context, block cid.Cid) ([]BlockLocation, error)
+	// PiecesRebuildIndex records the locations of all payload blocks of a
+	// piece, read from an unsealed copy of a sector storing it (unsealing it
+	// if needed), and returns the number of blocks found
+	PiecesRebuildIndex(ctx context.Context, pieceCid cid.Cid) (int, error)
 
 	// CreateBackup creates node backup onder the specified file name. The
 	// method requires that the lotus-miner is running with the
@@ -141,6 +247,30 @@ type StorageMiner interface {
 	CreateBackup(ctx context.Context, fpath string) error
 
 	CheckProvable(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, expensive bool) (map[abi.SectorNumber]string, error)
+	// CheckProvableSampled returns the sectors which can't be proven, with the
+	// reason. The files of all sectors are checked for presence and size; a
+	// random sampleRate fraction (0 to 1) of the sectors additionally have
+	// their sealed data read at random PoSt challenges, which finds unreadable
+	// or corrupt data.
+	CheckProvableSampled(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, sampleRate float64) (map[abi.SectorNumber]string, error)
+
+	// ProvingPrechecks returns the results of the last checks of sectors of
+	// upcoming window PoSt deadlines, by deadline index. Deadlines are checked
+	// when they are less than Storage.PoStPrecheckEpochs away.
+	ProvingPrechecks(ctx context.Context) ([]PoStPrecheck, error)
+
+	// ProvingRecoveries returns the results of the last checks of faulty
+	// sectors, by deadline index. Recoveries of sectors which pass the check
+	// are declared, unless Storage.PoStDisableRecoveryDeclarations is set.
+	ProvingRecoveries(ctx context.Context) ([]PoStRecovery, error)
+
+	// SLAReportGenerate generates a report of proving performance, deal
+	// activation latency and retrieval serving over the given epoch range,
+	// signed by the worker key. The report is also kept in the report history.
+	SLAReportGenerate(ctx context.Context, from, to abi.ChainEpoch) (*SignedSLAReport, error)
+	// SLAReportList returns up to limit of the latest reports, newest first;
+	// 0 for all kept reports
+	SLAReportList(ctx context.Context, limit int) ([]*SignedSLAReport, error)
 }
 
 type SealRes struct {
@@ -159,6 +289,40 @@ type SectorLog struct {
 	Message string
 }
 
+// DealCommitEstimate is when the sector holding a deal is expected to be
+// committed
+type DealCommitEstimate struct {
+	DealID abi.DealID
+	// Sector and State are unset for deals waiting to be added to a sector
+	Sector abi.SectorNumber
+	State  SectorState
+	// Estimate is zero when the sector isn't sealing normally, or there are
+	// no committed sectors to estimate from
+	Estimate time.Time
+}
+
+// SectorStateSummary aggregates the sectors in a sealing state
+type SectorStateSummary struct {
+	Count int
+	// OldestSince is when the sector which has been in the state the longest
+	// entered it
+	OldestSince time.Time
+	// DealBytes is the total padded size of deal pieces in the sectors
+	DealBytes abi.PaddedPieceSize
+}
+
+// SectorStateTransition records a sealing state machine transition of a sector
+type SectorStateTransition struct {
+	From SectorState
+	To   SectorState
+	// Trigger is the event which caused the transition
+	Trigger string
+	Time    time.Time
+	// Duration is the time the sector spent in the From state, zero if unknown
+	Duration time.Duration
+	Error    string
+}
+
 type SectorInfo struct {
 	SectorID     abi.SectorNumber
 	State        SectorState
@@ -173,6 +337,11 @@ type SectorInfo struct {
 	Retries      uint64
 	ToUpgrade    bool
 
+	// consecutive retries from failure states, and when the sector will be
+	// retried next (unix timestamp, 0 if it isn't waiting for a retry)
+	FailureRetries uint64
+	NextRetry      uint64
+
 	LastErr string
 
 	Log []SectorLog
@@ -189,6 +358,17 @@ type SectorInfo struct {
 	// non-zero if sector is faulty, epoch at which it will be permanently
 	// removed if it doesn't recover
 	Early abi.ChainEpoch
+	// Epoch during which the sector was precommitted, only known until the
+	// sector is proven
+	PreCommitEpoch abi.ChainEpoch
+	// Location of the sector in the proving period, nil if it isn't proven
+	Deadline  *uint64
+	Partition *uint64
+	// Fault state of the sector
+	Faulty     bool
+	Recovering bool
+	// Discrepancies between the local sealing state and the chain state
+	Discrepancies []string
 }
 
 type SealedRef struct {
@@ -221,6 +401,188 @@ func (st *SealSeed) Equals(ost *SealSeed) bool {
 
 type SectorState string
 
+type AddPieceQueueInfo struct {
+	// pieces waiting for, or being written into a sector
+	Pending    uint64
+	MaxPending uint64 // 0 = no limit
+	// how long the longest waiting piece has been pending
+	OldestWait time.Duration
+
+	// pieces rejected because the queue was full, or which timed out while
+	// waiting for a sector, since the node started
+	Rejected uint64
+	TimedOut uint64
+}
+
+type ClientQuotaUsage struct {
+	Client address.Address
+
+	// deals which haven't failed, been slashed or expired
+	ActiveDeals     uint64
+	ActiveDealBytes uint64
+	// pieces waiting to be added to a sector
+	PendingPieces uint64
+
+	// 0 = no limit
+	MaxActiveDeals     uint64
+	MaxActiveDealBytes uint64
+	MaxPendingPieces   uint64
+}
+
+// PieceDealInfo describes the deal a piece passed to SectorAddPieceToAny
+// belongs to
+type PieceDealInfo struct {
+	PublishCid   *cid.Cid
+	DealID       abi.DealID
+	StartEpoch   abi.ChainEpoch
+	EndEpoch     abi.ChainEpoch
+	KeepUnsealed bool
+	// Labels constrain which pieces the piece can share a sector with, see
+	// the ExclusiveLabels and DedicatedLabels sealing config
+	Labels []string
+}
+
+// TransferLimits are bandwidth limits of data transfers, in bytes per second.
+// 0 = no limit
+type TransferLimits struct {
+	// limit of each transfer
+	PerTransfer uint64
+	// limit of all transfers together
+	Total uint64
+}
+
+// BlockLocation is the location of a payload block in a sector
+type BlockLocation struct {
+	PieceCID cid.Cid
+	DealID   abi.DealID
+	Sector   abi.SectorNumber
+	// offset of the piece in the sector
+	PieceOffset abi.PaddedPieceSize
+	// offset and size of the block data in the unpadded piece
+	BlockOffset uint64
+	BlockSize   uint64
+}
+
+type WorkerCredential struct {
+	ID          uuid.UUID
+	Certificate string
+}
+
+type WorkerIdentity struct {
+	ID     uuid.UUID
+	Name   string
+	Joined time.Time
+	// The certificate of the worker is valid until then, unless renewed
+	Expires time.Time
+	Revoked bool
+}
+
+type SectorOffset struct {
+	Sector abi.SectorNumber
+	Offset abi.PaddedPieceSize
+}
+
+// PieceLease is space reserved in a sector for a piece, see SectorReservePiece
+type PieceLease struct {
+	ID      uint64
+	Sector  abi.SectorNumber
+	Offset  abi.PaddedPieceSize
+	Size    abi.UnpaddedPieceSize
+	DealID  abi.DealID
+	Expires time.Time
+}
+
+type BlockCandidate struct {
+	Base         types.TipSetKey
+	Height       abi.ChainEpoch
+	NullRounds   abi.ChainEpoch
+	ParentWeight types.BigInt
+
+	Messages []BlockCandidateMessage
+	GasLimit int64
+
+	// reward for a single win, and the estimated gas premiums paid by the
+	// selected messages
+	BlockReward abi.TokenAmount
+	GasReward   abi.TokenAmount
+}
+
+type BlockCandidateMessage struct {
+	Cid       cid.Cid
+	From      address.Address
+	Nonce     uint64
+	GasLimit  int64
+	GasReward abi.TokenAmount
+}
+
+type MinedBlock struct {
+	Cid        cid.Cid
+	Height     abi.ChainEpoch
+	Parents    types.TipSetKey
+	NullRounds abi.ChainEpoch
+	Messages   int
+	Mined      time.Time
+
+	Timings MinedBlockTimings
+}
+
+type MinedBlockTimings struct {
+	MinerBaseInfo  time.Duration
+	Election       time.Duration // ticket and winner check
+	Seed           time.Duration
+	WinningPoSt    time.Duration
+	SelectMessages time.Duration
+	CreateBlock    time.Duration
+	Total          time.Duration
+}
+
+// PoStPrecheck is the result of checking sectors of a window PoSt deadline
+// before it opens
+type PoStPrecheck struct {
+	Deadline uint64
+	Open     abi.ChainEpoch
+	// Height of the tipset the check was started at
+	Height abi.ChainEpoch
+
+	Partitions []PoStPrecheckPartition
+
+	// Faults for the unprovable sectors are declared if the check finishes
+	// before the fault declaration cutoff of the deadline
+	DeclareMessage *cid.Cid
+	Error          string
+}
+
+type PoStPrecheckPartition struct {
+	Index   uint64
+	Checked uint64
+	// Sectors which failed the check
+	Faulty bitfield.BitField
+}
+
+// PoStRecovery is the result of checking faulty sectors of a window PoSt
+// deadline, which weren't declared as recovering yet
+type PoStRecovery struct {
+	Deadline uint64
+	// Height of the tipset the check was started at
+	Height abi.ChainEpoch
+
+	Partitions []PoStRecoveryPartition
+
+	// Set when recoveries were declared for the sectors which passed the
+	// check. Declared sectors are proven again by the next proof of the
+	// deadline.
+	DeclareMessage *cid.Cid
+	Declared       bool
+	Error          string
+}
+
+type PoStRecoveryPartition struct {
+	Index  uint64
+	Faulty bitfield.BitField
+	// Faulty sectors which passed the check
+	Recovered bitfield.BitField
+}
+
 type AddrUse int
 
 const (
@@ -236,3 +598,86 @@ type AddressConfig struct {
 	CommitControl    []address.Address
 	TerminateControl []address.Address
 }
+
+// FundsPolicy sets how the funds manager maintains the available balance of
+// the miner actor
+type FundsPolicy struct {
+	// How often the balance is checked; 0 disables the funds manager
+	CheckInterval time.Duration
+	// The available balance is topped up to this amount when it falls below
+	// it; zero for no top-ups
+	TargetBalance abi.TokenAmount
+	// Address top-ups are sent from; undefined for the worker address
+	TopUpFrom address.Address
+	// The available balance above this amount is withdrawn to the owner; zero
+	// for no withdrawals
+	WithdrawAbove abi.TokenAmount
+	// Address withdrawn funds are forwarded to from the owner; undefined to
+	// keep them with the owner
+	Beneficiary address.Address
+}
+
+// SLAReport summarizes how the miner met its service levels over a range of
+// epochs, for clients requiring SLAs
+type SLAReport struct {
+	Miner address.Address
+	// Key address of the worker, which signs the report
+	Worker address.Address
+
+	From abi.ChainEpoch
+	To   abi.ChainEpoch
+	// Generated is when the report was generated, in UTC and second precision
+	Generated time.Time
+
+	Proving    SLAProvingStats
+	Deals      SLADealStats
+	Retrievals SLARetrievalStats
+}
+
+// SLAProvingStats covers the window PoSt deadlines which opened and closed
+// within the report range
+type SLAProvingStats struct {
+	// deadlines with live sectors, which needed a proof
+	DeadlinesDue    uint64
+	DeadlinesMet    uint64
+	DeadlinesMissed uint64
+
+	// sectors which became faulty during the range, and how many of them
+	// recovered
+	NewFaults        uint64
+	RecoveredSectors uint64
+	// sectors faulty at the end of the range
+	FaultySectors uint64
+
+	// epochs from sectors becoming faulty to being proven again
+	MeanRecoveryEpochs abi.ChainEpoch
+	MaxRecoveryEpochs  abi.ChainEpoch
+}
+
+// SLADealStats covers the storage deals activated within the report range
+type SLADealStats struct {
+	Activated uint64
+
+	// time from receiving the deal proposal to the deal sector being proven
+	MeanActivationLatency   time.Duration
+	MedianActivationLatency time.Duration
+	MaxActivationLatency    time.Duration
+}
+
+// SLARetrievalStats covers all retrieval deals tracked by the provider;
+// retrieval deals aren't timestamped, so they aren't limited to the report
+// range
+type SLARetrievalStats struct {
+	Completed uint64
+	Failed    uint64
+	Ongoing   uint64
+
+	BytesSent     uint64
+	FundsReceived abi.TokenAmount
+}
+
+type SignedSLAReport struct {
+	Report SLAReport
+	// Signature by the worker key over the JSON encoding of the report
+	Signature *crypto.Signature
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -40,6 +41,10 @@ type WorkerAPI interface {
 	// WaitQuiet blocks until there are no tasks running
 	WaitQuiet(ctx context.Context) error
 
+	// ProfileCapture captures the named runtime profile of the worker process,
+	// see the Common API ProfileCapture
+	ProfileCapture(ctx context.Context, name string, duration time.Duration) ([]byte, error)
+
 	// returns a random UUID of worker session, generated randomly when worker
 	// process starts
 	ProcessSession(context.Context) (uuid.UUID, error)
@@ -12,9 +12,15 @@ const (
 	PermWrite auth.Permission = "write"
 	PermSign  auth.Permission = "sign"  // Use wallet keys for signing
 	PermAdmin auth.Permission = "admin" // Manage permissions
+
+	// Connect workers to the miner and report their work, granted to worker
+	// identity certificates. Miner admin tokens have it as well.
+	PermWorker auth.Permission = "worker"
 )
 
-var AllPermissions = []auth.Permission{PermRead, PermWrite, PermSign, PermAdmin}
+// AllPermissions are ordered by privilege, so that admin tokens created with
+// 'auth create-token' include the worker permission
+var AllPermissions = []auth.Permission{PermRead, PermWrite, PermSign, PermWorker, PermAdmin}
 var DefaultPerms = []auth.Permission{PermRead}
 
 func PermissionedStorMinerAPI(a api.StorageMiner) api.StorageMiner {
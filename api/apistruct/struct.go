@@ -30,6 +30,7 @@ import (
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	marketevents "github.com/filecoin-project/lotus/markets/loggers"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/api"
@@ -48,21 +49,32 @@ type CommonStruct struct {
 		AuthVerify func(ctx context.Context, token string) ([]auth.Permission, error) `perm:"read"`
 		AuthNew    func(ctx context.Context, perms []auth.Permission) ([]byte, error) `perm:"admin"`
 
-		NetConnectedness            func(context.Context, peer.ID) (network.Connectedness, error)    `perm:"read"`
-		NetPeers                    func(context.Context) ([]peer.AddrInfo, error)                   `perm:"read"`
-		NetConnect                  func(context.Context, peer.AddrInfo) error                       `perm:"write"`
-		NetAddrsListen              func(context.Context) (peer.AddrInfo, error)                     `perm:"read"`
-		NetDisconnect               func(context.Context, peer.ID) error                             `perm:"write"`
-		NetFindPeer                 func(context.Context, peer.ID) (peer.AddrInfo, error)            `perm:"read"`
-		NetPubsubScores             func(context.Context) ([]api.PubsubScore, error)                 `perm:"read"`
-		NetAutoNatStatus            func(context.Context) (api.NatInfo, error)                       `perm:"read"`
-		NetBandwidthStats           func(ctx context.Context) (metrics.Stats, error)                 `perm:"read"`
-		NetBandwidthStatsByPeer     func(ctx context.Context) (map[string]metrics.Stats, error)      `perm:"read"`
-		NetBandwidthStatsByProtocol func(ctx context.Context) (map[protocol.ID]metrics.Stats, error) `perm:"read"`
-		NetAgentVersion             func(ctx context.Context, p peer.ID) (string, error)             `perm:"read"`
-		NetBlockAdd                 func(ctx context.Context, acl api.NetBlockList) error            `perm:"admin"`
-		NetBlockRemove              func(ctx context.Context, acl api.NetBlockList) error            `perm:"admin"`
-		NetBlockList                func(ctx context.Context) (api.NetBlockList, error)              `perm:"read"`
+		NetConnectedness            func(context.Context, peer.ID) (network.Connectedness, error)                `perm:"read"`
+		NetPeers                    func(context.Context) ([]peer.AddrInfo, error)                               `perm:"read"`
+		NetConnect                  func(context.Context, peer.AddrInfo) error                                   `perm:"write"`
+		NetAddrsListen              func(context.Context) (peer.AddrInfo, error)                                 `perm:"read"`
+		NetDisconnect               func(context.Context, peer.ID) error                                         `perm:"write"`
+		NetFindPeer                 func(context.Context, peer.ID) (peer.AddrInfo, error)                        `perm:"read"`
+		NetPubsubScores             func(context.Context) ([]api.PubsubScore, error)                             `perm:"read"`
+		NetAutoNatStatus            func(context.Context) (api.NatInfo, error)                                   `perm:"read"`
+		NetBandwidthStats           func(ctx context.Context) (metrics.Stats, error)                             `perm:"read"`
+		NetBandwidthStatsByPeer     func(ctx context.Context) (map[string]metrics.Stats, error)                  `perm:"read"`
+		NetBandwidthStatsByProtocol func(ctx context.Context) (map[protocol.ID]metrics.Stats, error)             `perm:"read"`
+		NetBandwidthHistory         func(ctx context.Context, since time.Time) ([]api.NetBandwidthSample, error) `perm:"read"`
+		NetAgentVersion             func(ctx context.Context, p peer.ID) (string, error)                         `perm:"read"`
+		NetBlockAdd                 func(ctx context.Context, acl api.NetBlockList) error                        `perm:"admin"`
+		NetBlockRemove              func(ctx context.Context, acl api.NetBlockList) error                        `perm:"admin"`
+		NetBlockList                func(ctx context.Context) (api.NetBlockList, error)                          `perm:"read"`
+
+		BusTopics    func(context.Context) ([]string, error)                                 `perm:"read"`
+		BusSubscribe func(ctx context.Context, topics []string) (<-chan api.BusEvent, error) `perm:"read"`
+
+		ProfileCapture func(ctx context.Context, name string, duration time.Duration) ([]byte, error) `perm:"admin"`
+		ProfileInfo    func(context.Context) (api.ProfileInfo, error)                                 `perm:"admin"`
+
+		SubsystemList  func(context.Context) ([]api.SubsystemStatus, error) `perm:"read"`
+		SubsystemStart func(ctx context.Context, name string) error         `perm:"admin"`
+		SubsystemStop  func(ctx context.Context, name string) error         `perm:"admin"`
 
 		ID      func(context.Context) (peer.ID, error)     `perm:"read"`
 		Version func(context.Context) (api.Version, error) `perm:"read"`
@@ -82,6 +94,7 @@ type FullNodeStruct struct {
 
 	Internal struct {
 		ChainNotify                   func(context.Context) (<-chan []*api.HeadChange, error)                                                            `perm:"read"`
+		ChainNotifyMessages           func(context.Context, api.MessageFilter) (<-chan []api.MessageEvent, error)                                        `perm:"read"`
 		ChainHead                     func(context.Context) (*types.TipSet, error)                                                                       `perm:"read"`
 		ChainGetRandomnessFromTickets func(context.Context, types.TipSetKey, crypto.DomainSeparationTag, abi.ChainEpoch, []byte) (abi.Randomness, error) `perm:"read"`
 		ChainGetRandomnessFromBeacon  func(context.Context, types.TipSetKey, crypto.DomainSeparationTag, abi.ChainEpoch, []byte) (abi.Randomness, error) `perm:"read"`
@@ -89,8 +102,11 @@ type FullNodeStruct struct {
 		ChainGetTipSet                func(context.Context, types.TipSetKey) (*types.TipSet, error)                                                      `perm:"read"`
 		ChainGetBlockMessages         func(context.Context, cid.Cid) (*api.BlockMessages, error)                                                         `perm:"read"`
 		ChainGetParentReceipts        func(context.Context, cid.Cid) ([]*types.MessageReceipt, error)                                                    `perm:"read"`
+		ChainGetParentMessagesStream  func(context.Context, cid.Cid, uint64) (<-chan api.ParentMessagesChunk, error)                                     `perm:"read"`
+		ChainGetNetworkStats          func(context.Context, abi.ChainEpoch, abi.ChainEpoch) (*api.NetworkStats, error)                                   `perm:"read"`
 		ChainGetParentMessages        func(context.Context, cid.Cid) ([]api.Message, error)                                                              `perm:"read"`
 		ChainGetTipSetByHeight        func(context.Context, abi.ChainEpoch, types.TipSetKey) (*types.TipSet, error)                                      `perm:"read"`
+		ChainGetTipSetAtHeight        func(context.Context, abi.ChainEpoch, api.HeightAnchor, types.TipSetKey) (*api.TipSetAtHeight, error)              `perm:"read"`
 		ChainReadObj                  func(context.Context, cid.Cid) ([]byte, error)                                                                     `perm:"read"`
 		ChainDeleteObj                func(context.Context, cid.Cid) error                                                                               `perm:"admin"`
 		ChainHasObj                   func(context.Context, cid.Cid) (bool, error)                                                                       `perm:"read"`
@@ -102,6 +118,8 @@ type FullNodeStruct struct {
 		ChainGetMessage               func(context.Context, cid.Cid) (*types.Message, error)                                                             `perm:"read"`
 		ChainGetPath                  func(context.Context, types.TipSetKey, types.TipSetKey) ([]*api.HeadChange, error)                                 `perm:"read"`
 		ChainExport                   func(context.Context, abi.ChainEpoch, bool, types.TipSetKey) (<-chan []byte, error)                                `perm:"read"`
+		ChainPrune                    func(context.Context) error                                                                                        `perm:"admin"`
+		ChainGCBlockstore             func(context.Context) ([]api.BlockstoreGCStats, error)                                                             `perm:"admin"`
 
 		BeaconGetEntry func(ctx context.Context, epoch abi.ChainEpoch) (*types.BeaconEntry, error) `perm:"read"`
 
@@ -114,6 +132,9 @@ type FullNodeStruct struct {
 		SyncSubmitBlock    func(ctx context.Context, blk *types.BlockMsg) error         `perm:"write"`
 		SyncIncomingBlocks func(ctx context.Context) (<-chan *types.BlockHeader, error) `perm:"read"`
 		SyncCheckpoint     func(ctx context.Context, key types.TipSetKey) error         `perm:"admin"`
+		SyncGetCheckpoint  func(ctx context.Context) (types.TipSetKey, error)           `perm:"read"`
+		SyncPeers          func(ctx context.Context) ([]api.SyncPeer, error)            `perm:"read"`
+		SyncUnbanPeer      func(ctx context.Context, p peer.ID) error                   `perm:"admin"`
 		SyncMarkBad        func(ctx context.Context, bcid cid.Cid) error                `perm:"admin"`
 		SyncUnmarkBad      func(ctx context.Context, bcid cid.Cid) error                `perm:"admin"`
 		SyncUnmarkAllBad   func(ctx context.Context) error                              `perm:"admin"`
@@ -156,78 +177,87 @@ type FullNodeStruct struct {
 		WalletDelete          func(context.Context, address.Address) error                                         `perm:"write"`
 		WalletValidateAddress func(context.Context, string) (address.Address, error)                               `perm:"read"`
 
-		ClientImport                              func(ctx context.Context, ref api.FileRef) (*api.ImportRes, error)                                                `perm:"admin"`
-		ClientListImports                         func(ctx context.Context) ([]api.Import, error)                                                                   `perm:"write"`
-		ClientRemoveImport                        func(ctx context.Context, importID multistore.StoreID) error                                                      `perm:"admin"`
-		ClientHasLocal                            func(ctx context.Context, root cid.Cid) (bool, error)                                                             `perm:"write"`
-		ClientFindData                            func(ctx context.Context, root cid.Cid, piece *cid.Cid) ([]api.QueryOffer, error)                                 `perm:"read"`
-		ClientMinerQueryOffer                     func(ctx context.Context, miner address.Address, root cid.Cid, piece *cid.Cid) (api.QueryOffer, error)            `perm:"read"`
-		ClientStartDeal                           func(ctx context.Context, params *api.StartDealParams) (*cid.Cid, error)                                          `perm:"admin"`
-		ClientGetDealInfo                         func(context.Context, cid.Cid) (*api.DealInfo, error)                                                             `perm:"read"`
-		ClientGetDealStatus                       func(context.Context, uint64) (string, error)                                                                     `perm:"read"`
-		ClientListDeals                           func(ctx context.Context) ([]api.DealInfo, error)                                                                 `perm:"write"`
-		ClientGetDealUpdates                      func(ctx context.Context) (<-chan api.DealInfo, error)                                                            `perm:"read"`
-		ClientRetrieve                            func(ctx context.Context, order api.RetrievalOrder, ref *api.FileRef) error                                       `perm:"admin"`
-		ClientRetrieveWithEvents                  func(ctx context.Context, order api.RetrievalOrder, ref *api.FileRef) (<-chan marketevents.RetrievalEvent, error) `perm:"admin"`
-		ClientQueryAsk                            func(ctx context.Context, p peer.ID, miner address.Address) (*storagemarket.StorageAsk, error)                    `perm:"read"`
-		ClientDealPieceCID                        func(ctx context.Context, root cid.Cid) (api.DataCIDSize, error)                                                  `perm:"read"`
-		ClientCalcCommP                           func(ctx context.Context, inpath string) (*api.CommPRet, error)                                                   `perm:"read"`
-		ClientGenCar                              func(ctx context.Context, ref api.FileRef, outpath string) error                                                  `perm:"write"`
-		ClientDealSize                            func(ctx context.Context, root cid.Cid) (api.DataSize, error)                                                     `perm:"read"`
-		ClientListDataTransfers                   func(ctx context.Context) ([]api.DataTransferChannel, error)                                                      `perm:"write"`
-		ClientDataTransferUpdates                 func(ctx context.Context) (<-chan api.DataTransferChannel, error)                                                 `perm:"write"`
-		ClientRestartDataTransfer                 func(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error          `perm:"write"`
-		ClientCancelDataTransfer                  func(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error          `perm:"write"`
-		ClientRetrieveTryRestartInsufficientFunds func(ctx context.Context, paymentChannel address.Address) error                                                   `perm:"write"`
-
-		StateNetworkName                   func(context.Context) (dtypes.NetworkName, error)                                                                   `perm:"read"`
-		StateMinerSectors                  func(context.Context, address.Address, *bitfield.BitField, types.TipSetKey) ([]*miner.SectorOnChainInfo, error)     `perm:"read"`
-		StateMinerActiveSectors            func(context.Context, address.Address, types.TipSetKey) ([]*miner.SectorOnChainInfo, error)                         `perm:"read"`
-		StateMinerProvingDeadline          func(context.Context, address.Address, types.TipSetKey) (*dline.Info, error)                                        `perm:"read"`
-		StateMinerPower                    func(context.Context, address.Address, types.TipSetKey) (*api.MinerPower, error)                                    `perm:"read"`
-		StateMinerInfo                     func(context.Context, address.Address, types.TipSetKey) (miner.MinerInfo, error)                                    `perm:"read"`
-		StateMinerDeadlines                func(context.Context, address.Address, types.TipSetKey) ([]api.Deadline, error)                                     `perm:"read"`
-		StateMinerPartitions               func(ctx context.Context, m address.Address, dlIdx uint64, tsk types.TipSetKey) ([]api.Partition, error)            `perm:"read"`
-		StateMinerFaults                   func(context.Context, address.Address, types.TipSetKey) (bitfield.BitField, error)                                  `perm:"read"`
-		StateAllMinerFaults                func(context.Context, abi.ChainEpoch, types.TipSetKey) ([]*api.Fault, error)                                        `perm:"read"`
-		StateMinerRecoveries               func(context.Context, address.Address, types.TipSetKey) (bitfield.BitField, error)                                  `perm:"read"`
-		StateMinerPreCommitDepositForPower func(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error)            `perm:"read"`
-		StateMinerInitialPledgeCollateral  func(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error)            `perm:"read"`
-		StateMinerAvailableBalance         func(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)                                       `perm:"read"`
-		StateMinerSectorAllocated          func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (bool, error)                             `perm:"read"`
-		StateSectorPreCommitInfo           func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (miner.SectorPreCommitOnChainInfo, error) `perm:"read"`
-		StateSectorGetInfo                 func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (*miner.SectorOnChainInfo, error)         `perm:"read"`
-		StateSectorExpiration              func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (*miner.SectorExpiration, error)          `perm:"read"`
-		StateSectorPartition               func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (*miner.SectorLocation, error)            `perm:"read"`
-		StateCall                          func(context.Context, *types.Message, types.TipSetKey) (*api.InvocResult, error)                                    `perm:"read"`
-		StateReplay                        func(context.Context, types.TipSetKey, cid.Cid) (*api.InvocResult, error)                                           `perm:"read"`
-		StateGetActor                      func(context.Context, address.Address, types.TipSetKey) (*types.Actor, error)                                       `perm:"read"`
-		StateReadState                     func(context.Context, address.Address, types.TipSetKey) (*api.ActorState, error)                                    `perm:"read"`
-		StateWaitMsg                       func(ctx context.Context, cid cid.Cid, confidence uint64) (*api.MsgLookup, error)                                   `perm:"read"`
-		StateWaitMsgLimited                func(context.Context, cid.Cid, uint64, abi.ChainEpoch) (*api.MsgLookup, error)                                      `perm:"read"`
-		StateSearchMsg                     func(context.Context, cid.Cid) (*api.MsgLookup, error)                                                              `perm:"read"`
-		StateSearchMsgLimited              func(context.Context, cid.Cid, abi.ChainEpoch) (*api.MsgLookup, error)                                              `perm:"read"`
-		StateListMiners                    func(context.Context, types.TipSetKey) ([]address.Address, error)                                                   `perm:"read"`
-		StateListActors                    func(context.Context, types.TipSetKey) ([]address.Address, error)                                                   `perm:"read"`
-		StateMarketBalance                 func(context.Context, address.Address, types.TipSetKey) (api.MarketBalance, error)                                  `perm:"read"`
-		StateMarketParticipants            func(context.Context, types.TipSetKey) (map[string]api.MarketBalance, error)                                        `perm:"read"`
-		StateMarketDeals                   func(context.Context, types.TipSetKey) (map[string]api.MarketDeal, error)                                           `perm:"read"`
-		StateMarketStorageDeal             func(context.Context, abi.DealID, types.TipSetKey) (*api.MarketDeal, error)                                         `perm:"read"`
-		StateLookupID                      func(ctx context.Context, addr address.Address, tsk types.TipSetKey) (address.Address, error)                       `perm:"read"`
-		StateAccountKey                    func(context.Context, address.Address, types.TipSetKey) (address.Address, error)                                    `perm:"read"`
-		StateChangedActors                 func(context.Context, cid.Cid, cid.Cid) (map[string]types.Actor, error)                                             `perm:"read"`
-		StateGetReceipt                    func(context.Context, cid.Cid, types.TipSetKey) (*types.MessageReceipt, error)                                      `perm:"read"`
-		StateMinerSectorCount              func(context.Context, address.Address, types.TipSetKey) (api.MinerSectors, error)                                   `perm:"read"`
-		StateListMessages                  func(ctx context.Context, match *api.MessageMatch, tsk types.TipSetKey, toht abi.ChainEpoch) ([]cid.Cid, error)     `perm:"read"`
-		StateDecodeParams                  func(context.Context, address.Address, abi.MethodNum, []byte, types.TipSetKey) (interface{}, error)                 `perm:"read"`
-		StateCompute                       func(context.Context, abi.ChainEpoch, []*types.Message, types.TipSetKey) (*api.ComputeStateOutput, error)           `perm:"read"`
-		StateVerifierStatus                func(context.Context, address.Address, types.TipSetKey) (*abi.StoragePower, error)                                  `perm:"read"`
-		StateVerifiedClientStatus          func(context.Context, address.Address, types.TipSetKey) (*abi.StoragePower, error)                                  `perm:"read"`
-		StateVerifiedRegistryRootKey       func(ctx context.Context, tsk types.TipSetKey) (address.Address, error)                                             `perm:"read"`
-		StateDealProviderCollateralBounds  func(context.Context, abi.PaddedPieceSize, bool, types.TipSetKey) (api.DealCollateralBounds, error)                 `perm:"read"`
-		StateCirculatingSupply             func(context.Context, types.TipSetKey) (abi.TokenAmount, error)                                                     `perm:"read"`
-		StateVMCirculatingSupplyInternal   func(context.Context, types.TipSetKey) (api.CirculatingSupply, error)                                               `perm:"read"`
-		StateNetworkVersion                func(context.Context, types.TipSetKey) (stnetwork.Version, error)                                                   `perm:"read"`
+		ClientImport                              func(ctx context.Context, ref api.FileRef) (*api.ImportRes, error)                                                                            `perm:"admin"`
+		ClientListImports                         func(ctx context.Context) ([]api.Import, error)                                                                                               `perm:"write"`
+		ClientRemoveImport                        func(ctx context.Context, importID multistore.StoreID) error                                                                                  `perm:"admin"`
+		ClientHasLocal                            func(ctx context.Context, root cid.Cid) (bool, error)                                                                                         `perm:"write"`
+		ClientFindData                            func(ctx context.Context, root cid.Cid, piece *cid.Cid) ([]api.QueryOffer, error)                                                             `perm:"read"`
+		ClientMinerQueryOffer                     func(ctx context.Context, miner address.Address, root cid.Cid, piece *cid.Cid) (api.QueryOffer, error)                                        `perm:"read"`
+		ClientStartDeal                           func(ctx context.Context, params *api.StartDealParams) (*cid.Cid, error)                                                                      `perm:"admin"`
+		ClientCreateDealAllowance                 func(ctx context.Context, client, delegate address.Address, amount types.BigInt, expiration abi.ChainEpoch) (*api.SignedDealAllowance, error) `perm:"admin"`
+		ClientListDealAllowances                  func(ctx context.Context) ([]api.DealAllowanceInfo, error)                                                                                    `perm:"read"`
+		ClientRevokeDealAllowance                 func(ctx context.Context, id uint64) error                                                                                                    `perm:"admin"`
+		ClientStartDelegatedDeal                  func(ctx context.Context, req *api.DelegatedDealRequest) (*cid.Cid, error)                                                                    `perm:"write"`
+		ClientGetDealInfo                         func(context.Context, cid.Cid) (*api.DealInfo, error)                                                                                         `perm:"read"`
+		ClientGetDealStatus                       func(context.Context, uint64) (string, error)                                                                                                 `perm:"read"`
+		ClientListDeals                           func(ctx context.Context) ([]api.DealInfo, error)                                                                                             `perm:"write"`
+		ClientProviderScores                      func(ctx context.Context) ([]api.ProviderScore, error)                                                                                        `perm:"write"`
+		ClientGetDealUpdates                      func(ctx context.Context) (<-chan api.DealInfo, error)                                                                                        `perm:"read"`
+		ClientRetrieve                            func(ctx context.Context, order api.RetrievalOrder, ref *api.FileRef) error                                                                   `perm:"admin"`
+		ClientRetrieveWithEvents                  func(ctx context.Context, order api.RetrievalOrder, ref *api.FileRef) (<-chan marketevents.RetrievalEvent, error)                             `perm:"admin"`
+		ClientQueryAsk                            func(ctx context.Context, p peer.ID, miner address.Address) (*storagemarket.StorageAsk, error)                                                `perm:"read"`
+		ClientDealPieceCID                        func(ctx context.Context, root cid.Cid) (api.DataCIDSize, error)                                                                              `perm:"read"`
+		ClientCalcCommP                           func(ctx context.Context, inpath string) (*api.CommPRet, error)                                                                               `perm:"read"`
+		ClientGenCar                              func(ctx context.Context, ref api.FileRef, outpath string) error                                                                              `perm:"write"`
+		ClientDealSize                            func(ctx context.Context, root cid.Cid) (api.DataSize, error)                                                                                 `perm:"read"`
+		ClientListDataTransfers                   func(ctx context.Context) ([]api.DataTransferChannel, error)                                                                                  `perm:"write"`
+		ClientDataTransferUpdates                 func(ctx context.Context) (<-chan api.DataTransferChannel, error)                                                                             `perm:"write"`
+		ClientRestartDataTransfer                 func(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error                                      `perm:"write"`
+		ClientCancelDataTransfer                  func(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error                                      `perm:"write"`
+		ClientRetrieveTryRestartInsufficientFunds func(ctx context.Context, paymentChannel address.Address) error                                                                               `perm:"write"`
+
+		StateNetworkName                   func(context.Context) (dtypes.NetworkName, error)                                                                                   `perm:"read"`
+		StateMinerSectorsStream            func(context.Context, address.Address, *bitfield.BitField, abi.SectorNumber, types.TipSetKey) (<-chan api.MinerSectorsChunk, error) `perm:"read"`
+		StateMinerSectors                  func(context.Context, address.Address, *bitfield.BitField, types.TipSetKey) ([]*miner.SectorOnChainInfo, error)                     `perm:"read"`
+		StateMinerActiveSectors            func(context.Context, address.Address, types.TipSetKey) ([]*miner.SectorOnChainInfo, error)                                         `perm:"read"`
+		StateMinerProvingDeadline          func(context.Context, address.Address, types.TipSetKey) (*dline.Info, error)                                                        `perm:"read"`
+		StateMinerDeadlineNotify           func(context.Context, address.Address, abi.ChainEpoch, []uint64) (<-chan *api.DeadlineEvent, error)                                 `perm:"read"`
+		StateMinerPower                    func(context.Context, address.Address, types.TipSetKey) (*api.MinerPower, error)                                                    `perm:"read"`
+		StateMinerInfo                     func(context.Context, address.Address, types.TipSetKey) (miner.MinerInfo, error)                                                    `perm:"read"`
+		StateMinerDeadlines                func(context.Context, address.Address, types.TipSetKey) ([]api.Deadline, error)                                                     `perm:"read"`
+		StateMinerPartitions               func(ctx context.Context, m address.Address, dlIdx uint64, tsk types.TipSetKey) ([]api.Partition, error)                            `perm:"read"`
+		StateMinerFaults                   func(context.Context, address.Address, types.TipSetKey) (bitfield.BitField, error)                                                  `perm:"read"`
+		StateAllMinerFaults                func(context.Context, abi.ChainEpoch, types.TipSetKey) ([]*api.Fault, error)                                                        `perm:"read"`
+		StateMinerRecoveries               func(context.Context, address.Address, types.TipSetKey) (bitfield.BitField, error)                                                  `perm:"read"`
+		StateMinerPreCommitDepositForPower func(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error)                            `perm:"read"`
+		StateMinerInitialPledgeCollateral  func(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error)                            `perm:"read"`
+		StateMinerAvailableBalance         func(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)                                                       `perm:"read"`
+		StateMinerSectorAllocated          func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (bool, error)                                             `perm:"read"`
+		StateSectorPreCommitInfo           func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (miner.SectorPreCommitOnChainInfo, error)                 `perm:"read"`
+		StateSectorGetInfo                 func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (*miner.SectorOnChainInfo, error)                         `perm:"read"`
+		StateSectorExpiration              func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (*miner.SectorExpiration, error)                          `perm:"read"`
+		StateSectorPartition               func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (*miner.SectorLocation, error)                            `perm:"read"`
+		StateCall                          func(context.Context, *types.Message, types.TipSetKey) (*api.InvocResult, error)                                                    `perm:"read"`
+		StateCallMany                      func(context.Context, []*types.Message, types.TipSetKey) ([]*api.InvocResult, error)                                                `perm:"read"`
+		StateReplay                        func(context.Context, types.TipSetKey, cid.Cid) (*api.InvocResult, error)                                                           `perm:"read"`
+		StateGetActor                      func(context.Context, address.Address, types.TipSetKey) (*types.Actor, error)                                                       `perm:"read"`
+		StateReadState                     func(context.Context, address.Address, types.TipSetKey) (*api.ActorState, error)                                                    `perm:"read"`
+		StateWaitMsg                       func(ctx context.Context, cid cid.Cid, confidence uint64) (*api.MsgLookup, error)                                                   `perm:"read"`
+		StateWaitMsgLimited                func(context.Context, cid.Cid, uint64, abi.ChainEpoch) (*api.MsgLookup, error)                                                      `perm:"read"`
+		StateSearchMsg                     func(context.Context, cid.Cid) (*api.MsgLookup, error)                                                                              `perm:"read"`
+		StateSearchMsgLimited              func(context.Context, cid.Cid, abi.ChainEpoch) (*api.MsgLookup, error)                                                              `perm:"read"`
+		StateListMiners                    func(context.Context, types.TipSetKey) ([]address.Address, error)                                                                   `perm:"read"`
+		StateListActors                    func(context.Context, types.TipSetKey) ([]address.Address, error)                                                                   `perm:"read"`
+		StateMarketBalance                 func(context.Context, address.Address, types.TipSetKey) (api.MarketBalance, error)                                                  `perm:"read"`
+		StateMarketParticipants            func(context.Context, types.TipSetKey) (map[string]api.MarketBalance, error)                                                        `perm:"read"`
+		StateMarketDeals                   func(context.Context, types.TipSetKey) (map[string]api.MarketDeal, error)                                                           `perm:"read"`
+		StateMarketStorageDeal             func(context.Context, abi.DealID, types.TipSetKey) (*api.MarketDeal, error)                                                         `perm:"read"`
+		StateLookupID                      func(ctx context.Context, addr address.Address, tsk types.TipSetKey) (address.Address, error)                                       `perm:"read"`
+		StateAccountKey                    func(context.Context, address.Address, types.TipSetKey) (address.Address, error)                                                    `perm:"read"`
+		StateChangedActors                 func(context.Context, cid.Cid, cid.Cid) (map[string]types.Actor, error)                                                             `perm:"read"`
+		StateGetReceipt                    func(context.Context, cid.Cid, types.TipSetKey) (*types.MessageReceipt, error)                                                      `perm:"read"`
+		StateGetReceipts                   func(context.Context, types.TipSetKey) ([]api.MsgReceipt, error)                                                                    `perm:"read"`
+		StateMinerSectorCount              func(context.Context, address.Address, types.TipSetKey) (api.MinerSectors, error)                                                   `perm:"read"`
+		StateListMessages                  func(ctx context.Context, match *api.MessageMatch, tsk types.TipSetKey, toht abi.ChainEpoch) ([]cid.Cid, error)                     `perm:"read"`
+		StateDecodeParams                  func(context.Context, address.Address, abi.MethodNum, []byte, types.TipSetKey) (interface{}, error)                                 `perm:"read"`
+		StateCompute                       func(context.Context, abi.ChainEpoch, []*types.Message, types.TipSetKey) (*api.ComputeStateOutput, error)                           `perm:"read"`
+		StateVerifierStatus                func(context.Context, address.Address, types.TipSetKey) (*abi.StoragePower, error)                                                  `perm:"read"`
+		StateVerifiedClientStatus          func(context.Context, address.Address, types.TipSetKey) (*abi.StoragePower, error)                                                  `perm:"read"`
+		StateVerifiedRegistryRootKey       func(ctx context.Context, tsk types.TipSetKey) (address.Address, error)                                                             `perm:"read"`
+		StateDealProviderCollateralBounds  func(context.Context, abi.PaddedPieceSize, bool, types.TipSetKey) (api.DealCollateralBounds, error)                                 `perm:"read"`
+		StateCirculatingSupply             func(context.Context, types.TipSetKey) (abi.TokenAmount, error)                                                                     `perm:"read"`
+		StateVMCirculatingSupplyInternal   func(context.Context, types.TipSetKey) (api.CirculatingSupply, error)                                                               `perm:"read"`
+		StateNetworkVersion                func(context.Context, types.TipSetKey) (stnetwork.Version, error)                                                                   `perm:"read"`
 
 		MsigGetAvailableBalance func(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)                                                                    `perm:"read"`
 		MsigGetVestingSchedule  func(context.Context, address.Address, types.TipSetKey) (api.MsigVesting, error)                                                                 `perm:"read"`
@@ -269,6 +299,12 @@ type FullNodeStruct struct {
 		PaychVoucherList            func(context.Context, address.Address) ([]*paych.SignedVoucher, error)                                    `perm:"write"`
 		PaychVoucherSubmit          func(context.Context, address.Address, *paych.SignedVoucher, []byte, []byte) (cid.Cid, error)             `perm:"sign"`
 
+		EpochTaskAdd    func(context.Context, api.EpochTask) (uint64, error) `perm:"admin"`
+		EpochTaskList   func(context.Context) ([]api.EpochTask, error)       `perm:"read"`
+		EpochTaskRemove func(ctx context.Context, id uint64) error           `perm:"admin"`
+
+		NetDiag func(context.Context) (api.NetDiagnostics, error) `perm:"write"`
+
 		CreateBackup func(ctx context.Context, fpath string) error `perm:"admin"`
 	}
 }
@@ -281,11 +317,15 @@ type StorageMinerStruct struct {
 	CommonStruct
 
 	Internal struct {
-		ActorAddress       func(context.Context) (address.Address, error)                 `perm:"read"`
-		ActorSectorSize    func(context.Context, address.Address) (abi.SectorSize, error) `perm:"read"`
-		ActorAddressConfig func(ctx context.Context) (api.AddressConfig, error)           `perm:"read"`
+		ActorAddress        func(context.Context) (address.Address, error)                 `perm:"read"`
+		ActorSectorSize     func(context.Context, address.Address) (abi.SectorSize, error) `perm:"read"`
+		ActorAddressConfig  func(ctx context.Context) (api.AddressConfig, error)           `perm:"read"`
+		ActorFundsPolicy    func(ctx context.Context) (api.FundsPolicy, error)             `perm:"read"`
+		ActorSetFundsPolicy func(ctx context.Context, p api.FundsPolicy) error             `perm:"admin"`
 
-		MiningBase func(context.Context) (*types.TipSet, error) `perm:"read"`
+		MiningBase           func(context.Context) (*types.TipSet, error)       `perm:"read"`
+		MiningBlockCandidate func(context.Context) (*api.BlockCandidate, error) `perm:"read"`
+		MiningBlockHistory   func(context.Context) ([]api.MinedBlock, error)    `perm:"read"`
 
 		MarketImportDealData      func(context.Context, cid.Cid, string) error                                                                                                                                 `perm:"write"`
 		MarketListDeals           func(ctx context.Context) ([]api.MarketDeal, error)                                                                                                                          `perm:"read"`
@@ -293,67 +333,96 @@ type StorageMinerStruct struct {
 		MarketGetDealUpdates      func(ctx context.Context) (<-chan storagemarket.MinerDeal, error)                                                                                                            `perm:"read"`
 		MarketListIncompleteDeals func(ctx context.Context) ([]storagemarket.MinerDeal, error)                                                                                                                 `perm:"read"`
 		MarketSetAsk              func(ctx context.Context, price types.BigInt, verifiedPrice types.BigInt, duration abi.ChainEpoch, minPieceSize abi.PaddedPieceSize, maxPieceSize abi.PaddedPieceSize) error `perm:"admin"`
+		MarketClientQuotas        func(ctx context.Context) ([]api.ClientQuotaUsage, error)                                                                                                                    `perm:"read"`
 		MarketGetAsk              func(ctx context.Context) (*storagemarket.SignedStorageAsk, error)                                                                                                           `perm:"read"`
+		MarketGetAskHistory       func(ctx context.Context, limit int) ([]*storagemarket.SignedStorageAsk, error)                                                                                              `perm:"read"`
 		MarketSetRetrievalAsk     func(ctx context.Context, rask *retrievalmarket.Ask) error                                                                                                                   `perm:"admin"`
 		MarketGetRetrievalAsk     func(ctx context.Context) (*retrievalmarket.Ask, error)                                                                                                                      `perm:"read"`
+		MarketHTTPRetrievalToken  func(ctx context.Context) (string, error)                                                                                                                                    `perm:"admin"`
 		MarketListDataTransfers   func(ctx context.Context) ([]api.DataTransferChannel, error)                                                                                                                 `perm:"write"`
 		MarketDataTransferUpdates func(ctx context.Context) (<-chan api.DataTransferChannel, error)                                                                                                            `perm:"write"`
-		MarketRestartDataTransfer func(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error                                                                     `perm:"read"`
-		MarketCancelDataTransfer  func(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error                                                                     `perm:"read"`
+		MarketRestartDataTransfer func(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error                                                                     `perm:"write"`
+		MarketCancelDataTransfer  func(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error                                                                     `perm:"write"`
+		MarketGetTransferLimits   func(ctx context.Context) (api.TransferLimits, error)                                                                                                                        `perm:"read"`
+		MarketSetTransferLimits   func(ctx context.Context, limits api.TransferLimits) error                                                                                                                   `perm:"admin"`
 
 		PledgeSector func(context.Context) error `perm:"write"`
 
-		SectorsStatus                 func(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error) `perm:"read"`
-		SectorsList                   func(context.Context) ([]abi.SectorNumber, error)                                             `perm:"read"`
-		SectorsListInStates           func(context.Context, []api.SectorState) ([]abi.SectorNumber, error)                          `perm:"read"`
-		SectorsSummary                func(ctx context.Context) (map[api.SectorState]int, error)                                    `perm:"read"`
-		SectorsRefs                   func(context.Context) (map[string][]api.SealedRef, error)                                     `perm:"read"`
-		SectorStartSealing            func(context.Context, abi.SectorNumber) error                                                 `perm:"write"`
-		SectorSetSealDelay            func(context.Context, time.Duration) error                                                    `perm:"write"`
-		SectorGetSealDelay            func(context.Context) (time.Duration, error)                                                  `perm:"read"`
-		SectorSetExpectedSealDuration func(context.Context, time.Duration) error                                                    `perm:"write"`
-		SectorGetExpectedSealDuration func(context.Context) (time.Duration, error)                                                  `perm:"read"`
-		SectorsUpdate                 func(context.Context, abi.SectorNumber, api.SectorState) error                                `perm:"admin"`
-		SectorRemove                  func(context.Context, abi.SectorNumber) error                                                 `perm:"admin"`
-		SectorTerminate               func(context.Context, abi.SectorNumber) error                                                 `perm:"admin"`
-		SectorTerminateFlush          func(ctx context.Context) (*cid.Cid, error)                                                   `perm:"admin"`
-		SectorTerminatePending        func(ctx context.Context) ([]abi.SectorID, error)                                             `perm:"admin"`
-		SectorMarkForUpgrade          func(ctx context.Context, id abi.SectorNumber) error                                          `perm:"admin"`
-
-		WorkerConnect func(context.Context, string) error                                `perm:"admin" retry:"true"` // TODO: worker perm
+		SectorsStatus                 func(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error)                        `perm:"read"`
+		SectorsList                   func(context.Context) ([]abi.SectorNumber, error)                                                                    `perm:"read"`
+		SectorsListInStates           func(context.Context, []api.SectorState) ([]abi.SectorNumber, error)                                                 `perm:"read"`
+		SectorsSummary                func(ctx context.Context) (map[api.SectorState]int, error)                                                           `perm:"read"`
+		SectorsStateSummary           func(ctx context.Context) (map[api.SectorState]api.SectorStateSummary, error)                                        `perm:"read"`
+		SectorsRefs                   func(context.Context) (map[string][]api.SealedRef, error)                                                            `perm:"read"`
+		SectorsHistory                func(context.Context, abi.SectorNumber) ([]api.SectorStateTransition, error)                                         `perm:"read"`
+		SectorStartSealing            func(context.Context, abi.SectorNumber) error                                                                        `perm:"write"`
+		SectorSetSealDelay            func(context.Context, time.Duration) error                                                                           `perm:"write"`
+		SectorGetSealDelay            func(context.Context) (time.Duration, error)                                                                         `perm:"read"`
+		SectorSetExpectedSealDuration func(context.Context, time.Duration) error                                                                           `perm:"write"`
+		SectorGetExpectedSealDuration func(context.Context) (time.Duration, error)                                                                         `perm:"read"`
+		SectorsUpdate                 func(context.Context, abi.SectorNumber, api.SectorState) error                                                       `perm:"admin"`
+		SectorAddPieceToAny           func(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error) `perm:"admin"`
+		SectorReservePiece            func(ctx context.Context, size abi.UnpaddedPieceSize, d api.PieceDealInfo) (api.PieceLease, error)                   `perm:"admin"`
+		SectorCommitPiece             func(ctx context.Context, lease uint64, r storage.Data) (api.SectorOffset, error)                                    `perm:"admin"`
+		SectorRemove                  func(context.Context, abi.SectorNumber) error                                                                        `perm:"admin"`
+		SectorAbort                   func(context.Context, abi.SectorNumber) error                                                                        `perm:"admin"`
+		SectorRegenerateCache         func(context.Context, abi.SectorNumber) error                                                                        `perm:"admin"`
+		SectorTerminate               func(context.Context, abi.SectorNumber) error                                                                        `perm:"admin"`
+		SectorTerminateFlush          func(ctx context.Context) (*cid.Cid, error)                                                                          `perm:"admin"`
+		SectorTerminatePending        func(ctx context.Context) ([]abi.SectorID, error)                                                                    `perm:"admin"`
+		SectorMarkForUpgrade          func(ctx context.Context, id abi.SectorNumber) error                                                                 `perm:"admin"`
+
+		WorkerConnect func(context.Context, string) error                                `perm:"worker" retry:"true"`
 		WorkerStats   func(context.Context) (map[uuid.UUID]storiface.WorkerStats, error) `perm:"admin"`
 		WorkerJobs    func(context.Context) (map[uuid.UUID][]storiface.WorkerJob, error) `perm:"admin"`
-
-		ReturnAddPiece        func(ctx context.Context, callID storiface.CallID, pi abi.PieceInfo, err *storiface.CallError) error          `perm:"admin" retry:"true"`
-		ReturnSealPreCommit1  func(ctx context.Context, callID storiface.CallID, p1o storage.PreCommit1Out, err *storiface.CallError) error `perm:"admin" retry:"true"`
-		ReturnSealPreCommit2  func(ctx context.Context, callID storiface.CallID, sealed storage.SectorCids, err *storiface.CallError) error `perm:"admin" retry:"true"`
-		ReturnSealCommit1     func(ctx context.Context, callID storiface.CallID, out storage.Commit1Out, err *storiface.CallError) error    `perm:"admin" retry:"true"`
-		ReturnSealCommit2     func(ctx context.Context, callID storiface.CallID, proof storage.Proof, err *storiface.CallError) error       `perm:"admin" retry:"true"`
-		ReturnFinalizeSector  func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                            `perm:"admin" retry:"true"`
-		ReturnReleaseUnsealed func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                            `perm:"admin" retry:"true"`
-		ReturnMoveStorage     func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                            `perm:"admin" retry:"true"`
-		ReturnUnsealPiece     func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                            `perm:"admin" retry:"true"`
-		ReturnReadPiece       func(ctx context.Context, callID storiface.CallID, ok bool, err *storiface.CallError) error                   `perm:"admin" retry:"true"`
-		ReturnFetch           func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                            `perm:"admin" retry:"true"`
-
-		SealingSchedDiag func(context.Context, bool) (interface{}, error)       `perm:"admin"`
-		SealingAbort     func(ctx context.Context, call storiface.CallID) error `perm:"admin"`
+		WorkerDrain   func(context.Context, uuid.UUID, bool) error                       `perm:"admin"`
+
+		WorkerJoinTokenCreate func(context.Context, time.Duration) (string, error)                    `perm:"admin"`
+		WorkerJoin            func(context.Context, string, string) (api.WorkerCredential, error)     `perm:"read"`
+		WorkerRenew           func(context.Context, string) (time.Time, error)                        `perm:"worker"`
+		WorkerIdentities      func(context.Context) ([]api.WorkerIdentity, error)                     `perm:"admin"`
+		WorkerIdentityRevoke  func(context.Context, uuid.UUID) error                                  `perm:"admin"`
+		WorkerProfileCapture  func(context.Context, uuid.UUID, string, time.Duration) ([]byte, error) `perm:"admin"`
+
+		ReturnAddPiece            func(ctx context.Context, callID storiface.CallID, pi abi.PieceInfo, err *storiface.CallError) error               `perm:"worker" retry:"true"`
+		ReturnSealPreCommit1      func(ctx context.Context, callID storiface.CallID, p1o storage.PreCommit1Out, err *storiface.CallError) error      `perm:"worker" retry:"true"`
+		ReturnSealPreCommit2      func(ctx context.Context, callID storiface.CallID, sealed storage.SectorCids, err *storiface.CallError) error      `perm:"worker" retry:"true"`
+		ReturnSealCommit1         func(ctx context.Context, callID storiface.CallID, out storage.Commit1Out, err *storiface.CallError) error         `perm:"worker" retry:"true"`
+		ReturnSealCommit2         func(ctx context.Context, callID storiface.CallID, proof storage.Proof, err *storiface.CallError) error            `perm:"worker" retry:"true"`
+		ReturnFinalizeSector      func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                                 `perm:"worker" retry:"true"`
+		ReturnReleaseUnsealed     func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                                 `perm:"worker" retry:"true"`
+		ReturnMoveStorage         func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                                 `perm:"worker" retry:"true"`
+		ReturnUnsealPiece         func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                                 `perm:"worker" retry:"true"`
+		ReturnReadPiece           func(ctx context.Context, callID storiface.CallID, ok bool, err *storiface.CallError) error                        `perm:"worker" retry:"true"`
+		ReturnFetch               func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                                 `perm:"worker" retry:"true"`
+		ReturnRegenerateCache     func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                                 `perm:"worker" retry:"true"`
+		ReturnGenerateWinningPoSt func(ctx context.Context, callID storiface.CallID, proofs []proof2.PoStProof, err *storiface.CallError) error      `perm:"worker" retry:"true"`
+		ReturnGenerateWindowPoSt  func(ctx context.Context, callID storiface.CallID, res storiface.WindowPoStResult, err *storiface.CallError) error `perm:"worker" retry:"true"`
+
+		SealingSchedDiag       func(context.Context, bool) (interface{}, error)                     `perm:"admin"`
+		SealingAddPieceQueue   func(context.Context) (api.AddPieceQueueInfo, error)                 `perm:"read"`
+		SealingAbort           func(ctx context.Context, call storiface.CallID) error               `perm:"admin"`
+		SealingSetTaskPriority func(ctx context.Context, tt sealtasks.TaskType, priority int) error `perm:"admin"`
+		SealingTaskPriorities  func(ctx context.Context) (map[sealtasks.TaskType]int, error)        `perm:"read"`
 
 		StorageList          func(context.Context) (map[stores.ID][]stores.Decl, error)                                                                                   `perm:"admin"`
 		StorageLocal         func(context.Context) (map[stores.ID]string, error)                                                                                          `perm:"admin"`
 		StorageStat          func(context.Context, stores.ID) (fsutil.FsStat, error)                                                                                      `perm:"admin"`
-		StorageAttach        func(context.Context, stores.StorageInfo, fsutil.FsStat) error                                                                               `perm:"admin"`
-		StorageDeclareSector func(context.Context, stores.ID, abi.SectorID, storiface.SectorFileType, bool) error                                                         `perm:"admin"`
-		StorageDropSector    func(context.Context, stores.ID, abi.SectorID, storiface.SectorFileType) error                                                               `perm:"admin"`
-		StorageFindSector    func(context.Context, abi.SectorID, storiface.SectorFileType, abi.SectorSize, bool) ([]stores.SectorStorageInfo, error)                      `perm:"admin"`
-		StorageInfo          func(context.Context, stores.ID) (stores.StorageInfo, error)                                                                                 `perm:"admin"`
-		StorageBestAlloc     func(ctx context.Context, allocate storiface.SectorFileType, ssize abi.SectorSize, sealing storiface.PathType) ([]stores.StorageInfo, error) `perm:"admin"`
-		StorageReportHealth  func(ctx context.Context, id stores.ID, report stores.HealthReport) error                                                                    `perm:"admin"`
-		StorageLock          func(ctx context.Context, sector abi.SectorID, read storiface.SectorFileType, write storiface.SectorFileType) error                          `perm:"admin"`
-		StorageTryLock       func(ctx context.Context, sector abi.SectorID, read storiface.SectorFileType, write storiface.SectorFileType) (bool, error)                  `perm:"admin"`
+		StorageHealth        func(context.Context, stores.ID) (stores.HealthState, error)                                                                                 `perm:"admin"`
+		StorageTransfers     func(context.Context) ([]stores.TransferInfo, error)                                                                                         `perm:"admin"`
+		StorageAttach        func(context.Context, stores.StorageInfo, fsutil.FsStat) error                                                                               `perm:"worker"`
+		StorageDeclareSector func(context.Context, stores.ID, abi.SectorID, storiface.SectorFileType, bool) error                                                         `perm:"worker"`
+		StorageDropSector    func(context.Context, stores.ID, abi.SectorID, storiface.SectorFileType) error                                                               `perm:"worker"`
+		StorageFindSector    func(context.Context, abi.SectorID, storiface.SectorFileType, abi.SectorSize, bool) ([]stores.SectorStorageInfo, error)                      `perm:"worker"`
+		StorageInfo          func(context.Context, stores.ID) (stores.StorageInfo, error)                                                                                 `perm:"worker"`
+		StorageBestAlloc     func(ctx context.Context, allocate storiface.SectorFileType, ssize abi.SectorSize, sealing storiface.PathType) ([]stores.StorageInfo, error) `perm:"worker"`
+		StorageReportHealth  func(ctx context.Context, id stores.ID, report stores.HealthReport) error                                                                    `perm:"worker"`
+		StorageLock          func(ctx context.Context, sector abi.SectorID, read storiface.SectorFileType, write storiface.SectorFileType) error                          `perm:"worker"`
+		StorageTryLock       func(ctx context.Context, sector abi.SectorID, read storiface.SectorFileType, write storiface.SectorFileType) (bool, error)                  `perm:"worker"`
 
 		DealsImportData                        func(ctx context.Context, dealPropCid cid.Cid, file string) error `perm:"write"`
 		DealsList                              func(ctx context.Context) ([]api.MarketDeal, error)               `perm:"read"`
+		DealsCommitEstimates                   func(ctx context.Context) ([]api.DealCommitEstimate, error)       `perm:"read"`
 		DealsConsiderOnlineStorageDeals        func(context.Context) (bool, error)                               `perm:"read"`
 		DealsSetConsiderOnlineStorageDeals     func(context.Context, bool) error                                 `perm:"admin"`
 		DealsConsiderOnlineRetrievalDeals      func(context.Context) (bool, error)                               `perm:"read"`
@@ -375,10 +444,18 @@ type StorageMinerStruct struct {
 		PiecesListCidInfos func(ctx context.Context) ([]cid.Cid, error)                               `perm:"read"`
 		PiecesGetPieceInfo func(ctx context.Context, pieceCid cid.Cid) (*piecestore.PieceInfo, error) `perm:"read"`
 		PiecesGetCIDInfo   func(ctx context.Context, payloadCid cid.Cid) (*piecestore.CIDInfo, error) `perm:"read"`
+		PiecesLocateBlock  func(ctx context.Context, block cid.Cid) ([]api.BlockLocation, error)      `perm:"read"`
+		PiecesRebuildIndex func(ctx context.Context, pieceCid cid.Cid) (int, error)                   `perm:"admin"`
 
 		CreateBackup func(ctx context.Context, fpath string) error `perm:"admin"`
 
-		CheckProvable func(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, expensive bool) (map[abi.SectorNumber]string, error) `perm:"admin"`
+		CheckProvable        func(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, expensive bool) (map[abi.SectorNumber]string, error)     `perm:"admin"`
+		CheckProvableSampled func(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, sampleRate float64) (map[abi.SectorNumber]string, error) `perm:"admin"`
+		ProvingPrechecks     func(ctx context.Context) ([]api.PoStPrecheck, error)                                                                                       `perm:"read"`
+		ProvingRecoveries    func(ctx context.Context) ([]api.PoStRecovery, error)                                                                                       `perm:"read"`
+
+		SLAReportGenerate func(ctx context.Context, from, to abi.ChainEpoch) (*api.SignedSLAReport, error) `perm:"sign"`
+		SLAReportList     func(ctx context.Context, limit int) ([]*api.SignedSLAReport, error)             `perm:"read"`
 	}
 }
 
@@ -403,6 +480,10 @@ type WorkerStruct struct {
 		UnsealPiece     func(context.Context, storage.SectorRef, storiface.UnpaddedByteIndex, abi.UnpaddedPieceSize, abi.SealRandomness, cid.Cid) (storiface.CallID, error)                                           `perm:"admin"`
 		ReadPiece       func(context.Context, io.Writer, storage.SectorRef, storiface.UnpaddedByteIndex, abi.UnpaddedPieceSize) (storiface.CallID, error)                                                             `perm:"admin"`
 		Fetch           func(context.Context, storage.SectorRef, storiface.SectorFileType, storiface.PathType, storiface.AcquireMode) (storiface.CallID, error)                                                       `perm:"admin"`
+		RegenerateCache func(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) (storiface.CallID, error)                                              `perm:"admin"`
+
+		GenerateWinningPoSt func(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) `perm:"admin"`
+		GenerateWindowPoSt  func(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) `perm:"admin"`
 
 		TaskDisable func(ctx context.Context, tt sealtasks.TaskType) error `perm:"admin"`
 		TaskEnable  func(ctx context.Context, tt sealtasks.TaskType) error `perm:"admin"`
@@ -415,6 +496,8 @@ type WorkerStruct struct {
 
 		WaitQuiet func(ctx context.Context) error `perm:"admin"`
 
+		ProfileCapture func(ctx context.Context, name string, duration time.Duration) ([]byte, error) `perm:"admin"`
+
 		ProcessSession func(context.Context) (uuid.UUID, error) `perm:"admin"`
 		Session        func(context.Context) (uuid.UUID, error) `perm:"admin"`
 	}
@@ -424,6 +507,7 @@ type GatewayStruct struct {
 	Internal struct {
 		ChainGetBlockMessages             func(ctx context.Context, c cid.Cid) (*api.BlockMessages, error)
 		ChainGetMessage                   func(ctx context.Context, mc cid.Cid) (*types.Message, error)
+		ChainGetParentMessagesStream      func(ctx context.Context, blockCid cid.Cid, cursor uint64) (<-chan api.ParentMessagesChunk, error)
 		ChainGetTipSet                    func(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error)
 		ChainGetTipSetByHeight            func(ctx context.Context, h abi.ChainEpoch, tsk types.TipSetKey) (*types.TipSet, error)
 		ChainHasObj                       func(context.Context, cid.Cid) (bool, error)
@@ -442,6 +526,7 @@ type GatewayStruct struct {
 		StateListMiners                   func(ctx context.Context, tsk types.TipSetKey) ([]address.Address, error)
 		StateMinerInfo                    func(ctx context.Context, actor address.Address, tsk types.TipSetKey) (miner.MinerInfo, error)
 		StateMinerProvingDeadline         func(ctx context.Context, addr address.Address, tsk types.TipSetKey) (*dline.Info, error)
+		StateMinerSectorsStream           func(ctx context.Context, addr address.Address, sectorNos *bitfield.BitField, cursor abi.SectorNumber, tsk types.TipSetKey) (<-chan api.MinerSectorsChunk, error)
 		StateMinerPower                   func(context.Context, address.Address, types.TipSetKey) (*api.MinerPower, error)
 		StateMarketBalance                func(ctx context.Context, addr address.Address, tsk types.TipSetKey) (api.MarketBalance, error)
 		StateSearchMsg                    func(ctx context.Context, msg cid.Cid) (*api.MsgLookup, error)
@@ -519,6 +604,10 @@ func (c *CommonStruct) NetBandwidthStatsByProtocol(ctx context.Context) (map[pro
 	return c.Internal.NetBandwidthStatsByProtocol(ctx)
 }
 
+func (c *CommonStruct) NetBandwidthHistory(ctx context.Context, since time.Time) ([]api.NetBandwidthSample, error) {
+	return c.Internal.NetBandwidthHistory(ctx, since)
+}
+
 func (c *CommonStruct) NetBlockAdd(ctx context.Context, acl api.NetBlockList) error {
 	return c.Internal.NetBlockAdd(ctx, acl)
 }
@@ -557,6 +646,34 @@ func (c *CommonStruct) Shutdown(ctx context.Context) error {
 	return c.Internal.Shutdown(ctx)
 }
 
+func (c *CommonStruct) BusTopics(ctx context.Context) ([]string, error) {
+	return c.Internal.BusTopics(ctx)
+}
+
+func (c *CommonStruct) BusSubscribe(ctx context.Context, topics []string) (<-chan api.BusEvent, error) {
+	return c.Internal.BusSubscribe(ctx, topics)
+}
+
+func (c *CommonStruct) ProfileCapture(ctx context.Context, name string, duration time.Duration) ([]byte, error) {
+	return c.Internal.ProfileCapture(ctx, name, duration)
+}
+
+func (c *CommonStruct) ProfileInfo(ctx context.Context) (api.ProfileInfo, error) {
+	return c.Internal.ProfileInfo(ctx)
+}
+
+func (c *CommonStruct) SubsystemList(ctx context.Context) ([]api.SubsystemStatus, error) {
+	return c.Internal.SubsystemList(ctx)
+}
+
+func (c *CommonStruct) SubsystemStart(ctx context.Context, name string) error {
+	return c.Internal.SubsystemStart(ctx, name)
+}
+
+func (c *CommonStruct) SubsystemStop(ctx context.Context, name string) error {
+	return c.Internal.SubsystemStop(ctx, name)
+}
+
 func (c *CommonStruct) Session(ctx context.Context) (uuid.UUID, error) {
 	return c.Internal.Session(ctx)
 }
@@ -595,6 +712,22 @@ func (c *FullNodeStruct) ClientStartDeal(ctx context.Context, params *api.StartD
 	return c.Internal.ClientStartDeal(ctx, params)
 }
 
+func (c *FullNodeStruct) ClientCreateDealAllowance(ctx context.Context, client, delegate address.Address, amount types.BigInt, expiration abi.ChainEpoch) (*api.SignedDealAllowance, error) {
+	return c.Internal.ClientCreateDealAllowance(ctx, client, delegate, amount, expiration)
+}
+
+func (c *FullNodeStruct) ClientListDealAllowances(ctx context.Context) ([]api.DealAllowanceInfo, error) {
+	return c.Internal.ClientListDealAllowances(ctx)
+}
+
+func (c *FullNodeStruct) ClientRevokeDealAllowance(ctx context.Context, id uint64) error {
+	return c.Internal.ClientRevokeDealAllowance(ctx, id)
+}
+
+func (c *FullNodeStruct) ClientStartDelegatedDeal(ctx context.Context, req *api.DelegatedDealRequest) (*cid.Cid, error) {
+	return c.Internal.ClientStartDelegatedDeal(ctx, req)
+}
+
 func (c *FullNodeStruct) ClientGetDealInfo(ctx context.Context, deal cid.Cid) (*api.DealInfo, error) {
 	return c.Internal.ClientGetDealInfo(ctx, deal)
 }
@@ -607,6 +740,10 @@ func (c *FullNodeStruct) ClientListDeals(ctx context.Context) ([]api.DealInfo, e
 	return c.Internal.ClientListDeals(ctx)
 }
 
+func (c *FullNodeStruct) ClientProviderScores(ctx context.Context) ([]api.ProviderScore, error) {
+	return c.Internal.ClientProviderScores(ctx)
+}
+
 func (c *FullNodeStruct) ClientGetDealUpdates(ctx context.Context) (<-chan api.DealInfo, error) {
 	return c.Internal.ClientGetDealUpdates(ctx)
 }
@@ -823,10 +960,26 @@ func (c *FullNodeStruct) ChainGetParentMessages(ctx context.Context, b cid.Cid)
 	return c.Internal.ChainGetParentMessages(ctx, b)
 }
 
+func (c *FullNodeStruct) ChainGetParentMessagesStream(ctx context.Context, b cid.Cid, cursor uint64) (<-chan api.ParentMessagesChunk, error) {
+	return c.Internal.ChainGetParentMessagesStream(ctx, b, cursor)
+}
+
+func (c *FullNodeStruct) ChainGetNetworkStats(ctx context.Context, from, to abi.ChainEpoch) (*api.NetworkStats, error) {
+	return c.Internal.ChainGetNetworkStats(ctx, from, to)
+}
+
 func (c *FullNodeStruct) ChainNotify(ctx context.Context) (<-chan []*api.HeadChange, error) {
 	return c.Internal.ChainNotify(ctx)
 }
 
+func (c *FullNodeStruct) ChainGetTipSetAtHeight(ctx context.Context, h abi.ChainEpoch, anchor api.HeightAnchor, tsk types.TipSetKey) (*api.TipSetAtHeight, error) {
+	return c.Internal.ChainGetTipSetAtHeight(ctx, h, anchor, tsk)
+}
+
+func (c *FullNodeStruct) ChainNotifyMessages(ctx context.Context, filter api.MessageFilter) (<-chan []api.MessageEvent, error) {
+	return c.Internal.ChainNotifyMessages(ctx, filter)
+}
+
 func (c *FullNodeStruct) ChainReadObj(ctx context.Context, obj cid.Cid) ([]byte, error) {
 	return c.Internal.ChainReadObj(ctx, obj)
 }
@@ -871,6 +1024,14 @@ func (c *FullNodeStruct) ChainExport(ctx context.Context, nroots abi.ChainEpoch,
 	return c.Internal.ChainExport(ctx, nroots, iom, tsk)
 }
 
+func (c *FullNodeStruct) ChainPrune(ctx context.Context) error {
+	return c.Internal.ChainPrune(ctx)
+}
+
+func (c *FullNodeStruct) ChainGCBlockstore(ctx context.Context) ([]api.BlockstoreGCStats, error) {
+	return c.Internal.ChainGCBlockstore(ctx)
+}
+
 func (c *FullNodeStruct) BeaconGetEntry(ctx context.Context, epoch abi.ChainEpoch) (*types.BeaconEntry, error) {
 	return c.Internal.BeaconGetEntry(ctx, epoch)
 }
@@ -891,6 +1052,18 @@ func (c *FullNodeStruct) SyncCheckpoint(ctx context.Context, tsk types.TipSetKey
 	return c.Internal.SyncCheckpoint(ctx, tsk)
 }
 
+func (c *FullNodeStruct) SyncGetCheckpoint(ctx context.Context) (types.TipSetKey, error) {
+	return c.Internal.SyncGetCheckpoint(ctx)
+}
+
+func (c *FullNodeStruct) SyncPeers(ctx context.Context) ([]api.SyncPeer, error) {
+	return c.Internal.SyncPeers(ctx)
+}
+
+func (c *FullNodeStruct) SyncUnbanPeer(ctx context.Context, p peer.ID) error {
+	return c.Internal.SyncUnbanPeer(ctx, p)
+}
+
 func (c *FullNodeStruct) SyncMarkBad(ctx context.Context, bcid cid.Cid) error {
 	return c.Internal.SyncMarkBad(ctx, bcid)
 }
@@ -919,6 +1092,10 @@ func (c *FullNodeStruct) StateMinerSectors(ctx context.Context, addr address.Add
 	return c.Internal.StateMinerSectors(ctx, addr, sectorNos, tsk)
 }
 
+func (c *FullNodeStruct) StateMinerSectorsStream(ctx context.Context, addr address.Address, sectorNos *bitfield.BitField, cursor abi.SectorNumber, tsk types.TipSetKey) (<-chan api.MinerSectorsChunk, error) {
+	return c.Internal.StateMinerSectorsStream(ctx, addr, sectorNos, cursor, tsk)
+}
+
 func (c *FullNodeStruct) StateMinerActiveSectors(ctx context.Context, addr address.Address, tsk types.TipSetKey) ([]*miner.SectorOnChainInfo, error) {
 	return c.Internal.StateMinerActiveSectors(ctx, addr, tsk)
 }
@@ -927,6 +1104,10 @@ func (c *FullNodeStruct) StateMinerProvingDeadline(ctx context.Context, addr add
 	return c.Internal.StateMinerProvingDeadline(ctx, addr, tsk)
 }
 
+func (c *FullNodeStruct) StateMinerDeadlineNotify(ctx context.Context, maddr address.Address, offset abi.ChainEpoch, deadlines []uint64) (<-chan *api.DeadlineEvent, error) {
+	return c.Internal.StateMinerDeadlineNotify(ctx, maddr, offset, deadlines)
+}
+
 func (c *FullNodeStruct) StateMinerPower(ctx context.Context, a address.Address, tsk types.TipSetKey) (*api.MinerPower, error) {
 	return c.Internal.StateMinerPower(ctx, a, tsk)
 }
@@ -991,6 +1172,10 @@ func (c *FullNodeStruct) StateCall(ctx context.Context, msg *types.Message, tsk
 	return c.Internal.StateCall(ctx, msg, tsk)
 }
 
+func (c *FullNodeStruct) StateCallMany(ctx context.Context, msgs []*types.Message, tsk types.TipSetKey) ([]*api.InvocResult, error) {
+	return c.Internal.StateCallMany(ctx, msgs, tsk)
+}
+
 func (c *FullNodeStruct) StateReplay(ctx context.Context, tsk types.TipSetKey, mc cid.Cid) (*api.InvocResult, error) {
 	return c.Internal.StateReplay(ctx, tsk, mc)
 }
@@ -1059,6 +1244,10 @@ func (c *FullNodeStruct) StateGetReceipt(ctx context.Context, msg cid.Cid, tsk t
 	return c.Internal.StateGetReceipt(ctx, msg, tsk)
 }
 
+func (c *FullNodeStruct) StateGetReceipts(ctx context.Context, tsk types.TipSetKey) ([]api.MsgReceipt, error) {
+	return c.Internal.StateGetReceipts(ctx, tsk)
+}
+
 func (c *FullNodeStruct) StateListMessages(ctx context.Context, match *api.MessageMatch, tsk types.TipSetKey, toht abi.ChainEpoch) ([]cid.Cid, error) {
 	return c.Internal.StateListMessages(ctx, match, tsk, toht)
 }
@@ -1243,6 +1432,22 @@ func (c *FullNodeStruct) PaychVoucherSubmit(ctx context.Context, ch address.Addr
 	return c.Internal.PaychVoucherSubmit(ctx, ch, sv, secret, proof)
 }
 
+func (c *FullNodeStruct) EpochTaskAdd(ctx context.Context, t api.EpochTask) (uint64, error) {
+	return c.Internal.EpochTaskAdd(ctx, t)
+}
+
+func (c *FullNodeStruct) EpochTaskList(ctx context.Context) ([]api.EpochTask, error) {
+	return c.Internal.EpochTaskList(ctx)
+}
+
+func (c *FullNodeStruct) EpochTaskRemove(ctx context.Context, id uint64) error {
+	return c.Internal.EpochTaskRemove(ctx, id)
+}
+
+func (c *FullNodeStruct) NetDiag(ctx context.Context) (api.NetDiagnostics, error) {
+	return c.Internal.NetDiag(ctx)
+}
+
 func (c *FullNodeStruct) CreateBackup(ctx context.Context, fpath string) error {
 	return c.Internal.CreateBackup(ctx, fpath)
 }
@@ -1257,6 +1462,14 @@ func (c *StorageMinerStruct) MiningBase(ctx context.Context) (*types.TipSet, err
 	return c.Internal.MiningBase(ctx)
 }
 
+func (c *StorageMinerStruct) MiningBlockCandidate(ctx context.Context) (*api.BlockCandidate, error) {
+	return c.Internal.MiningBlockCandidate(ctx)
+}
+
+func (c *StorageMinerStruct) MiningBlockHistory(ctx context.Context) ([]api.MinedBlock, error) {
+	return c.Internal.MiningBlockHistory(ctx)
+}
+
 func (c *StorageMinerStruct) ActorSectorSize(ctx context.Context, addr address.Address) (abi.SectorSize, error) {
 	return c.Internal.ActorSectorSize(ctx, addr)
 }
@@ -1265,6 +1478,14 @@ func (c *StorageMinerStruct) ActorAddressConfig(ctx context.Context) (api.Addres
 	return c.Internal.ActorAddressConfig(ctx)
 }
 
+func (c *StorageMinerStruct) ActorFundsPolicy(ctx context.Context) (api.FundsPolicy, error) {
+	return c.Internal.ActorFundsPolicy(ctx)
+}
+
+func (c *StorageMinerStruct) ActorSetFundsPolicy(ctx context.Context, p api.FundsPolicy) error {
+	return c.Internal.ActorSetFundsPolicy(ctx, p)
+}
+
 func (c *StorageMinerStruct) PledgeSector(ctx context.Context) error {
 	return c.Internal.PledgeSector(ctx)
 }
@@ -1287,10 +1508,18 @@ func (c *StorageMinerStruct) SectorsSummary(ctx context.Context) (map[api.Sector
 	return c.Internal.SectorsSummary(ctx)
 }
 
+func (c *StorageMinerStruct) SectorsStateSummary(ctx context.Context) (map[api.SectorState]api.SectorStateSummary, error) {
+	return c.Internal.SectorsStateSummary(ctx)
+}
+
 func (c *StorageMinerStruct) SectorsRefs(ctx context.Context) (map[string][]api.SealedRef, error) {
 	return c.Internal.SectorsRefs(ctx)
 }
 
+func (c *StorageMinerStruct) SectorsHistory(ctx context.Context, sid abi.SectorNumber) ([]api.SectorStateTransition, error) {
+	return c.Internal.SectorsHistory(ctx, sid)
+}
+
 func (c *StorageMinerStruct) SectorStartSealing(ctx context.Context, number abi.SectorNumber) error {
 	return c.Internal.SectorStartSealing(ctx, number)
 }
@@ -1315,10 +1544,30 @@ func (c *StorageMinerStruct) SectorsUpdate(ctx context.Context, id abi.SectorNum
 	return c.Internal.SectorsUpdate(ctx, id, state)
 }
 
+func (c *StorageMinerStruct) SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error) {
+	return c.Internal.SectorAddPieceToAny(ctx, size, r, d)
+}
+
+func (c *StorageMinerStruct) SectorReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d api.PieceDealInfo) (api.PieceLease, error) {
+	return c.Internal.SectorReservePiece(ctx, size, d)
+}
+
+func (c *StorageMinerStruct) SectorCommitPiece(ctx context.Context, lease uint64, r storage.Data) (api.SectorOffset, error) {
+	return c.Internal.SectorCommitPiece(ctx, lease, r)
+}
+
 func (c *StorageMinerStruct) SectorRemove(ctx context.Context, number abi.SectorNumber) error {
 	return c.Internal.SectorRemove(ctx, number)
 }
 
+func (c *StorageMinerStruct) SectorAbort(ctx context.Context, number abi.SectorNumber) error {
+	return c.Internal.SectorAbort(ctx, number)
+}
+
+func (c *StorageMinerStruct) SectorRegenerateCache(ctx context.Context, number abi.SectorNumber) error {
+	return c.Internal.SectorRegenerateCache(ctx, number)
+}
+
 func (c *StorageMinerStruct) SectorTerminate(ctx context.Context, number abi.SectorNumber) error {
 	return c.Internal.SectorTerminate(ctx, number)
 }
@@ -1347,6 +1596,34 @@ func (c *StorageMinerStruct) WorkerJobs(ctx context.Context) (map[uuid.UUID][]st
 	return c.Internal.WorkerJobs(ctx)
 }
 
+func (c *StorageMinerStruct) WorkerDrain(ctx context.Context, worker uuid.UUID, drain bool) error {
+	return c.Internal.WorkerDrain(ctx, worker, drain)
+}
+
+func (c *StorageMinerStruct) WorkerJoinTokenCreate(ctx context.Context, ttl time.Duration) (string, error) {
+	return c.Internal.WorkerJoinTokenCreate(ctx, ttl)
+}
+
+func (c *StorageMinerStruct) WorkerJoin(ctx context.Context, token string, name string) (api.WorkerCredential, error) {
+	return c.Internal.WorkerJoin(ctx, token, name)
+}
+
+func (c *StorageMinerStruct) WorkerRenew(ctx context.Context, certificate string) (time.Time, error) {
+	return c.Internal.WorkerRenew(ctx, certificate)
+}
+
+func (c *StorageMinerStruct) WorkerIdentities(ctx context.Context) ([]api.WorkerIdentity, error) {
+	return c.Internal.WorkerIdentities(ctx)
+}
+
+func (c *StorageMinerStruct) WorkerIdentityRevoke(ctx context.Context, id uuid.UUID) error {
+	return c.Internal.WorkerIdentityRevoke(ctx, id)
+}
+
+func (c *StorageMinerStruct) WorkerProfileCapture(ctx context.Context, worker uuid.UUID, name string, duration time.Duration) ([]byte, error) {
+	return c.Internal.WorkerProfileCapture(ctx, worker, name, duration)
+}
+
 func (c *StorageMinerStruct) ReturnAddPiece(ctx context.Context, callID storiface.CallID, pi abi.PieceInfo, err *storiface.CallError) error {
 	return c.Internal.ReturnAddPiece(ctx, callID, pi, err)
 }
@@ -1391,14 +1668,38 @@ func (c *StorageMinerStruct) ReturnFetch(ctx context.Context, callID storiface.C
 	return c.Internal.ReturnFetch(ctx, callID, err)
 }
 
+func (c *StorageMinerStruct) ReturnRegenerateCache(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	return c.Internal.ReturnRegenerateCache(ctx, callID, err)
+}
+
+func (c *StorageMinerStruct) ReturnGenerateWinningPoSt(ctx context.Context, callID storiface.CallID, proofs []proof2.PoStProof, err *storiface.CallError) error {
+	return c.Internal.ReturnGenerateWinningPoSt(ctx, callID, proofs, err)
+}
+
+func (c *StorageMinerStruct) ReturnGenerateWindowPoSt(ctx context.Context, callID storiface.CallID, res storiface.WindowPoStResult, err *storiface.CallError) error {
+	return c.Internal.ReturnGenerateWindowPoSt(ctx, callID, res, err)
+}
+
 func (c *StorageMinerStruct) SealingSchedDiag(ctx context.Context, doSched bool) (interface{}, error) {
 	return c.Internal.SealingSchedDiag(ctx, doSched)
 }
 
+func (c *StorageMinerStruct) SealingAddPieceQueue(ctx context.Context) (api.AddPieceQueueInfo, error) {
+	return c.Internal.SealingAddPieceQueue(ctx)
+}
+
 func (c *StorageMinerStruct) SealingAbort(ctx context.Context, call storiface.CallID) error {
 	return c.Internal.SealingAbort(ctx, call)
 }
 
+func (c *StorageMinerStruct) SealingSetTaskPriority(ctx context.Context, tt sealtasks.TaskType, priority int) error {
+	return c.Internal.SealingSetTaskPriority(ctx, tt, priority)
+}
+
+func (c *StorageMinerStruct) SealingTaskPriorities(ctx context.Context) (map[sealtasks.TaskType]int, error) {
+	return c.Internal.SealingTaskPriorities(ctx)
+}
+
 func (c *StorageMinerStruct) StorageAttach(ctx context.Context, si stores.StorageInfo, st fsutil.FsStat) error {
 	return c.Internal.StorageAttach(ctx, si, st)
 }
@@ -1427,6 +1728,14 @@ func (c *StorageMinerStruct) StorageStat(ctx context.Context, id stores.ID) (fsu
 	return c.Internal.StorageStat(ctx, id)
 }
 
+func (c *StorageMinerStruct) StorageHealth(ctx context.Context, id stores.ID) (stores.HealthState, error) {
+	return c.Internal.StorageHealth(ctx, id)
+}
+
+func (c *StorageMinerStruct) StorageTransfers(ctx context.Context) ([]stores.TransferInfo, error) {
+	return c.Internal.StorageTransfers(ctx)
+}
+
 func (c *StorageMinerStruct) StorageInfo(ctx context.Context, id stores.ID) (stores.StorageInfo, error) {
 	return c.Internal.StorageInfo(ctx, id)
 }
@@ -1467,6 +1776,10 @@ func (c *StorageMinerStruct) MarketListIncompleteDeals(ctx context.Context) ([]s
 	return c.Internal.MarketListIncompleteDeals(ctx)
 }
 
+func (c *StorageMinerStruct) MarketClientQuotas(ctx context.Context) ([]api.ClientQuotaUsage, error) {
+	return c.Internal.MarketClientQuotas(ctx)
+}
+
 func (c *StorageMinerStruct) MarketSetAsk(ctx context.Context, price types.BigInt, verifiedPrice types.BigInt, duration abi.ChainEpoch, minPieceSize abi.PaddedPieceSize, maxPieceSize abi.PaddedPieceSize) error {
 	return c.Internal.MarketSetAsk(ctx, price, verifiedPrice, duration, minPieceSize, maxPieceSize)
 }
@@ -1475,6 +1788,10 @@ func (c *StorageMinerStruct) MarketGetAsk(ctx context.Context) (*storagemarket.S
 	return c.Internal.MarketGetAsk(ctx)
 }
 
+func (c *StorageMinerStruct) MarketGetAskHistory(ctx context.Context, limit int) ([]*storagemarket.SignedStorageAsk, error) {
+	return c.Internal.MarketGetAskHistory(ctx, limit)
+}
+
 func (c *StorageMinerStruct) MarketSetRetrievalAsk(ctx context.Context, rask *retrievalmarket.Ask) error {
 	return c.Internal.MarketSetRetrievalAsk(ctx, rask)
 }
@@ -1483,6 +1800,10 @@ func (c *StorageMinerStruct) MarketGetRetrievalAsk(ctx context.Context) (*retrie
 	return c.Internal.MarketGetRetrievalAsk(ctx)
 }
 
+func (c *StorageMinerStruct) MarketHTTPRetrievalToken(ctx context.Context) (string, error) {
+	return c.Internal.MarketHTTPRetrievalToken(ctx)
+}
+
 func (c *StorageMinerStruct) MarketListDataTransfers(ctx context.Context) ([]api.DataTransferChannel, error) {
 	return c.Internal.MarketListDataTransfers(ctx)
 }
@@ -1499,6 +1820,14 @@ func (c *StorageMinerStruct) MarketCancelDataTransfer(ctx context.Context, trans
 	return c.Internal.MarketCancelDataTransfer(ctx, transferID, otherPeer, isInitiator)
 }
 
+func (c *StorageMinerStruct) MarketGetTransferLimits(ctx context.Context) (api.TransferLimits, error) {
+	return c.Internal.MarketGetTransferLimits(ctx)
+}
+
+func (c *StorageMinerStruct) MarketSetTransferLimits(ctx context.Context, limits api.TransferLimits) error {
+	return c.Internal.MarketSetTransferLimits(ctx, limits)
+}
+
 func (c *StorageMinerStruct) DealsImportData(ctx context.Context, dealPropCid cid.Cid, file string) error {
 	return c.Internal.DealsImportData(ctx, dealPropCid, file)
 }
@@ -1507,6 +1836,10 @@ func (c *StorageMinerStruct) DealsList(ctx context.Context) ([]api.MarketDeal, e
 	return c.Internal.DealsList(ctx)
 }
 
+func (c *StorageMinerStruct) DealsCommitEstimates(ctx context.Context) ([]api.DealCommitEstimate, error) {
+	return c.Internal.DealsCommitEstimates(ctx)
+}
+
 func (c *StorageMinerStruct) DealsConsiderOnlineStorageDeals(ctx context.Context) (bool, error) {
 	return c.Internal.DealsConsiderOnlineStorageDeals(ctx)
 }
@@ -1583,6 +1916,14 @@ func (c *StorageMinerStruct) PiecesGetCIDInfo(ctx context.Context, payloadCid ci
 	return c.Internal.PiecesGetCIDInfo(ctx, payloadCid)
 }
 
+func (c *StorageMinerStruct) PiecesLocateBlock(ctx context.Context, block cid.Cid) ([]api.BlockLocation, error) {
+	return c.Internal.PiecesLocateBlock(ctx, block)
+}
+
+func (c *StorageMinerStruct) PiecesRebuildIndex(ctx context.Context, pieceCid cid.Cid) (int, error) {
+	return c.Internal.PiecesRebuildIndex(ctx, pieceCid)
+}
+
 func (c *StorageMinerStruct) CreateBackup(ctx context.Context, fpath string) error {
 	return c.Internal.CreateBackup(ctx, fpath)
 }
@@ -1591,6 +1932,26 @@ func (c *StorageMinerStruct) CheckProvable(ctx context.Context, pp abi.Registere
 	return c.Internal.CheckProvable(ctx, pp, sectors, expensive)
 }
 
+func (c *StorageMinerStruct) CheckProvableSampled(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, sampleRate float64) (map[abi.SectorNumber]string, error) {
+	return c.Internal.CheckProvableSampled(ctx, pp, sectors, sampleRate)
+}
+
+func (c *StorageMinerStruct) ProvingPrechecks(ctx context.Context) ([]api.PoStPrecheck, error) {
+	return c.Internal.ProvingPrechecks(ctx)
+}
+
+func (c *StorageMinerStruct) ProvingRecoveries(ctx context.Context) ([]api.PoStRecovery, error) {
+	return c.Internal.ProvingRecoveries(ctx)
+}
+
+func (c *StorageMinerStruct) SLAReportGenerate(ctx context.Context, from, to abi.ChainEpoch) (*api.SignedSLAReport, error) {
+	return c.Internal.SLAReportGenerate(ctx, from, to)
+}
+
+func (c *StorageMinerStruct) SLAReportList(ctx context.Context, limit int) ([]*api.SignedSLAReport, error) {
+	return c.Internal.SLAReportList(ctx, limit)
+}
+
 // WorkerStruct
 
 func (w *WorkerStruct) Version(ctx context.Context) (build.Version, error) {
@@ -1653,6 +2014,18 @@ func (w *WorkerStruct) Fetch(ctx context.Context, id storage.SectorRef, fileType
 	return w.Internal.Fetch(ctx, id, fileType, ptype, am)
 }
 
+func (w *WorkerStruct) RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) (storiface.CallID, error) {
+	return w.Internal.RegenerateCache(ctx, sector, ticket, pieces, sealed)
+}
+
+func (w *WorkerStruct) GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	return w.Internal.GenerateWinningPoSt(ctx, minerID, sectorInfo, randomness)
+}
+
+func (w *WorkerStruct) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	return w.Internal.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness)
+}
+
 func (w *WorkerStruct) TaskDisable(ctx context.Context, tt sealtasks.TaskType) error {
 	return w.Internal.TaskDisable(ctx, tt)
 }
@@ -1681,6 +2054,10 @@ func (w *WorkerStruct) WaitQuiet(ctx context.Context) error {
 	return w.Internal.WaitQuiet(ctx)
 }
 
+func (w *WorkerStruct) ProfileCapture(ctx context.Context, name string, duration time.Duration) ([]byte, error) {
+	return w.Internal.ProfileCapture(ctx, name, duration)
+}
+
 func (w *WorkerStruct) ProcessSession(ctx context.Context) (uuid.UUID, error) {
 	return w.Internal.ProcessSession(ctx)
 }
@@ -1693,6 +2070,10 @@ func (g GatewayStruct) ChainGetBlockMessages(ctx context.Context, c cid.Cid) (*a
 	return g.Internal.ChainGetBlockMessages(ctx, c)
 }
 
+func (g GatewayStruct) ChainGetParentMessagesStream(ctx context.Context, blockCid cid.Cid, cursor uint64) (<-chan api.ParentMessagesChunk, error) {
+	return g.Internal.ChainGetParentMessagesStream(ctx, blockCid, cursor)
+}
+
 func (g GatewayStruct) ChainGetMessage(ctx context.Context, mc cid.Cid) (*types.Message, error) {
 	return g.Internal.ChainGetMessage(ctx, mc)
 }
@@ -1789,6 +2170,10 @@ func (g GatewayStruct) StateSearchMsg(ctx context.Context, msg cid.Cid) (*api.Ms
 	return g.Internal.StateSearchMsg(ctx, msg)
 }
 
+func (g GatewayStruct) StateMinerSectorsStream(ctx context.Context, addr address.Address, sectorNos *bitfield.BitField, cursor abi.SectorNumber, tsk types.TipSetKey) (<-chan api.MinerSectorsChunk, error) {
+	return g.Internal.StateMinerSectorsStream(ctx, addr, sectorNos, cursor, tsk)
+}
+
 func (g GatewayStruct) StateSectorGetInfo(ctx context.Context, maddr address.Address, n abi.SectorNumber, tsk types.TipSetKey) (*miner.SectorOnChainInfo, error) {
 	return g.Internal.StateSectorGetInfo(ctx, maddr, n, tsk)
 }
@@ -729,3 +729,267 @@ func (t *SealSeed) UnmarshalCBOR(r io.Reader) error {
 
 	return nil
 }
+func (t *Deadline) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{161}); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.PostSubmissions (bitfield.BitField) (struct)
+	if len("PostSubmissions") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"PostSubmissions\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("PostSubmissions"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("PostSubmissions")); err != nil {
+		return err
+	}
+
+	if err := t.PostSubmissions.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *Deadline) UnmarshalCBOR(r io.Reader) error {
+	*t = Deadline{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("Deadline: map struct too large (%d)", extra)
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+
+		{
+			sval, err := cbg.ReadStringBuf(br, scratch)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.PostSubmissions (bitfield.BitField) (struct)
+		case "PostSubmissions":
+
+			{
+
+				if err := t.PostSubmissions.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.PostSubmissions: %w", err)
+				}
+
+			}
+
+		default:
+			return fmt.Errorf("unknown struct field %d: '%s'", i, name)
+		}
+	}
+
+	return nil
+}
+func (t *Partition) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{165}); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.AllSectors (bitfield.BitField) (struct)
+	if len("AllSectors") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"AllSectors\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("AllSectors"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("AllSectors")); err != nil {
+		return err
+	}
+
+	if err := t.AllSectors.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.FaultySectors (bitfield.BitField) (struct)
+	if len("FaultySectors") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"FaultySectors\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("FaultySectors"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("FaultySectors")); err != nil {
+		return err
+	}
+
+	if err := t.FaultySectors.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.RecoveringSectors (bitfield.BitField) (struct)
+	if len("RecoveringSectors") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"RecoveringSectors\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("RecoveringSectors"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("RecoveringSectors")); err != nil {
+		return err
+	}
+
+	if err := t.RecoveringSectors.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.LiveSectors (bitfield.BitField) (struct)
+	if len("LiveSectors") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"LiveSectors\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("LiveSectors"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("LiveSectors")); err != nil {
+		return err
+	}
+
+	if err := t.LiveSectors.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.ActiveSectors (bitfield.BitField) (struct)
+	if len("ActiveSectors") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"ActiveSectors\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("ActiveSectors"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("ActiveSectors")); err != nil {
+		return err
+	}
+
+	if err := t.ActiveSectors.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *Partition) UnmarshalCBOR(r io.Reader) error {
+	*t = Partition{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("Partition: map struct too large (%d)", extra)
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+
+		{
+			sval, err := cbg.ReadStringBuf(br, scratch)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.AllSectors (bitfield.BitField) (struct)
+		case "AllSectors":
+
+			{
+
+				if err := t.AllSectors.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.AllSectors: %w", err)
+				}
+
+			}
+			// t.FaultySectors (bitfield.BitField) (struct)
+		case "FaultySectors":
+
+			{
+
+				if err := t.FaultySectors.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.FaultySectors: %w", err)
+				}
+
+			}
+			// t.RecoveringSectors (bitfield.BitField) (struct)
+		case "RecoveringSectors":
+
+			{
+
+				if err := t.RecoveringSectors.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.RecoveringSectors: %w", err)
+				}
+
+			}
+			// t.LiveSectors (bitfield.BitField) (struct)
+		case "LiveSectors":
+
+			{
+
+				if err := t.LiveSectors.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.LiveSectors: %w", err)
+				}
+
+			}
+			// t.ActiveSectors (bitfield.BitField) (struct)
+		case "ActiveSectors":
+
+			{
+
+				if err := t.ActiveSectors.UnmarshalCBOR(br); err != nil {
+					return xerrors.Errorf("unmarshaling t.ActiveSectors: %w", err)
+				}
+
+			}
+
+		default:
+			return fmt.Errorf("unknown struct field %d: '%s'", i, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,421 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-jsonrpc"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+)
+
+var log = logging.Logger("rpcclient")
+
+var errClosed = xerrors.New("client closed")
+
+// Endpoint is the address of a node API, and the headers sent with requests
+// to it, e.g. for authorization
+type Endpoint struct {
+	Addr   string
+	Header http.Header
+}
+
+// RetryPolicy decides how failed calls are retried. Each retry is made on the
+// next endpoint if the call failed on the current one.
+type RetryPolicy struct {
+	// Maximum number of attempts of a call, over all endpoints; 0 for no limit
+	Attempts int
+	// Delay before the second attempt, doubled after each attempt up to
+	// MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable returns whether a call which failed with the given error can
+	// be retried; nil retries only failures to connect to an endpoint
+	Retryable func(error) bool
+	// Methods lists the methods which are retried when they fail after being
+	// sent to a node; nil for the methods only reading the state of the node.
+	// Calls are always retried when they couldn't be sent.
+	Methods map[string]bool
+}
+
+// DefaultRetryPolicy retries calls failing because of connection problems.
+// The node can execute a call before the connection is lost, so only calls
+// of read-only methods are retried once they were sent, and calls with side
+// effects, like MpoolPushMessage or WalletSignMessage, aren't executed twice.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   5,
+	Backoff:    time.Second,
+	MaxBackoff: 30 * time.Second,
+	Retryable:  IsConnectionError,
+}
+
+// NoRetry makes calls fail on the first error
+var NoRetry = RetryPolicy{Attempts: 1}
+
+// readOnly are the methods which only read the state of a node, by their
+// permission in the API structs
+var readOnly = map[string]bool{}
+
+func init() {
+	for _, t := range []reflect.Type{
+		reflect.TypeOf(apistruct.CommonStruct{}.Internal),
+		reflect.TypeOf(apistruct.FullNodeStruct{}.Internal),
+		reflect.TypeOf(apistruct.StorageMinerStruct{}.Internal),
+	} {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			ro, seen := readOnly[f.Name]
+			readOnly[f.Name] = (ro || !seen) && f.Tag.Get("perm") == string(apistruct.PermRead)
+		}
+	}
+}
+
+// retriedAfterSent returns whether calls of the method can be retried after
+// they were sent to a node
+func (rp RetryPolicy) retriedAfterSent(method string) bool {
+	if rp.Methods != nil {
+		return rp.Methods[method]
+	}
+	return readOnly[method]
+}
+
+type retryPolicyKey struct{}
+
+// WithRetryPolicy sets the retry policy of calls made with the returned
+// context, overriding the policy of the client
+func WithRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// resubscribed are the methods returning channels of notifications which
+// don't end on their own. When the channel is closed before the call context
+// is done, e.g. because the connection was lost, the method is called again and
+// notifications from the new channel are passed on. Note that a new ChainNotify
+// channel starts with the current head, like when subscribing the first time.
+var resubscribed = map[string]struct{}{
+	"ChainNotify":               {},
+	"SyncIncomingBlocks":        {},
+	"MpoolSub":                  {},
+	"ClientGetDealUpdates":      {},
+	"ClientDataTransferUpdates": {},
+	"StateMinerDeadlineNotify":  {},
+	"MarketGetDealUpdates":      {},
+	"MarketDataTransferUpdates": {},
+}
+
+// IsConnectionError returns whether the error is caused by the connection to
+// the node, and not returned by the called method
+func IsConnectionError(err error) bool {
+	var netErr net.Error
+	var closeErr *websocket.CloseError
+	switch {
+	case xerrors.As(err, &netErr), xerrors.As(err, &closeErr),
+		xerrors.Is(err, io.EOF), xerrors.Is(err, io.ErrUnexpectedEOF),
+		xerrors.Is(err, websocket.ErrBadHandshake), xerrors.Is(err, errClosed):
+		return true
+	}
+
+	// errors of requests made on a closed connection aren't typed
+	msg := err.Error()
+	return strings.Contains(msg, "websocket connection closed") || strings.Contains(msg, "websocket routine exiting")
+}
+
+// NewFullNodeClient creates a full node API client which connects to the
+// first reachable endpoint, and fails over to the next endpoints when the
+// connection is lost. Failed calls are retried with the given policy, and
+// subscriptions are re-established on the next endpoint.
+func NewFullNodeClient(ctx context.Context, endpoints []Endpoint, policy RetryPolicy) (api.FullNode, jsonrpc.ClientCloser, error) {
+	p, err := newPool(ctx, endpoints, policy, func(ctx context.Context, e Endpoint) (interface{}, jsonrpc.ClientCloser, error) {
+		return NewFullNodeRPC(ctx, e.Addr, e.Header, jsonrpc.WithNoReconnect())
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out apistruct.FullNodeStruct
+	p.proxy(&out.Internal)
+	p.proxy(&out.CommonStruct.Internal)
+	return &out, p.close, nil
+}
+
+// NewStorageMinerClient creates a miner API client with endpoint failover and
+// retries, like NewFullNodeClient
+func NewStorageMinerClient(ctx context.Context, endpoints []Endpoint, policy RetryPolicy) (api.StorageMiner, jsonrpc.ClientCloser, error) {
+	p, err := newPool(ctx, endpoints, policy, func(ctx context.Context, e Endpoint) (interface{}, jsonrpc.ClientCloser, error) {
+		return NewStorageMinerRPC(ctx, e.Addr, e.Header, jsonrpc.WithNoReconnect())
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out apistruct.StorageMinerStruct
+	p.proxy(&out.Internal)
+	p.proxy(&out.CommonStruct.Internal)
+	return &out, p.close, nil
+}
+
+// NewGatewayClient creates a gateway API client with endpoint failover and
+// retries, like NewFullNodeClient
+func NewGatewayClient(ctx context.Context, endpoints []Endpoint, policy RetryPolicy) (api.GatewayAPI, jsonrpc.ClientCloser, error) {
+	p, err := newPool(ctx, endpoints, policy, func(ctx context.Context, e Endpoint) (interface{}, jsonrpc.ClientCloser, error) {
+		return NewGatewayRPC(ctx, e.Addr, e.Header, jsonrpc.WithNoReconnect())
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out apistruct.GatewayStruct
+	p.proxy(&out.Internal)
+	return &out, p.close, nil
+}
+
+type dialFunc func(ctx context.Context, e Endpoint) (interface{}, jsonrpc.ClientCloser, error)
+
+type pool struct {
+	ctx       context.Context
+	endpoints []Endpoint
+	dial      dialFunc
+	policy    RetryPolicy
+
+	lk     sync.Mutex
+	cur    int
+	conns  []*poolConn
+	closed bool
+}
+
+type poolConn struct {
+	api    reflect.Value
+	closer jsonrpc.ClientCloser
+}
+
+func newPool(ctx context.Context, endpoints []Endpoint, policy RetryPolicy, dial dialFunc) (*pool, error) {
+	if len(endpoints) == 0 {
+		return nil, xerrors.Errorf("no endpoints")
+	}
+
+	p := &pool{
+		ctx:       ctx,
+		endpoints: endpoints,
+		dial:      dial,
+		policy:    policy,
+		conns:     make([]*poolConn, len(endpoints)),
+	}
+
+	if _, _, err := p.conn(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// conn returns the connection to the current endpoint, connecting to the
+// next endpoints if it isn't reachable
+func (p *pool) conn() (int, *poolConn, error) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	if p.closed {
+		return 0, nil, errClosed
+	}
+
+	var err error
+	for i := range p.endpoints {
+		idx := (p.cur + i) % len(p.endpoints)
+		if c := p.conns[idx]; c != nil {
+			p.cur = idx
+			return idx, c, nil
+		}
+
+		e := p.endpoints[idx]
+		a, closer, derr := p.dial(p.ctx, e)
+		if derr != nil {
+			log.Warnw("connecting to API endpoint", "endpoint", e.Addr, "error", derr)
+			err = xerrors.Errorf("connecting to %s: %w", e.Addr, derr)
+			continue
+		}
+
+		p.conns[idx] = &poolConn{api: reflect.ValueOf(a), closer: closer}
+		p.cur = idx
+		return idx, p.conns[idx], nil
+	}
+
+	return 0, nil, err
+}
+
+// drop closes a connection a call failed on, so that the next call is made
+// on the next endpoint
+func (p *pool) drop(idx int, c *poolConn) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	if p.conns[idx] != c {
+		return
+	}
+
+	c.closer()
+	p.conns[idx] = nil
+	p.cur = (idx + 1) % len(p.endpoints)
+}
+
+func (p *pool) close() {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	p.closed = true
+	for i, c := range p.conns {
+		if c != nil {
+			c.closer()
+			p.conns[i] = nil
+		}
+	}
+}
+
+func (p *pool) proxy(out interface{}) {
+	rint := reflect.ValueOf(out).Elem()
+
+	for f := 0; f < rint.NumField(); f++ {
+		field := rint.Type().Field(f)
+		_, resub := resubscribed[field.Name]
+
+		rint.Field(f).Set(reflect.MakeFunc(field.Type, func(args []reflect.Value) (results []reflect.Value) {
+			ctx := args[0].Interface().(context.Context)
+
+			res := p.call(ctx, field.Name, field.Type, args)
+			if !resub || resultErr(res) != nil {
+				return res
+			}
+
+			return p.resubscribing(ctx, field.Name, field.Type, args, res)
+		}))
+	}
+}
+
+func (p *pool) call(ctx context.Context, method string, ft reflect.Type, args []reflect.Value) []reflect.Value {
+	policy := p.policy
+	if cp, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		policy = cp
+	}
+
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		idx, c, err := p.conn()
+		if err == errClosed {
+			return errResults(ft, err)
+		}
+		if err == nil {
+			res := c.api.MethodByName(method).Call(args)
+
+			err = resultErr(res)
+			if err == nil || policy.Retryable == nil || !policy.Retryable(err) {
+				return res
+			}
+
+			p.drop(idx, c)
+
+			// the node may have executed the call before the connection was
+			// lost
+			if !policy.retriedAfterSent(method) {
+				return res
+			}
+		}
+
+		if policy.Attempts > 0 && attempt >= policy.Attempts {
+			return errResults(ft, xerrors.Errorf("%s failed after %d attempts: %w", method, attempt, err))
+		}
+
+		log.Warnw("API call failed, retrying", "method", method, "attempt", attempt, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errResults(ft, ctx.Err())
+		}
+
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// resubscribing returns a channel passing on notifications from the channel
+// returned by the first call, and from channels returned by calling the method
+// again each time the previous channel is closed
+func (p *pool) resubscribing(ctx context.Context, method string, ft reflect.Type, args []reflect.Value, first []reflect.Value) []reflect.Value {
+	chType := ft.Out(0)
+	out := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, chType.Elem()), 0)
+
+	go func() {
+		defer out.Close()
+
+		in := first[0]
+		for {
+			forward(ctx, in, out)
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Warnw("API subscription closed, subscribing again", "method", method)
+
+			res := p.call(ctx, method, ft, args)
+			if err := resultErr(res); err != nil {
+				if !xerrors.Is(err, errClosed) && ctx.Err() == nil {
+					log.Errorw("re-establishing API subscription", "method", method, "error", err)
+				}
+				return
+			}
+
+			in = res[0]
+		}
+	}()
+
+	return []reflect.Value{out.Convert(chType), first[1]}
+}
+
+// forward passes values from in to out until in is closed, or the context is
+// done
+func forward(ctx context.Context, in, out reflect.Value) {
+	done := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+
+	for {
+		chosen, v, ok := reflect.Select([]reflect.SelectCase{done, {Dir: reflect.SelectRecv, Chan: in}})
+		if chosen == 0 || !ok {
+			return
+		}
+
+		chosen, _, _ = reflect.Select([]reflect.SelectCase{done, {Dir: reflect.SelectSend, Chan: out, Send: v}})
+		if chosen == 0 {
+			return
+		}
+	}
+}
+
+func resultErr(res []reflect.Value) error {
+	last := res[len(res)-1]
+	if last.IsNil() {
+		return nil
+	}
+	return last.Interface().(error)
+}
+
+// errResults returns zero values with the given error, as results of a
+// function of the given type
+func errResults(ft reflect.Type, err error) []reflect.Value {
+	out := make([]reflect.Value, ft.NumOut())
+	for i := 0; i < len(out)-1; i++ {
+		out[i] = reflect.Zero(ft.Out(i))
+	}
+	out[len(out)-1] = reflect.ValueOf(&err).Elem()
+	return out
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-jsonrpc"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+)
+
+func testNode(t *testing.T, version func() (api.Version, error)) (*httptest.Server, Endpoint) {
+	var impl apistruct.FullNodeStruct
+	impl.CommonStruct.Internal.Version = func(context.Context) (api.Version, error) {
+		return version()
+	}
+
+	rpcServer := jsonrpc.NewServer()
+	rpcServer.Register("Filecoin", &impl)
+
+	srv := httptest.NewServer(rpcServer)
+	return srv, Endpoint{Addr: "ws" + strings.TrimPrefix(srv.URL, "http")}
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+
+	dead, deadEp := testNode(t, nil)
+	dead.Close()
+
+	alive, aliveEp := testNode(t, func() (api.Version, error) {
+		return api.Version{Version: "alive"}, nil
+	})
+	defer alive.Close()
+
+	fapi, closer, err := NewFullNodeClient(ctx, []Endpoint{deadEp, aliveEp}, DefaultRetryPolicy)
+	require.NoError(t, err)
+	defer closer()
+
+	v, err := fapi.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "alive", v.Version)
+}
+
+func TestNoRetryOnMethodError(t *testing.T) {
+	ctx := context.Background()
+
+	var calls int64
+	srv, ep := testNode(t, func() (api.Version, error) {
+		atomic.AddInt64(&calls, 1)
+		return api.Version{}, errors.New("method failed")
+	})
+	defer srv.Close()
+
+	fapi, closer, err := NewFullNodeClient(ctx, []Endpoint{ep}, DefaultRetryPolicy)
+	require.NoError(t, err)
+	defer closer()
+
+	_, err = fapi.Version(ctx)
+	require.Error(t, err)
+	require.False(t, IsConnectionError(err))
+	require.Equal(t, int64(1), atomic.LoadInt64(&calls))
+}
+
+func TestRetriedAfterSent(t *testing.T) {
+	for method, retried := range map[string]bool{
+		"Version":           true,
+		"ChainHead":         true,
+		"StateGetActor":     true,
+		"MpoolPush":         false,
+		"MpoolPushMessage":  false,
+		"WalletSign":        false,
+		"WalletSignMessage": false,
+		"SectorRemove":      false,
+		"Unknown":           false,
+	} {
+		require.Equal(t, retried, DefaultRetryPolicy.retriedAfterSent(method), method)
+	}
+
+	policy := DefaultRetryPolicy
+	policy.Methods = map[string]bool{"MpoolPush": true}
+	require.True(t, policy.retriedAfterSent("MpoolPush"))
+	require.False(t, policy.retriedAfterSent("ChainHead"))
+}
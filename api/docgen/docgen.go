@@ -123,6 +123,7 @@ func init() {
 	addExample(retrievalmarket.DealStatusNew)
 	addExample(network.ReachabilityPublic)
 	addExample(build.NewestNetworkVersion)
+	addExample(api.HeightAnchorPrev)
 	addExample(&types.ExecutionTrace{
 		Msg:    exampleValue("init", reflect.TypeOf(&types.Message{}), nil).(*types.Message),
 		MsgRct: exampleValue("init", reflect.TypeOf(&types.MessageReceipt{}), nil).(*types.MessageReceipt),
@@ -161,6 +162,19 @@ func init() {
 		},
 	})
 
+	addExample(map[string]api.NetBandwidthUsage{
+		"12D3KooWSXmXLJmBR1M7i9RW9GQPNUhZSzXKzxDHWtAgNuJAbyEJ": {
+			In:  174000,
+			Out: 12500,
+		},
+	})
+	addExample(map[protocol.ID]api.NetBandwidthUsage{
+		"/fil/hello/1.0.0": {
+			In:  174000,
+			Out: 12500,
+		},
+	})
+
 	maddr, err := multiaddr.NewMultiaddr("/ip4/52.36.61.156/tcp/1347/p2p/12D3KooWFETiESTf1v4PGUvtnxMAcEFMzLZbJGg4tjWfGEimYior")
 	if err != nil {
 		panic(err)
@@ -218,12 +232,16 @@ func init() {
 		uuid.MustParse("ef8d99a2-6865-4189-8ffa-9fef0f806eee"): {
 			Info: storiface.WorkerInfo{
 				Hostname: "host",
+				Groups:   []string{"rack-1"},
 				Resources: storiface.WorkerResources{
 					MemPhysical: 256 << 30,
 					MemSwap:     120 << 30,
 					MemReserved: 2 << 30,
 					CPUs:        64,
 					GPUs:        []string{"aGPU 1337"},
+					TaskLimits: map[sealtasks.TaskType]int{
+						sealtasks.TTPreCommit1: 4,
+					},
 				},
 			},
 			Enabled:    true,
@@ -231,6 +249,10 @@ func init() {
 			MemUsedMax: 0,
 			GpuUsed:    false,
 			CpuUse:     0,
+			TaskCounts: map[sealtasks.TaskType]int{
+				sealtasks.TTPreCommit1: 2,
+			},
+			PinnedSectors: 3,
 		},
 	})
 	addExample(storiface.ErrorCode(0))
@@ -240,6 +262,13 @@ func init() {
 	addExample(map[api.SectorState]int{
 		api.SectorState(sealing.Proving): 120,
 	})
+	addExample(map[api.SectorState]api.SectorStateSummary{
+		api.SectorState(sealing.WaitDeals): {
+			Count:       3,
+			OldestSince: time.Unix(1605172927, 0).UTC(),
+			DealBytes:   1 << 30,
+		},
+	})
 	addExample([]abi.SectorNumber{123, 124})
 
 	// worker specific
@@ -249,6 +278,9 @@ func init() {
 		sealtasks.TTPreCommit2: {},
 	})
 	addExample(sealtasks.TTCommit2)
+	addExample(map[sealtasks.TaskType]int{
+		sealtasks.TTPreCommit1: 4,
+	})
 }
 
 func exampleValue(method string, t, parent reflect.Type) interface{} {
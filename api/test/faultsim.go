@@ -0,0 +1,257 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/extern/sector-storage/mock"
+	bminer "github.com/filecoin-project/lotus/miner"
+	"github.com/filecoin-project/lotus/node/impl"
+)
+
+// FaultSim is a single miner test network which can be driven through
+// network-wide fault scenarios: null round gaps and mass faults. It's used to
+// validate the window PoSt scheduler and fault recovery end-to-end.
+type FaultSim struct {
+	t   *testing.T
+	ctx context.Context
+
+	Client *impl.FullNodeAPI
+	Miner  TestStorageNode
+	Maddr  address.Address
+
+	// Pledged are the sectors sealed by the miner, excluding genesis sectors
+	Pledged []abi.SectorNumber
+
+	mid       abi.ActorID
+	sectors   *mock.SectorMgr
+	blocktime time.Duration
+	nulls     int64
+
+	cancel func()
+	done   chan struct{}
+}
+
+// NewFaultSim starts a network with a single miner, and waits until the miner
+// pledged and proves nSectors
+func NewFaultSim(t *testing.T, b APIBuilder, blocktime time.Duration, nSectors int) *FaultSim {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	n, sn := b(t, OneFull, OneMiner)
+
+	s := &FaultSim{
+		t:   t,
+		ctx: ctx,
+
+		Client: n[0].FullNode.(*impl.FullNodeAPI),
+		Miner:  sn[0],
+
+		sectors:   sn[0].StorageMiner.(*impl.StorageMinerAPI).IStorageMgr.(*mock.SectorMgr),
+		blocktime: blocktime,
+
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	addrinfo, err := s.Client.NetAddrsListen(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Miner.NetConnect(ctx, addrinfo))
+	build.Clock.Sleep(time.Second)
+
+	go s.mine()
+
+	pledgeSectors(t, ctx, s.Miner, nSectors, 0, nil)
+
+	s.Maddr, err = s.Miner.ActorAddress(ctx)
+	require.NoError(t, err)
+
+	mid, err := address.IDFromAddress(s.Maddr)
+	require.NoError(t, err)
+	s.mid = abi.ActorID(mid)
+
+	s.Pledged, err = s.Miner.SectorsList(ctx)
+	require.NoError(t, err)
+
+	// wait for all sectors to gain power
+	s.WaitProvingPeriod()
+	require.Equal(t, nSectors+GenesisPreseals, s.ProvenSectors())
+
+	return s
+}
+
+func (s *FaultSim) mine() {
+	defer close(s.done)
+
+	for s.ctx.Err() == nil {
+		build.Clock.Sleep(s.blocktime)
+
+		nulls := atomic.SwapInt64(&s.nulls, 0)
+		if err := s.Miner.MineOne(s.ctx, bminer.MineReq{
+			InjectNulls: abi.ChainEpoch(nulls),
+			Done:        func(bool, abi.ChainEpoch, error) {},
+		}); err != nil {
+			if s.ctx.Err() != nil {
+				// context was canceled, ignore the error.
+				return
+			}
+			s.t.Error(err)
+		}
+	}
+}
+
+// Stop stops mining and waits for the mining loop to exit
+func (s *FaultSim) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// InjectNulls makes the next block be mined after n null rounds
+func (s *FaultSim) InjectNulls(n abi.ChainEpoch) {
+	atomic.AddInt64(&s.nulls, int64(n))
+}
+
+// FailSectors makes the sectors fail (or pass) checks and proving, like
+// sectors whose data was lost
+func (s *FaultSim) FailSectors(failed bool, sectors ...abi.SectorNumber) {
+	for _, sn := range sectors {
+		require.NoError(s.t, s.sectors.MarkFailed(s.sectorRef(sn), failed))
+	}
+}
+
+func (s *FaultSim) sectorRef(sn abi.SectorNumber) storage.SectorRef {
+	return storage.SectorRef{
+		ID: abi.SectorID{
+			Miner:  s.mid,
+			Number: sn,
+		},
+	}
+}
+
+// Head returns the current chain head
+func (s *FaultSim) Head() *types.TipSet {
+	head, err := s.Client.ChainHead(s.ctx)
+	require.NoError(s.t, err)
+	return head
+}
+
+// WaitHeight waits until the chain head is above the height
+func (s *FaultSim) WaitHeight(h abi.ChainEpoch) {
+	fmt.Printf("End for head.Height > %d\n", h)
+
+	for {
+		head := s.Head()
+		if head.Height() > h {
+			fmt.Printf("Now head.Height = %d\n", head.Height())
+			return
+		}
+
+		build.Clock.Sleep(s.blocktime)
+	}
+}
+
+// WaitProvingPeriod waits until the current proving period of the miner ends,
+// so every deadline had a chance to be proven
+func (s *FaultSim) WaitProvingPeriod() {
+	di, err := s.Client.StateMinerProvingDeadline(s.ctx, s.Maddr, types.EmptyTSK)
+	require.NoError(s.t, err)
+
+	s.WaitHeight(di.PeriodStart + di.WPoStProvingPeriod + 2)
+}
+
+// ProvenSectors returns the number of sectors the miner has power for
+func (s *FaultSim) ProvenSectors() int {
+	p, err := s.Client.StateMinerPower(s.ctx, s.Maddr, types.EmptyTSK)
+	require.NoError(s.t, err)
+
+	ssz, err := s.Miner.ActorSectorSize(s.ctx, s.Maddr)
+	require.NoError(s.t, err)
+
+	return int(p.MinerPower.RawBytePower.Uint64() / uint64(ssz))
+}
+
+// Faults returns the number of faulty sectors of the miner
+func (s *FaultSim) Faults() int {
+	f, err := s.Client.StateMinerFaults(s.ctx, s.Maddr, types.EmptyTSK)
+	require.NoError(s.t, err)
+
+	n, err := f.Count()
+	require.NoError(s.t, err)
+	return int(n)
+}
+
+// TestWindowPostFaults runs the window PoSt scheduler and fault recovery
+// through fault scenarios
+func TestWindowPostFaults(t *testing.T, b APIBuilder, blocktime time.Duration, nSectors int) {
+	t.Run("null-rounds", func(t *testing.T) {
+		testFaultsNullRounds(t, b, blocktime, nSectors)
+	})
+	t.Run("mass-faults", func(t *testing.T) {
+		testFaultsMassFaults(t, b, blocktime, nSectors)
+	})
+}
+
+func testFaultsNullRounds(t *testing.T, b APIBuilder, blocktime time.Duration, nSectors int) {
+	s := NewFaultSim(t, b, blocktime, nSectors)
+	defer s.Stop()
+
+	di, err := s.Client.StateMinerProvingDeadline(s.ctx, s.Maddr, types.EmptyTSK)
+	require.NoError(t, err)
+
+	fmt.Printf("Injecting null rounds for a proving period\n")
+
+	end := di.PeriodStart + di.WPoStProvingPeriod + 2
+	for s.Head().Height() <= end {
+		s.InjectNulls(3)
+		s.WaitHeight(s.Head().Height() + 5)
+	}
+
+	// no deadline was missed
+	require.Equal(t, 0, s.Faults())
+	require.Equal(t, nSectors+GenesisPreseals, s.ProvenSectors())
+}
+
+func testFaultsMassFaults(t *testing.T, b APIBuilder, blocktime time.Duration, nSectors int) {
+	s := NewFaultSim(t, b, blocktime, nSectors)
+	defer s.Stop()
+
+	fmt.Printf("Failing all pledged sectors\n")
+
+	// genesis sectors keep the miner's power above the minimum, so it keeps
+	// mining
+	s.FailSectors(true, s.Pledged...)
+	s.WaitProvingPeriod()
+	s.WaitProvingPeriod()
+
+	require.Equal(t, nSectors, s.Faults())
+	require.Equal(t, GenesisPreseals, s.ProvenSectors())
+
+	fmt.Printf("Recovering all pledged sectors\n")
+
+	s.FailSectors(false, s.Pledged...)
+	s.WaitProvingPeriod()
+	s.WaitProvingPeriod()
+
+	require.Equal(t, 0, s.Faults())
+	require.Equal(t, nSectors+GenesisPreseals, s.ProvenSectors())
+
+	recoveries, err := s.Miner.ProvingRecoveries(s.ctx)
+	require.NoError(t, err)
+
+	var declared bool
+	for _, r := range recoveries {
+		declared = declared || r.Declared
+	}
+	require.True(t, declared, "no recoveries were declared")
+}
@@ -3,12 +3,14 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/ipfs/go-cid"
 
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -46,6 +48,25 @@ type PubsubScore struct {
 	Score *pubsub.PeerScoreSnapshot
 }
 
+// NetBandwidthUsage is the number of bytes transferred during a sample interval
+type NetBandwidthUsage struct {
+	In  int64
+	Out int64
+}
+
+// NetBandwidthSample is the bandwidth used between Start and End, as
+// recorded by the node
+type NetBandwidthSample struct {
+	Start time.Time
+	End   time.Time
+
+	Total     NetBandwidthUsage
+	Protocols map[protocol.ID]NetBandwidthUsage
+
+	// Peers only lists the peers with the highest usage in the interval
+	Peers map[string]NetBandwidthUsage
+}
+
 type MessageSendSpec struct {
 	MaxFee abi.TokenAmount
 }
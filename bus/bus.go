@@ -0,0 +1,91 @@
+// Package bus is the event bus of the node. The sealing, chain and markets
+// subsystems publish typed events to it, in addition to their journal and
+// logging callbacks, so tooling can follow them with the BusSubscribe API.
+//
+// Subsystems don't subscribe to each other through the bus yet; the bus only
+// feeds external subscribers. Every event type has a topic name, which those
+// subscribers use to select events.
+package bus
+
+import (
+	"reflect"
+	"sort"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-eventbus"
+	"github.com/libp2p/go-libp2p-core/event"
+	"golang.org/x/xerrors"
+)
+
+// Bus is the event bus shared by the subsystems of the node
+type Bus event.Bus
+
+func New() Bus {
+	return eventbus.NewBus()
+}
+
+var log = logging.Logger("bus")
+
+// topics maps topic names to the event types published under them
+var topics = map[string]interface{}{
+	TopicSectorState: new(EvtSectorState),
+	TopicHeadChange:  new(EvtHeadChange),
+	TopicStorageDeal: new(EvtStorageDeal),
+}
+
+// Topics returns the names of all topics, sorted
+func Topics() []string {
+	out := make([]string, 0, len(topics))
+	for t := range topics {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// TopicOf returns the topic name of an event emitted on the bus
+func TopicOf(evt interface{}) (string, bool) {
+	typ := reflect.TypeOf(evt)
+	for name, et := range topics {
+		if reflect.TypeOf(et).Elem() == typ {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Subscribe subscribes to events of the named topics, or all topics if none
+// are given
+func Subscribe(b Bus, names []string, opts ...event.SubscriptionOpt) (event.Subscription, error) {
+	if len(names) == 0 {
+		names = Topics()
+	}
+
+	evtTypes := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		et, ok := topics[name]
+		if !ok {
+			return nil, xerrors.Errorf("unknown topic %q", name)
+		}
+		evtTypes = append(evtTypes, et)
+	}
+
+	return b.Subscribe(evtTypes, opts...)
+}
+
+// Emitter returns an emitter for events of the type of evt, logging errors
+// instead of returning them, as subsystems don't stop when an event can't be
+// published
+func Emitter(b Bus, evt interface{}) func(interface{}) {
+	em, err := b.Emitter(evt)
+	if err != nil {
+		log.Errorf("creating emitter for %T: %+v", evt, err)
+		return func(interface{}) {}
+	}
+
+	return func(e interface{}) {
+		if err := em.Emit(e); err != nil {
+			log.Errorf("emitting %T: %+v", e, err)
+		}
+	}
+}
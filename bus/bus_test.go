@@ -0,0 +1,41 @@
+package bus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+func TestSubscribeTopics(t *testing.T) {
+	b := New()
+
+	sub, err := Subscribe(b, []string{TopicSectorState})
+	require.NoError(t, err)
+	defer sub.Close() //nolint:errcheck
+
+	_, err = Subscribe(b, []string{"nope"})
+	require.Error(t, err)
+
+	emitSector := Emitter(b, new(EvtSectorState))
+	emitHead := Emitter(b, new(EvtHeadChange))
+
+	emitHead(EvtHeadChange{Type: "apply", Height: 10})
+	emitSector(EvtSectorState{SectorNumber: 3, From: "Packing", After: "PreCommit1"})
+
+	evt := (<-sub.Out()).(EvtSectorState)
+	require.Equal(t, abi.SectorNumber(3), evt.SectorNumber)
+
+	topic, ok := TopicOf(evt)
+	require.True(t, ok)
+	require.Equal(t, TopicSectorState, topic)
+
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+
+	require.Len(t, Topics(), 3)
+}
@@ -0,0 +1,49 @@
+package bus
+
+import (
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+const (
+	TopicSectorState = "sealing/sector_state"
+	TopicHeadChange  = "chain/head_change"
+	TopicStorageDeal = "markets/storage_deal"
+)
+
+// EvtSectorState is emitted by the sealing subsystem when a sector changes
+// state
+type EvtSectorState struct {
+	SectorNumber abi.SectorNumber
+	SectorType   abi.RegisteredSealProof
+	From         string
+	After        string
+	Error        string
+}
+
+// EvtHeadChange is emitted when a tipset is applied to, or reverted from the
+// head of the chain
+type EvtHeadChange struct {
+	// "apply" or "revert"
+	Type   string
+	Height abi.ChainEpoch
+	TipSet types.TipSetKey
+}
+
+const (
+	DealRoleClient   = "client"
+	DealRoleProvider = "provider"
+)
+
+// EvtStorageDeal is emitted when a storage deal of the storage client, or
+// provider changes
+type EvtStorageDeal struct {
+	Role        string
+	ProposalCid cid.Cid
+	Event       string
+	State       string
+	Message     string
+}
@@ -1,6 +1,8 @@
 package miner
 
 import (
+	"io"
+
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -123,6 +125,49 @@ type SectorOnChainInfo struct {
 	ExpectedStoragePledge abi.TokenAmount
 }
 
+// MarshalCBOR encodes the sector info like the v2 actors state. The v2 fields
+// describing the replaced sector aren't tracked here, so they're encoded as
+// zero.
+func (si *SectorOnChainInfo) MarshalCBOR(w io.Writer) error {
+	v2 := miner2.SectorOnChainInfo{
+		SectorNumber:          si.SectorNumber,
+		SealProof:             si.SealProof,
+		SealedCID:             si.SealedCID,
+		DealIDs:               si.DealIDs,
+		Activation:            si.Activation,
+		Expiration:            si.Expiration,
+		DealWeight:            si.DealWeight,
+		VerifiedDealWeight:    si.VerifiedDealWeight,
+		InitialPledge:         si.InitialPledge,
+		ExpectedDayReward:     si.ExpectedDayReward,
+		ExpectedStoragePledge: si.ExpectedStoragePledge,
+		ReplacedDayReward:     big.Zero(),
+	}
+	return v2.MarshalCBOR(w)
+}
+
+func (si *SectorOnChainInfo) UnmarshalCBOR(r io.Reader) error {
+	var v2 miner2.SectorOnChainInfo
+	if err := v2.UnmarshalCBOR(r); err != nil {
+		return err
+	}
+
+	*si = SectorOnChainInfo{
+		SectorNumber:          v2.SectorNumber,
+		SealProof:             v2.SealProof,
+		SealedCID:             v2.SealedCID,
+		DealIDs:               v2.DealIDs,
+		Activation:            v2.Activation,
+		Expiration:            v2.Expiration,
+		DealWeight:            v2.DealWeight,
+		VerifiedDealWeight:    v2.VerifiedDealWeight,
+		InitialPledge:         v2.InitialPledge,
+		ExpectedDayReward:     v2.ExpectedDayReward,
+		ExpectedStoragePledge: v2.ExpectedStoragePledge,
+	}
+	return nil
+}
+
 type SectorPreCommitInfo = miner0.SectorPreCommitInfo
 
 type SectorPreCommitOnChainInfo struct {
@@ -142,6 +187,8 @@ type DeclareFaultsParams = miner0.DeclareFaultsParams
 type DeclareFaultsRecoveredParams = miner0.DeclareFaultsRecoveredParams
 type SubmitWindowedPoStParams = miner0.SubmitWindowedPoStParams
 type ProveCommitSectorParams = miner0.ProveCommitSectorParams
+type ExtendSectorExpirationParams = miner0.ExtendSectorExpirationParams
+type ExpirationExtension = miner0.ExpirationExtension
 
 type MinerInfo struct {
 	Owner                      address.Address   // Must be an ID-address.
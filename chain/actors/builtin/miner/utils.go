@@ -1,6 +1,7 @@
 package miner
 
 import (
+	cbg "github.com/whyrusleeping/cbor-gen"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-bitfield"
@@ -29,6 +30,50 @@ func AllPartSectors(mas State, sget func(Partition) (bitfield.BitField, error))
 	return bitfield.MultiMerge(parts...)
 }
 
+// ForEachSector calls cb with the miner's sectors in ascending sector number
+// order, starting at the from sector number. If snos is nil, all sectors are
+// included; sector numbers in snos which don't exist on chain are skipped.
+// Sectors are decoded one at a time, so that large miners can be walked
+// without loading all sectors into memory.
+func ForEachSector(mas State, snos *bitfield.BitField, from abi.SectorNumber, cb func(*SectorOnChainInfo) error) error {
+	if snos != nil {
+		return snos.ForEach(func(sno uint64) error {
+			if abi.SectorNumber(sno) < from {
+				return nil
+			}
+
+			si, err := mas.GetSector(abi.SectorNumber(sno))
+			if err != nil {
+				return xerrors.Errorf("getting sector %d: %w", sno, err)
+			}
+			if si == nil {
+				return nil
+			}
+
+			return cb(si)
+		})
+	}
+
+	sectors, err := mas.sectors()
+	if err != nil {
+		return xerrors.Errorf("loading sectors: %w", err)
+	}
+
+	var val cbg.Deferred
+	return sectors.ForEach(&val, func(sno int64) error {
+		if abi.SectorNumber(sno) < from {
+			return nil
+		}
+
+		si, err := mas.decodeSectorOnChainInfo(&val)
+		if err != nil {
+			return xerrors.Errorf("decoding sector %d: %w", sno, err)
+		}
+
+		return cb(&si)
+	})
+}
+
 // SealProofTypeFromSectorSize returns preferred seal proof type for creating
 // new miner actors and new sectors
 func SealProofTypeFromSectorSize(ssize abi.SectorSize, nv network.Version) (abi.RegisteredSealProof, error) {
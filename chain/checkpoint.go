@@ -56,7 +56,7 @@ func (syncer *Syncer) SetCheckpoint(tsk types.TipSetKey) error {
 	}
 
 	if !hts.Equals(ts) && !anc {
-		return xerrors.Errorf("cannot mark tipset as checkpoint, since it isn't in the main-chain: %w", err)
+		return xerrors.Errorf("cannot mark tipset %s as checkpoint, since it isn't in the main-chain", tsk)
 	}
 
 	tskBytes, err := json.Marshal(tsk)
@@ -79,3 +79,20 @@ func (syncer *Syncer) GetCheckpoint() types.TipSetKey {
 	defer syncer.checkptLk.Unlock()
 	return syncer.checkpt
 }
+
+// KeepsCheckpoint returns whether the checkpointed tipset, if any, is ts or
+// one of its ancestors, i.e. whether ts can become the head without forking
+// away from the checkpoint
+func (syncer *Syncer) KeepsCheckpoint(ts *types.TipSet) (bool, error) {
+	chkpt := syncer.GetCheckpoint()
+	if chkpt == types.EmptyTSK || ts.Key() == chkpt {
+		return true, nil
+	}
+
+	cts, err := syncer.ChainStore().LoadTipSet(chkpt)
+	if err != nil {
+		return false, xerrors.Errorf("loading checkpoint tipset: %w", err)
+	}
+
+	return syncer.ChainStore().IsAncestorOf(cts, ts)
+}
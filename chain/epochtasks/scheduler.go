@@ -0,0 +1,448 @@
+package epochtasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var log = logging.Logger("epochtasks")
+
+// ActionTimeout bounds the time a task action can take
+const ActionTimeout = 5 * time.Minute
+
+// maxResultLen caps the length of command output kept as the result of a run
+const maxResultLen = 1 << 10
+
+// API is the part of the full node API used to schedule and run tasks
+type API interface {
+	StateMinerProvingDeadline(context.Context, address.Address, types.TipSetKey) (*dline.Info, error)
+	MpoolPushMessage(context.Context, *types.Message, *api.MessageSendSpec) (*types.SignedMessage, error)
+}
+
+// Run is passed to command and webhook actions as JSON
+type Run struct {
+	Task api.EpochTask
+	// Epoch is the epoch the task was scheduled to run at
+	Epoch abi.ChainEpoch
+	// Height and TipSet are of the chain head when the task ran
+	Height abi.ChainEpoch
+	TipSet types.TipSetKey
+}
+
+// Scheduler runs tasks as the chain head reaches the epochs they are
+// scheduled at. Tasks are persisted in the datastore, and a task whose epoch
+// passed while the node was down runs when it's back, unless a deadline
+// relative task is more than a challenge window late.
+type Scheduler struct {
+	api API
+	ds  datastore.Batching
+
+	lk     sync.Mutex
+	tasks  map[uint64]*api.EpochTask
+	nextID uint64
+	head   *types.TipSet
+}
+
+func New(a API, ds datastore.Batching) (*Scheduler, error) {
+	s := &Scheduler{
+		api:    a,
+		ds:     ds,
+		tasks:  map[uint64]*api.EpochTask{},
+		nextID: 1,
+	}
+
+	res, err := ds.Query(query.Query{})
+	if err != nil {
+		return nil, xerrors.Errorf("querying tasks: %w", err)
+	}
+	defer res.Close() // nolint:errcheck
+
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, xerrors.Errorf("reading tasks: %w", e.Error)
+		}
+
+		var t api.EpochTask
+		if err := json.Unmarshal(e.Value, &t); err != nil {
+			return nil, xerrors.Errorf("decoding task %s: %w", e.Key, err)
+		}
+
+		s.tasks[t.ID] = &t
+		if t.ID >= s.nextID {
+			s.nextID = t.ID + 1
+		}
+	}
+
+	return s, nil
+}
+
+// Run processes head changes until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context, notifs <-chan []*api.HeadChange) {
+	for {
+		select {
+		case changes, ok := <-notifs:
+			if !ok {
+				log.Warn("head change subscription closed")
+				return
+			}
+
+			if len(changes) == 0 {
+				continue
+			}
+
+			// tasks don't run again on reorgs, only the new head matters
+			hc := changes[len(changes)-1]
+			if hc.Type == store.HCRevert {
+				continue
+			}
+
+			s.processHead(ctx, hc.Val)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Add validates and schedules the task
+func (s *Scheduler) Add(ctx context.Context, t api.EpochTask) (uint64, error) {
+	if err := validate(t); err != nil {
+		return 0, err
+	}
+
+	t.NextRun, t.LastRun, t.LastResult, t.LastError = 0, 0, "", ""
+
+	s.lk.Lock()
+	head := s.head
+	s.lk.Unlock()
+
+	if head != nil {
+		t.Created = head.Height()
+		if t.Deadline == nil && t.Epoch <= head.Height() {
+			return 0, xerrors.Errorf("epoch %d was already reached, chain head is at %d", t.Epoch, head.Height())
+		}
+
+		// also checks that the miner exists
+		if _, err := s.schedule(ctx, &t, head); err != nil {
+			return 0, err
+		}
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	t.ID = s.nextID
+	if err := s.put(&t); err != nil {
+		return 0, err
+	}
+	s.nextID++
+	s.tasks[t.ID] = &t
+
+	return t.ID, nil
+}
+
+func validate(t api.EpochTask) error {
+	if (t.Epoch > 0) == (t.Deadline != nil) {
+		return xerrors.Errorf("either an epoch or a deadline must be set")
+	}
+	if t.Deadline != nil && t.Deadline.Index >= miner.WPoStPeriodDeadlines {
+		return xerrors.Errorf("deadline index %d out of range", t.Deadline.Index)
+	}
+
+	actions := 0
+	if a := t.Action.Message; a != nil {
+		if a.To == address.Undef || a.From == address.Undef {
+			return xerrors.Errorf("message action must have a sender and a target")
+		}
+		actions++
+	}
+	if t.Action.Command != "" {
+		actions++
+	}
+	if t.Action.Webhook != "" {
+		u, err := url.Parse(t.Action.Webhook)
+		if err != nil {
+			return xerrors.Errorf("parsing webhook url: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return xerrors.Errorf("webhook url must be http or https")
+		}
+		actions++
+	}
+	if actions != 1 {
+		return xerrors.Errorf("task must have exactly one action, has %d", actions)
+	}
+
+	return nil
+}
+
+// Remove removes the task, a run in progress isn't interrupted
+func (s *Scheduler) Remove(id uint64) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, ok := s.tasks[id]; !ok {
+		return xerrors.Errorf("task %d not found", id)
+	}
+
+	if err := s.ds.Delete(taskKey(id)); err != nil {
+		return xerrors.Errorf("deleting task %d: %w", id, err)
+	}
+	delete(s.tasks, id)
+
+	return nil
+}
+
+// List returns the tasks by ID
+func (s *Scheduler) List() []api.EpochTask {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	out := make([]api.EpochTask, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	return out
+}
+
+func (s *Scheduler) processHead(ctx context.Context, ts *types.TipSet) {
+	s.lk.Lock()
+	s.head = ts
+	tasks := make([]api.EpochTask, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, *t)
+	}
+	s.lk.Unlock()
+
+	for _, t := range tasks {
+		t := t
+
+		at, err := s.schedule(ctx, &t, ts)
+		if err != nil {
+			log.Warnw("scheduling task", "task", t.ID, "error", err)
+			continue
+		}
+
+		s.lk.Lock()
+		cur, ok := s.tasks[t.ID]
+		if !ok {
+			// removed meanwhile
+			s.lk.Unlock()
+			continue
+		}
+
+		cur.NextRun = t.NextRun
+		if at == 0 {
+			s.lk.Unlock()
+			continue
+		}
+
+		// marked as run before running, so that it doesn't run again if the
+		// node restarts meanwhile
+		cur.LastRun = at
+		if err := s.put(cur); err != nil {
+			log.Errorw("storing task", "task", t.ID, "error", err)
+		}
+		t = *cur
+		s.lk.Unlock()
+
+		go s.run(ctx, t, Run{
+			Task:   t,
+			Epoch:  at,
+			Height: ts.Height(),
+			TipSet: ts.Key(),
+		})
+	}
+}
+
+// schedule sets the next run of the task, and returns the epoch it's due at,
+// 0 if it isn't due
+func (s *Scheduler) schedule(ctx context.Context, t *api.EpochTask, ts *types.TipSet) (abi.ChainEpoch, error) {
+	if t.Deadline == nil {
+		at, next := dueAtEpoch(t, ts.Height())
+		t.NextRun = next
+		return at, nil
+	}
+
+	di, err := s.api.StateMinerProvingDeadline(ctx, t.Deadline.Miner, ts.Key())
+	if err != nil {
+		return 0, xerrors.Errorf("getting proving deadline of %s: %w", t.Deadline.Miner, err)
+	}
+
+	at, next := dueAtDeadline(t, ts.Height(), di)
+	t.NextRun = next
+	return at, nil
+}
+
+// dueAtEpoch returns the epoch the task is due at if it is, and the epoch of
+// its next run, 0 if there isn't one
+func dueAtEpoch(t *api.EpochTask, h abi.ChainEpoch) (at, next abi.ChainEpoch) {
+	switch {
+	case t.LastRun != 0:
+		return 0, 0
+	case h >= t.Epoch:
+		return t.Epoch, 0
+	default:
+		return 0, t.Epoch
+	}
+}
+
+// dueAtDeadline is dueAtEpoch for deadline relative tasks. The last occurrence
+// of the task at or before h is due if the task didn't run at it, it was
+// created before it, and it's less than a challenge window ago.
+func dueAtDeadline(t *api.EpochTask, h abi.ChainEpoch, di *dline.Info) (at, next abi.ChainEpoch) {
+	period := di.WPoStProvingPeriod
+
+	last := di.PeriodStart + abi.ChainEpoch(t.Deadline.Index)*di.WPoStChallengeWindow + t.Deadline.Offset
+	for last > h {
+		last -= period
+	}
+	for last+period <= h {
+		last += period
+	}
+
+	if last > t.LastRun && last > t.Created && h-last < di.WPoStChallengeWindow {
+		return last, last + period
+	}
+	return 0, last + period
+}
+
+func (s *Scheduler) run(ctx context.Context, t api.EpochTask, r Run) {
+	ctx, cancel := context.WithTimeout(ctx, ActionTimeout)
+	defer cancel()
+
+	res, err := s.execute(ctx, t.Action, r)
+	if err != nil {
+		log.Warnw("epoch task failed", "task", t.ID, "name", t.Name, "epoch", r.Epoch, "error", err)
+	} else {
+		log.Infow("epoch task ran", "task", t.ID, "name", t.Name, "epoch", r.Epoch, "result", res)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	cur, ok := s.tasks[t.ID]
+	if !ok || cur.LastRun != r.Epoch {
+		// removed, or ran again meanwhile
+		return
+	}
+
+	cur.LastResult = res
+	cur.LastError = ""
+	if err != nil {
+		cur.LastError = err.Error()
+	}
+
+	if err := s.put(cur); err != nil {
+		log.Errorw("storing task", "task", t.ID, "error", err)
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, a api.EpochTaskAction, r Run) (string, error) {
+	switch {
+	case a.Message != nil:
+		msg := *a.Message
+		sm, err := s.api.MpoolPushMessage(ctx, &msg, nil)
+		if err != nil {
+			return "", xerrors.Errorf("pushing message: %w", err)
+		}
+		return sm.Cid().String(), nil
+	case a.Command != "":
+		return runCommand(ctx, a.Command, r)
+	case a.Webhook != "":
+		return callWebhook(ctx, a.Webhook, r)
+	default:
+		return "", xerrors.Errorf("task has no action")
+	}
+}
+
+func runCommand(ctx context.Context, cmd string, r Run) (string, error) {
+	j, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+
+	c := exec.CommandContext(ctx, "sh", "-c", cmd)
+	c.Stdin = bytes.NewReader(j)
+	c.Stdout = &out
+	c.Stderr = &out
+
+	err = c.Run()
+
+	res := out.String()
+	if len(res) > maxResultLen {
+		res = res[:maxResultLen]
+	}
+
+	if err != nil {
+		return res, xerrors.Errorf("running command: %w", err)
+	}
+	return res, nil
+}
+
+func callWebhook(ctx context.Context, u string, r Run) (string, error) {
+	j, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(j))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", xerrors.Errorf("calling webhook: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.Status, xerrors.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.Status, nil
+}
+
+func (s *Scheduler) put(t *api.EpochTask) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return xerrors.Errorf("encoding task %d: %w", t.ID, err)
+	}
+
+	if err := s.ds.Put(taskKey(t.ID), b); err != nil {
+		return xerrors.Errorf("storing task %d: %w", t.ID, err)
+	}
+	return nil
+}
+
+func taskKey(id uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("/%020d", id))
+}
@@ -0,0 +1,114 @@
+package epochtasks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestDueAtEpoch(t *testing.T) {
+	task := &api.EpochTask{Epoch: 100}
+
+	at, next := dueAtEpoch(task, 99)
+	require.Equal(t, abi.ChainEpoch(0), at)
+	require.Equal(t, abi.ChainEpoch(100), next)
+
+	// runs late if the node was down
+	at, next = dueAtEpoch(task, 105)
+	require.Equal(t, abi.ChainEpoch(100), at)
+	require.Equal(t, abi.ChainEpoch(0), next)
+
+	task.LastRun = 100
+	at, next = dueAtEpoch(task, 106)
+	require.Equal(t, abi.ChainEpoch(0), at)
+	require.Equal(t, abi.ChainEpoch(0), next)
+}
+
+func TestDueAtDeadline(t *testing.T) {
+	di := dline.NewInfo(1000, 0, 1010, 48, 2880, 60, 20, 4)
+	task := &api.EpochTask{
+		Deadline: &api.EpochTaskDeadline{Index: 2, Offset: -10},
+		Created:  1000,
+	}
+
+	// deadline 2 opens at 1120
+	at, next := dueAtDeadline(task, 1100, di)
+	require.Equal(t, abi.ChainEpoch(0), at)
+	require.Equal(t, abi.ChainEpoch(1110), next)
+
+	at, next = dueAtDeadline(task, 1112, di)
+	require.Equal(t, abi.ChainEpoch(1110), at)
+	require.Equal(t, abi.ChainEpoch(1110+2880), next)
+
+	task.LastRun = 1110
+	at, _ = dueAtDeadline(task, 1115, di)
+	require.Equal(t, abi.ChainEpoch(0), at)
+
+	// next period, the deadline info is still of the previous one
+	at, _ = dueAtDeadline(task, 1110+2880+1, di)
+	require.Equal(t, abi.ChainEpoch(1110+2880), at)
+
+	// more than a challenge window late
+	at, next = dueAtDeadline(task, 1110+2880+60, di)
+	require.Equal(t, abi.ChainEpoch(0), at)
+	require.Equal(t, abi.ChainEpoch(1110+2*2880), next)
+}
+
+type nilAPI struct{}
+
+func (nilAPI) StateMinerProvingDeadline(context.Context, address.Address, types.TipSetKey) (*dline.Info, error) {
+	return dline.NewInfo(0, 0, 0, 48, 2880, 60, 20, 4), nil
+}
+
+func (nilAPI) MpoolPushMessage(context.Context, *types.Message, *api.MessageSendSpec) (*types.SignedMessage, error) {
+	panic("not implemented")
+}
+
+func TestAddRemove(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMapDatastore()
+
+	s, err := New(nilAPI{}, ds)
+	require.NoError(t, err)
+
+	_, err = s.Add(ctx, api.EpochTask{Epoch: 10})
+	require.Error(t, err, "no action")
+
+	_, err = s.Add(ctx, api.EpochTask{Epoch: 10, Action: api.EpochTaskAction{Command: "true", Webhook: "http://localhost"}})
+	require.Error(t, err, "two actions")
+
+	_, err = s.Add(ctx, api.EpochTask{Deadline: &api.EpochTaskDeadline{Index: 48}, Action: api.EpochTaskAction{Command: "true"}})
+	require.Error(t, err, "deadline out of range")
+
+	id, err := s.Add(ctx, api.EpochTask{Name: "a", Epoch: 10, Action: api.EpochTaskAction{Command: "true"}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), id)
+
+	id, err = s.Add(ctx, api.EpochTask{Name: "b", Epoch: 20, Action: api.EpochTaskAction{Webhook: "http://localhost/hook"}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), id)
+
+	require.NoError(t, s.Remove(1))
+	require.Error(t, s.Remove(1))
+
+	// tasks survive restarts, IDs aren't reused
+	s, err = New(nilAPI{}, ds)
+	require.NoError(t, err)
+
+	tasks := s.List()
+	require.Len(t, tasks, 1)
+	require.Equal(t, "b", tasks[0].Name)
+
+	id, err = s.Add(ctx, api.EpochTask{Epoch: 30, Action: api.EpochTaskAction{Command: "true"}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), id)
+}
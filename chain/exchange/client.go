@@ -90,6 +90,9 @@ func (c *client) doRequest(
 	// by an internal peer tracker with some randomness injected).
 	var peers []peer.ID
 	if singlePeer != nil {
+		if c.peerTracker.peerBanned(*singlePeer) {
+			return nil, xerrors.Errorf("peer %s is banned", *singlePeer)
+		}
 		peers = []peer.ID{*singlePeer}
 	} else {
 		peers = c.getShuffledPeers()
@@ -113,6 +116,7 @@ func (c *client) doRequest(
 		}
 
 		// Send request, read response.
+		reqStart := build.Clock.Now()
 		res, err := c.sendRequestToPeer(ctx, peer, req)
 		if err != nil {
 			if !xerrors.Is(err, network.ErrNoConn) {
@@ -127,9 +131,11 @@ func (c *client) doRequest(
 		if err != nil {
 			log.Warnf("processing peer %s response failed: %s",
 				peer.String(), err)
+			c.peerTracker.logInvalid(peer)
 			continue
 		}
 
+		c.peerTracker.logSuccess(peer, build.Clock.Since(reqStart), uint64(len(res.Chain)))
 		c.peerTracker.logGlobalSuccess(build.Clock.Since(globalTime))
 		c.host.ConnManager().TagPeer(peer, "bsync", SuccessPeerTagValue)
 		return validRes, nil
@@ -444,9 +450,7 @@ func (c *client) sendRequestToPeer(ctx context.Context, peer peer.ID, req *Reque
 		)
 	}
 
-	c.peerTracker.logSuccess(peer, build.Clock.Since(connectionStart), uint64(len(res.Chain)))
-	// FIXME: We should really log a success only after we validate the response.
-	//  It might be a bit hard to do.
+	// The success is logged by the caller once the response is validated.
 	return &res, nil
 }
 
@@ -460,6 +464,21 @@ func (c *client) RemovePeer(p peer.ID) {
 	c.peerTracker.removePeer(p)
 }
 
+// LogBadBlock implements Client.LogBadBlock(). Refer to the godocs there.
+func (c *client) LogBadBlock(p peer.ID) {
+	c.peerTracker.logBadBlock(p)
+}
+
+// Peers implements Client.Peers(). Refer to the godocs there.
+func (c *client) Peers() []PeerInfo {
+	return c.peerTracker.peerInfos()
+}
+
+// UnbanPeer implements Client.UnbanPeer(). Refer to the godocs there.
+func (c *client) UnbanPeer(p peer.ID) bool {
+	return c.peerTracker.unban(p)
+}
+
 // getShuffledPeers returns a preference-sorted set of peers (by latency
 // and failure counting), shuffling the first few peers so we don't always
 // pick the same peer.
@@ -47,4 +47,16 @@ type Client interface {
 	// RemovePeer removes a peer from the pool of peers that the Client
 	// requests data from.
 	RemovePeer(peer peer.ID)
+
+	// LogBadBlock penalizes a peer which sent us an invalid block. Peers
+	// which misbehave too often are banned for BanDuration.
+	LogBadBlock(peer peer.ID)
+
+	// Peers returns the track record of the peers the Client requests data
+	// from, including the banned ones.
+	Peers() []PeerInfo
+
+	// UnbanPeer lifts the ban of a peer and resets its score. It returns
+	// false if the peer wasn't banned.
+	UnbanPeer(peer peer.ID) bool
 }
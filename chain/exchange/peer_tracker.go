@@ -4,6 +4,7 @@ package exchange
 
 import (
 	"context"
+	"math"
 	"sort"
 	"sync"
 	"time"
@@ -21,21 +22,84 @@ type peerStats struct {
 	failures    int
 	firstSeen   time.Time
 	averageTime time.Duration
+
+	invalid int
+	slow    int
+}
+
+// peerScore tracks the misbehaviour of a peer. Scores are kept apart from the
+// stats of the peers we request data from, as peers relaying bad blocks over
+// gossip aren't necessarily in that pool.
+type peerScore struct {
+	badBlocks int
+
+	// score is the sum of the penalties of the peer, decayed to scoreAt
+	score   float64
+	scoreAt time.Time
+}
+
+// PeerInfo is the track record of a peer we request chain data from, or which
+// relayed bad blocks
+type PeerInfo struct {
+	ID peer.ID
+
+	Successes int
+	// Failures counts the requests which timed out or broke off
+	Failures         int
+	InvalidResponses int
+	SlowResponses    int
+	BadBlocks        int
+
+	// AverageTime is the average time it took the peer to send a tipset
+	AverageTime time.Duration
+	Score       float64
+	// BannedUntil is zero for peers which aren't banned
+	BannedUntil time.Time
 }
 
+const (
+	// Penalties added to the score of peers for each kind of misbehaviour.
+	// The score halves every scoreHalfLife; peers reaching banScore are
+	// not requested any data for BanDuration.
+	failurePenalty  = 1
+	slowPenalty     = 0.5
+	invalidPenalty  = 5
+	badBlockPenalty = 10
+
+	banScore      = 20
+	scoreHalfLife = 10 * time.Minute
+
+	// slowResponseMul is how many times slower than the average of all peers
+	// a peer has to send tipsets for its response to count as slow
+	slowResponseMul = 4
+
+	// scores decayed below minScore are forgotten
+	minScore = 0.01
+)
+
 type bsPeerTracker struct {
 	lk sync.Mutex
 
 	peers         map[peer.ID]*peerStats
 	avgGlobalTime time.Duration
+	// avgItemTime is the average time it took all peers to send a tipset
+	avgItemTime time.Duration
+
+	// scores of the peers which misbehaved, and banned peers with the time
+	// their ban ends; both outlive disconnections, so peers can't lift them
+	// by reconnecting
+	scores map[peer.ID]*peerScore
+	banned map[peer.ID]time.Time
 
 	pmgr *peermgr.PeerMgr
 }
 
 func newPeerTracker(lc fx.Lifecycle, h host.Host, pmgr *peermgr.PeerMgr) *bsPeerTracker {
 	bsPt := &bsPeerTracker{
-		peers: make(map[peer.ID]*peerStats),
-		pmgr:  pmgr,
+		peers:  make(map[peer.ID]*peerStats),
+		scores: make(map[peer.ID]*peerScore),
+		banned: make(map[peer.ID]time.Time),
+		pmgr:   pmgr,
 	}
 
 	evtSub, err := h.EventBus().Subscribe(new(peermgr.FilPeerEvt))
@@ -88,6 +152,9 @@ func (bpt *bsPeerTracker) prefSortedPeers() []peer.ID {
 	defer bpt.lk.Unlock()
 	out := make([]peer.ID, 0, len(bpt.peers))
 	for p := range bpt.peers {
+		if bpt.isBanned(p) {
+			continue
+		}
 		out = append(out, p)
 	}
 
@@ -167,7 +234,19 @@ func (bpt *bsPeerTracker) logSuccess(p peer.ID, dur time.Duration, reqSize uint6
 	if reqSize == 0 {
 		reqSize = 1
 	}
-	logTime(pi, dur/time.Duration(reqSize))
+	itemTime := dur / time.Duration(reqSize)
+	logTime(pi, itemTime)
+
+	if bpt.avgItemTime != 0 && itemTime > slowResponseMul*bpt.avgItemTime {
+		pi.slow++
+		bpt.penalize(p, slowPenalty)
+	}
+
+	if bpt.avgItemTime == 0 {
+		bpt.avgItemTime = itemTime
+	} else {
+		bpt.avgItemTime += (itemTime - bpt.avgItemTime) / globalInvAlpha
+	}
 }
 
 func (bpt *bsPeerTracker) logFailure(p peer.ID, dur time.Duration, reqSize uint64) {
@@ -186,6 +265,166 @@ func (bpt *bsPeerTracker) logFailure(p peer.ID, dur time.Duration, reqSize uint6
 		reqSize = 1
 	}
 	logTime(pi, dur/time.Duration(reqSize))
+	bpt.penalize(p, failurePenalty)
+}
+
+// logInvalid records a response which failed validation
+func (bpt *bsPeerTracker) logInvalid(p peer.ID) {
+	bpt.lk.Lock()
+	defer bpt.lk.Unlock()
+
+	pi, ok := bpt.peers[p]
+	if !ok {
+		log.Warnw("log invalid called on peer not in tracker", "peerid", p.String())
+		return
+	}
+
+	pi.invalid++
+	bpt.penalize(p, invalidPenalty)
+}
+
+// logBadBlock records a block from the peer which failed validation. The peer
+// doesn't need to be in the pool of peers we request data from.
+func (bpt *bsPeerTracker) logBadBlock(p peer.ID) {
+	bpt.lk.Lock()
+	defer bpt.lk.Unlock()
+
+	ps := bpt.penalize(p, badBlockPenalty)
+	ps.badBlocks++
+}
+
+// penalize adds a penalty to the score of a peer, and bans it when the score
+// gets too high. Must be called with the lock held.
+func (bpt *bsPeerTracker) penalize(p peer.ID, penalty float64) *peerScore {
+	now := build.Clock.Now()
+
+	// forget the peers which behaved since they were last penalized, so
+	// the scores of peers relaying over gossip don't pile up
+	for sp, ps := range bpt.scores {
+		if sp != p && decayedScore(ps, now) < minScore {
+			delete(bpt.scores, sp)
+		}
+	}
+
+	ps, ok := bpt.scores[p]
+	if !ok {
+		ps = &peerScore{}
+		bpt.scores[p] = ps
+	}
+
+	ps.score = decayedScore(ps, now) + penalty
+	ps.scoreAt = now
+
+	if ps.score >= banScore && !bpt.isBanned(p) {
+		log.Warnw("banning misbehaving peer", "peerid", p.String(), "score", ps.score, "until", now.Add(BanDuration))
+		bpt.banned[p] = now.Add(BanDuration)
+		ps.score = 0
+	}
+
+	return ps
+}
+
+func decayedScore(ps *peerScore, now time.Time) float64 {
+	if ps.score == 0 {
+		return 0
+	}
+	return ps.score * math.Pow(0.5, float64(now.Sub(ps.scoreAt))/float64(scoreHalfLife))
+}
+
+// score returns the decayed score of a peer. Must be called with the lock
+// held.
+func (bpt *bsPeerTracker) score(p peer.ID, now time.Time) (float64, int) {
+	ps, ok := bpt.scores[p]
+	if !ok {
+		return 0, 0
+	}
+	return decayedScore(ps, now), ps.badBlocks
+}
+
+// isBanned must be called with the lock held
+func (bpt *bsPeerTracker) isBanned(p peer.ID) bool {
+	until, ok := bpt.banned[p]
+	if !ok {
+		return false
+	}
+	if !build.Clock.Now().Before(until) {
+		delete(bpt.banned, p)
+		return false
+	}
+	return true
+}
+
+func (bpt *bsPeerTracker) peerBanned(p peer.ID) bool {
+	bpt.lk.Lock()
+	defer bpt.lk.Unlock()
+	return bpt.isBanned(p)
+}
+
+// unban lifts the ban of a peer and resets its score. It returns false if
+// the peer wasn't banned.
+func (bpt *bsPeerTracker) unban(p peer.ID) bool {
+	bpt.lk.Lock()
+	defer bpt.lk.Unlock()
+
+	wasBanned := bpt.isBanned(p)
+	delete(bpt.banned, p)
+	if ps, ok := bpt.scores[p]; ok {
+		ps.score = 0
+	}
+	return wasBanned
+}
+
+func (bpt *bsPeerTracker) peerInfos() []PeerInfo {
+	bpt.lk.Lock()
+	defer bpt.lk.Unlock()
+
+	now := build.Clock.Now()
+	out := make([]PeerInfo, 0, len(bpt.peers))
+	for p, pi := range bpt.peers {
+		info := PeerInfo{
+			ID:               p,
+			Successes:        pi.successes,
+			Failures:         pi.failures,
+			InvalidResponses: pi.invalid,
+			SlowResponses:    pi.slow,
+			AverageTime:      pi.averageTime,
+		}
+		info.Score, info.BadBlocks = bpt.score(p, now)
+		if bpt.isBanned(p) {
+			info.BannedUntil = bpt.banned[p]
+		}
+		out = append(out, info)
+	}
+
+	// peers outside of the pool which misbehaved, e.g. by relaying bad
+	// blocks, or which disconnected while banned
+	others := map[peer.ID]struct{}{}
+	for p := range bpt.scores {
+		others[p] = struct{}{}
+	}
+	for p := range bpt.banned {
+		others[p] = struct{}{}
+	}
+	for p := range others {
+		if _, ok := bpt.peers[p]; ok {
+			continue
+		}
+
+		info := PeerInfo{ID: p}
+		info.Score, info.BadBlocks = bpt.score(p, now)
+		if bpt.isBanned(p) {
+			info.BannedUntil = bpt.banned[p]
+		} else if info.BadBlocks == 0 {
+			continue
+		}
+		out = append(out, info)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	return out
 }
 
 func (bpt *bsPeerTracker) removePeer(p peer.ID) {
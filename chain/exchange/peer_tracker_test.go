@@ -0,0 +1,111 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/raulk/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/build"
+)
+
+func testPeerTracker(t *testing.T) (*bsPeerTracker, *clock.Mock) {
+	mClock := clock.NewMock()
+	mClock.Set(time.Now())
+
+	oldClock := build.Clock
+	build.Clock = mClock
+	t.Cleanup(func() {
+		build.Clock = oldClock
+	})
+
+	return &bsPeerTracker{
+		peers:  make(map[peer.ID]*peerStats),
+		scores: make(map[peer.ID]*peerScore),
+		banned: make(map[peer.ID]time.Time),
+	}, mClock
+}
+
+func TestPeerTrackerBan(t *testing.T) {
+	bpt, mClock := testPeerTracker(t)
+
+	p := peer.ID("requested")
+	bpt.addPeer(p)
+
+	for i := 0; i < banScore/invalidPenalty-1; i++ {
+		bpt.logInvalid(p)
+	}
+	require.False(t, bpt.peerBanned(p))
+	require.Contains(t, bpt.prefSortedPeers(), p)
+
+	bpt.logInvalid(p)
+	require.True(t, bpt.peerBanned(p))
+	require.NotContains(t, bpt.prefSortedPeers(), p)
+
+	// reconnecting doesn't lift the ban
+	bpt.removePeer(p)
+	bpt.addPeer(p)
+	require.True(t, bpt.peerBanned(p))
+
+	mClock.Add(BanDuration)
+	require.False(t, bpt.peerBanned(p))
+	require.Contains(t, bpt.prefSortedPeers(), p)
+}
+
+func TestPeerTrackerDecay(t *testing.T) {
+	bpt, mClock := testPeerTracker(t)
+
+	p := peer.ID("requested")
+	bpt.addPeer(p)
+
+	bpt.logInvalid(p)
+	bpt.logInvalid(p)
+	bpt.logInvalid(p)
+
+	// the score halves every scoreHalfLife, so the next penalties don't
+	// reach banScore
+	mClock.Add(scoreHalfLife)
+	bpt.logInvalid(p)
+	require.False(t, bpt.peerBanned(p))
+
+	score, _ := bpt.score(p, mClock.Now())
+	require.InDelta(t, float64(3*invalidPenalty)/2+invalidPenalty, score, 0.001)
+
+	// decayed scores are forgotten
+	mClock.Add(20 * scoreHalfLife)
+	bpt.logInvalid(peer.ID("other"))
+	_, ok := bpt.scores[p]
+	require.False(t, ok)
+}
+
+func TestPeerTrackerBadBlocks(t *testing.T) {
+	bpt, _ := testPeerTracker(t)
+
+	// peers relaying bad blocks over gossip aren't added to the pool of
+	// peers chain data is requested from
+	p := peer.ID("gossip")
+	bpt.logBadBlock(p)
+	require.Empty(t, bpt.prefSortedPeers())
+
+	infos := bpt.peerInfos()
+	require.Len(t, infos, 1)
+	require.Equal(t, p, infos[0].ID)
+	require.Equal(t, 1, infos[0].BadBlocks)
+	require.True(t, infos[0].BannedUntil.IsZero())
+
+	bpt.logBadBlock(p)
+	require.True(t, bpt.peerBanned(p))
+
+	// the ban holds when the peer joins the pool
+	bpt.addPeer(p)
+	require.Empty(t, bpt.prefSortedPeers())
+
+	require.True(t, bpt.unban(p))
+	require.False(t, bpt.peerBanned(p))
+	require.Equal(t, []peer.ID{p}, bpt.prefSortedPeers())
+
+	score, _ := bpt.score(p, build.Clock.Now())
+	require.Zero(t, score)
+}
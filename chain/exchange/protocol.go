@@ -42,6 +42,7 @@ const (
 	ReadResMinSpeed     = 50 << 10
 	ShufflePeersPrefix  = 16
 	WriteResDeadline    = 60 * time.Second
+	BanDuration         = 30 * time.Minute
 )
 
 // FIXME: Rename. Make private.
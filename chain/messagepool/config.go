@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/filecoin-project/go-address"
+
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/ipfs/go-datastore"
@@ -81,6 +83,17 @@ func (mp *MessagePool) SetConfig(cfg *types.MpoolConfig) error {
 	return nil
 }
 
+// SetPriorityOverride prioritizes messages from addrs, and from all local
+// addresses if local is set, on top of the mpool config. Unlike SetConfig the
+// override isn't persisted, so it only lasts until the node restarts
+func (mp *MessagePool) SetPriorityOverride(addrs []address.Address, local bool) {
+	mp.lk.Lock()
+	defer mp.lk.Unlock()
+
+	mp.priorityOverride = append([]address.Address{}, addrs...)
+	mp.priorityLocalOverride = local
+}
+
 func DefaultConfig() *types.MpoolConfig {
 	return &types.MpoolConfig{
 		SizeLimitHigh:          MemPoolSizeLimitHiDefault,
@@ -126,8 +126,19 @@ type MessagePool struct {
 
 	republished map[cid.Cid]struct{}
 
+	// unconfirmed are the local messages published by this node, which
+	// weren't yet included in a block
+	unconfirmed map[cid.Cid]*unconfirmedMsg
+	// set while publishing local messages is paused
+	publishPaused bool
+
 	localAddrs map[address.Address]struct{}
 
+	// priority addresses set by the node config on top of the mpool config,
+	// which aren't persisted
+	priorityOverride      []address.Address
+	priorityLocalOverride bool
+
 	pending map[address.Address]*msgSet
 
 	curTsLk sync.Mutex // DO NOT LOCK INSIDE lk
@@ -358,6 +369,7 @@ func New(api Provider, ds dtypes.MetadataDS, netName dtypes.NetworkName, j journ
 		closer:        make(chan struct{}),
 		repubTk:       build.Clock.Ticker(RepublishInterval),
 		repubTrigger:  make(chan struct{}, 1),
+		unconfirmed:   make(map[cid.Cid]*unconfirmedMsg),
 		localAddrs:    make(map[address.Address]struct{}),
 		pending:       make(map[address.Address]*msgSet),
 		minGasPrice:   types.NewInt(0),
@@ -533,6 +545,7 @@ func (mp *MessagePool) Push(m *types.SignedMessage) (cid.Cid, error) {
 	}()
 
 	mp.curTsLk.Lock()
+	epoch := mp.curTs.Height()
 	publish, err := mp.addTs(m, mp.curTs, true, false)
 	if err != nil {
 		mp.curTsLk.Unlock()
@@ -541,14 +554,8 @@ func (mp *MessagePool) Push(m *types.SignedMessage) (cid.Cid, error) {
 	mp.curTsLk.Unlock()
 
 	if publish {
-		msgb, err := m.Serialize()
-		if err != nil {
-			return cid.Undef, xerrors.Errorf("error serializing message: %w", err)
-		}
-
-		err = mp.api.PubSubPublish(build.MessagesTopic(mp.netName), msgb)
-		if err != nil {
-			return cid.Undef, xerrors.Errorf("error publishing message: %w", err)
+		if err := mp.publish(m, epoch); err != nil {
+			return cid.Undef, err
 		}
 	}
 
@@ -871,6 +878,7 @@ func (mp *MessagePool) PushUntrusted(m *types.SignedMessage) (cid.Cid, error) {
 	}()
 
 	mp.curTsLk.Lock()
+	epoch := mp.curTs.Height()
 	publish, err := mp.addTs(m, mp.curTs, true, true)
 	if err != nil {
 		mp.curTsLk.Unlock()
@@ -879,14 +887,8 @@ func (mp *MessagePool) PushUntrusted(m *types.SignedMessage) (cid.Cid, error) {
 	mp.curTsLk.Unlock()
 
 	if publish {
-		msgb, err := m.Serialize()
-		if err != nil {
-			return cid.Undef, xerrors.Errorf("error serializing message: %w", err)
-		}
-
-		err = mp.api.PubSubPublish(build.MessagesTopic(mp.netName), msgb)
-		if err != nil {
-			return cid.Undef, xerrors.Errorf("error publishing message: %w", err)
+		if err := mp.publish(m, epoch); err != nil {
+			return cid.Undef, err
 		}
 	}
 
@@ -907,6 +909,8 @@ func (mp *MessagePool) remove(from address.Address, nonce uint64, applied bool)
 	}
 
 	if m, ok := mset.msgs[nonce]; ok {
+		delete(mp.unconfirmed, m.Cid())
+
 		mp.changes.Pub(api.MpoolUpdate{
 			Type:    api.MpoolRemove,
 			Message: m,
@@ -1148,6 +1152,10 @@ func (mp *MessagePool) HeadChange(revert []*types.TipSet, apply []*types.TipSet)
 		}
 	}
 
+	if len(apply) > 0 {
+		mp.publishUnconfirmed(mp.curTs.Height())
+	}
+
 	return merr
 }
 
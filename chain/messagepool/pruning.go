@@ -54,7 +54,7 @@ func (mp *MessagePool) pruneMessages(ctx context.Context, ts *types.TipSet) erro
 	protected := make(map[address.Address]struct{})
 
 	// we never prune priority addresses
-	for _, actor := range mp.cfg.PriorityAddrs {
+	for _, actor := range mp.priorityAddrs() {
 		protected[actor] = struct{}{}
 	}
 
@@ -0,0 +1,157 @@
+package messagepool
+
+import (
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// PublishConfirmEpochs is the number of epochs in which a message published by
+// this node must be included in a block, before it's published again. Peers
+// gossiping the message back can't confirm it, as pubsub drops messages it has
+// already seen before they reach the pool.
+var PublishConfirmEpochs = abi.ChainEpoch(5)
+
+// PublishMaxAttempts is the number of times an unconfirmed message is
+// published before the publisher gives up on it, leaving it to the republish
+// loop
+var PublishMaxAttempts = 5
+
+func init() {
+	// publishing again before the pubsub timecache expires is a noop
+	minEpochs := abi.ChainEpoch(pubsub.TimeCacheDuration/(time.Duration(build.BlockDelaySecs)*time.Second)) + 1
+	if PublishConfirmEpochs < minEpochs {
+		PublishConfirmEpochs = minEpochs
+	}
+}
+
+// unconfirmedMsg is a message published by this node, which wasn't yet
+// included in a block
+type unconfirmedMsg struct {
+	msg         *types.SignedMessage
+	publishedAt abi.ChainEpoch
+	attempts    int
+	// gaveUp is set after PublishMaxAttempts, the message stays tracked until
+	// it leaves the pool so that it's not tracked again
+	gaveUp bool
+}
+
+// SetPublishing pauses or resumes publishing local messages. Messages pushed
+// while publishing is paused stay in the pool, and are published at the first
+// head change after it's resumed.
+func (mp *MessagePool) SetPublishing(enabled bool) {
+	mp.lk.Lock()
+	defer mp.lk.Unlock()
+
+	mp.publishPaused = !enabled
+}
+
+// publish publishes a local message, and tracks it until it's confirmed. The
+// message must already be persisted in the local message store, so it's not
+// lost if the node restarts before it's confirmed.
+func (mp *MessagePool) publish(m *types.SignedMessage, epoch abi.ChainEpoch) error {
+	mp.lk.Lock()
+	if mp.publishPaused {
+		if _, ok := mp.unconfirmed[m.Cid()]; !ok {
+			// due as soon as publishing is resumed
+			mp.unconfirmed[m.Cid()] = &unconfirmedMsg{
+				msg:         m,
+				publishedAt: epoch - PublishConfirmEpochs,
+			}
+		}
+		mp.lk.Unlock()
+		return nil
+	}
+	mp.lk.Unlock()
+
+	msgb, err := m.Serialize()
+	if err != nil {
+		return xerrors.Errorf("error serializing message: %w", err)
+	}
+
+	if err := mp.api.PubSubPublish(build.MessagesTopic(mp.netName), msgb); err != nil {
+		return xerrors.Errorf("error publishing message: %w", err)
+	}
+
+	mp.lk.Lock()
+	mp.trackUnconfirmed(m, epoch)
+	mp.lk.Unlock()
+
+	return nil
+}
+
+// trackUnconfirmed starts tracking a published message, unless it's already
+// tracked, or was given up on. mp.lk must be held.
+func (mp *MessagePool) trackUnconfirmed(m *types.SignedMessage, epoch abi.ChainEpoch) {
+	if _, ok := mp.unconfirmed[m.Cid()]; ok {
+		return
+	}
+
+	mp.unconfirmed[m.Cid()] = &unconfirmedMsg{
+		msg:         m,
+		publishedAt: epoch,
+		attempts:    1,
+	}
+}
+
+// publishUnconfirmed publishes the messages which weren't confirmed within
+// PublishConfirmEpochs again. By then, the pubsub mesh has usually changed,
+// and the message goes out through other peers.
+func (mp *MessagePool) publishUnconfirmed(epoch abi.ChainEpoch) {
+	var due []*types.SignedMessage
+
+	mp.lk.Lock()
+	if mp.publishPaused {
+		mp.lk.Unlock()
+		return
+	}
+	for c, u := range mp.unconfirmed {
+		if epoch-u.publishedAt < PublishConfirmEpochs {
+			continue
+		}
+
+		// replaced or pruned messages don't need to be delivered
+		mset, ok := mp.pending[u.msg.Message.From]
+		if !ok {
+			delete(mp.unconfirmed, c)
+			continue
+		}
+		if pm, ok := mset.msgs[u.msg.Message.Nonce]; !ok || pm.Cid() != c {
+			delete(mp.unconfirmed, c)
+			continue
+		}
+
+		if u.gaveUp {
+			continue
+		}
+		if u.attempts >= PublishMaxAttempts {
+			log.Warnf("local message %s wasn't included after publishing it %d times", c, u.attempts)
+			u.gaveUp = true
+			continue
+		}
+
+		u.attempts++
+		u.publishedAt = epoch
+		due = append(due, u.msg)
+	}
+	mp.lk.Unlock()
+
+	for _, m := range due {
+		msgb, err := m.Serialize()
+		if err != nil {
+			log.Errorf("error serializing message %s: %s", m.Cid(), err)
+			continue
+		}
+
+		log.Infof("publishing unconfirmed message %s again", m.Cid())
+		if err := mp.api.PubSubPublish(build.MessagesTopic(mp.netName), msgb); err != nil {
+			log.Errorf("error publishing message %s: %s", m.Cid(), err)
+		}
+	}
+}
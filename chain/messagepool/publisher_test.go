@@ -0,0 +1,163 @@
+package messagepool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	builtin2 "github.com/filecoin-project/specs-actors/v2/actors/builtin"
+
+	"github.com/filecoin-project/lotus/chain/messagepool/gasguess"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+func TestPublishUnconfirmed(t *testing.T) {
+	tma := newTestMpoolAPI()
+	ds := datastore.NewMapDatastore()
+
+	mp, err := New(tma, ds, "mptest", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w1, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a1, err := w1.WalletNew(context.Background(), types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w2, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err := w2.WalletNew(context.Background(), types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gasLimit := gasguess.Costs[gasguess.CostKey{Code: builtin2.StorageMarketActorCodeID, M: 2}]
+
+	tma.setBalance(a1, 1) // in FIL
+
+	var msgs []*types.SignedMessage
+	for i := 0; i < 3; i++ {
+		m := makeTestMessage(w1, a1, a2, uint64(i), gasLimit, 100)
+		if _, err := mp.Push(m); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+
+	if tma.published != 3 {
+		t.Fatalf("expected to have published 3 messages, but got %d instead", tma.published)
+	}
+
+	// the first message is included, a peer gossiping back the second one
+	// doesn't confirm it
+	b := tma.nextBlock()
+	tma.setBlockMessages(b, msgs[0])
+	tma.applyBlock(t, b)
+
+	_ = mp.Add(msgs[1])
+
+	for i := abi.ChainEpoch(1); i < PublishConfirmEpochs; i++ {
+		tma.applyBlock(t, tma.nextBlock())
+	}
+
+	// the second and third messages are published again
+	if tma.published != 5 {
+		t.Fatalf("expected to have published 5 messages, but got %d instead", tma.published)
+	}
+
+	mp.lk.Lock()
+	_, ok := mp.unconfirmed[msgs[2].Cid()]
+	mp.lk.Unlock()
+	if !ok {
+		t.Fatal("expected the third message to stay unconfirmed")
+	}
+
+	// the publisher gives up after PublishMaxAttempts
+	for i := abi.ChainEpoch(0); i < PublishConfirmEpochs*abi.ChainEpoch(PublishMaxAttempts); i++ {
+		tma.applyBlock(t, tma.nextBlock())
+	}
+
+	expected := 1 + 2*PublishMaxAttempts
+	if tma.published != expected {
+		t.Fatalf("expected to have published %d messages, but got %d instead", expected, tma.published)
+	}
+
+	// the republish loop doesn't track messages which were given up on again
+	mp.lk.Lock()
+	mp.trackUnconfirmed(msgs[2], mp.curTs.Height())
+	mp.lk.Unlock()
+
+	for i := abi.ChainEpoch(0); i < PublishConfirmEpochs; i++ {
+		tma.applyBlock(t, tma.nextBlock())
+	}
+
+	if tma.published != expected {
+		t.Fatalf("expected to have published %d messages, but got %d instead", expected, tma.published)
+	}
+}
+
+func TestPublishingPaused(t *testing.T) {
+	tma := newTestMpoolAPI()
+	ds := datastore.NewMapDatastore()
+
+	mp, err := New(tma, ds, "mptest", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w1, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a1, err := w1.WalletNew(context.Background(), types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w2, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err := w2.WalletNew(context.Background(), types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gasLimit := gasguess.Costs[gasguess.CostKey{Code: builtin2.StorageMarketActorCodeID, M: 2}]
+
+	tma.setBalance(a1, 1) // in FIL
+
+	mp.SetPublishing(false)
+
+	m := makeTestMessage(w1, a1, a2, 0, gasLimit, 100)
+	if _, err := mp.Push(m); err != nil {
+		t.Fatal(err)
+	}
+
+	tma.applyBlock(t, tma.nextBlock())
+
+	if tma.published != 0 {
+		t.Fatalf("expected no published messages while paused, but got %d", tma.published)
+	}
+
+	mp.SetPublishing(true)
+	tma.applyBlock(t, tma.nextBlock())
+
+	if tma.published == 0 {
+		t.Fatal("expected the message to be published after resuming")
+	}
+}
@@ -19,6 +19,13 @@ const repubMsgLimit = 30
 var RepublishBatchDelay = 100 * time.Millisecond
 
 func (mp *MessagePool) republishPendingMessages() error {
+	mp.lk.Lock()
+	paused := mp.publishPaused
+	mp.lk.Unlock()
+	if paused {
+		return nil
+	}
+
 	mp.curTsLk.Lock()
 	ts := mp.curTs
 
@@ -168,6 +175,11 @@ loop:
 	mp.lk.Lock()
 	// update the republished set so that we can trigger early republish from head changes
 	mp.republished = republished
+	// messages loaded from the local store after a restart are first
+	// published here
+	for _, m := range msgs[:count] {
+		mp.trackUnconfirmed(m, ts.Height())
+	}
 	mp.lk.Unlock()
 
 	return nil
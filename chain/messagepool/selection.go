@@ -539,7 +539,7 @@ func (mp *MessagePool) selectPriorityMessages(pending map[address.Address]map[ui
 
 	// 1. Get priority actor chains
 	var chains []*msgChain
-	priority := mp.cfg.PriorityAddrs
+	priority := mp.priorityAddrs()
 	for _, actor := range priority {
 		mset, ok := pending[actor]
 		if ok {
@@ -630,6 +630,33 @@ tailLoop:
 	return result, gasLimit
 }
 
+// priorityAddrs returns the addresses whose messages are selected before all
+// other messages, regardless of their gas performance. Caller should hold mp.lk
+func (mp *MessagePool) priorityAddrs() []address.Address {
+	out := make([]address.Address, 0, len(mp.cfg.PriorityAddrs)+len(mp.priorityOverride))
+	seen := make(map[address.Address]struct{}, cap(out))
+	add := func(a address.Address) {
+		if _, ok := seen[a]; !ok {
+			seen[a] = struct{}{}
+			out = append(out, a)
+		}
+	}
+
+	for _, a := range mp.cfg.PriorityAddrs {
+		add(a)
+	}
+	for _, a := range mp.priorityOverride {
+		add(a)
+	}
+	if mp.cfg.PriorityLocal || mp.priorityLocalOverride {
+		for a := range mp.localAddrs {
+			add(a)
+		}
+	}
+
+	return out
+}
+
 func (mp *MessagePool) getPendingMessages(curTs, ts *types.TipSet) (map[address.Address]map[uint64]*types.SignedMessage, error) {
 	start := time.Now()
 
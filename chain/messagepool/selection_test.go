@@ -1466,3 +1466,105 @@ readLoop:
 	}
 
 }
+
+func TestPriorityLocalMessageSelection(t *testing.T) {
+	mp, tma := makeTestMpool()
+
+	// the actors
+	w1, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a1, err := w1.WalletNew(context.Background(), types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w2, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err := w2.WalletNew(context.Background(), types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := tma.nextBlock()
+	ts := mock.TipSet(block)
+	tma.applyBlock(t, block)
+
+	gasLimit := gasguess.Costs[gasguess.CostKey{Code: builtin2.StorageMarketActorCodeID, M: 2}]
+
+	tma.setBalance(a1, 1) // in FIL
+	tma.setBalance(a2, 1) // in FIL
+
+	// a1 published its messages through this node
+	mp.localAddrs[a1] = struct{}{}
+	mp.cfg.PriorityLocal = true
+
+	nMessages := 10
+	for i := 0; i < nMessages; i++ {
+		// messages from a1 pay less than messages from a2
+		m := makeTestMessage(w1, a1, a2, uint64(i), gasLimit, 1)
+		mustAdd(t, mp, m)
+		m = makeTestMessage(w2, a2, a1, uint64(i), gasLimit, 10)
+		mustAdd(t, mp, m)
+	}
+
+	msgs, err := mp.SelectMessages(ts, 1.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 20 {
+		t.Fatalf("expected 20 messages but got %d", len(msgs))
+	}
+
+	// messages from a1 must be first
+	for i := 0; i < 10; i++ {
+		m := msgs[i]
+		if m.Message.From != a1 {
+			t.Fatal("expected messages from a1 before messages from a2")
+		}
+		if m.Message.Nonce != uint64(i) {
+			t.Fatalf("expected nonce %d but got %d", i, m.Message.Nonce)
+		}
+	}
+}
+
+func TestPriorityOverride(t *testing.T) {
+	mp, _ := makeTestMpool()
+
+	a1, err := address.NewIDAddress(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, err := address.NewIDAddress(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp.localAddrs[a2] = struct{}{}
+	mp.SetPriorityOverride([]address.Address{a1}, true)
+
+	prio := mp.priorityAddrs()
+	if len(prio) != 2 || prio[0] != a1 || prio[1] != a2 {
+		t.Fatalf("expected priority addresses [%s %s] but got %v", a1, a2, prio)
+	}
+
+	// the override doesn't end up in the persisted config
+	cfg, err := loadConfig(mp.ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.PriorityAddrs) != 0 || cfg.PriorityLocal {
+		t.Fatal("expected the override not to be persisted")
+	}
+
+	mp.SetPriorityOverride(nil, false)
+	if prio := mp.priorityAddrs(); len(prio) != 0 {
+		t.Fatalf("expected no priority addresses but got %v", prio)
+	}
+}
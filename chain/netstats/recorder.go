@@ -0,0 +1,286 @@
+package netstats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	blockadt "github.com/filecoin-project/specs-actors/actors/util/adt"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/power"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+)
+
+var log = logging.Logger("netstats")
+
+// MaxRange is the maximum number of epochs which can be queried at once
+const MaxRange = 7 * builtin.EpochsInDay
+
+var dsPrefix = datastore.NewKey("/netstats")
+
+// epochStats holds the statistics of a single tipset, as persisted in the
+// metadata datastore
+type epochStats struct {
+	Height    abi.ChainEpoch
+	Timestamp uint64
+
+	BaseFee  abi.TokenAmount
+	GasUsed  int64
+	GasLimit int64
+	Messages uint64
+
+	NetworkQAP      abi.StoragePower
+	NetworkRawPower abi.StoragePower
+}
+
+// Recorder records per-epoch network statistics as the chain head advances,
+// so that they can be served as time series without walking the chain
+type Recorder struct {
+	sm *stmgr.StateManager
+	cs *store.ChainStore
+	ds datastore.Batching
+}
+
+func NewRecorder(mctx helpers.MetricsCtx, lc fx.Lifecycle, sm *stmgr.StateManager, ds dtypes.MetadataDS) *Recorder {
+	r := &Recorder{
+		sm: sm,
+		cs: sm.ChainStore(),
+		ds: ds,
+	}
+
+	ctx := helpers.LifecycleCtx(mctx, lc)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go r.run(ctx)
+			return nil
+		},
+	})
+
+	return r
+}
+
+func (r *Recorder) run(ctx context.Context) {
+	notifs := r.cs.SubHeadChanges(ctx)
+
+	for {
+		select {
+		case changes, ok := <-notifs:
+			if !ok {
+				log.Warn("head change subscription closed")
+				return
+			}
+
+			for _, hc := range changes {
+				if hc.Type == store.HCRevert {
+					continue
+				}
+
+				if err := r.record(ctx, hc.Val); err != nil {
+					log.Warnw("recording network stats", "height", hc.Val.Height(), "error", err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// record stores the stats of the parent of the given tipset. Stats of a tipset
+// are only known once its messages are executed, which happens in the child
+// tipset.
+func (r *Recorder) record(ctx context.Context, ts *types.TipSet) error {
+	if ts.Height() == 0 {
+		return nil
+	}
+
+	pts, err := r.cs.LoadTipSet(ts.Parents())
+	if err != nil {
+		return xerrors.Errorf("loading parent tipset: %w", err)
+	}
+
+	st, err := r.compute(ctx, pts, ts)
+	if err != nil {
+		return err
+	}
+
+	if err := r.put(st); err != nil {
+		return err
+	}
+
+	// clear any stats recorded on a different fork at heights which are null
+	// rounds in this chain
+	for h := pts.Height() + 1; h < ts.Height(); h++ {
+		if err := r.ds.Delete(statsKey(h)); err != nil {
+			return xerrors.Errorf("deleting null round stats: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Recorder) compute(ctx context.Context, ts, child *types.TipSet) (*epochStats, error) {
+	msgs, err := r.cs.MessagesForTipset(ts)
+	if err != nil {
+		return nil, xerrors.Errorf("loading messages: %w", err)
+	}
+
+	st := &epochStats{
+		Height:    ts.Height(),
+		Timestamp: ts.MinTimestamp(),
+		BaseFee:   ts.Blocks()[0].ParentBaseFee,
+		Messages:  uint64(len(msgs)),
+	}
+
+	for _, m := range msgs {
+		st.GasLimit += m.VMMessage().GasLimit
+	}
+
+	// block headers use adt0
+	receipts, err := blockadt.AsArray(r.cs.Store(ctx), child.Blocks()[0].ParentMessageReceipts)
+	if err != nil {
+		return nil, xerrors.Errorf("loading receipts: %w", err)
+	}
+
+	var rcpt types.MessageReceipt
+	if err := receipts.ForEach(&rcpt, func(int64) error {
+		st.GasUsed += rcpt.GasUsed
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("iterating receipts: %w", err)
+	}
+
+	// the child's parent state is the state after executing the tipset
+	act, err := r.sm.LoadActor(ctx, power.Address, child)
+	if err != nil {
+		return nil, xerrors.Errorf("loading power actor: %w", err)
+	}
+
+	pst, err := power.Load(r.cs.Store(ctx), act)
+	if err != nil {
+		return nil, xerrors.Errorf("loading power actor state: %w", err)
+	}
+
+	claim, err := pst.TotalPower()
+	if err != nil {
+		return nil, xerrors.Errorf("getting total power: %w", err)
+	}
+
+	st.NetworkQAP = claim.QualityAdjPower
+	st.NetworkRawPower = claim.RawBytePower
+
+	return st, nil
+}
+
+// Stats returns the network statistics of tipsets in the [from, to] height
+// range of the current chain. Null rounds are omitted. Stats of the head
+// tipset aren't known yet, so the range ends at most at the parent of the head.
+func (r *Recorder) Stats(ctx context.Context, from, to abi.ChainEpoch) (*api.NetworkStats, error) {
+	head := r.cs.GetHeaviestTipSet()
+	if to >= head.Height() {
+		to = head.Height() - 1
+	}
+	if from < 0 {
+		from = 0
+	}
+	if from > to {
+		return nil, xerrors.Errorf("invalid epoch range: from %d, to %d (head %d)", from, to, head.Height())
+	}
+	if to-from+1 > MaxRange {
+		return nil, xerrors.Errorf("epoch range too large: %d epochs, max %d", to-from+1, MaxRange)
+	}
+
+	out := &api.NetworkStats{}
+
+	for h := from; h <= to; h++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		st, err := r.get(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if st == nil {
+			// not recorded yet, e.g. for epochs synced before the node
+			// started recording stats
+			child, err := r.cs.GetTipsetByHeight(ctx, h+1, head, false)
+			if err != nil {
+				return nil, xerrors.Errorf("loading tipset at height %d: %w", h+1, err)
+			}
+
+			if err := r.record(ctx, child); err != nil {
+				return nil, xerrors.Errorf("computing stats at height %d: %w", h, err)
+			}
+
+			if st, err = r.get(h); err != nil {
+				return nil, err
+			}
+			if st == nil {
+				continue // null round
+			}
+		}
+
+		out.Heights = append(out.Heights, st.Height)
+		out.Timestamps = append(out.Timestamps, st.Timestamp)
+		out.BaseFee = append(out.BaseFee, st.BaseFee)
+		out.GasUsed = append(out.GasUsed, st.GasUsed)
+		out.GasLimit = append(out.GasLimit, st.GasLimit)
+		out.Messages = append(out.Messages, st.Messages)
+		out.NetworkQAP = append(out.NetworkQAP, st.NetworkQAP)
+		out.NetworkRawPower = append(out.NetworkRawPower, st.NetworkRawPower)
+	}
+
+	return out, nil
+}
+
+func (r *Recorder) put(st *epochStats) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return xerrors.Errorf("marshaling stats: %w", err)
+	}
+
+	if err := r.ds.Put(statsKey(st.Height), b); err != nil {
+		return xerrors.Errorf("storing stats: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Recorder) get(h abi.ChainEpoch) (*epochStats, error) {
+	b, err := r.ds.Get(statsKey(h))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("loading stats at height %d: %w", h, err)
+	}
+
+	st := &epochStats{
+		BaseFee:         big.Zero(),
+		NetworkQAP:      big.Zero(),
+		NetworkRawPower: big.Zero(),
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, xerrors.Errorf("unmarshaling stats at height %d: %w", h, err)
+	}
+
+	return st, nil
+}
+
+func statsKey(h abi.ChainEpoch) datastore.Key {
+	return dsPrefix.ChildString(fmt.Sprint(h))
+}
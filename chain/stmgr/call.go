@@ -13,6 +13,7 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/state"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
@@ -24,6 +25,57 @@ func (sm *StateManager) Call(ctx context.Context, msg *types.Message, ts *types.
 	ctx, span := trace.StartSpan(ctx, "statemanager.Call")
 	defer span.End()
 
+	vmi, err := sm.callVM(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.applyCall(ctx, span, vmi, msg)
+}
+
+// CallMany runs each of the messages on top of the state of the tipset, like
+// Call, but sets up the VM only once. Messages are run independently, so they
+// don't see state changes of each other. Messages which fail to apply have the
+// error set in their result.
+func (sm *StateManager) CallMany(ctx context.Context, msgs []*types.Message, ts *types.TipSet) ([]*api.InvocResult, error) {
+	ctx, span := trace.StartSpan(ctx, "statemanager.CallMany")
+	defer span.End()
+
+	vmi, err := sm.callVM(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	st := vmi.StateTree().(*state.StateTree)
+
+	out := make([]*api.InvocResult, len(msgs))
+	for i, msg := range msgs {
+		if err := st.Snapshot(ctx); err != nil {
+			return nil, xerrors.Errorf("state snapshot: %w", err)
+		}
+
+		res, err := sm.applyCall(ctx, span, vmi, msg)
+		if err != nil {
+			res = &api.InvocResult{
+				MsgCid: msg.Cid(),
+				Msg:    msg,
+				Error:  err.Error(),
+			}
+		}
+		out[i] = res
+
+		if err := st.Revert(); err != nil {
+			return nil, xerrors.Errorf("reverting state of message %d: %w", i, err)
+		}
+		st.ClearSnapshot()
+	}
+
+	return out, nil
+}
+
+// callVM sets up a VM on top of the parent state of the tipset, for running
+// calls
+func (sm *StateManager) callVM(ctx context.Context, ts *types.TipSet) (*vm.VM, error) {
 	// If no tipset is provided, try to find one without a fork.
 	if ts == nil {
 		ts = sm.cs.GetHeaviestTipSet()
@@ -72,6 +124,10 @@ func (sm *StateManager) Call(ctx context.Context, msg *types.Message, ts *types.
 		return nil, xerrors.Errorf("failed to set up vm: %w", err)
 	}
 
+	return vmi, nil
+}
+
+func (sm *StateManager) applyCall(ctx context.Context, span *trace.Span, vmi *vm.VM, msg *types.Message) (*api.InvocResult, error) {
 	if msg.GasLimit == 0 {
 		msg.GasLimit = build.BlockGasLimit
 	}
@@ -1209,6 +1209,29 @@ func (cs *ChainStore) GetParentReceipt(b *types.BlockHeader, i int) (*types.Mess
 	return &r, nil
 }
 
+// ReadReceipts loads all receipts from the receipts AMT with the given root
+func (cs *ChainStore) ReadReceipts(ctx context.Context, root cid.Cid) ([]types.MessageReceipt, error) {
+	a, err := blockadt.AsArray(cs.Store(ctx), root)
+	if err != nil {
+		return nil, xerrors.Errorf("amt load: %w", err)
+	}
+
+	out := make([]types.MessageReceipt, 0, a.Length())
+	var r types.MessageReceipt
+	err = a.ForEach(&r, func(i int64) error {
+		if int64(len(out)) != i {
+			return xerrors.Errorf("missing receipt %d", len(out))
+		}
+		out = append(out, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
 func (cs *ChainStore) LoadMessagesFromCids(cids []cid.Cid) ([]*types.Message, error) {
 	msgs := make([]*types.Message, 0, len(cids))
 	for i, c := range cids {
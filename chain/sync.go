@@ -239,11 +239,14 @@ func (syncer *Syncer) InformNewHead(from peer.ID, fts *store.FullTipSet) bool {
 
 	for _, b := range fts.Blocks {
 		if reason, ok := syncer.bad.Has(b.Cid()); ok {
+			// blocks can be marked as bad locally, e.g. with SyncMarkBad, so
+			// the peer relaying it isn't penalized
 			log.Warnf("InformNewHead called on block marked as bad: %s (reason: %s)", b.Cid(), reason)
 			return false
 		}
 		if err := syncer.ValidateMsgMeta(b); err != nil {
 			log.Warnf("invalid block received: %s", err)
+			syncer.Exchange.LogBadBlock(from)
 			return false
 		}
 	}
@@ -1004,7 +1007,7 @@ func (syncer *Syncer) VerifyWinningPoStProof(ctx context.Context, nv network.Ver
 		return xerrors.Errorf("getting winning post sector set: %w", err)
 	}
 
-	ok, err := ffiwrapper.ProofVerifier.VerifyWinningPoSt(ctx, proof2.WinningPoStVerifyInfo{
+	ok, err := syncer.verifier.VerifyWinningPoSt(ctx, proof2.WinningPoStVerifyInfo{
 		Randomness:        rand,
 		Proofs:            h.WinPoStProof,
 		ChallengedSectors: sectors,
@@ -13,6 +13,10 @@ type MpoolConfig struct {
 	ReplaceByFeeRatio      float64
 	PruneCooldown          time.Duration
 	GasLimitOverestimation float64
+
+	// also select messages from addresses which published messages through
+	// this node first, like messages from PriorityAddrs
+	PriorityLocal bool
 }
 
 func (mc *MpoolConfig) Clone() *MpoolConfig {
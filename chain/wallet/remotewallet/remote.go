@@ -31,6 +31,14 @@ func SetupRemoteWallet(info string) func(mctx helpers.MetricsCtx, lc fx.Lifecycl
 		}
 
 		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				// fail early on a bad api token, instead of on the first
+				// message the node needs to sign
+				if _, err := wapi.WalletList(ctx); err != nil {
+					return xerrors.Errorf("checking remote wallet access: %w", err)
+				}
+				return nil
+			},
 			OnStop: func(ctx context.Context) error {
 				closer()
 				return nil
@@ -27,7 +27,7 @@ var authCreateAdminToken = &cli.Command{
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "perm",
-			Usage: "permission to assign to the token, one of: read, write, sign, admin",
+			Usage: "permission to assign to the token, one of: read, write, sign, worker, admin",
 		},
 	},
 
@@ -75,7 +75,7 @@ var authApiInfoToken = &cli.Command{
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "perm",
-			Usage: "permission to assign to the token, one of: read, write, sign, admin",
+			Usage: "permission to assign to the token, one of: read, write, sign, worker, admin",
 		},
 	},
 
@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
@@ -16,6 +17,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DataDog/zstd"
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -53,6 +55,9 @@ var chainCmd = &cli.Command{
 		chainGetCmd,
 		chainBisectCmd,
 		chainExportCmd,
+		chainVerifySnapshotCmd,
+		chainPruneCmd,
+		chainGCBlockstoreCmd,
 		slashConsensusFault,
 		chainGasPriceCmd,
 		chainInspectUsage,
@@ -1027,7 +1032,7 @@ var chainBisectCmd = &cli.Command{
 var chainExportCmd = &cli.Command{
 	Name:      "export",
 	Usage:     "export chain to a car file",
-	ArgsUsage: "[outputPath]",
+	ArgsUsage: "[outputPath, - for stdout]",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name: "tipset",
@@ -1039,6 +1044,11 @@ var chainExportCmd = &cli.Command{
 		&cli.BoolFlag{
 			Name: "skip-old-msgs",
 		},
+		&cli.StringFlag{
+			Name:  "compress",
+			Usage: "compress the export: none, zstd",
+			Value: "none",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
@@ -1057,16 +1067,25 @@ var chainExportCmd = &cli.Command{
 			return fmt.Errorf("\"recent-stateroots\" has to be greater than %d", build.Finality)
 		}
 
-		fi, err := os.Create(cctx.Args().First())
-		if err != nil {
-			return err
+		compress := cctx.String("compress")
+		if compress != "none" && compress != "zstd" {
+			return xerrors.Errorf("unknown compression %q, expected none or zstd", compress)
 		}
-		defer func() {
-			err := fi.Close()
+
+		var out io.Writer = os.Stdout
+		if cctx.Args().First() != "-" {
+			fi, err := os.Create(cctx.Args().First())
 			if err != nil {
-				fmt.Printf("error closing output file: %+v", err)
+				return err
 			}
-		}()
+			defer func() {
+				err := fi.Close()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error closing output file: %+v", err)
+				}
+			}()
+			out = fi
+		}
 
 		ts, err := LoadTipSet(ctx, cctx, api)
 		if err != nil {
@@ -1084,11 +1103,18 @@ var chainExportCmd = &cli.Command{
 			return err
 		}
 
+		w := out
+		var zw *zstd.Writer
+		if compress == "zstd" {
+			zw = zstd.NewWriter(out)
+			w = zw
+		}
+
 		var last bool
 		for b := range stream {
 			last = len(b) == 0
 
-			_, err := fi.Write(b)
+			_, err := w.Write(b)
 			if err != nil {
 				return err
 			}
@@ -1098,6 +1124,63 @@ var chainExportCmd = &cli.Command{
 			return xerrors.Errorf("incomplete export (remote connection lost?)")
 		}
 
+		if zw != nil {
+			if err := zw.Close(); err != nil {
+				return xerrors.Errorf("finishing compressed export: %w", err)
+			}
+		}
+
+		return nil
+	},
+}
+
+var chainPruneCmd = &cli.Command{
+	Name:  "prune",
+	Usage: "Move old chain data out of the hot store of the splitstore",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if err := api.ChainPrune(ctx); err != nil {
+			return err
+		}
+
+		fmt.Println("Compacted the hot store")
+		return nil
+	},
+}
+
+var chainGCBlockstoreCmd = &cli.Command{
+	Name:  "gc-blockstore",
+	Usage: "Reclaim the disk space of objects deleted from the splitstore hot store",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		stats, err := api.ChainGCBlockstore(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range stats {
+			var reclaimed int64
+			if s.SizeAfter < s.SizeBefore {
+				reclaimed = s.SizeBefore - s.SizeAfter
+			}
+
+			fmt.Printf("%s: %s -> %s (reclaimed %s)\n", s.Store,
+				types.SizeStr(types.NewInt(uint64(s.SizeBefore))),
+				types.SizeStr(types.NewInt(uint64(s.SizeAfter))),
+				types.SizeStr(types.NewInt(uint64(reclaimed))))
+		}
 		return nil
 	},
 }
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car"
+	"github.com/mitchellh/go-homedir"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/journal"
+	badgerbs "github.com/filecoin-project/lotus/lib/blockstore/badger"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var chainVerifySnapshotCmd = &cli.Command{
+	Name:      "verify-snapshot",
+	Usage:     "verify a chain snapshot before importing it",
+	ArgsUsage: "[snapshotPath]",
+	Description: `Loads the snapshot into a temporary blockstore, checking that every block
+   matches its CID, walks the block headers from the head back to genesis, and
+   recomputes the state of the most recent tipsets, checking it against the
+   state roots and receipts the following blocks commit to.
+
+   The snapshot must contain state for all recomputed epochs; snapshots made
+   with 'lotus chain export --recent-stateroots' contain the given number of
+   recent state roots.`,
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "epochs",
+			Usage: "number of most recent epochs to recompute state for",
+			Value: 10,
+		},
+		&cli.StringFlag{
+			Name:  "tmp-dir",
+			Usage: "directory to create the temporary blockstore in (needs space for the whole snapshot)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must specify snapshot file to verify")
+		}
+
+		fname, err := homedir.Expand(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		fi, err := os.Open(fname)
+		if err != nil {
+			return err
+		}
+		defer fi.Close() //nolint:errcheck
+
+		tdir, err := ioutil.TempDir(cctx.String("tmp-dir"), "lotus-verify-snapshot")
+		if err != nil {
+			return xerrors.Errorf("creating temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tdir) //nolint:errcheck
+
+		opts, err := repo.BadgerBlockstoreOptions(repo.BlockstoreChain, tdir, false)
+		if err != nil {
+			return err
+		}
+		opts.SyncWrites = false
+
+		bs, err := badgerbs.Open(opts)
+		if err != nil {
+			return xerrors.Errorf("opening temporary blockstore: %w", err)
+		}
+		defer bs.Close() //nolint:errcheck
+
+		fmt.Printf("Loading %s...\n", fname)
+
+		roots, nblocks, err := loadVerifiedCar(bs, bufio.NewReaderSize(fi, 1<<20))
+		if err != nil {
+			return xerrors.Errorf("snapshot integrity check failed: %w", err)
+		}
+
+		fmt.Printf("Loaded %d blocks, all match their CIDs\n", nblocks)
+
+		mds := dssync.MutexWrap(datastore.NewMapDatastore())
+		cs := store.NewChainStore(bs, bs, mds, vm.Syscalls(ffiwrapper.ProofVerifier), journal.NilJournal())
+		defer cs.Close() //nolint:errcheck
+
+		head, err := cs.LoadTipSet(types.NewTipSetKey(roots...))
+		if err != nil {
+			return xerrors.Errorf("loading head tipset from snapshot roots: %w", err)
+		}
+
+		fmt.Println("Checking chain linkage...")
+
+		tschain := []*types.TipSet{head}
+		ts := head
+		for ts.Height() > 0 {
+			pts, err := cs.LoadTipSet(ts.Parents())
+			if err != nil {
+				return xerrors.Errorf("loading parent of tipset at height %d: %w", ts.Height(), err)
+			}
+			if pts.Height() >= ts.Height() {
+				return xerrors.Errorf("tipset at height %d has parent at height %d", ts.Height(), pts.Height())
+			}
+			if ts.Height() >= head.Height()-abi.ChainEpoch(cctx.Int64("epochs")) {
+				tschain = append(tschain, pts)
+			}
+			ts = pts
+		}
+		gen := ts
+
+		fmt.Printf("Header chain links back to genesis %s\n", gen.Cids()[0])
+
+		if gb := build.MaybeGenesis(); gb != nil {
+			gh, err := car.LoadCar(bs, bytes.NewReader(gb))
+			if err != nil {
+				return xerrors.Errorf("loading network genesis: %w", err)
+			}
+			if len(gh.Roots) != 1 || gh.Roots[0] != gen.Cids()[0] {
+				return xerrors.Errorf("snapshot genesis %s doesn't match network genesis %s", gen.Cids()[0], gh.Roots)
+			}
+		} else {
+			log.Warn("no genesis built into this binary, state recomputation may fail")
+		}
+
+		if err := cs.SetGenesis(gen.Blocks()[0]); err != nil {
+			return xerrors.Errorf("setting genesis: %w", err)
+		}
+
+		sm := stmgr.NewStateManager(cs)
+
+		// tschain is newest first; recompute from the oldest tipset which
+		// still has a child to check against
+		for i := len(tschain) - 1; i > 0; i-- {
+			cur, child := tschain[i], tschain[i-1]
+
+			fmt.Printf("Recomputing state at height %d...\n", cur.Height())
+
+			st, rec, err := sm.TipSetState(ctx, cur)
+			if err != nil {
+				return xerrors.Errorf("computing state at height %d: %w", cur.Height(), err)
+			}
+
+			if st != child.ParentState() {
+				return xerrors.Errorf("state mismatch at height %d: computed %s, block at height %d has %s", cur.Height(), st, child.Height(), child.ParentState())
+			}
+			if rec != child.Blocks()[0].ParentMessageReceipts {
+				return xerrors.Errorf("receipts mismatch at height %d: computed %s, block at height %d has %s", cur.Height(), rec, child.Height(), child.Blocks()[0].ParentMessageReceipts)
+			}
+		}
+
+		fmt.Println("Snapshot OK")
+		fmt.Printf("Head: %d: %s\n", head.Height(), head.Cids())
+		fmt.Printf("Head parent state root: %s\n", head.ParentState())
+
+		return nil
+	},
+}
+
+// loadVerifiedCar is like car.LoadCar, but checks that each block hashes to
+// its CID, so corrupt snapshots are caught before their blocks are used
+func loadVerifiedCar(bs *badgerbs.Blockstore, r io.Reader) ([]cid.Cid, int, error) {
+	cr, err := car.NewCarReader(r)
+	if err != nil {
+		return nil, 0, xerrors.Errorf("reading car header: %w", err)
+	}
+
+	var (
+		n     int
+		batch []blocks.Block
+	)
+	for {
+		blk, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, xerrors.Errorf("reading block %d: %w", n, err)
+		}
+
+		c, err := blk.Cid().Prefix().Sum(blk.RawData())
+		if err != nil {
+			return nil, 0, xerrors.Errorf("hashing block %s: %w", blk.Cid(), err)
+		}
+		if !c.Equals(blk.Cid()) {
+			return nil, 0, xerrors.Errorf("block %s has wrong data (hashes to %s)", blk.Cid(), c)
+		}
+
+		batch = append(batch, blk)
+		n++
+
+		if len(batch) >= 1000 {
+			if err := bs.PutMany(batch); err != nil {
+				return nil, 0, xerrors.Errorf("storing blocks: %w", err)
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := bs.PutMany(batch); err != nil {
+			return nil, 0, xerrors.Errorf("storing blocks: %w", err)
+		}
+	}
+
+	return cr.Header.Roots, n, nil
+}
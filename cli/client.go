@@ -83,6 +83,8 @@ var clientCmd = &cli.Command{
 		WithCategory("storage", clientGetDealCmd),
 		WithCategory("storage", clientListAsksCmd),
 		WithCategory("storage", clientDealStatsCmd),
+		WithCategory("storage", clientProviderScoresCmd),
+		WithCategory("storage", clientAllowanceCmd),
 		WithCategory("data", clientImportCmd),
 		WithCategory("data", clientDropCmd),
 		WithCategory("data", clientLocalCmd),
@@ -972,6 +974,25 @@ var clientRetrieveCmd = &cli.Command{
 			Name:  "pieceCid",
 			Usage: "require data to be retrieved from a specific Piece CID",
 		},
+		&cli.StringFlag{
+			Name:        "payment-interval",
+			Usage:       "number of bytes to receive between payments; must not exceed the provider's offer",
+			DefaultText: "provider's offer",
+		},
+		&cli.StringFlag{
+			Name:        "payment-interval-increase",
+			Usage:       "number of bytes the payment interval grows by after each payment; must not exceed the provider's offer",
+			DefaultText: "provider's offer",
+		},
+		&cli.StringFlag{
+			Name:  "transport",
+			Usage: "transport to retrieve the data over: graphsync, http (paid upfront, if the provider offers it), or auto for http when offered and graphsync otherwise",
+			Value: "graphsync",
+		},
+		&cli.StringFlag{
+			Name:  "http-token",
+			Usage: "token issued by the provider for retrievals over http (lotus-miner retrieval-deals http-token)",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.NArg() != 2 {
@@ -1080,11 +1101,38 @@ var clientRetrieveCmd = &cli.Command{
 			return xerrors.Errorf("failed to find offer satisfying maxPrice: %s", maxPrice)
 		}
 
+		order := offer.Order(payer)
+
+		if cctx.IsSet("payment-interval") {
+			v, err := units.RAMInBytes(cctx.String("payment-interval"))
+			if err != nil {
+				return xerrors.Errorf("parsing payment-interval: %w", err)
+			}
+			if v <= 0 || uint64(v) > offer.PaymentInterval {
+				return xerrors.Errorf("payment interval must be between 1B and %s (offered by the provider)", units.BytesSize(float64(offer.PaymentInterval)))
+			}
+			order.PaymentInterval = uint64(v)
+		}
+
+		if cctx.IsSet("payment-interval-increase") {
+			v, err := units.RAMInBytes(cctx.String("payment-interval-increase"))
+			if err != nil {
+				return xerrors.Errorf("parsing payment-interval-increase: %w", err)
+			}
+			if v < 0 || uint64(v) > offer.PaymentIntervalIncrease {
+				return xerrors.Errorf("payment interval increase must not exceed %s (offered by the provider)", units.BytesSize(float64(offer.PaymentIntervalIncrease)))
+			}
+			order.PaymentIntervalIncrease = uint64(v)
+		}
+
+		order.Transport = cctx.String("transport")
+		order.HTTPToken = cctx.String("http-token")
+
 		ref := &lapi.FileRef{
 			Path:  cctx.Args().Get(1),
 			IsCAR: cctx.Bool("car"),
 		}
-		updates, err := fapi.ClientRetrieveWithEvents(ctx, offer.Order(payer), ref)
+		updates, err := fapi.ClientRetrieveWithEvents(ctx, order, ref)
 		if err != nil {
 			return xerrors.Errorf("error setting up retrieval: %w", err)
 		}
@@ -1195,6 +1243,10 @@ var clientListAsksCmd = &cli.Command{
 		&cli.BoolFlag{
 			Name: "by-ping",
 		},
+		&cli.BoolFlag{
+			Name:  "by-score",
+			Usage: "rank miners by the success of past deals and retrievals with them",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
@@ -1215,15 +1267,48 @@ var clientListAsksCmd = &cli.Command{
 			})
 		}
 
+		// scores are only loaded, and shown, when ranking by them
+		var scores map[address.Address]lapi.ProviderScore
+		if cctx.Bool("by-score") {
+			scoreList, err := api.ClientProviderScores(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting provider scores: %w", err)
+			}
+			scores = map[address.Address]lapi.ProviderScore{}
+			for _, s := range scoreList {
+				scores[s.Provider] = s
+			}
+			scoreOf := func(a QueriedAsk) float64 {
+				if s, ok := scores[a.Ask.Miner]; ok {
+					return s.Score
+				}
+				return lapi.NeutralProviderScore
+			}
+
+			// asks are ordered by price (or ping), which breaks ties
+			sort.SliceStable(asks, func(i, j int) bool {
+				return scoreOf(asks[i]) > scoreOf(asks[j])
+			})
+		}
+
 		for _, a := range asks {
 			ask := a.Ask
 
-			fmt.Printf("%s: min:%s max:%s price:%s/GiB/Epoch verifiedPrice:%s/GiB/Epoch ping:%s\n", ask.Miner,
+			score := ""
+			if scores != nil {
+				score = " score:-"
+				if s, ok := scores[ask.Miner]; ok {
+					score = fmt.Sprintf(" score:%.2f (%d deals, %d retrievals)", s.Score, s.Deals, s.Retrievals)
+				}
+			}
+
+			fmt.Printf("%s: min:%s max:%s price:%s/GiB/Epoch verifiedPrice:%s/GiB/Epoch ping:%s%s\n", ask.Miner,
 				types.SizeStr(types.NewInt(uint64(ask.MinPieceSize))),
 				types.SizeStr(types.NewInt(uint64(ask.MaxPieceSize))),
 				types.FIL(ask.Price),
 				types.FIL(ask.VerifiedPrice),
 				a.Ping,
+				score,
 			)
 		}
 
@@ -1231,6 +1316,62 @@ var clientListAsksCmd = &cli.Command{
 	},
 }
 
+var clientProviderScoresCmd = &cli.Command{
+	Name:  "provider-scores",
+	Usage: "Score miners by the success of past deals and retrievals with them",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		scores, err := api.ClientProviderScores(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tablewriter.New(tablewriter.Col("Provider"),
+			tablewriter.Col("Score"),
+			tablewriter.Col("Deals"),
+			tablewriter.Col("Rejected"),
+			tablewriter.Col("Failed"),
+			tablewriter.Col("Active"),
+			tablewriter.Col("TimeToActivation"),
+			tablewriter.Col("Retrievals"),
+			tablewriter.Col("FailedRetrievals"),
+			tablewriter.Col("RetrievalSpeed"))
+
+		for _, s := range scores {
+			activation := "-"
+			if s.AvgTimeToActivation > 0 {
+				activation = s.AvgTimeToActivation.Truncate(time.Minute).String()
+			}
+
+			speed := "-"
+			if s.AvgRetrievalSpeed > 0 {
+				speed = types.SizeStr(types.NewInt(s.AvgRetrievalSpeed)) + "/s"
+			}
+
+			w.Write(map[string]interface{}{
+				"Provider":         s.Provider,
+				"Score":            fmt.Sprintf("%.2f", s.Score),
+				"Deals":            s.Deals,
+				"Rejected":         s.RejectedDeals,
+				"Failed":           s.FailedDeals,
+				"Active":           s.ActiveDeals,
+				"TimeToActivation": activation,
+				"Retrievals":       s.Retrievals,
+				"FailedRetrievals": s.FailedRetrievals,
+				"RetrievalSpeed":   speed,
+			})
+		}
+
+		return w.Flush(os.Stdout)
+	},
+}
+
 type QueriedAsk struct {
 	Ask  *storagemarket.StorageAsk
 	Ping time.Duration
@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/filecoin-project/lotus/markets/delegation"
+)
+
+var clientAllowanceCmd = &cli.Command{
+	Name:  "allowance",
+	Usage: "Manage deal allowances of delegate keys",
+	Description: `Deal allowances let a delegate key propose deals paid for by a client wallet,
+up to a total deal price, without access to the wallet key or an admin API
+token. The delegate proposes deals with 'lotus client allowance deal', which
+only requires a write API token.`,
+	Subcommands: []*cli.Command{
+		clientAllowanceCreateCmd,
+		clientAllowanceListCmd,
+		clientAllowanceRevokeCmd,
+		clientAllowanceDealCmd,
+	},
+}
+
+var clientAllowanceCreateCmd = &cli.Command{
+	Name:      "create",
+	Usage:     "Authorize a delegate key to propose deals from a client wallet",
+	ArgsUsage: "[client delegate amount]",
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "expire-after",
+			Usage: "number of epochs the allowance is valid for",
+			Value: 30 * builtin.EpochsInDay,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 3 {
+			return ShowHelp(cctx, fmt.Errorf("expected 3 args: client, delegate, amount"))
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		client, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return xerrors.Errorf("parsing client address: %w", err)
+		}
+
+		delegate, err := address.NewFromString(cctx.Args().Get(1))
+		if err != nil {
+			return xerrors.Errorf("parsing delegate address: %w", err)
+		}
+
+		amount, err := types.ParseFIL(cctx.Args().Get(2))
+		if err != nil {
+			return xerrors.Errorf("parsing amount: %w", err)
+		}
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		sa, err := api.ClientCreateDealAllowance(ctx, client, delegate, types.BigInt(amount), head.Height()+abi.ChainEpoch(cctx.Int64("expire-after")))
+		if err != nil {
+			return err
+		}
+
+		out, err := json.MarshalIndent(sa, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var clientAllowanceListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List deal allowances",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		allowances, err := api.ClientListDealAllowances(ctx)
+		if err != nil {
+			return err
+		}
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("ID"),
+			tablewriter.Col("Client"),
+			tablewriter.Col("Delegate"),
+			tablewriter.Col("Amount"),
+			tablewriter.Col("Spent"),
+			tablewriter.Col("Expiration"),
+			tablewriter.Col("Status"))
+
+		for _, a := range allowances {
+			status := "active"
+			switch {
+			case a.Revoked:
+				status = "revoked"
+			case a.Allowance.Expiration <= head.Height():
+				status = "expired"
+			}
+
+			tw.Write(map[string]interface{}{
+				"ID":         a.Allowance.ID,
+				"Client":     a.Allowance.Client,
+				"Delegate":   a.Allowance.Delegate,
+				"Amount":     types.FIL(a.Allowance.Amount),
+				"Spent":      types.FIL(a.Spent),
+				"Expiration": EpochTime(head.Height(), a.Allowance.Expiration),
+				"Status":     status,
+			})
+		}
+
+		return tw.Flush(os.Stdout)
+	},
+}
+
+var clientAllowanceRevokeCmd = &cli.Command{
+	Name:      "revoke",
+	Usage:     "Revoke a deal allowance",
+	ArgsUsage: "[id]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("expected 1 arg: allowance id"))
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("parsing allowance id: %w", err)
+		}
+
+		return api.ClientRevokeDealAllowance(ctx, id)
+	},
+}
+
+var clientAllowanceDealCmd = &cli.Command{
+	Name:      "deal",
+	Usage:     "Propose a storage deal under a deal allowance, signed by the delegate key",
+	ArgsUsage: "[dataCid miner price duration]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "allowance",
+			Usage:    "path to the signed allowance, as printed by 'lotus client allowance create'",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "key",
+			Usage:    "path to the delegate private key, in the 'lotus wallet export' format",
+			Required: true,
+		},
+		&cli.Int64Flag{
+			Name:  "start-epoch",
+			Usage: "specify the epoch that the deal should start at",
+			Value: -1,
+		},
+		&cli.BoolFlag{
+			Name:  "fast-retrieval",
+			Usage: "indicates that data should be available for fast retrieval",
+			Value: true,
+		},
+		&cli.BoolFlag{
+			Name:  "verified-deal",
+			Usage: "indicate that the deal counts towards verified client total",
+		},
+		&cli.StringFlag{
+			Name:  "provider-collateral",
+			Usage: "specify the requested provider collateral the miner should put up",
+		},
+		&CidBaseFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 4 {
+			return ShowHelp(cctx, fmt.Errorf("expected 4 args: dataCid, miner, price, duration"))
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		ab, err := ioutil.ReadFile(cctx.String("allowance"))
+		if err != nil {
+			return xerrors.Errorf("reading allowance: %w", err)
+		}
+
+		var sa lapi.SignedDealAllowance
+		if err := json.Unmarshal(ab, &sa); err != nil {
+			return xerrors.Errorf("parsing allowance: %w", err)
+		}
+
+		key, err := readDelegateKey(cctx.String("key"))
+		if err != nil {
+			return err
+		}
+
+		if key.Address != sa.Allowance.Delegate {
+			return xerrors.Errorf("key %s is not the allowance delegate %s", key.Address, sa.Allowance.Delegate)
+		}
+
+		data, err := cid.Parse(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		miner, err := address.NewFromString(cctx.Args().Get(1))
+		if err != nil {
+			return err
+		}
+
+		price, err := types.ParseFIL(cctx.Args().Get(2))
+		if err != nil {
+			return err
+		}
+
+		dur, err := strconv.ParseInt(cctx.Args().Get(3), 10, 32)
+		if err != nil {
+			return err
+		}
+
+		if abi.ChainEpoch(dur) < build.MinDealDuration {
+			return xerrors.Errorf("minimum deal duration is %d blocks", build.MinDealDuration)
+		}
+
+		provCol := big.Zero()
+		if pcs := cctx.String("provider-collateral"); pcs != "" {
+			provCol, err = big.FromString(pcs)
+			if err != nil {
+				return xerrors.Errorf("failed to parse provider-collateral: %w", err)
+			}
+		}
+
+		req := &lapi.DelegatedDealRequest{
+			Allowance: sa,
+			Params: lapi.StartDealParams{
+				Data: &storagemarket.DataRef{
+					TransferType: storagemarket.TTGraphsync,
+					Root:         data,
+				},
+				Wallet:             sa.Allowance.Client,
+				Miner:              miner,
+				EpochPrice:         types.BigInt(price),
+				MinBlocksDuration:  uint64(dur),
+				DealStartEpoch:     abi.ChainEpoch(cctx.Int64("start-epoch")),
+				FastRetrieval:      cctx.Bool("fast-retrieval"),
+				VerifiedDeal:       cctx.Bool("verified-deal"),
+				ProviderCollateral: provCol,
+			},
+			Nonce: uint64(time.Now().UnixNano()),
+		}
+
+		rb, err := delegation.RequestSigningBytes(req)
+		if err != nil {
+			return err
+		}
+
+		req.Signature, err = sigs.Sign(wallet.ActSigType(key.Type), key.PrivateKey, rb)
+		if err != nil {
+			return xerrors.Errorf("signing request: %w", err)
+		}
+
+		proposal, err := api.ClientStartDelegatedDeal(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		encoder, err := GetCidEncoder(cctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(encoder.Encode(*proposal))
+		return nil
+	},
+}
+
+func readDelegateKey(path string) (*wallet.Key, error) {
+	kb, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("reading key: %w", err)
+	}
+
+	data, err := hex.DecodeString(strings.TrimSpace(string(kb)))
+	if err != nil {
+		return nil, xerrors.Errorf("decoding key: %w", err)
+	}
+
+	var ki types.KeyInfo
+	if err := json.Unmarshal(data, &ki); err != nil {
+		return nil, xerrors.Errorf("parsing key: %w", err)
+	}
+
+	return wallet.NewKey(ki)
+}
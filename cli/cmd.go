@@ -301,6 +301,7 @@ var CommonCommands = []*cli.Command{
 	waitApiCmd,
 	fetchParamCmd,
 	pprofCmd,
+	subsystemCmd,
 	VersionCmd,
 }
 
@@ -312,11 +313,14 @@ var Commands = []*cli.Command{
 	WithCategory("basic", paychCmd),
 	WithCategory("developer", authCmd),
 	WithCategory("developer", mpoolCmd),
+	WithCategory("developer", msgCmd),
+	WithCategory("developer", scheduleCmd),
 	WithCategory("developer", stateCmd),
 	WithCategory("developer", chainCmd),
 	WithCategory("developer", logCmd),
 	WithCategory("developer", waitApiCmd),
 	WithCategory("developer", fetchParamCmd),
+	WithCategory("developer", subsystemCmd),
 	WithCategory("network", netCmd),
 	WithCategory("network", syncCmd),
 	pprofCmd,
@@ -34,6 +34,7 @@ var mpoolCmd = &cli.Command{
 		mpoolFindCmd,
 		mpoolConfig,
 		mpoolGasPerfCmd,
+		mpoolPublishFile,
 	},
 }
 
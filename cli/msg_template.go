@@ -0,0 +1,538 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+const msgTemplateDir = "msg-templates"
+
+var msgTemplateName = regexp.MustCompile(`^[a-zA-Z0-9_\-.]+$`)
+
+// msgTemplateNum matches params strings which expand to a JSON number
+var msgTemplateNum = regexp.MustCompile(`^\{\{\s*num\s+\.[a-zA-Z0-9_]+\s*\}\}$`)
+
+// jsonNumber matches numbers in JSON syntax, which unlike strconv.ParseFloat
+// doesn't allow NaN, Inf, hex or underscores
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+var msgTemplateFuncs = template.FuncMap{
+	// num checks that an argument is a finite JSON number; params strings
+	// consisting of only a num expansion are replaced by the number
+	"num": func(s string) (string, error) {
+		if !jsonNumber.MatchString(s) {
+			return "", xerrors.Errorf("argument %q is not a number", s)
+		}
+		// out of range numbers parse to Inf
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "", xerrors.Errorf("argument %q is not a finite number", s)
+		}
+		return s, nil
+	},
+}
+
+// MsgTemplate is a parameterized message stored in the node repo. To, Value
+// and Params are text/template strings expanded with the template's Args
+type MsgTemplate struct {
+	Name        string
+	Description string `json:",omitempty"`
+
+	// names of the arguments which must be given when instantiating the template
+	Args []string `json:",omitempty"`
+
+	To     string
+	Method abi.MethodNum
+	Value  string `json:",omitempty"` // FIL, defaults to 0
+
+	// JSON encoded method params, encoded to CBOR using the method's params
+	// type. Only the strings in the params are templates, so arguments can't
+	// change the structure of the params; a string of only {{num .name}}
+	// expands to a number.
+	Params string `json:",omitempty"`
+}
+
+// Instantiate expands the template with the given arguments
+func (t *MsgTemplate) Instantiate(args map[string]string) (to address.Address, value abi.TokenAmount, paramsJSON string, err error) {
+	for name := range args {
+		if !t.hasArg(name) {
+			return address.Undef, abi.TokenAmount{}, "", xerrors.Errorf("template %s has no argument %q", t.Name, name)
+		}
+	}
+	for _, name := range t.Args {
+		if _, ok := args[name]; !ok {
+			return address.Undef, abi.TokenAmount{}, "", xerrors.Errorf("missing template argument %q", name)
+		}
+	}
+
+	expand := func(field, s string) (string, error) {
+		tpl, err := template.New(field).Funcs(msgTemplateFuncs).Option("missingkey=error").Parse(s)
+		if err != nil {
+			return "", xerrors.Errorf("parsing %s template: %w", field, err)
+		}
+
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, args); err != nil {
+			return "", xerrors.Errorf("expanding %s template: %w", field, err)
+		}
+		return buf.String(), nil
+	}
+
+	tos, err := expand("to", t.To)
+	if err != nil {
+		return address.Undef, abi.TokenAmount{}, "", err
+	}
+	to, err = address.NewFromString(tos)
+	if err != nil {
+		return address.Undef, abi.TokenAmount{}, "", xerrors.Errorf("parsing target address %q: %w", tos, err)
+	}
+
+	value = abi.NewTokenAmount(0)
+	if t.Value != "" {
+		vs, err := expand("value", t.Value)
+		if err != nil {
+			return address.Undef, abi.TokenAmount{}, "", err
+		}
+		v, err := types.ParseFIL(vs)
+		if err != nil {
+			return address.Undef, abi.TokenAmount{}, "", xerrors.Errorf("parsing value %q: %w", vs, err)
+		}
+		value = abi.TokenAmount(v)
+	}
+
+	if t.Params != "" {
+		params, err := t.parseParams()
+		if err != nil {
+			return address.Undef, abi.TokenAmount{}, "", err
+		}
+
+		params, err = expandParams(params, func(s string) (string, error) {
+			return expand("params", s)
+		})
+		if err != nil {
+			return address.Undef, abi.TokenAmount{}, "", err
+		}
+
+		b, err := json.Marshal(params)
+		if err != nil {
+			return address.Undef, abi.TokenAmount{}, "", xerrors.Errorf("encoding params: %w", err)
+		}
+		paramsJSON = string(b)
+	}
+
+	return to, value, paramsJSON, nil
+}
+
+func (t *MsgTemplate) parseParams() (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(t.Params))
+	dec.UseNumber()
+
+	var params interface{}
+	if err := dec.Decode(&params); err != nil {
+		return nil, xerrors.Errorf("parsing params of template %s: %w", t.Name, err)
+	}
+	return params, nil
+}
+
+// expandParams expands the strings in decoded JSON params. Arguments only
+// end up in string values, or in numbers when checked with num, so they can't
+// add fields to the params.
+func expandParams(v interface{}, expand func(string) (string, error)) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		s, err := expand(v)
+		if err != nil {
+			return nil, err
+		}
+		if msgTemplateNum.MatchString(v) {
+			return json.Number(s), nil
+		}
+		return s, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			ev, err := expandParams(e, expand)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = ev
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			ev, err := expandParams(e, expand)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = ev
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func (t *MsgTemplate) hasArg(name string) bool {
+	for _, a := range t.Args {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+func msgTemplatePath(cctx *cli.Context, name string) (string, error) {
+	if !msgTemplateName.MatchString(name) {
+		return "", xerrors.Errorf("invalid template name %q", name)
+	}
+
+	rpath, err := homedir.Expand(cctx.String("repo"))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(rpath, msgTemplateDir, name+".json"), nil
+}
+
+func loadMsgTemplate(cctx *cli.Context, name string) (*MsgTemplate, error) {
+	p, err := msgTemplatePath(cctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, xerrors.Errorf("template %s not found", name)
+		}
+		return nil, err
+	}
+
+	var t MsgTemplate
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, xerrors.Errorf("parsing template %s: %w", name, err)
+	}
+
+	return &t, nil
+}
+
+var msgCmd = &cli.Command{
+	Name:  "msg",
+	Usage: "Work with message templates",
+	Subcommands: []*cli.Command{
+		msgTemplateCmd,
+	},
+}
+
+var msgTemplateCmd = &cli.Command{
+	Name:  "template",
+	Usage: "Manage and send parameterized message templates",
+	Description: `Templates are stored in the node repo. The target address, value and the
+   strings in the JSON params of a template can reference its arguments with
+   {{.name}}; a params string of only {{num .name}} is replaced by the number
+   given as argument, e.g.
+
+   lotus msg template save change-peer --arg miner --arg peer \
+     --to '{{.miner}}' --method 23 --params-json '{"NewID": "{{.peer}}"}'
+   lotus msg template run change-peer miner=f01234 peer=12D3KooW...`,
+	Subcommands: []*cli.Command{
+		msgTemplateSaveCmd,
+		msgTemplateListCmd,
+		msgTemplateShowCmd,
+		msgTemplateDeleteCmd,
+		msgTemplateRunCmd,
+	},
+}
+
+var msgTemplateSaveCmd = &cli.Command{
+	Name:      "save",
+	Usage:     "Create or replace a message template",
+	ArgsUsage: "[name]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "target address (template)",
+			Required: true,
+		},
+		&cli.Uint64Flag{
+			Name:  "method",
+			Usage: "method number",
+		},
+		&cli.StringFlag{
+			Name:  "value",
+			Usage: "value in FIL (template)",
+		},
+		&cli.StringFlag{
+			Name:  "params-json",
+			Usage: "JSON method params (template)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "arg",
+			Usage: "declare a template argument",
+		},
+		&cli.StringFlag{
+			Name:  "description",
+			Usage: "what the template is for",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify template name"))
+		}
+
+		t := MsgTemplate{
+			Name:        cctx.Args().First(),
+			Description: cctx.String("description"),
+			Args:        cctx.StringSlice("arg"),
+			To:          cctx.String("to"),
+			Method:      abi.MethodNum(cctx.Uint64("method")),
+			Value:       cctx.String("value"),
+			Params:      cctx.String("params-json"),
+		}
+
+		// make sure the template expands with placeholder arguments
+		for _, field := range []string{t.To, t.Value} {
+			if _, err := template.New("").Funcs(msgTemplateFuncs).Option("missingkey=error").Parse(field); err != nil {
+				return xerrors.Errorf("parsing template: %w", err)
+			}
+		}
+		if t.Params != "" {
+			params, err := t.parseParams()
+			if err != nil {
+				return err
+			}
+			if _, err := expandParams(params, func(s string) (string, error) {
+				_, err := template.New("").Funcs(msgTemplateFuncs).Option("missingkey=error").Parse(s)
+				return s, err
+			}); err != nil {
+				return xerrors.Errorf("parsing params template: %w", err)
+			}
+		}
+
+		p, err := msgTemplatePath(cctx, t.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+
+		b, err := json.MarshalIndent(&t, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(p, b, 0644)
+	},
+}
+
+var msgTemplateListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List message templates",
+	Action: func(cctx *cli.Context) error {
+		rpath, err := homedir.Expand(cctx.String("repo"))
+		if err != nil {
+			return err
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(rpath, msgTemplateDir))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		var names []string
+		for _, f := range files {
+			if strings.HasSuffix(f.Name(), ".json") {
+				names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+			}
+		}
+		sort.Strings(names)
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Name\tArgs\tMethod\tDescription\n")
+		for _, name := range names {
+			t, err := loadMsgTemplate(cctx, name)
+			if err != nil {
+				log.Warnf("%s", err)
+				continue
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", t.Name, strings.Join(t.Args, ","), t.Method, t.Description)
+		}
+		return tw.Flush()
+	},
+}
+
+var msgTemplateShowCmd = &cli.Command{
+	Name:      "show",
+	Usage:     "Print a message template",
+	ArgsUsage: "[name]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify template name"))
+		}
+
+		t, err := loadMsgTemplate(cctx, cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		b, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(b))
+		return nil
+	},
+}
+
+var msgTemplateDeleteCmd = &cli.Command{
+	Name:      "delete",
+	Usage:     "Delete a message template",
+	ArgsUsage: "[name]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify template name"))
+		}
+
+		p, err := msgTemplatePath(cctx, cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		return os.Remove(p)
+	},
+}
+
+var msgTemplateRunCmd = &cli.Command{
+	Name:      "run",
+	Usage:     "Instantiate a message template, simulate and send the message",
+	ArgsUsage: "[name] [arg=value ...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "optionally specify the account to send the message from",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only simulate the message",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "send the message even if the simulation fails",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return ShowHelp(cctx, fmt.Errorf("must specify template name"))
+		}
+
+		t, err := loadMsgTemplate(cctx, cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		args := map[string]string{}
+		for _, a := range cctx.Args().Tail() {
+			kv := strings.SplitN(a, "=", 2)
+			if len(kv) != 2 {
+				return ShowHelp(cctx, fmt.Errorf("template arguments must be in name=value form, got %q", a))
+			}
+			args[kv[0]] = kv[1]
+		}
+
+		to, value, paramsJSON, err := t.Instantiate(args)
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		var from address.Address
+		if cctx.IsSet("from") {
+			from, err = address.NewFromString(cctx.String("from"))
+		} else {
+			from, err = api.WalletDefaultAddress(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		var params []byte
+		if paramsJSON != "" {
+			params, err = decodeTypedParams(ctx, api, to, t.Method, paramsJSON)
+			if err != nil {
+				return xerrors.Errorf("encoding params: %w", err)
+			}
+		}
+
+		msg := &types.Message{
+			From:   from,
+			To:     to,
+			Value:  value,
+			Method: t.Method,
+			Params: params,
+		}
+
+		fmt.Printf("From:   %s\n", msg.From)
+		fmt.Printf("To:     %s\n", msg.To)
+		fmt.Printf("Method: %d\n", msg.Method)
+		fmt.Printf("Value:  %s\n", types.FIL(msg.Value))
+		if len(params) > 0 {
+			fmt.Printf("Params: %s\n", hex.EncodeToString(params))
+		}
+
+		res, err := api.StateCall(ctx, msg, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("simulating message: %w", err)
+		}
+
+		if res.MsgRct.ExitCode.IsSuccess() {
+			fmt.Printf("Simulation OK (gas used: %d)\n", res.MsgRct.GasUsed)
+		} else {
+			fmt.Printf("Simulation failed: exit code %d: %s\n", res.MsgRct.ExitCode, res.Error)
+			if !cctx.Bool("force") {
+				return fmt.Errorf("not sending message which fails in simulation, use --force to send anyway")
+			}
+		}
+
+		if cctx.Bool("dry-run") {
+			return nil
+		}
+
+		sm, err := api.MpoolPushMessage(ctx, msg, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(sm.Cid())
+		return nil
+	},
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestMsgTemplateInstantiate(t *testing.T) {
+	tpl := &MsgTemplate{
+		Name:   "change-peer",
+		Args:   []string{"miner", "peer"},
+		To:     "{{.miner}}",
+		Method: 23,
+		Value:  "0.5",
+		Params: `{"NewID": "{{.peer}}"}`,
+	}
+
+	to, value, params, err := tpl.Instantiate(map[string]string{"miner": "f01234", "peer": "abcd"})
+	require.NoError(t, err)
+	require.Equal(t, "f01234", to.String())
+	require.Equal(t, abi.TokenAmount(types.MustParseFIL("0.5")), value)
+	require.Equal(t, `{"NewID":"abcd"}`, params)
+
+	_, _, _, err = tpl.Instantiate(map[string]string{"miner": "f01234"})
+	require.Error(t, err)
+
+	_, _, _, err = tpl.Instantiate(map[string]string{"miner": "f01234", "peer": "abcd", "other": "x"})
+	require.Error(t, err)
+
+	_, _, _, err = tpl.Instantiate(map[string]string{"miner": "not-an-address", "peer": "abcd"})
+	require.Error(t, err)
+}
+
+func TestMsgTemplateParamsInjection(t *testing.T) {
+	tpl := &MsgTemplate{
+		Name:   "change-worker",
+		Args:   []string{"worker", "epoch"},
+		To:     "f01234",
+		Method: 3,
+		Params: `{"NewWorker": "{{.worker}}", "Epoch": "{{num .epoch}}"}`,
+	}
+
+	_, _, params, err := tpl.Instantiate(map[string]string{"worker": `f0100", "Extra": "x`, "epoch": "100"})
+	require.NoError(t, err)
+	require.Equal(t, `{"Epoch":100,"NewWorker":"f0100\", \"Extra\": \"x"}`, params)
+
+	_, _, _, err = tpl.Instantiate(map[string]string{"worker": "f0100", "epoch": `1, "Extra": 2`})
+	require.Error(t, err)
+
+	// numbers which aren't valid JSON
+	for _, epoch := range []string{"NaN", "Inf", "-Infinity", "0x10", "1_000", "+1", "1e999"} {
+		_, _, _, err = tpl.Instantiate(map[string]string{"worker": "f0100", "epoch": epoch})
+		require.Error(t, err, epoch)
+	}
+}
@@ -3,10 +3,12 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/urfave/cli/v2"
@@ -36,6 +38,7 @@ var netCmd = &cli.Command{
 		NetReachability,
 		NetBandwidthCmd,
 		NetBlockCmd,
+		netDiag,
 	},
 }
 
@@ -293,6 +296,49 @@ var NetReachability = &cli.Command{
 	},
 }
 
+var netDiag = &cli.Command{
+	Name:  "diag",
+	Usage: "Collect connectivity diagnostics of the full node into a JSON bundle",
+	Description: `Dials the bootstrap peers, times fetching block headers with chain exchange, and
+   collects the NAT status, pubsub topic peers and scores, and bandwidth stats of the node.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "write the bundle to this file instead of stdout",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		diag, err := api.NetDiag(ctx)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.MarshalIndent(diag, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if out := cctx.String("output"); out != "" {
+			if err := ioutil.WriteFile(out, b, 0644); err != nil {
+				return xerrors.Errorf("writing bundle: %w", err)
+			}
+			fmt.Printf("Wrote diagnostics to %s\n", out)
+			return nil
+		}
+
+		fmt.Println(string(b))
+		return nil
+	},
+}
+
 var NetBandwidthCmd = &cli.Command{
 	Name:  "bandwidth",
 	Usage: "Print bandwidth usage information",
@@ -376,6 +422,142 @@ var NetBandwidthCmd = &cli.Command{
 		return tw.Flush()
 
 	},
+	Subcommands: []*cli.Command{
+		NetBandwidthHistoryCmd,
+	},
+}
+
+// protocol groups used to attribute recorded bandwidth, matched by prefix
+var bandwidthProtocolGroups = []struct {
+	prefix string
+	group  string
+}{
+	{"/fil/chain/xchg", "chain-exchange"},
+	{"/fil/hello", "hello"},
+	{"/ipfs/graphsync", "graphsync"},
+	{"/meshsub", "gossipsub"},
+	{"/floodsub", "gossipsub"},
+	{"/fil/storage", "markets"},
+	{"/fil/retrieval", "markets"},
+	{"/fil/kad", "dht"},
+	{"/ipfs/kad", "dht"},
+	{"/ipfs/bitswap", "bitswap"},
+	{"/chain/ipfs/bitswap", "bitswap"},
+	{"/ipfs/id", "identify"},
+	{"/ipfs/ping", "ping"},
+}
+
+func bandwidthProtocolGroup(p protocol.ID) string {
+	for _, g := range bandwidthProtocolGroups {
+		if strings.HasPrefix(string(p), g.prefix) {
+			return g.group
+		}
+	}
+	return "other"
+}
+
+var NetBandwidthHistoryCmd = &cli.Command{
+	Name:  "history",
+	Usage: "Print bandwidth usage recorded by the node",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "since",
+			Usage: "how far back to look",
+			Value: 24 * time.Hour,
+		},
+		&cli.BoolFlag{
+			Name:  "by-peer",
+			Usage: "list the peers which used the most bandwidth",
+		},
+		&cli.BoolFlag{
+			Name:  "by-protocol",
+			Usage: "list bandwidth usage by protocol",
+		},
+		&cli.BoolFlag{
+			Name:  "by-group",
+			Usage: "list bandwidth usage by protocol group (chain-exchange, graphsync, gossipsub, markets, ...)",
+		},
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "number of segments to list",
+			Value: 20,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		samples, err := api.NetBandwidthHistory(ctx, time.Now().Add(-cctx.Duration("since")))
+		if err != nil {
+			return err
+		}
+
+		if len(samples) == 0 {
+			fmt.Println("no bandwidth usage recorded in this period")
+			return nil
+		}
+
+		usage := map[string]atypes.NetBandwidthUsage{}
+		add := func(seg string, u atypes.NetBandwidthUsage) {
+			t := usage[seg]
+			t.In += u.In
+			t.Out += u.Out
+			usage[seg] = t
+		}
+
+		for _, s := range samples {
+			switch {
+			case cctx.Bool("by-peer"):
+				for p, u := range s.Peers {
+					add(p, u)
+				}
+			case cctx.Bool("by-protocol"):
+				for p, u := range s.Protocols {
+					if p == "" {
+						p = "<unknown>"
+					}
+					add(string(p), u)
+				}
+			case cctx.Bool("by-group"):
+				for p, u := range s.Protocols {
+					add(bandwidthProtocolGroup(p), u)
+				}
+			default:
+				add("Total", s.Total)
+			}
+		}
+
+		segs := make([]string, 0, len(usage))
+		for seg := range usage {
+			segs = append(segs, seg)
+		}
+		sort.Slice(segs, func(i, j int) bool {
+			ui, uj := usage[segs[i]], usage[segs[j]]
+			return ui.In+ui.Out > uj.In+uj.Out
+		})
+		if top := cctx.Int("top"); top > 0 && len(segs) > top {
+			segs = segs[:top]
+		}
+
+		fmt.Printf("Usage from %s to %s:\n", samples[0].Start.Format(time.Stamp), samples[len(samples)-1].End.Format(time.Stamp))
+		if cctx.Bool("by-peer") {
+			fmt.Println("(only the top peers of each sample interval are recorded)")
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Segment\tTotalIn\tTotalOut\n")
+		for _, seg := range segs {
+			u := usage[seg]
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", seg, humanize.Bytes(uint64(u.In)), humanize.Bytes(uint64(u.Out)))
+		}
+
+		return tw.Flush()
+	},
 }
 
 var NetBlockCmd = &cli.Command{
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+// Offline signing: `send --offline` writes an unsigned message, with the nonce
+// and gas values filled in, to a file. `wallet sign-file` signs it with the keys
+// of a local repo, on a machine which doesn't run a node or connect to the
+// network, and
+// `mpool publish-file` publishes the signed message from an online node.
+
+// writeUnsignedMessage completes the message with a nonce and gas values, and
+// writes it to a file
+func writeUnsignedMessage(ctx context.Context, fapi api.FullNode, msg *types.Message, nonceSet bool, path string) error {
+	if !nonceSet {
+		nonce, err := fapi.MpoolGetNonce(ctx, msg.From)
+		if err != nil {
+			return xerrors.Errorf("getting nonce: %w", err)
+		}
+		msg.Nonce = nonce
+	}
+
+	msg, err := fapi.GasEstimateMessageGas(ctx, msg, nil, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("estimating gas: %w", err)
+	}
+
+	mb, err := msg.Serialize()
+	if err != nil {
+		return xerrors.Errorf("serializing message: %w", err)
+	}
+
+	if err := ioutil.WriteFile(path, mb, 0644); err != nil {
+		return xerrors.Errorf("writing message: %w", err)
+	}
+
+	if err := printOfflineMessage(msg); err != nil {
+		return err
+	}
+	fmt.Printf("wrote unsigned message %s to %s\n", msg.Cid(), path)
+	return nil
+}
+
+func printOfflineMessage(msg *types.Message) error {
+	b, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("marshaling message: %w", err)
+	}
+
+	fmt.Println(string(b))
+	fmt.Printf("Max fee: %s\n", types.FIL(msg.RequiredFunds()))
+	return nil
+}
+
+var walletSignFile = &cli.Command{
+	Name:      "sign-file",
+	Usage:     "Sign an unsigned message file written by 'send --offline' with the keys of the local repo, without a running node",
+	ArgsUsage: "<unsigned message file>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "signed message file to write (default: <unsigned message file>.signed)",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify the unsigned message file"))
+		}
+
+		in := cctx.Args().First()
+		mb, err := ioutil.ReadFile(in)
+		if err != nil {
+			return xerrors.Errorf("reading message: %w", err)
+		}
+
+		msg, err := types.DecodeMessage(mb)
+		if err != nil {
+			return xerrors.Errorf("decoding message: %w", err)
+		}
+
+		if err := printOfflineMessage(msg); err != nil {
+			return err
+		}
+
+		sm, err := signOffline(cctx.Context, cctx.String("repo"), msg)
+		if err != nil {
+			return err
+		}
+
+		smb, err := sm.Serialize()
+		if err != nil {
+			return xerrors.Errorf("serializing signed message: %w", err)
+		}
+
+		out := cctx.String("output")
+		if out == "" {
+			out = in + ".signed"
+		}
+
+		if err := ioutil.WriteFile(out, smb, 0644); err != nil {
+			return xerrors.Errorf("writing signed message: %w", err)
+		}
+
+		fmt.Printf("wrote signed message %s to %s\n", sm.Cid(), out)
+		return nil
+	},
+}
+
+// signOffline signs the message with the keys in the keystore of the repo at
+// repoPath, without a node. The sender address can't be resolved without the
+// chain, so it must be a key address.
+func signOffline(ctx context.Context, repoPath string, msg *types.Message) (*types.SignedMessage, error) {
+	if msg.From.Protocol() == address.ID {
+		return nil, xerrors.Errorf("can't sign for ID address %s offline, the message must be sent from a key address", msg.From)
+	}
+
+	r, err := repo.NewFS(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := r.Exists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, xerrors.Errorf("repo at '%s' is not initialized", repoPath)
+	}
+
+	lr, err := r.Lock(repo.FullNode)
+	if err != nil {
+		return nil, xerrors.Errorf("locking repo: %w", err)
+	}
+	defer lr.Close() // nolint:errcheck
+
+	ks, err := lr.KeyStore()
+	if err != nil {
+		return nil, xerrors.Errorf("opening keystore: %w", err)
+	}
+
+	w, err := wallet.NewWallet(ks)
+	if err != nil {
+		return nil, err
+	}
+
+	mb, err := msg.ToStorageBlock()
+	if err != nil {
+		return nil, xerrors.Errorf("serializing message: %w", err)
+	}
+
+	sig, err := w.WalletSign(ctx, msg.From, mb.Cid().Bytes(), api.MsgMeta{
+		Type:  api.MTChainMsg,
+		Extra: mb.RawData(),
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("signing message: %w", err)
+	}
+
+	return &types.SignedMessage{
+		Message:   *msg,
+		Signature: *sig,
+	}, nil
+}
+
+var mpoolPublishFile = &cli.Command{
+	Name:      "publish-file",
+	Usage:     "Publish a signed message file written by 'wallet sign-file'",
+	ArgsUsage: "<signed message file>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify the signed message file"))
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		smb, err := ioutil.ReadFile(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("reading signed message: %w", err)
+		}
+
+		sm, err := types.DecodeSignedMessage(smb)
+		if err != nil {
+			return xerrors.Errorf("decoding signed message: %w", err)
+		}
+
+		c, err := api.MpoolPush(ctx, sm)
+		if err != nil {
+			return xerrors.Errorf("publishing message: %w", err)
+		}
+
+		fmt.Println(c)
+		return nil
+	},
+}
@@ -1,11 +1,19 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/node/repo"
@@ -16,6 +24,7 @@ var pprofCmd = &cli.Command{
 	Hidden: true,
 	Subcommands: []*cli.Command{
 		PprofGoroutines,
+		PprofCapture,
 	},
 }
 
@@ -55,3 +64,126 @@ var PprofGoroutines = &cli.Command{
 		return r.Body.Close()
 	},
 }
+
+var PprofCapture = &cli.Command{
+	Name:  "capture",
+	Usage: "Capture runtime profiles of the node, and optionally its workers, over the admin API",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "duration",
+			Usage: "how long to sample the cpu profile for",
+			Value: 30 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:  "profiles",
+			Usage: "comma separated list of profiles to capture",
+			Value: "cpu,heap,goroutine",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "directory to write the profiles to",
+			Value: "profiles",
+		},
+		&cli.BoolFlag{
+			Name:  "workers",
+			Usage: "also capture profiles of all workers connected to the miner",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := ReqContext(cctx)
+
+		profiles := strings.Split(cctx.String("profiles"), ",")
+		duration := cctx.Duration("duration")
+
+		out := cctx.String("output")
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return xerrors.Errorf("creating output directory: %w", err)
+		}
+
+		napi, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		info, err := napi.ProfileInfo(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting node info: %w", err)
+		}
+
+		meta := map[string]interface{}{
+			"Node":     info,
+			"Time":     time.Now(),
+			"Duration": duration.String(),
+			"Profiles": profiles,
+		}
+
+		var eg errgroup.Group
+
+		eg.Go(func() error {
+			return captureProfiles(ctx, out, "node", profiles, duration, napi.ProfileCapture)
+		})
+
+		if cctx.Bool("workers") {
+			mapi, closer, err := GetStorageMinerAPI(cctx)
+			if err != nil {
+				return err
+			}
+			defer closer()
+
+			stats, err := mapi.WorkerStats(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting worker stats: %w", err)
+			}
+
+			workers := map[string]string{}
+			for wid, st := range stats {
+				wid := wid
+				prefix := fmt.Sprintf("worker-%s", wid)
+				workers[prefix] = st.Info.Hostname
+
+				eg.Go(func() error {
+					return captureProfiles(ctx, out, prefix, profiles, duration, func(ctx context.Context, name string, duration time.Duration) ([]byte, error) {
+						return mapi.WorkerProfileCapture(ctx, wid, name, duration)
+					})
+				})
+			}
+			meta["Workers"] = workers
+		}
+
+		if err := eg.Wait(); err != nil {
+			return err
+		}
+
+		mb, err := json.MarshalIndent(meta, "", "  ")
+		if err != nil {
+			return xerrors.Errorf("marshaling metadata: %w", err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(out, "metadata.json"), mb, 0644); err != nil {
+			return xerrors.Errorf("writing metadata: %w", err)
+		}
+
+		fmt.Printf("Profiles written to %s\n", out)
+		return nil
+	},
+}
+
+// captureProfiles captures the profiles of one process, writing them to
+// <prefix>-<profile>.pprof files in the output directory
+func captureProfiles(ctx context.Context, out, prefix string, profiles []string, duration time.Duration, capture func(context.Context, string, time.Duration) ([]byte, error)) error {
+	for _, name := range profiles {
+		name = strings.TrimSpace(name)
+
+		b, err := capture(ctx, name, duration)
+		if err != nil {
+			return xerrors.Errorf("capturing %s profile of %s: %w", name, prefix, err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(out, fmt.Sprintf("%s-%s.pprof", prefix, name)), b, 0644); err != nil {
+			return xerrors.Errorf("writing %s profile of %s: %w", name, prefix, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var scheduleCmd = &cli.Command{
+	Name:  "schedule",
+	Usage: "Manage tasks run by the node at chain epochs",
+	Description: `Tasks run once at an epoch, or every proving period at an offset from the opening
+   of a proving deadline of a miner. A task sends a message from a message template, runs a
+   command, or calls a webhook. Commands get, and webhooks are POSTed, the task run as JSON.
+
+   lotus schedule add --epoch 1000000 --template change-peer miner=f01234 peer=12D3KooW...
+   lotus schedule add --miner f01234 --deadline 3 --offset -20 --command 'check-deadline.sh'`,
+	Subcommands: []*cli.Command{
+		scheduleAddCmd,
+		scheduleListCmd,
+		scheduleRemoveCmd,
+	},
+}
+
+var scheduleAddCmd = &cli.Command{
+	Name:      "add",
+	Usage:     "Schedule a task",
+	ArgsUsage: "[template arg=value ...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "name",
+			Usage: "name of the task",
+		},
+		&cli.Int64Flag{
+			Name:  "epoch",
+			Usage: "run once at this epoch",
+		},
+		&cli.StringFlag{
+			Name:  "miner",
+			Usage: "run every proving period relative to a deadline of this miner",
+		},
+		&cli.Uint64Flag{
+			Name:  "deadline",
+			Usage: "index of the deadline the task runs relative to",
+		},
+		&cli.Int64Flag{
+			Name:  "offset",
+			Usage: "epochs after the deadline opens, negative to run before",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "send a message instantiated from this message template",
+		},
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "account to send the message from, defaults to the default wallet address",
+		},
+		&cli.StringFlag{
+			Name:  "command",
+			Usage: "run this command",
+		},
+		&cli.StringFlag{
+			Name:  "webhook",
+			Usage: "POST to this URL",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		t := api.EpochTask{
+			Name:  cctx.String("name"),
+			Epoch: abi.ChainEpoch(cctx.Int64("epoch")),
+			Action: api.EpochTaskAction{
+				Command: cctx.String("command"),
+				Webhook: cctx.String("webhook"),
+			},
+		}
+
+		if cctx.IsSet("miner") {
+			maddr, err := address.NewFromString(cctx.String("miner"))
+			if err != nil {
+				return xerrors.Errorf("parsing miner address: %w", err)
+			}
+
+			t.Deadline = &api.EpochTaskDeadline{
+				Miner:  maddr,
+				Index:  cctx.Uint64("deadline"),
+				Offset: abi.ChainEpoch(cctx.Int64("offset")),
+			}
+		}
+
+		if cctx.IsSet("template") {
+			tpl, err := loadMsgTemplate(cctx, cctx.String("template"))
+			if err != nil {
+				return err
+			}
+
+			args := map[string]string{}
+			for _, a := range cctx.Args().Slice() {
+				kv := strings.SplitN(a, "=", 2)
+				if len(kv) != 2 {
+					return ShowHelp(cctx, fmt.Errorf("template arguments must be in name=value form, got %q", a))
+				}
+				args[kv[0]] = kv[1]
+			}
+
+			to, value, paramsJSON, err := tpl.Instantiate(args)
+			if err != nil {
+				return err
+			}
+
+			var from address.Address
+			if cctx.IsSet("from") {
+				from, err = address.NewFromString(cctx.String("from"))
+			} else {
+				from, err = napi.WalletDefaultAddress(ctx)
+			}
+			if err != nil {
+				return err
+			}
+
+			var params []byte
+			if paramsJSON != "" {
+				params, err = decodeTypedParams(ctx, napi, to, tpl.Method, paramsJSON)
+				if err != nil {
+					return xerrors.Errorf("encoding params: %w", err)
+				}
+			}
+
+			t.Action.Message = &types.Message{
+				From:   from,
+				To:     to,
+				Value:  value,
+				Method: tpl.Method,
+				Params: params,
+			}
+			if t.Name == "" {
+				t.Name = tpl.Name
+			}
+		} else if cctx.Args().Present() {
+			return ShowHelp(cctx, fmt.Errorf("arguments are only taken with --template"))
+		}
+
+		id, err := napi.EpochTaskAdd(ctx, t)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Scheduled task %d\n", id)
+		return nil
+	},
+}
+
+var scheduleListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List scheduled tasks",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		tasks, err := napi.EpochTaskList(ReqContext(cctx))
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ID\tName\tWhen\tAction\tNext Run\tLast Run\tResult")
+		for _, t := range tasks {
+			when := fmt.Sprintf("epoch %d", t.Epoch)
+			if d := t.Deadline; d != nil {
+				when = fmt.Sprintf("%s deadline %d %+d", d.Miner, d.Index, d.Offset)
+			}
+
+			var action string
+			switch a := t.Action; {
+			case a.Message != nil:
+				action = fmt.Sprintf("send %s.%d", a.Message.To, a.Message.Method)
+			case a.Command != "":
+				action = "run " + a.Command
+			default:
+				action = "call " + a.Webhook
+			}
+
+			next, last := "-", "-"
+			if t.NextRun != 0 {
+				next = fmt.Sprint(t.NextRun)
+			}
+			if t.LastRun != 0 {
+				last = fmt.Sprint(t.LastRun)
+			}
+
+			res := strings.TrimSpace(t.LastResult)
+			if t.LastError != "" {
+				res = "error: " + t.LastError
+			}
+			if i := strings.IndexByte(res, '\n'); i >= 0 {
+				res = res[:i] + "..."
+			}
+
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, when, action, next, last, res)
+		}
+		return tw.Flush()
+	},
+}
+
+var scheduleRemoveCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "Remove a scheduled task",
+	ArgsUsage: "[id]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify task id"))
+		}
+
+		var id uint64
+		if _, err := fmt.Sscan(cctx.Args().First(), &id); err != nil {
+			return xerrors.Errorf("parsing task id: %w", err)
+		}
+
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		return napi.EpochTaskRemove(ReqContext(cctx), id)
+	},
+}
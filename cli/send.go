@@ -66,6 +66,10 @@ var sendCmd = &cli.Command{
 			Name:  "force",
 			Usage: "must be specified for the action to take effect if maybe SysErrInsufficientFunds etc",
 		},
+		&cli.StringFlag{
+			Name:  "offline",
+			Usage: "write the unsigned message to this file, to be signed with 'wallet sign-file', instead of sending it",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 2 {
@@ -162,6 +166,11 @@ var sendCmd = &cli.Command{
 			}
 		}
 
+		if out := cctx.String("offline"); out != "" {
+			msg.Nonce = cctx.Uint64("nonce")
+			return writeUnsignedMessage(ctx, api, msg, cctx.IsSet("nonce"), out)
+		}
+
 		if cctx.IsSet("nonce") {
 			msg.Nonce = cctx.Uint64("nonce")
 			sm, err := api.WalletSignMessage(ctx, fromAddr, msg)
@@ -9,6 +9,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"reflect"
 	"sort"
 	"strconv"
@@ -73,6 +74,7 @@ var stateCmd = &cli.Command{
 		stateMarketCmd,
 		stateExecTraceCmd,
 		stateNtwkVersionCmd,
+		stateWatchDeadlinesCmd,
 	},
 }
 
@@ -1868,3 +1870,82 @@ var stateNtwkVersionCmd = &cli.Command{
 		return nil
 	},
 }
+
+var stateWatchDeadlinesCmd = &cli.Command{
+	Name:      "watch-deadlines",
+	Usage:     "Print (or run a command) each time a deadline of the miner opens",
+	ArgsUsage: "[minerAddress]",
+	Description: `Notifications are sent when the chain reaches the epoch a deadline opens at,
+   shifted by --offset epochs, e.g. '--offset=-5 --deadline=12' notifies 5 epochs
+   before deadline 12 opens.
+
+   When --exec is set, the command is run through 'sh -c' for each
+   notification, with LOTUS_DEADLINE_INDEX, LOTUS_DEADLINE_OPEN,
+   LOTUS_DEADLINE_CLOSE, LOTUS_DEADLINE_CHALLENGE and LOTUS_DEADLINE_EPOCH set.`,
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "offset",
+			Usage: "notify this many epochs after a deadline opens (negative: before it opens)",
+		},
+		&cli.Int64SliceFlag{
+			Name:  "deadline",
+			Usage: "only notify about the given deadline indexes (default: all)",
+		},
+		&cli.StringFlag{
+			Name:  "exec",
+			Usage: "command to run on each notification",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify miner address"))
+		}
+
+		maddr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		var deadlines []uint64
+		for _, dl := range cctx.Int64Slice("deadline") {
+			deadlines = append(deadlines, uint64(dl))
+		}
+
+		events, err := api.StateMinerDeadlineNotify(ctx, maddr, abi.ChainEpoch(cctx.Int64("offset")), deadlines)
+		if err != nil {
+			return err
+		}
+
+		for ev := range events {
+			dl := ev.Deadline
+			fmt.Printf("%d: deadline %d opens at %d (challenge: %d, closes: %d)\n", ev.Epoch, dl.Index, dl.Open, dl.Challenge, dl.Close)
+
+			if cmd := cctx.String("exec"); cmd != "" {
+				c := exec.CommandContext(ctx, "sh", "-c", cmd)
+				c.Env = append(os.Environ(),
+					fmt.Sprintf("LOTUS_DEADLINE_INDEX=%d", dl.Index),
+					fmt.Sprintf("LOTUS_DEADLINE_OPEN=%d", dl.Open),
+					fmt.Sprintf("LOTUS_DEADLINE_CLOSE=%d", dl.Close),
+					fmt.Sprintf("LOTUS_DEADLINE_CHALLENGE=%d", dl.Challenge),
+					fmt.Sprintf("LOTUS_DEADLINE_EPOCH=%d", ev.Epoch),
+				)
+				c.Stdout = os.Stdout
+				c.Stderr = os.Stderr
+
+				if err := c.Run(); err != nil {
+					log.Errorf("running deadline command: %s", err)
+				}
+			}
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var subsystemCmd = &cli.Command{
+	Name:  "subsystem",
+	Usage: "Stop and start node subsystems without restarting the node",
+	Subcommands: []*cli.Command{
+		subsystemListCmd,
+		subsystemStartCmd,
+		subsystemStopCmd,
+	},
+}
+
+var subsystemListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List subsystems and whether they are running",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		subs, err := napi.SubsystemList(ReqContext(cctx))
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "Name\tState\tSince\tDepends On\tDescription")
+		for _, s := range subs {
+			state := "stopped"
+			if s.Running {
+				state = "running"
+			}
+			if s.Error != "" {
+				state += " (" + s.Error + ")"
+			}
+
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Name, state, s.Since.Local().Format(time.Stamp), strings.Join(s.DependsOn, ","), s.Description)
+		}
+		return tw.Flush()
+	},
+}
+
+var subsystemStartCmd = &cli.Command{
+	Name:      "start",
+	Usage:     "Start a stopped subsystem",
+	ArgsUsage: "[name]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected a subsystem name")
+		}
+
+		napi, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		if err := napi.SubsystemStart(ReqContext(cctx), cctx.Args().First()); err != nil {
+			return err
+		}
+
+		fmt.Printf("Started %s\n", cctx.Args().First())
+		return nil
+	},
+}
+
+var subsystemStopCmd = &cli.Command{
+	Name:      "stop",
+	Usage:     "Stop a running subsystem",
+	ArgsUsage: "[name]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected a subsystem name")
+		}
+
+		napi, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		if err := napi.SubsystemStop(ReqContext(cctx), cctx.Args().First()); err != nil {
+			return err
+		}
+
+		fmt.Printf("Stopped %s\n", cctx.Args().First())
+		return nil
+	},
+}
@@ -3,16 +3,19 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/filecoin-project/lotus/chain/types"
 
 	"github.com/filecoin-project/go-state-types/abi"
 	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/urfave/cli/v2"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
 )
 
 var syncCmd = &cli.Command{
@@ -25,6 +28,8 @@ var syncCmd = &cli.Command{
 		syncUnmarkBadCmd,
 		syncCheckBadCmd,
 		syncCheckpointCmd,
+		syncPeersCmd,
+		syncUnbanCmd,
 	},
 }
 
@@ -202,6 +207,9 @@ var syncCheckpointCmd = &cli.Command{
 	Name:      "checkpoint",
 	Usage:     "mark a certain tipset as checkpointed; the node will never fork away from this tipset",
 	ArgsUsage: "[tipsetKey]",
+	Description: `Checkpointing a tipset persists across restarts. The tipset must be in the
+   current chain, and the head can no longer be set to a tipset which doesn't
+   descend from it. Without arguments, the current checkpoint is printed.`,
 	Flags: []cli.Flag{
 		&cli.Uint64Flag{
 			Name:  "epoch",
@@ -218,6 +226,24 @@ var syncCheckpointCmd = &cli.Command{
 
 		var ts *types.TipSet
 
+		if !cctx.IsSet("epoch") && !cctx.Args().Present() {
+			tsk, err := napi.SyncGetCheckpoint(ctx)
+			if err != nil {
+				return err
+			}
+			if tsk == types.EmptyTSK {
+				fmt.Println("no checkpoint set")
+				return nil
+			}
+
+			ts, err := napi.ChainGetTipSet(ctx, tsk)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d: %s\n", ts.Height(), ts.Cids())
+			return nil
+		}
+
 		if cctx.IsSet("epoch") {
 			ts, err = napi.ChainGetTipSetByHeight(ctx, abi.ChainEpoch(cctx.Uint64("epoch")), types.EmptyTSK)
 		}
@@ -240,6 +266,88 @@ var syncCheckpointCmd = &cli.Command{
 	},
 }
 
+var syncPeersCmd = &cli.Command{
+	Name:  "peers",
+	Usage: "list the track record of the peers chain data is requested from",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "banned",
+			Usage: "only list banned peers",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		peers, err := napi.SyncPeers(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tablewriter.New(tablewriter.Col("Peer"),
+			tablewriter.Col("Score"),
+			tablewriter.Col("Successes"),
+			tablewriter.Col("Failures"),
+			tablewriter.Col("Invalid"),
+			tablewriter.Col("Slow"),
+			tablewriter.Col("BadBlocks"),
+			tablewriter.Col("AvgTime"),
+			tablewriter.Col("Banned"))
+
+		for _, p := range peers {
+			banned := "-"
+			if !p.BannedUntil.IsZero() {
+				banned = "until " + p.BannedUntil.Format(time.Stamp)
+			} else if cctx.Bool("banned") {
+				continue
+			}
+
+			w.Write(map[string]interface{}{
+				"Peer":      p.ID,
+				"Score":     fmt.Sprintf("%.2f", p.Score),
+				"Successes": p.Successes,
+				"Failures":  p.Failures,
+				"Invalid":   p.InvalidResponses,
+				"Slow":      p.SlowResponses,
+				"BadBlocks": p.BadBlocks,
+				"AvgTime":   p.AverageTime.Truncate(time.Millisecond),
+				"Banned":    banned,
+			})
+		}
+
+		return w.Flush(os.Stdout)
+	},
+}
+
+var syncUnbanCmd = &cli.Command{
+	Name:      "unban",
+	Usage:     "lift the ban of a peer which misbehaved during sync",
+	ArgsUsage: "[peerId]",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.NArg() != 1 {
+			return fmt.Errorf("must specify the peer to unban")
+		}
+
+		p, err := peer.Decode(cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("parsing peer ID: %w", err)
+		}
+
+		return napi.SyncUnbanPeer(ctx, p)
+	},
+}
+
 func SyncWait(ctx context.Context, napi api.FullNode, watch bool) error {
 	tick := time.Second / 4
 
@@ -33,9 +33,11 @@ var walletCmd = &cli.Command{
 		walletGetDefault,
 		walletSetDefault,
 		walletSign,
+		walletSignFile,
 		walletVerify,
 		walletDelete,
 		walletMarket,
+		walletPortfolio,
 	},
 }
 
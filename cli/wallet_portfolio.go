@@ -0,0 +1,384 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apibstore"
+	"github.com/filecoin-project/lotus/api/client"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+)
+
+// PortfolioEntry is the aggregated view of a single address across all
+// queried nodes
+type PortfolioEntry struct {
+	Address address.Address
+	Actor   string
+	Nodes   []string
+
+	Balance abi.TokenAmount
+
+	MarketEscrow abi.TokenAmount
+	MarketLocked abi.TokenAmount
+
+	// only set for miner actors
+	MinerAvailable abi.TokenAmount
+	MinerLocked    abi.TokenAmount
+
+	PendingMessages int
+	// PendingValue is the value plus the maximum gas cost of all pending
+	// messages sent from the address
+	PendingValue abi.TokenAmount
+
+	Error string `json:",omitempty"`
+}
+
+// Portfolio sums up the balances of all entries
+type Portfolio struct {
+	Entries []*PortfolioEntry
+
+	Balance         abi.TokenAmount
+	MarketAvailable abi.TokenAmount
+	MarketLocked    abi.TokenAmount
+	MinerAvailable  abi.TokenAmount
+	MinerLocked     abi.TokenAmount
+	PendingValue    abi.TokenAmount
+}
+
+type portfolioNode struct {
+	name string
+	api  api.FullNode
+}
+
+var walletPortfolio = &cli.Command{
+	Name:  "portfolio",
+	Usage: "Show aggregated balances of wallets across multiple nodes",
+	Description: `Collects the wallet addresses of the local node and all nodes passed with --node,
+plus any address passed with --address (e.g. miner actors), and prints a single
+summary of wallet, market escrow and miner balances, along with funds used by
+pending messages.
+
+Remote nodes are specified in the same format as FULLNODE_API_INFO, a read
+token is sufficient:
+
+   lotus wallet portfolio --node TOKEN:/ip4/10.0.0.2/tcp/1234/http`,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "node",
+			Usage: "additional full node to collect wallets and pending messages from (TOKEN:MULTIADDR)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "address",
+			Usage: "additional address to include, e.g. a miner actor or a wallet not held by any node",
+		},
+		&cli.BoolFlag{
+			Name:  "no-local",
+			Usage: "don't include wallets held by the local node",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "output json",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		fapi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var nodes []portfolioNode
+		if !cctx.Bool("no-local") {
+			nodes = append(nodes, portfolioNode{name: "local", api: fapi})
+		}
+
+		for _, info := range cctx.StringSlice("node") {
+			n, nc, err := dialPortfolioNode(cctx, info)
+			if err != nil {
+				return err
+			}
+			defer nc()
+
+			nodes = append(nodes, n)
+		}
+
+		entries := map[address.Address]*PortfolioEntry{}
+		var order []address.Address
+
+		// addresses are keyed by their ID address where possible so that the
+		// same actor known by different addresses is only counted once
+		add := func(addr address.Address, node string) (*PortfolioEntry, error) {
+			key := addr
+			if id, err := fapi.StateLookupID(ctx, addr, types.EmptyTSK); err == nil {
+				key = id
+			} else if !strings.Contains(err.Error(), "not found") {
+				return nil, xerrors.Errorf("looking up id of %s: %w", addr, err)
+			}
+
+			e, ok := entries[key]
+			if !ok {
+				e = &PortfolioEntry{Address: addr, PendingValue: big.Zero()}
+				entries[key] = e
+				order = append(order, key)
+			}
+
+			if node != "" {
+				for _, n := range e.Nodes {
+					if n == node {
+						return e, nil
+					}
+				}
+				e.Nodes = append(e.Nodes, node)
+			}
+
+			return e, nil
+		}
+
+		for _, n := range nodes {
+			addrs, err := n.api.WalletList(ctx)
+			if err != nil {
+				return xerrors.Errorf("listing wallets of node %s: %w", n.name, err)
+			}
+
+			for _, addr := range addrs {
+				if _, err := add(addr, n.name); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, s := range cctx.StringSlice("address") {
+			addr, err := address.NewFromString(s)
+			if err != nil {
+				return xerrors.Errorf("parsing address %s: %w", s, err)
+			}
+
+			if _, err := add(addr, ""); err != nil {
+				return err
+			}
+		}
+
+		// pending messages are collected from every node, as messages held
+		// by a remote mpool might not have propagated yet
+		seen := map[string]struct{}{}
+		for _, n := range nodes {
+			pending, err := n.api.MpoolPending(ctx, types.EmptyTSK)
+			if err != nil {
+				return xerrors.Errorf("getting pending messages of node %s: %w", n.name, err)
+			}
+
+			for _, sm := range pending {
+				c := sm.Cid().String()
+				if _, ok := seen[c]; ok {
+					continue
+				}
+				seen[c] = struct{}{}
+
+				from := sm.Message.From
+				if id, err := fapi.StateLookupID(ctx, from, types.EmptyTSK); err == nil {
+					from = id
+				}
+
+				e, ok := entries[from]
+				if !ok {
+					continue
+				}
+
+				e.PendingMessages++
+				e.PendingValue = big.Add(e.PendingValue, sm.Message.RequiredFunds())
+				e.PendingValue = big.Add(e.PendingValue, sm.Message.Value)
+			}
+		}
+
+		pf := &Portfolio{
+			Balance:         big.Zero(),
+			MarketAvailable: big.Zero(),
+			MarketLocked:    big.Zero(),
+			MinerAvailable:  big.Zero(),
+			MinerLocked:     big.Zero(),
+			PendingValue:    big.Zero(),
+		}
+
+		store := adt.WrapStore(ctx, cbor.NewCborStore(apibstore.NewAPIBlockstore(fapi)))
+
+		for _, key := range order {
+			e := entries[key]
+			pf.Entries = append(pf.Entries, e)
+
+			if err := fillPortfolioEntry(ctx, fapi, store, e); err != nil {
+				e.Error = err.Error()
+				continue
+			}
+
+			pf.Balance = big.Add(pf.Balance, e.Balance)
+			pf.MarketAvailable = big.Add(pf.MarketAvailable, big.Sub(e.MarketEscrow, e.MarketLocked))
+			pf.MarketLocked = big.Add(pf.MarketLocked, e.MarketLocked)
+			if e.MinerAvailable.Int != nil {
+				pf.MinerAvailable = big.Add(pf.MinerAvailable, e.MinerAvailable)
+				pf.MinerLocked = big.Add(pf.MinerLocked, e.MinerLocked)
+			}
+			pf.PendingValue = big.Add(pf.PendingValue, e.PendingValue)
+		}
+
+		sort.SliceStable(pf.Entries, func(i, j int) bool {
+			return pf.Entries[i].Balance.GreaterThan(pf.Entries[j].Balance)
+		})
+
+		if cctx.Bool("json") {
+			out, err := json.MarshalIndent(pf, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Address"),
+			tablewriter.Col("Actor"),
+			tablewriter.Col("Balance"),
+			tablewriter.Col("Market(Avail)"),
+			tablewriter.Col("Market(Locked)"),
+			tablewriter.Col("Miner(Avail)"),
+			tablewriter.Col("Miner(Locked)"),
+			tablewriter.Col("Pending"),
+			tablewriter.Col("Nodes"),
+			tablewriter.NewLineCol("Error"))
+
+		for _, e := range pf.Entries {
+			row := map[string]interface{}{
+				"Address": e.Address,
+				"Nodes":   strings.Join(e.Nodes, ","),
+			}
+
+			if e.Error != "" {
+				row["Error"] = e.Error
+				tw.Write(row)
+				continue
+			}
+
+			row["Actor"] = e.Actor
+			row["Balance"] = types.FIL(e.Balance).Short()
+			row["Market(Avail)"] = types.FIL(big.Sub(e.MarketEscrow, e.MarketLocked)).Short()
+			row["Market(Locked)"] = types.FIL(e.MarketLocked).Short()
+			if e.MinerAvailable.Int != nil {
+				row["Miner(Avail)"] = types.FIL(e.MinerAvailable).Short()
+				row["Miner(Locked)"] = types.FIL(e.MinerLocked).Short()
+			}
+			if e.PendingMessages > 0 {
+				row["Pending"] = fmt.Sprintf("%d (%s)", e.PendingMessages, types.FIL(e.PendingValue).Short())
+			}
+
+			tw.Write(row)
+		}
+
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+
+		fmt.Println()
+		fmt.Printf("Wallets:          %s\n", types.FIL(pf.Balance).Short())
+		fmt.Printf("Market Available: %s\n", types.FIL(pf.MarketAvailable).Short())
+		fmt.Printf("Market Locked:    %s\n", types.FIL(pf.MarketLocked).Short())
+		fmt.Printf("Miner Available:  %s\n", types.FIL(pf.MinerAvailable).Short())
+		fmt.Printf("Miner Locked:     %s\n", types.FIL(pf.MinerLocked).Short())
+		fmt.Printf("Pending Messages: %s\n", types.FIL(pf.PendingValue).Short())
+
+		total := big.Sum(pf.Balance, pf.MarketAvailable, pf.MarketLocked, pf.MinerAvailable, pf.MinerLocked)
+		fmt.Printf("Total:            %s\n", types.FIL(total).Short())
+
+		return nil
+	},
+}
+
+func dialPortfolioNode(cctx *cli.Context, s string) (portfolioNode, jsonrpc.ClientCloser, error) {
+	ainfo := cliutil.ParseApiInfo(s)
+
+	addr, err := ainfo.DialArgs()
+	if err != nil {
+		return portfolioNode{}, nil, xerrors.Errorf("parsing node api info %s: %w", ainfo.Addr, err)
+	}
+
+	name, err := ainfo.Host()
+	if err != nil {
+		name = ainfo.Addr
+	}
+
+	napi, closer, err := client.NewFullNodeRPC(cctx.Context, addr, ainfo.AuthHeader())
+	if err != nil {
+		return portfolioNode{}, nil, xerrors.Errorf("connecting to node %s: %w", name, err)
+	}
+
+	return portfolioNode{name: name, api: napi}, closer, nil
+}
+
+func fillPortfolioEntry(ctx context.Context, fapi api.FullNode, store adt.Store, e *PortfolioEntry) error {
+	e.Balance = big.Zero()
+	e.MarketEscrow = big.Zero()
+	e.MarketLocked = big.Zero()
+
+	act, err := fapi.StateGetActor(ctx, e.Address, types.EmptyTSK)
+	if err != nil {
+		if strings.Contains(err.Error(), "actor not found") {
+			// wallets which never received funds don't have an actor yet
+			e.Actor = "-"
+			return nil
+		}
+		return xerrors.Errorf("getting actor: %w", err)
+	}
+
+	e.Actor = path.Base(builtin.ActorNameByCode(act.Code))
+	e.Balance = act.Balance
+
+	if mb, err := fapi.StateMarketBalance(ctx, e.Address, types.EmptyTSK); err == nil {
+		e.MarketEscrow = mb.Escrow
+		e.MarketLocked = mb.Locked
+	}
+
+	if !builtin.IsStorageMinerActor(act.Code) {
+		return nil
+	}
+
+	mas, err := miner.Load(store, act)
+	if err != nil {
+		return xerrors.Errorf("loading miner state: %w", err)
+	}
+
+	locked, err := mas.LockedFunds()
+	if err != nil {
+		return xerrors.Errorf("getting locked funds: %w", err)
+	}
+
+	avail, err := mas.AvailableBalance(act.Balance)
+	if err != nil {
+		return xerrors.Errorf("getting available balance: %w", err)
+	}
+
+	// miner funds are reported separately from wallet balances
+	e.Balance = big.Zero()
+	e.MinerAvailable = avail
+	e.MinerLocked = locked.TotalLockedFunds()
+
+	return nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/filecoin-project/go-address"
@@ -37,6 +38,7 @@ type gatewayDepsAPI interface {
 	Version(context.Context) (api.Version, error)
 	ChainGetBlockMessages(context.Context, cid.Cid) (*api.BlockMessages, error)
 	ChainGetMessage(ctx context.Context, mc cid.Cid) (*types.Message, error)
+	ChainGetParentMessagesStream(ctx context.Context, blockCid cid.Cid, cursor uint64) (<-chan api.ParentMessagesChunk, error)
 	ChainGetNode(ctx context.Context, p string) (*api.IpldObject, error)
 	ChainGetTipSet(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error)
 	ChainGetTipSetByHeight(ctx context.Context, h abi.ChainEpoch, tsk types.TipSetKey) (*types.TipSet, error)
@@ -61,6 +63,7 @@ type gatewayDepsAPI interface {
 	StateWaitMsgLimited(ctx context.Context, msg cid.Cid, confidence uint64, h abi.ChainEpoch) (*api.MsgLookup, error)
 	StateReadState(ctx context.Context, actor address.Address, tsk types.TipSetKey) (*api.ActorState, error)
 	StateMinerPower(context.Context, address.Address, types.TipSetKey) (*api.MinerPower, error)
+	StateMinerSectorsStream(ctx context.Context, addr address.Address, sectorNos *bitfield.BitField, cursor abi.SectorNumber, tsk types.TipSetKey) (<-chan api.MinerSectorsChunk, error)
 	StateMinerFaults(context.Context, address.Address, types.TipSetKey) (bitfield.BitField, error)
 	StateMinerRecoveries(context.Context, address.Address, types.TipSetKey) (bitfield.BitField, error)
 	StateMinerInfo(context.Context, address.Address, types.TipSetKey) (miner.MinerInfo, error)
@@ -74,7 +77,9 @@ type gatewayDepsAPI interface {
 }
 
 type GatewayAPI struct {
-	api                    gatewayDepsAPI
+	api gatewayDepsAPI
+
+	lk                     sync.RWMutex
 	lookbackCap            time.Duration
 	stateWaitLookbackLimit abi.ChainEpoch
 }
@@ -89,6 +94,22 @@ func newGatewayAPI(api gatewayDepsAPI, lookbackCap time.Duration, stateWaitLookb
 	return &GatewayAPI{api: api, lookbackCap: lookbackCap, stateWaitLookbackLimit: stateWaitLookbackLimit}
 }
 
+// SetLookbackLimits changes how far back in the chain callers can look
+func (a *GatewayAPI) SetLookbackLimits(lookbackCap time.Duration, stateWaitLookbackLimit abi.ChainEpoch) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	a.lookbackCap = lookbackCap
+	a.stateWaitLookbackLimit = stateWaitLookbackLimit
+}
+
+func (a *GatewayAPI) lookbackLimits() (time.Duration, abi.ChainEpoch) {
+	a.lk.RLock()
+	defer a.lk.RUnlock()
+
+	return a.lookbackCap, a.stateWaitLookbackLimit
+}
+
 func (a *GatewayAPI) checkTipsetKey(ctx context.Context, tsk types.TipSetKey) error {
 	if tsk.IsEmpty() {
 		return nil
@@ -122,7 +143,7 @@ func (a *GatewayAPI) checkTipsetHeight(ts *types.TipSet, h abi.ChainEpoch) error
 }
 
 func (a *GatewayAPI) checkTimestamp(at time.Time) error {
-	if time.Since(at) > a.lookbackCap {
+	if lookbackCap, _ := a.lookbackLimits(); time.Since(at) > lookbackCap {
 		return ErrLookbackTooLong
 	}
 
@@ -151,6 +172,10 @@ func (a *GatewayAPI) ChainGetMessage(ctx context.Context, mc cid.Cid) (*types.Me
 	return a.api.ChainGetMessage(ctx, mc)
 }
 
+func (a *GatewayAPI) ChainGetParentMessagesStream(ctx context.Context, blockCid cid.Cid, cursor uint64) (<-chan api.ParentMessagesChunk, error) {
+	return a.api.ChainGetParentMessagesStream(ctx, blockCid, cursor)
+}
+
 func (a *GatewayAPI) ChainGetTipSet(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
 	return a.api.ChainGetTipSet(ctx, tsk)
 }
@@ -301,11 +326,13 @@ func (a *GatewayAPI) StateNetworkVersion(ctx context.Context, tsk types.TipSetKe
 }
 
 func (a *GatewayAPI) StateSearchMsg(ctx context.Context, msg cid.Cid) (*api.MsgLookup, error) {
-	return a.api.StateSearchMsgLimited(ctx, msg, a.stateWaitLookbackLimit)
+	_, limit := a.lookbackLimits()
+	return a.api.StateSearchMsgLimited(ctx, msg, limit)
 }
 
 func (a *GatewayAPI) StateWaitMsg(ctx context.Context, msg cid.Cid, confidence uint64) (*api.MsgLookup, error) {
-	return a.api.StateWaitMsgLimited(ctx, msg, confidence, a.stateWaitLookbackLimit)
+	_, limit := a.lookbackLimits()
+	return a.api.StateWaitMsgLimited(ctx, msg, confidence, limit)
 }
 
 func (a *GatewayAPI) StateReadState(ctx context.Context, actor address.Address, tsk types.TipSetKey) (*api.ActorState, error) {
@@ -322,6 +349,13 @@ func (a *GatewayAPI) StateMinerPower(ctx context.Context, m address.Address, tsk
 	return a.api.StateMinerPower(ctx, m, tsk)
 }
 
+func (a *GatewayAPI) StateMinerSectorsStream(ctx context.Context, m address.Address, sectorNos *bitfield.BitField, cursor abi.SectorNumber, tsk types.TipSetKey) (<-chan api.MinerSectorsChunk, error) {
+	if err := a.checkTipsetKey(ctx, tsk); err != nil {
+		return nil, err
+	}
+	return a.api.StateMinerSectorsStream(ctx, m, sectorNos, cursor, tsk)
+}
+
 func (a *GatewayAPI) StateMinerFaults(ctx context.Context, m address.Address, tsk types.TipSetKey) (bitfield.BitField, error) {
 	if err := a.checkTipsetKey(ctx, tsk); err != nil {
 		return bitfield.BitField{}, err
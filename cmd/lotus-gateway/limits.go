@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/time/rate"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+var (
+	ErrMethodNotAllowed = xerrors.New("method not allowed")
+	ErrRateLimited      = xerrors.New("rate limit exceeded")
+)
+
+// limiterSweepInterval is how often limiters of idle callers are dropped
+var limiterSweepInterval = time.Minute
+
+// Limit is a token bucket limit, allowing one request per Rate on average,
+// with bursts of up to Burst requests, which must be at least 1. A zero Rate
+// disables the limit.
+type Limit struct {
+	Rate  config.Duration
+	Burst int
+}
+
+func (l Limit) enabled() bool {
+	return l.Rate > 0
+}
+
+// validate rejects enabled limits which wouldn't let any request through
+func (l Limit) validate() error {
+	if l.Rate < 0 {
+		return xerrors.Errorf("rate can't be negative")
+	}
+	if l.enabled() && l.Burst <= 0 {
+		return xerrors.Errorf("burst must be positive when the rate is set")
+	}
+	return nil
+}
+
+// LimitsConfig configures the methods served by the gateway, and how often
+// callers can call them. It's read from a TOML file, which is reloaded when
+// the gateway receives SIGHUP.
+type LimitsConfig struct {
+	// Methods lists the methods which can be called. All gateway methods can
+	// be called when it's empty.
+	Methods []string
+
+	// IP limits the requests from each IP address, also of callers sending a
+	// token
+	IP Limit
+	// Tokens lists the bearer tokens callers can send in the Authorization
+	// header. Requests with other tokens are rejected.
+	Tokens []string
+	// Token limits the requests made with each token, in addition to the
+	// limit of the IP addresses it's used from
+	Token Limit
+	// MethodLimits limits the calls each caller can make to a method
+	MethodLimits map[string]Limit
+
+	// TrustRealIP makes the gateway identify callers by the X-Real-IP header,
+	// which must only be enabled behind a proxy setting it
+	TrustRealIP bool
+
+	LookbackCap            config.Duration
+	StateWaitLookbackLimit abi.ChainEpoch
+}
+
+func DefaultLimitsConfig() *LimitsConfig {
+	return &LimitsConfig{
+		LookbackCap:            config.Duration(LookbackCap),
+		StateWaitLookbackLimit: StateWaitLookbackLimit,
+	}
+}
+
+// LoadLimitsConfig reads the limits from a TOML file, with defaults for the
+// missing values
+func LoadLimitsConfig(path string) (*LimitsConfig, error) {
+	cfg := DefaultLimitsConfig()
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, xerrors.Errorf("decoding limits config: %w", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, xerrors.Errorf("invalid limits config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func (c *LimitsConfig) validate() error {
+	methods := gatewayMethods()
+
+	for _, m := range c.Methods {
+		if !methods[m] {
+			return xerrors.Errorf("unknown method %s", m)
+		}
+	}
+	for m, l := range c.MethodLimits {
+		if !methods[m] {
+			return xerrors.Errorf("unknown method %s in method limits", m)
+		}
+		if err := l.validate(); err != nil {
+			return xerrors.Errorf("limit of method %s: %w", m, err)
+		}
+	}
+
+	if err := c.IP.validate(); err != nil {
+		return xerrors.Errorf("IP limit: %w", err)
+	}
+	if err := c.Token.validate(); err != nil {
+		return xerrors.Errorf("token limit: %w", err)
+	}
+
+	if c.LookbackCap <= 0 {
+		return xerrors.Errorf("lookback cap must be positive")
+	}
+	if c.StateWaitLookbackLimit <= 0 {
+		return xerrors.Errorf("state wait lookback limit must be positive")
+	}
+
+	return nil
+}
+
+// gatewayMethods returns the names of all gateway API methods
+func gatewayMethods() map[string]bool {
+	out := map[string]bool{}
+
+	rint := reflect.TypeOf(apistruct.GatewayStruct{}.Internal)
+	for f := 0; f < rint.NumField(); f++ {
+		out[rint.Field(f).Name] = true
+	}
+
+	return out
+}
+
+type callerKey struct{}
+
+// caller identifies who made a request
+type caller struct {
+	ip    string
+	token string
+}
+
+type limiter struct {
+	*rate.Limiter
+	lim  Limit
+	last time.Time
+}
+
+// idle returns whether the bucket of the limiter refilled since it was last
+// used, so it can be replaced by a new one
+func (l *limiter) idle(now time.Time) bool {
+	return now.Sub(l.last) >= time.Duration(l.lim.Rate)*time.Duration(l.lim.Burst)
+}
+
+type limiters map[string]*limiter
+
+func (ls limiters) allow(key string, lim Limit, now time.Time) bool {
+	if !lim.enabled() {
+		return true
+	}
+
+	l, ok := ls[key]
+	if !ok {
+		l = &limiter{Limiter: rate.NewLimiter(rate.Every(time.Duration(lim.Rate)), lim.Burst), lim: lim}
+		ls[key] = l
+	}
+	l.last = now
+
+	return l.AllowN(now, 1)
+}
+
+func (ls limiters) sweep(now time.Time) {
+	for key, l := range ls {
+		if l.idle(now) {
+			delete(ls, key)
+		}
+	}
+}
+
+// Limits enforces a LimitsConfig on the calls made to the gateway
+type Limits struct {
+	lk  sync.Mutex
+	cfg *LimitsConfig
+
+	allowed map[string]bool
+	tokens  map[string]bool
+	callers limiters
+	methods map[string]limiters
+
+	lastSweep time.Time
+}
+
+func NewLimits(cfg *LimitsConfig) *Limits {
+	l := &Limits{}
+	l.SetConfig(cfg)
+	return l
+}
+
+// SetConfig replaces the limits config. Callers start with full buckets
+// under the new limits.
+func (l *Limits) SetConfig(cfg *LimitsConfig) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	l.cfg = cfg
+
+	l.allowed = map[string]bool{}
+	for _, m := range cfg.Methods {
+		l.allowed[m] = true
+	}
+
+	l.tokens = map[string]bool{}
+	for _, t := range cfg.Tokens {
+		l.tokens[t] = true
+	}
+
+	l.callers = limiters{}
+	l.methods = map[string]limiters{}
+}
+
+// ValidToken returns whether callers can send the token
+func (l *Limits) ValidToken(token string) bool {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	return l.tokens[token]
+}
+
+func (l *Limits) Config() *LimitsConfig {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	return l.cfg
+}
+
+// Allow checks whether the caller in ctx can call the method now
+func (l *Limits) Allow(ctx context.Context, method string) error {
+	c, _ := ctx.Value(callerKey{}).(caller)
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if len(l.allowed) > 0 && !l.allowed[method] {
+		return xerrors.Errorf("%s: %w", method, ErrMethodNotAllowed)
+	}
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= limiterSweepInterval {
+		l.callers.sweep(now)
+		for m, ls := range l.methods {
+			ls.sweep(now)
+			if len(ls) == 0 {
+				delete(l.methods, m)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	key := "ip:" + c.ip
+	if !l.callers.allow(key, l.cfg.IP, now) {
+		return xerrors.Errorf("caller limit: %w", ErrRateLimited)
+	}
+
+	// tokens are checked by Handler, callers sending one are also limited by
+	// the token, across the IPs it's used from
+	if c.token != "" {
+		key = "token:" + c.token
+		if !l.callers.allow(key, l.cfg.Token, now) {
+			return xerrors.Errorf("token limit: %w", ErrRateLimited)
+		}
+	}
+
+	if ml, ok := l.cfg.MethodLimits[method]; ok && ml.enabled() {
+		if l.methods[method] == nil {
+			l.methods[method] = limiters{}
+		}
+		if !l.methods[method].allow(key, ml, now) {
+			return xerrors.Errorf("%s limit: %w", method, ErrRateLimited)
+		}
+	}
+
+	return nil
+}
+
+// Handler records the caller of each request in the request context, so calls
+// can be limited per caller
+func (l *Limits) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var c caller
+
+		if l.Config().TrustRealIP {
+			c.ip = r.Header.Get("X-Real-IP")
+		}
+		if c.ip == "" {
+			h, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				log.Errorf("could not get ip from: %s, err: %s", r.RemoteAddr, err)
+			}
+			c.ip = h
+		}
+
+		if token := r.Header.Get("Authorization"); token != "" {
+			c.token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+			if !l.ValidToken(c.token) {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), callerKey{}, c)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// LimitedGatewayAPI checks every call to the gateway against the limits
+func LimitedGatewayAPI(a api.GatewayAPI, l *Limits) api.GatewayAPI {
+	var out apistruct.GatewayStruct
+
+	rint := reflect.ValueOf(&out.Internal).Elem()
+	ra := reflect.ValueOf(a)
+
+	for f := 0; f < rint.NumField(); f++ {
+		field := rint.Type().Field(f)
+		fn := ra.MethodByName(field.Name)
+
+		rint.Field(f).Set(reflect.MakeFunc(field.Type, func(args []reflect.Value) (results []reflect.Value) {
+			ctx := args[0].Interface().(context.Context)
+			if err := l.Allow(ctx, field.Name); err != nil {
+				res := make([]reflect.Value, field.Type.NumOut())
+				for i := range res[:len(res)-1] {
+					res[i] = reflect.Zero(field.Type.Out(i))
+				}
+				res[len(res)-1] = reflect.ValueOf(&err).Elem()
+				return res
+			}
+
+			return fn.Call(args)
+		}))
+	}
+
+	return &out
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+func callerCtx(ip, token string) context.Context {
+	return context.WithValue(context.Background(), callerKey{}, caller{ip: ip, token: token})
+}
+
+func TestLimits(t *testing.T) {
+	cfg := DefaultLimitsConfig()
+	cfg.Methods = []string{"ChainHead", "StateGetActor"}
+	cfg.IP = Limit{Rate: config.Duration(time.Hour), Burst: 3}
+	cfg.Tokens = []string{"tok"}
+	cfg.Token = Limit{Rate: config.Duration(time.Hour), Burst: 5}
+	cfg.MethodLimits = map[string]Limit{
+		"StateGetActor": {Rate: config.Duration(time.Hour), Burst: 1},
+	}
+
+	l := NewLimits(cfg)
+
+	err := l.Allow(callerCtx("1.2.3.4", ""), "MpoolPush")
+	require.True(t, xerrors.Is(err, ErrMethodNotAllowed))
+
+	// per method limit
+	require.NoError(t, l.Allow(callerCtx("1.2.3.4", ""), "StateGetActor"))
+	err = l.Allow(callerCtx("1.2.3.4", ""), "StateGetActor")
+	require.True(t, xerrors.Is(err, ErrRateLimited))
+
+	// per IP limit
+	require.NoError(t, l.Allow(callerCtx("1.2.3.4", ""), "ChainHead"))
+	err = l.Allow(callerCtx("1.2.3.4", ""), "ChainHead")
+	require.True(t, xerrors.Is(err, ErrRateLimited))
+	require.NoError(t, l.Allow(callerCtx("5.6.7.8", ""), "ChainHead"))
+
+	// a token doesn't lift the limit of the IP it's used from
+	err = l.Allow(callerCtx("1.2.3.4", "tok"), "ChainHead")
+	require.True(t, xerrors.Is(err, ErrRateLimited))
+
+	// tokens are also limited across the IPs they are used from
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		for i := 0; i < 2; i++ {
+			require.NoError(t, l.Allow(callerCtx(ip, "tok"), "ChainHead"))
+		}
+	}
+	require.NoError(t, l.Allow(callerCtx("10.0.0.3", "tok"), "ChainHead"))
+	err = l.Allow(callerCtx("10.0.0.4", "tok"), "ChainHead")
+	require.True(t, xerrors.Is(err, ErrRateLimited))
+	require.NoError(t, l.Allow(callerCtx("10.0.0.4", ""), "ChainHead"))
+
+	// new config resets the limits
+	l.SetConfig(DefaultLimitsConfig())
+	for i := 0; i < 10; i++ {
+		require.NoError(t, l.Allow(callerCtx("1.2.3.4", ""), "MpoolPush"))
+	}
+}
+
+func TestLimitsHandlerToken(t *testing.T) {
+	cfg := DefaultLimitsConfig()
+	cfg.Tokens = []string{"tok"}
+	l := NewLimits(cfg)
+
+	var called int
+	srv := httptest.NewServer(l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+	})))
+	defer srv.Close()
+
+	for token, status := range map[string]int{
+		"":        http.StatusOK,
+		"tok":     http.StatusOK,
+		"unknown": http.StatusUnauthorized,
+	} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, status, resp.StatusCode, token)
+	}
+	require.Equal(t, 2, called)
+}
+
+func TestLimitsEvictIdle(t *testing.T) {
+	cfg := DefaultLimitsConfig()
+	cfg.IP = Limit{Rate: config.Duration(time.Millisecond), Burst: 1}
+	l := NewLimits(cfg)
+
+	require.NoError(t, l.Allow(callerCtx("1.2.3.4", ""), "ChainHead"))
+	require.NoError(t, l.Allow(callerCtx("5.6.7.8", ""), "ChainHead"))
+	require.Len(t, l.callers, 2)
+
+	time.Sleep(10 * time.Millisecond)
+	l.lastSweep = time.Time{}
+
+	require.NoError(t, l.Allow(callerCtx("1.2.3.4", ""), "ChainHead"))
+	require.Len(t, l.callers, 1)
+}
+
+func TestLoadLimitsConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-limits")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	path := filepath.Join(dir, "limits.toml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+Methods = ["ChainHead"]
+LookbackCap = "1h"
+
+[IP]
+  Rate = "1s"
+  Burst = 10
+
+[MethodLimits.ChainHead]
+  Rate = "10s"
+  Burst = 1
+`), 0644))
+
+	cfg, err := LoadLimitsConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ChainHead"}, cfg.Methods)
+	require.Equal(t, config.Duration(time.Hour), cfg.LookbackCap)
+	require.Equal(t, StateWaitLookbackLimit, cfg.StateWaitLookbackLimit)
+	require.Equal(t, Limit{Rate: config.Duration(time.Second), Burst: 10}, cfg.IP)
+	require.Equal(t, 1, cfg.MethodLimits["ChainHead"].Burst)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`Methods = ["WalletSign"]`), 0644))
+	_, err = LoadLimitsConfig(path)
+	require.Error(t, err)
+
+	// a rate without a burst would reject all requests
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+[MethodLimits.ChainHead]
+  Rate = "10s"
+`), 0644))
+	_, err = LoadLimitsConfig(path)
+	require.Error(t, err)
+}
@@ -5,6 +5,9 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/filecoin-project/go-jsonrpc"
 	"go.opencensus.io/tag"
@@ -65,6 +68,10 @@ var runCmd = &cli.Command{
 			Name:  "api-max-req-size",
 			Usage: "maximum API request size accepted by the JSON RPC server",
 		},
+		&cli.StringFlag{
+			Name:  "limits",
+			Usage: "path to a TOML file with method allowlists, rate limits, and lookback limits, reloaded on SIGHUP",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		log.Info("Starting lotus gateway")
@@ -86,6 +93,45 @@ var runCmd = &cli.Command{
 		}
 		defer closer()
 
+		limitsCfg := DefaultLimitsConfig()
+		if path := cctx.String("limits"); path != "" {
+			limitsCfg, err = LoadLimitsConfig(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		gapi := NewGatewayAPI(api)
+		limits := NewLimits(limitsCfg)
+		applyLimits := func(cfg *LimitsConfig) {
+			limits.SetConfig(cfg)
+			gapi.SetLookbackLimits(time.Duration(cfg.LookbackCap), cfg.StateWaitLookbackLimit)
+		}
+		applyLimits(limitsCfg)
+
+		if path := cctx.String("limits"); path != "" {
+			sighup := make(chan os.Signal, 1)
+			signal.Notify(sighup, syscall.SIGHUP)
+
+			go func() {
+				for {
+					select {
+					case <-sighup:
+						cfg, err := LoadLimitsConfig(path)
+						if err != nil {
+							log.Errorf("reloading limits: %+v", err)
+							continue
+						}
+						applyLimits(cfg)
+						log.Infof("reloaded limits from %s", path)
+					case <-ctx.Done():
+						signal.Stop(sighup)
+						return
+					}
+				}
+			}()
+		}
+
 		address := cctx.String("listen")
 		mux := mux.NewRouter()
 
@@ -96,9 +142,9 @@ var runCmd = &cli.Command{
 			serverOptions = append(serverOptions, jsonrpc.WithMaxRequestSize(int64(maxRequestSize)))
 		}
 		rpcServer := jsonrpc.NewServer(serverOptions...)
-		rpcServer.Register("Filecoin", metrics.MetricedGatewayAPI(NewGatewayAPI(api)))
+		rpcServer.Register("Filecoin", metrics.MetricedGatewayAPI(LimitedGatewayAPI(gapi, limits)))
 
-		mux.Handle("/rpc/v0", rpcServer)
+		mux.Handle("/rpc/v0", limits.Handler(rpcServer))
 		mux.PathPrefix("/").Handler(http.DefaultServeMux)
 
 		/*ah := &auth.Handler{
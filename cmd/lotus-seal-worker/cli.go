@@ -1,6 +1,9 @@
 package main
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 
@@ -49,3 +52,64 @@ var waitQuietCmd = &cli.Command{
 		return api.WaitQuiet(ctx)
 	},
 }
+
+var drainCmd = &cli.Command{
+	Name:  "drain",
+	Usage: "Stop taking new tasks from the miner, letting running tasks finish",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "cancel",
+			Usage: "start taking new tasks again",
+		},
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "wait until all tasks have finished",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetWorkerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		sess, err := api.ProcessSession(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting session: %w", err)
+		}
+
+		nodeApi, ncloser, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return xerrors.Errorf("getting miner api: %w", err)
+		}
+		defer ncloser()
+
+		if err := nodeApi.WorkerDrain(ctx, sess, !cctx.Bool("cancel")); err != nil {
+			return xerrors.Errorf("WorkerDrain: %w", err)
+		}
+
+		if cctx.Bool("cancel") || !cctx.Bool("wait") {
+			return nil
+		}
+
+		for {
+			stats, err := nodeApi.WorkerStats(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting worker stats: %w", err)
+			}
+
+			if stats[sess].Drained {
+				fmt.Println("worker drained")
+				return nil
+			}
+
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	},
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var certKey = datastore.NewKey("/worker-auth/certificate")
+
+// setupMinerAuth makes the worker authenticate to the miner with its identity
+// certificate. When the worker doesn't have a valid certificate and a join
+// token is given, the worker joins the miner to obtain one.
+//
+// The certificate replaces the token in MINER_API_INFO, so that all miner API
+// clients created by the worker use it, and is returned. Without a
+// certificate, the token from MINER_API_INFO is used as is.
+func setupMinerAuth(ctx context.Context, cctx *cli.Context, ds datastore.Batching) (string, error) {
+	cert, err := ds.Get(certKey)
+	if err != nil && err != datastore.ErrNotFound {
+		return "", xerrors.Errorf("loading worker certificate: %w", err)
+	}
+
+	if cctx.IsSet("join-token") {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx, lcli.StorageMinerUseHttp)
+		if err != nil {
+			return "", xerrors.Errorf("connecting to miner: %w", err)
+		}
+		defer closer()
+
+		if cert != nil {
+			if _, err := nodeApi.AuthVerify(ctx, string(cert)); err != nil {
+				log.Warnf("worker certificate is no longer valid, joining again: %s", err)
+				cert = nil
+			} else {
+				log.Info("worker already joined the miner, ignoring join token")
+			}
+		}
+
+		if cert == nil {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return "", xerrors.Errorf("getting hostname: %w", err)
+			}
+
+			cred, err := nodeApi.WorkerJoin(ctx, cctx.String("join-token"), hostname)
+			if err != nil {
+				return "", xerrors.Errorf("joining miner: %w", err)
+			}
+
+			cert = []byte(cred.Certificate)
+			if err := ds.Put(certKey, cert); err != nil {
+				return "", xerrors.Errorf("storing worker certificate: %w", err)
+			}
+
+			log.Infow("joined miner", "identity", cred.ID)
+		}
+	}
+
+	if cert == nil {
+		return "", nil
+	}
+
+	ainfo, err := lcli.GetAPIInfo(cctx, repo.StorageMiner)
+	if err != nil {
+		return "", xerrors.Errorf("could not get miner API info: %w", err)
+	}
+
+	return string(cert), os.Setenv("MINER_API_INFO", string(cert)+":"+ainfo.Addr)
+}
+
+// certRenewInterval is how often workers renew their certificate, well within
+// its validity
+const certRenewInterval = time.Hour
+
+// renewCertificate keeps the worker certificate valid while the worker runs
+func renewCertificate(ctx context.Context, nodeApi api.StorageMiner, cert string) {
+	renew := func() {
+		expires, err := nodeApi.WorkerRenew(ctx, cert)
+		if err != nil {
+			log.Errorf("renewing worker certificate: %+v", err)
+			return
+		}
+		log.Debugw("renewed worker certificate", "expires", expires)
+	}
+
+	renew()
+
+	tick := time.NewTicker(certRenewInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			renew()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// verifyWithCertificate verifies tokens with the miner. The certificate of
+// the worker itself is valid for all worker API permissions, so that the
+// token stored in the worker repo keeps working for the worker CLI.
+func verifyWithCertificate(nodeApi api.StorageMiner, cert string) func(ctx context.Context, token string) ([]auth.Permission, error) {
+	return func(ctx context.Context, token string) ([]auth.Permission, error) {
+		perms, err := nodeApi.AuthVerify(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if cert != "" && token == cert {
+			return apistruct.AllPermissions, nil
+		}
+		return perms, nil
+	}
+}
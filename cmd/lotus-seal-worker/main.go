@@ -9,9 +9,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/docker/go-units"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/ipfs/go-datastore/namespace"
@@ -59,6 +61,7 @@ func main() {
 		storageCmd,
 		setCmd,
 		waitQuietCmd,
+		drainCmd,
 		tasksCmd,
 	}
 
@@ -126,6 +129,16 @@ var runCmd = &cli.Command{
 			Usage: "enable addpiece",
 			Value: true,
 		},
+		&cli.BoolFlag{
+			Name:  "windowpost",
+			Usage: "enable window PoSt proving, disables sealing tasks (sectors are read from storage paths attached to the worker)",
+			Value: false,
+		},
+		&cli.BoolFlag{
+			Name:  "winningpost",
+			Usage: "enable winning PoSt proving, disables sealing tasks (sectors are read from storage paths attached to the worker)",
+			Value: false,
+		},
 		&cli.BoolFlag{
 			Name:  "precommit1",
 			Usage: "enable precommit1 (32G sectors: 1 core, 128GiB Memory)",
@@ -136,6 +149,11 @@ var runCmd = &cli.Command{
 			Usage: "enable unsealing (32G sectors: 1 core, 128GiB Memory)",
 			Value: true,
 		},
+		&cli.BoolFlag{
+			Name:  "regen-cache",
+			Usage: "enable regenerating lost sector cache files (same resources as precommit1)",
+			Value: true,
+		},
 		&cli.BoolFlag{
 			Name:  "precommit2",
 			Usage: "enable precommit2 (32G sectors: all cores, 96GiB Memory)",
@@ -146,11 +164,38 @@ var runCmd = &cli.Command{
 			Usage: "enable commit (32G sectors: all cores or GPUs, 128GiB Memory + 64GiB swap)",
 			Value: true,
 		},
+		&cli.StringSliceFlag{
+			Name:  "group",
+			Usage: "worker group label; tasks of sectors sealed by workers in a group are kept on workers in that group (can be repeated)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "task-limit",
+			Usage: "maximum number of concurrently running tasks of a type, e.g. PC1=2 (can also be set with [TASK]_MAX_CONCURRENT env vars)",
+		},
 		&cli.IntFlag{
 			Name:  "parallel-fetch-limit",
 			Usage: "maximum fetch operations to run in parallel",
 			Value: 5,
 		},
+		&cli.IntFlag{
+			Name:  "parallel-fetch-path-limit",
+			Usage: "maximum fetch operations into a single storage path to run in parallel (0 for no limit)",
+		},
+		&cli.StringFlag{
+			Name:  "fetch-bandwidth",
+			Usage: "maximum total fetch bandwidth per second, e.g. 500MiB (0 for no limit)",
+			Value: "0",
+		},
+		&cli.StringFlag{
+			Name:  "fetch-path-bandwidth",
+			Usage: "maximum fetch bandwidth per second into a single storage path, e.g. 200MiB (0 for no limit)",
+			Value: "0",
+		},
+		&cli.StringFlag{
+			Name:    "join-token",
+			Usage:   "single-use token from 'lotus-miner worker token create'; used to obtain a worker identity certificate when the worker doesn't have a valid one",
+			EnvVars: []string{"LOTUS_WORKER_JOIN_TOKEN"},
+		},
 		&cli.StringFlag{
 			Name:  "timeout",
 			Usage: "used when 'listen' is unspecified. must be a valid duration recognized by golang's time.ParseDuration function",
@@ -176,86 +221,6 @@ var runCmd = &cli.Command{
 			}
 		}
 
-		// Connect to storage-miner
-		ctx := lcli.ReqContext(cctx)
-
-		var nodeApi api.StorageMiner
-		var closer func()
-		var err error
-		for {
-			nodeApi, closer, err = lcli.GetStorageMinerAPI(cctx, lcli.StorageMinerUseHttp)
-			if err == nil {
-				_, err = nodeApi.Version(ctx)
-				if err == nil {
-					break
-				}
-			}
-			fmt.Printf("\r\x1b[0KConnecting to miner API... (%s)", err)
-			time.Sleep(time.Second)
-			continue
-		}
-
-		defer closer()
-		ctx, cancel := context.WithCancel(ctx)
-		defer cancel()
-
-		// Register all metric views
-		if err := view.Register(
-			metrics.DefaultViews...,
-		); err != nil {
-			log.Fatalf("Cannot register the view: %v", err)
-		}
-
-		v, err := nodeApi.Version(ctx)
-		if err != nil {
-			return err
-		}
-		if v.APIVersion != build.MinerAPIVersion {
-			return xerrors.Errorf("lotus-miner API version doesn't match: expected: %s", api.Version{APIVersion: build.MinerAPIVersion})
-		}
-		log.Infof("Remote version %s", v)
-
-		// Check params
-
-		act, err := nodeApi.ActorAddress(ctx)
-		if err != nil {
-			return err
-		}
-		ssize, err := nodeApi.ActorSectorSize(ctx, act)
-		if err != nil {
-			return err
-		}
-
-		if cctx.Bool("commit") {
-			if err := paramfetch.GetParams(ctx, build.ParametersJSON(), uint64(ssize)); err != nil {
-				return xerrors.Errorf("get params: %w", err)
-			}
-		}
-
-		var taskTypes []sealtasks.TaskType
-
-		taskTypes = append(taskTypes, sealtasks.TTFetch, sealtasks.TTCommit1, sealtasks.TTFinalize)
-
-		if cctx.Bool("addpiece") {
-			taskTypes = append(taskTypes, sealtasks.TTAddPiece)
-		}
-		if cctx.Bool("precommit1") {
-			taskTypes = append(taskTypes, sealtasks.TTPreCommit1)
-		}
-		if cctx.Bool("unseal") {
-			taskTypes = append(taskTypes, sealtasks.TTUnseal)
-		}
-		if cctx.Bool("precommit2") {
-			taskTypes = append(taskTypes, sealtasks.TTPreCommit2)
-		}
-		if cctx.Bool("commit") {
-			taskTypes = append(taskTypes, sealtasks.TTCommit2)
-		}
-
-		if len(taskTypes) == 0 {
-			return xerrors.Errorf("no task types specified")
-		}
-
 		// Open repo
 
 		repoPath := cctx.String(FlagWorkerRepo)
@@ -332,6 +297,127 @@ var runCmd = &cli.Command{
 			return err
 		}
 
+		ctx := lcli.ReqContext(cctx)
+
+		cert, err := setupMinerAuth(ctx, cctx, ds)
+		if err != nil {
+			return xerrors.Errorf("setting up miner authentication: %w", err)
+		}
+
+		// Connect to storage-miner
+
+		var nodeApi api.StorageMiner
+		var closer func()
+		for {
+			nodeApi, closer, err = lcli.GetStorageMinerAPI(cctx, lcli.StorageMinerUseHttp)
+			if err == nil {
+				_, err = nodeApi.Version(ctx)
+				if err == nil {
+					break
+				}
+			}
+			fmt.Printf("\r\x1b[0KConnecting to miner API... (%s)", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		defer closer()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// Register all metric views
+		if err := view.Register(
+			metrics.DefaultViews...,
+		); err != nil {
+			log.Fatalf("Cannot register the view: %v", err)
+		}
+
+		v, err := nodeApi.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if v.APIVersion != build.MinerAPIVersion {
+			return xerrors.Errorf("lotus-miner API version doesn't match: expected: %s", api.Version{APIVersion: build.MinerAPIVersion})
+		}
+		log.Infof("Remote version %s", v)
+
+		// Check params
+
+		act, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+		ssize, err := nodeApi.ActorSectorSize(ctx, act)
+		if err != nil {
+			return err
+		}
+
+		proving := cctx.Bool("windowpost") || cctx.Bool("winningpost")
+
+		if cctx.Bool("commit") || proving {
+			if err := paramfetch.GetParams(ctx, build.ParametersJSON(), uint64(ssize)); err != nil {
+				return xerrors.Errorf("get params: %w", err)
+			}
+		}
+
+		var taskTypes []sealtasks.TaskType
+
+		if proving {
+			// proving workers don't seal, so that sealing doesn't compete with
+			// proofs for resources
+			if cctx.Bool("windowpost") {
+				taskTypes = append(taskTypes, sealtasks.TTGenerateWindowPoSt)
+			}
+			if cctx.Bool("winningpost") {
+				taskTypes = append(taskTypes, sealtasks.TTGenerateWinningPoSt)
+			}
+		} else {
+			taskTypes = append(taskTypes, sealtasks.TTFetch, sealtasks.TTCommit1, sealtasks.TTFinalize)
+
+			if cctx.Bool("addpiece") {
+				taskTypes = append(taskTypes, sealtasks.TTAddPiece)
+			}
+			if cctx.Bool("precommit1") {
+				taskTypes = append(taskTypes, sealtasks.TTPreCommit1)
+			}
+			if cctx.Bool("unseal") {
+				taskTypes = append(taskTypes, sealtasks.TTUnseal)
+			}
+			if cctx.Bool("regen-cache") {
+				taskTypes = append(taskTypes, sealtasks.TTRegenCache)
+			}
+			if cctx.Bool("precommit2") {
+				taskTypes = append(taskTypes, sealtasks.TTPreCommit2)
+			}
+			if cctx.Bool("commit") {
+				taskTypes = append(taskTypes, sealtasks.TTCommit2)
+			}
+		}
+
+		if len(taskTypes) == 0 {
+			return xerrors.Errorf("no task types specified")
+		}
+
+		taskLimits := map[sealtasks.TaskType]int{}
+		for _, l := range cctx.StringSlice("task-limit") {
+			parts := strings.SplitN(l, "=", 2)
+			if len(parts) != 2 {
+				return xerrors.Errorf("invalid task limit '%s', expected [TASK]=[LIMIT]", l)
+			}
+
+			tt, ok := sealtasks.FromShort(strings.ToUpper(parts[0]))
+			if !ok {
+				return xerrors.Errorf("unknown task type in task limit '%s'", l)
+			}
+
+			limit, err := strconv.Atoi(parts[1])
+			if err != nil || limit < 0 {
+				return xerrors.Errorf("invalid task limit '%s'", l)
+			}
+
+			taskLimits[tt] = limit
+		}
+
 		log.Info("Opening local storage; connecting to master")
 		const unspecifiedAddress = "0.0.0.0"
 		address := cctx.String("listen")
@@ -361,13 +447,26 @@ var runCmd = &cli.Command{
 			return xerrors.Errorf("could not get api info: %w", err)
 		}
 
-		remote := stores.NewRemote(localStore, nodeApi, sminfo.AuthHeader(), cctx.Int("parallel-fetch-limit"))
+		fetchBw, err := units.RAMInBytes(cctx.String("fetch-bandwidth"))
+		if err != nil {
+			return xerrors.Errorf("parsing fetch-bandwidth: %w", err)
+		}
+		fetchPathBw, err := units.RAMInBytes(cctx.String("fetch-path-bandwidth"))
+		if err != nil {
+			return xerrors.Errorf("parsing fetch-path-bandwidth: %w", err)
+		}
+
+		remote := stores.NewRemote(localStore, nodeApi, sminfo.AuthHeader(), cctx.Int("parallel-fetch-limit"), stores.FetchLimits{
+			PathConcurrency: cctx.Int("parallel-fetch-path-limit"),
+			Bandwidth:       fetchBw,
+			PathBandwidth:   fetchPathBw,
+		})
 
 		fh := &stores.FetchHandler{Local: localStore}
 		remoteHandler := func(w http.ResponseWriter, r *http.Request) {
-			if !auth.HasPerm(r.Context(), nil, apistruct.PermAdmin) {
+			if !auth.HasPerm(r.Context(), nil, apistruct.PermWorker) {
 				w.WriteHeader(401)
-				_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing admin permission"})
+				_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing worker permission"})
 				return
 			}
 
@@ -380,8 +479,10 @@ var runCmd = &cli.Command{
 
 		workerApi := &worker{
 			LocalWorker: sectorstorage.NewLocalWorker(sectorstorage.WorkerConfig{
-				TaskTypes: taskTypes,
-				NoSwap:    cctx.Bool("no-swap"),
+				TaskTypes:  taskTypes,
+				NoSwap:     cctx.Bool("no-swap"),
+				TaskLimits: taskLimits,
+				Groups:     cctx.StringSlice("group"),
 			}, remote, localStore, nodeApi, nodeApi, wsts),
 			localStore: localStore,
 			ls:         lr,
@@ -401,7 +502,7 @@ var runCmd = &cli.Command{
 		mux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
 
 		ah := &auth.Handler{
-			Verify: nodeApi.AuthVerify,
+			Verify: verifyWithCertificate(nodeApi, cert),
 			Next:   mux.ServeHTTP,
 		}
 
@@ -453,6 +554,10 @@ var runCmd = &cli.Command{
 			}
 		}
 
+		if cert != "" {
+			go renewCertificate(ctx, nodeApi, cert)
+		}
+
 		minerSession, err := nodeApi.Session(ctx)
 		if err != nil {
 			return xerrors.Errorf("getting miner session: %w", err)
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/mitchellh/go-homedir"
@@ -12,6 +13,7 @@ import (
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/lib/profile"
 )
 
 type worker struct {
@@ -64,6 +66,10 @@ func (w *worker) WaitQuiet(ctx context.Context) error {
 	return nil
 }
 
+func (w *worker) ProfileCapture(ctx context.Context, name string, duration time.Duration) ([]byte, error) {
+	return profile.Capture(ctx, name, duration)
+}
+
 func (w *worker) ProcessSession(ctx context.Context) (uuid.UUID, error) {
 	return w.LocalWorker.Session(ctx)
 }
@@ -46,6 +46,10 @@ var storageAttachCmd = &cli.Command{
 			Name:  "store",
 			Usage: "(for init) use path for long-term storage",
 		},
+		&cli.StringFlag{
+			Name:  "failure-domain",
+			Usage: "(for init) label of the disk / host the path is on, sector replicas are kept in distinct failure domains",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		nodeApi, closer, err := lcli.GetWorkerAPI(cctx)
@@ -84,6 +88,8 @@ var storageAttachCmd = &cli.Command{
 				Weight:   cctx.Uint64("weight"),
 				CanSeal:  cctx.Bool("seal"),
 				CanStore: cctx.Bool("store"),
+
+				FailureDomain: cctx.String("failure-domain"),
 			}
 
 			if !(cfg.CanStore || cfg.CanSeal) {
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/exitcode"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+// backfillEpoch holds the data extracted from the execution of a tipset
+type backfillEpoch struct {
+	// Height of the executed tipset, where the messages were included
+	Height abi.ChainEpoch
+	TipSet types.TipSetKey
+	// State root after executing the tipset
+	StateRoot cid.Cid
+
+	Messages []backfillMessage
+	Actors   []backfillActorChange
+}
+
+type backfillMessage struct {
+	Cid cid.Cid
+
+	From       address.Address
+	To         address.Address
+	Nonce      uint64
+	Value      types.BigInt
+	GasLimit   int64
+	GasFeeCap  types.BigInt
+	GasPremium types.BigInt
+	Method     abi.MethodNum
+	Params     []byte
+	// Params decoded with the method parameter type of the receiving actor,
+	// if known
+	DecodedParams json.RawMessage `json:",omitempty"`
+
+	ExitCode exitcode.ExitCode
+	GasUsed  int64
+	Return   []byte
+}
+
+type backfillActorChange struct {
+	Address string
+	Code    cid.Cid
+	Head    cid.Cid
+	Nonce   uint64
+	Balance types.BigInt
+}
+
+type backfillCheckpoint struct {
+	// All tipsets up to this height were written to the sink
+	Done abi.ChainEpoch
+}
+
+var backfillCmd = &cli.Command{
+	Name:  "backfill",
+	Usage: "Extract messages, receipts and actor changes of a range of epochs into an external sink",
+	Description: `Executed tipsets in the [from, to] height range are read from the node, and for
+each one, its messages with receipts and decoded parameters, and the actors changed
+by executing it are written to the sink. Supported sinks:
+
+  file:<path>            newline-delimited JSON, one line per epoch
+  postgres:<conn-string> backfill_messages and backfill_actor_changes tables
+  kafka:<topic-url>      a record per epoch, keyed by height, produced through a
+                         Kafka REST proxy, e.g. kafka:http://localhost:8082/topics/backfill
+
+With --checkpoint, the progress is saved, and an interrupted backfill continues
+where it stopped.`,
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "from",
+			Usage: "first epoch to backfill",
+		},
+		&cli.Int64Flag{
+			Name:  "to",
+			Usage: "last epoch to backfill (default: parent of the current head)",
+		},
+		&cli.StringFlag{
+			Name:     "sink",
+			Usage:    "where to write the data, e.g. file:./out.ndjson",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "checkpoint",
+			Usage: "file to save progress to",
+		},
+		&cli.IntFlag{
+			Name:  "workers",
+			Usage: "number of epochs processed in parallel",
+			Value: 8,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		fapi, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		head, err := fapi.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		from := abi.ChainEpoch(cctx.Int64("from"))
+		to := head.Height() - 1
+		if cctx.IsSet("to") {
+			to = abi.ChainEpoch(cctx.Int64("to"))
+		}
+		if to >= head.Height() {
+			return xerrors.Errorf("epoch %d isn't executed yet (head %d)", to, head.Height())
+		}
+
+		cpPath := cctx.String("checkpoint")
+		if cpPath != "" {
+			cp, err := loadBackfillCheckpoint(cpPath)
+			if err != nil {
+				return err
+			}
+			if cp != nil && cp.Done >= from {
+				log.Infow("continuing from checkpoint", "done", cp.Done)
+				from = cp.Done + 1
+			}
+		}
+
+		if from > to {
+			log.Info("nothing to backfill")
+			return nil
+		}
+
+		sink, err := openBackfillSink(ctx, cctx.String("sink"))
+		if err != nil {
+			return xerrors.Errorf("opening sink: %w", err)
+		}
+		defer sink.Close() // nolint
+
+		b := &backfiller{
+			api:    fapi,
+			head:   head.Key(),
+			sink:   sink,
+			cpPath: cpPath,
+			from:   from,
+			done:   map[abi.ChainEpoch]struct{}{},
+			lowest: from - 1,
+		}
+
+		return b.run(ctx, from, to, cctx.Int("workers"))
+	},
+}
+
+type backfiller struct {
+	api    api.FullNode
+	head   types.TipSetKey
+	sink   backfillSink
+	cpPath string
+	from   abi.ChainEpoch
+
+	lk      sync.Mutex
+	done    map[abi.ChainEpoch]struct{}
+	lowest  abi.ChainEpoch // all epochs up to this one are done
+	lastCp  time.Time
+	started time.Time
+}
+
+func (b *backfiller) run(ctx context.Context, from, to abi.ChainEpoch, workers int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	b.started = time.Now()
+
+	heights := make(chan abi.ChainEpoch)
+	errs := make(chan error, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for h := range heights {
+				if err := b.process(ctx, h); err != nil {
+					errs <- xerrors.Errorf("epoch %d: %w", h, err)
+					cancel()
+					return
+				}
+				b.markDone(h)
+			}
+		}()
+	}
+
+feed:
+	for h := from; h <= to; h++ {
+		select {
+		case heights <- h:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(heights)
+	wg.Wait()
+
+	if err := b.checkpoint(true); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	log.Infow("backfill done", "from", from, "to", to, "took", time.Since(b.started))
+	return nil
+}
+
+// process extracts the execution of the tipset at the given height, if it
+// isn't a null round
+func (b *backfiller) process(ctx context.Context, h abi.ChainEpoch) error {
+	ts, err := b.api.ChainGetTipSetByHeight(ctx, h, b.head)
+	if err != nil {
+		return xerrors.Errorf("getting tipset: %w", err)
+	}
+	if ts.Height() != h {
+		return nil // null round
+	}
+
+	// the execution results of a tipset are in its first non-null child
+	var child *types.TipSet
+	for ch := h + 1; ; ch++ {
+		child, err = b.api.ChainGetTipSetByHeight(ctx, ch, b.head)
+		if err != nil {
+			return xerrors.Errorf("getting child tipset: %w", err)
+		}
+		if child.Height() == ch {
+			break
+		}
+	}
+
+	msgs, err := b.api.ChainGetParentMessages(ctx, child.Blocks()[0].Cid())
+	if err != nil {
+		return xerrors.Errorf("getting messages: %w", err)
+	}
+
+	rcpts, err := b.api.ChainGetParentReceipts(ctx, child.Blocks()[0].Cid())
+	if err != nil {
+		return xerrors.Errorf("getting receipts: %w", err)
+	}
+
+	if len(msgs) != len(rcpts) {
+		return xerrors.Errorf("got %d messages, but %d receipts", len(msgs), len(rcpts))
+	}
+
+	out := &backfillEpoch{
+		Height:    h,
+		TipSet:    ts.Key(),
+		StateRoot: child.ParentState(),
+	}
+
+	codes := map[address.Address]cid.Cid{}
+	for i, m := range msgs {
+		bm := backfillMessage{
+			Cid:        m.Cid,
+			From:       m.Message.From,
+			To:         m.Message.To,
+			Nonce:      m.Message.Nonce,
+			Value:      m.Message.Value,
+			GasLimit:   m.Message.GasLimit,
+			GasFeeCap:  m.Message.GasFeeCap,
+			GasPremium: m.Message.GasPremium,
+			Method:     m.Message.Method,
+			Params:     m.Message.Params,
+			ExitCode:   rcpts[i].ExitCode,
+			GasUsed:    rcpts[i].GasUsed,
+			Return:     rcpts[i].Return,
+		}
+
+		if m.Message.Method != 0 && len(m.Message.Params) > 0 {
+			code, ok := codes[m.Message.To]
+			if !ok {
+				act, err := b.api.StateGetActor(ctx, m.Message.To, child.Key())
+				if err == nil {
+					code = act.Code
+				}
+				codes[m.Message.To] = code
+			}
+
+			if code.Defined() {
+				if p, err := lcli.JsonParams(code, m.Message.Method, m.Message.Params); err == nil {
+					bm.DecodedParams = json.RawMessage(p)
+				}
+			}
+		}
+
+		out.Messages = append(out.Messages, bm)
+	}
+
+	changed, err := b.api.StateChangedActors(ctx, ts.ParentState(), child.ParentState())
+	if err != nil {
+		return xerrors.Errorf("getting changed actors: %w", err)
+	}
+
+	for addr, act := range changed {
+		out.Actors = append(out.Actors, backfillActorChange{
+			Address: addr,
+			Code:    act.Code,
+			Head:    act.Head,
+			Nonce:   act.Nonce,
+			Balance: act.Balance,
+		})
+	}
+
+	return b.sink.Write(ctx, out)
+}
+
+func (b *backfiller) markDone(h abi.ChainEpoch) {
+	b.lk.Lock()
+	b.done[h] = struct{}{}
+	for {
+		if _, ok := b.done[b.lowest+1]; !ok {
+			break
+		}
+		delete(b.done, b.lowest+1)
+		b.lowest++
+	}
+	b.lk.Unlock()
+
+	if err := b.checkpoint(false); err != nil {
+		log.Errorf("saving checkpoint: %+v", err)
+	}
+}
+
+// checkpoint saves the progress, at most every 10 seconds unless forced
+func (b *backfiller) checkpoint(force bool) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if !force && time.Since(b.lastCp) < 10*time.Second {
+		return nil
+	}
+	b.lastCp = time.Now()
+
+	if b.lowest < b.from {
+		return nil
+	}
+
+	log.Infow("backfill progress", "done", b.lowest, "took", time.Since(b.started))
+
+	if b.cpPath == "" {
+		return nil
+	}
+
+	// the epochs must be persisted before they are recorded as done
+	if err := b.sink.Flush(); err != nil {
+		return xerrors.Errorf("flushing sink: %w", err)
+	}
+
+	cb, err := json.Marshal(&backfillCheckpoint{Done: b.lowest})
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(b.cpPath+".tmp", cb, 0644); err != nil { // nolint
+		return xerrors.Errorf("writing checkpoint: %w", err)
+	}
+
+	return os.Rename(b.cpPath+".tmp", b.cpPath)
+}
+
+func loadBackfillCheckpoint(path string) (*backfillCheckpoint, error) {
+	cb, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("reading checkpoint: %w", err)
+	}
+
+	var cp backfillCheckpoint
+	if err := json.Unmarshal(cb, &cp); err != nil {
+		return nil, xerrors.Errorf("parsing checkpoint: %w", err)
+	}
+
+	return &cp, nil
+}
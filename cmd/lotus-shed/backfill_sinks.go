@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+	"golang.org/x/xerrors"
+)
+
+// backfillSink receives extracted epochs. Write is called concurrently, in no
+// particular height order, and can be called again for an epoch which was
+// written before an interrupted backfill was checkpointed. Flush persists the
+// epochs written so far, before they are recorded as done in the checkpoint.
+type backfillSink interface {
+	Write(ctx context.Context, epoch *backfillEpoch) error
+	Flush() error
+	Close() error
+}
+
+func openBackfillSink(ctx context.Context, spec string) (backfillSink, error) {
+	i := strings.Index(spec, ":")
+	if i < 0 {
+		return nil, xerrors.Errorf("expected sink in the <kind>:<target> format, got %q", spec)
+	}
+
+	kind, target := spec[:i], spec[i+1:]
+	switch kind {
+	case "file":
+		return newFileSink(target)
+	case "postgres":
+		return newPostgresSink(ctx, target)
+	case "kafka":
+		return newKafkaSink(target)
+	default:
+		return nil, xerrors.Errorf("unknown sink kind %q", kind)
+	}
+}
+
+type fileSink struct {
+	lk sync.Mutex
+	f  *os.File
+	w  *bufio.Writer
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644) // nolint
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileSink{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (s *fileSink) Write(_ context.Context, epoch *backfillEpoch) error {
+	b, err := json.Marshal(epoch)
+	if err != nil {
+		return xerrors.Errorf("marshaling epoch: %w", err)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, err := s.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *fileSink) Flush() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+
+	return s.f.Sync()
+}
+
+func (s *fileSink) Close() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if err := s.w.Flush(); err != nil {
+		_ = s.f.Close()
+		return err
+	}
+
+	return s.f.Close()
+}
+
+type postgresSink struct {
+	db *sql.DB
+}
+
+func newPostgresSink(ctx context.Context, conn string) (*postgresSink, error) {
+	db, err := sql.Open("postgres", conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.ExecContext(ctx, `
+create table if not exists backfill_messages
+(
+	cid text not null,
+	height bigint not null,
+	"from" text not null,
+	"to" text not null,
+	nonce bigint not null,
+	value text not null,
+	gas_limit bigint not null,
+	gas_fee_cap text not null,
+	gas_premium text not null,
+	method bigint not null,
+	params bytea,
+	decoded_params jsonb,
+	exit_code bigint not null,
+	gas_used bigint not null,
+	return bytea,
+	constraint backfill_messages_pk
+		primary key (height, cid)
+);
+
+create table if not exists backfill_actor_changes
+(
+	height bigint not null,
+	state_root text not null,
+	address text not null,
+	code text not null,
+	head text not null,
+	nonce bigint not null,
+	balance text not null,
+	constraint backfill_actor_changes_pk
+		primary key (height, address)
+);
+`); err != nil {
+		_ = db.Close()
+		return nil, xerrors.Errorf("creating tables: %w", err)
+	}
+
+	return &postgresSink{db: db}, nil
+}
+
+func (s *postgresSink) Write(ctx context.Context, epoch *backfillEpoch) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return xerrors.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback() // nolint
+
+	// rows of epochs written before a checkpoint was saved are kept
+	for _, m := range epoch.Messages {
+		var decoded interface{}
+		if len(m.DecodedParams) > 0 {
+			decoded = string(m.DecodedParams)
+		}
+
+		if _, err := tx.ExecContext(ctx, `insert into backfill_messages
+			(cid, height, "from", "to", nonce, value, gas_limit, gas_fee_cap, gas_premium, method, params, decoded_params, exit_code, gas_used, return)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+			on conflict do nothing`,
+			m.Cid.String(), epoch.Height, m.From.String(), m.To.String(), m.Nonce, m.Value.String(), m.GasLimit,
+			m.GasFeeCap.String(), m.GasPremium.String(), m.Method, m.Params, decoded, m.ExitCode, m.GasUsed, m.Return); err != nil {
+			return xerrors.Errorf("inserting message %s: %w", m.Cid, err)
+		}
+	}
+
+	for _, a := range epoch.Actors {
+		if _, err := tx.ExecContext(ctx, `insert into backfill_actor_changes
+			(height, state_root, address, code, head, nonce, balance)
+			values ($1, $2, $3, $4, $5, $6, $7)
+			on conflict do nothing`,
+			epoch.Height, epoch.StateRoot.String(), a.Address, a.Code.String(), a.Head.String(), a.Nonce, a.Balance.String()); err != nil {
+			return xerrors.Errorf("inserting actor change %s: %w", a.Address, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Flush is a no-op, epochs are committed by Write
+func (s *postgresSink) Flush() error {
+	return nil
+}
+
+func (s *postgresSink) Close() error {
+	return s.db.Close()
+}
+
+// kafkaSink produces a record per epoch, keyed by height, to a topic through
+// the REST proxy of a Kafka cluster, e.g.
+// kafka:http://localhost:8082/topics/backfill
+type kafkaSink struct {
+	url    string
+	client *http.Client
+}
+
+func newKafkaSink(topicURL string) (*kafkaSink, error) {
+	u, err := url.Parse(topicURL)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing topic URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, xerrors.Errorf("expected a http(s) topic URL of a Kafka REST proxy, got %q", topicURL)
+	}
+
+	return &kafkaSink{
+		url:    topicURL,
+		client: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (s *kafkaSink) Write(ctx context.Context, epoch *backfillEpoch) error {
+	type record struct {
+		Key   string         `json:"key"`
+		Value *backfillEpoch `json:"value"`
+	}
+
+	b, err := json.Marshal(map[string][]record{
+		"records": {{Key: fmt.Sprint(epoch.Height), Value: epoch}},
+	})
+	if err != nil {
+		return xerrors.Errorf("marshaling epoch: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return xerrors.Errorf("producing record: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return xerrors.Errorf("producing record: %s: %s", resp.Status, string(msg))
+	}
+
+	// the proxy reports errors of single records in the response
+	var out struct {
+		Offsets []struct {
+			Error *string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return xerrors.Errorf("decoding response: %w", err)
+	}
+	for _, o := range out.Offsets {
+		if o.Error != nil {
+			return xerrors.Errorf("producing record: %s", *o.Error)
+		}
+	}
+
+	return nil
+}
+
+// Flush is a no-op, records are acknowledged by the proxy in Write
+func (s *kafkaSink) Flush() error {
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	return nil
+}
@@ -44,6 +44,7 @@ func main() {
 		syncCmd,
 		stateTreePruneCmd,
 		datastoreCmd,
+		sealingStateCmd,
 		ledgerCmd,
 		sectorsCmd,
 		msgCmd,
@@ -51,6 +52,7 @@ func main() {
 		rpcCmd,
 		cidCmd,
 		blockmsgidCmd,
+		backfillCmd,
 	}
 
 	app := &cli.App{
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var sealingStateCmd = &cli.Command{
+	Name:  "sealing-state",
+	Usage: "Inspect and repair the sealing state machine datastore of a stopped miner",
+	Description: `These commands open the miner repo directly, so the miner must not be
+   running. Use --miner-repo to point them at the miner repo.`,
+	Subcommands: []*cli.Command{
+		sealingStateDumpCmd,
+		sealingStateCheckCmd,
+		sealingStateSetCmd,
+	},
+}
+
+var sealingStateDumpCmd = &cli.Command{
+	Name:      "dump",
+	Usage:     "Print the sealing state of sectors as JSON",
+	ArgsUsage: "[sectorNum ...]",
+	Action: func(cctx *cli.Context) error {
+		return withSectorStore(cctx, func(ds datastore.Batching, _ address.Address) error {
+			sectors, err := loadSectors(ds, cctx.Args().Slice())
+			if err != nil {
+				return err
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			for _, s := range sectors {
+				if err := enc.Encode(s.info); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	},
+}
+
+var sealingStateCheckCmd = &cli.Command{
+	Name:      "check",
+	Usage:     "Validate the invariants of the sealing state of sectors",
+	ArgsUsage: "[sectorNum ...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "chain",
+			Usage: "also compare the sealing state with the chain state, using the full node API",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.ReqContext(cctx)
+
+		return withSectorStore(cctx, func(ds datastore.Batching, maddr address.Address) error {
+			sectors, err := loadSectors(ds, cctx.Args().Slice())
+			if err != nil {
+				return err
+			}
+
+			var checkChain func(si *sealing.SectorInfo) ([]string, error)
+			if cctx.Bool("chain") {
+				api, closer, err := lcli.GetFullNodeAPI(cctx)
+				if err != nil {
+					return err
+				}
+				defer closer()
+
+				checkChain = func(si *sealing.SectorInfo) ([]string, error) {
+					var problems []string
+
+					onChain, err := api.StateSectorGetInfo(ctx, maddr, si.SectorNumber, types.EmptyTSK)
+					if err != nil {
+						return nil, xerrors.Errorf("getting on-chain info of sector %d: %w", si.SectorNumber, err)
+					}
+					if onChain != nil {
+						if sealing.IsBeforeProving(si.State) {
+							problems = append(problems, "sector is proven on chain, but still in a sealing state")
+						}
+						if si.CommR != nil && *si.CommR != onChain.SealedCID {
+							problems = append(problems, fmt.Sprintf("CommR %s doesn't match the on-chain sealed CID %s", si.CommR, onChain.SealedCID))
+						}
+						return problems, nil
+					}
+
+					// the API doesn't tell a missing precommit apart from a failure
+					pci, err := api.StateSectorPreCommitInfo(ctx, maddr, si.SectorNumber, types.EmptyTSK)
+					if err != nil {
+						if sealing.IsPreCommitted(si.State) && si.State != sealing.PreCommitWait {
+							problems = append(problems, fmt.Sprintf("no precommit on chain (%s)", err))
+						}
+						return problems, nil
+					}
+
+					if sealing.IsBeforePreCommit(si.State) {
+						problems = append(problems, "sector is precommitted on chain, but its state is before precommit")
+					}
+					if si.CommR != nil && *si.CommR != pci.Info.SealedCID {
+						problems = append(problems, fmt.Sprintf("CommR %s doesn't match the precommitted sealed CID %s", si.CommR, pci.Info.SealedCID))
+					}
+					if !sameDeals(dealIDs(si), pci.Info.DealIDs) {
+						problems = append(problems, fmt.Sprintf("deal IDs %v don't match the precommitted deals %v", dealIDs(si), pci.Info.DealIDs))
+					}
+
+					return problems, nil
+				}
+			}
+
+			var bad int
+			for _, s := range sectors {
+				si := s.info
+				problems := checkSectorInfo(si)
+				if s.key != sectorKey(si.SectorNumber) {
+					problems = append(problems, fmt.Sprintf("stored under key %s", s.key))
+				}
+
+				if checkChain != nil {
+					cp, err := checkChain(si)
+					if err != nil {
+						return err
+					}
+					problems = append(problems, cp...)
+				}
+
+				if len(problems) == 0 {
+					continue
+				}
+
+				bad++
+				fmt.Printf("sector %d (%s):\n", si.SectorNumber, si.State)
+				for _, p := range problems {
+					fmt.Printf("\t%s\n", p)
+				}
+			}
+
+			fmt.Printf("%d sectors checked, %d with problems\n", len(sectors), bad)
+			return nil
+		})
+	},
+}
+
+var sealingStateSetCmd = &cli.Command{
+	Name:      "set-state",
+	Usage:     "Force the sealing state of a sector",
+	ArgsUsage: "[sectorNum] [state]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "pass this flag if you know what you are doing",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("expected sector number and new state")
+		}
+
+		state := sealing.SectorState(cctx.Args().Get(1))
+		if _, ok := sealing.ExistSectorStateList[state]; !ok {
+			return xerrors.Errorf("unknown sector state %q", state)
+		}
+
+		return withSectorStore(cctx, func(ds datastore.Batching, _ address.Address) error {
+			sectors, err := loadSectors(ds, cctx.Args().Slice()[:1])
+			if err != nil {
+				return err
+			}
+			si := sectors[0].info
+
+			fmt.Printf("sector %d: %s -> %s\n", si.SectorNumber, si.State, state)
+			if !cctx.Bool("really-do-it") {
+				return xerrors.Errorf("pass --really-do-it to actually change the state")
+			}
+
+			si.Log = append(si.Log, sealing.Log{
+				Timestamp: uint64(time.Now().Unix()),
+				Message:   fmt.Sprintf("state forced from %s to %s with lotus-shed", si.State, state),
+				Kind:      "event;lotus-shed",
+			})
+			si.State = state
+
+			b, err := cborutil.Dump(si)
+			if err != nil {
+				return err
+			}
+
+			return ds.Put(sectors[0].key, b)
+		})
+	},
+}
+
+// withSectorStore opens the sealing state machine datastore of the miner repo
+func withSectorStore(cctx *cli.Context, cb func(ds datastore.Batching, maddr address.Address) error) error {
+	logging.SetLogLevel("badger", "ERROR") // nolint:errcheck
+
+	r, err := repo.NewFS(cctx.String("miner-repo"))
+	if err != nil {
+		return xerrors.Errorf("opening fs repo: %w", err)
+	}
+
+	exists, err := r.Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return xerrors.Errorf("miner repo doesn't exist")
+	}
+
+	lr, err := r.Lock(repo.StorageMiner)
+	if err != nil {
+		return xerrors.Errorf("locking miner repo (is the miner running?): %w", err)
+	}
+	defer lr.Close() //nolint:errcheck
+
+	mds, err := lr.Datastore("/metadata")
+	if err != nil {
+		return err
+	}
+
+	maddrb, err := mds.Get(datastore.NewKey("miner-address"))
+	if err != nil {
+		return xerrors.Errorf("getting miner address: %w", err)
+	}
+	maddr, err := address.NewFromBytes(maddrb)
+	if err != nil {
+		return xerrors.Errorf("parsing miner address: %w", err)
+	}
+
+	return cb(namespace.Wrap(mds, datastore.NewKey(sealing.SectorStorePrefix)), maddr)
+}
+
+func sectorKey(n abi.SectorNumber) datastore.Key {
+	return datastore.NewKey(fmt.Sprint(n))
+}
+
+type storedSector struct {
+	key  datastore.Key
+	info *sealing.SectorInfo
+}
+
+// loadSectors loads the given sectors, or all sectors if none are given
+func loadSectors(ds datastore.Batching, args []string) ([]storedSector, error) {
+	var keys []datastore.Key
+	if len(args) == 0 {
+		res, err := ds.Query(dsq.Query{KeysOnly: true})
+		if err != nil {
+			return nil, xerrors.Errorf("listing sectors: %w", err)
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			return nil, xerrors.Errorf("listing sectors: %w", err)
+		}
+		for _, e := range entries {
+			keys = append(keys, datastore.NewKey(e.Key))
+		}
+	} else {
+		for _, arg := range args {
+			n, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				return nil, xerrors.Errorf("parsing sector number %q: %w", arg, err)
+			}
+			keys = append(keys, sectorKey(abi.SectorNumber(n)))
+		}
+	}
+
+	out := make([]storedSector, 0, len(keys))
+	for _, k := range keys {
+		b, err := ds.Get(k)
+		if err != nil {
+			return nil, xerrors.Errorf("getting sector %s: %w", k.BaseNamespace(), err)
+		}
+
+		var si sealing.SectorInfo
+		if err := si.UnmarshalCBOR(bytes.NewReader(b)); err != nil {
+			return nil, xerrors.Errorf("decoding sector %s: %w", k.BaseNamespace(), err)
+		}
+
+		out = append(out, storedSector{key: k, info: &si})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].info.SectorNumber < out[j].info.SectorNumber
+	})
+
+	return out, nil
+}
+
+// checkSectorInfo returns the violated invariants of the local sector state
+func checkSectorInfo(si *sealing.SectorInfo) []string {
+	var problems []string
+
+	if _, ok := sealing.ExistSectorStateList[si.State]; !ok {
+		problems = append(problems, fmt.Sprintf("unknown state %q", si.State))
+	}
+
+	unsealed := sealing.IsBeforePreCommit(si.State)
+	precommitted := sealing.IsPreCommitted(si.State)
+
+	ssize, err := si.SectorType.SectorSize()
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("invalid sector type %d", si.SectorType))
+	} else if !unsealed {
+		var size abi.PaddedPieceSize
+		for _, p := range si.Pieces {
+			size += p.Piece.Size
+		}
+		if size != abi.PaddedPieceSize(ssize) && si.State != sealing.Removed {
+			problems = append(problems, fmt.Sprintf("pieces add up to %d bytes, the sector has %d", size, ssize))
+		}
+	}
+
+	if precommitted {
+		if si.CommD == nil || si.CommR == nil {
+			problems = append(problems, "CommD or CommR missing after PreCommit2")
+		}
+		if si.PreCommitMessage == nil {
+			problems = append(problems, "precommit message missing")
+		}
+	}
+
+	if si.State == sealing.CommitWait && si.CommitMessage == nil {
+		problems = append(problems, "commit message missing in CommitWait")
+	}
+
+	if pci := si.PreCommitInfo; pci != nil {
+		if pci.SectorNumber != si.SectorNumber {
+			problems = append(problems, fmt.Sprintf("precommit info is for sector %d", pci.SectorNumber))
+		}
+		if si.CommR != nil && pci.SealedCID != *si.CommR {
+			problems = append(problems, fmt.Sprintf("precommit info sealed CID %s doesn't match CommR %s", pci.SealedCID, si.CommR))
+		}
+		if !sameDeals(dealIDs(si), pci.DealIDs) {
+			problems = append(problems, fmt.Sprintf("precommit info deals %v don't match the pieces deals %v", pci.DealIDs, dealIDs(si)))
+		}
+	}
+
+	for i, p := range si.Pieces {
+		if p.DealInfo != nil && p.DealInfo.DealID == 0 && !unsealed {
+			problems = append(problems, fmt.Sprintf("piece %d has no deal ID", i))
+		}
+	}
+
+	return problems
+}
+
+func dealIDs(si *sealing.SectorInfo) []abi.DealID {
+	var out []abi.DealID
+	for _, p := range si.Pieces {
+		if p.DealInfo != nil {
+			out = append(out, p.DealInfo.DealID)
+		}
+	}
+	return out
+}
+
+func sameDeals(a, b []abi.DealID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
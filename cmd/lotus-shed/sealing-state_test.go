@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	tutils "github.com/filecoin-project/specs-actors/v2/support/testing"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+func testSectorInfo() *sealing.SectorInfo {
+	commD := tutils.MakeCID("commD", nil)
+	commR := tutils.MakeCID("commR", nil)
+	msg := tutils.MakeCID("precommit", nil)
+
+	return &sealing.SectorInfo{
+		State:        sealing.WaitSeed,
+		SectorNumber: 3,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces: []sealing.Piece{
+			{
+				Piece:    abi.PieceInfo{Size: 1024, PieceCID: tutils.MakeCID("deal", nil)},
+				DealInfo: &sealing.DealInfo{DealID: 7},
+			},
+			{
+				Piece: abi.PieceInfo{Size: 1024, PieceCID: tutils.MakeCID("filler", nil)},
+			},
+		},
+		CommD: &commD,
+		CommR: &commR,
+		PreCommitInfo: &miner.SectorPreCommitInfo{
+			SectorNumber: 3,
+			SealedCID:    commR,
+			DealIDs:      []abi.DealID{7},
+		},
+		PreCommitMessage: &msg,
+	}
+}
+
+func TestCheckSectorInfo(t *testing.T) {
+	require.Empty(t, checkSectorInfo(testSectorInfo()))
+
+	tcases := []struct {
+		name    string
+		modify  func(si *sealing.SectorInfo)
+		problem string
+	}{
+		{
+			name:    "unknown state",
+			modify:  func(si *sealing.SectorInfo) { si.State = "NotAState" },
+			problem: `unknown state "NotAState"`,
+		},
+		{
+			name:    "invalid sector type",
+			modify:  func(si *sealing.SectorInfo) { si.SectorType = -1 },
+			problem: "invalid sector type -1",
+		},
+		{
+			name:    "pieces don't fill the sector",
+			modify:  func(si *sealing.SectorInfo) { si.Pieces = si.Pieces[:1] },
+			problem: "pieces add up to 1024 bytes, the sector has 2048",
+		},
+		{
+			name:    "missing CommR",
+			modify:  func(si *sealing.SectorInfo) { si.CommR = nil },
+			problem: "CommD or CommR missing after PreCommit2",
+		},
+		{
+			name:    "missing precommit message",
+			modify:  func(si *sealing.SectorInfo) { si.PreCommitMessage = nil },
+			problem: "precommit message missing",
+		},
+		{
+			name: "missing commit message",
+			modify: func(si *sealing.SectorInfo) {
+				si.State = sealing.CommitWait
+			},
+			problem: "commit message missing in CommitWait",
+		},
+		{
+			name:    "precommit info for another sector",
+			modify:  func(si *sealing.SectorInfo) { si.PreCommitInfo.SectorNumber = 4 },
+			problem: "precommit info is for sector 4",
+		},
+		{
+			name:    "precommit info deals don't match",
+			modify:  func(si *sealing.SectorInfo) { si.PreCommitInfo.DealIDs = []abi.DealID{8} },
+			problem: "precommit info deals [8] don't match the pieces deals [7]",
+		},
+		{
+			name: "piece without deal ID",
+			modify: func(si *sealing.SectorInfo) {
+				si.Pieces[0].DealInfo.DealID = 0
+				si.PreCommitInfo.DealIDs = []abi.DealID{0}
+			},
+			problem: "piece 0 has no deal ID",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			si := testSectorInfo()
+			tc.modify(si)
+			require.Equal(t, []string{tc.problem}, checkSectorInfo(si))
+		})
+	}
+}
+
+func TestCheckSectorInfoBeforePreCommit(t *testing.T) {
+	// sectors which are still being packed or sealed don't have to be full,
+	// nor have deal IDs or commitments yet
+	si := &sealing.SectorInfo{
+		State:        sealing.WaitDeals,
+		SectorNumber: 3,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1,
+		Pieces: []sealing.Piece{
+			{
+				Piece:    abi.PieceInfo{Size: 1024, PieceCID: tutils.MakeCID("deal", nil)},
+				DealInfo: &sealing.DealInfo{},
+			},
+		},
+	}
+	require.Empty(t, checkSectorInfo(si))
+}
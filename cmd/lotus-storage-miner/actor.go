@@ -41,6 +41,167 @@ var actorCmd = &cli.Command{
 		actorControl,
 		actorProposeChangeWorker,
 		actorConfirmChangeWorker,
+		actorFundsPolicyCmd,
+	},
+}
+
+var actorFundsPolicyCmd = &cli.Command{
+	Name:  "funds-policy",
+	Usage: "Manage the policy of the funds manager, which maintains the miner actor balance",
+	Subcommands: []*cli.Command{
+		actorFundsPolicyGetCmd,
+		actorFundsPolicySetCmd,
+	},
+}
+
+var actorFundsPolicyGetCmd = &cli.Command{
+	Name:  "get",
+	Usage: "Print the funds policy, and the current available balance",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		p, err := nodeApi.ActorFundsPolicy(ctx)
+		if err != nil {
+			return err
+		}
+
+		maddr, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		available, err := api.StateMinerAvailableBalance(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			return err
+		}
+
+		orDefault := func(a address.Address, def string) string {
+			if a == address.Undef {
+				return def
+			}
+			return a.String()
+		}
+
+		if p.CheckInterval == 0 {
+			fmt.Println("Funds manager: " + color.YellowString("disabled"))
+		} else {
+			fmt.Printf("Funds manager: %s, checking every %s\n", color.GreenString("enabled"), p.CheckInterval)
+		}
+		fmt.Printf("Available balance: %s\n", types.FIL(available))
+		fmt.Println()
+
+		if p.TargetBalance.Int == nil || p.TargetBalance.IsZero() {
+			fmt.Println("Top-ups: disabled")
+		} else {
+			fmt.Printf("Top-ups: up to %s, from %s\n", types.FIL(p.TargetBalance), orDefault(p.TopUpFrom, "the worker address"))
+		}
+
+		if p.WithdrawAbove.Int == nil || p.WithdrawAbove.IsZero() {
+			fmt.Println("Withdrawals: disabled")
+		} else {
+			fmt.Printf("Withdrawals: above %s, to %s\n", types.FIL(p.WithdrawAbove), orDefault(p.Beneficiary, "the owner address"))
+		}
+
+		return nil
+	},
+}
+
+var actorFundsPolicySetCmd = &cli.Command{
+	Name:  "set",
+	Usage: "Change the funds policy; settings which aren't passed are kept",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "check-interval",
+			Usage: "how often the balance is checked, 0 to disable the funds manager",
+		},
+		&cli.StringFlag{
+			Name:  "target",
+			Usage: "top up the available balance to this amount (FIL) when it falls below it, 0 to disable top-ups",
+		},
+		&cli.StringFlag{
+			Name:  "top-up-from",
+			Usage: "address top-ups are sent from, empty for the worker address",
+		},
+		&cli.StringFlag{
+			Name:  "withdraw-above",
+			Usage: "withdraw the available balance above this amount (FIL), 0 to disable withdrawals",
+		},
+		&cli.StringFlag{
+			Name:  "beneficiary",
+			Usage: "address withdrawn funds are forwarded to, empty to keep them with the owner",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		p, err := nodeApi.ActorFundsPolicy(ctx)
+		if err != nil {
+			return err
+		}
+
+		parseAddr := func(flag string) (address.Address, error) {
+			if cctx.String(flag) == "" {
+				return address.Undef, nil
+			}
+			a, err := address.NewFromString(cctx.String(flag))
+			if err != nil {
+				return address.Undef, xerrors.Errorf("parsing --%s: %w", flag, err)
+			}
+			return a, nil
+		}
+
+		if cctx.IsSet("check-interval") {
+			p.CheckInterval = cctx.Duration("check-interval")
+		}
+		if cctx.IsSet("target") {
+			f, err := types.ParseFIL(cctx.String("target"))
+			if err != nil {
+				return xerrors.Errorf("parsing --target: %w", err)
+			}
+			p.TargetBalance = abi.TokenAmount(f)
+		}
+		if cctx.IsSet("top-up-from") {
+			if p.TopUpFrom, err = parseAddr("top-up-from"); err != nil {
+				return err
+			}
+		}
+		if cctx.IsSet("withdraw-above") {
+			f, err := types.ParseFIL(cctx.String("withdraw-above"))
+			if err != nil {
+				return xerrors.Errorf("parsing --withdraw-above: %w", err)
+			}
+			p.WithdrawAbove = abi.TokenAmount(f)
+		}
+		if cctx.IsSet("beneficiary") {
+			if p.Beneficiary, err = parseAddr("beneficiary"); err != nil {
+				return err
+			}
+		}
+
+		if err := nodeApi.ActorSetFundsPolicy(ctx, p); err != nil {
+			return err
+		}
+
+		fmt.Println("Funds policy updated")
+		return nil
 	},
 }
 
@@ -190,6 +351,9 @@ var actorWithdrawCmd = &cli.Command{
 	Name:      "withdraw",
 	Usage:     "withdraw available balance",
 	ArgsUsage: "[amount (FIL)]",
+	Flags: []cli.Flag{
+		proposerFlag,
+	},
 	Action: func(cctx *cli.Context) error {
 		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
 		if err != nil {
@@ -241,13 +405,24 @@ var actorWithdrawCmd = &cli.Command{
 			return err
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		msg := &types.Message{
 			To:     maddr,
 			From:   mi.Owner,
 			Value:  types.NewInt(0),
 			Method: miner.Methods.WithdrawBalance,
 			Params: params,
-		}, nil)
+		}
+
+		msig, err := isMultisig(ctx, api, mi.Owner)
+		if err != nil {
+			return err
+		}
+		if msig {
+			_, err := proposeControlMessage(ctx, cctx, api, msg)
+			return err
+		}
+
+		smsg, err := api.MpoolPushMessage(ctx, msg, nil)
 		if err != nil {
 			return err
 		}
@@ -630,6 +805,7 @@ var actorSetOwnerCmd = &cli.Command{
 			Usage: "Actually send transaction performing the action",
 			Value: false,
 		},
+		proposerFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		if !cctx.Bool("really-do-it") {
@@ -694,13 +870,30 @@ var actorSetOwnerCmd = &cli.Command{
 			return xerrors.Errorf("serializing params: %w", err)
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, &types.Message{
+		msg := &types.Message{
 			From:   fromAddrId,
 			To:     maddr,
 			Method: miner.Methods.ChangeOwnerAddress,
 			Value:  big.Zero(),
 			Params: sp,
-		}, nil)
+		}
+
+		msig, err := isMultisig(ctx, api, fromAddrId)
+		if err != nil {
+			return err
+		}
+		if msig {
+			executed, err := proposeControlMessage(ctx, cctx, api, msg)
+			if err != nil {
+				return err
+			}
+			if executed {
+				fmt.Println("message succeeded!")
+			}
+			return nil
+		}
+
+		smsg, err := api.MpoolPushMessage(ctx, msg, nil)
 		if err != nil {
 			return xerrors.Errorf("mpool push: %w", err)
 		}
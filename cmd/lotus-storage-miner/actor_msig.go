@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	msig2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/multisig"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var proposerFlag = &cli.StringFlag{
+	Name:  "proposer",
+	Usage: "signer proposing the message when the sender is a multisig (default: wallet default address)",
+}
+
+func isMultisig(ctx context.Context, api lapi.FullNode, addr address.Address) (bool, error) {
+	act, err := api.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return false, xerrors.Errorf("looking up actor %s: %w", addr, err)
+	}
+
+	return builtin.IsMultisigActor(act.Code), nil
+}
+
+// proposeControlMessage proposes the message through the multisig it's sent
+// from, and prints how other signers can approve it. It returns whether the
+// message was executed already, which happens when the multisig threshold is
+// one.
+func proposeControlMessage(ctx context.Context, cctx *cli.Context, api lapi.FullNode, msg *types.Message) (bool, error) {
+	var proposer address.Address
+	if p := cctx.String(proposerFlag.Name); p != "" {
+		a, err := address.NewFromString(p)
+		if err != nil {
+			return false, xerrors.Errorf("parsing proposer address: %w", err)
+		}
+		proposer = a
+	} else {
+		a, err := api.WalletDefaultAddress(ctx)
+		if err != nil {
+			return false, xerrors.Errorf("getting default wallet address: %w", err)
+		}
+		proposer = a
+	}
+
+	proposerID, err := api.StateLookupID(ctx, proposer, types.EmptyTSK)
+	if err != nil {
+		return false, xerrors.Errorf("looking up proposer: %w", err)
+	}
+
+	mcid, err := api.MsigPropose(ctx, msg.From, msg.To, msg.Value, proposer, uint64(msg.Method), msg.Params)
+	if err != nil {
+		return false, xerrors.Errorf("proposing message: %w", err)
+	}
+
+	fmt.Printf("Proposed through multisig %s in message %s\n", msg.From, mcid)
+
+	wait, err := api.StateWaitMsg(ctx, mcid, build.MessageConfidence)
+	if err != nil {
+		return false, err
+	}
+
+	if wait.Receipt.ExitCode != 0 {
+		return false, xerrors.Errorf("proposal failed with exit code %d", wait.Receipt.ExitCode)
+	}
+
+	var ret msig2.ProposeReturn
+	if err := ret.UnmarshalCBOR(bytes.NewReader(wait.Receipt.Return)); err != nil {
+		return false, xerrors.Errorf("unmarshaling propose return value: %w", err)
+	}
+
+	if ret.Applied {
+		if ret.Code != 0 {
+			return false, xerrors.Errorf("proposed message failed with exit code %d", ret.Code)
+		}
+
+		fmt.Printf("Transaction %d was executed during propose\n", ret.TxnID)
+		return true, nil
+	}
+
+	fmt.Printf("Transaction ID: %d\n", ret.TxnID)
+	fmt.Printf("Approve it from the other signers with:\n")
+	approve := fmt.Sprintf("lotus msig approve %s %d %s %s %s", msg.From, ret.TxnID, proposerID, msg.To, types.FIL(msg.Value).Unitless())
+	if msg.Method != 0 || len(msg.Params) > 0 {
+		params := hex.EncodeToString(msg.Params)
+		if params == "" {
+			params = "''"
+		}
+		approve += fmt.Sprintf(" %d %s", msg.Method, params)
+	}
+	fmt.Printf("  %s\n", approve)
+
+	return false, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api/client"
+	lcli "github.com/filecoin-project/lotus/cli"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var initMarketsCmd = &cli.Command{
+	Name:  "markets",
+	Usage: "Initialize a repo for a miner node running only the markets subsystem",
+	Description: `The markets node handles deal intake, data transfer and retrieval for the miner
+   of a sealing node, and hands deal data to it over its API. Start it with
+   lotus-miner run --type=markets. Clients reach the markets node once the peer ID
+   of the miner is set to the one of the markets node.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "sealer-api-info",
+			Usage:    "API info of the sealing node, in the token:multiaddr form; the token needs admin permissions",
+			Required: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.ReqContext(cctx)
+
+		apiInfo := cctx.String("sealer-api-info")
+		info := cliutil.ParseApiInfo(apiInfo)
+		addr, err := info.DialArgs()
+		if err != nil {
+			return xerrors.Errorf("parsing sealing node API info: %w", err)
+		}
+
+		log.Info("Connecting to the sealing node")
+
+		sealer, closer, err := client.NewStorageMinerRPC(ctx, addr, info.AuthHeader())
+		if err != nil {
+			return xerrors.Errorf("connecting to the sealing node: %w", err)
+		}
+		defer closer()
+
+		maddr, err := sealer.ActorAddress(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting miner address from the sealing node: %w", err)
+		}
+
+		repoPath := cctx.String(FlagMinerRepo)
+		r, err := repo.NewFS(repoPath)
+		if err != nil {
+			return err
+		}
+
+		ok, err := r.Exists()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return xerrors.Errorf("repo at '%s' is already initialized", repoPath)
+		}
+
+		if err := r.Init(repo.StorageMiner); err != nil {
+			return err
+		}
+
+		lr, err := r.Lock(repo.StorageMiner)
+		if err != nil {
+			return err
+		}
+		defer lr.Close() //nolint:errcheck
+
+		log.Info("Initializing libp2p identity")
+
+		p2pSk, err := makeHostKey(lr)
+		if err != nil {
+			return xerrors.Errorf("make host key: %w", err)
+		}
+
+		peerid, err := peer.IDFromPrivateKey(p2pSk)
+		if err != nil {
+			return xerrors.Errorf("peer ID from private key: %w", err)
+		}
+
+		mds, err := lr.Datastore("/metadata")
+		if err != nil {
+			return err
+		}
+
+		if err := mds.Put(datastore.NewKey("miner-address"), maddr.Bytes()); err != nil {
+			return err
+		}
+
+		var cerr error
+		err = lr.SetConfig(func(raw interface{}) {
+			rcfg, ok := raw.(*config.StorageMiner)
+			if !ok {
+				cerr = xerrors.New("expected miner config")
+				return
+			}
+
+			rcfg.MarketsNode.SealerApiInfo = apiInfo
+		})
+		if err != nil {
+			return xerrors.Errorf("setting config: %w", err)
+		}
+		if cerr != nil {
+			return cerr
+		}
+
+		log.Infof("Initialized markets node for miner %s", maddr)
+		log.Infof("Set the peer ID of the miner with 'lotus-miner actor set-peer-id %s' to receive deals on this node", peerid)
+
+		return nil
+	},
+}
@@ -26,9 +26,11 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
 
+	lapi "github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/markets/askrefresh"
 )
 
 var CidBaseFlag = cli.StringFlag{
@@ -328,19 +330,63 @@ var getAskCmd = &cli.Command{
 	},
 }
 
+var getAskHistoryCmd = &cli.Command{
+	Name:  "ask-history",
+	Usage: "Print the latest signed asks of the miner",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "number of asks to print, 0 for all recorded asks",
+			Value: 10,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.DaemonContext(cctx)
+
+		smapi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		asks, err := smapi.MarketGetAskHistory(ctx, cctx.Int("limit"))
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Seq. No.\tPrice per GiB/Epoch\tVerified\tMin. Piece Size (padded)\tMax. Piece Size (padded)\tTimestamp (Epoch)\tExpiry (Epoch)\tCommitment\n")
+		for _, sask := range asks {
+			ask := sask.Ask
+
+			c, err := askrefresh.Commitment(sask)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%x\n", ask.SeqNo, types.FIL(ask.Price), types.FIL(ask.VerifiedPrice), types.SizeStr(types.NewInt(uint64(ask.MinPieceSize))), types.SizeStr(types.NewInt(uint64(ask.MaxPieceSize))), ask.Timestamp, ask.Expiry, c)
+		}
+
+		return w.Flush()
+	},
+}
+
 var storageDealsCmd = &cli.Command{
 	Name:  "storage-deals",
 	Usage: "Manage storage deals and related configuration",
 	Subcommands: []*cli.Command{
 		dealsImportDataCmd,
 		dealsListCmd,
+		dealsPendingCmd,
 		storageDealSelectionCmd,
 		setAskCmd,
 		getAskCmd,
+		getAskHistoryCmd,
 		setBlocklistCmd,
 		getBlocklistCmd,
 		resetBlocklistCmd,
 		setSealDurationCmd,
+		clientQuotasCmd,
 	},
 }
 
@@ -413,7 +459,7 @@ var dealsListCmd = &cli.Command{
 				tm.Clear()
 				tm.MoveCursor(1, 1)
 
-				err = outputStorageDeals(tm.Output, deals, verbose)
+				err = outputStorageDeals(tm.Output, deals, commitEstimates(ctx, api), verbose)
 				if err != nil {
 					return err
 				}
@@ -439,11 +485,30 @@ var dealsListCmd = &cli.Command{
 			}
 		}
 
-		return outputStorageDeals(os.Stdout, deals, verbose)
+		return outputStorageDeals(os.Stdout, deals, commitEstimates(ctx, api), verbose)
 	},
 }
 
-func outputStorageDeals(out io.Writer, deals []storagemarket.MinerDeal, verbose bool) error {
+// commitEstimates returns when the deals which aren't committed yet are
+// expected to be, by deal ID. The estimates are only informational, so the
+// deals are listed without them if they can't be fetched
+func commitEstimates(ctx context.Context, api lapi.StorageMiner) map[abi.DealID]time.Time {
+	estimates, err := api.DealsCommitEstimates(ctx)
+	if err != nil {
+		log.Warnf("getting commit estimates: %s", err)
+		return nil
+	}
+
+	out := map[abi.DealID]time.Time{}
+	for _, e := range estimates {
+		if !e.Estimate.IsZero() {
+			out[e.DealID] = e.Estimate
+		}
+	}
+	return out
+}
+
+func outputStorageDeals(out io.Writer, deals []storagemarket.MinerDeal, estimates map[abi.DealID]time.Time, verbose bool) error {
 	sort.Slice(deals, func(i, j int) bool {
 		return deals[i].CreationTime.Time().Before(deals[j].CreationTime.Time())
 	})
@@ -451,9 +516,9 @@ func outputStorageDeals(out io.Writer, deals []storagemarket.MinerDeal, verbose
 	w := tabwriter.NewWriter(out, 2, 4, 2, ' ', 0)
 
 	if verbose {
-		_, _ = fmt.Fprintf(w, "Creation\tVerified\tProposalCid\tDealId\tState\tClient\tSize\tPrice\tDuration\tTransferChannelID\tMessage\n")
+		_, _ = fmt.Fprintf(w, "Creation\tVerified\tProposalCid\tDealId\tState\tClient\tSize\tPrice\tDuration\tEstimatedCommit\tTransferChannelID\tMessage\n")
 	} else {
-		_, _ = fmt.Fprintf(w, "ProposalCid\tDealId\tState\tClient\tSize\tPrice\tDuration\n")
+		_, _ = fmt.Fprintf(w, "ProposalCid\tDealId\tState\tClient\tSize\tPrice\tDuration\tEstimatedCommit\n")
 	}
 
 	for _, deal := range deals {
@@ -469,6 +534,13 @@ func outputStorageDeals(out io.Writer, deals []storagemarket.MinerDeal, verbose
 		}
 
 		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s", propcid, deal.DealID, storagemarket.DealStates[deal.State], deal.Proposal.Client, units.BytesSize(float64(deal.Proposal.PieceSize)), fil, deal.Proposal.Duration())
+
+		eta := ""
+		if e, ok := estimates[deal.DealID]; ok && deal.DealID != 0 {
+			eta = e.Local().Format(time.Stamp)
+		}
+		_, _ = fmt.Fprintf(w, "\t%s", eta)
+
 		if verbose {
 			tchid := ""
 			if deal.TransferChannelId != nil {
@@ -577,6 +649,105 @@ var resetBlocklistCmd = &cli.Command{
 	},
 }
 
+var clientQuotasCmd = &cli.Command{
+	Name:  "client-quotas",
+	Usage: "List per-client deal quota usage",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		usage, err := api.MarketClientQuotas(ctx)
+		if err != nil {
+			return err
+		}
+
+		limit := func(used, max uint64, f func(uint64) string) string {
+			if max == 0 {
+				return f(used)
+			}
+			return fmt.Sprintf("%s / %s", f(used), f(max))
+		}
+		count := func(n uint64) string {
+			return fmt.Sprint(n)
+		}
+		size := func(n uint64) string {
+			return types.SizeStr(types.NewInt(n))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Client\tActive Deals\tActive Deal Bytes\tPending Pieces\n")
+		for _, u := range usage {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.Client,
+				limit(u.ActiveDeals, u.MaxActiveDeals, count),
+				limit(u.ActiveDealBytes, u.MaxActiveDealBytes, size),
+				limit(u.PendingPieces, u.MaxPendingPieces, count))
+		}
+
+		return w.Flush()
+	},
+}
+
+var dealsPendingCmd = &cli.Command{
+	Name:      "pending",
+	Usage:     "List deals waiting for their sector to be committed, with the estimated commit time",
+	ArgsUsage: "[dealId]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		var only *abi.DealID
+		if cctx.Args().Present() {
+			id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+			if err != nil {
+				return xerrors.Errorf("parsing deal id: %w", err)
+			}
+			did := abi.DealID(id)
+			only = &did
+		}
+
+		estimates, err := api.DealsCommitEstimates(ctx)
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(estimates, func(i, j int) bool {
+			return estimates[i].DealID < estimates[j].DealID
+		})
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Deal\tSector\tState\tEstimated Commit\n")
+		for _, e := range estimates {
+			if only != nil && e.DealID != *only {
+				continue
+			}
+
+			eta := "unknown"
+			if !e.Estimate.IsZero() {
+				eta = fmt.Sprintf("%s (in %s)", e.Estimate.Local().Format(time.Stamp), time.Until(e.Estimate).Truncate(time.Minute))
+			}
+
+			sector, state := "-", "queued"
+			if e.State != "" {
+				sector, state = fmt.Sprint(e.Sector), string(e.State)
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", e.DealID, sector, state, eta)
+		}
+
+		return w.Flush()
+	},
+}
+
 var setSealDurationCmd = &cli.Command{
 	Name:      "set-seal-duration",
 	Usage:     "Set the expected time, in minutes, that you expect sealing sectors to take. Deals that start before this duration will be rejected.",
@@ -610,6 +781,67 @@ var dataTransfersCmd = &cli.Command{
 		transfersListCmd,
 		marketRestartTransfer,
 		marketCancelTransfer,
+		transfersLimitCmd,
+	},
+}
+
+var transfersLimitCmd = &cli.Command{
+	Name:  "limit",
+	Usage: "Get or set the bandwidth limits of data transfers",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "per-transfer",
+			Usage: "limit of each transfer per second, e.g. 10MiB; 0 to remove the limit",
+		},
+		&cli.StringFlag{
+			Name:  "total",
+			Usage: "limit of all transfers together per second, e.g. 100MiB; 0 to remove the limit",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		limits, err := nodeApi.MarketGetTransferLimits(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.IsSet("per-transfer") || cctx.IsSet("total") {
+			if cctx.IsSet("per-transfer") {
+				v, err := units.RAMInBytes(cctx.String("per-transfer"))
+				if err != nil {
+					return xerrors.Errorf("parsing per-transfer limit: %w", err)
+				}
+				limits.PerTransfer = uint64(v)
+			}
+			if cctx.IsSet("total") {
+				v, err := units.RAMInBytes(cctx.String("total"))
+				if err != nil {
+					return xerrors.Errorf("parsing total limit: %w", err)
+				}
+				limits.Total = uint64(v)
+			}
+
+			if err := nodeApi.MarketSetTransferLimits(ctx, limits); err != nil {
+				return err
+			}
+		}
+
+		formatLimit := func(l uint64) string {
+			if l == 0 {
+				return "unlimited"
+			}
+			return types.SizeStr(types.NewInt(l)) + "/s"
+		}
+
+		fmt.Printf("Per transfer: %s\n", formatLimit(limits.PerTransfer))
+		fmt.Printf("Total: %s\n", formatLimit(limits.Total))
+		return nil
 	},
 }
 
@@ -644,29 +876,9 @@ var marketRestartTransfer = &cli.Command{
 		}
 		transferID := datatransfer.TransferID(transferUint)
 		initiator := cctx.Bool("initiator")
-		var other peer.ID
-		if pidstr := cctx.String("peerid"); pidstr != "" {
-			p, err := peer.Decode(pidstr)
-			if err != nil {
-				return err
-			}
-			other = p
-		} else {
-			channels, err := nodeApi.MarketListDataTransfers(ctx)
-			if err != nil {
-				return err
-			}
-			found := false
-			for _, channel := range channels {
-				if channel.IsInitiator == initiator && channel.TransferID == transferID {
-					other = channel.OtherPeer
-					found = true
-					break
-				}
-			}
-			if !found {
-				return errors.New("unable to find matching data transfer")
-			}
+		other, err := transferPeer(ctx, nodeApi, transferID, initiator, cctx.String("peerid"))
+		if err != nil {
+			return err
 		}
 
 		return nodeApi.MarketRestartDataTransfer(ctx, transferID, other, initiator)
@@ -675,7 +887,7 @@ var marketRestartTransfer = &cli.Command{
 
 var marketCancelTransfer = &cli.Command{
 	Name:  "cancel",
-	Usage: "Force cancel a data transfer",
+	Usage: "Force cancel a data transfer, failing the deal it belongs to",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "peerid",
@@ -709,29 +921,9 @@ var marketCancelTransfer = &cli.Command{
 		}
 		transferID := datatransfer.TransferID(transferUint)
 		initiator := cctx.Bool("initiator")
-		var other peer.ID
-		if pidstr := cctx.String("peerid"); pidstr != "" {
-			p, err := peer.Decode(pidstr)
-			if err != nil {
-				return err
-			}
-			other = p
-		} else {
-			channels, err := nodeApi.MarketListDataTransfers(ctx)
-			if err != nil {
-				return err
-			}
-			found := false
-			for _, channel := range channels {
-				if channel.IsInitiator == initiator && channel.TransferID == transferID {
-					other = channel.OtherPeer
-					found = true
-					break
-				}
-			}
-			if !found {
-				return errors.New("unable to find matching data transfer")
-			}
+		other, err := transferPeer(ctx, nodeApi, transferID, initiator, cctx.String("peerid"))
+		if err != nil {
+			return err
 		}
 
 		timeoutCtx, cancel := context.WithTimeout(ctx, cctx.Duration("cancel-timeout"))
@@ -740,6 +932,37 @@ var marketCancelTransfer = &cli.Command{
 	},
 }
 
+// transferPeer returns the peer on the other side of the data transfer, which
+// is looked up in the transfers of the miner when peerID isn't set. Transfer
+// IDs are only unique per initiator, so the lookup fails when several peers
+// have a transfer with the ID.
+func transferPeer(ctx context.Context, nodeApi lapi.StorageMiner, transferID datatransfer.TransferID, initiator bool, peerID string) (peer.ID, error) {
+	if peerID != "" {
+		return peer.Decode(peerID)
+	}
+
+	channels, err := nodeApi.MarketListDataTransfers(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []peer.ID
+	for _, channel := range channels {
+		if channel.IsInitiator == initiator && channel.TransferID == transferID {
+			matches = append(matches, channel.OtherPeer)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", errors.New("unable to find matching data transfer")
+	case 1:
+		return matches[0], nil
+	default:
+		return "", xerrors.Errorf("transfer ID %d matches transfers with %d peers (%s), select one with --peerid", transferID, len(matches), matches)
+	}
+}
+
 var transfersListCmd = &cli.Command{
 	Name:  "list",
 	Usage: "List ongoing data transfers for this miner",
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var miningCmd = &cli.Command{
+	Name:  "mining",
+	Usage: "Inspect block production",
+	Subcommands: []*cli.Command{
+		miningCandidateCmd,
+		miningHistoryCmd,
+	},
+}
+
+var miningCandidateCmd = &cli.Command{
+	Name:  "candidate",
+	Usage: "Print the block the miner would produce if it won the next round right now",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "list selected messages",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		bc, err := nodeApi.MiningBlockCandidate(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Base:          %s\n", bc.Base)
+		fmt.Printf("Height:        %d (null rounds: %d)\n", bc.Height, bc.NullRounds)
+		fmt.Printf("Parent Weight: %s\n", bc.ParentWeight)
+		fmt.Printf("Messages:      %d (gas limit: %d)\n", len(bc.Messages), bc.GasLimit)
+		fmt.Printf("Block Reward:  %s\n", types.FIL(bc.BlockReward))
+		fmt.Printf("Gas Reward:    ~%s\n", types.FIL(bc.GasReward))
+		fmt.Printf("Total Reward:  ~%s\n", types.FIL(big.Add(bc.BlockReward, bc.GasReward)))
+
+		if !cctx.Bool("verbose") {
+			return nil
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Cid\tFrom\tNonce\tGas Limit\tGas Reward\n")
+		for _, m := range bc.Messages {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", m.Cid, m.From, m.Nonce, m.GasLimit, types.FIL(m.GasReward))
+		}
+
+		return w.Flush()
+	},
+}
+
+var miningHistoryCmd = &cli.Command{
+	Name:  "history",
+	Usage: "List recently produced blocks with the time spent in each production step",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		blocks, err := nodeApi.MiningBlockHistory(ctx)
+		if err != nil {
+			return err
+		}
+
+		ms := func(d time.Duration) string {
+			return d.Truncate(time.Millisecond).String()
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Height\tCid\tNulls\tMsgs\tMined\tBaseInfo\tElection\tSeed\tWinningPoSt\tSelectMsgs\tCreate\tTotal\n")
+		for _, b := range blocks {
+			t := b.Timings
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				b.Height, b.Cid, b.NullRounds, b.Messages, b.Mined.Format(time.Stamp),
+				ms(t.MinerBaseInfo), ms(t.Election), ms(t.Seed), ms(t.WinningPoSt), ms(t.SelectMessages), ms(t.CreateBlock), ms(t.Total))
+		}
+
+		return w.Flush()
+	},
+}
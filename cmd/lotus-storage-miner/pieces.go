@@ -19,6 +19,8 @@ var piecesCmd = &cli.Command{
 		piecesListCidInfosCmd,
 		piecesInfoCmd,
 		piecesCidInfoCmd,
+		piecesLocateCmd,
+		piecesRebuildIndexCmd,
 	},
 }
 
@@ -138,3 +140,99 @@ var piecesCidInfoCmd = &cli.Command{
 		return w.Flush()
 	},
 }
+
+var piecesLocateCmd = &cli.Command{
+	Name:      "locate",
+	Usage:     "locate a payload block in the sectors of the miner",
+	ArgsUsage: "[blockCid]",
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return lcli.ShowHelp(cctx, fmt.Errorf("must specify block cid"))
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		c, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		locs, err := nodeApi.PiecesLocateBlock(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		if len(locs) == 0 {
+			fmt.Println("block not found in any piece")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PieceCid\tDealID\tSectorID\tPieceOffset\tBlockOffset\tBlockSize")
+		for _, l := range locs {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", l.PieceCID, l.DealID, l.Sector, l.PieceOffset, l.BlockOffset, l.BlockSize)
+		}
+		return w.Flush()
+	},
+}
+
+var piecesRebuildIndexCmd = &cli.Command{
+	Name:      "rebuild-index",
+	Usage:     "record the locations of the payload blocks of pieces, read from unsealed sectors",
+	ArgsUsage: "[pieceCid ...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "rebuild the index of all registered pieces",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() && !cctx.Bool("all") {
+			return lcli.ShowHelp(cctx, fmt.Errorf("must specify piece cids or --all"))
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		var pieces []cid.Cid
+		if cctx.Bool("all") {
+			pieces, err = nodeApi.PiecesListPieces(ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			for _, s := range cctx.Args().Slice() {
+				c, err := cid.Decode(s)
+				if err != nil {
+					return fmt.Errorf("parsing piece cid %s: %w", s, err)
+				}
+				pieces = append(pieces, c)
+			}
+		}
+
+		var failed int
+		for _, pc := range pieces {
+			n, err := nodeApi.PiecesRebuildIndex(ctx, pc)
+			if err != nil {
+				fmt.Printf("%s: %s\n", pc, err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: %d blocks\n", pc, n)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to rebuild the index of %d pieces", failed)
+		}
+		return nil
+	},
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/fatih/color"
@@ -17,6 +18,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
+	lstorage "github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/specs-storage/storage"
 )
 
@@ -29,6 +31,9 @@ var provingCmd = &cli.Command{
 		provingDeadlineInfoCmd,
 		provingFaultsCmd,
 		provingCheckProvableCmd,
+		provingPostsCmd,
+		provingPrechecksCmd,
+		provingRecoveriesCmd,
 	},
 }
 
@@ -388,7 +393,15 @@ var provingCheckProvableCmd = &cli.Command{
 		},
 		&cli.BoolFlag{
 			Name:  "slow",
-			Usage: "run slower checks",
+			Usage: "read the sealed data of all sectors at random challenges, same as --sample-rate=1",
+		},
+		&cli.Float64Flag{
+			Name:  "sample-rate",
+			Usage: "fraction (0 to 1) of the sectors to read the sealed data of at random challenges, to find corrupt data",
+		},
+		&cli.BoolFlag{
+			Name:  "faulty-only",
+			Usage: "only check the sectors which are faulty on chain, e.g. before declaring recoveries",
 		},
 	},
 	Action: func(cctx *cli.Context) error {
@@ -396,6 +409,11 @@ var provingCheckProvableCmd = &cli.Command{
 			return xerrors.Errorf("must pass deadline index")
 		}
 
+		sampleRate := cctx.Float64("sample-rate")
+		if cctx.Bool("slow") {
+			sampleRate = 1
+		}
+
 		dlIdx, err := strconv.ParseUint(cctx.Args().Get(0), 10, 64)
 		if err != nil {
 			return xerrors.Errorf("could not parse deadline index: %w", err)
@@ -446,7 +464,12 @@ var provingCheckProvableCmd = &cli.Command{
 		for parIdx, par := range partitions {
 			sectors := make(map[abi.SectorNumber]struct{})
 
-			sectorInfos, err := api.StateMinerSectors(ctx, addr, &par.LiveSectors, types.EmptyTSK)
+			toCheck := par.LiveSectors
+			if cctx.Bool("faulty-only") {
+				toCheck = par.FaultySectors
+			}
+
+			sectorInfos, err := api.StateMinerSectors(ctx, addr, &toCheck, types.EmptyTSK)
 			if err != nil {
 				return err
 			}
@@ -463,7 +486,7 @@ var provingCheckProvableCmd = &cli.Command{
 				})
 			}
 
-			bad, err := sapi.CheckProvable(ctx, pf, tocheck, cctx.Bool("slow"))
+			bad, err := sapi.CheckProvableSampled(ctx, pf, tocheck, sampleRate)
 			if err != nil {
 				return err
 			}
@@ -480,3 +503,244 @@ var provingCheckProvableCmd = &cli.Command{
 		return tw.Flush()
 	},
 }
+
+var provingPostsCmd = &cli.Command{
+	Name:  "posts",
+	Usage: "List the window PoSts which landed on chain during recent proving periods",
+	Description: `For every deadline, list the window PoSt messages this miner submitted which
+executed successfully, with the partitions they proved and the number of sectors
+they skipped. The miner actor verifies posts when they're submitted, so posts
+which landed were valid; deadlines without any post had their sectors marked
+faulty.`,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "periods",
+			Usage: "number of proving periods to look back",
+			Value: 1,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		color.NoColor = !cctx.Bool("color")
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := getActorAddress(ctx, nodeApi, cctx.String("actor"))
+		if err != nil {
+			return err
+		}
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting chain head: %w", err)
+		}
+
+		di, err := api.StateMinerProvingDeadline(ctx, maddr, head.Key())
+		if err != nil {
+			return xerrors.Errorf("getting proving deadline: %w", err)
+		}
+
+		stop := head.Height() - di.WPoStProvingPeriod*abi.ChainEpoch(cctx.Int("periods"))
+		subs, err := lstorage.FindWdPoStSubmissions(ctx, api, maddr, head, stop)
+		if err != nil {
+			return xerrors.Errorf("finding window post messages: %w", err)
+		}
+
+		byDeadline := make(map[uint64][]lstorage.WdPoStSubmission, di.WPoStPeriodDeadlines)
+		for _, sub := range subs {
+			byDeadline[sub.Params.Deadline] = append(byDeadline[sub.Params.Deadline], sub)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "deadline\theight\tmessage\tpartitions\tskipped")
+
+		for dlIdx := uint64(0); dlIdx < di.WPoStPeriodDeadlines; dlIdx++ {
+			if len(byDeadline[dlIdx]) == 0 {
+				_, _ = fmt.Fprintf(tw, "%d\t-\t-\t-\t%s\n", dlIdx, color.YellowString("no posts found"))
+				continue
+			}
+
+			for _, sub := range byDeadline[dlIdx] {
+				var parts []string
+				var skipped uint64
+				for _, p := range sub.Params.Partitions {
+					parts = append(parts, strconv.FormatUint(p.Index, 10))
+
+					n, err := p.Skipped.Count()
+					if err != nil {
+						return xerrors.Errorf("counting skipped sectors: %w", err)
+					}
+					skipped += n
+				}
+
+				_, _ = fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%d\n", dlIdx, sub.TipSet.Height(), sub.Message, strings.Join(parts, ","), skipped)
+			}
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Printf("\n%d posts landed\n", len(subs))
+		return nil
+	},
+}
+
+var provingPrechecksCmd = &cli.Command{
+	Name:  "prechecks",
+	Usage: "View results of sector checks run before deadlines open",
+	Description: `Lists the results of the last check of each deadline, run Storage.PoStPrecheckEpochs
+before the deadline opens. Faults are declared for sectors which failed the check.`,
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		checks, err := nodeApi.ProvingPrechecks(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(checks) == 0 {
+			fmt.Println("No deadlines were pre-checked, is Storage.PoStPrecheckEpochs set?")
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "deadline\topen\tchecked at\tpartition\tsectors\tfaulty\tdeclared")
+		for _, c := range checks {
+			declared := "-"
+			if c.DeclareMessage != nil {
+				declared = c.DeclareMessage.String()
+			}
+			if c.Error != "" {
+				declared = color.RedString("error: %s", c.Error)
+			}
+
+			if len(c.Partitions) == 0 {
+				_, _ = fmt.Fprintf(tw, "%d\t%d\t%d\t-\t0\t0\t%s\n", c.Deadline, c.Open, c.Height, declared)
+				continue
+			}
+
+			for _, p := range c.Partitions {
+				faulty, err := p.Faulty.Count()
+				if err != nil {
+					return err
+				}
+
+				fs := fmt.Sprint(faulty)
+				if faulty > 0 {
+					fs = color.RedString("%d", faulty)
+				}
+
+				_, _ = fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%s\t%s\n", c.Deadline, c.Open, c.Height, p.Index, p.Checked, fs, declared)
+			}
+		}
+		return tw.Flush()
+	},
+}
+
+var provingRecoveriesCmd = &cli.Command{
+	Name:  "recoveries",
+	Usage: "View faulty sectors which can be recovered, and pending recoveries",
+	Description: `Lists the results of the last check of faulty sectors of each deadline. Recoveries are
+declared for sectors which passed the check, unless Storage.PoStDisableRecoveryDeclarations
+is set. Declared recoveries are pending until the next proof of the deadline.`,
+	Action: func(cctx *cli.Context) error {
+		color.NoColor = !cctx.Bool("color")
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := getActorAddress(ctx, nodeApi, cctx.String("actor"))
+		if err != nil {
+			return err
+		}
+
+		checks, err := nodeApi.ProvingRecoveries(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(checks) == 0 {
+			fmt.Println("No faulty sectors were checked")
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "deadline\tchecked at\tpartition\tfaulty\trecoverable\tpending\tdeclared")
+		for _, c := range checks {
+			partitions, err := api.StateMinerPartitions(ctx, maddr, c.Deadline, types.EmptyTSK)
+			if err != nil {
+				return xerrors.Errorf("getting partitions of deadline %d: %w", c.Deadline, err)
+			}
+
+			declared := "-"
+			if c.DeclareMessage != nil {
+				declared = c.DeclareMessage.String()
+			}
+			if c.Error != "" {
+				declared = color.RedString("error: %s", c.Error)
+			}
+
+			if len(c.Partitions) == 0 {
+				_, _ = fmt.Fprintf(tw, "%d\t%d\t-\t0\t0\t0\t%s\n", c.Deadline, c.Height, declared)
+				continue
+			}
+
+			for _, p := range c.Partitions {
+				faulty, err := p.Faulty.Count()
+				if err != nil {
+					return err
+				}
+				recovered, err := p.Recovered.Count()
+				if err != nil {
+					return err
+				}
+
+				// recoveries declared on chain, proven by the next proof of the deadline
+				var pending uint64
+				if p.Index < uint64(len(partitions)) {
+					pending, err = partitions[p.Index].RecoveringSectors.Count()
+					if err != nil {
+						return err
+					}
+				}
+
+				rs := fmt.Sprint(recovered)
+				if recovered > 0 && !c.Declared {
+					rs = color.YellowString("%d", recovered)
+				}
+
+				_, _ = fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%s\t%d\t%s\n", c.Deadline, c.Height, p.Index, faulty, rs, pending, declared)
+			}
+		}
+		return tw.Flush()
+	},
+}
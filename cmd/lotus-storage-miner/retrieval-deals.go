@@ -22,6 +22,7 @@ var retrievalDealsCmd = &cli.Command{
 		retrievalDealsListCmd,
 		retrievalSetAskCmd,
 		retrievalGetAskCmd,
+		retrievalHTTPTokenCmd,
 	},
 }
 
@@ -268,3 +269,28 @@ var retrievalGetAskCmd = &cli.Command{
 
 	},
 }
+
+var retrievalHTTPTokenCmd = &cli.Command{
+	Name:  "http-token",
+	Usage: "Create a token for clients retrieving over the HTTP transport",
+	Description: `Clients pass the token to lotus client retrieve with --http-token. It
+   only gives access to the HTTP transport, retrievals over it are still paid
+   for at the current retrieval ask.`,
+	Action: func(cctx *cli.Context) error {
+		ctx := lcli.DaemonContext(cctx)
+
+		api, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		token, err := api.MarketHTTPRetrievalToken(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(token)
+		return nil
+	},
+}
@@ -24,6 +24,8 @@ import (
 	"github.com/filecoin-project/lotus/api/apistruct"
 	"github.com/filecoin-project/lotus/build"
 	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/cborrpc"
+	"github.com/filecoin-project/lotus/lib/rpcenc"
 	"github.com/filecoin-project/lotus/lib/ulimit"
 	"github.com/filecoin-project/lotus/metrics"
 	"github.com/filecoin-project/lotus/node"
@@ -54,8 +56,22 @@ var runCmd = &cli.Command{
 			Usage: "manage open file limit",
 			Value: true,
 		},
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "subsystems to run: 'full', or 'markets' with a repo set up with 'lotus-miner init markets'",
+			Value: "full",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
+		var markets bool
+		switch cctx.String("type") {
+		case "full":
+		case "markets":
+			markets = true
+		default:
+			return xerrors.Errorf("unknown node type %q, expected 'full' or 'markets'", cctx.String("type"))
+		}
+
 		if !cctx.Bool("enable-gpu-proving") {
 			err := os.Setenv("BELLMAN_NO_GPU", "true")
 			if err != nil {
@@ -128,6 +144,7 @@ var runCmd = &cli.Command{
 					return multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/" + cctx.String("miner-api"))
 				})),
 			node.Override(new(api.FullNode), nodeApi),
+			node.If(markets, node.StorageMarkets()),
 		)
 		if err != nil {
 			return xerrors.Errorf("creating node: %w", err)
@@ -157,11 +174,18 @@ var runCmd = &cli.Command{
 
 		mux := mux.NewRouter()
 
-		rpcServer := jsonrpc.NewServer()
-		rpcServer.Register("Filecoin", apistruct.PermissionedStorMinerAPI(metrics.MetricedStorMinerAPI(minerapi)))
+		readerHandler, readerServerOpt := rpcenc.ReaderParamDecoder()
+		rpcServer := jsonrpc.NewServer(readerServerOpt)
+		pma := apistruct.PermissionedStorMinerAPI(metrics.MetricedStorMinerAPI(minerapi))
+		rpcServer.Register("Filecoin", pma)
 
-		mux.Handle("/rpc/v0", rpcServer)
-		mux.PathPrefix("/remote").HandlerFunc(minerapi.(*impl.StorageMinerAPI).ServeRemote)
+		mux.Handle("/rpc/v0", cborrpc.NewHandler("Filecoin", pma, rpcServer))
+		mux.Handle("/rpc/streams/v0/push/{uuid}", readerHandler)
+		if !markets {
+			mux.PathPrefix("/remote").HandlerFunc(minerapi.(*impl.StorageMinerAPI).ServeRemote)
+			mux.HandleFunc("/piece/{sector}/{offset}/{size}", minerapi.(*impl.StorageMinerAPI).ServePiece)
+		}
+		mux.HandleFunc("/pieces/{cid}", minerapi.(*impl.StorageMinerAPI).ServePieceData).Methods("GET", "HEAD")
 		mux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
 
 		ah := &auth.Handler{
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -15,8 +16,10 @@ import (
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
 )
@@ -28,7 +31,11 @@ var sealingCmd = &cli.Command{
 		sealingJobsCmd,
 		sealingWorkersCmd,
 		sealingSchedDiagCmd,
+		sealingAddPieceQueueCmd,
+		sealingSummaryCmd,
 		sealingAbortCmd,
+		sealingTaskPriorityCmd,
+		sealingDrainCmd,
 	},
 }
 
@@ -37,6 +44,10 @@ var sealingWorkersCmd = &cli.Command{
 	Usage: "list workers",
 	Flags: []cli.Flag{
 		&cli.BoolFlag{Name: "color"},
+		&cli.BoolFlag{
+			Name:  "groups",
+			Usage: "list workers by group, with the number of sectors pinned to them",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		color.NoColor = !cctx.Bool("color")
@@ -68,6 +79,38 @@ var sealingWorkersCmd = &cli.Command{
 			return st[i].id.String() < st[j].id.String()
 		})
 
+		if cctx.Bool("groups") {
+			byGroup := map[string][]sortableStat{}
+			for _, stat := range st {
+				if len(stat.Info.Groups) == 0 {
+					byGroup[""] = append(byGroup[""], stat)
+				}
+				for _, g := range stat.Info.Groups {
+					byGroup[g] = append(byGroup[g], stat)
+				}
+			}
+
+			groups := make([]string, 0, len(byGroup))
+			for g := range byGroup {
+				groups = append(groups, g)
+			}
+			sort.Strings(groups)
+
+			for _, g := range groups {
+				if g == "" {
+					fmt.Println("No group:")
+				} else {
+					fmt.Printf("Group %s:\n", color.CyanString(g))
+				}
+
+				for _, stat := range byGroup[g] {
+					fmt.Printf("\tWorker %s, host %s, %d pinned sector(s)\n", stat.id, color.MagentaString(stat.Info.Hostname), stat.PinnedSectors)
+				}
+			}
+
+			return nil
+		}
+
 		for _, stat := range st {
 			gpuUse := "not "
 			gpuCol := color.FgBlue
@@ -80,8 +123,18 @@ var sealingWorkersCmd = &cli.Command{
 			if !stat.Enabled {
 				disabled = color.RedString(" (disabled)")
 			}
+			if stat.Drained {
+				disabled += color.YellowString(" (drained)")
+			} else if stat.Draining {
+				disabled += color.YellowString(" (draining)")
+			}
+
+			var groups string
+			if len(stat.Info.Groups) > 0 {
+				groups = fmt.Sprintf(", groups %s", strings.Join(stat.Info.Groups, ","))
+			}
 
-			fmt.Printf("Worker %s, host %s%s\n", stat.id, color.MagentaString(stat.Info.Hostname), disabled)
+			fmt.Printf("Worker %s, host %s%s%s\n", stat.id, color.MagentaString(stat.Info.Hostname), groups, disabled)
 
 			var barCols = uint64(64)
 			cpuBars := int(stat.CpuUse * barCols / stat.Info.Resources.CPUs)
@@ -117,6 +170,17 @@ var sealingWorkersCmd = &cli.Command{
 			for _, gpu := range stat.Info.Resources.GPUs {
 				fmt.Printf("\tGPU: %s\n", color.New(gpuCol).Sprintf("%s, %sused", gpu, gpuUse))
 			}
+
+			limited := make([]sealtasks.TaskType, 0, len(stat.Info.Resources.TaskLimits))
+			for tt := range stat.Info.Resources.TaskLimits {
+				limited = append(limited, tt)
+			}
+			sort.Slice(limited, func(i, j int) bool {
+				return limited[i].Less(limited[j])
+			})
+			for _, tt := range limited {
+				fmt.Printf("\t%s:  %d/%d task(s) running\n", tt.Short(), stat.TaskCounts[tt], stat.Info.Resources.TaskLimits[tt])
+			}
 		}
 
 		return nil
@@ -262,6 +326,94 @@ var sealingSchedDiagCmd = &cli.Command{
 	},
 }
 
+var sealingAddPieceQueueCmd = &cli.Command{
+	Name:  "add-piece-queue",
+	Usage: "Show pieces waiting to be added to a sector",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		q, err := nodeApi.SealingAddPieceQueue(ctx)
+		if err != nil {
+			return err
+		}
+
+		max := "no limit"
+		if q.MaxPending > 0 {
+			max = fmt.Sprint(q.MaxPending)
+		}
+
+		fmt.Printf("Pending:     %d (max: %s)\n", q.Pending, max)
+		fmt.Printf("Oldest wait: %s\n", q.OldestWait.Truncate(time.Second))
+		fmt.Printf("Rejected:    %d\n", q.Rejected)
+		fmt.Printf("Timed out:   %d\n", q.TimedOut)
+
+		return nil
+	},
+}
+
+var sealingSummaryCmd = &cli.Command{
+	Name:  "summary",
+	Usage: "Show the number of sectors, the oldest one, and deal data in each sealing state",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the summary as JSON",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		summary, err := nodeApi.SectorsStateSummary(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			b, err := json.MarshalIndent(summary, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			return nil
+		}
+
+		states := make([]api.SectorState, 0, len(summary))
+		for st := range summary {
+			states = append(states, st)
+		}
+		sort.Slice(states, func(i, j int) bool {
+			return states[i] < states[j]
+		})
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "State\tSectors\tOldest\tDeal Data\n")
+		for _, st := range states {
+			s := summary[st]
+
+			oldest := "-"
+			if !s.OldestSince.IsZero() {
+				oldest = time.Since(s.OldestSince).Truncate(time.Second).String()
+			}
+
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", st, s.Count, oldest, types.SizeStr(types.NewInt(uint64(s.DealBytes))))
+		}
+
+		return tw.Flush()
+	},
+}
+
 var sealingAbortCmd = &cli.Command{
 	Name:      "abort",
 	Usage:     "Abort a running job",
@@ -305,3 +457,139 @@ var sealingAbortCmd = &cli.Command{
 		return nodeApi.SealingAbort(ctx, job.ID)
 	},
 }
+
+var sealingTaskPriorityCmd = &cli.Command{
+	Name:      "task-priority",
+	Usage:     "Get or set scheduling priority of sealing task types",
+	ArgsUsage: "[task type (e.g. PC2)] [priority]",
+	Description: `Without arguments, prints the task priorities set on the scheduler.
+   Tasks with higher priority are scheduled first; setting priority 0 restores
+   the default. Explicit priorities (e.g. of sectors with deals) are not changed.`,
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		switch cctx.Args().Len() {
+		case 0:
+			prios, err := nodeApi.SealingTaskPriorities(ctx)
+			if err != nil {
+				return err
+			}
+
+			tts := make([]sealtasks.TaskType, 0, len(prios))
+			for tt := range prios {
+				tts = append(tts, tt)
+			}
+			sort.Slice(tts, func(i, j int) bool {
+				return tts[i].Less(tts[j])
+			})
+
+			for _, tt := range tts {
+				fmt.Printf("%s: %d\n", tt.Short(), prios[tt])
+			}
+			return nil
+		case 2:
+			tt, ok := sealtasks.FromShort(strings.ToUpper(cctx.Args().Get(0)))
+			if !ok {
+				return xerrors.Errorf("unknown task type: %s", cctx.Args().Get(0))
+			}
+
+			prio, err := strconv.Atoi(cctx.Args().Get(1))
+			if err != nil {
+				return xerrors.Errorf("parsing priority: %w", err)
+			}
+
+			return nodeApi.SealingSetTaskPriority(ctx, tt, prio)
+		default:
+			return xerrors.Errorf("expected 0 or 2 arguments")
+		}
+	},
+}
+
+var sealingDrainCmd = &cli.Command{
+	Name:      "drain",
+	Usage:     "Stop assigning new tasks to a worker, letting it finish running tasks",
+	ArgsUsage: "[worker id prefix]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "cancel",
+			Usage: "take the worker out of drain mode",
+		},
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "wait until the worker has finished all tasks",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		stats, err := nodeApi.WorkerStats(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting worker stats: %w", err)
+		}
+
+		var wid uuid.UUID
+		for id := range stats {
+			if strings.HasPrefix(id.String(), cctx.Args().First()) {
+				if wid != uuid.Nil {
+					return xerrors.Errorf("worker id prefix matches multiple workers")
+				}
+				wid = id
+			}
+		}
+		if wid == uuid.Nil {
+			return xerrors.Errorf("worker with specified id prefix not found")
+		}
+
+		if err := nodeApi.WorkerDrain(ctx, wid, !cctx.Bool("cancel")); err != nil {
+			return err
+		}
+
+		if cctx.Bool("cancel") {
+			fmt.Printf("worker %s (%s) is accepting new tasks\n", wid, stats[wid].Info.Hostname)
+			return nil
+		}
+
+		fmt.Printf("draining worker %s (%s)\n", wid, stats[wid].Info.Hostname)
+		if !cctx.Bool("wait") {
+			return nil
+		}
+
+		for {
+			stats, err := nodeApi.WorkerStats(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting worker stats: %w", err)
+			}
+
+			st, ok := stats[wid]
+			if !ok {
+				return xerrors.Errorf("worker disconnected")
+			}
+			if st.Drained {
+				fmt.Println("worker drained")
+				return nil
+			}
+
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	},
+}
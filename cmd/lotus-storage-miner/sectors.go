@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"sort"
@@ -13,10 +14,13 @@ import (
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/actors/policy"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -31,16 +35,20 @@ var sectorsCmd = &cli.Command{
 	Usage: "interact with sector store",
 	Subcommands: []*cli.Command{
 		sectorsStatusCmd,
+		sectorsHistoryCmd,
 		sectorsListCmd,
 		sectorsRefsCmd,
 		sectorsUpdateCmd,
 		sectorsPledgeCmd,
 		sectorsTerminateCmd,
 		sectorsRemoveCmd,
+		sectorsAbortCmd,
+		sectorsRegenCacheCmd,
 		sectorsMarkForUpgradeCmd,
 		sectorsStartSealCmd,
 		sectorsSealDelayCmd,
 		sectorsCapacityCollateralCmd,
+		sectorsRenewCmd,
 	},
 }
 
@@ -68,6 +76,10 @@ var sectorsStatusCmd = &cli.Command{
 			Name:  "log",
 			Usage: "display event log",
 		},
+		&cli.BoolFlag{
+			Name:  "events",
+			Usage: "display a timeline of state transitions and events",
+		},
 		&cli.BoolFlag{
 			Name:  "on-chain-info",
 			Usage: "show sector on chain info",
@@ -109,6 +121,12 @@ var sectorsStatusCmd = &cli.Command{
 		fmt.Printf("Proof:\t\t%x\n", status.Proof)
 		fmt.Printf("Deals:\t\t%v\n", status.Deals)
 		fmt.Printf("Retries:\t%d\n", status.Retries)
+		if status.FailureRetries > 0 {
+			fmt.Printf("Failure Retries:\t%d\n", status.FailureRetries)
+		}
+		if status.NextRetry > 0 {
+			fmt.Printf("Next Retry:\t%s\n", time.Unix(int64(status.NextRetry), 0).Format(time.RFC3339))
+		}
 		if status.LastErr != "" {
 			fmt.Printf("Last Error:\t\t%s\n", status.LastErr)
 		}
@@ -124,6 +142,22 @@ var sectorsStatusCmd = &cli.Command{
 			fmt.Printf("\nExpiration Info\n")
 			fmt.Printf("OnTime:\t\t%v\n", status.OnTime)
 			fmt.Printf("Early:\t\t%v\n", status.Early)
+			if status.PreCommitEpoch != 0 {
+				fmt.Printf("PreCommitEpoch:\t%v\n", status.PreCommitEpoch)
+			}
+			if status.Deadline != nil && status.Partition != nil {
+				fmt.Printf("Deadline:\t%d\n", *status.Deadline)
+				fmt.Printf("Partition:\t%d\n", *status.Partition)
+				fmt.Printf("Faulty:\t\t%t\n", status.Faulty)
+				fmt.Printf("Recovering:\t%t\n", status.Recovering)
+			}
+
+			if len(status.Discrepancies) > 0 {
+				fmt.Printf("\nDiscrepancies With Chain State\n")
+				for _, d := range status.Discrepancies {
+					fmt.Printf("  %s\n", d)
+				}
+			}
 		}
 
 		if cctx.Bool("log") {
@@ -136,10 +170,194 @@ var sectorsStatusCmd = &cli.Command{
 				}
 			}
 		}
+
+		if cctx.Bool("events") {
+			history, err := nodeApi.SectorsHistory(ctx, abi.SectorNumber(id))
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("--------\nTimeline:\n")
+			return printSectorTimeline(status, history)
+		}
 		return nil
 	},
 }
 
+type timelineEntry struct {
+	at     time.Time
+	what   string
+	detail string
+}
+
+// printSectorTimeline merges the event log of the sector with its recorded
+// state transitions, and summarizes how the sector was packed
+func printSectorTimeline(status api.SectorInfo, history []api.SectorStateTransition) error {
+	var entries []timelineEntry
+	var deals []abi.DealID
+	var lastDeal time.Time
+
+	for _, l := range status.Log {
+		e := timelineEntry{
+			at:   time.Unix(int64(l.Timestamp), 0),
+			what: l.Kind,
+		}
+
+		if strings.HasPrefix(l.Kind, "event;") {
+			kind := strings.TrimPrefix(l.Kind, "event;")
+			e.what = kind[strings.LastIndex(kind, ".")+1:]
+		}
+
+		switch {
+		case e.what == "SectorAddPiece":
+			var evt sealing.SectorAddPiece
+			if err := json.Unmarshal([]byte(l.Message), &evt); err == nil && evt.NewPiece.DealInfo != nil {
+				e.detail = fmt.Sprintf("deal %d", evt.NewPiece.DealInfo.DealID)
+				deals = append(deals, evt.NewPiece.DealInfo.DealID)
+				lastDeal = e.at
+			}
+		case l.Trace != "":
+			e.detail = strings.SplitN(l.Message, "\n", 2)[0]
+		}
+
+		entries = append(entries, e)
+	}
+
+	var waited time.Duration
+	var packed *api.SectorStateTransition
+	for i, tr := range history {
+		e := timelineEntry{
+			at:     tr.Time,
+			what:   fmt.Sprintf("%s -> %s", tr.From, tr.To),
+			detail: tr.Error,
+		}
+		entries = append(entries, e)
+
+		if tr.From == api.SectorState(sealing.WaitDeals) {
+			waited += tr.Duration
+			if packed == nil {
+				packed = &history[i]
+			}
+		}
+	}
+
+	if n := len(history); n > 0 && history[n-1].To == api.SectorState(sealing.WaitDeals) {
+		waited += time.Since(history[n-1].Time)
+	}
+
+	// log timestamps only have second precision, events go before the state
+	// transitions they caused
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].at.Unix() < entries[j].at.Unix()
+	})
+
+	tw := tablewriter.New(
+		tablewriter.Col("Time"),
+		tablewriter.Col("Elapsed"),
+		tablewriter.Col("Event"),
+		tablewriter.NewLineCol("Details"))
+
+	for i, e := range entries {
+		m := map[string]interface{}{
+			"Time":  e.at.Local().Format(time.Stamp),
+			"Event": e.what,
+		}
+		if i > 0 {
+			m["Elapsed"] = "+" + e.at.Sub(entries[i-1].at).Truncate(time.Second).String()
+		}
+		if e.detail != "" {
+			m["Details"] = e.detail
+		}
+		tw.Write(m)
+	}
+
+	if err := tw.Flush(os.Stdout); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("Retries:\t%d\n", status.Retries)
+	fmt.Printf("In WaitDeals:\t%s\n", waited.Truncate(time.Second))
+	if packed != nil {
+		fmt.Printf("Packed by:\t%s\n", packed.Trigger)
+		if !lastDeal.IsZero() && !lastDeal.After(packed.Time) {
+			fmt.Printf("Last Deal:\t%d, added %s before packing\n", deals[len(deals)-1], packed.Time.Sub(lastDeal).Truncate(time.Second))
+		}
+	}
+	fmt.Printf("Deals:\t\t%v\n", deals)
+
+	return nil
+}
+
+var sectorsHistoryCmd = &cli.Command{
+	Name:      "history",
+	Usage:     "Show the state transitions of a sector, with how long it spent in each state",
+	ArgsUsage: "<sectorNum>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the transitions as JSON",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must specify sector number to get history of")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		history, err := nodeApi.SectorsHistory(ctx, abi.SectorNumber(id))
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			b, err := json.MarshalIndent(history, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			return nil
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Time"),
+			tablewriter.Col("From"),
+			tablewriter.Col("To"),
+			tablewriter.Col("Trigger"),
+			tablewriter.Col("Time In From"),
+			tablewriter.NewLineCol("Error"))
+
+		for _, tr := range history {
+			m := map[string]interface{}{
+				"Time":    tr.Time.Local().Format(time.Stamp),
+				"From":    tr.From,
+				"To":      tr.To,
+				"Trigger": tr.Trigger,
+			}
+			if tr.Duration > 0 {
+				m["Time In From"] = tr.Duration.Truncate(time.Second)
+			}
+			if tr.Error != "" {
+				m["Error"] = tr.Error
+			}
+			tw.Write(m)
+		}
+
+		return tw.Flush(os.Stdout)
+	},
+}
+
 var sectorsListCmd = &cli.Command{
 	Name:  "list",
 	Usage: "List sectors",
@@ -544,6 +762,59 @@ var sectorsRemoveCmd = &cli.Command{
 	},
 }
 
+var sectorsAbortCmd = &cli.Command{
+	Name:      "abort",
+	Usage:     "Abort a sector which didn't start sealing yet, moving its deals to other sectors",
+	ArgsUsage: "<sectorNum>",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass sector number")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector number: %w", err)
+		}
+
+		return nodeApi.SectorAbort(ctx, abi.SectorNumber(id))
+	},
+}
+
+var sectorsRegenCacheCmd = &cli.Command{
+	Name:      "regen-cache",
+	Usage:     "Regenerate lost or corrupted cache files of a sealed sector by sealing it again",
+	ArgsUsage: "<sectorNum>",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass sector number")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector number: %w", err)
+		}
+
+		if err := nodeApi.SectorRegenerateCache(ctx, abi.SectorNumber(id)); err != nil {
+			return err
+		}
+
+		fmt.Printf("Regenerated cache of sector %d\n", id)
+		return nil
+	},
+}
+
 var sectorsMarkForUpgradeCmd = &cli.Command{
 	Name:      "mark-for-upgrade",
 	Usage:     "Mark a committed capacity sector for replacement by a sector with deals",
@@ -714,3 +985,267 @@ func yesno(b bool) string {
 	}
 	return color.RedString("NO")
 }
+
+var sectorsRenewCmd = &cli.Command{
+	Name:  "renew",
+	Usage: "Analyze and extend the expiration of sectors",
+	Description: `Lists candidate sectors with the quality adjusted power kept and lost by
+extending them to the new expiration (deal weight is spread over the longer
+sector lifetime), the pledge kept locked, and the estimated message cost.
+With --really-do-it, the extension is sent in batched messages.`,
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "new-expiration",
+			Usage: "epoch to extend the sectors to",
+		},
+		&cli.Int64Flag{
+			Name:  "extension",
+			Usage: "number of epochs to extend the sectors by, if --new-expiration isn't set",
+			Value: 180 * builtin.EpochsInDay,
+		},
+		&cli.Int64Flag{
+			Name:  "expiring-within",
+			Usage: "consider sectors expiring within this many epochs",
+			Value: 30 * builtin.EpochsInDay,
+		},
+		&cli.Int64SliceFlag{
+			Name:  "sectors",
+			Usage: "consider only the listed sectors",
+		},
+		&cli.BoolFlag{
+			Name:  "keep-qap",
+			Usage: "skip sectors which would lose quality adjusted power",
+		},
+		&cli.IntFlag{
+			Name:  "max-sectors",
+			Usage: "maximum number of sectors extended in a single message",
+			Value: int(miner.AddressedSectorsMax),
+		},
+		&cli.StringFlag{
+			Name:  "max-fee",
+			Usage: "maximum fee for each message",
+			Value: "0.1",
+		},
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "send the extension messages",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		mApi, mCloser, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer mCloser()
+
+		nApi, nCloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer nCloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := mApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		head, err := nApi.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		mi, err := nApi.StateMinerInfo(ctx, maddr, head.Key())
+		if err != nil {
+			return err
+		}
+
+		maxFee, err := types.ParseFIL(cctx.String("max-fee"))
+		if err != nil {
+			return xerrors.Errorf("parsing max-fee: %w", err)
+		}
+
+		if cctx.Int("max-sectors") <= 0 {
+			return xerrors.Errorf("--max-sectors must be positive")
+		}
+
+		newExp := abi.ChainEpoch(cctx.Int64("new-expiration"))
+		if newExp == 0 {
+			newExp = head.Height() + abi.ChainEpoch(cctx.Int64("extension"))
+		}
+		if maxExp := head.Height() + policy.GetMaxSectorExpirationExtension(); newExp > maxExp {
+			return xerrors.Errorf("new expiration %d is past the maximum extension (epoch %d)", newExp, maxExp)
+		}
+
+		sectors, err := nApi.StateMinerActiveSectors(ctx, maddr, head.Key())
+		if err != nil {
+			return xerrors.Errorf("getting active sectors: %w", err)
+		}
+
+		only := map[abi.SectorNumber]struct{}{}
+		for _, s := range cctx.Int64Slice("sectors") {
+			only[abi.SectorNumber(s)] = struct{}{}
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("ID"),
+			tablewriter.Col("Expiration"),
+			tablewriter.Col("QAP"),
+			tablewriter.Col("QAP(Extended)"),
+			tablewriter.Col("Pledge"),
+			tablewriter.Col("Skip"))
+
+		// extensions by deadline and partition
+		type partKey struct {
+			dl, part uint64
+		}
+		byPart := map[partKey][]uint64{}
+		var parts []partKey
+
+		qapKept, qapLost, pledge := big.Zero(), big.Zero(), big.Zero()
+		var extended int
+
+		sort.Slice(sectors, func(i, j int) bool {
+			return sectors[i].SectorNumber < sectors[j].SectorNumber
+		})
+
+		for _, s := range sectors {
+			if len(only) > 0 {
+				if _, ok := only[s.SectorNumber]; !ok {
+					continue
+				}
+			} else if s.Expiration > head.Height()+abi.ChainEpoch(cctx.Int64("expiring-within")) {
+				continue
+			}
+
+			oldQAP := builtin.QAPowerForWeight(mi.SectorSize, s.Expiration-s.Activation, s.DealWeight, s.VerifiedDealWeight)
+			newQAP := builtin.QAPowerForWeight(mi.SectorSize, newExp-s.Activation, s.DealWeight, s.VerifiedDealWeight)
+
+			skip := ""
+			switch {
+			case s.Expiration >= newExp:
+				skip = "expires after new expiration"
+			case newQAP.LessThan(oldQAP) && cctx.Bool("keep-qap"):
+				skip = "loses QAP"
+			}
+
+			tw.Write(map[string]interface{}{
+				"ID":            s.SectorNumber,
+				"Expiration":    lcli.EpochTime(head.Height(), s.Expiration),
+				"QAP":           types.SizeStr(oldQAP),
+				"QAP(Extended)": types.SizeStr(newQAP),
+				"Pledge":        types.FIL(s.InitialPledge).Short(),
+				"Skip":          skip,
+			})
+
+			if skip != "" {
+				continue
+			}
+
+			loc, err := nApi.StateSectorPartition(ctx, maddr, s.SectorNumber, head.Key())
+			if err != nil {
+				return xerrors.Errorf("getting location of sector %d: %w", s.SectorNumber, err)
+			}
+
+			k := partKey{dl: loc.Deadline, part: loc.Partition}
+			if _, ok := byPart[k]; !ok {
+				parts = append(parts, k)
+			}
+			byPart[k] = append(byPart[k], uint64(s.SectorNumber))
+
+			qapKept = big.Add(qapKept, newQAP)
+			qapLost = big.Add(qapLost, big.Sub(oldQAP, newQAP))
+			pledge = big.Add(pledge, s.InitialPledge)
+			extended++
+		}
+
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+
+		// batch the extensions into messages, respecting the declaration and
+		// addressed sector limits
+		var batches []*miner.ExtendSectorExpirationParams
+		cur := &miner.ExtendSectorExpirationParams{}
+		var curSectors int
+		for _, k := range parts {
+			secs := byPart[k]
+			for len(secs) > 0 {
+				if len(cur.Extensions) >= int(miner.DeclarationsMax) || curSectors >= cctx.Int("max-sectors") {
+					batches = append(batches, cur)
+					cur = &miner.ExtendSectorExpirationParams{}
+					curSectors = 0
+				}
+
+				n := cctx.Int("max-sectors") - curSectors
+				if n > len(secs) {
+					n = len(secs)
+				}
+
+				cur.Extensions = append(cur.Extensions, miner.ExpirationExtension{
+					Deadline:      k.dl,
+					Partition:     k.part,
+					Sectors:       bitfield.NewFromSet(secs[:n]),
+					NewExpiration: newExp,
+				})
+				curSectors += n
+				secs = secs[n:]
+			}
+		}
+		if len(cur.Extensions) > 0 {
+			batches = append(batches, cur)
+		}
+
+		var msgs []*types.Message
+		cost := big.Zero()
+		for i, params := range batches {
+			enc, aerr := actors.SerializeParams(params)
+			if aerr != nil {
+				return xerrors.Errorf("serializing params: %w", aerr)
+			}
+
+			msg := &types.Message{
+				From:   mi.Worker,
+				To:     maddr,
+				Method: miner.Methods.ExtendSectorExpiration,
+				Value:  big.Zero(),
+				Params: enc,
+			}
+
+			est, err := nApi.GasEstimateMessageGas(ctx, msg, &api.MessageSendSpec{MaxFee: abi.TokenAmount(maxFee)}, head.Key())
+			if err != nil {
+				return xerrors.Errorf("estimating gas of message %d: %w", i, err)
+			}
+
+			cost = big.Add(cost, est.RequiredFunds())
+			msgs = append(msgs, msg)
+		}
+
+		fmt.Println()
+		fmt.Printf("New expiration:   %s\n", lcli.EpochTime(head.Height(), newExp))
+		fmt.Printf("Sectors extended: %d\n", extended)
+		fmt.Printf("QAP kept:         %s\n", types.SizeStr(qapKept))
+		fmt.Printf("QAP lost:         %s\n", types.SizeStr(qapLost))
+		fmt.Printf("Pledge kept:      %s\n", types.FIL(pledge))
+		fmt.Printf("Messages:         %d (max cost %s)\n", len(msgs), types.FIL(cost))
+
+		if !cctx.Bool("really-do-it") {
+			if len(msgs) > 0 {
+				fmt.Println("Pass --really-do-it to send the extension messages")
+			}
+			return nil
+		}
+
+		for i, msg := range msgs {
+			smsg, err := nApi.MpoolPushMessage(ctx, msg, &api.MessageSendSpec{MaxFee: abi.TokenAmount(maxFee)})
+			if err != nil {
+				return xerrors.Errorf("pushing message %d: %w", i, err)
+			}
+
+			fmt.Printf("Extension message %d: %s\n", i, smsg.Cid())
+		}
+
+		return nil
+	},
+}
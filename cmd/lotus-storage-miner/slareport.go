@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/storage/slareport"
+)
+
+var slaReportCmd = &cli.Command{
+	Name:  "sla-report",
+	Usage: "Generate and verify signed reports of proving, deal and retrieval performance",
+	Description: `SLA reports cover window PoSt deadlines met and missed, sector faults and recovery times,
+deal activation latency and retrieval serving stats over a range of epochs. Reports are signed
+by the worker key, and are generated periodically when SLAReports.Interval is set.`,
+	Subcommands: []*cli.Command{
+		slaReportGenerateCmd,
+		slaReportListCmd,
+		slaReportVerifyCmd,
+	},
+}
+
+var slaReportGenerateCmd = &cli.Command{
+	Name:  "generate",
+	Usage: "Generate a signed report, printed as JSON",
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "to",
+			Usage: "last epoch covered by the report (default: chain head)",
+		},
+		&cli.Int64Flag{
+			Name:  "epochs",
+			Usage: "number of epochs covered by the report",
+			Value: int64(24 * time.Hour / (time.Duration(build.BlockDelaySecs) * time.Second)),
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "write the report to a file",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		fapi, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		to := abi.ChainEpoch(cctx.Int64("to"))
+		if !cctx.IsSet("to") {
+			head, err := fapi.ChainHead(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting chain head: %w", err)
+			}
+			to = head.Height()
+		}
+
+		sr, err := nodeApi.SLAReportGenerate(ctx, to-abi.ChainEpoch(cctx.Int64("epochs")), to)
+		if err != nil {
+			return err
+		}
+
+		return writeSLAReport(cctx.String("output"), sr)
+	},
+}
+
+func writeSLAReport(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		fmt.Println(string(b))
+		return nil
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+var slaReportListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List generated reports",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "number of reports to list, newest first (0 for all)",
+			Value: 10,
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the signed reports as JSON",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		reports, err := nodeApi.SLAReportList(ctx, cctx.Int("limit"))
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			return writeSLAReport("", reports)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "from\tto\tgenerated\tdeadlines met\tmissed\tnew faults\trecovered\tdeals activated\tmedian activation\tretrievals\tfailed")
+		for _, sr := range reports {
+			r := sr.Report
+			_, _ = fmt.Fprintf(tw, "%d\t%d\t%s\t%d/%d\t%d\t%d\t%d\t%d\t%s\t%d\t%d\n",
+				r.From, r.To, r.Generated.Local().Format(time.Stamp),
+				r.Proving.DeadlinesMet, r.Proving.DeadlinesDue, r.Proving.DeadlinesMissed,
+				r.Proving.NewFaults, r.Proving.RecoveredSectors,
+				r.Deals.Activated, r.Deals.MedianActivationLatency.Truncate(time.Second),
+				r.Retrievals.Completed, r.Retrievals.Failed)
+		}
+		return tw.Flush()
+	},
+}
+
+var slaReportVerifyCmd = &cli.Command{
+	Name:      "verify",
+	Usage:     "Verify the signature of a report",
+	ArgsUsage: "[report file]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected a report file")
+		}
+
+		b, err := ioutil.ReadFile(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		var sr api.SignedSLAReport
+		if err := json.Unmarshal(b, &sr); err != nil {
+			return xerrors.Errorf("decoding report: %w", err)
+		}
+
+		fapi, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		if err := slareport.Verify(lcli.ReqContext(cctx), fapi, &sr); err != nil {
+			return err
+		}
+
+		fmt.Printf("Report of miner %s for epochs %d-%d is signed by worker %s\n", sr.Report.Miner, sr.Report.From, sr.Report.To, sr.Report.Worker)
+		fmt.Printf("Deadlines met: %d/%d\n", sr.Report.Proving.DeadlinesMet, sr.Report.Proving.DeadlinesDue)
+		fmt.Printf("Deals activated: %d\n", sr.Report.Deals.Activated)
+		fmt.Printf("Retrieved data: %s\n", types.SizeStr(types.NewInt(sr.Report.Retrievals.BytesSent)))
+
+		return nil
+	},
+}
@@ -45,6 +45,7 @@ stored while moving through the sealing pipeline (references as 'seal').`,
 		storageListCmd,
 		storageFindCmd,
 		storageCleanupCmd,
+		storageTransfersCmd,
 	},
 }
 
@@ -69,6 +70,11 @@ Data for the sealing process will be stored here
 Store
 Finalized sectors that will be moved here for long term storage and be proven
 over time
+
+Failure Domain
+Paths which can fail together (e.g. disks in the same host) should share a
+failure domain label. When sector replication is enabled, sealed and cache
+copies of a sector are kept in distinct failure domains
    `,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
@@ -88,6 +94,10 @@ over time
 			Name:  "store",
 			Usage: "(for init) use path for long-term storage",
 		},
+		&cli.StringFlag{
+			Name:  "failure-domain",
+			Usage: "(for init) label of the disk / host the path is on, sector replicas are kept in distinct failure domains",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
@@ -126,6 +136,8 @@ over time
 				Weight:   cctx.Uint64("weight"),
 				CanSeal:  cctx.Bool("seal"),
 				CanStore: cctx.Bool("store"),
+
+				FailureDomain: cctx.String("failure-domain"),
 			}
 
 			if !(cfg.CanStore || cfg.CanSeal) {
@@ -220,6 +232,14 @@ var storageListCmd = &cli.Command{
 			}
 			ping := time.Now().Sub(pingStart)
 
+			health, err := nodeApi.StorageHealth(ctx, s.ID)
+			if err != nil {
+				return err
+			}
+			if !health.Healthy {
+				fmt.Printf("\t%s (not used for new sectors): %s\n", color.RedString("Unhealthy"), health.Err)
+			}
+
 			usedPercent := (st.Capacity - st.Available) * 100 / st.Capacity
 
 			percCol := color.FgGreen
@@ -260,6 +280,9 @@ var storageListCmd = &cli.Command{
 				if si.CanStore {
 					fmt.Print(color.CyanString("Store"))
 				}
+				if si.FailureDomain != "" {
+					fmt.Printf("; Failure Domain: %s", si.FailureDomain)
+				}
 				fmt.Println("")
 			} else {
 				fmt.Print(color.HiYellowString("Use: ReadOnly"))
@@ -676,3 +699,70 @@ func cleanupRemovedSectorData(ctx context.Context, api api.StorageMiner, napi ap
 
 	return nil
 }
+
+var storageTransfersCmd = &cli.Command{
+	Name:  "transfers",
+	Usage: "list running and queued sector file fetches into local storage",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "color",
+			Value: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		color.NoColor = !cctx.Bool("color")
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		transfers, err := nodeApi.StorageTransfers(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("ID"),
+			tablewriter.Col("Sector"),
+			tablewriter.Col("Type"),
+			tablewriter.Col("Storage"),
+			tablewriter.Col("State"),
+			tablewriter.Col("Time"),
+			tablewriter.Col("Transferred"),
+			tablewriter.Col("Rate"),
+			tablewriter.Col("Source"),
+		)
+
+		now := time.Now()
+		for _, t := range transfers {
+			m := map[string]interface{}{
+				"ID":          t.ID,
+				"Sector":      t.Sector.Number,
+				"Type":        t.FileType.String(),
+				"Storage":     t.Dest,
+				"Transferred": types.SizeStr(types.NewInt(uint64(t.Transferred))),
+				"Source":      t.URL,
+			}
+
+			if t.Started.IsZero() {
+				m["State"] = color.YellowString("queued")
+				m["Time"] = now.Sub(t.Queued).Truncate(time.Second)
+			} else {
+				running := now.Sub(t.Started)
+				m["State"] = color.GreenString("running")
+				m["Time"] = running.Truncate(time.Second)
+				if running > 0 {
+					rate := float64(t.Transferred) / running.Seconds()
+					m["Rate"] = types.SizeStr(types.NewInt(uint64(rate))) + "/s"
+				}
+			}
+
+			tw.Write(m)
+		}
+
+		return tw.Flush(os.Stdout)
+	},
+}
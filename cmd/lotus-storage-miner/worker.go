@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var workerCmd = &cli.Command{
+	Name:  "worker",
+	Usage: "Manage remote worker authentication",
+	Subcommands: []*cli.Command{
+		workerTokenCmd,
+		workerIdentitiesCmd,
+		workerRevokeCmd,
+	},
+}
+
+var workerTokenCmd = &cli.Command{
+	Name:  "token",
+	Usage: "Manage worker join tokens",
+	Subcommands: []*cli.Command{
+		workerTokenCreateCmd,
+	},
+}
+
+var workerTokenCreateCmd = &cli.Command{
+	Name:  "create",
+	Usage: "Create a single-use token for attaching a new worker (lotus-worker run --join-token)",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "ttl",
+			Usage: "how long the token can be used for",
+			Value: time.Hour,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		token, err := nodeApi.WorkerJoinTokenCreate(ctx, cctx.Duration("ttl"))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(token)
+		return nil
+	},
+}
+
+var workerIdentitiesCmd = &cli.Command{
+	Name:  "identities",
+	Usage: "List identities of workers which joined with a join token",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		ids, err := nodeApi.WorkerIdentities(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "ID\tName\tJoined\tStatus\n")
+		now := time.Now()
+		for _, id := range ids {
+			status := "active"
+			if id.Revoked {
+				status = "revoked"
+			} else if now.After(id.Expires) {
+				status = "expired"
+			}
+
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", id.ID, id.Name, id.Joined.Format(time.Stamp), status)
+		}
+
+		return w.Flush()
+	},
+}
+
+var workerRevokeCmd = &cli.Command{
+	Name:      "revoke",
+	Usage:     "Revoke the certificate of a worker identity",
+	ArgsUsage: "[identity id]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		id, err := uuid.Parse(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing identity id: %w", err)
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		if err := nodeApi.WorkerIdentityRevoke(ctx, id); err != nil {
+			return err
+		}
+
+		fmt.Printf("revoked worker identity %s; the worker needs a new join token to attach again\n", id)
+		return nil
+	},
+}
@@ -2,25 +2,32 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 
+	"github.com/gbrlsnchs/jwt/v3"
 	"github.com/gorilla/mux"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/urfave/cli/v2"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-jsonrpc/auth"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/wallet"
 	ledgerwallet "github.com/filecoin-project/lotus/chain/wallet/ledger"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/filecoin-project/lotus/lib/lotuslog"
 	"github.com/filecoin-project/lotus/metrics"
+	"github.com/filecoin-project/lotus/node/modules"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/repo"
 )
 
@@ -33,6 +40,7 @@ func main() {
 
 	local := []*cli.Command{
 		runCmd,
+		getApiKeyCmd,
 	}
 
 	app := &cli.App{
@@ -70,6 +78,10 @@ var runCmd = &cli.Command{
 			Name:  "ledger",
 			Usage: "use a ledger device instead of an on-disk wallet",
 		},
+		&cli.BoolFlag{
+			Name:  "disable-auth",
+			Usage: "(insecure) serve the wallet api without requiring an api token",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		log.Info("Starting lotus wallet")
@@ -134,16 +146,28 @@ var runCmd = &cli.Command{
 
 		log.Info("Setting up API endpoint at " + address)
 
+		w = &LoggedWallet{under: metrics.MetricedWalletAPI(w)}
+
 		rpcServer := jsonrpc.NewServer()
-		rpcServer.Register("Filecoin", &LoggedWallet{under: metrics.MetricedWalletAPI(w)})
 
-		mux.Handle("/rpc/v0", rpcServer)
-		mux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
+		if cctx.Bool("disable-auth") {
+			log.Warn("API authentication is disabled, anyone who can reach the api can sign with the wallet keys")
+			rpcServer.Register("Filecoin", w)
+			mux.Handle("/rpc/v0", rpcServer)
+		} else {
+			secret, err := modules.APISecret(ks, lr)
+			if err != nil {
+				return xerrors.Errorf("getting api secret: %w", err)
+			}
 
-		/*ah := &auth.Handler{
-			Verify: nodeApi.AuthVerify,
-			Next:   mux.ServeHTTP,
-		}*/
+			rpcServer.Register("Filecoin", apistruct.PermissionedWalletAPI(w))
+			mux.Handle("/rpc/v0", &auth.Handler{
+				Verify: authVerify(secret),
+				Next:   rpcServer.ServeHTTP,
+			})
+		}
+
+		mux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
 
 		srv := &http.Server{
 			Handler: mux,
@@ -170,3 +194,33 @@ var runCmd = &cli.Command{
 		return srv.Serve(nl)
 	},
 }
+
+var getApiKeyCmd = &cli.Command{
+	Name:  "get-api-key",
+	Usage: "Print the api token to set in Wallet.RemoteBackend of nodes using this wallet",
+	Action: func(cctx *cli.Context) error {
+		r, err := repo.NewFS(cctx.String(FlagWalletRepo))
+		if err != nil {
+			return err
+		}
+
+		token, err := r.APIToken()
+		if err != nil {
+			return xerrors.Errorf("reading api token (was the wallet started with auth enabled?): %w", err)
+		}
+
+		fmt.Println(string(token))
+		return nil
+	},
+}
+
+func authVerify(secret *dtypes.APIAlg) func(ctx context.Context, token string) ([]auth.Permission, error) {
+	return func(ctx context.Context, token string) ([]auth.Permission, error) {
+		var payload modules.JwtPayload
+		if _, err := jwt.Verify([]byte(token), (*jwt.HMACSHA)(secret), &payload); err != nil {
+			return nil, xerrors.Errorf("JWT Verification failed: %w", err)
+		}
+
+		return payload.Allow, nil
+	}
+}
@@ -4,17 +4,19 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"runtime/pprof"
 	"strings"
 
+	"github.com/DataDog/zstd"
 	paramfetch "github.com/filecoin-project/go-paramfetch"
 	metricsprom "github.com/ipfs/go-metrics-prometheus"
 	"github.com/mitchellh/go-homedir"
@@ -108,7 +110,11 @@ var DaemonCmd = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:  "import-snapshot",
-			Usage: "import chain state from a given chain export file or url",
+			Usage: "import chain state from a given chain export file or url, optionally zstd compressed",
+		},
+		&cli.StringFlag{
+			Name:  "import-sha256",
+			Usage: "hex sha256 checksum of the chain or snapshot import, verified before anything is imported; downloads are saved to a temporary file first",
 		},
 		&cli.BoolFlag{
 			Name:  "halt-after-import",
@@ -144,6 +150,10 @@ var DaemonCmd = &cli.Command{
 			Name:  "api-max-req-size",
 			Usage: "maximum API request size accepted by the JSON RPC server",
 		},
+		&cli.BoolFlag{
+			Name:  "explorer",
+			Usage: "serve a read-only chain explorer at /explorer/ on the API endpoint",
+		},
 		&cli.PathFlag{
 			Name:  "restore",
 			Usage: "restore from backup file",
@@ -254,7 +264,7 @@ var DaemonCmd = &cli.Command{
 				issnapshot = true
 			}
 
-			if err := ImportChain(r, chainfile, issnapshot); err != nil {
+			if err := ImportChain(r, chainfile, issnapshot, cctx.String("import-sha256")); err != nil {
 				return err
 			}
 			if cctx.Bool("halt-after-import") {
@@ -348,7 +358,7 @@ var DaemonCmd = &cli.Command{
 		}
 
 		// TODO: properly parse api endpoint (or make it a URL)
-		return serveRPC(api, stop, endpoint, shutdownChan, int64(cctx.Int("api-max-req-size")))
+		return serveRPC(api, stop, endpoint, shutdownChan, int64(cctx.Int("api-max-req-size")), cctx.Bool("explorer"))
 	},
 	Subcommands: []*cli.Command{
 		daemonStopCmd,
@@ -389,22 +399,39 @@ func importKey(ctx context.Context, api api.FullNode, f string) error {
 	return nil
 }
 
-func ImportChain(r repo.Repo, fname string, snapshot bool) (err error) {
-	var rd io.Reader
-	var l int64
-	if strings.HasPrefix(fname, "http://") || strings.HasPrefix(fname, "https://") {
-		resp, err := http.Get(fname) //nolint:gosec
+func ImportChain(r repo.Repo, fname string, snapshot bool, checksum string) (err error) {
+	var expectSum []byte
+	if checksum != "" {
+		expectSum, err = hex.DecodeString(checksum)
+		if err != nil {
+			return xerrors.Errorf("parsing sha256 checksum: %w", err)
+		}
+	}
+
+	remote := strings.HasPrefix(fname, "http://") || strings.HasPrefix(fname, "https://")
+	if remote && expectSum != nil {
+		// the checksum is verified before anything is imported, so the
+		// download is saved first
+		tmp, err := downloadImport(fname)
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close() //nolint:errcheck
+		defer os.Remove(tmp) //nolint:errcheck
+
+		fname, remote = tmp, false
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return xerrors.Errorf("non-200 response: %d", resp.StatusCode)
+	var rd io.Reader
+	var l int64
+	if remote {
+		hr, err := openHTTPImport(fname)
+		if err != nil {
+			return err
 		}
+		defer hr.Close() //nolint:errcheck
 
-		rd = resp.Body
-		l = resp.ContentLength
+		rd = hr
+		l = hr.Size()
 	} else {
 		fname, err = homedir.Expand(fname)
 		if err != nil {
@@ -422,6 +449,12 @@ func ImportChain(r repo.Repo, fname string, snapshot bool) (err error) {
 			return err
 		}
 
+		if expectSum != nil {
+			if err := checkImportSum(fi, expectSum); err != nil {
+				return err
+			}
+		}
+
 		rd = fi
 		l = st.Size()
 	}
@@ -454,13 +487,27 @@ func ImportChain(r repo.Repo, fname string, snapshot bool) (err error) {
 
 	bufr := bufio.NewReaderSize(rd, 1<<20)
 
+	// snapshots exported with --compress zstd are recognized by the zstd magic
+	// number at the start of the stream
+	magic, err := bufr.Peek(4)
+	if err != nil {
+		return xerrors.Errorf("reading snapshot header: %w", err)
+	}
+	compressed := bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd})
+
 	bar := pb.New64(l)
-	br := bar.NewProxyReader(bufr)
+	var br io.Reader = bar.NewProxyReader(bufr)
 	bar.ShowTimeLeft = true
 	bar.ShowPercent = true
 	bar.ShowSpeed = true
 	bar.Units = pb.U_BYTES
 
+	if compressed {
+		zr := zstd.NewReader(br)
+		defer zr.Close() //nolint:errcheck
+		br = zr
+	}
+
 	bar.Start()
 	ts, err := cst.Import(br)
 	bar.Finish()
@@ -499,3 +546,20 @@ func ImportChain(r repo.Repo, fname string, snapshot bool) (err error) {
 
 	return nil
 }
+
+// checkImportSum verifies the sha256 checksum of a chain import file, and
+// rewinds it to be imported
+func checkImportSum(f *os.File, expect []byte) error {
+	log.Infof("verifying sha256 checksum of %s...", f.Name())
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return xerrors.Errorf("reading import for checksum: %w", err)
+	}
+	if sum := hasher.Sum(nil); !bytes.Equal(sum, expect) {
+		return xerrors.Errorf("sha256 checksum mismatch: expected %x, got %x; nothing was imported", expect, sum)
+	}
+
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+const explorerPrefix = "/explorer/"
+
+// explorerTipSets is the number of tipsets listed on the chain page
+const explorerTipSets = 20
+
+// explorer is a minimal, read-only chain browser served on the daemon API
+// port. It only calls read methods on the node API, so it doesn't need to
+// go through the auth handler.
+type explorer struct {
+	api  api.FullNode
+	tmpl *template.Template
+}
+
+func newExplorer(a api.FullNode) (*explorer, error) {
+	t, err := template.New("explorer").Funcs(map[string]interface{}{
+		"ToFil":     func(f types.BigInt) types.FIL { return types.FIL(f) },
+		"ActorName": builtin.ActorNameByCode,
+		"SizeStr":   func(b abi.StoragePower) string { return types.SizeStr(b) },
+	}).Parse(explorerLayout)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing explorer layout: %w", err)
+	}
+
+	for name, page := range explorerPages {
+		if _, err := t.New(name).Parse(page); err != nil {
+			return nil, xerrors.Errorf("parsing explorer page %s: %w", name, err)
+		}
+	}
+
+	return &explorer{
+		api:  a,
+		tmpl: t,
+	}, nil
+}
+
+func (e *explorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, explorerPrefix)
+	parts := strings.SplitN(path, "/", 2)
+
+	var (
+		page string
+		data interface{}
+		err  error
+	)
+
+	switch parts[0] {
+	case "":
+		page = "chain"
+		data, err = e.chain(r.Context(), r.URL.Query().Get("height"))
+	case "block":
+		page = "block"
+		data, err = e.block(r.Context(), arg(parts))
+	case "msg":
+		page = "msg"
+		data, err = e.message(r.Context(), arg(parts))
+	case "actor":
+		page = "actor"
+		data, err = e.actor(r.Context(), arg(parts))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		page, data = "error", err.Error()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := e.tmpl.ExecuteTemplate(w, page, data); err != nil {
+		log.Errorf("rendering explorer page %s: %+v", page, err)
+	}
+}
+
+func arg(parts []string) string {
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.TrimSuffix(parts[1], "/")
+}
+
+type explorerTipSet struct {
+	*types.TipSet
+	Messages int
+}
+
+type explorerChain struct {
+	TipSets  []explorerTipSet
+	HasOlder bool
+	Older    abi.ChainEpoch
+}
+
+func (e *explorer) chain(ctx context.Context, height string) (*explorerChain, error) {
+	ts, err := e.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	if height != "" {
+		h, err := strconv.ParseInt(height, 10, 64)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing height: %w", err)
+		}
+		if ts, err = e.api.ChainGetTipSetByHeight(ctx, abi.ChainEpoch(h), ts.Key()); err != nil {
+			return nil, xerrors.Errorf("getting tipset at height %d: %w", h, err)
+		}
+	}
+
+	out := &explorerChain{}
+	for len(out.TipSets) < explorerTipSets {
+		// summed over blocks, so messages included in more than one block are
+		// counted more than once
+		var msgs int
+		for _, c := range ts.Cids() {
+			bm, err := e.api.ChainGetBlockMessages(ctx, c)
+			if err != nil {
+				return nil, xerrors.Errorf("getting messages in block %s: %w", c, err)
+			}
+			msgs += len(bm.Cids)
+		}
+		out.TipSets = append(out.TipSets, explorerTipSet{TipSet: ts, Messages: msgs})
+
+		if ts.Height() == 0 {
+			return out, nil
+		}
+		if ts, err = e.api.ChainGetTipSet(ctx, ts.Parents()); err != nil {
+			return nil, xerrors.Errorf("getting parent tipset: %w", err)
+		}
+	}
+
+	out.HasOlder = true
+	out.Older = ts.Height()
+
+	return out, nil
+}
+
+type explorerBlock struct {
+	Cid      cid.Cid
+	Header   *types.BlockHeader
+	Messages []*types.Message
+}
+
+func (e *explorer) block(ctx context.Context, s string) (*explorerBlock, error) {
+	c, err := cid.Parse(s)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing block cid: %w", err)
+	}
+
+	bh, err := e.api.ChainGetBlock(ctx, c)
+	if err != nil {
+		return nil, xerrors.Errorf("getting block: %w", err)
+	}
+
+	bm, err := e.api.ChainGetBlockMessages(ctx, c)
+	if err != nil {
+		return nil, xerrors.Errorf("getting block messages: %w", err)
+	}
+
+	out := &explorerBlock{
+		Cid:      c,
+		Header:   bh,
+		Messages: bm.BlsMessages,
+	}
+	for _, sm := range bm.SecpkMessages {
+		out.Messages = append(out.Messages, &sm.Message)
+	}
+
+	return out, nil
+}
+
+type explorerMessage struct {
+	Cid     cid.Cid
+	Message *types.Message
+	Method  string
+	Params  string
+	Lookup  *api.MsgLookup
+}
+
+func (e *explorer) message(ctx context.Context, s string) (*explorerMessage, error) {
+	c, err := cid.Parse(s)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing message cid: %w", err)
+	}
+
+	m, err := e.api.ChainGetMessage(ctx, c)
+	if err != nil {
+		return nil, xerrors.Errorf("getting message: %w", err)
+	}
+
+	out := &explorerMessage{
+		Cid:     c,
+		Message: m,
+		Method:  strconv.FormatUint(uint64(m.Method), 10),
+	}
+
+	// the message may not have executed yet, which is fine for display
+	out.Lookup, err = e.api.StateSearchMsg(ctx, c)
+	if err != nil {
+		log.Warnf("explorer: searching for message %s: %s", c, err)
+	}
+
+	act, err := e.api.StateGetActor(ctx, m.To, types.EmptyTSK)
+	if err != nil {
+		// actor may not exist yet if this message creates it
+		return out, nil
+	}
+
+	if mm, ok := stmgr.MethodsMap[act.Code][m.Method]; ok {
+		out.Method = mm.Name
+	}
+	if len(m.Params) > 0 {
+		if out.Params, err = lcli.JsonParams(act.Code, m.Method, m.Params); err != nil {
+			out.Params = "failed to decode params: " + err.Error()
+		}
+	}
+
+	return out, nil
+}
+
+type explorerMiner struct {
+	Info      miner.MinerInfo
+	Power     *api.MinerPower
+	Available abi.TokenAmount
+	Deadline  uint64
+}
+
+type explorerActor struct {
+	Address address.Address
+	ID      address.Address
+	Actor   *types.Actor
+	Miner   *explorerMiner
+}
+
+func (e *explorer) actor(ctx context.Context, s string) (*explorerActor, error) {
+	addr, err := address.NewFromString(s)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing address: %w", err)
+	}
+
+	act, err := e.api.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting actor: %w", err)
+	}
+
+	id, err := e.api.StateLookupID(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("looking up actor id: %w", err)
+	}
+
+	out := &explorerActor{
+		Address: addr,
+		ID:      id,
+		Actor:   act,
+	}
+
+	if !builtin.IsStorageMinerActor(act.Code) {
+		return out, nil
+	}
+
+	mi, err := e.api.StateMinerInfo(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner info: %w", err)
+	}
+
+	pow, err := e.api.StateMinerPower(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner power: %w", err)
+	}
+
+	avail, err := e.api.StateMinerAvailableBalance(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner available balance: %w", err)
+	}
+
+	di, err := e.api.StateMinerProvingDeadline(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner proving deadline: %w", err)
+	}
+
+	out.Miner = &explorerMiner{
+		Info:      mi,
+		Power:     pow,
+		Available: avail,
+		Deadline:  di.Index,
+	}
+
+	return out, nil
+}
+
+var explorerLayout = `
+{{define "header"}}<html>
+<head>
+ <title>Lotus Explorer</title>
+ <style>
+  html, body { font-family: monospace; }
+  table { border-collapse: collapse; }
+  td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+  pre { margin: 0; }
+ </style>
+</head>
+<body>
+<div>
+ <a href="/explorer/">chain</a> |
+ <form style="display: inline" onsubmit="window.location='/explorer/actor/'+this.addr.value; return false"><input name="addr" placeholder="actor address"></form>
+ <form style="display: inline" onsubmit="window.location='/explorer/msg/'+this.msg.value; return false"><input name="msg" placeholder="message cid"></form>
+</div>
+<hr>
+{{end}}
+{{define "footer"}}</body>
+</html>{{end}}
+{{define "error"}}{{template "header"}}<b>Error:</b> <pre>{{.}}</pre>{{template "footer"}}{{end}}
+`
+
+var explorerPages = map[string]string{
+	"chain": `{{template "header"}}
+<table>
+ <tr><th>Height</th><th>Blocks</th><th>Miners</th><th>Messages</th><th>Parent Base Fee</th></tr>
+ {{range .TipSets}}
+ <tr>
+  <td>{{.Height}}</td>
+  <td>{{range .Cids}}<div><a href="/explorer/block/{{.}}">{{.}}</a></div>{{end}}</td>
+  <td>{{range .Blocks}}<div><a href="/explorer/actor/{{.Miner}}">{{.Miner}}</a></div>{{end}}</td>
+  <td>{{.Messages}}</td>
+  <td>{{(index .Blocks 0).ParentBaseFee}}</td>
+ </tr>
+ {{end}}
+</table>
+{{if .HasOlder}}<div><a href="/explorer/?height={{.Older}}">older</a></div>{{end}}
+{{template "footer"}}`,
+
+	"block": `{{template "header"}}
+<h3>Block {{.Cid}}</h3>
+<table>
+ <tr><td>Height</td><td>{{.Header.Height}}</td></tr>
+ <tr><td>Miner</td><td><a href="/explorer/actor/{{.Header.Miner}}">{{.Header.Miner}}</a></td></tr>
+ <tr><td>Timestamp</td><td>{{.Header.Timestamp}}</td></tr>
+ <tr><td>Parents</td><td>{{range .Header.Parents}}<div><a href="/explorer/block/{{.}}">{{.}}</a></div>{{end}}</td></tr>
+ <tr><td>Parent Weight</td><td>{{.Header.ParentWeight}}</td></tr>
+ <tr><td>Parent State Root</td><td>{{.Header.ParentStateRoot}}</td></tr>
+ <tr><td>Parent Base Fee</td><td>{{.Header.ParentBaseFee}}</td></tr>
+ <tr><td>Win Count</td><td>{{.Header.ElectionProof.WinCount}}</td></tr>
+</table>
+<h4>Messages ({{len .Messages}})</h4>
+<table>
+ <tr><th>Cid</th><th>From</th><th>To</th><th>Method</th><th>Value</th></tr>
+ {{range .Messages}}
+ <tr>
+  <td><a href="/explorer/msg/{{.Cid}}">{{.Cid}}</a></td>
+  <td><a href="/explorer/actor/{{.From}}">{{.From}}</a></td>
+  <td><a href="/explorer/actor/{{.To}}">{{.To}}</a></td>
+  <td>{{.Method}}</td>
+  <td>{{ToFil .Value}}</td>
+ </tr>
+ {{end}}
+</table>
+{{template "footer"}}`,
+
+	"msg": `{{template "header"}}
+<h3>Message {{.Cid}}</h3>
+<table>
+ <tr><td>From</td><td><a href="/explorer/actor/{{.Message.From}}">{{.Message.From}}</a></td></tr>
+ <tr><td>To</td><td><a href="/explorer/actor/{{.Message.To}}">{{.Message.To}}</a></td></tr>
+ <tr><td>Nonce</td><td>{{.Message.Nonce}}</td></tr>
+ <tr><td>Value</td><td>{{ToFil .Message.Value}}</td></tr>
+ <tr><td>Method</td><td>{{.Method}}</td></tr>
+ <tr><td>Gas Limit</td><td>{{.Message.GasLimit}}</td></tr>
+ <tr><td>Gas Fee Cap</td><td>{{.Message.GasFeeCap}}</td></tr>
+ <tr><td>Gas Premium</td><td>{{.Message.GasPremium}}</td></tr>
+ <tr><td>Params</td><td><pre>{{.Params}}</pre></td></tr>
+ {{with .Lookup}}
+ <tr><td>Executed At</td><td>{{.Height}} ({{range .TipSet.Cids}}<a href="/explorer/block/{{.}}">{{.}}</a> {{end}})</td></tr>
+ <tr><td>Exit Code</td><td>{{.Receipt.ExitCode}}</td></tr>
+ <tr><td>Gas Used</td><td>{{.Receipt.GasUsed}}</td></tr>
+ {{else}}
+ <tr><td>Executed At</td><td>not found on chain</td></tr>
+ {{end}}
+</table>
+{{template "footer"}}`,
+
+	"actor": `{{template "header"}}
+<h3>Actor {{.Address}}</h3>
+<table>
+ <tr><td>ID</td><td>{{.ID}}</td></tr>
+ <tr><td>Type</td><td>{{ActorName .Actor.Code}}</td></tr>
+ <tr><td>Balance</td><td>{{ToFil .Actor.Balance}}</td></tr>
+ <tr><td>Nonce</td><td>{{.Actor.Nonce}}</td></tr>
+ <tr><td>Head</td><td>{{.Actor.Head}}</td></tr>
+</table>
+{{with .Miner}}
+<h4>Miner</h4>
+<table>
+ <tr><td>Owner</td><td><a href="/explorer/actor/{{.Info.Owner}}">{{.Info.Owner}}</a></td></tr>
+ <tr><td>Worker</td><td><a href="/explorer/actor/{{.Info.Worker}}">{{.Info.Worker}}</a></td></tr>
+ <tr><td>Control</td><td>{{range .Info.ControlAddresses}}<div><a href="/explorer/actor/{{.}}">{{.}}</a></div>{{end}}</td></tr>
+ <tr><td>Sector Size</td><td>{{.Info.SectorSize.ShortString}}</td></tr>
+ <tr><td>Raw Power</td><td>{{SizeStr .Power.MinerPower.RawBytePower}} / {{SizeStr .Power.TotalPower.RawBytePower}}</td></tr>
+ <tr><td>Quality Adj Power</td><td>{{SizeStr .Power.MinerPower.QualityAdjPower}} / {{SizeStr .Power.TotalPower.QualityAdjPower}}</td></tr>
+ <tr><td>Available Balance</td><td>{{ToFil .Available}}</td></tr>
+ <tr><td>Current Deadline</td><td>{{.Deadline}}</td></tr>
+</table>
+{{end}}
+{{template "footer"}}`,
+}
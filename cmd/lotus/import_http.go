@@ -0,0 +1,192 @@
+// +build !nodaemon
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// importRetries is how many times a broken chain import download is resumed
+const importRetries = 10
+
+// httpImportReader reads a chain import over HTTP. When the connection breaks,
+// the download is resumed at the last read byte with a range request, if the
+// server supports them. Retries are counted since the download last made
+// progress.
+type httpImportReader struct {
+	url string
+
+	body   io.ReadCloser
+	offset int64
+	size   int64
+	ranges bool
+
+	retries int
+}
+
+// downloadImport saves a chain import to a temporary file, so its checksum can
+// be verified before it's imported. The caller removes the file
+func downloadImport(url string) (string, error) {
+	hr, err := openHTTPImport(url)
+	if err != nil {
+		return "", err
+	}
+	defer hr.Close() //nolint:errcheck
+
+	f, err := ioutil.TempFile("", "lotus-import-")
+	if err != nil {
+		return "", xerrors.Errorf("creating download file: %w", err)
+	}
+
+	log.Infof("downloading %s to %s...", url, f.Name())
+
+	_, err = io.Copy(f, hr)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", xerrors.Errorf("downloading import: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+func openHTTPImport(url string) (*httpImportReader, error) {
+	resp, err := http.Get(url) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, xerrors.Errorf("non-200 response: %d", resp.StatusCode)
+	}
+
+	return &httpImportReader{
+		url:    url,
+		body:   resp.Body,
+		size:   resp.ContentLength,
+		ranges: resp.Header.Get("Accept-Ranges") == "bytes",
+	}, nil
+}
+
+// Size returns the size of the download, -1 if the server didn't tell
+func (r *httpImportReader) Size() int64 {
+	return r.size
+}
+
+func (r *httpImportReader) Read(p []byte) (int, error) {
+	for {
+		if r.body == nil {
+			if err := r.resume(); err != nil {
+				if err := r.retry(err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+		}
+
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if n > 0 {
+			r.retries = 0
+		}
+
+		if err == nil || (err == io.EOF && (r.size < 0 || r.offset == r.size)) {
+			return n, err
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		_ = r.body.Close()
+		r.body = nil
+
+		if err := r.retry(err); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// retry waits before the next resume attempt, or returns the download error
+// when the download can't be resumed
+func (r *httpImportReader) retry(err error) error {
+	if !r.ranges {
+		return xerrors.Errorf("download failed, the server doesn't support resuming it: %w", err)
+	}
+	if r.retries >= importRetries {
+		return xerrors.Errorf("download failed after %d retries: %w", r.retries, err)
+	}
+
+	r.retries++
+	log.Warnf("chain import download failed at byte %d, resuming (attempt %d/%d): %s", r.offset, r.retries, importRetries, err)
+	time.Sleep(time.Duration(r.retries) * time.Second)
+	return nil
+}
+
+func (r *httpImportReader) resume() error {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		_ = resp.Body.Close()
+		return xerrors.Errorf("resuming download: unexpected response: %d", resp.StatusCode)
+	}
+
+	start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+	if err != nil {
+		_ = resp.Body.Close()
+		return xerrors.Errorf("resuming download: %w", err)
+	}
+	if start != r.offset {
+		_ = resp.Body.Close()
+		return xerrors.Errorf("resuming download: server resumed at byte %d, requested %d", start, r.offset)
+	}
+
+	r.body = resp.Body
+	return nil
+}
+
+// contentRangeStart returns the first byte of the range in a Content-Range
+// header, e.g. 'bytes 100-199/200'
+func contentRangeStart(h string) (int64, error) {
+	rng := strings.TrimPrefix(h, "bytes ")
+	end := strings.IndexByte(rng, '-')
+	if rng == h || end < 0 {
+		return 0, xerrors.Errorf("invalid Content-Range %q", h)
+	}
+
+	start, err := strconv.ParseInt(rng[:end], 10, 64)
+	if err != nil {
+		return 0, xerrors.Errorf("invalid Content-Range %q: %w", h, err)
+	}
+	return start, nil
+}
+
+func (r *httpImportReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/lib/cborrpc"
 	"github.com/filecoin-project/lotus/metrics"
 	"github.com/filecoin-project/lotus/node"
 	"github.com/filecoin-project/lotus/node/impl"
@@ -33,17 +34,18 @@ import (
 
 var log = logging.Logger("main")
 
-func serveRPC(a api.FullNode, stop node.StopFunc, addr multiaddr.Multiaddr, shutdownCh <-chan struct{}, maxRequestSize int64) error {
+func serveRPC(a api.FullNode, stop node.StopFunc, addr multiaddr.Multiaddr, shutdownCh <-chan struct{}, maxRequestSize int64, serveExplorer bool) error {
 	serverOptions := make([]jsonrpc.ServerOption, 0)
 	if maxRequestSize != 0 { // config set
 		serverOptions = append(serverOptions, jsonrpc.WithMaxRequestSize(maxRequestSize))
 	}
 	rpcServer := jsonrpc.NewServer(serverOptions...)
-	rpcServer.Register("Filecoin", apistruct.PermissionedFullAPI(metrics.MetricedFullAPI(a)))
+	pma := apistruct.PermissionedFullAPI(metrics.MetricedFullAPI(a))
+	rpcServer.Register("Filecoin", pma)
 
 	ah := &auth.Handler{
 		Verify: a.AuthVerify,
-		Next:   rpcServer.ServeHTTP,
+		Next:   cborrpc.NewHandler("Filecoin", pma, rpcServer).ServeHTTP,
 	}
 
 	http.Handle("/rpc/v0", ah)
@@ -55,6 +57,14 @@ func serveRPC(a api.FullNode, stop node.StopFunc, addr multiaddr.Multiaddr, shut
 
 	http.Handle("/rest/v0/import", importAH)
 
+	if serveExplorer {
+		exp, err := newExplorer(a)
+		if err != nil {
+			return xerrors.Errorf("creating explorer: %w", err)
+		}
+		http.Handle(explorerPrefix, exp)
+	}
+
 	// Prometheus globals are exposed as interfaces, but the prometheus
 	// OpenCensus exporter expects a concrete *Registry. The concrete type of
 	// the globals are actually *Registry, so we downcast them, staying
@@ -0,0 +1,63 @@
+package sectorstorage
+
+import (
+	"context"
+	"math"
+	"math/rand"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// CheckProvableSampled checks the files of all sectors for presence and size
+// with the fault tracker. A random sampleRate fraction (0 to 1) of the sectors
+// additionally have their sealed data read at random PoSt challenges, using rg
+// to get their CommR, which finds unreadable or corrupt data.
+func CheckProvableSampled(ctx context.Context, ft FaultTracker, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, sampleRate float64, rg storiface.RGetter) (map[abi.SectorID]string, error) {
+	if sampleRate < 0 || sampleRate > 1 {
+		return nil, xerrors.Errorf("sample rate must be between 0 and 1, got %f", sampleRate)
+	}
+
+	sampled, rest := sampleSectors(sectors, sampleRate)
+
+	out := make(map[abi.SectorID]string)
+	for _, check := range []struct {
+		sectors []storage.SectorRef
+		rg      storiface.RGetter
+	}{
+		{sectors: rest},
+		{sectors: sampled, rg: rg},
+	} {
+		if len(check.sectors) == 0 {
+			continue
+		}
+
+		bad, err := ft.CheckProvable(ctx, pp, check.sectors, check.rg)
+		if err != nil {
+			return nil, err
+		}
+
+		for id, reason := range bad {
+			out[id] = reason
+		}
+	}
+
+	return out, nil
+}
+
+// sampleSectors splits the sectors into a random sample of the given rate, and
+// the rest
+func sampleSectors(sectors []storage.SectorRef, rate float64) (sampled, rest []storage.SectorRef) {
+	shuffled := make([]storage.SectorRef, len(sectors))
+	copy(shuffled, sectors)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	n := int(math.Ceil(rate * float64(len(shuffled))))
+	return shuffled[:n], shuffled[n:]
+}
@@ -0,0 +1,58 @@
+package sectorstorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+type sampleFaultTracker struct {
+	read map[abi.SectorNumber]bool
+}
+
+func (ft *sampleFaultTracker) CheckProvable(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, rg storiface.RGetter) (map[abi.SectorID]string, error) {
+	bad := map[abi.SectorID]string{}
+	for _, s := range sectors {
+		ft.read[s.ID.Number] = rg != nil
+		if s.ID.Number == 3 {
+			bad[s.ID] = "bad"
+		}
+	}
+	return bad, nil
+}
+
+func TestCheckProvableSampled(t *testing.T) {
+	var sectors []storage.SectorRef
+	for i := 0; i < 10; i++ {
+		sectors = append(sectors, storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: abi.SectorNumber(i)}})
+	}
+	rg := func(ctx context.Context, id abi.SectorID) (cid.Cid, error) {
+		return cid.Undef, nil
+	}
+
+	for rate, expectRead := range map[float64]int{0: 0, 0.25: 3, 1: 10} {
+		ft := &sampleFaultTracker{read: map[abi.SectorNumber]bool{}}
+		bad, err := CheckProvableSampled(context.Background(), ft, abi.RegisteredPoStProof_StackedDrgWindow2KiBV1, sectors, rate, rg)
+		require.NoError(t, err)
+		require.Equal(t, map[abi.SectorID]string{{Miner: 1000, Number: 3}: "bad"}, bad)
+		require.Len(t, ft.read, len(sectors))
+
+		var read int
+		for _, r := range ft.read {
+			if r {
+				read++
+			}
+		}
+		require.Equal(t, expectRead, read, rate)
+	}
+
+	_, err := CheckProvableSampled(context.Background(), &sampleFaultTracker{}, abi.RegisteredPoStProof_StackedDrgWindow2KiBV1, sectors, 2, rg)
+	require.Error(t, err)
+}
@@ -0,0 +1,183 @@
+//+build cgo
+
+package ffiwrapper
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/go-commp-utils/zerocomm"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// RegenerateCache rebuilds the cache files needed to prove a sealed sector
+// (p_aux, t_aux and tree-r-last) when they are lost or corrupted. Sealing is
+// deterministic, so the sector data is sealed again with the original ticket
+// into temporary files next to the cache, and if the new replica matches the
+// sealed CID, its cache files replace the existing ones. The sealed file
+// itself is kept.
+//
+// Sectors without deals are sealed again from zeros, sectors with deals need
+// a complete unsealed copy. Resealing needs as much scratch space as sealing
+// the sector, on the storage path holding the cache.
+func (sb *Sealer) RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) error {
+	ssize, err := sector.ProofType.SectorSize()
+	if err != nil {
+		return err
+	}
+	maxPieceSize := abi.PaddedPieceSize(ssize)
+
+	var sum abi.UnpaddedPieceSize
+	hasData := false
+	for _, piece := range pieces {
+		sum += piece.Size.Unpadded()
+		if !piece.PieceCID.Equals(zerocomm.ZeroPieceCommitment(piece.Size.Unpadded())) {
+			hasData = true
+		}
+	}
+	if sum != maxPieceSize.Unpadded() {
+		return xerrors.Errorf("aggregated piece sizes don't match sector size: %d != %d", sum, maxPieceSize.Unpadded())
+	}
+
+	paths, done, err := sb.sectors.AcquireSector(ctx, sector, storiface.FTSealed|storiface.FTCache, 0, storiface.PathStorage)
+	if xerrors.Is(err, storiface.ErrSectorNotFound) {
+		// the whole cache directory is gone
+		paths, done, err = sb.sectors.AcquireSector(ctx, sector, storiface.FTSealed, storiface.FTCache, storiface.PathStorage)
+	}
+	if err != nil {
+		return xerrors.Errorf("acquiring sector paths: %w", err)
+	}
+	defer done()
+
+	work := paths.Cache + ".regen"
+	if err := os.RemoveAll(work); err != nil {
+		return xerrors.Errorf("removing leftover regeneration files: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(work, "cache"), 0755); err != nil { // nolint:gosec
+		return xerrors.Errorf("creating regeneration directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(work); err != nil {
+			log.Errorf("removing regeneration files of sector %d: %+v", sector.ID, err)
+		}
+	}()
+
+	unsealed := filepath.Join(work, "unsealed")
+	if hasData {
+		upaths, udone, err := sb.sectors.AcquireSector(ctx, sector, storiface.FTUnsealed, 0, storiface.PathStorage)
+		if err != nil {
+			return xerrors.Errorf("sector has deal data, acquiring unsealed copy to regenerate from: %w", err)
+		}
+		defer udone()
+
+		pf, err := openPartialFile(maxPieceSize, upaths.Unsealed)
+		if err != nil {
+			return xerrors.Errorf("opening unsealed file: %w", err)
+		}
+		// finalizing frees the ranges of padding pieces, which read back as
+		// zeros, so only the ranges of the deal pieces need to be there
+		complete, err := hasDealData(pf, pieces)
+		if cerr := pf.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return xerrors.Errorf("checking unsealed file: %w", err)
+		}
+		if !complete {
+			return xerrors.Errorf("unsealed copy of the sector isn't complete, unseal the sector first")
+		}
+
+		unsealed = upaths.Unsealed
+	} else {
+		f, err := os.Create(unsealed)
+		if err != nil {
+			return xerrors.Errorf("creating unsealed file: %w", err)
+		}
+		err = f.Truncate(int64(ssize))
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return xerrors.Errorf("allocating unsealed file: %w", err)
+		}
+	}
+
+	workCache := filepath.Join(work, "cache")
+	workSealed := filepath.Join(work, "sealed")
+	f, err := os.Create(workSealed)
+	if err != nil {
+		return xerrors.Errorf("creating sealed file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	log.Infow("regenerating sector cache", "sector", sector.ID, "fromData", hasData)
+
+	p1o, err := ffi.SealPreCommitPhase1(sector.ProofType, workCache, unsealed, workSealed, sector.ID.Number, sector.ID.Miner, ticket, pieces)
+	if err != nil {
+		return xerrors.Errorf("presealing sector %d: %w", sector.ID.Number, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sealedCID, _, err := ffi.SealPreCommitPhase2(p1o, workCache, workSealed)
+	if err != nil {
+		return xerrors.Errorf("presealing sector %d: %w", sector.ID.Number, err)
+	}
+
+	if !sealedCID.Equals(sealed) {
+		return xerrors.Errorf("regenerated replica of sector %d doesn't match, sealed CID %s, expected %s", sector.ID.Number, sealedCID, sealed)
+	}
+
+	// keep only the files needed for proving, like when finalizing
+	if err := ffi.ClearCache(uint64(ssize), workCache); err != nil {
+		return xerrors.Errorf("clearing regenerated cache: %w", err)
+	}
+
+	if err := os.MkdirAll(paths.Cache, 0755); err != nil { // nolint:gosec
+		return xerrors.Errorf("creating cache directory: %w", err)
+	}
+
+	files, err := ioutil.ReadDir(workCache)
+	if err != nil {
+		return xerrors.Errorf("listing regenerated cache files: %w", err)
+	}
+	for _, fi := range files {
+		if err := os.Rename(filepath.Join(workCache, fi.Name()), filepath.Join(paths.Cache, fi.Name())); err != nil {
+			return xerrors.Errorf("moving regenerated cache file: %w", err)
+		}
+	}
+
+	log.Infow("regenerated sector cache", "sector", sector.ID, "files", len(files))
+
+	return nil
+}
+
+// hasDealData returns whether the ranges of all pieces which aren't padding
+// are allocated in the unsealed file
+func hasDealData(pf *partialFile, pieces []abi.PieceInfo) (bool, error) {
+	var offset storiface.UnpaddedByteIndex
+	for _, piece := range pieces {
+		size := piece.Size.Unpadded()
+		if !piece.PieceCID.Equals(zerocomm.ZeroPieceCommitment(size)) {
+			has, err := pf.HasAllocated(offset, size)
+			if err != nil || !has {
+				return false, err
+			}
+		}
+		offset += storiface.UnpaddedByteIndex(size)
+	}
+	return true, nil
+}
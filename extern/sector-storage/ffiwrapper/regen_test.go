@@ -0,0 +1,53 @@
+//+build cgo
+
+package ffiwrapper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-commp-utils/zerocomm"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestHasDealData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "regen")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint:errcheck
+
+	pf, err := createPartialFile(2048, filepath.Join(dir, "unsealed"))
+	require.NoError(t, err)
+	defer pf.Close() // nolint:errcheck
+
+	deal, err := cid.NewPrefixV1(cid.Raw, multihash.SHA2_256).Sum([]byte("deal"))
+	require.NoError(t, err)
+
+	pieces := []abi.PieceInfo{
+		{Size: 512, PieceCID: deal},
+		{Size: 512, PieceCID: zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(512).Unpadded())},
+		{Size: 1024, PieceCID: deal},
+	}
+
+	has, err := hasDealData(pf, pieces)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, pf.MarkAllocated(0, 512))
+	has, err = hasDealData(pf, pieces)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	// the padding piece range was freed by finalizing
+	require.NoError(t, pf.MarkAllocated(storiface.PaddedByteIndex(1024), 1024))
+	has, err = hasDealData(pf, pieces)
+	require.NoError(t, err)
+	require.True(t, has)
+}
@@ -3,7 +3,6 @@
 package ffiwrapper
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"io"
@@ -103,6 +102,10 @@ func (sb *Sealer) AddPiece(ctx context.Context, sector storage.SectorRef, existi
 		}
 	}
 
+	if nr, ok := file.(nullReader); ok && nr.NullBytes() == int64(pieceSize) {
+		return sb.addNullPiece(stagedFile, offset, pieceSize)
+	}
+
 	w, err := stagedFile.Writer(storiface.UnpaddedByteIndex(offset).Padded(), pieceSize.Padded())
 	if err != nil {
 		return abi.PieceInfo{}, xerrors.Errorf("getting partial file writer: %w", err)
@@ -219,6 +222,35 @@ func (sb *Sealer) AddPiece(ctx context.Context, sector storage.SectorRef, existi
 	}, nil
 }
 
+// nullReader is implemented by readers of padding pieces, which only contain
+// zeros
+type nullReader interface {
+	NullBytes() int64
+}
+
+// addNullPiece records a zero piece in the unsealed file without streaming it
+// through the fr32 padder; the range is deallocated, so that it reads back as
+// zeros, and the piece commitment comes from the precomputed zero piece
+// commitments, as it only depends on the piece size
+func (sb *Sealer) addNullPiece(stagedFile *partialFile, offset abi.UnpaddedPieceSize, pieceSize abi.UnpaddedPieceSize) (abi.PieceInfo, error) {
+	if err := pieceSize.Validate(); err != nil {
+		return abi.PieceInfo{}, xerrors.Errorf("invalid padding piece size: %w", err)
+	}
+
+	if err := stagedFile.Free(storiface.UnpaddedByteIndex(offset).Padded(), pieceSize.Padded()); err != nil {
+		return abi.PieceInfo{}, xerrors.Errorf("clearing padding piece range: %w", err)
+	}
+
+	if err := stagedFile.MarkAllocated(storiface.UnpaddedByteIndex(offset).Padded(), pieceSize.Padded()); err != nil {
+		return abi.PieceInfo{}, xerrors.Errorf("marking padding piece range as allocated: %w", err)
+	}
+
+	return abi.PieceInfo{
+		Size:     pieceSize.Padded(),
+		PieceCID: zerocomm.ZeroPieceCommitment(pieceSize),
+	}, nil
+}
+
 func (sb *Sealer) pieceCid(spt abi.RegisteredSealProof, in []byte) (cid.Cid, error) {
 	prf, werr, err := commpffi.ToReadableFile(bytes.NewReader(in), int64(len(in)))
 	if err != nil {
@@ -314,83 +346,25 @@ func (sb *Sealer) UnsealPiece(ctx context.Context, sector storage.SectorRef, off
 			continue
 		}
 
-		out, err := pf.Writer(offset.Padded(), size.Padded())
-		if err != nil {
-			return xerrors.Errorf("getting partial file writer: %w", err)
-		}
-
-		// <eww>
-		opr, opw, err := os.Pipe()
-		if err != nil {
-			return xerrors.Errorf("creating out pipe: %w", err)
-		}
-
-		var perr error
-		outWait := make(chan struct{})
-
-		{
-			go func() {
-				defer close(outWait)
-				defer opr.Close() // nolint
-
-				padwriter := fr32.NewPadWriter(out)
-
-				bsize := uint64(size.Padded())
-				if bsize > uint64(runtime.NumCPU())*fr32.MTTresh {
-					bsize = uint64(runtime.NumCPU()) * fr32.MTTresh
-				}
-
-				bw := bufio.NewWriterSize(padwriter, int(abi.PaddedPieceSize(bsize).Unpadded()))
-
-				_, err := io.CopyN(bw, opr, int64(size))
-				if err != nil {
-					perr = xerrors.Errorf("copying data: %w", err)
-					return
-				}
-
-				if err := bw.Flush(); err != nil {
-					perr = xerrors.Errorf("flushing unpadded data: %w", err)
-					return
-				}
-
-				if err := padwriter.Close(); err != nil {
-					perr = xerrors.Errorf("closing padwriter: %w", err)
-					return
-				}
-			}()
-		}
-		// </eww>
+		// only the run is unsealed, which may be less than the requested range
+		// when parts of it are already unsealed
+		runSize := abi.PaddedPieceSize(piece.Len)
 
 		// TODO: This may be possible to do in parallel
-		err = ffi.UnsealRange(sector.ProofType,
-			srcPaths.Cache,
-			sealed,
-			opw,
-			sector.ID.Number,
-			sector.ID.Miner,
-			randomness,
-			commd,
-			uint64(at.Unpadded()),
-			uint64(abi.PaddedPieceSize(piece.Len).Unpadded()))
-
-		_ = opw.Close()
-
+		err = writeUnsealedRun(ctx, pf, at, runSize, func(opw *os.File) error {
+			return ffi.UnsealRange(sector.ProofType,
+				srcPaths.Cache,
+				sealed,
+				opw,
+				sector.ID.Number,
+				sector.ID.Miner,
+				randomness,
+				commd,
+				uint64(at.Unpadded()),
+				uint64(runSize.Unpadded()))
+		})
 		if err != nil {
-			return xerrors.Errorf("unseal range: %w", err)
-		}
-
-		select {
-		case <-outWait:
-		case <-ctx.Done():
-			return ctx.Err()
-		}
-
-		if perr != nil {
-			return xerrors.Errorf("piping output to unsealed file: %w", perr)
-		}
-
-		if err := pf.MarkAllocated(storiface.PaddedByteIndex(at), abi.PaddedPieceSize(piece.Len)); err != nil {
-			return xerrors.Errorf("marking unsealed range as allocated: %w", err)
+			return err
 		}
 
 		if !toUnseal.HasNext() {
@@ -667,6 +667,73 @@ func TestAddPiece512M(t *testing.T) {
 	require.Equal(t, "baga6ea4seaqhyticusemlcrjhvulpfng4nint6bu3wpe5s3x4bnuj2rs47hfacy", c.PieceCID.String())
 }
 
+type testNullReader struct {
+	*io.LimitedReader
+}
+
+func (r testNullReader) NullBytes() int64 {
+	return r.N
+}
+
+func TestAddNullPiece(t *testing.T) {
+	cdir, err := ioutil.TempDir("", "sbtest-c-")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(cdir)
+	})
+
+	sp := &basicfs.Provider{
+		Root: cdir,
+	}
+	sb, err := New(sp)
+	require.NoError(t, err)
+
+	sz := abi.PaddedPieceSize(1024).Unpadded()
+
+	addPieces := func(num abi.SectorNumber, pad io.Reader) (storage.SectorRef, abi.PieceInfo) {
+		sector := storage.SectorRef{
+			ID: abi.SectorID{
+				Miner:  123,
+				Number: num,
+			},
+			ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1,
+		}
+
+		data := bytes.Repeat([]byte{0xab}, int(sz))
+		_, err := sb.AddPiece(context.TODO(), sector, nil, sz, bytes.NewReader(data))
+		require.NoError(t, err)
+
+		pi, err := sb.AddPiece(context.TODO(), sector, []abi.UnpaddedPieceSize{sz}, sz, pad)
+		require.NoError(t, err)
+
+		return sector, pi
+	}
+
+	_, streamed := addPieces(1, bytes.NewReader(make([]byte, sz)))
+	sector, nullPi := addPieces(2, testNullReader{io.LimitReader(&nullreader.Reader{}, int64(sz)).(*io.LimitedReader)})
+
+	require.Equal(t, streamed, nullPi)
+
+	paths, done, err := sp.AcquireSector(context.TODO(), sector, storiface.FTUnsealed, storiface.FTNone, storiface.PathSealing)
+	require.NoError(t, err)
+	defer done()
+
+	pf, err := openPartialFile(abi.PaddedPieceSize(2048), paths.Unsealed)
+	require.NoError(t, err)
+	defer pf.Close() // nolint
+
+	has, err := pf.HasAllocated(0, 2*sz)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	f, err := pf.Reader(storiface.PaddedByteIndex(1024), 1024)
+	require.NoError(t, err)
+
+	padded, err := ioutil.ReadAll(io.LimitReader(f, 1024))
+	require.NoError(t, err)
+	require.Equal(t, make([]byte, 1024), padded)
+}
+
 func BenchmarkAddPiece512M(b *testing.B) {
 	sz := abi.PaddedPieceSize(512 << 20).Unpadded()
 	b.SetBytes(int64(sz))
@@ -1,12 +1,19 @@
 package ffiwrapper
 
 import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"runtime"
+
 	"golang.org/x/xerrors"
 
 	rlepluslazy "github.com/filecoin-project/go-bitfield/rle"
 
 	"github.com/filecoin-project/go-state-types/abi"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/fr32"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
@@ -25,3 +32,79 @@ func computeUnsealRanges(unsealed rlepluslazy.RunIterator, offset storiface.Unpa
 
 	return rlepluslazy.JoinClose(todo, mergeGaps)
 }
+
+// writeUnsealedRun writes the unpadded data of the run at the padded offset,
+// written by unseal into the pipe it's given, into the unsealed file, and marks
+// the run as allocated
+func writeUnsealedRun(ctx context.Context, pf *partialFile, at, runSize abi.PaddedPieceSize, unseal func(opw *os.File) error) error {
+	out, err := pf.Writer(storiface.PaddedByteIndex(at), runSize)
+	if err != nil {
+		return xerrors.Errorf("getting partial file writer: %w", err)
+	}
+
+	// <eww>
+	opr, opw, err := os.Pipe()
+	if err != nil {
+		return xerrors.Errorf("creating out pipe: %w", err)
+	}
+
+	var perr error
+	outWait := make(chan struct{})
+
+	{
+		go func() {
+			defer close(outWait)
+			defer opr.Close() // nolint
+
+			padwriter := fr32.NewPadWriter(out)
+
+			bsize := uint64(runSize)
+			if bsize > uint64(runtime.NumCPU())*fr32.MTTresh {
+				bsize = uint64(runtime.NumCPU()) * fr32.MTTresh
+			}
+
+			bw := bufio.NewWriterSize(padwriter, int(abi.PaddedPieceSize(bsize).Unpadded()))
+
+			_, err := io.CopyN(bw, opr, int64(runSize.Unpadded()))
+			if err != nil {
+				perr = xerrors.Errorf("copying data: %w", err)
+				return
+			}
+
+			if err := bw.Flush(); err != nil {
+				perr = xerrors.Errorf("flushing unpadded data: %w", err)
+				return
+			}
+
+			if err := padwriter.Close(); err != nil {
+				perr = xerrors.Errorf("closing padwriter: %w", err)
+				return
+			}
+		}()
+	}
+	// </eww>
+
+	err = unseal(opw)
+
+	_ = opw.Close()
+
+	if err != nil {
+		return xerrors.Errorf("unseal range: %w", err)
+	}
+
+	select {
+	case <-outWait:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if perr != nil {
+		return xerrors.Errorf("piping output to unsealed file: %w", perr)
+	}
+
+	if err := pf.MarkAllocated(storiface.PaddedByteIndex(at), runSize); err != nil {
+		return xerrors.Errorf("marking unsealed range as allocated: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package ffiwrapper
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	rlepluslazy "github.com/filecoin-project/go-bitfield/rle"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/fr32"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestComputeUnsealRanges(t *testing.T) {
+	const piece = abi.PaddedPieceSize(1 << 30)
+
+	at := storiface.UnpaddedByteIndex((2 * piece).Unpadded())
+
+	// returns the [start, end) padded ranges to unseal
+	ranges := func(it rlepluslazy.RunIterator) [][2]uint64 {
+		var out [][2]uint64
+		var offset uint64
+		for it.HasNext() {
+			r, err := it.NextRun()
+			require.NoError(t, err)
+			if r.Val {
+				out = append(out, [2]uint64{offset, offset + r.Len})
+			}
+			offset += r.Len
+		}
+		return out
+	}
+
+	// nothing unsealed, only the requested piece is unsealed
+	todo, err := computeUnsealRanges(&rlepluslazy.RunSliceIterator{}, at, piece.Unpadded())
+	require.NoError(t, err)
+	require.Equal(t, [][2]uint64{{uint64(2 * piece), uint64(3 * piece)}}, ranges(todo))
+
+	// the first half of the piece is already unsealed
+	have := pieceRun(storiface.PaddedByteIndex(2*piece), piece/2)
+	todo, err = computeUnsealRanges(have, at, piece.Unpadded())
+	require.NoError(t, err)
+	require.Equal(t, [][2]uint64{{uint64(2*piece + piece/2), uint64(3 * piece)}}, ranges(todo))
+
+	// the whole piece is already unsealed
+	have = pieceRun(0, 4*piece)
+	todo, err = computeUnsealRanges(have, at, piece.Unpadded())
+	require.NoError(t, err)
+	require.Empty(t, ranges(todo))
+}
+
+func TestWriteUnsealedRun(t *testing.T) {
+	const piece = abi.PaddedPieceSize(2048)
+
+	dir, err := ioutil.TempDir("", "lotus-unseal-run-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+
+	pf, err := createPartialFile(4*piece, filepath.Join(dir, "unsealed"))
+	require.NoError(t, err)
+	defer pf.Close() // nolint
+
+	// unseal writes size bytes of b, and records how much it was asked for
+	var requested []abi.PaddedPieceSize
+	unsealer := func(b byte, size abi.PaddedPieceSize) func(*os.File) error {
+		return func(opw *os.File) error {
+			requested = append(requested, size)
+			_, err := opw.Write(bytes.Repeat([]byte{b}, int(size.Unpadded())))
+			return err
+		}
+	}
+
+	// the first half of the piece is already unsealed
+	require.NoError(t, writeUnsealedRun(context.Background(), pf, piece, piece/2, unsealer(1, piece/2)))
+
+	allocated, err := pf.Allocated()
+	require.NoError(t, err)
+	todo, err := computeUnsealRanges(allocated, storiface.UnpaddedByteIndex(piece.Unpadded()), piece.Unpadded())
+	require.NoError(t, err)
+
+	// unseal the rest the way UnsealPiece does
+	var at abi.PaddedPieceSize
+	for todo.HasNext() {
+		run, err := todo.NextRun()
+		require.NoError(t, err)
+
+		runAt := at
+		at += abi.PaddedPieceSize(run.Len)
+		if !run.Val {
+			continue
+		}
+
+		runSize := abi.PaddedPieceSize(run.Len)
+		require.NoError(t, writeUnsealedRun(context.Background(), pf, runAt, runSize, unsealer(2, runSize)))
+	}
+
+	// only the missing half was unsealed
+	require.Equal(t, []abi.PaddedPieceSize{piece / 2, piece / 2}, requested)
+
+	has, err := pf.HasAllocated(storiface.UnpaddedByteIndex(piece.Unpadded()), piece.Unpadded())
+	require.NoError(t, err)
+	require.True(t, has)
+
+	r, err := pf.Reader(storiface.PaddedByteIndex(piece), piece)
+	require.NoError(t, err)
+
+	padded := make([]byte, piece)
+	_, err = io.ReadFull(r, padded)
+	require.NoError(t, err)
+
+	unpadded := make([]byte, piece.Unpadded())
+	fr32.Unpad(padded, unpadded)
+
+	half := int((piece / 2).Unpadded())
+	require.Equal(t, bytes.Repeat([]byte{1}, half), unpadded[:half])
+	require.Equal(t, bytes.Repeat([]byte{2}, half), unpadded[half:])
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-multierror"
@@ -49,6 +50,18 @@ type Worker interface {
 type SectorManager interface {
 	ReadPiece(context.Context, io.Writer, storage.SectorRef, storiface.UnpaddedByteIndex, abi.UnpaddedPieceSize, abi.SealRandomness, cid.Cid) error
 
+	// ReplicateSector makes sure sealed and cache files of a finalized sector
+	// are stored in at least the given number of storage failure domains
+	ReplicateSector(ctx context.Context, sector storage.SectorRef, copies int) error
+
+	// RegenerateCache rebuilds lost or corrupted cache files of a sealed sector
+	RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) error
+
+	// HasUnsealed returns whether an unsealed copy of the sector is stored
+	HasUnsealed(ctx context.Context, sector storage.SectorRef) (bool, error)
+	// RemoveUnsealed removes all unsealed copies of a finalized sector
+	RemoveUnsealed(ctx context.Context, sector storage.SectorRef) error
+
 	ffiwrapper.StorageSealer
 	storage.Prover
 	storiface.WorkerReturn
@@ -91,6 +104,13 @@ type result struct {
 
 type SealerConfig struct {
 	ParallelFetchLimit int
+	// Maximum number of parallel fetches into a single storage path, 0 for no
+	// limit
+	ParallelFetchPathLimit int
+	// Maximum fetch bandwidth in bytes per second, in total and into a single
+	// storage path; 0 for no limit
+	FetchBandwidth     int64
+	FetchPathBandwidth int64
 
 	// Local worker config
 	AllowAddPiece   bool
@@ -98,6 +118,48 @@ type SealerConfig struct {
 	AllowPreCommit2 bool
 	AllowCommit     bool
 	AllowUnseal     bool
+	// Regenerating lost sector cache files needs the same resources as
+	// sealing the sector
+	AllowRegenCache bool
+
+	// Keep sealing tasks of a sector on the worker which started sealing it,
+	// also when the worker isn't part of any group
+	PinSectorsToWorker bool
+
+	// Hold back Finalize and Fetch tasks using storage paths which hold
+	// sectors of an upcoming window PoSt deadline, from this many epochs before
+	// the deadline opens until its proofs are submitted. 0 disables deferring.
+	PoStIODeferEpochs abi.ChainEpoch
+
+	// Check sectors of window PoSt deadlines this many epochs before the
+	// deadline opens, and declare faults for sectors which can't be proven.
+	// Faults can only be declared before the fault cutoff, 70 epochs before
+	// the deadline opens. 0 disables pre-checking.
+	PoStPrecheckEpochs abi.ChainEpoch
+	// Fraction (0 to 1) of the sectors whose sealed data is read at random
+	// challenges each time sectors are checked before window PoSt, so that
+	// corrupt data is found before the proof fails. The files of all sectors
+	// are always checked for presence and size. Reading the challenges costs
+	// random reads of the sealed files of every sampled sector on each check,
+	// so sampling is disabled (0) by default.
+	PoStCheckSampleRate float64
+
+	// Maximum number of partitions proven in a single window PoSt message; 0
+	// for the network limit. Proofs of separate messages can be generated in
+	// parallel, and a failing proof only fails the partitions of its message.
+	PoStMaxPartitionsPerMessage int
+	// Number of window PoSt messages of a deadline for which proofs are
+	// generated at the same time
+	PoStParallelProofs int
+	// Number of times generating a window PoSt proof is retried when it fails
+	// for reasons other than unprovable sectors. Retries wait 10 seconds after
+	// the first failure, doubling up to 2 minutes.
+	PoStProofRetries int
+
+	// Don't declare recoveries of faulty sectors which can be proven again.
+	// Faulty sectors are still checked, the results are listed by
+	// lotus-miner proving recoveries.
+	PoStDisableRecoveryDeclarations bool
 }
 
 type StorageAuth http.Header
@@ -116,7 +178,11 @@ func New(ctx context.Context, ls stores.LocalStorage, si stores.SectorIndex, sc
 		return nil, xerrors.Errorf("creating prover instance: %w", err)
 	}
 
-	stor := stores.NewRemote(lstor, si, http.Header(sa), sc.ParallelFetchLimit)
+	stor := stores.NewRemote(lstor, si, http.Header(sa), sc.ParallelFetchLimit, stores.FetchLimits{
+		PathConcurrency: sc.ParallelFetchPathLimit,
+		Bandwidth:       sc.FetchBandwidth,
+		PathBandwidth:   sc.FetchPathBandwidth,
+	})
 
 	m := &Manager{
 		ls:         ls,
@@ -136,6 +202,9 @@ func New(ctx context.Context, ls stores.LocalStorage, si stores.SectorIndex, sc
 		waitRes:    map[WorkID]chan struct{}{},
 	}
 
+	m.sched.pinToWorker = sc.PinSectorsToWorker
+	m.sched.taskPaths = m.taskStoragePaths
+
 	m.setupWorkTracker()
 
 	go m.sched.runSched()
@@ -158,6 +227,9 @@ func New(ctx context.Context, ls stores.LocalStorage, si stores.SectorIndex, sc
 	if sc.AllowUnseal {
 		localTasks = append(localTasks, sealtasks.TTUnseal)
 	}
+	if sc.AllowRegenCache {
+		localTasks = append(localTasks, sealtasks.TTRegenCache)
+	}
 
 	err = m.AddWorker(ctx, NewLocalWorker(WorkerConfig{
 		TaskTypes: localTasks,
@@ -602,6 +674,8 @@ func (m *Manager) Remove(ctx context.Context, sector storage.SectorRef) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	m.sched.unpinSector(sector.ID)
+
 	if err := m.index.StorageLock(ctx, sector.ID, storiface.FTNone, storiface.FTSealed|storiface.FTUnsealed|storiface.FTCache); err != nil {
 		return xerrors.Errorf("acquiring sector lock: %w", err)
 	}
@@ -621,6 +695,53 @@ func (m *Manager) Remove(ctx context.Context, sector storage.SectorRef) error {
 	return err
 }
 
+// RegenerateCache rebuilds lost or corrupted cache files of a sealed sector
+// by sealing its data again, on a worker with access to the sealed file. It is
+// scheduled as the lowest priority task.
+func (m *Manager) RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := m.index.StorageLock(ctx, sector.ID, storiface.FTSealed|storiface.FTUnsealed, storiface.FTCache); err != nil {
+		return xerrors.Errorf("acquiring sector lock: %w", err)
+	}
+
+	selector := newExistingSelector(m.index, sector.ID, storiface.FTSealed, false)
+
+	return m.sched.Schedule(ctx, sector, sealtasks.TTRegenCache, selector, schedNop, func(ctx context.Context, w Worker) error {
+		_, err := m.waitSimpleCall(ctx)(w.RegenerateCache(ctx, sector, ticket, pieces, sealed))
+		return err
+	})
+}
+
+func (m *Manager) ReplicateSector(ctx context.Context, sector storage.SectorRef, copies int) error {
+	return m.storage.ReplicateSector(ctx, sector, storiface.FTSealed|storiface.FTCache, copies)
+}
+
+func (m *Manager) HasUnsealed(ctx context.Context, sector storage.SectorRef) (bool, error) {
+	best, err := m.index.StorageFindSector(ctx, sector.ID, storiface.FTUnsealed, 0, false)
+	if err != nil {
+		return false, xerrors.Errorf("finding unsealed sector: %w", err)
+	}
+
+	return len(best) > 0, nil
+}
+
+func (m *Manager) RemoveUnsealed(ctx context.Context, sector storage.SectorRef) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := m.index.StorageLock(ctx, sector.ID, storiface.FTNone, storiface.FTUnsealed); err != nil {
+		return xerrors.Errorf("acquiring sector lock: %w", err)
+	}
+
+	if err := m.storage.Remove(ctx, sector.ID, storiface.FTUnsealed, true); err != nil {
+		return xerrors.Errorf("removing sector (unsealed): %w", err)
+	}
+
+	return nil
+}
+
 func (m *Manager) ReturnAddPiece(ctx context.Context, callID storiface.CallID, pi abi.PieceInfo, err *storiface.CallError) error {
 	return m.returnResult(callID, pi, err)
 }
@@ -665,6 +786,10 @@ func (m *Manager) ReturnFetch(ctx context.Context, callID storiface.CallID, err
 	return m.returnResult(callID, nil, err)
 }
 
+func (m *Manager) ReturnRegenerateCache(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	return m.returnResult(callID, nil, err)
+}
+
 func (m *Manager) StorageLocal(ctx context.Context) (map[stores.ID]string, error) {
 	l, err := m.localStore.Local(ctx)
 	if err != nil {
@@ -683,6 +808,114 @@ func (m *Manager) FsStat(ctx context.Context, id stores.ID) (fsutil.FsStat, erro
 	return m.storage.FsStat(ctx, id)
 }
 
+// WorkerDrain puts the worker into / out of drain mode
+func (m *Manager) WorkerDrain(wid uuid.UUID, drain bool) error {
+	return m.sched.SetDraining(WorkerID(wid), drain)
+}
+
+// profiledWorker is implemented by workers which can capture runtime profiles
+// of their process
+type profiledWorker interface {
+	ProfileCapture(ctx context.Context, name string, duration time.Duration) ([]byte, error)
+}
+
+// WorkerProfileCapture captures the named runtime profile of a worker process
+func (m *Manager) WorkerProfileCapture(ctx context.Context, wid uuid.UUID, name string, duration time.Duration) ([]byte, error) {
+	m.sched.workersLk.RLock()
+	w, ok := m.sched.workers[WorkerID(wid)]
+	m.sched.workersLk.RUnlock()
+	if !ok {
+		return nil, xerrors.Errorf("worker %s not found", wid)
+	}
+
+	pw, ok := w.workerRpc.(profiledWorker)
+	if !ok {
+		return nil, xerrors.Errorf("worker %s doesn't support capturing profiles", wid)
+	}
+
+	return pw.ProfileCapture(ctx, name, duration)
+}
+
+// DeferIO holds back Finalize and Fetch tasks using storage paths which hold
+// any of the given sectors, until ResumeIO is called
+func (m *Manager) DeferIO(ctx context.Context, sectors []abi.SectorID) error {
+	paths := map[stores.ID]struct{}{}
+	for _, sector := range sectors {
+		infos, err := m.index.StorageFindSector(ctx, sector, storiface.FTSealed|storiface.FTCache, 0, false)
+		if err != nil {
+			return xerrors.Errorf("finding sector %d: %w", sector.Number, err)
+		}
+
+		for _, info := range infos {
+			paths[info.ID] = struct{}{}
+		}
+	}
+
+	out := make([]stores.ID, 0, len(paths))
+	for id := range paths {
+		out = append(out, id)
+	}
+
+	log.Infow("deferring finalize / fetch I/O for window PoSt", "sectors", len(sectors), "paths", len(out))
+
+	m.sched.deferIO(out)
+	return nil
+}
+
+// ResumeIO resumes tasks held back by DeferIO
+func (m *Manager) ResumeIO() {
+	log.Info("resuming finalize / fetch I/O")
+
+	m.sched.deferIO(nil)
+}
+
+// taskStoragePaths returns storage paths a task reads from or may write to
+func (m *Manager) taskStoragePaths(req *workerRequest) []stores.ID {
+	ctx := req.ctx
+
+	var out []stores.ID
+
+	infos, err := m.index.StorageFindSector(ctx, req.sector.ID, storiface.FTUnsealed|storiface.FTSealed|storiface.FTCache, 0, false)
+	if err != nil {
+		log.Warnw("finding sector storage", "sector", req.sector.ID, "error", err)
+	}
+	for _, info := range infos {
+		out = append(out, info.ID)
+	}
+
+	if req.taskType == sealtasks.TTFinalize {
+		ssize, err := req.sector.ProofType.SectorSize()
+		if err != nil {
+			log.Warnw("getting sector size", "sector", req.sector.ID, "error", err)
+			return out
+		}
+
+		// finalized sectors are most likely moved to the best storage path
+		best, err := m.index.StorageBestAlloc(ctx, storiface.FTSealed|storiface.FTCache, ssize, storiface.PathStorage)
+		if err == nil && len(best) > 0 {
+			out = append(out, best[0].ID)
+		}
+	}
+
+	return out
+}
+
+// Transfers returns running and queued fetches of sector files into storage
+// paths of this node
+func (m *Manager) Transfers() []stores.TransferInfo {
+	return m.storage.Transfers()
+}
+
+// SetTaskPriority sets the scheduling priority of tasks of the given type which
+// don't have an explicit priority set
+func (m *Manager) SetTaskPriority(tt sealtasks.TaskType, priority int) {
+	m.sched.SetTaskPriority(tt, priority)
+}
+
+func (m *Manager) TaskPriorities() map[sealtasks.TaskType]int {
+	return m.sched.TaskPriorities()
+}
+
 func (m *Manager) SchedDiag(ctx context.Context, doSched bool) (interface{}, error) {
 	if doSched {
 		select {
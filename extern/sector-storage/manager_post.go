@@ -0,0 +1,81 @@
+package sectorstorage
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// GenerateWinningPoSt generates the proof on a worker with the winning PoSt
+// task enabled. When no such worker is connected, the proof is generated by
+// the miner process.
+func (m *Manager) GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, error) {
+	if len(sectorInfo) == 0 || !m.sched.hasWorkers(sealtasks.TTGenerateWinningPoSt) {
+		return m.Prover.GenerateWinningPoSt(ctx, minerID, sectorInfo, randomness)
+	}
+
+	var proofs []proof2.PoStProof
+	err := m.sched.Schedule(ctx, postSector(minerID, sectorInfo), sealtasks.TTGenerateWinningPoSt, newTaskSelector(), schedNop, func(ctx context.Context, w Worker) error {
+		r, err := m.waitSimpleCall(ctx)(w.GenerateWinningPoSt(ctx, minerID, sectorInfo, randomness))
+		if err != nil {
+			return err
+		}
+
+		proofs = r.([]proof2.PoStProof)
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("generating winning PoSt on worker: %w", err)
+	}
+
+	return proofs, nil
+}
+
+// GenerateWindowPoSt generates the proof on a worker with the window PoSt task
+// enabled. Workers read sectors from their local storage paths, which are
+// usually shared with the miner. When no such worker is connected, the proof
+// is generated by the miner process.
+func (m *Manager) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, []abi.SectorID, error) {
+	if len(sectorInfo) == 0 || !m.sched.hasWorkers(sealtasks.TTGenerateWindowPoSt) {
+		return m.Prover.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness)
+	}
+
+	var res storiface.WindowPoStResult
+	err := m.sched.Schedule(ctx, postSector(minerID, sectorInfo), sealtasks.TTGenerateWindowPoSt, newTaskSelector(), schedNop, func(ctx context.Context, w Worker) error {
+		r, err := m.waitSimpleCall(ctx)(w.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness))
+		if r != nil {
+			// skipped sectors are also returned with the error
+			res = r.(storiface.WindowPoStResult)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, res.Skipped, xerrors.Errorf("generating window PoSt on worker: %w", err)
+	}
+
+	return res.PoStProofs, res.Skipped, nil
+}
+
+func (m *Manager) ReturnGenerateWinningPoSt(ctx context.Context, callID storiface.CallID, proofs []proof2.PoStProof, err *storiface.CallError) error {
+	return m.returnResult(callID, proofs, err)
+}
+
+func (m *Manager) ReturnGenerateWindowPoSt(ctx context.Context, callID storiface.CallID, res storiface.WindowPoStResult, err *storiface.CallError) error {
+	return m.returnResult(callID, res, err)
+}
+
+// postSector returns the sector used to schedule a PoSt task. Resources needed
+// by the task are determined by the seal proof type of the proven sectors.
+func postSector(minerID abi.ActorID, sectorInfo []proof2.SectorInfo) storage.SectorRef {
+	return storage.SectorRef{
+		ID:        abi.SectorID{Miner: minerID},
+		ProofType: sectorInfo[0].SealProof,
+	}
+}
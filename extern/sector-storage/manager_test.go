@@ -21,6 +21,7 @@ import (
 
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-statestore"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
@@ -98,7 +99,7 @@ func newTestMgr(ctx context.Context, t *testing.T, ds datastore.Datastore) (*Man
 	prover, err := ffiwrapper.New(&readonlyProvider{stor: lstor, index: si})
 	require.NoError(t, err)
 
-	stor := stores.NewRemote(lstor, si, nil, 6000)
+	stor := stores.NewRemote(lstor, si, nil, 6000, stores.FetchLimits{})
 
 	m := &Manager{
 		ls:         st,
@@ -453,3 +454,35 @@ func TestReenableWorker(t *testing.T) {
 	i, _ = m.sched.Info(ctx)
 	require.Len(t, i.(SchedDiagInfo).OpenWindows, 2)
 }
+
+func TestPoStWorker(t *testing.T) {
+	logging.SetAllLoggers(logging.LevelDebug)
+
+	ctx := context.Background()
+	m, lstor, _, _, cleanup := newTestMgr(ctx, t, datastore.NewMapDatastore())
+	defer cleanup()
+
+	err := m.AddWorker(ctx, newTestWorker(WorkerConfig{
+		TaskTypes: []sealtasks.TaskType{sealtasks.TTGenerateWinningPoSt, sealtasks.TTGenerateWindowPoSt},
+	}, lstor, m))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return m.sched.hasWorkers(sealtasks.TTGenerateWindowPoSt)
+	}, time.Second, 10*time.Millisecond)
+
+	sinfos := []proof2.SectorInfo{{
+		SealProof:    abi.RegisteredSealProof_StackedDrg2KiBV1,
+		SectorNumber: 1,
+	}}
+
+	// sectors aren't stored in the miner, the proof is generated by the worker
+	proofs, err := m.GenerateWinningPoSt(ctx, 1000, sinfos, abi.PoStRandomness(make([]byte, 32)))
+	require.NoError(t, err)
+	require.Len(t, proofs, 1)
+
+	// skipped sectors are returned along with the error
+	_, skipped, err := m.GenerateWindowPoSt(ctx, 1000, sinfos, abi.PoStRandomness(make([]byte, 32)))
+	require.Error(t, err)
+	require.Equal(t, []abi.SectorID{{Miner: 1000, Number: 1}}, skipped)
+}
@@ -405,6 +405,22 @@ func (mgr *SectorMgr) Remove(ctx context.Context, sector storage.SectorRef) erro
 	return nil
 }
 
+func (mgr *SectorMgr) ReplicateSector(ctx context.Context, sector storage.SectorRef, copies int) error {
+	return nil
+}
+
+func (mgr *SectorMgr) RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) error {
+	return nil
+}
+
+func (mgr *SectorMgr) HasUnsealed(ctx context.Context, sector storage.SectorRef) (bool, error) {
+	return true, nil
+}
+
+func (mgr *SectorMgr) RemoveUnsealed(ctx context.Context, sector storage.SectorRef) error {
+	return nil
+}
+
 func (mgr *SectorMgr) CheckProvable(ctx context.Context, pp abi.RegisteredPoStProof, ids []storage.SectorRef, rg storiface.RGetter) (map[abi.SectorID]string, error) {
 	bad := map[abi.SectorID]string{}
 
@@ -463,6 +479,18 @@ func (mgr *SectorMgr) ReturnFetch(ctx context.Context, callID storiface.CallID,
 	panic("not supported")
 }
 
+func (mgr *SectorMgr) ReturnRegenerateCache(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	panic("not supported")
+}
+
+func (mgr *SectorMgr) ReturnGenerateWinningPoSt(ctx context.Context, callID storiface.CallID, proofs []proof2.PoStProof, err *storiface.CallError) error {
+	panic("not supported")
+}
+
+func (mgr *SectorMgr) ReturnGenerateWindowPoSt(ctx context.Context, callID storiface.CallID, res storiface.WindowPoStResult, err *storiface.CallError) error {
+	panic("not supported")
+}
+
 func (m mockVerif) VerifySeal(svi proof2.SealVerifyInfo) (bool, error) {
 	if len(svi.Proof) != 1920 {
 		return false, nil
@@ -309,11 +309,109 @@ var ResourceTable = map[sealtasks.TaskType]map[abi.RegisteredSealProof]Resources
 			BaseMinMemory: 0,
 		},
 	},
+	sealtasks.TTGenerateWindowPoSt: {
+		abi.RegisteredSealProof_StackedDrg64GiBV1: Resources{
+			MaxMemory: 120 << 30,
+			MinMemory: 60 << 30,
+
+			MaxParallelism: -1,
+			CanGPU:         true,
+
+			BaseMinMemory: 64 << 30, // params
+		},
+		abi.RegisteredSealProof_StackedDrg32GiBV1: Resources{
+			MaxMemory: 96 << 30,
+			MinMemory: 30 << 30,
+
+			MaxParallelism: -1,
+			CanGPU:         true,
+
+			BaseMinMemory: 32 << 30, // params
+		},
+		abi.RegisteredSealProof_StackedDrg512MiBV1: Resources{
+			MaxMemory: 3 << 29, // 1.5G
+			MinMemory: 1 << 30,
+
+			MaxParallelism: -1,
+			CanGPU:         true,
+
+			BaseMinMemory: 10 << 30,
+		},
+		abi.RegisteredSealProof_StackedDrg2KiBV1: Resources{
+			MaxMemory: 2 << 10,
+			MinMemory: 2 << 10,
+
+			MaxParallelism: -1,
+			CanGPU:         true,
+
+			BaseMinMemory: 2 << 10,
+		},
+		abi.RegisteredSealProof_StackedDrg8MiBV1: Resources{
+			MaxMemory: 8 << 20,
+			MinMemory: 8 << 20,
+
+			MaxParallelism: -1,
+			CanGPU:         true,
+
+			BaseMinMemory: 8 << 20,
+		},
+	},
+	sealtasks.TTGenerateWinningPoSt: { // Only a few challenged sectors are read
+		// Not accounted as a GPU task, and using a single thread, so that
+		// winning PoSt doesn't wait for window PoSt running on the same worker
+		abi.RegisteredSealProof_StackedDrg64GiBV1: Resources{
+			MaxMemory: 1 << 30,
+			MinMemory: 1 << 30,
+
+			MaxParallelism: 1,
+			CanGPU:         false,
+
+			BaseMinMemory: 1 << 30,
+		},
+		abi.RegisteredSealProof_StackedDrg32GiBV1: Resources{
+			MaxMemory: 1 << 30,
+			MinMemory: 1 << 30,
+
+			MaxParallelism: 1,
+			CanGPU:         false,
+
+			BaseMinMemory: 1 << 30,
+		},
+		abi.RegisteredSealProof_StackedDrg512MiBV1: Resources{
+			MaxMemory: 1 << 30,
+			MinMemory: 1 << 30,
+
+			MaxParallelism: 1,
+			CanGPU:         false,
+
+			BaseMinMemory: 1 << 30,
+		},
+		abi.RegisteredSealProof_StackedDrg2KiBV1: Resources{
+			MaxMemory: 2 << 10,
+			MinMemory: 2 << 10,
+
+			MaxParallelism: 1,
+			CanGPU:         false,
+
+			BaseMinMemory: 2 << 10,
+		},
+		abi.RegisteredSealProof_StackedDrg8MiBV1: Resources{
+			MaxMemory: 8 << 20,
+			MinMemory: 8 << 20,
+
+			MaxParallelism: 1,
+			CanGPU:         false,
+
+			BaseMinMemory: 8 << 20,
+		},
+	},
 }
 
 func init() {
 	ResourceTable[sealtasks.TTUnseal] = ResourceTable[sealtasks.TTPreCommit1] // TODO: measure accurately
 	ResourceTable[sealtasks.TTReadUnsealed] = ResourceTable[sealtasks.TTFetch]
+	// regenerating runs PC1 and PC2, PC1 needs the most memory
+	ResourceTable[sealtasks.TTRegenCache] = ResourceTable[sealtasks.TTPreCommit1]
 
 	// V1_1 is the same as V1
 	for _, m := range ResourceTable {
@@ -14,6 +14,7 @@ import (
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
@@ -37,6 +38,11 @@ func getPriority(ctx context.Context) int {
 	return DefaultSchedPriority
 }
 
+func hasPriority(ctx context.Context) bool {
+	_, ok := ctx.Value(SchedPriorityKey).(int)
+	return ok
+}
+
 func WithPriority(ctx context.Context, priority int) context.Context {
 	return context.WithValue(ctx, SchedPriorityKey, priority)
 }
@@ -59,6 +65,21 @@ type scheduler struct {
 	windowRequests chan *schedWindowRequest
 	workerChange   chan struct{} // worker added / changed/freed resources
 	workerDisable  chan workerDisableReq
+	prioChange     chan struct{}
+
+	// per task type priorities used for requests without an explicit priority
+	prioLk         sync.Mutex
+	taskPriorities map[sealtasks.TaskType]int
+
+	// sectors pinned to workers / worker groups, see sched_pins.go
+	pinLk       sync.Mutex
+	pins        map[abi.SectorID]sectorPin
+	pinToWorker bool
+
+	// storage paths with deferred I/O, see sched_iodefer.go
+	ioLk      sync.Mutex
+	ioPaths   map[stores.ID]struct{}
+	taskPaths func(*workerRequest) []stores.ID
 
 	// owned by the sh.runSched goroutine
 	schedQueue  *requestQueue
@@ -77,6 +98,8 @@ type workerHandle struct {
 	workerRpc Worker
 
 	info storiface.WorkerInfo
+	// task types the worker accepts, which don't change while it's connected
+	tasks map[sealtasks.TaskType]struct{}
 
 	preparing *activeResources
 	active    *activeResources
@@ -87,6 +110,8 @@ type workerHandle struct {
 	activeWindows []*schedWindow
 
 	enabled bool
+	// draining workers finish assigned tasks, but don't get new ones
+	draining bool
 
 	// for sync manager goroutine closing
 	cleanupStarted bool
@@ -117,13 +142,16 @@ type activeResources struct {
 	gpuUsed    bool
 	cpuUse     uint64
 
+	taskCounts map[sealtasks.TaskType]int
+
 	cond *sync.Cond
 }
 
 type workerRequest struct {
 	sector   storage.SectorRef
 	taskType sealtasks.TaskType
-	priority int // larger values more important
+	priority int  // larger values more important
+	prioSet  bool // priority set explicitly in the request context
 	sel      WorkerSelector
 
 	prepare WorkerAction
@@ -150,6 +178,10 @@ func newScheduler() *scheduler {
 		windowRequests: make(chan *schedWindowRequest, 20),
 		workerChange:   make(chan struct{}, 20),
 		workerDisable:  make(chan workerDisableReq),
+		prioChange:     make(chan struct{}, 1),
+
+		taskPriorities: map[sealtasks.TaskType]int{},
+		pins:           map[abi.SectorID]sectorPin{},
 
 		schedQueue: &requestQueue{},
 
@@ -172,7 +204,8 @@ func (sh *scheduler) Schedule(ctx context.Context, sector storage.SectorRef, tas
 	case sh.schedule <- &workerRequest{
 		sector:   sector,
 		taskType: taskType,
-		priority: getPriority(ctx),
+		priority: sh.requestPriority(ctx, taskType),
+		prioSet:  hasPriority(ctx),
 		sel:      sel,
 
 		prepare: prepare,
@@ -199,6 +232,106 @@ func (sh *scheduler) Schedule(ctx context.Context, sector storage.SectorRef, tas
 	}
 }
 
+// hasWorkers returns whether an enabled worker accepting the task type is
+// connected
+func (sh *scheduler) hasWorkers(taskType sealtasks.TaskType) bool {
+	sh.workersLk.RLock()
+	defer sh.workersLk.RUnlock()
+
+	for _, w := range sh.workers {
+		if !w.enabled || w.draining {
+			continue
+		}
+
+		if _, ok := w.tasks[taskType]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (sh *scheduler) requestPriority(ctx context.Context, taskType sealtasks.TaskType) int {
+	if hasPriority(ctx) {
+		return getPriority(ctx)
+	}
+
+	sh.prioLk.Lock()
+	defer sh.prioLk.Unlock()
+
+	if p, ok := sh.taskPriorities[taskType]; ok {
+		return p
+	}
+
+	return DefaultSchedPriority
+}
+
+// SetTaskPriority sets the priority of requests of the given task type which
+// don't have an explicit priority set. Setting DefaultSchedPriority removes the
+// override.
+func (sh *scheduler) SetTaskPriority(taskType sealtasks.TaskType, priority int) {
+	sh.prioLk.Lock()
+	if priority == DefaultSchedPriority {
+		delete(sh.taskPriorities, taskType)
+	} else {
+		sh.taskPriorities[taskType] = priority
+	}
+	sh.prioLk.Unlock()
+
+	select {
+	case sh.prioChange <- struct{}{}:
+	default: // already pending
+	}
+}
+
+func (sh *scheduler) TaskPriorities() map[sealtasks.TaskType]int {
+	sh.prioLk.Lock()
+	defer sh.prioLk.Unlock()
+
+	out := make(map[sealtasks.TaskType]int, len(sh.taskPriorities))
+	for tt, p := range sh.taskPriorities {
+		out[tt] = p
+	}
+
+	return out
+}
+
+// SetDraining puts the worker into / out of drain mode. Draining workers finish
+// running and already assigned tasks, but don't get any new tasks assigned.
+func (sh *scheduler) SetDraining(wid WorkerID, drain bool) error {
+	sh.workersLk.Lock()
+	w, ok := sh.workers[wid]
+	if !ok {
+		sh.workersLk.Unlock()
+		return xerrors.Errorf("worker %s not found", uuid.UUID(wid))
+	}
+	w.draining = drain
+	sh.workersLk.Unlock()
+
+	if !drain {
+		// the worker may have open windows which can be used now
+		select {
+		case sh.workerChange <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// re-prioritize queued requests after task priorities changed
+func (sh *scheduler) updatePriorities() {
+	for _, req := range *sh.schedQueue {
+		if req.prioSet {
+			continue
+		}
+
+		req.priority = sh.requestPriority(req.ctx, req.taskType)
+	}
+
+	sort.Sort(sh.schedQueue)
+}
+
 func (r *workerRequest) respond(err error) {
 	select {
 	case r.ret <- workerResponse{err: err}:
@@ -244,6 +377,9 @@ func (sh *scheduler) runSched() {
 		case req := <-sh.windowRequests:
 			sh.openWindows = append(sh.openWindows, req)
 			doSched = true
+		case <-sh.prioChange:
+			sh.updatePriorities()
+			doSched = true
 		case ireq := <-sh.info:
 			ireq(sh.diag())
 
@@ -328,6 +464,50 @@ func (sh *scheduler) diag() SchedDiagInfo {
 	return out
 }
 
+// selOkCache keeps the results of task selector Ok calls during a scheduling
+// pass, so that each task is checked against each worker once, no matter how
+// many windows the worker has open, or how often its sector pin is checked
+type selOkCache struct {
+	lk  sync.Mutex
+	res map[selOkKey]selOkRes
+}
+
+type selOkKey struct {
+	task   *workerRequest
+	worker WorkerID
+}
+
+type selOkRes struct {
+	ok  bool
+	err error
+}
+
+func newSelOkCache() *selOkCache {
+	return &selOkCache{res: map[selOkKey]selOkRes{}}
+}
+
+// ok returns whether the selector of the task accepts the worker
+func (c *selOkCache) ok(task *workerRequest, wid WorkerID, w *workerHandle) (bool, error) {
+	key := selOkKey{task: task, worker: wid}
+
+	c.lk.Lock()
+	res, found := c.res[key]
+	c.lk.Unlock()
+	if found {
+		return res.ok, res.err
+	}
+
+	rpcCtx, cancel := context.WithTimeout(task.ctx, SelectorTimeout)
+	res.ok, res.err = task.sel.Ok(rpcCtx, task.taskType, task.sector.ProofType, w)
+	cancel()
+
+	c.lk.Lock()
+	c.res[key] = res
+	c.lk.Unlock()
+
+	return res.ok, res.err
+}
+
 func (sh *scheduler) trySched() {
 	/*
 		This assigns tasks to workers based on:
@@ -351,6 +531,8 @@ func (sh *scheduler) trySched() {
 	windowsLen := len(sh.openWindows)
 	queuneLen := sh.schedQueue.Len()
 
+	oks := newSelOkCache()
+
 	log.Debugf("SCHED %d queued; %d open windows", queuneLen, windowsLen)
 
 	if windowsLen == 0 || queuneLen == 0 {
@@ -379,6 +561,11 @@ func (sh *scheduler) trySched() {
 			needRes := ResourceTable[task.taskType][task.sector.ProofType]
 
 			task.indexHeap = sqi
+
+			if sh.ioDeferred(task) {
+				log.Debugw("deferring task using storage paths busy with window PoSt", "sector", task.sector.ID, "task", task.taskType)
+				return
+			}
 			for wnd, windowRequest := range sh.openWindows {
 				worker, ok := sh.workers[windowRequest.worker]
 				if !ok {
@@ -392,14 +579,21 @@ func (sh *scheduler) trySched() {
 					continue
 				}
 
+				if worker.draining {
+					log.Debugw("skipping draining worker", "worker", windowRequest.worker)
+					continue
+				}
+
+				if !sh.pinOk(task, windowRequest.worker, worker, oks) {
+					continue
+				}
+
 				// TODO: allow bigger windows
-				if !windows[wnd].allocated.canHandleRequest(needRes, windowRequest.worker, "schedAcceptable", worker.info.Resources) {
+				if !windows[wnd].allocated.canHandleRequest(task.taskType, needRes, windowRequest.worker, "schedAcceptable", worker.info.Resources) {
 					continue
 				}
 
-				rpcCtx, cancel := context.WithTimeout(task.ctx, SelectorTimeout)
-				ok, err := task.sel.Ok(rpcCtx, task.taskType, task.sector.ProofType, worker)
-				cancel()
+				ok, err := oks.ok(task, windowRequest.worker, worker)
 				if err != nil {
 					log.Errorf("trySched(1) req.sel.Ok error: %+v", err)
 					continue
@@ -464,14 +658,20 @@ func (sh *scheduler) trySched() {
 
 			log.Debugf("SCHED try assign sqi:%d sector %d to window %d", sqi, task.sector.ID.Number, wnd)
 
+			// another task of the sector may have pinned it in this loop
+			if !sh.pinOk(task, wid, sh.workers[wid], oks) {
+				continue
+			}
+
 			// TODO: allow bigger windows
-			if !windows[wnd].allocated.canHandleRequest(needRes, wid, "schedAssign", wr) {
+			if !windows[wnd].allocated.canHandleRequest(task.taskType, needRes, wid, "schedAssign", wr) {
 				continue
 			}
 
 			log.Debugf("SCHED ASSIGNED sqi:%d sector %d task %s to window %d", sqi, task.sector.ID.Number, task.taskType, wnd)
 
-			windows[wnd].allocated.add(wr, needRes)
+			windows[wnd].allocated.add(wr, task.taskType, needRes)
+			sh.pinSector(task, wid, sh.workers[wid])
 			// TODO: We probably want to re-sort acceptableWindows here based on new
 			//  workerHandle.utilization + windows[wnd].allocated.utilization (workerHandle.utilization is used in all
 			//  task selectors, but not in the same way, so need to figure out how to do that in a non-O(n^2 way), and
@@ -0,0 +1,59 @@
+package sectorstorage
+
+import (
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+)
+
+// ioDeferredTasks are tasks doing heavy, but not time critical I/O. They are
+// held back while storage paths they use are read for window PoSt, so that
+// I/O contention doesn't make proofs miss their deadline.
+var ioDeferredTasks = map[sealtasks.TaskType]struct{}{
+	sealtasks.TTFinalize: {},
+	sealtasks.TTFetch:    {},
+}
+
+// deferIO sets the storage paths on which I/O is deferred. An empty list
+// resumes all deferred tasks.
+func (sh *scheduler) deferIO(paths []stores.ID) {
+	sh.ioLk.Lock()
+	sh.ioPaths = map[stores.ID]struct{}{}
+	for _, p := range paths {
+		sh.ioPaths[p] = struct{}{}
+	}
+	sh.ioLk.Unlock()
+
+	// deferred tasks may be schedulable now
+	select {
+	case sh.workerChange <- struct{}{}:
+	default:
+	}
+}
+
+func (sh *scheduler) ioDeferred(task *workerRequest) bool {
+	if _, ok := ioDeferredTasks[task.taskType]; !ok {
+		return false
+	}
+
+	sh.ioLk.Lock()
+	deferring := len(sh.ioPaths) > 0
+	sh.ioLk.Unlock()
+
+	if !deferring || sh.taskPaths == nil {
+		return false
+	}
+
+	// resolved without holding ioLk, this can query the sector index
+	paths := sh.taskPaths(task)
+
+	sh.ioLk.Lock()
+	defer sh.ioLk.Unlock()
+
+	for _, p := range paths {
+		if _, ok := sh.ioPaths[p]; ok {
+			return true
+		}
+	}
+
+	return false
+}
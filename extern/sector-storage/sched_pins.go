@@ -0,0 +1,135 @@
+package sectorstorage
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+)
+
+// pinnedTasks are the tasks which need the sector cache / unsealed files
+// created by AddPiece and PreCommit1. Once one of those tasks is assigned to a
+// worker in a group, the following ones are kept on workers in that group,
+// avoiding transferring the (multi-gigabyte) files between workers.
+var pinnedTasks = map[sealtasks.TaskType]struct{}{
+	sealtasks.TTAddPiece:   {},
+	sealtasks.TTPreCommit1: {},
+	sealtasks.TTPreCommit2: {},
+	sealtasks.TTCommit1:    {},
+	sealtasks.TTFinalize:   {},
+}
+
+type sectorPin struct {
+	worker WorkerID
+	groups []string
+}
+
+func (p sectorPin) matches(wid WorkerID, w *workerHandle) bool {
+	if len(p.groups) == 0 {
+		return wid == p.worker
+	}
+
+	for _, g := range w.info.Groups {
+		for _, pg := range p.groups {
+			if g == pg {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// pinOk returns whether the task can be assigned to the worker given the
+// sector pin. Whether pinned workers can run the task is checked through oks,
+// the selector results of the scheduling pass. Must be called with
+// sh.workersLk held.
+func (sh *scheduler) pinOk(task *workerRequest, wid WorkerID, w *workerHandle, oks *selOkCache) bool {
+	if _, ok := pinnedTasks[task.taskType]; !ok {
+		return true
+	}
+
+	sh.pinLk.Lock()
+	pin, ok := sh.pins[task.sector.ID]
+	sh.pinLk.Unlock()
+	if !ok {
+		return true
+	}
+
+	if pin.matches(wid, w) {
+		return true
+	}
+
+	// don't get stuck when the pinned worker / group goes away, or none of
+	// its workers can run the task
+	for id, other := range sh.workers {
+		if !other.enabled || other.draining || !pin.matches(id, other) {
+			continue
+		}
+
+		ok, err := oks.ok(task, id, other)
+		if err != nil {
+			log.Warnw("checking whether a pinned worker can run the task", "sector", task.sector.ID, "task", task.taskType, "worker", id, "error", err)
+			continue
+		}
+		if ok {
+			return false
+		}
+	}
+
+	log.Infow("releasing sector pin, no pinned worker can run the task", "sector", task.sector.ID, "task", task.taskType)
+	sh.unpinSector(task.sector.ID)
+
+	return true
+}
+
+// pinSector records the worker a pinned task of a sector got assigned to.
+// Sectors are pinned to the groups of the worker, or when the worker doesn't
+// have any groups, to the worker itself if pinToWorker is set.
+func (sh *scheduler) pinSector(task *workerRequest, wid WorkerID, w *workerHandle) {
+	if _, ok := pinnedTasks[task.taskType]; !ok {
+		return
+	}
+
+	sh.pinLk.Lock()
+	defer sh.pinLk.Unlock()
+
+	if task.taskType == sealtasks.TTFinalize {
+		// nothing needs the sector cache on the sealing worker after finalize
+		delete(sh.pins, task.sector.ID)
+		return
+	}
+
+	if pin, ok := sh.pins[task.sector.ID]; ok && pin.matches(wid, w) {
+		return
+	}
+
+	if len(w.info.Groups) == 0 && !sh.pinToWorker {
+		delete(sh.pins, task.sector.ID)
+		return
+	}
+
+	sh.pins[task.sector.ID] = sectorPin{
+		worker: wid,
+		groups: w.info.Groups,
+	}
+}
+
+func (sh *scheduler) unpinSector(sector abi.SectorID) {
+	sh.pinLk.Lock()
+	defer sh.pinLk.Unlock()
+
+	delete(sh.pins, sector)
+}
+
+// pinnedSectors returns the number of sectors pinned to each worker
+func (sh *scheduler) pinnedSectors() map[WorkerID]int {
+	sh.pinLk.Lock()
+	defer sh.pinLk.Unlock()
+
+	out := map[WorkerID]int{}
+	for _, pin := range sh.pins {
+		out[pin.worker]++
+	}
+
+	return out
+}
@@ -3,22 +3,23 @@ package sectorstorage
 import (
 	"sync"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
-func (a *activeResources) withResources(id WorkerID, wr storiface.WorkerResources, r Resources, locker sync.Locker, cb func() error) error {
-	for !a.canHandleRequest(r, id, "withResources", wr) {
+func (a *activeResources) withResources(id WorkerID, wr storiface.WorkerResources, tt sealtasks.TaskType, r Resources, locker sync.Locker, cb func() error) error {
+	for !a.canHandleRequest(tt, r, id, "withResources", wr) {
 		if a.cond == nil {
 			a.cond = sync.NewCond(locker)
 		}
 		a.cond.Wait()
 	}
 
-	a.add(wr, r)
+	a.add(wr, tt, r)
 
 	err := cb()
 
-	a.free(wr, r)
+	a.free(wr, tt, r)
 	if a.cond != nil {
 		a.cond.Broadcast()
 	}
@@ -26,7 +27,11 @@ func (a *activeResources) withResources(id WorkerID, wr storiface.WorkerResource
 	return err
 }
 
-func (a *activeResources) add(wr storiface.WorkerResources, r Resources) {
+func (a *activeResources) add(wr storiface.WorkerResources, tt sealtasks.TaskType, r Resources) {
+	if a.taskCounts == nil {
+		a.taskCounts = map[sealtasks.TaskType]int{}
+	}
+	a.taskCounts[tt]++
 	if r.CanGPU {
 		a.gpuUsed = true
 	}
@@ -35,7 +40,11 @@ func (a *activeResources) add(wr storiface.WorkerResources, r Resources) {
 	a.memUsedMax += r.MaxMemory
 }
 
-func (a *activeResources) free(wr storiface.WorkerResources, r Resources) {
+func (a *activeResources) free(wr storiface.WorkerResources, tt sealtasks.TaskType, r Resources) {
+	a.taskCounts[tt]--
+	if a.taskCounts[tt] <= 0 {
+		delete(a.taskCounts, tt)
+	}
 	if r.CanGPU {
 		a.gpuUsed = false
 	}
@@ -44,7 +53,11 @@ func (a *activeResources) free(wr storiface.WorkerResources, r Resources) {
 	a.memUsedMax -= r.MaxMemory
 }
 
-func (a *activeResources) canHandleRequest(needRes Resources, wid WorkerID, caller string, res storiface.WorkerResources) bool {
+func (a *activeResources) canHandleRequest(tt sealtasks.TaskType, needRes Resources, wid WorkerID, caller string, res storiface.WorkerResources) bool {
+	if limit := res.TaskLimits[tt]; limit > 0 && a.taskCounts[tt] >= limit {
+		log.Debugf("sched: not scheduling on worker %s for %s; %s task limit reached - %d running, limit %d", wid, caller, tt.Short(), a.taskCounts[tt], limit)
+		return false
+	}
 
 	// TODO: dedupe needRes.BaseMinMemory per task type (don't add if that task is already running)
 	minNeedMem := res.MemReserved + a.memUsedMin + needRes.MinMemory + needRes.BaseMinMemory
@@ -94,6 +107,21 @@ func (a *activeResources) utilization(wr storiface.WorkerResources) float64 {
 	return max
 }
 
+// idle returns whether the worker has no running, preparing or assigned tasks
+func (wh *workerHandle) idle() bool {
+	wh.lk.Lock()
+	running := len(wh.active.taskCounts) + len(wh.preparing.taskCounts)
+	wh.lk.Unlock()
+
+	wh.wndLk.Lock()
+	for _, window := range wh.activeWindows {
+		running += len(window.todo)
+	}
+	wh.wndLk.Unlock()
+
+	return running == 0
+}
+
 func (wh *workerHandle) utilization() float64 {
 	wh.lk.Lock()
 	u := wh.active.utilization(wh.info.Resources)
@@ -16,6 +16,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
@@ -38,6 +39,22 @@ func TestWithPriority(t *testing.T) {
 	require.Equal(t, 2222, getPriority(ctx))
 }
 
+func TestTaskPriorities(t *testing.T) {
+	sh := newScheduler()
+	ctx := context.Background()
+
+	sh.SetTaskPriority(sealtasks.TTPreCommit2, 10)
+	require.Equal(t, 10, sh.requestPriority(ctx, sealtasks.TTPreCommit2))
+	require.Equal(t, DefaultSchedPriority, sh.requestPriority(ctx, sealtasks.TTPreCommit1))
+
+	// explicit priorities aren't overridden
+	require.Equal(t, 2222, sh.requestPriority(WithPriority(ctx, 2222), sealtasks.TTPreCommit2))
+
+	sh.SetTaskPriority(sealtasks.TTPreCommit2, DefaultSchedPriority)
+	require.Empty(t, sh.TaskPriorities())
+	require.Equal(t, DefaultSchedPriority, sh.requestPriority(ctx, sealtasks.TTPreCommit2))
+}
+
 type schedTestWorker struct {
 	name      string
 	taskTypes map[sealtasks.TaskType]struct{}
@@ -99,6 +116,18 @@ func (s *schedTestWorker) ReadPiece(ctx context.Context, writer io.Writer, id st
 	panic("implement me")
 }
 
+func (s *schedTestWorker) RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) (storiface.CallID, error) {
+	panic("implement me")
+}
+
+func (s *schedTestWorker) GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	panic("implement me")
+}
+
+func (s *schedTestWorker) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	panic("implement me")
+}
+
 func (s *schedTestWorker) TaskTypes(ctx context.Context) (map[sealtasks.TaskType]struct{}, error) {
 	return s.taskTypes, nil
 }
@@ -588,7 +617,7 @@ func TestWindowCompact(t *testing.T) {
 						taskType: task,
 						sector:   storage.SectorRef{ProofType: spt},
 					})
-					window.allocated.add(wh.info.Resources, ResourceTable[task][spt])
+					window.allocated.add(wh.info.Resources, task, ResourceTable[task][spt])
 				}
 
 				wh.activeWindows = append(wh.activeWindows, window)
@@ -607,7 +636,7 @@ func TestWindowCompact(t *testing.T) {
 
 				for ti, task := range tasks {
 					require.Equal(t, task, wh.activeWindows[wi].todo[ti].taskType, "%d, %d", wi, ti)
-					expectRes.add(wh.info.Resources, ResourceTable[task][spt])
+					expectRes.add(wh.info.Resources, task, ResourceTable[task][spt])
 				}
 
 				require.Equal(t, expectRes.cpuUse, wh.activeWindows[wi].allocated.cpuUse, "%d", wi)
@@ -644,3 +673,189 @@ func TestWindowCompact(t *testing.T) {
 		[][]sealtasks.TaskType{{sealtasks.TTPreCommit1, sealtasks.TTPreCommit1, sealtasks.TTAddPiece}, {sealtasks.TTPreCommit1, sealtasks.TTPreCommit2}}),
 	)
 }
+
+func TestTaskLimits(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1
+	wr := decentWorkerResources
+	wr.TaskLimits = map[sealtasks.TaskType]int{
+		sealtasks.TTPreCommit1: 2,
+	}
+
+	var a activeResources
+	pc1 := ResourceTable[sealtasks.TTPreCommit1][spt]
+	pc2 := ResourceTable[sealtasks.TTPreCommit2][spt]
+
+	for i := 0; i < 2; i++ {
+		require.True(t, a.canHandleRequest(sealtasks.TTPreCommit1, pc1, WorkerID{}, "test", wr))
+		a.add(wr, sealtasks.TTPreCommit1, pc1)
+	}
+
+	require.False(t, a.canHandleRequest(sealtasks.TTPreCommit1, pc1, WorkerID{}, "test", wr))
+	require.True(t, a.canHandleRequest(sealtasks.TTPreCommit2, pc2, WorkerID{}, "test", wr))
+
+	a.free(wr, sealtasks.TTPreCommit1, pc1)
+	require.True(t, a.canHandleRequest(sealtasks.TTPreCommit1, pc1, WorkerID{}, "test", wr))
+}
+
+func TestSectorPins(t *testing.T) {
+	sh := newScheduler()
+
+	mkWorker := func(groups ...string) (WorkerID, *workerHandle) {
+		wid := WorkerID(uuid.New())
+		wh := &workerHandle{
+			info:    storiface.WorkerInfo{Groups: groups},
+			enabled: true,
+		}
+		sh.workers[wid] = wh
+		return wid, wh
+	}
+
+	a1, wa1 := mkWorker("a")
+	a2, wa2 := mkWorker("a", "b")
+	b1, wb1 := mkWorker("b")
+
+	// workers which can run the tasks
+	capable := workerSetSelector{wa1: {}, wa2: {}, wb1: {}}
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 1}}
+	req := func(tt sealtasks.TaskType) *workerRequest {
+		return &workerRequest{sector: sector, taskType: tt, sel: capable, ctx: context.Background()}
+	}
+
+	require.True(t, sh.pinOk(req(sealtasks.TTPreCommit1), b1, wb1, newSelOkCache()))
+	sh.pinSector(req(sealtasks.TTPreCommit1), a1, wa1)
+
+	require.True(t, sh.pinOk(req(sealtasks.TTPreCommit2), a1, wa1, newSelOkCache()))
+	require.True(t, sh.pinOk(req(sealtasks.TTPreCommit2), a2, wa2, newSelOkCache()))
+	require.False(t, sh.pinOk(req(sealtasks.TTPreCommit2), b1, wb1, newSelOkCache()))
+
+	// commit2 only needs commit1 output
+	require.True(t, sh.pinOk(req(sealtasks.TTCommit2), b1, wb1, newSelOkCache()))
+	require.Equal(t, 1, sh.pinnedSectors()[a1])
+
+	// fall back to other workers when the group is gone
+	wa1.enabled = false
+	wa2.enabled = false
+	require.True(t, sh.pinOk(req(sealtasks.TTPreCommit2), b1, wb1, newSelOkCache()))
+
+	sh.pinSector(req(sealtasks.TTFinalize), b1, wb1)
+	require.Empty(t, sh.pinnedSectors())
+
+	// release the pin when none of the pinned workers can run the task
+	wa1.enabled = true
+	wa2.enabled = true
+	sh.pinSector(req(sealtasks.TTPreCommit1), a1, wa1)
+	require.False(t, sh.pinOk(req(sealtasks.TTPreCommit2), b1, wb1, newSelOkCache()))
+
+	delete(capable, wa1)
+	delete(capable, wa2)
+	require.True(t, sh.pinOk(req(sealtasks.TTPreCommit2), b1, wb1, newSelOkCache()))
+	require.Empty(t, sh.pinnedSectors())
+}
+
+type workerSetSelector map[*workerHandle]struct{}
+
+func (s workerSetSelector) Ok(ctx context.Context, task sealtasks.TaskType, spt abi.RegisteredSealProof, a *workerHandle) (bool, error) {
+	_, ok := s[a]
+	return ok, nil
+}
+
+func (s workerSetSelector) Cmp(ctx context.Context, task sealtasks.TaskType, a, b *workerHandle) (bool, error) {
+	return true, nil
+}
+
+type countingSelector struct {
+	workerSetSelector
+	calls int
+}
+
+func (s *countingSelector) Ok(ctx context.Context, task sealtasks.TaskType, spt abi.RegisteredSealProof, a *workerHandle) (bool, error) {
+	s.calls++
+	return s.workerSetSelector.Ok(ctx, task, spt, a)
+}
+
+func TestSelOkCache(t *testing.T) {
+	sh := newScheduler()
+
+	a1 := WorkerID(uuid.New())
+	wa1 := &workerHandle{info: storiface.WorkerInfo{Groups: []string{"a"}}, enabled: true}
+	sh.workers[a1] = wa1
+	b1 := WorkerID(uuid.New())
+	wb1 := &workerHandle{info: storiface.WorkerInfo{Groups: []string{"b"}}, enabled: true}
+	sh.workers[b1] = wb1
+
+	sel := &countingSelector{workerSetSelector: workerSetSelector{wa1: {}, wb1: {}}}
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 1}}
+	task := &workerRequest{sector: sector, taskType: sealtasks.TTPreCommit2, sel: sel, ctx: context.Background()}
+	sh.pinSector(&workerRequest{sector: sector, taskType: sealtasks.TTPreCommit1}, a1, wa1)
+
+	// the pinned worker is only asked once per scheduling pass
+	oks := newSelOkCache()
+	for i := 0; i < 3; i++ {
+		require.False(t, sh.pinOk(task, b1, wb1, oks))
+		ok, err := oks.ok(task, a1, wa1)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+	require.Equal(t, 1, sel.calls)
+
+	// and again in the next pass
+	require.False(t, sh.pinOk(task, b1, wb1, newSelOkCache()))
+	require.Equal(t, 2, sel.calls)
+}
+
+func TestWorkerDrain(t *testing.T) {
+	sh := newScheduler()
+
+	wid := WorkerID(uuid.New())
+	wh := &workerHandle{
+		info:      storiface.WorkerInfo{Resources: decentWorkerResources},
+		preparing: &activeResources{},
+		active:    &activeResources{},
+		enabled:   true,
+	}
+	sh.workers[wid] = wh
+
+	require.Error(t, sh.SetDraining(WorkerID(uuid.New()), true))
+	require.NoError(t, sh.SetDraining(wid, true))
+	require.True(t, wh.draining)
+
+	res := ResourceTable[sealtasks.TTPreCommit1][abi.RegisteredSealProof_StackedDrg2KiBV1]
+	wh.active.add(wh.info.Resources, sealtasks.TTPreCommit1, res)
+	require.False(t, wh.idle())
+
+	wh.active.free(wh.info.Resources, sealtasks.TTPreCommit1, res)
+	require.True(t, wh.idle())
+
+	require.NoError(t, sh.SetDraining(wid, false))
+	require.False(t, wh.draining)
+}
+
+func TestIODeferral(t *testing.T) {
+	sh := newScheduler()
+
+	sh.taskPaths = func(req *workerRequest) []stores.ID {
+		return []stores.ID{stores.ID(fmt.Sprint(req.sector.ID.Number))}
+	}
+
+	req := func(tt sealtasks.TaskType, sector abi.SectorNumber) *workerRequest {
+		return &workerRequest{
+			sector:   storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: sector}},
+			taskType: tt,
+		}
+	}
+
+	require.False(t, sh.ioDeferred(req(sealtasks.TTFinalize, 1)))
+
+	sh.deferIO([]stores.ID{"1"})
+
+	require.True(t, sh.ioDeferred(req(sealtasks.TTFinalize, 1)))
+	require.True(t, sh.ioDeferred(req(sealtasks.TTFetch, 1)))
+	require.False(t, sh.ioDeferred(req(sealtasks.TTFinalize, 2)))
+	// sealing tasks are never deferred
+	require.False(t, sh.ioDeferred(req(sealtasks.TTPreCommit1, 1)))
+
+	sh.deferIO(nil)
+
+	require.False(t, sh.ioDeferred(req(sealtasks.TTFinalize, 1)))
+}
@@ -29,6 +29,11 @@ func (sh *scheduler) runWorker(ctx context.Context, w Worker) error {
 		return xerrors.Errorf("getting worker info: %w", err)
 	}
 
+	tasks, err := w.TaskTypes(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting worker task types: %w", err)
+	}
+
 	sessID, err := w.Session(ctx)
 	if err != nil {
 		return xerrors.Errorf("getting worker session: %w", err)
@@ -40,6 +45,7 @@ func (sh *scheduler) runWorker(ctx context.Context, w Worker) error {
 	worker := &workerHandle{
 		workerRpc: w,
 		info:      info,
+		tasks:     tasks,
 
 		preparing: &activeResources{},
 		active:    &activeResources{},
@@ -296,14 +302,14 @@ func (sw *schedWorker) workerCompactWindows() {
 
 			for ti, todo := range window.todo {
 				needRes := ResourceTable[todo.taskType][todo.sector.ProofType]
-				if !lower.allocated.canHandleRequest(needRes, sw.wid, "compactWindows", worker.info.Resources) {
+				if !lower.allocated.canHandleRequest(todo.taskType, needRes, sw.wid, "compactWindows", worker.info.Resources) {
 					continue
 				}
 
 				moved = append(moved, ti)
 				lower.todo = append(lower.todo, todo)
-				lower.allocated.add(worker.info.Resources, needRes)
-				window.allocated.free(worker.info.Resources, needRes)
+				lower.allocated.add(worker.info.Resources, todo.taskType, needRes)
+				window.allocated.free(worker.info.Resources, todo.taskType, needRes)
 			}
 
 			if len(moved) > 0 {
@@ -352,7 +358,7 @@ assignLoop:
 			worker.lk.Lock()
 			for t, todo := range firstWindow.todo {
 				needRes := ResourceTable[todo.taskType][todo.sector.ProofType]
-				if worker.preparing.canHandleRequest(needRes, sw.wid, "startPreparing", worker.info.Resources) {
+				if worker.preparing.canHandleRequest(todo.taskType, needRes, sw.wid, "startPreparing", worker.info.Resources) {
 					tidx = t
 					break
 				}
@@ -393,17 +399,17 @@ func (sw *schedWorker) startProcessingTask(taskDone chan struct{}, req *workerRe
 	needRes := ResourceTable[req.taskType][req.sector.ProofType]
 
 	w.lk.Lock()
-	w.preparing.add(w.info.Resources, needRes)
+	w.preparing.add(w.info.Resources, req.taskType, needRes)
 	w.lk.Unlock()
 
 	go func() {
 		// first run the prepare step (e.g. fetching sector data from other worker)
-		err := req.prepare(req.ctx, sh.workTracker.worker(sw.wid, w.workerRpc))
+		err := req.prepare(req.ctx, sh.workTracker.worker(sw.wid, w.info.Hostname, w.workerRpc))
 		sh.workersLk.Lock()
 
 		if err != nil {
 			w.lk.Lock()
-			w.preparing.free(w.info.Resources, needRes)
+			w.preparing.free(w.info.Resources, req.taskType, needRes)
 			w.lk.Unlock()
 			sh.workersLk.Unlock()
 
@@ -424,9 +430,9 @@ func (sw *schedWorker) startProcessingTask(taskDone chan struct{}, req *workerRe
 		}
 
 		// wait (if needed) for resources in the 'active' window
-		err = w.active.withResources(sw.wid, w.info.Resources, needRes, &sh.workersLk, func() error {
+		err = w.active.withResources(sw.wid, w.info.Resources, req.taskType, needRes, &sh.workersLk, func() error {
 			w.lk.Lock()
-			w.preparing.free(w.info.Resources, needRes)
+			w.preparing.free(w.info.Resources, req.taskType, needRes)
 			w.lk.Unlock()
 			sh.workersLk.Unlock()
 			defer sh.workersLk.Lock() // we MUST return locked from this function
@@ -437,7 +443,7 @@ func (sw *schedWorker) startProcessingTask(taskDone chan struct{}, req *workerRe
 			}
 
 			// Do the work!
-			err = req.work(req.ctx, sh.workTracker.worker(sw.wid, w.workerRpc))
+			err = req.work(req.ctx, sh.workTracker.worker(sw.wid, w.info.Hostname, w.workerRpc))
 
 			select {
 			case req.ret <- workerResponse{err: err}:
@@ -14,10 +14,16 @@ const (
 	TTFetch        TaskType = "seal/v0/fetch"
 	TTUnseal       TaskType = "seal/v0/unseal"
 	TTReadUnsealed TaskType = "seal/v0/unsealread"
+
+	TTRegenCache TaskType = "seal/v0/regencache"
+
+	TTGenerateWindowPoSt  TaskType = "post/v0/windowproof"
+	TTGenerateWinningPoSt TaskType = "post/v0/winningproof"
 )
 
 var order = map[TaskType]int{
-	TTAddPiece:     6, // least priority
+	TTRegenCache:   7, // least priority
+	TTAddPiece:     6,
 	TTPreCommit1:   5,
 	TTPreCommit2:   4,
 	TTCommit2:      3,
@@ -25,7 +31,10 @@ var order = map[TaskType]int{
 	TTUnseal:       1,
 	TTFetch:        -1,
 	TTReadUnsealed: -1,
-	TTFinalize:     -2, // most priority
+	TTFinalize:     -2,
+
+	TTGenerateWindowPoSt:  -3,
+	TTGenerateWinningPoSt: -4, // most priority
 }
 
 var shortNames = map[TaskType]string{
@@ -41,6 +50,11 @@ var shortNames = map[TaskType]string{
 	TTFetch:        "GET",
 	TTUnseal:       "UNS",
 	TTReadUnsealed: "RD",
+
+	TTRegenCache: "RGC",
+
+	TTGenerateWindowPoSt:  "WDP",
+	TTGenerateWinningPoSt: "WNP",
 }
 
 func (a TaskType) MuchLess(b TaskType) (bool, bool) {
@@ -61,3 +75,14 @@ func (a TaskType) Short() string {
 
 	return n
 }
+
+// FromShort returns the task type with the given short name (e.g. PC1)
+func FromShort(s string) (TaskType, bool) {
+	for tt, n := range shortNames {
+		if n == s {
+			return tt, true
+		}
+	}
+
+	return "", false
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
@@ -13,8 +14,14 @@ func (m *Manager) WorkerStats() map[uuid.UUID]storiface.WorkerStats {
 	defer m.sched.workersLk.RUnlock()
 
 	out := map[uuid.UUID]storiface.WorkerStats{}
+	pinned := m.sched.pinnedSectors()
 
 	for id, handle := range m.sched.workers {
+		taskCounts := map[sealtasks.TaskType]int{}
+		for tt, n := range handle.active.taskCounts {
+			taskCounts[tt] = n
+		}
+
 		out[uuid.UUID(id)] = storiface.WorkerStats{
 			Info:    handle.info,
 			Enabled: handle.enabled,
@@ -23,12 +30,24 @@ func (m *Manager) WorkerStats() map[uuid.UUID]storiface.WorkerStats {
 			MemUsedMax: handle.active.memUsedMax,
 			GpuUsed:    handle.active.gpuUsed,
 			CpuUse:     handle.active.cpuUse,
+			TaskCounts: taskCounts,
+
+			PinnedSectors: pinned[id],
+
+			Draining: handle.draining,
+			Drained:  handle.draining && handle.idle(),
 		}
 	}
 
 	return out
 }
 
+// AddTaskDoneNotifee registers a function called with every task which
+// finished on a worker. Notifees are called synchronously, and must not block.
+func (m *Manager) AddTaskDoneNotifee(n TaskDoneNotifee) {
+	m.sched.workTracker.addNotifee(n)
+}
+
 func (m *Manager) WorkerJobs() map[uuid.UUID][]storiface.WorkerJob {
 	out := map[uuid.UUID][]storiface.WorkerJob{}
 	calls := map[storiface.CallID]struct{}{}
@@ -0,0 +1,155 @@
+package stores
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ProbeTimeout is the time after which a storage path health probe is
+// considered failed. File operations on dead network mounts tend to hang
+// rather than fail.
+var ProbeTimeout = 30 * time.Second
+
+// MaxProbeLatency is the probe read / write latency above which a storage path
+// is considered unhealthy
+var MaxProbeLatency = 5 * time.Second
+
+const probeFile = ".health-probe"
+
+// HealthState is the health of a storage path, as known to the index
+type HealthState struct {
+	Healthy bool
+	Err     string
+
+	LastHeartbeat time.Time
+	ReadLatency   time.Duration
+	WriteLatency  time.Duration
+}
+
+type probeResult struct {
+	read, write time.Duration
+	err         error
+}
+
+// probe checks that the path can be read, and written to if it's writable.
+// Only one probe runs at a time, so that probes hanging on a dead mount don't
+// pile up.
+func (p *path) probe() probeResult {
+	p.probeLk.Lock()
+	if p.probing {
+		p.probeLk.Unlock()
+		return probeResult{err: xerrors.Errorf("previous health probe still running")}
+	}
+	p.probing = true
+	p.probeLk.Unlock()
+
+	done := make(chan probeResult, 1)
+	go func() {
+		res := probePath(p.local, p.canWrite)
+
+		p.probeLk.Lock()
+		p.probing = false
+		p.probeLk.Unlock()
+
+		done <- res
+	}()
+
+	select {
+	case res := <-done:
+		if res.err == nil && (res.read > MaxProbeLatency || res.write > MaxProbeLatency) {
+			res.err = xerrors.Errorf("health probe too slow (read: %s, write: %s, max: %s)", res.read, res.write, MaxProbeLatency)
+		}
+		return res
+	case <-time.After(ProbeTimeout):
+		return probeResult{err: xerrors.Errorf("health probe timed out after %s", ProbeTimeout)}
+	}
+}
+
+func probePath(dir string, write bool) (res probeResult) {
+	if write {
+		data := make([]byte, 4<<10)
+		if _, err := rand.Read(data); err != nil {
+			return probeResult{err: xerrors.Errorf("generating probe data: %w", err)}
+		}
+
+		pf := filepath.Join(dir, probeFile)
+
+		start := time.Now()
+		if err := writeSync(pf, data); err != nil {
+			return probeResult{err: xerrors.Errorf("write probe: %w", err)}
+		}
+		res.write = time.Since(start)
+
+		got, err := ioutil.ReadFile(pf)
+		if err != nil {
+			return probeResult{err: xerrors.Errorf("reading back write probe: %w", err)}
+		}
+		if !bytes.Equal(got, data) {
+			return probeResult{err: xerrors.Errorf("write probe data mismatch")}
+		}
+
+		if err := os.Remove(pf); err != nil {
+			return probeResult{err: xerrors.Errorf("removing write probe: %w", err)}
+		}
+	}
+
+	start := time.Now()
+	if _, err := ioutil.ReadFile(filepath.Join(dir, MetaFile)); err != nil {
+		return probeResult{err: xerrors.Errorf("read probe: %w", err)}
+	}
+	res.read = time.Since(start)
+
+	return res
+}
+
+func writeSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// HealthNotifee is called by the index with each storage health report.
+// changed is set when the report changes whether the path is healthy.
+type HealthNotifee func(id ID, report HealthReport, changed bool)
+
+type healthNotifees struct {
+	lk       sync.Mutex
+	notifees []HealthNotifee
+}
+
+func (h *healthNotifees) add(n HealthNotifee) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	h.notifees = append(h.notifees, n)
+}
+
+func (h *healthNotifees) notify(id ID, report HealthReport, changed bool) {
+	h.lk.Lock()
+	notifees := h.notifees
+	h.lk.Unlock()
+
+	for _, n := range notifees {
+		n(id, report, changed)
+	}
+}
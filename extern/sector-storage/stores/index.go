@@ -3,6 +3,7 @@ package stores
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/url"
 	gopath "path"
 	"sort"
@@ -32,11 +33,17 @@ type StorageInfo struct {
 
 	CanSeal  bool
 	CanStore bool
+
+	FailureDomain string
 }
 
 type HealthReport struct {
 	Stat fsutil.FsStat
 	Err  string
+
+	// latencies of the health probe, see ProbeTimeout
+	ReadLatency  time.Duration
+	WriteLatency time.Duration
 }
 
 type SectorStorageInfo struct {
@@ -47,6 +54,8 @@ type SectorStorageInfo struct {
 	CanSeal  bool
 	CanStore bool
 
+	FailureDomain string
+
 	Primary bool
 }
 
@@ -82,12 +91,17 @@ type storageEntry struct {
 
 	lastHeartbeat time.Time
 	heartbeatErr  error
+
+	readLatency  time.Duration
+	writeLatency time.Duration
 }
 
 type Index struct {
 	*indexLocks
 	lk sync.RWMutex
 
+	healthNotifees healthNotifees
+
 	sectors map[Decl][]*declMeta
 	stores  map[ID]*storageEntry
 }
@@ -158,6 +172,7 @@ func (i *Index) StorageAttach(ctx context.Context, si StorageInfo, st fsutil.FsS
 		i.stores[si.ID].info.Weight = si.Weight
 		i.stores[si.ID].info.CanSeal = si.CanSeal
 		i.stores[si.ID].info.CanStore = si.CanStore
+		i.stores[si.ID].info.FailureDomain = si.FailureDomain
 
 		return nil
 	}
@@ -172,22 +187,78 @@ func (i *Index) StorageAttach(ctx context.Context, si StorageInfo, st fsutil.FsS
 
 func (i *Index) StorageReportHealth(ctx context.Context, id ID, report HealthReport) error {
 	i.lk.Lock()
-	defer i.lk.Unlock()
 
 	ent, ok := i.stores[id]
 	if !ok {
+		i.lk.Unlock()
 		return xerrors.Errorf("health report for unknown storage: %s", id)
 	}
 
+	wasHealthy := ent.heartbeatErr == nil
+
 	ent.fsi = report.Stat
 	if report.Err != "" {
 		ent.heartbeatErr = errors.New(report.Err)
+	} else {
+		ent.heartbeatErr = nil
 	}
 	ent.lastHeartbeat = time.Now()
+	ent.readLatency = report.ReadLatency
+	ent.writeLatency = report.WriteLatency
+
+	changed := wasHealthy != (ent.heartbeatErr == nil)
+
+	i.lk.Unlock()
+
+	if changed {
+		if report.Err != "" {
+			log.Warnw("storage path unhealthy, not using it for new allocations", "storage", id, "error", report.Err)
+		} else {
+			log.Infow("storage path healthy again", "storage", id)
+		}
+	}
+
+	i.healthNotifees.notify(id, report, changed)
 
 	return nil
 }
 
+// StorageHealth returns the health of the storage path, based on the last
+// health report
+func (i *Index) StorageHealth(ctx context.Context, id ID) (HealthState, error) {
+	i.lk.RLock()
+	defer i.lk.RUnlock()
+
+	ent, ok := i.stores[id]
+	if !ok {
+		return HealthState{}, xerrors.Errorf("sector store not found")
+	}
+
+	out := HealthState{
+		Healthy:       true,
+		LastHeartbeat: ent.lastHeartbeat,
+		ReadLatency:   ent.readLatency,
+		WriteLatency:  ent.writeLatency,
+	}
+
+	switch {
+	case ent.heartbeatErr != nil:
+		out.Healthy = false
+		out.Err = ent.heartbeatErr.Error()
+	case time.Since(ent.lastHeartbeat) > SkippedHeartbeatThresh:
+		out.Healthy = false
+		out.Err = fmt.Sprintf("no heartbeats for %s", time.Since(ent.lastHeartbeat).Truncate(time.Second))
+	}
+
+	return out, nil
+}
+
+// AddHealthNotifee registers a function called with every storage health
+// report. Notifees are called synchronously, and must not block.
+func (i *Index) AddHealthNotifee(n HealthNotifee) {
+	i.healthNotifees.add(n)
+}
+
 func (i *Index) StorageDeclareSector(ctx context.Context, storageID ID, s abi.SectorID, ft storiface.SectorFileType, primary bool) error {
 	i.lk.Lock()
 	defer i.lk.Unlock()
@@ -300,6 +371,8 @@ func (i *Index) StorageFindSector(ctx context.Context, s abi.SectorID, ft storif
 			CanSeal:  st.info.CanSeal,
 			CanStore: st.info.CanStore,
 
+			FailureDomain: st.info.FailureDomain,
+
 			Primary: isprimary[id],
 		})
 	}
@@ -353,6 +426,8 @@ func (i *Index) StorageFindSector(ctx context.Context, s abi.SectorID, ft storif
 				CanSeal:  st.info.CanSeal,
 				CanStore: st.info.CanStore,
 
+				FailureDomain: st.info.FailureDomain,
+
 				Primary: false,
 			})
 		}
@@ -42,6 +42,10 @@ type LocalStorageMeta struct {
 
 	// Finalized sectors that will be proved over time will be stored here
 	CanStore bool
+
+	// Paths sharing a failure domain (e.g. the same disk or host) can be lost
+	// together, sector replicas are placed in distinct failure domains
+	FailureDomain string
 }
 
 // StorageConfig .lotusstorage/storage.json
@@ -77,10 +81,14 @@ type Local struct {
 }
 
 type path struct {
-	local string // absolute local path
+	local    string // absolute local path
+	canWrite bool
 
 	reserved     int64
 	reservations map[abi.SectorID]storiface.SectorFileType
+
+	probeLk sync.Mutex
+	probing bool
 }
 
 func (p *path) stat(ls LocalStorage) (fsutil.FsStat, error) {
@@ -162,7 +170,8 @@ func (st *Local) OpenPath(ctx context.Context, p string) error {
 	// TODO: Check existing / dedupe
 
 	out := &path{
-		local: p,
+		local:    p,
+		canWrite: meta.CanSeal || meta.CanStore,
 
 		reserved:     0,
 		reservations: map[abi.SectorID]storiface.SectorFileType{},
@@ -179,6 +188,8 @@ func (st *Local) OpenPath(ctx context.Context, p string) error {
 		Weight:   meta.Weight,
 		CanSeal:  meta.CanSeal,
 		CanStore: meta.CanStore,
+
+		FailureDomain: meta.FailureDomain,
 	}, fst)
 	if err != nil {
 		return xerrors.Errorf("declaring storage in index: %w", err)
@@ -242,6 +253,8 @@ func (st *Local) Redeclare(ctx context.Context) error {
 			Weight:   meta.Weight,
 			CanSeal:  meta.CanSeal,
 			CanStore: meta.CanStore,
+
+			FailureDomain: meta.FailureDomain,
 		}, fst)
 		if err != nil {
 			return xerrors.Errorf("redeclaring storage in index: %w", err)
@@ -304,14 +317,56 @@ func (st *Local) reportHealth(ctx context.Context) {
 }
 
 func (st *Local) reportStorage(ctx context.Context) {
+	st.localLk.RLock()
+	paths := make(map[ID]*path, len(st.paths))
+	for id, p := range st.paths {
+		paths[id] = p
+	}
+	st.localLk.RUnlock()
+
+	// probe without holding localLk, probes can take a while on slow or dead
+	// mounts
+	var probeLk sync.Mutex
+	var wg sync.WaitGroup
+	probes := make(map[ID]probeResult, len(paths))
+	for id, p := range paths {
+		wg.Add(1)
+		go func(id ID, p *path) {
+			defer wg.Done()
+
+			res := p.probe()
+
+			probeLk.Lock()
+			probes[id] = res
+			probeLk.Unlock()
+		}(id, p)
+	}
+	wg.Wait()
+
 	st.localLk.RLock()
 
 	toReport := map[ID]HealthReport{}
-	for id, p := range st.paths {
+	for id, p := range paths {
+		probe := probes[id]
+		r := HealthReport{
+			ReadLatency:  probe.read,
+			WriteLatency: probe.write,
+		}
+
+		if probe.err != nil {
+			// don't stat, it would likely hang too
+			r.Err = probe.err.Error()
+			toReport[id] = r
+			continue
+		}
+
 		stat, err := p.stat(st.localStorage)
-		r := HealthReport{Stat: stat}
-		if err != nil {
+		r.Stat = stat
+		switch {
+		case err != nil:
 			r.Err = err.Error()
+		case stat.Capacity <= 0:
+			r.Err = "filesystem reports no capacity"
 		}
 
 		toReport[id] = r
@@ -646,6 +701,18 @@ func (st *Local) MoveStorage(ctx context.Context, s storage.SectorRef, types sto
 	return nil
 }
 
+func (st *Local) localSectorPath(id ID, s storage.SectorRef, fileType storiface.SectorFileType) (string, bool) {
+	st.localLk.RLock()
+	defer st.localLk.RUnlock()
+
+	p, ok := st.paths[id]
+	if !ok || p.local == "" {
+		return "", false
+	}
+
+	return p.sectorPath(s.ID, fileType), true
+}
+
 var errPathNotFound = xerrors.Errorf("fsstat: path not found")
 
 func (st *Local) FsStat(ctx context.Context, id ID) (fsutil.FsStat, error) {
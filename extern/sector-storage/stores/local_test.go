@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -93,3 +94,70 @@ func TestLocalStorage(t *testing.T) {
 
 	// TODO: put more things here
 }
+
+func TestStorageHealth(t *testing.T) {
+	ctx := context.TODO()
+
+	root, err := ioutil.TempDir("", "sector-storage-teststorage-")
+	require.NoError(t, err)
+	defer os.RemoveAll(root) // nolint
+
+	tstor := &TestingLocalStorage{
+		root: root,
+	}
+
+	index := NewIndex()
+
+	var changes []bool
+	index.AddHealthNotifee(func(id ID, report HealthReport, changed bool) {
+		if changed {
+			changes = append(changes, report.Err == "")
+		}
+	})
+
+	st, err := NewLocal(ctx, tstor, index, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, tstor.init("1"))
+	p := filepath.Join(tstor.root, "1")
+	require.NoError(t, st.OpenPath(ctx, p))
+
+	var id ID
+	for sid := range st.paths {
+		id = sid
+	}
+
+	st.reportStorage(ctx)
+
+	h, err := index.StorageHealth(ctx, id)
+	require.NoError(t, err)
+	require.True(t, h.Healthy)
+
+	// make the read probe fail
+	meta, err := ioutil.ReadFile(filepath.Join(p, MetaFile))
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(p, MetaFile)))
+
+	st.reportStorage(ctx)
+
+	h, err = index.StorageHealth(ctx, id)
+	require.NoError(t, err)
+	require.False(t, h.Healthy)
+
+	_, err = index.StorageBestAlloc(ctx, storiface.FTSealed, 2048, storiface.PathStorage)
+	require.Error(t, err)
+
+	// recover
+	require.NoError(t, ioutil.WriteFile(filepath.Join(p, MetaFile), meta, 0644))
+
+	st.reportStorage(ctx)
+
+	h, err = index.StorageHealth(ctx, id)
+	require.NoError(t, err)
+	require.True(t, h.Healthy)
+
+	_, err = index.StorageBestAlloc(ctx, storiface.FTSealed, 2048, storiface.PathStorage)
+	require.NoError(t, err)
+
+	require.Equal(t, []bool{false, true}, changes)
+}
@@ -35,7 +35,7 @@ type Remote struct {
 	index SectorIndex
 	auth  http.Header
 
-	limit chan struct{}
+	transfers *transferQueue
 
 	fetchLk  sync.Mutex
 	fetching map[abi.SectorID]chan struct{}
@@ -49,13 +49,13 @@ func (r *Remote) RemoveCopies(ctx context.Context, s abi.SectorID, types storifa
 	return r.local.RemoveCopies(ctx, s, types)
 }
 
-func NewRemote(local *Local, index SectorIndex, auth http.Header, fetchLimit int) *Remote {
+func NewRemote(local *Local, index SectorIndex, auth http.Header, fetchLimit int, limits FetchLimits) *Remote {
 	return &Remote{
 		local: local,
 		index: index,
 		auth:  auth,
 
-		limit: make(chan struct{}, fetchLimit),
+		transfers: newTransferQueue(fetchLimit, limits),
 
 		fetching: map[abi.SectorID]chan struct{}{},
 	}
@@ -137,7 +137,7 @@ func (r *Remote) AcquireSector(ctx context.Context, s storage.SectorRef, existin
 		dest := storiface.PathByType(apaths, fileType)
 		storageID := storiface.PathByType(ids, fileType)
 
-		url, err := r.acquireFromRemote(ctx, s.ID, fileType, dest)
+		url, err := r.acquireFromRemote(ctx, s.ID, fileType, ID(storageID), dest)
 		if err != nil {
 			return storiface.SectorPaths{}, storiface.SectorPaths{}, err
 		}
@@ -172,7 +172,7 @@ func tempFetchDest(spath string, create bool) (string, error) {
 	return filepath.Join(tempdir, b), nil
 }
 
-func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType storiface.SectorFileType, dest string) (string, error) {
+func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType storiface.SectorFileType, destID ID, dest string) (string, error) {
 	si, err := r.index.StorageFindSector(ctx, s, fileType, 0, false)
 	if err != nil {
 		return "", err
@@ -200,7 +200,12 @@ func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType
 				return "", xerrors.Errorf("removing dest: %w", err)
 			}
 
-			err = r.fetch(ctx, url, tempDest)
+			err = r.fetch(ctx, TransferInfo{
+				Sector:   s,
+				FileType: fileType,
+				URL:      url,
+				Dest:     destID,
+			}, tempDest)
 			if err != nil {
 				merr = multierror.Append(merr, xerrors.Errorf("fetch error %s (storage %s) -> %s: %w", url, info.ID, tempDest, err))
 				continue
@@ -220,25 +225,19 @@ func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType
 	return "", xerrors.Errorf("failed to acquire sector %v from remote (tried %v): %w", s, si, merr)
 }
 
-func (r *Remote) fetch(ctx context.Context, url, outname string) error {
-	log.Infof("Fetch %s -> %s", url, outname)
-
-	if len(r.limit) >= cap(r.limit) {
-		log.Infof("Throttling fetch, %d already running", len(r.limit))
-	}
+func (r *Remote) fetch(ctx context.Context, ti TransferInfo, outname string) error {
+	log.Infof("Fetch %s -> %s", ti.URL, outname)
 
 	// TODO: Smarter throttling
 	//  * Priority (just going sequentially is still pretty good)
-	//  * Per interface
 	//  * Aware of remote load
-	select {
-	case r.limit <- struct{}{}:
-		defer func() { <-r.limit }()
-	case <-ctx.Done():
-		return xerrors.Errorf("context error while waiting for fetch limiter: %w", ctx.Err())
+	t, done, err := r.transfers.start(ctx, ti)
+	if err != nil {
+		return err
 	}
+	defer done()
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest("GET", ti.URL, nil)
 	if err != nil {
 		return xerrors.Errorf("request: %w", err)
 	}
@@ -274,15 +273,17 @@ func (r *Remote) fetch(ctx context.Context, url, outname string) error {
 		return xerrors.Errorf("removing dest: %w", err)
 	}
 
+	body := r.transfers.reader(ctx, t, resp.Body)
+
 	switch mediatype {
 	case "application/x-tar":
-		return tarutil.ExtractTar(resp.Body, outname)
+		return tarutil.ExtractTar(body, outname)
 	case "application/octet-stream":
 		f, err := os.Create(outname)
 		if err != nil {
 			return err
 		}
-		_, err = io.CopyBuffer(f, resp.Body, make([]byte, CopyBuf))
+		_, err = io.CopyBuffer(f, body, make([]byte, CopyBuf))
 		if err != nil {
 			f.Close() // nolint
 			return err
@@ -293,6 +294,11 @@ func (r *Remote) fetch(ctx context.Context, url, outname string) error {
 	}
 }
 
+// Transfers returns running and queued fetches, oldest first
+func (r *Remote) Transfers() []TransferInfo {
+	return r.transfers.list()
+}
+
 func (r *Remote) MoveStorage(ctx context.Context, s storage.SectorRef, types storiface.SectorFileType) error {
 	// Make sure we have the data local
 	_, _, err := r.AcquireSector(ctx, s, types, storiface.FTNone, storiface.PathStorage, storiface.AcquireMove)
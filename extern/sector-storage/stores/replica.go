@@ -0,0 +1,131 @@
+package stores
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// failureDomain returns the failure domain of a storage path. Paths without
+// a failure domain label are assumed to fail independently of other paths
+func failureDomain(id ID, label string) string {
+	if label == "" {
+		return "path:" + string(id)
+	}
+	return label
+}
+
+// ReplicateSector makes sure that each of the given sector file types is
+// kept in long-term storage in at least `copies` distinct failure domains,
+// fetching missing replicas into local storage paths in domains which don't
+// have a copy yet
+func (r *Remote) ReplicateSector(ctx context.Context, s storage.SectorRef, types storiface.SectorFileType, copies int) error {
+	ssize, err := s.ProofType.SectorSize()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// make sure the sector isn't removed or moved while we copy it
+	if err := r.index.StorageLock(ctx, s.ID, types, storiface.FTNone); err != nil {
+		return xerrors.Errorf("acquiring sector lock: %w", err)
+	}
+
+	for _, fileType := range storiface.PathTypes {
+		if fileType&types == 0 {
+			continue
+		}
+
+		si, err := r.index.StorageFindSector(ctx, s.ID, fileType, 0, false)
+		if err != nil {
+			return xerrors.Errorf("finding sector replicas: %w", err)
+		}
+
+		have := map[ID]struct{}{}
+		domains := map[string]struct{}{}
+		for _, info := range si {
+			have[info.ID] = struct{}{}
+			if info.CanStore {
+				domains[failureDomain(info.ID, info.FailureDomain)] = struct{}{}
+			}
+		}
+
+		if len(domains) == 0 {
+			// the sector isn't finalized yet
+			continue
+		}
+		if len(domains) >= copies {
+			continue
+		}
+
+		candidates, err := r.index.StorageBestAlloc(ctx, fileType, ssize, storiface.PathStorage)
+		if err != nil {
+			return xerrors.Errorf("finding storage for sector replicas: %w", err)
+		}
+
+		for _, candidate := range candidates {
+			if len(domains) >= copies {
+				break
+			}
+
+			if _, ok := have[candidate.ID]; ok {
+				continue
+			}
+
+			domain := failureDomain(candidate.ID, candidate.FailureDomain)
+			if _, ok := domains[domain]; ok {
+				continue
+			}
+
+			dest, ok := r.local.localSectorPath(candidate.ID, s, fileType)
+			if !ok {
+				continue
+			}
+
+			if err := r.replicateTo(ctx, s, fileType, candidate.ID, dest); err != nil {
+				log.Warnw("replicating sector", "sector", s.ID, "type", fileType, "storage", candidate.ID, "error", err)
+				continue
+			}
+
+			domains[domain] = struct{}{}
+		}
+
+		if len(domains) < copies {
+			return xerrors.Errorf("sector %d(t:%d) is stored in %d failure domains, no local storage available for %d more replicas", s.ID, fileType, len(domains), copies-len(domains))
+		}
+	}
+
+	return nil
+}
+
+func (r *Remote) replicateTo(ctx context.Context, s storage.SectorRef, fileType storiface.SectorFileType, id ID, dest string) error {
+	var ids storiface.SectorPaths
+	storiface.SetPathByType(&ids, fileType, string(id))
+
+	releaseStorage, err := r.local.Reserve(ctx, s, fileType, ids, storiface.FsOverheadFinalized)
+	if err != nil {
+		return xerrors.Errorf("reserving storage space: %w", err)
+	}
+	defer releaseStorage()
+
+	if _, err := r.acquireFromRemote(ctx, s.ID, fileType, id, dest); err != nil {
+		return err
+	}
+
+	// replicas are primary copies, so that RemoveCopies doesn't drop them
+	if err := r.index.StorageDeclareSector(ctx, id, s.ID, fileType, true); err != nil {
+		return xerrors.Errorf("declaring replica: %w", err)
+	}
+
+	log.Infow("replicated sector", "sector", s.ID, "type", fileType, "storage", id)
+
+	r.local.reportStorage(ctx) // report space use changes
+
+	return nil
+}
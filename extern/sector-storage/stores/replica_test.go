@@ -0,0 +1,115 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func initDomainPath(t *testing.T, root, subpath, domain string) (string, ID) {
+	path := filepath.Join(root, subpath)
+	require.NoError(t, os.Mkdir(path, 0755))
+
+	meta := &LocalStorageMeta{
+		ID:       ID(uuid.New().String()),
+		Weight:   1,
+		CanStore: true,
+
+		FailureDomain: domain,
+	}
+
+	mb, err := json.MarshalIndent(meta, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(path, MetaFile), mb, 0644))
+
+	return path, meta.ID
+}
+
+func TestReplicateSector(t *testing.T) {
+	ctx := context.TODO()
+
+	root, err := ioutil.TempDir("", "sector-storage-replicas-")
+	require.NoError(t, err)
+	defer os.RemoveAll(root) // nolint
+
+	tstor := &TestingLocalStorage{
+		root: root,
+	}
+
+	index := NewIndex()
+
+	var handler FetchHandler
+	srv := httptest.NewServer(&handler)
+	defer srv.Close()
+
+	st, err := NewLocal(ctx, tstor, index, []string{srv.URL + "/remote"})
+	require.NoError(t, err)
+	handler.Local = st
+
+	remote := NewRemote(st, index, nil, 1, FetchLimits{})
+
+	p1, id1 := initDomainPath(t, root, "1", "host-a")
+	_, id2 := initDomainPath(t, root, "2", "host-a")
+	p3, id3 := initDomainPath(t, root, "3", "host-b")
+
+	sector := storage.SectorRef{
+		ID:        abi.SectorID{Miner: 1000, Number: 1},
+		ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1,
+	}
+	name := storiface.SectorName(sector.ID)
+
+	// the sector exists in a single path before any storage is opened, so it
+	// gets declared when the path is opened
+	require.NoError(t, os.MkdirAll(filepath.Join(p1, storiface.FTSealed.String()), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(p1, storiface.FTSealed.String(), name), []byte("sealed"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(p1, storiface.FTCache.String(), name), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(p1, storiface.FTCache.String(), name, "p_aux"), []byte("aux"), 0644))
+
+	for _, p := range []string{"1", "2", "3"} {
+		require.NoError(t, st.OpenPath(ctx, filepath.Join(root, p)))
+	}
+
+	found := func(ft storiface.SectorFileType) map[ID]bool {
+		si, err := index.StorageFindSector(ctx, sector.ID, ft, 0, false)
+		require.NoError(t, err)
+
+		out := map[ID]bool{}
+		for _, info := range si {
+			out[info.ID] = info.Primary
+		}
+		return out
+	}
+
+	// a single copy is already stored in one failure domain
+	require.NoError(t, remote.ReplicateSector(ctx, sector, storiface.FTSealed|storiface.FTCache, 1))
+	require.Equal(t, map[ID]bool{id1: true}, found(storiface.FTSealed))
+
+	// the second copy must go to host-b, not the other path in host-a
+	require.NoError(t, remote.ReplicateSector(ctx, sector, storiface.FTSealed|storiface.FTCache, 2))
+	require.Equal(t, map[ID]bool{id1: true, id3: true}, found(storiface.FTSealed))
+	require.Equal(t, map[ID]bool{id1: true, id3: true}, found(storiface.FTCache))
+	require.NotContains(t, found(storiface.FTSealed), id2)
+
+	b, err := ioutil.ReadFile(filepath.Join(p3, storiface.FTSealed.String(), name))
+	require.NoError(t, err)
+	require.Equal(t, "sealed", string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(p3, storiface.FTCache.String(), name, "p_aux"))
+	require.NoError(t, err)
+	require.Equal(t, "aux", string(b))
+
+	// there is no third failure domain
+	require.Error(t, remote.ReplicateSector(ctx, sector, storiface.FTSealed, 3))
+}
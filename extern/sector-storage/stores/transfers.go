@@ -0,0 +1,216 @@
+package stores
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// FetchLimits limit fetches of sector files from remote storage, in addition
+// to the total number of parallel fetches. Zero values mean no limit.
+type FetchLimits struct {
+	// Maximum number of parallel fetches into a single local storage path
+	PathConcurrency int
+
+	// Maximum total fetch bandwidth, in bytes per second
+	Bandwidth int64
+	// Maximum fetch bandwidth into a single local storage path, in bytes per
+	// second
+	PathBandwidth int64
+}
+
+// TransferInfo describes a running or queued fetch of a sector file
+type TransferInfo struct {
+	ID       uint64
+	Sector   abi.SectorID
+	FileType storiface.SectorFileType
+	URL      string
+	// Local storage path the file is fetched into
+	Dest ID
+
+	Queued time.Time
+	// Zero while the transfer is waiting for a free slot
+	Started     time.Time
+	Transferred int64
+}
+
+type transfer struct {
+	info        TransferInfo
+	transferred int64 // atomic
+}
+
+type transferQueue struct {
+	limits FetchLimits
+
+	global    chan struct{}
+	bandwidth *rate.Limiter
+
+	lk        sync.Mutex
+	paths     map[ID]chan struct{}
+	pathBw    map[ID]*rate.Limiter
+	transfers map[uint64]*transfer
+	next      uint64
+}
+
+func newTransferQueue(fetchLimit int, limits FetchLimits) *transferQueue {
+	q := &transferQueue{
+		limits: limits,
+
+		global: make(chan struct{}, fetchLimit),
+
+		paths:     map[ID]chan struct{}{},
+		pathBw:    map[ID]*rate.Limiter{},
+		transfers: map[uint64]*transfer{},
+	}
+
+	if limits.Bandwidth > 0 {
+		q.bandwidth = rate.NewLimiter(rate.Limit(limits.Bandwidth), CopyBuf)
+	}
+
+	return q
+}
+
+// start queues a transfer, and waits for a free slot. The returned function
+// must be called when the transfer is done.
+func (q *transferQueue) start(ctx context.Context, info TransferInfo) (*transfer, func(), error) {
+	q.lk.Lock()
+	q.next++
+	info.ID = q.next
+	info.Queued = time.Now()
+
+	t := &transfer{info: info}
+	q.transfers[t.info.ID] = t
+
+	path := q.paths[info.Dest]
+	if path == nil && q.limits.PathConcurrency > 0 {
+		path = make(chan struct{}, q.limits.PathConcurrency)
+		q.paths[info.Dest] = path
+	}
+	q.lk.Unlock()
+
+	done := func() {
+		q.lk.Lock()
+		delete(q.transfers, t.info.ID)
+		q.lk.Unlock()
+	}
+
+	if len(q.global) >= cap(q.global) {
+		log.Infof("Throttling fetch, %d already running", len(q.global))
+	}
+
+	// take the path slot first, so that fetches waiting for a busy path don't
+	// hold back fetches into other paths
+	if path != nil {
+		select {
+		case path <- struct{}{}:
+		case <-ctx.Done():
+			done()
+			return nil, nil, xerrors.Errorf("context error while waiting for path fetch limiter: %w", ctx.Err())
+		}
+	}
+
+	select {
+	case q.global <- struct{}{}:
+	case <-ctx.Done():
+		if path != nil {
+			<-path
+		}
+		done()
+		return nil, nil, xerrors.Errorf("context error while waiting for fetch limiter: %w", ctx.Err())
+	}
+
+	q.lk.Lock()
+	t.info.Started = time.Now()
+	q.lk.Unlock()
+
+	return t, func() {
+		<-q.global
+		if path != nil {
+			<-path
+		}
+		done()
+	}, nil
+}
+
+// reader wraps the transfer source, applying bandwidth limits and counting
+// transferred bytes
+func (q *transferQueue) reader(ctx context.Context, t *transfer, r io.Reader) io.Reader {
+	var limiters []*rate.Limiter
+	if q.bandwidth != nil {
+		limiters = append(limiters, q.bandwidth)
+	}
+
+	if q.limits.PathBandwidth > 0 {
+		q.lk.Lock()
+		l, ok := q.pathBw[t.info.Dest]
+		if !ok {
+			l = rate.NewLimiter(rate.Limit(q.limits.PathBandwidth), CopyBuf)
+			q.pathBw[t.info.Dest] = l
+		}
+		q.lk.Unlock()
+
+		limiters = append(limiters, l)
+	}
+
+	return &transferReader{
+		ctx:      ctx,
+		r:        r,
+		t:        t,
+		limiters: limiters,
+	}
+}
+
+func (q *transferQueue) list() []TransferInfo {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	out := make([]TransferInfo, 0, len(q.transfers))
+	for _, t := range q.transfers {
+		info := t.info
+		info.Transferred = atomic.LoadInt64(&t.transferred)
+		out = append(out, info)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	return out
+}
+
+type transferReader struct {
+	ctx      context.Context
+	r        io.Reader
+	t        *transfer
+	limiters []*rate.Limiter
+}
+
+func (tr *transferReader) Read(p []byte) (int, error) {
+	if len(tr.limiters) > 0 && len(p) > CopyBuf {
+		// limiter bursts are CopyBuf bytes
+		p = p[:CopyBuf]
+	}
+
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		atomic.AddInt64(&tr.t.transferred, int64(n))
+
+		for _, l := range tr.limiters {
+			if werr := l.WaitN(tr.ctx, n); werr != nil {
+				return n, xerrors.Errorf("waiting for bandwidth limiter: %w", werr)
+			}
+		}
+	}
+
+	return n, err
+}
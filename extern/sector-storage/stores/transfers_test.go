@@ -0,0 +1,81 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestTransferQueuePathLimit(t *testing.T) {
+	ctx := context.Background()
+	q := newTransferQueue(2, FetchLimits{PathConcurrency: 1})
+
+	info := func(sector abi.SectorNumber, dest ID) TransferInfo {
+		return TransferInfo{
+			Sector:   abi.SectorID{Miner: 1000, Number: sector},
+			FileType: storiface.FTSealed,
+			Dest:     dest,
+		}
+	}
+
+	_, done1, err := q.start(ctx, info(1, "a"))
+	require.NoError(t, err)
+
+	// a different path isn't held back
+	_, done2, err := q.start(ctx, info(2, "b"))
+	require.NoError(t, err)
+
+	started := make(chan func())
+	go func() {
+		_, done, err := q.start(ctx, info(3, "a"))
+		require.NoError(t, err)
+		started <- done
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(q.list()) == 3
+	}, time.Second, time.Millisecond)
+
+	list := q.list()
+	require.False(t, list[0].Started.IsZero())
+	require.False(t, list[1].Started.IsZero())
+	require.True(t, list[2].Started.IsZero())
+
+	select {
+	case <-started:
+		t.Fatal("transfer started on a busy path")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done2()
+	done1()
+
+	done3 := <-started
+	done3()
+
+	require.Empty(t, q.list())
+}
+
+func TestTransferReaderCounts(t *testing.T) {
+	ctx := context.Background()
+	q := newTransferQueue(1, FetchLimits{Bandwidth: 64 << 20, PathBandwidth: 64 << 20})
+
+	tr, done, err := q.start(ctx, TransferInfo{Dest: "a"})
+	require.NoError(t, err)
+	defer done()
+
+	data := bytes.Repeat([]byte{1}, 3<<20)
+	out, err := ioutil.ReadAll(q.reader(ctx, tr, bytes.NewReader(data)))
+	require.NoError(t, err)
+	require.Equal(t, data, out)
+
+	require.Equal(t, int64(len(data)), q.list()[0].Transferred)
+}
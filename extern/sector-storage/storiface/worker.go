@@ -11,6 +11,7 @@ import (
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
@@ -18,6 +19,8 @@ import (
 
 type WorkerInfo struct {
 	Hostname string
+	// Sectors sealed on a worker in a group are kept on workers in that group
+	Groups []string
 
 	Resources WorkerResources
 }
@@ -30,6 +33,10 @@ type WorkerResources struct {
 
 	CPUs uint64 // Logical cores
 	GPUs []string
+
+	// Maximum number of concurrently running tasks of a given type; task types
+	// without a limit (or with a 0 limit) are only limited by resources
+	TaskLimits map[sealtasks.TaskType]int
 }
 
 type WorkerStats struct {
@@ -40,6 +47,15 @@ type WorkerStats struct {
 	MemUsedMax uint64
 	GpuUsed    bool   // nolint
 	CpuUse     uint64 // nolint
+
+	TaskCounts map[sealtasks.TaskType]int
+
+	PinnedSectors int
+
+	// Draining workers don't get new tasks; Drained is set once all their
+	// tasks have finished
+	Draining bool
+	Drained  bool
 }
 
 const (
@@ -89,6 +105,17 @@ type WorkerCalls interface {
 	UnsealPiece(context.Context, storage.SectorRef, UnpaddedByteIndex, abi.UnpaddedPieceSize, abi.SealRandomness, cid.Cid) (CallID, error)
 	ReadPiece(context.Context, io.Writer, storage.SectorRef, UnpaddedByteIndex, abi.UnpaddedPieceSize) (CallID, error)
 	Fetch(context.Context, storage.SectorRef, SectorFileType, PathType, AcquireMode) (CallID, error)
+	RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) (CallID, error)
+
+	GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (CallID, error)
+	GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (CallID, error)
+}
+
+// WindowPoStResult is the result of generating a window PoSt on a worker.
+// Skipped sectors are set also when proving fails because of them.
+type WindowPoStResult struct {
+	PoStProofs []proof2.PoStProof
+	Skipped    []abi.SectorID
 }
 
 type ErrorCode int
@@ -143,4 +170,7 @@ type WorkerReturn interface {
 	ReturnUnsealPiece(ctx context.Context, callID CallID, err *CallError) error
 	ReturnReadPiece(ctx context.Context, callID CallID, ok bool, err *CallError) error
 	ReturnFetch(ctx context.Context, callID CallID, err *CallError) error
+	ReturnRegenerateCache(ctx context.Context, callID CallID, err *CallError) error
+	ReturnGenerateWinningPoSt(ctx context.Context, callID CallID, proofs []proof2.PoStProof, err *CallError) error
+	ReturnGenerateWindowPoSt(ctx context.Context, callID CallID, res WindowPoStResult, err *CallError) error
 }
@@ -78,4 +78,8 @@ func (t *testExec) ReadPiece(ctx context.Context, writer io.Writer, sector stora
 	panic("implement me")
 }
 
+func (t *testExec) RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) error {
+	panic("implement me")
+}
+
 var _ ffiwrapper.Storage = &testExec{}
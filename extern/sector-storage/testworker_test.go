@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 	"github.com/google/uuid"
 
@@ -93,6 +94,28 @@ func (t *testWorker) Fetch(ctx context.Context, sector storage.SectorRef, fileTy
 	})
 }
 
+func (t *testWorker) GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	return t.asyncCall(storage.SectorRef{ID: abi.SectorID{Miner: minerID}}, func(ci storiface.CallID) {
+		proofs, err := t.mockSeal.GenerateWinningPoSt(ctx, minerID, sectorInfo, randomness)
+		if err := t.ret.ReturnGenerateWinningPoSt(ctx, ci, proofs, toCallError(err)); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+func (t *testWorker) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	return t.asyncCall(storage.SectorRef{ID: abi.SectorID{Miner: minerID}}, func(ci storiface.CallID) {
+		proofs, skipped, err := t.mockSeal.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness)
+		res := storiface.WindowPoStResult{
+			PoStProofs: proofs,
+			Skipped:    skipped,
+		}
+		if err := t.ret.ReturnGenerateWindowPoSt(ctx, ci, res, toCallError(err)); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
 func (t *testWorker) TaskTypes(ctx context.Context) (map[sealtasks.TaskType]struct{}, error) {
 	return t.acceptTasks, nil
 }
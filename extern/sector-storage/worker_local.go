@@ -7,6 +7,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,6 +21,7 @@ import (
 	ffi "github.com/filecoin-project/filecoin-ffi"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-statestore"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	storage "github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
@@ -33,6 +35,15 @@ var pathTypes = []storiface.SectorFileType{storiface.FTUnsealed, storiface.FTSea
 type WorkerConfig struct {
 	TaskTypes []sealtasks.TaskType
 	NoSwap    bool
+
+	// TaskLimits caps the number of concurrently running tasks of a given type.
+	// Task types without a limit here can be limited with the
+	// [SHORT_NAME]_MAX_CONCURRENT env var, e.g. PC1_MAX_CONCURRENT=2
+	TaskLimits map[sealtasks.TaskType]int
+
+	// Groups the worker is part of; sectors sealed on a worker in a group are
+	// kept on workers in that group
+	Groups []string
 }
 
 // used do provide custom proofs impl (mostly used in testing)
@@ -45,6 +56,11 @@ type LocalWorker struct {
 	ret        storiface.WorkerReturn
 	executor   ExecutorFunc
 	noSwap     bool
+	taskLimits map[sealtasks.TaskType]int
+	groups     []string
+
+	// proves sectors in local storage paths, without fetching them
+	postExecutor ExecutorFunc
 
 	ct          *workerCallTracker
 	acceptTasks map[sealtasks.TaskType]struct{}
@@ -58,8 +74,24 @@ type LocalWorker struct {
 
 func newLocalWorker(executor ExecutorFunc, wcfg WorkerConfig, store stores.Store, local *stores.Local, sindex stores.SectorIndex, ret storiface.WorkerReturn, cst *statestore.StateStore) *LocalWorker {
 	acceptTasks := map[sealtasks.TaskType]struct{}{}
+	taskLimits := map[sealtasks.TaskType]int{}
 	for _, taskType := range wcfg.TaskTypes {
 		acceptTasks[taskType] = struct{}{}
+
+		if limit, ok := wcfg.TaskLimits[taskType]; ok {
+			taskLimits[taskType] = limit
+			continue
+		}
+
+		env := taskType.Short() + "_MAX_CONCURRENT"
+		if v, ok := os.LookupEnv(env); ok {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit < 0 {
+				log.Errorf("invalid %s value '%s', ignoring", env, v)
+				continue
+			}
+			taskLimits[taskType] = limit
+		}
 	}
 
 	w := &LocalWorker{
@@ -74,6 +106,8 @@ func newLocalWorker(executor ExecutorFunc, wcfg WorkerConfig, store stores.Store
 		acceptTasks: acceptTasks,
 		executor:    executor,
 		noSwap:      wcfg.NoSwap,
+		taskLimits:  taskLimits,
+		groups:      wcfg.Groups,
 
 		session: uuid.New(),
 		closing: make(chan struct{}),
@@ -81,6 +115,9 @@ func newLocalWorker(executor ExecutorFunc, wcfg WorkerConfig, store stores.Store
 
 	if w.executor == nil {
 		w.executor = w.ffiExec
+		w.postExecutor = w.ffiPoStExec
+	} else {
+		w.postExecutor = w.executor
 	}
 
 	unfinished, err := w.ct.unfinished()
@@ -149,6 +186,10 @@ func (l *LocalWorker) ffiExec() (ffiwrapper.Storage, error) {
 	return ffiwrapper.New(&localWorkerPathProvider{w: l})
 }
 
+func (l *LocalWorker) ffiPoStExec() (ffiwrapper.Storage, error) {
+	return ffiwrapper.New(&readonlyProvider{stor: l.localStore, index: l.sindex})
+}
+
 type ReturnType string
 
 const (
@@ -163,6 +204,10 @@ const (
 	UnsealPiece     ReturnType = "UnsealPiece"
 	ReadPiece       ReturnType = "ReadPiece"
 	Fetch           ReturnType = "Fetch"
+	RegenerateCache ReturnType = "RegenerateCache"
+
+	GenerateWinningPoSt ReturnType = "GenerateWinningPoSt"
+	GenerateWindowPoSt  ReturnType = "GenerateWindowPoSt"
 )
 
 // in: func(WorkerReturn, context.Context, CallID, err string)
@@ -211,6 +256,10 @@ var returnFunc = map[ReturnType]func(context.Context, storiface.CallID, storifac
 	UnsealPiece:     rfunc(storiface.WorkerReturn.ReturnUnsealPiece),
 	ReadPiece:       rfunc(storiface.WorkerReturn.ReturnReadPiece),
 	Fetch:           rfunc(storiface.WorkerReturn.ReturnFetch),
+	RegenerateCache: rfunc(storiface.WorkerReturn.ReturnRegenerateCache),
+
+	GenerateWinningPoSt: rfunc(storiface.WorkerReturn.ReturnGenerateWinningPoSt),
+	GenerateWindowPoSt:  rfunc(storiface.WorkerReturn.ReturnGenerateWindowPoSt),
 }
 
 func (l *LocalWorker) asyncCall(ctx context.Context, sector storage.SectorRef, rt ReturnType, work func(ctx context.Context, ci storiface.CallID) (interface{}, error)) (storiface.CallID, error) {
@@ -457,6 +506,47 @@ func (l *LocalWorker) ReadPiece(ctx context.Context, writer io.Writer, sector st
 	})
 }
 
+func (l *LocalWorker) RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) (storiface.CallID, error) {
+	sb, err := l.executor()
+	if err != nil {
+		return storiface.UndefCall, err
+	}
+
+	return l.asyncCall(ctx, sector, RegenerateCache, func(ctx context.Context, ci storiface.CallID) (interface{}, error) {
+		if err := sb.RegenerateCache(ctx, sector, ticket, pieces, sealed); err != nil {
+			return nil, xerrors.Errorf("regenerating sector cache: %w", err)
+		}
+
+		return nil, nil
+	})
+}
+
+func (l *LocalWorker) GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	sb, err := l.postExecutor()
+	if err != nil {
+		return storiface.UndefCall, err
+	}
+
+	return l.asyncCall(ctx, storage.SectorRef{ID: abi.SectorID{Miner: minerID}}, GenerateWinningPoSt, func(ctx context.Context, ci storiface.CallID) (interface{}, error) {
+		return sb.GenerateWinningPoSt(ctx, minerID, sectorInfo, randomness)
+	})
+}
+
+func (l *LocalWorker) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	sb, err := l.postExecutor()
+	if err != nil {
+		return storiface.UndefCall, err
+	}
+
+	return l.asyncCall(ctx, storage.SectorRef{ID: abi.SectorID{Miner: minerID}}, GenerateWindowPoSt, func(ctx context.Context, ci storiface.CallID) (interface{}, error) {
+		proofs, skipped, err := sb.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness)
+		return storiface.WindowPoStResult{
+			PoStProofs: proofs,
+			Skipped:    skipped,
+		}, err
+	})
+}
+
 func (l *LocalWorker) TaskTypes(context.Context) (map[sealtasks.TaskType]struct{}, error) {
 	l.taskLk.Lock()
 	defer l.taskLk.Unlock()
@@ -512,12 +602,14 @@ func (l *LocalWorker) Info(context.Context) (storiface.WorkerInfo, error) {
 
 	return storiface.WorkerInfo{
 		Hostname: hostname,
+		Groups:   l.groups,
 		Resources: storiface.WorkerResources{
 			MemPhysical: mem.Total,
 			MemSwap:     memSwap,
 			MemReserved: mem.VirtualUsed + mem.Total - mem.Available, // TODO: sub this process
 			CPUs:        uint64(runtime.NumCPU()),
 			GPUs:        gpus,
+			TaskLimits:  l.taskLimits,
 		},
 	}, nil
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
@@ -16,33 +17,53 @@ import (
 )
 
 type trackedWork struct {
-	job    storiface.WorkerJob
-	worker WorkerID
+	job      storiface.WorkerJob
+	worker   WorkerID
+	hostname string
 }
 
+// TaskDoneNotifee is called with each task which finished on a worker
+type TaskDoneNotifee func(job storiface.WorkerJob, hostname string, took time.Duration, failed bool)
+
 type workTracker struct {
 	lk sync.Mutex
 
 	done    map[storiface.CallID]struct{}
 	running map[storiface.CallID]trackedWork
 
+	notifees []TaskDoneNotifee
+
 	// TODO: done, aggregate stats, queue stats, scheduler feedback
 }
 
-func (wt *workTracker) onDone(callID storiface.CallID) {
+func (wt *workTracker) onDone(callID storiface.CallID, failed bool) {
 	wt.lk.Lock()
-	defer wt.lk.Unlock()
 
-	_, ok := wt.running[callID]
+	tw, ok := wt.running[callID]
 	if !ok {
 		wt.done[callID] = struct{}{}
+		wt.lk.Unlock()
 		return
 	}
 
 	delete(wt.running, callID)
+	notifees := wt.notifees
+	wt.lk.Unlock()
+
+	took := time.Since(tw.job.Start)
+	for _, n := range notifees {
+		n(tw.job, tw.hostname, took, failed)
+	}
+}
+
+func (wt *workTracker) addNotifee(n TaskDoneNotifee) {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	wt.notifees = append(wt.notifees, n)
 }
 
-func (wt *workTracker) track(wid WorkerID, sid storage.SectorRef, task sealtasks.TaskType) func(storiface.CallID, error) (storiface.CallID, error) {
+func (wt *workTracker) track(wid WorkerID, hostname string, sid storage.SectorRef, task sealtasks.TaskType) func(storiface.CallID, error) (storiface.CallID, error) {
 	return func(callID storiface.CallID, err error) (storiface.CallID, error) {
 		if err != nil {
 			return callID, err
@@ -64,17 +85,19 @@ func (wt *workTracker) track(wid WorkerID, sid storage.SectorRef, task sealtasks
 				Task:   task,
 				Start:  time.Now(),
 			},
-			worker: wid,
+			worker:   wid,
+			hostname: hostname,
 		}
 
 		return callID, err
 	}
 }
 
-func (wt *workTracker) worker(wid WorkerID, w Worker) Worker {
+func (wt *workTracker) worker(wid WorkerID, hostname string, w Worker) Worker {
 	return &trackedWorker{
-		Worker: w,
-		wid:    wid,
+		Worker:   w,
+		wid:      wid,
+		hostname: hostname,
 
 		tracker: wt,
 	}
@@ -94,45 +117,58 @@ func (wt *workTracker) Running() []trackedWork {
 
 type trackedWorker struct {
 	Worker
-	wid WorkerID
+	wid      WorkerID
+	hostname string
 
 	tracker *workTracker
 }
 
 func (t *trackedWorker) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTPreCommit1)(t.Worker.SealPreCommit1(ctx, sector, ticket, pieces))
+	return t.tracker.track(t.wid, t.hostname, sector, sealtasks.TTPreCommit1)(t.Worker.SealPreCommit1(ctx, sector, ticket, pieces))
 }
 
 func (t *trackedWorker) SealPreCommit2(ctx context.Context, sector storage.SectorRef, pc1o storage.PreCommit1Out) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTPreCommit2)(t.Worker.SealPreCommit2(ctx, sector, pc1o))
+	return t.tracker.track(t.wid, t.hostname, sector, sealtasks.TTPreCommit2)(t.Worker.SealPreCommit2(ctx, sector, pc1o))
 }
 
 func (t *trackedWorker) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTCommit1)(t.Worker.SealCommit1(ctx, sector, ticket, seed, pieces, cids))
+	return t.tracker.track(t.wid, t.hostname, sector, sealtasks.TTCommit1)(t.Worker.SealCommit1(ctx, sector, ticket, seed, pieces, cids))
 }
 
 func (t *trackedWorker) SealCommit2(ctx context.Context, sector storage.SectorRef, c1o storage.Commit1Out) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTCommit2)(t.Worker.SealCommit2(ctx, sector, c1o))
+	return t.tracker.track(t.wid, t.hostname, sector, sealtasks.TTCommit2)(t.Worker.SealCommit2(ctx, sector, c1o))
 }
 
 func (t *trackedWorker) FinalizeSector(ctx context.Context, sector storage.SectorRef, keepUnsealed []storage.Range) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTFinalize)(t.Worker.FinalizeSector(ctx, sector, keepUnsealed))
+	return t.tracker.track(t.wid, t.hostname, sector, sealtasks.TTFinalize)(t.Worker.FinalizeSector(ctx, sector, keepUnsealed))
 }
 
 func (t *trackedWorker) AddPiece(ctx context.Context, sector storage.SectorRef, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTAddPiece)(t.Worker.AddPiece(ctx, sector, pieceSizes, newPieceSize, pieceData))
+	return t.tracker.track(t.wid, t.hostname, sector, sealtasks.TTAddPiece)(t.Worker.AddPiece(ctx, sector, pieceSizes, newPieceSize, pieceData))
 }
 
 func (t *trackedWorker) Fetch(ctx context.Context, s storage.SectorRef, ft storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, s, sealtasks.TTFetch)(t.Worker.Fetch(ctx, s, ft, ptype, am))
+	return t.tracker.track(t.wid, t.hostname, s, sealtasks.TTFetch)(t.Worker.Fetch(ctx, s, ft, ptype, am))
 }
 
 func (t *trackedWorker) UnsealPiece(ctx context.Context, id storage.SectorRef, index storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize, randomness abi.SealRandomness, cid cid.Cid) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, id, sealtasks.TTUnseal)(t.Worker.UnsealPiece(ctx, id, index, size, randomness, cid))
+	return t.tracker.track(t.wid, t.hostname, id, sealtasks.TTUnseal)(t.Worker.UnsealPiece(ctx, id, index, size, randomness, cid))
 }
 
 func (t *trackedWorker) ReadPiece(ctx context.Context, writer io.Writer, id storage.SectorRef, index storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, id, sealtasks.TTReadUnsealed)(t.Worker.ReadPiece(ctx, writer, id, index, size))
+	return t.tracker.track(t.wid, t.hostname, id, sealtasks.TTReadUnsealed)(t.Worker.ReadPiece(ctx, writer, id, index, size))
+}
+
+func (t *trackedWorker) RegenerateCache(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, sealed cid.Cid) (storiface.CallID, error) {
+	return t.tracker.track(t.wid, t.hostname, sector, sealtasks.TTRegenCache)(t.Worker.RegenerateCache(ctx, sector, ticket, pieces, sealed))
+}
+
+func (t *trackedWorker) GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	return t.tracker.track(t.wid, t.hostname, storage.SectorRef{ID: abi.SectorID{Miner: minerID}}, sealtasks.TTGenerateWinningPoSt)(t.Worker.GenerateWinningPoSt(ctx, minerID, sectorInfo, randomness))
+}
+
+func (t *trackedWorker) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) (storiface.CallID, error) {
+	return t.tracker.track(t.wid, t.hostname, storage.SectorRef{ID: abi.SectorID{Miner: minerID}}, sealtasks.TTGenerateWindowPoSt)(t.Worker.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness))
 }
 
 var _ Worker = &trackedWorker{}
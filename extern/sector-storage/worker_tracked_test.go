@@ -0,0 +1,63 @@
+package sectorstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestWorkTrackerNotifees(t *testing.T) {
+	wt := &workTracker{
+		done:    map[storiface.CallID]struct{}{},
+		running: map[storiface.CallID]trackedWork{},
+	}
+
+	type done struct {
+		job      storiface.WorkerJob
+		hostname string
+		failed   bool
+	}
+	var notified []done
+	wt.addNotifee(func(job storiface.WorkerJob, hostname string, took time.Duration, failed bool) {
+		require.True(t, took >= 0)
+		notified = append(notified, done{job, hostname, failed})
+	})
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 1}}
+	pc1 := storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+	pc2 := storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+
+	_, err := wt.track(WorkerID{}, "host-a", sector, sealtasks.TTPreCommit1)(pc1, nil)
+	require.NoError(t, err)
+	_, err = wt.track(WorkerID{}, "host-b", sector, sealtasks.TTPreCommit2)(pc2, nil)
+	require.NoError(t, err)
+	require.Len(t, wt.Running(), 2)
+
+	wt.onDone(pc1, false)
+	wt.onDone(pc2, true)
+	require.Empty(t, wt.Running())
+
+	require.Len(t, notified, 2)
+	require.Equal(t, sealtasks.TTPreCommit1, notified[0].job.Task)
+	require.Equal(t, "host-a", notified[0].hostname)
+	require.False(t, notified[0].failed)
+	require.Equal(t, sealtasks.TTPreCommit2, notified[1].job.Task)
+	require.Equal(t, "host-b", notified[1].hostname)
+	require.True(t, notified[1].failed)
+
+	// calls which return before they are tracked aren't timed
+	early := storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+	wt.onDone(early, false)
+	_, err = wt.track(WorkerID{}, "host-a", sector, sealtasks.TTCommit1)(early, nil)
+	require.NoError(t, err)
+	require.Empty(t, wt.Running())
+	require.Len(t, notified, 2)
+}
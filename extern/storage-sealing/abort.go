@@ -0,0 +1,283 @@
+package sealing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// MovedPiece is a deal piece of an aborted sector which was added to another
+// sector
+type MovedPiece struct {
+	DealID   abi.DealID
+	PieceCID cid.Cid
+
+	Sector abi.SectorNumber
+	Offset abi.PaddedPieceSize
+	Size   abi.PaddedPieceSize
+}
+
+const AbortedSectorStorePrefix = "/aborted-sectors"
+
+// abortTracker tracks sectors being packed, and aborted sectors with deal
+// pieces already moved to other sectors
+type abortTracker struct {
+	lk sync.Mutex
+	ds datastore.Datastore
+
+	packing map[abi.SectorNumber]struct{}
+	// indexes of the deal pieces which were added to other sectors, by aborted
+	// sector; persisted so that a failed abort can be retried without adding
+	// pieces twice, also after a restart, until the sector is removed
+	released map[abi.SectorNumber]map[int]struct{}
+}
+
+func newAbortTracker(ds datastore.Datastore) *abortTracker {
+	return &abortTracker{
+		ds:       ds,
+		packing:  map[abi.SectorNumber]struct{}{},
+		released: map[abi.SectorNumber]map[int]struct{}{},
+	}
+}
+
+func abortKey(sid abi.SectorNumber) datastore.Key {
+	return datastore.NewKey(fmt.Sprint(uint64(sid)))
+}
+
+// load reads the aborted sectors from the datastore, it must be called before
+// sectors are restarted
+func (a *abortTracker) load() error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	res, err := a.ds.Query(query.Query{})
+	if err != nil {
+		return xerrors.Errorf("querying aborted sectors: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	for r := range res.Next() {
+		if r.Error != nil {
+			return xerrors.Errorf("reading aborted sectors: %w", r.Error)
+		}
+
+		var sid uint64
+		if _, err := fmt.Sscan(datastore.NewKey(r.Key).BaseNamespace(), &sid); err != nil {
+			return xerrors.Errorf("parsing aborted sector key %q: %w", r.Key, err)
+		}
+
+		var pieces []int
+		if err := json.Unmarshal(r.Value, &pieces); err != nil {
+			return xerrors.Errorf("decoding released pieces of aborted sector %d: %w", sid, err)
+		}
+
+		released := map[int]struct{}{}
+		for _, i := range pieces {
+			released[i] = struct{}{}
+		}
+		a.released[abi.SectorNumber(sid)] = released
+	}
+
+	return nil
+}
+
+// save persists the released pieces of the aborted sector. Must be called
+// with a.lk held
+func (a *abortTracker) save(sid abi.SectorNumber) error {
+	pieces := make([]int, 0, len(a.released[sid]))
+	for i := range a.released[sid] {
+		pieces = append(pieces, i)
+	}
+
+	b, err := json.Marshal(pieces)
+	if err != nil {
+		return xerrors.Errorf("encoding released pieces: %w", err)
+	}
+
+	if err := a.ds.Put(abortKey(sid), b); err != nil {
+		return xerrors.Errorf("storing released pieces of aborted sector %d: %w", sid, err)
+	}
+	return nil
+}
+
+// abort records the sector as aborted, and returns whether it was already
+func (a *abortTracker) abort(sid abi.SectorNumber) (bool, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	if _, ok := a.released[sid]; ok {
+		return true, nil
+	}
+
+	a.released[sid] = map[int]struct{}{}
+	return false, a.save(sid)
+}
+
+// isReleased returns whether the deal piece of the aborted sector was already
+// added to another sector
+func (a *abortTracker) isReleased(sid abi.SectorNumber, piece int) bool {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	_, ok := a.released[sid][piece]
+	return ok
+}
+
+// release records that the deal piece of the aborted sector was added to
+// another sector
+func (a *abortTracker) release(sid abi.SectorNumber, piece int) error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	released, ok := a.released[sid]
+	if !ok {
+		return xerrors.Errorf("sector %d wasn't aborted", sid)
+	}
+
+	released[piece] = struct{}{}
+	return a.save(sid)
+}
+
+// startPacking records that the packing handler of a sector is running. It
+// returns false if the sector was aborted.
+func (a *abortTracker) startPacking(sid abi.SectorNumber) (func(), bool) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	if _, aborted := a.released[sid]; aborted {
+		return nil, false
+	}
+
+	a.packing[sid] = struct{}{}
+	return func() {
+		a.lk.Lock()
+		delete(a.packing, sid)
+		a.lk.Unlock()
+	}, true
+}
+
+// forget drops the aborted sector once it's removed
+func (a *abortTracker) forget(sid abi.SectorNumber) error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	if _, ok := a.released[sid]; !ok {
+		return nil
+	}
+
+	delete(a.released, sid)
+	if err := a.ds.Delete(abortKey(sid)); err != nil {
+		return xerrors.Errorf("deleting aborted sector %d: %w", sid, err)
+	}
+	return nil
+}
+
+// AbortSector aborts a sector which didn't start sealing yet. Deal pieces
+// already written into the sector are read back from its unsealed file and
+// added to other sectors, like newly received pieces, then the sector and its
+// partially written unsealed file are removed.
+//
+// The pieces moved to other sectors are returned, also when aborting fails
+// part way, so that the caller can update the locations of the deals.
+func (m *Sealing) AbortSector(ctx context.Context, sid abi.SectorNumber) ([]MovedPiece, error) {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return nil, xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	m.aborts.lk.Lock()
+	_, retry := m.aborts.released[sid]
+	_, packing := m.aborts.packing[sid]
+	m.aborts.lk.Unlock()
+
+	switch si.State {
+	case Empty, WaitDeals:
+		// taking the lock waits for pieces being added to the sector, and
+		// removing the sector from the map stops new pieces from being added
+		m.unsealedInfoMap.lk.Lock()
+		_, open := m.unsealedInfoMap.infos[sid]
+		delete(m.unsealedInfoMap.infos, sid)
+		m.unsealedInfoMap.lk.Unlock()
+
+		if !open && !retry {
+			return nil, xerrors.Errorf("sector %d started packing, retry once it reaches the Packing state", sid)
+		}
+	case Packing:
+		if packing {
+			return nil, xerrors.Errorf("sector %d is being filled, retry once packing is done or has failed", sid)
+		}
+	default:
+		return nil, xerrors.Errorf("can only abort sectors in the Empty, WaitDeals or Packing states, sector %d is in %s", sid, si.State)
+	}
+
+	if _, err := m.aborts.abort(sid); err != nil {
+		return nil, err
+	}
+
+	log.Infow("aborting sector", "sector", sid, "state", si.State, "pieces", len(si.Pieces))
+
+	var moved []MovedPiece
+	var offset abi.PaddedPieceSize
+	for i, p := range si.Pieces {
+		pieceOffset := offset
+		offset += p.Piece.Size
+
+		if p.DealInfo == nil {
+			continue
+		}
+
+		if m.aborts.isReleased(sid, i) {
+			continue
+		}
+
+		sn, so, err := m.readdPiece(ctx, si, pieceOffset, p)
+		if err != nil {
+			return moved, xerrors.Errorf("moving piece %s (deal %d) to another sector: %w", p.Piece.PieceCID, p.DealInfo.DealID, err)
+		}
+
+		log.Infow("moved piece of aborted sector", "sector", sid, "deal", p.DealInfo.DealID, "to", sn)
+
+		moved = append(moved, MovedPiece{
+			DealID:   p.DealInfo.DealID,
+			PieceCID: p.Piece.PieceCID,
+			Sector:   sn,
+			Offset:   so,
+			Size:     p.Piece.Size,
+		})
+
+		if err := m.aborts.release(sid, i); err != nil {
+			// the piece would be added to another sector again when retrying
+			return moved, xerrors.Errorf("recording moved piece: %w", err)
+		}
+	}
+
+	return moved, m.sectors.Send(uint64(sid), SectorRemove{})
+}
+
+func (m *Sealing) readdPiece(ctx context.Context, si SectorInfo, offset abi.PaddedPieceSize, p Piece) (abi.SectorNumber, abi.PaddedPieceSize, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	size := p.Piece.Size.Unpadded()
+
+	pr, pw := io.Pipe()
+	go func() {
+		// the piece is only in the unsealed file, so there is nothing to unseal
+		err := m.sealer.ReadPiece(ctx, pw, m.minerSector(si.SectorType, si.SectorNumber), storiface.UnpaddedByteIndex(offset.Unpadded()), size, nil, cid.Undef)
+		_ = pw.CloseWithError(err)
+	}()
+	defer pr.Close() // nolint
+
+	return m.AddPieceToAnySector(ctx, size, pr, *p.DealInfo)
+}
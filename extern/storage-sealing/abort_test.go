@@ -0,0 +1,48 @@
+package sealing
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+func TestAbortTracker(t *testing.T) {
+	ds := datastore.NewMapDatastore()
+	a := newAbortTracker(ds)
+	require.NoError(t, a.load())
+
+	done, ok := a.startPacking(1)
+	require.True(t, ok)
+	require.Contains(t, a.packing, abi.SectorNumber(1))
+	done()
+	require.NotContains(t, a.packing, abi.SectorNumber(1))
+
+	// aborted sectors don't start packing
+	retry, err := a.abort(2)
+	require.NoError(t, err)
+	require.False(t, retry)
+	require.NoError(t, a.release(2, 0))
+	_, ok = a.startPacking(2)
+	require.False(t, ok)
+	require.NotContains(t, a.packing, abi.SectorNumber(2))
+
+	// released pieces are kept across restarts
+	a = newAbortTracker(ds)
+	require.NoError(t, a.load())
+	require.True(t, a.isReleased(2, 0))
+	require.False(t, a.isReleased(2, 1))
+	retry, err = a.abort(2)
+	require.NoError(t, err)
+	require.True(t, retry)
+
+	// removed sectors are forgotten
+	require.NoError(t, a.forget(2))
+	require.Empty(t, a.released)
+
+	a = newAbortTracker(ds)
+	require.NoError(t, a.load())
+	require.Empty(t, a.released)
+}
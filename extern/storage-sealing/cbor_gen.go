@@ -135,7 +135,7 @@ func (t *DealInfo) MarshalCBOR(w io.Writer) error {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{164}); err != nil {
+	if _, err := w.Write([]byte{165}); err != nil {
 		return err
 	}
 
@@ -210,6 +210,38 @@ func (t *DealInfo) MarshalCBOR(w io.Writer) error {
 	if err := cbg.WriteBool(w, t.KeepUnsealed); err != nil {
 		return err
 	}
+
+	// t.Labels ([]string) (slice)
+	if len("Labels") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Labels\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("Labels"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("Labels")); err != nil {
+		return err
+	}
+
+	if len(t.Labels) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Labels was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.Labels))); err != nil {
+		return err
+	}
+	for _, v := range t.Labels {
+		if len(v) > cbg.MaxLength {
+			return xerrors.Errorf("Value in field v was too long")
+		}
+
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len(v))); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, string(v)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -312,6 +344,37 @@ func (t *DealInfo) UnmarshalCBOR(r io.Reader) error {
 			default:
 				return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
 			}
+			// t.Labels ([]string) (slice)
+		case "Labels":
+
+			maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.MaxLength {
+				return fmt.Errorf("t.Labels: array too large (%d)", extra)
+			}
+
+			if maj != cbg.MajArray {
+				return fmt.Errorf("expected cbor array")
+			}
+
+			if extra > 0 {
+				t.Labels = make([]string, extra)
+			}
+
+			for i := 0; i < int(extra); i++ {
+
+				{
+					sval, err := cbg.ReadStringBuf(br, scratch)
+					if err != nil {
+						return err
+					}
+
+					t.Labels[i] = string(sval)
+				}
+			}
 
 		default:
 			return fmt.Errorf("unknown struct field %d: '%s'", i, name)
@@ -475,7 +538,7 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{184, 25}); err != nil {
+	if _, err := w.Write([]byte{184, 26}); err != nil {
 		return err
 	}
 
@@ -972,6 +1035,22 @@ func (t *SectorInfo) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
+	// t.FailureRetries (uint64) (uint64)
+	if len("FailureRetries") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"FailureRetries\" was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, uint64(len("FailureRetries"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("FailureRetries")); err != nil {
+		return err
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.FailureRetries)); err != nil {
+		return err
+	}
+
 	// t.LastErr (string) (string)
 	if len("LastErr") > cbg.MaxLength {
 		return xerrors.Errorf("Value in field \"LastErr\" was too long")
@@ -1534,6 +1613,21 @@ func (t *SectorInfo) UnmarshalCBOR(r io.Reader) error {
 
 				t.TerminatedAt = abi.ChainEpoch(extraI)
 			}
+			// t.FailureRetries (uint64) (uint64)
+		case "FailureRetries":
+
+			{
+
+				maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return fmt.Errorf("wrong type for uint64 field")
+				}
+				t.FailureRetries = uint64(extra)
+
+			}
 			// t.LastErr (string) (string)
 		case "LastErr":
 
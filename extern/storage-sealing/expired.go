@@ -0,0 +1,107 @@
+package sealing
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+)
+
+// ExpiredSectorCheckInterval is how often proving sectors are checked for
+// on-chain expiration when RemoveExpiredSectors is enabled
+var ExpiredSectorCheckInterval = time.Hour
+
+func (m *Sealing) runExpiredSectorCleanup(ctx context.Context) {
+	t := time.NewTicker(ExpiredSectorCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := m.removeExpiredSectors(ctx); err != nil {
+				log.Errorf("removing expired sectors: %+v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Sealing) removeExpiredSectors(ctx context.Context) error {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return xerrors.Errorf("getting sealing config: %w", err)
+	}
+
+	if !cfg.RemoveExpiredSectors {
+		return nil
+	}
+
+	tok, height, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	var failed int
+	for _, sector := range sectors {
+		if sector.State != Proving {
+			continue
+		}
+
+		si, err := m.api.StateSectorGetInfo(ctx, m.maddr, sector.SectorNumber, tok)
+		if err != nil {
+			log.Warnw("getting sector on-chain info", "sector", sector.SectorNumber, "error", err)
+			failed++
+			continue
+		}
+
+		if !sectorExpired(sector, si, height) {
+			continue
+		}
+
+		log.Infow("removing expired sector", "sector", sector.SectorNumber, "height", height)
+
+		if err := m.sectors.Send(uint64(sector.SectorNumber), SectorRemove{}); err != nil {
+			log.Warnw("removing expired sector", "sector", sector.SectorNumber, "error", err)
+			failed++
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	if failed > 0 {
+		return xerrors.Errorf("failed to check or remove %d expired sectors", failed)
+	}
+
+	return nil
+}
+
+// sectorExpired checks whether the sector expired at least finality ago, so
+// it can't come back in a reorg and no longer needs to be proven
+func sectorExpired(sector SectorInfo, si *miner.SectorOnChainInfo, height abi.ChainEpoch) bool {
+	var expiration abi.ChainEpoch
+	switch {
+	case si != nil:
+		expiration = si.Expiration
+	case sector.PreCommitInfo != nil:
+		// expired sectors are eventually removed from the miner state, at which
+		// point the expiration the sector was committed with is the best we know
+		expiration = sector.PreCommitInfo.Expiration
+	default:
+		return false
+	}
+
+	return height > expiration+policy.ChainFinality
+}
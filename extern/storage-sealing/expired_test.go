@@ -0,0 +1,33 @@
+package sealing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+)
+
+func TestSectorExpired(t *testing.T) {
+	committed := SectorInfo{
+		PreCommitInfo: &miner.SectorPreCommitInfo{Expiration: 1000},
+	}
+	afterFinality := 1000 + policy.ChainFinality + 1
+
+	// on-chain expiration takes precedence, it may have been extended
+	require.False(t, sectorExpired(committed, &miner.SectorOnChainInfo{Expiration: 5000}, afterFinality))
+	require.True(t, sectorExpired(committed, &miner.SectorOnChainInfo{Expiration: 1000}, afterFinality))
+
+	// not removed until the expiration is final
+	require.False(t, sectorExpired(committed, &miner.SectorOnChainInfo{Expiration: 1000}, afterFinality-1))
+
+	// sector no longer in miner state
+	require.True(t, sectorExpired(committed, nil, afterFinality))
+	require.False(t, sectorExpired(committed, nil, 1001))
+
+	// nothing known about the sector expiration
+	require.False(t, sectorExpired(SectorInfo{}, nil, abi.ChainEpoch(1<<40)))
+}
@@ -14,6 +14,8 @@ import (
 
 	"github.com/filecoin-project/go-state-types/abi"
 	statemachine "github.com/filecoin-project/go-statemachine"
+
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
 )
 
 func (m *Sealing) Plan(events []statemachine.Event, user interface{}) (interface{}, uint64, error) {
@@ -80,6 +82,10 @@ var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *Secto
 		on(SectorChainPreCommitFailed{}, PreCommitFailed),
 	),
 	Committing: planCommitting,
+	CommitFinalize: planOne(
+		on(SectorFinalized{}, SubmitCommit),
+		on(SectorFinalizeFailed{}, CommitFinalizeFailed),
+	),
 	SubmitCommit: planOne(
 		on(SectorCommitSubmitted{}, CommitWait),
 		on(SectorCommitFailed{}, CommitFailed),
@@ -134,6 +140,9 @@ var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *Secto
 	FinalizeFailed: planOne(
 		on(SectorRetryFinalize{}, FinalizeSector),
 	),
+	CommitFinalizeFailed: planOne(
+		on(SectorRetryFinalize{}, CommitFinalize),
+	),
 	PackingFailed: planOne(), // TODO: Deprecated, remove
 	DealsExpired:  planOne(
 	// SectorRemove (global)
@@ -228,10 +237,12 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		return nil, 0, xerrors.Errorf("planner for state %s not found", state.State)
 	}
 
+	before := state.State
 	processed, err := p(events, state)
 	if err != nil {
 		return nil, 0, xerrors.Errorf("running planner for state %s failed: %w", state.State, err)
 	}
+	countRetries(before, state)
 
 	/////
 	// Now decide what to do next
@@ -268,6 +279,9 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 				*<- Committing    |
 				|   |        ^--> CommitFailed
 				|   v             ^
+				|   CommitFinalize <--> CommitFinalizeFailed (FinalizeEarly only)
+				|   |             |
+				|   v             |
 		        |   SubmitCommit  |
 		        |   |             |
 		        |   v             |
@@ -312,6 +326,8 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		return m.handleWaitSeed, processed, nil
 	case Committing:
 		return m.handleCommitting, processed, nil
+	case CommitFinalize:
+		return m.handleFinalizeSector, processed, nil
 	case SubmitCommit:
 		return m.handleSubmitCommit, processed, nil
 	case CommitWait:
@@ -330,7 +346,7 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		return m.handleComputeProofFailed, processed, nil
 	case CommitFailed:
 		return m.handleCommitFailed, processed, nil
-	case FinalizeFailed:
+	case FinalizeFailed, CommitFinalizeFailed:
 		return m.handleFinalizeFailed, processed, nil
 	case PackingFailed: // DEPRECATED: remove this for the next reset
 		state.State = DealsExpired
@@ -387,6 +403,9 @@ func planCommitting(events []statemachine.Event, state *SectorInfo) (uint64, err
 		case SectorCommitted: // the normal case
 			e.apply(state)
 			state.State = SubmitCommit
+		case SectorProofReady: // early finalize
+			e.apply(state)
+			state.State = CommitFinalize
 		case SectorSeedReady: // seed changed :/
 			if e.SeedEpoch == state.SeedEpoch && bytes.Equal(e.SeedValue, state.SeedValue) {
 				log.Warnf("planCommitting: got SectorSeedReady, but the seed didn't change")
@@ -423,15 +442,6 @@ func (m *Sealing) restartSectors(ctx context.Context) error {
 		return xerrors.Errorf("getting the sealing delay: %w", err)
 	}
 
-	spt, err := m.currentSealProof(ctx)
-	if err != nil {
-		return xerrors.Errorf("getting current seal proof: %w", err)
-	}
-	ssize, err := spt.SectorSize()
-	if err != nil {
-		return err
-	}
-
 	// m.unsealedInfoMap.lk.Lock() taken early in .New to prevent races
 	defer m.unsealedInfoMap.lk.Unlock()
 
@@ -447,30 +457,17 @@ func (m *Sealing) restartSectors(ctx context.Context) error {
 				// something's funky here, but probably safe to move on
 				log.Warnf("sector %v was already in the unsealedInfoMap when restarting", sector.SectorNumber)
 			} else {
-				ui := UnsealedSectorInfo{
-					ssize: ssize,
-				}
-				for _, p := range sector.Pieces {
-					if p.DealInfo != nil {
-						ui.numDeals++
-					}
-					ui.stored += p.Piece.Size
-					ui.pieceSizes = append(ui.pieceSizes, p.Piece.Size.Unpadded())
+				ui, err := restoreUnsealedInfo(cfg, sector)
+				if err != nil {
+					log.Errorf("sector %d has invalid proof type %d: %+v", sector.SectorNumber, sector.SectorType, err)
+					continue
 				}
 
 				m.unsealedInfoMap.infos[sector.SectorNumber] = ui
 			}
 
 			// start a fresh timer for the sector
-			if cfg.WaitDealsDelay > 0 {
-				timer := time.NewTimer(cfg.WaitDealsDelay)
-				go func() {
-					<-timer.C
-					if err := m.StartPacking(sector.SectorNumber); err != nil {
-						log.Errorf("starting sector %d: %+v", sector.SectorNumber, err)
-					}
-				}()
-			}
+			m.startWaitDealsTimer(sector.SectorNumber, cfg)
 		}
 	}
 
@@ -479,6 +476,35 @@ func (m *Sealing) restartSectors(ctx context.Context) error {
 	return nil
 }
 
+// restoreUnsealedInfo rebuilds the unsealed sector info of a sector waiting for
+// deals from its pieces
+func restoreUnsealedInfo(cfg sealiface.Config, sector SectorInfo) (UnsealedSectorInfo, error) {
+	ssize, err := sector.SectorType.SectorSize()
+	if err != nil {
+		return UnsealedSectorInfo{}, err
+	}
+
+	ui := UnsealedSectorInfo{
+		ssize:     ssize,
+		spt:       sector.SectorType,
+		waitSince: time.Now(),
+	}
+	for _, p := range sector.Pieces {
+		if p.DealInfo != nil {
+			ui.numDeals++
+			if ui.firstDeal == 0 {
+				ui.firstDeal = p.Piece.Size
+				ui.dedicated = hasDedicatedLabel(cfg, p.DealInfo.Labels) || fillsSector(sector.SectorType, p.Piece.Size.Unpadded())
+				ui.exclusive = exclusiveLabels(cfg, p.DealInfo.Labels)
+			}
+		}
+		ui.stored += p.Piece.Size
+		ui.pieceSizes = append(ui.pieceSizes, p.Piece.Size.Unpadded())
+	}
+
+	return ui, nil
+}
+
 func (m *Sealing) ForceSectorState(ctx context.Context, id abi.SectorNumber, state SectorState) error {
 	return m.sectors.Send(id, SectorForceState{state})
 }
@@ -18,9 +18,9 @@ func (m *Sealing) pledgeSector(ctx context.Context, sectorID storage.SectorRef,
 
 	out := make([]abi.PieceInfo, len(sizes))
 	for i, size := range sizes {
-		ppi, err := m.sealer.AddPiece(ctx, sectorID, existingPieceSizes, size, NewNullReader(size))
+		ppi, err := m.padPiece(ctx, sectorID, existingPieceSizes, size)
 		if err != nil {
-			return nil, xerrors.Errorf("add piece: %w", err)
+			return nil, err
 		}
 
 		existingPieceSizes = append(existingPieceSizes, size)
@@ -86,7 +86,7 @@ func (m *Sealing) PledgeSector() error {
 			}
 		}
 
-		if err := m.newSectorCC(ctx, sid, ps); err != nil {
+		if err := m.newSectorCC(ctx, spt, sid, ps); err != nil {
 			log.Errorf("%+v", err)
 			return
 		}
@@ -0,0 +1,142 @@
+package sealing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+var ErrTooManyPendingPieces = xerrors.New("too many pieces waiting to be added to a sector")
+
+// pieceQueue does admission control for AddPieceToAnySector, so that callers
+// get an error instead of piling up behind the sector allocation lock
+type pieceQueue struct {
+	lk sync.Mutex
+
+	next    uint64
+	pending map[uint64]queuedPiece
+
+	rejected uint64
+	timedOut uint64
+
+	// held while a piece is being allocated to and written into a sector
+	slot chan struct{}
+}
+
+type queuedPiece struct {
+	since time.Time
+	deal  *DealInfo // nil for pieces not in deals
+}
+
+func newPieceQueue() *pieceQueue {
+	return &pieceQueue{
+		pending: map[uint64]queuedPiece{},
+		slot:    make(chan struct{}, 1),
+	}
+}
+
+// admitPiece registers a new pending piece, returning the context bounding how
+// long the piece can wait for a sector, and a func to call once it's done
+func (m *Sealing) admitPiece(ctx context.Context, deal *DealInfo) (context.Context, func(), error) {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return nil, nil, xerrors.Errorf("getting config: %w", err)
+	}
+
+	q := m.pieceQueue
+
+	q.lk.Lock()
+	if cfg.MaxPendingPieces > 0 && uint64(len(q.pending)) >= cfg.MaxPendingPieces {
+		q.rejected++
+		q.lk.Unlock()
+		return nil, nil, ErrTooManyPendingPieces
+	}
+
+	id := q.next
+	q.next++
+	q.pending[id] = queuedPiece{since: time.Now(), deal: deal}
+	q.lk.Unlock()
+
+	waitCtx, cancel := ctx, context.CancelFunc(func() {})
+	if cfg.PendingPieceTimeout > 0 {
+		waitCtx, cancel = context.WithTimeout(ctx, cfg.PendingPieceTimeout)
+	}
+
+	return waitCtx, func() {
+		cancel()
+
+		q.lk.Lock()
+		delete(q.pending, id)
+		q.lk.Unlock()
+	}, nil
+}
+
+func (q *pieceQueue) acquireSlot(ctx context.Context) error {
+	select {
+	case q.slot <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *pieceQueue) releaseSlot() {
+	<-q.slot
+}
+
+// timeout records a piece which gave up waiting for a sector
+func (q *pieceQueue) timeout() {
+	q.lk.Lock()
+	q.timedOut++
+	q.lk.Unlock()
+}
+
+func (m *Sealing) AddPieceQueue() (api.AddPieceQueueInfo, error) {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return api.AddPieceQueueInfo{}, xerrors.Errorf("getting config: %w", err)
+	}
+
+	q := m.pieceQueue
+
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	out := api.AddPieceQueueInfo{
+		Pending:    uint64(len(q.pending)),
+		MaxPending: cfg.MaxPendingPieces,
+		Rejected:   q.rejected,
+		TimedOut:   q.timedOut,
+	}
+
+	for _, p := range q.pending {
+		if wait := time.Since(p.since); wait > out.OldestWait {
+			out.OldestWait = wait
+		}
+	}
+
+	return out, nil
+}
+
+// QueuedDeals returns the deals of the pieces waiting to be added to a sector
+func (m *Sealing) QueuedDeals() []abi.DealID {
+	q := m.pieceQueue
+
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	var out []abi.DealID
+	for _, p := range q.pending {
+		if p.deal != nil {
+			out = append(out, p.deal.DealID)
+		}
+	}
+
+	return out
+}
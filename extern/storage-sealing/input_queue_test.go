@@ -0,0 +1,64 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+func TestAdmitPiece(t *testing.T) {
+	m := &Sealing{
+		pieceQueue: newPieceQueue(),
+		getConfig: func() (sealiface.Config, error) {
+			return sealiface.Config{
+				MaxPendingPieces:    2,
+				PendingPieceTimeout: 10 * time.Millisecond,
+			}, nil
+		},
+	}
+
+	ctx := context.Background()
+
+	wait1, done1, err := m.admitPiece(ctx, &DealInfo{DealID: 7})
+	require.NoError(t, err)
+	_, done2, err := m.admitPiece(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, []abi.DealID{7}, m.QueuedDeals())
+
+	_, _, err = m.admitPiece(ctx, nil)
+	require.Equal(t, ErrTooManyPendingPieces, err)
+
+	require.NoError(t, m.pieceQueue.acquireSlot(wait1))
+
+	// the queue is still full
+	wait3, done3, err := m.admitPiece(ctx, nil)
+	require.Error(t, err)
+	require.Nil(t, wait3)
+	require.Nil(t, done3)
+
+	done2()
+	wait3, done3, err = m.admitPiece(ctx, nil)
+	require.NoError(t, err)
+
+	// the slot is taken by the first piece, so the new piece times out
+	require.Error(t, m.pieceQueue.acquireSlot(wait3))
+	m.pieceQueue.timeout()
+	done3()
+
+	m.pieceQueue.releaseSlot()
+	done1()
+
+	q, err := m.AddPieceQueue()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), q.Pending)
+	require.Equal(t, uint64(2), q.MaxPending)
+	require.Equal(t, uint64(2), q.Rejected)
+	require.Equal(t, uint64(1), q.TimedOut)
+	require.Empty(t, m.QueuedDeals())
+}
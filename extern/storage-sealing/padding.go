@@ -0,0 +1,29 @@
+package sealing
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+// padPiece adds a padding piece of the given size after existingPieceSizes in
+// the sector. The padding is added with a NullReader, which workers recognize:
+// they mark the range as allocated in the unsealed file without writing it,
+// and take the commitment from the precomputed zero piece commitments, so no
+// bytes are streamed over RPC or hashed.
+//
+// Padding piece commitments aren't cached: the AddPiece call can't be skipped,
+// as the worker must mark the range as allocated for the unsealed file to be
+// sealed and read correctly, and with the precomputed commitments a cache
+// wouldn't save any work.
+func (m *Sealing) padPiece(ctx context.Context, sector storage.SectorRef, existingPieceSizes []abi.UnpaddedPieceSize, size abi.UnpaddedPieceSize) (abi.PieceInfo, error) {
+	ppi, err := m.sealer.AddPiece(ctx, sector, existingPieceSizes, size, NewNullReader(size))
+	if err != nil {
+		return abi.PieceInfo{}, xerrors.Errorf("add padding piece: %w", err)
+	}
+
+	return ppi, nil
+}
@@ -0,0 +1,36 @@
+package sealing
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+)
+
+// RegenerateCache rebuilds lost or corrupted cache files of a sealed sector.
+// This can take as long as sealing the sector, the work is scheduled as the
+// lowest priority sealing task.
+func (m *Sealing) RegenerateCache(ctx context.Context, sid abi.SectorNumber) error {
+	si, err := m.GetSectorInfo(sid)
+	if err != nil {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	switch si.State {
+	case Proving, Faulty, FaultReported, FaultedFinal:
+	default:
+		return xerrors.Errorf("can only regenerate cache of sealed sectors, sector %d is in %s", sid, si.State)
+	}
+
+	if si.CommR == nil {
+		return xerrors.Errorf("sector %d has no sealed CID", sid)
+	}
+
+	log.Infow("regenerating sector cache", "sector", sid, "state", si.State)
+
+	if err := m.sealer.RegenerateCache(ctx, m.minerSector(si.SectorType, sid), si.TicketValue, si.pieceInfos(), *si.CommR); err != nil {
+		return xerrors.Errorf("regenerating cache of sector %d: %w", sid, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package sealing
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ReplicaCheckInterval is how often proving sectors are checked for missing
+// replicas when SectorReplicas is set
+var ReplicaCheckInterval = 30 * time.Minute
+
+func (m *Sealing) runReplicaRepair(ctx context.Context) {
+	t := time.NewTicker(ReplicaCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := m.repairReplicas(ctx); err != nil {
+				log.Errorf("repairing sector replicas: %+v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Sealing) repairReplicas(ctx context.Context) error {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return xerrors.Errorf("getting sealing config: %w", err)
+	}
+
+	if cfg.SectorReplicas <= 1 {
+		return nil
+	}
+
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	var failed int
+	for _, sector := range sectors {
+		if sector.State != Proving {
+			continue
+		}
+
+		if err := m.sealer.ReplicateSector(ctx, m.minerSector(sector.SectorType, sector.SectorNumber), int(cfg.SectorReplicas)); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			log.Warnw("replicating sector", "sector", sector.SectorNumber, "error", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return xerrors.Errorf("%d sectors don't have %d replicas", failed, cfg.SectorReplicas)
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package sealing
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	padreader "github.com/filecoin-project/go-padreader"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
+)
+
+// used when PieceLeaseTimeout isn't set
+const defaultPieceLeaseTimeout = 30 * time.Minute
+
+var ErrLeaseNotFound = xerrors.New("piece lease not found or expired")
+
+// pieceLeases tracks space reserved in deal sectors by ReservePiece for pieces
+// whose data wasn't written yet. A sector with an outstanding lease isn't
+// offered to other pieces and doesn't start packing until the lease is
+// committed or expires, so the reserved offset stays valid. Guarded by
+// unsealedInfoMap.lk.
+type pieceLeases struct {
+	next     uint64
+	leases   map[uint64]*pieceLease
+	bySector map[abi.SectorNumber]uint64
+
+	// sectors which were asked to start packing while leased
+	packAfter map[abi.SectorNumber]struct{}
+}
+
+type pieceLease struct {
+	sector abi.SectorNumber
+	size   abi.UnpaddedPieceSize
+	pads   []abi.PaddedPieceSize
+	offset abi.PaddedPieceSize
+	deal   DealInfo
+
+	expires time.Time
+	// set while the piece data is being written, the lease doesn't expire
+	committing bool
+}
+
+func newPieceLeases() *pieceLeases {
+	return &pieceLeases{
+		leases:    map[uint64]*pieceLease{},
+		bySector:  map[abi.SectorNumber]uint64{},
+		packAfter: map[abi.SectorNumber]struct{}{},
+	}
+}
+
+func (l *pieceLease) info(id uint64) api.PieceLease {
+	return api.PieceLease{
+		ID:      id,
+		Sector:  l.sector,
+		Offset:  l.offset,
+		Size:    l.size,
+		DealID:  l.deal.DealID,
+		Expires: l.expires,
+	}
+}
+
+// ReservePiece allocates space for a deal piece in a sector accepting deals,
+// without writing any data. The piece data must be written with CommitPiece
+// before the returned lease expires, otherwise the space is released.
+func (m *Sealing) ReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d DealInfo) (api.PieceLease, error) {
+	log.Infof("Reserving space for piece of deal %d (publish msg: %s)", d.DealID, d.PublishCid)
+
+	sp, err := m.checkPieceSize(ctx, size)
+	if err != nil {
+		return api.PieceLease{}, err
+	}
+
+	cfg, err := m.getConfig()
+	if err != nil {
+		return api.PieceLease{}, xerrors.Errorf("getting config: %w", err)
+	}
+	ttl := cfg.PieceLeaseTimeout
+	if ttl <= 0 {
+		ttl = defaultPieceLeaseTimeout
+	}
+
+	waitCtx, done, err := m.admitPiece(ctx, &d)
+	if err != nil {
+		return api.PieceLease{}, err
+	}
+	defer done()
+
+	if err := m.pieceQueue.acquireSlot(waitCtx); err != nil {
+		m.pieceQueue.timeout()
+		return api.PieceLease{}, xerrors.Errorf("waiting for other pieces to be added: %w", err)
+	}
+	defer m.pieceQueue.releaseSlot()
+
+	m.unsealedInfoMap.lk.Lock()
+	defer m.unsealedInfoMap.lk.Unlock()
+
+	sid, pads, err := m.getSectorAndPadding(waitCtx, sp, size, d.Labels)
+	if err != nil {
+		if waitCtx.Err() != nil && ctx.Err() == nil {
+			m.pieceQueue.timeout()
+		}
+		return api.PieceLease{}, xerrors.Errorf("getting available sector: %w", err)
+	}
+
+	id, lease := m.leasePiece(sid, size, pads, d, ttl)
+	return lease.info(id), nil
+}
+
+// leasePiece records a lease on the sector. Caller should hold
+// m.unsealedInfoMap.lk
+func (m *Sealing) leasePiece(sid abi.SectorNumber, size abi.UnpaddedPieceSize, pads []abi.PaddedPieceSize, d DealInfo, ttl time.Duration) (uint64, *pieceLease) {
+	offset := m.unsealedInfoMap.infos[sid].stored
+	for _, p := range pads {
+		offset += p
+	}
+
+	l := m.leases
+	id := l.next
+	l.next++
+
+	lease := &pieceLease{
+		sector:  sid,
+		size:    size,
+		pads:    pads,
+		offset:  offset,
+		deal:    d,
+		expires: time.Now().Add(ttl),
+	}
+	l.leases[id] = lease
+	l.bySector[sid] = id
+
+	time.AfterFunc(ttl, func() {
+		m.expireLease(id)
+	})
+
+	return id, lease
+}
+
+func (m *Sealing) expireLease(id uint64) {
+	m.unsealedInfoMap.lk.Lock()
+
+	lease, ok := m.leases.leases[id]
+	if !ok || lease.committing {
+		m.unsealedInfoMap.lk.Unlock()
+		return
+	}
+
+	log.Warnw("piece lease expired before the piece was committed, releasing reserved space", "lease", id, "sector", lease.sector, "deal", lease.deal.DealID)
+	pack := m.releaseLease(id)
+
+	m.unsealedInfoMap.lk.Unlock()
+
+	m.startPackingAfterLease(lease.sector, pack)
+}
+
+// releaseLease removes the lease, returning whether the sector should start
+// packing now. Caller should hold m.unsealedInfoMap.lk
+func (m *Sealing) releaseLease(id uint64) bool {
+	l := m.leases
+
+	lease, ok := l.leases[id]
+	if !ok {
+		return false
+	}
+
+	delete(l.leases, id)
+	delete(l.bySector, lease.sector)
+
+	_, pack := l.packAfter[lease.sector]
+	delete(l.packAfter, lease.sector)
+	return pack
+}
+
+// Caller should NOT hold m.unsealedInfoMap.lk
+func (m *Sealing) startPackingAfterLease(sid abi.SectorNumber, pack bool) {
+	if !pack {
+		return
+	}
+
+	if err := m.StartPacking(sid); err != nil {
+		log.Errorf("starting sector %d after piece lease was released: %+v", sid, err)
+	}
+}
+
+// CommitPiece writes the data of a piece into the space reserved with
+// ReservePiece. If writing fails, the lease is released and the piece has to
+// be reserved again.
+func (m *Sealing) CommitPiece(ctx context.Context, id uint64, r io.Reader) (api.PieceLease, error) {
+	m.unsealedInfoMap.lk.Lock()
+
+	lease, ok := m.leases.leases[id]
+	if !ok || lease.committing {
+		m.unsealedInfoMap.lk.Unlock()
+		return api.PieceLease{}, ErrLeaseNotFound
+	}
+	if time.Now().After(lease.expires) {
+		pack := m.releaseLease(id)
+		m.unsealedInfoMap.lk.Unlock()
+		m.startPackingAfterLease(lease.sector, pack)
+		return api.PieceLease{}, ErrLeaseNotFound
+	}
+
+	lease.committing = true
+	ui := m.unsealedInfoMap.infos[lease.sector]
+
+	// no other pieces are added to the sector while it's leased, so the data
+	// can be written without holding the lock
+	m.unsealedInfoMap.lk.Unlock()
+
+	ppis, err := m.writeLeasedPiece(ctx, ui, lease, r)
+
+	m.unsealedInfoMap.lk.Lock()
+
+	if err == nil {
+		for i, ppi := range ppis {
+			var di *DealInfo
+			if i == len(ppis)-1 {
+				di = &lease.deal
+			}
+			if err = m.recordPiece(lease.sector, ppi, di); err != nil {
+				err = xerrors.Errorf("recording piece: %w", err)
+				break
+			}
+		}
+	}
+
+	pack := m.releaseLease(id)
+	if err == nil {
+		pack = pack || ui.dedicated || m.unsealedInfoMap.infos[lease.sector].numDeals >= getDealPerSectorLimit(ui.ssize)
+	}
+
+	m.unsealedInfoMap.lk.Unlock()
+
+	m.startPackingAfterLease(lease.sector, pack)
+
+	if err != nil {
+		return api.PieceLease{}, err
+	}
+
+	return lease.info(id), nil
+}
+
+// writeLeasedPiece writes the pads and the data of a leased piece into the
+// sector, returning the infos of the written pieces in order
+func (m *Sealing) writeLeasedPiece(ctx context.Context, ui UnsealedSectorInfo, lease *pieceLease, r io.Reader) ([]abi.PieceInfo, error) {
+	ctx = sectorstorage.WithPriority(ctx, DealSectorPriority)
+	sector := m.minerSector(ui.spt, lease.sector)
+
+	sizes := append([]abi.UnpaddedPieceSize{}, ui.pieceSizes...)
+	var out []abi.PieceInfo
+
+	for _, p := range lease.pads {
+		ppi, err := m.padPiece(ctx, sector, sizes, p.Unpadded())
+		if err != nil {
+			return nil, xerrors.Errorf("writing pads: %w", err)
+		}
+		sizes = append(sizes, p.Unpadded())
+		out = append(out, ppi)
+	}
+
+	ppi, err := m.sealer.AddPiece(ctx, sector, sizes, lease.size, r)
+	if err != nil {
+		return nil, xerrors.Errorf("writing piece: %w", err)
+	}
+
+	return append(out, ppi), nil
+}
+
+// checkPieceSize returns the current seal proof type after checking that a
+// piece of the size fits into a sector
+func (m *Sealing) checkPieceSize(ctx context.Context, size abi.UnpaddedPieceSize) (abi.RegisteredSealProof, error) {
+	if padreader.PaddedSize(uint64(size)) != size {
+		return 0, xerrors.Errorf("cannot allocate unpadded piece")
+	}
+
+	sp, err := m.currentSealProof(ctx)
+	if err != nil {
+		return 0, xerrors.Errorf("getting current seal proof type: %w", err)
+	}
+
+	ssize, err := sp.SectorSize()
+	if err != nil {
+		return 0, err
+	}
+
+	if size > abi.PaddedPieceSize(ssize).Unpadded() {
+		return 0, xerrors.Errorf("piece cannot fit into a sector")
+	}
+
+	return sp, nil
+}
@@ -0,0 +1,77 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+func TestPieceLeases(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1
+
+	m := &Sealing{
+		unsealedInfoMap: UnsealedSectorMap{
+			infos: map[abi.SectorNumber]UnsealedSectorInfo{
+				1: {
+					stored:     512,
+					pieceSizes: []abi.UnpaddedPieceSize{abi.PaddedPieceSize(512).Unpadded()},
+					ssize:      2048,
+					spt:        spt,
+				},
+				2: {
+					ssize: 2048,
+					spt:   spt,
+				},
+			},
+		},
+		leases: newPieceLeases(),
+		getConfig: func() (sealiface.Config, error) {
+			return sealiface.Config{}, nil
+		},
+	}
+
+	ctx := context.Background()
+	size := abi.PaddedPieceSize(1024).Unpadded()
+
+	m.unsealedInfoMap.lk.Lock()
+
+	pads, _ := ffiwrapper.GetRequiredPadding(512, size.Padded())
+	id, lease := m.leasePiece(1, size, pads, DealInfo{DealID: 5}, time.Hour)
+	// the piece goes after the padding
+	require.Equal(t, abi.PaddedPieceSize(1024), lease.offset)
+
+	// the leased sector isn't offered to other pieces
+	for i := 0; i < 10; i++ {
+		sid, _, err := m.getSectorAndPadding(ctx, spt, size, nil)
+		require.NoError(t, err)
+		require.Equal(t, abi.SectorNumber(2), sid)
+	}
+
+	m.unsealedInfoMap.lk.Unlock()
+
+	m.expireLease(id)
+
+	_, err := m.CommitPiece(ctx, id, nil)
+	require.Equal(t, ErrLeaseNotFound, err)
+
+	m.unsealedInfoMap.lk.Lock()
+	require.Empty(t, m.leases.leases)
+	require.Empty(t, m.leases.bySector)
+
+	// leases expire on their own
+	_, _ = m.leasePiece(1, size, pads, DealInfo{DealID: 6}, time.Millisecond)
+	m.unsealedInfoMap.lk.Unlock()
+
+	require.Eventually(t, func() bool {
+		m.unsealedInfoMap.lk.Lock()
+		defer m.unsealedInfoMap.lk.Unlock()
+		return len(m.leases.bySector) == 0
+	}, time.Second, time.Millisecond)
+}
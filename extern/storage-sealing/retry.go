@@ -0,0 +1,152 @@
+package sealing
+
+import (
+	"math"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-statemachine"
+
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+const minRetryTime = 1 * time.Minute
+
+// retryStates are failure states which are retried after a cooldown, with the
+// states retrying them goes back to
+var retryStates = map[SectorState][]SectorState{
+	SealPreCommit1Failed: {PreCommit1},
+	SealPreCommit2Failed: {PreCommit1, PreCommit2},
+	PreCommitFailed:      {PreCommitting, PreCommitWait, WaitSeed},
+	ComputeProofFailed:   {Committing},
+	CommitFailed:         {WaitSeed, Committing, PreCommitWait, PreCommitting, CommitWait, SubmitCommit},
+	FinalizeFailed:       {FinalizeSector},
+	CommitFinalizeFailed: {CommitFinalize},
+	TerminateFailed:      {Terminating},
+	RemoveFailed:         {Removing},
+}
+
+// progressStates are reached once a step which can be retried completed, e.g.
+// the precommit landed on chain. Retried steps go through several states
+// before failing again, so other transitions don't mean progress.
+var progressStates = map[SectorState]struct{}{
+	WaitSeed:          {},
+	FinalizeSector:    {},
+	Proving:           {},
+	TerminateFinality: {},
+}
+
+// countRetries tracks consecutive retries from failure states. Going from a
+// failure state back to a state it retries counts as a retry, other ways out
+// of the failure state, e.g. removing the sector, don't; reaching a progress
+// state, other than by retrying, resets the count.
+func countRetries(before SectorState, state *SectorInfo) {
+	if before == state.State {
+		return
+	}
+
+	retried, wasFailed := retryStates[before]
+	_, progress := progressStates[state.State]
+
+	switch {
+	case wasFailed:
+		for _, st := range retried {
+			if st == state.State {
+				state.FailureRetries++
+				break
+			}
+		}
+	case progress:
+		state.FailureRetries = 0
+	}
+}
+
+func retryPolicy(cfg sealiface.Config, st SectorState) sealiface.RetryPolicy {
+	if p, ok := cfg.StateRetryPolicies[string(st)]; ok {
+		return p
+	}
+	return cfg.RetryPolicy
+}
+
+// backoff returns how long to wait before retrying after the given number of
+// consecutive retries
+func backoff(p sealiface.RetryPolicy, retries uint64) time.Duration {
+	d := p.MinBackoff
+	if d <= 0 {
+		d = minRetryTime
+	}
+
+	if p.BackoffFactor > 1 {
+		d = time.Duration(float64(d) * math.Pow(p.BackoffFactor, float64(retries)))
+		if d <= 0 { // overflow
+			d = math.MaxInt64
+		}
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	return d
+}
+
+// NextRetry returns when a sector in a failure state will be retried, zero
+// if it isn't waiting to be retried
+func (m *Sealing) NextRetry(sector SectorInfo) (time.Time, error) {
+	if _, ok := retryStates[sector.State]; !ok || len(sector.Log) == 0 {
+		return time.Time{}, nil
+	}
+
+	cfg, err := m.getConfig()
+	if err != nil {
+		return time.Time{}, xerrors.Errorf("getting sealing config: %w", err)
+	}
+
+	p := retryPolicy(cfg, sector.State)
+	if p.MaxRetries > 0 && sector.FailureRetries >= p.MaxRetries {
+		return time.Time{}, nil
+	}
+
+	return retryStart(p, sector), nil
+}
+
+func retryStart(p sealiface.RetryPolicy, sector SectorInfo) time.Time {
+	return time.Unix(int64(sector.Log[len(sector.Log)-1].Timestamp), 0).Add(backoff(p, sector.FailureRetries))
+}
+
+func (m *Sealing) failedCooldown(ctx statemachine.Context, sector SectorInfo) error {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return xerrors.Errorf("getting sealing config: %w", err)
+	}
+
+	p := retryPolicy(cfg, sector.State)
+
+	if p.MaxRetries > 0 && sector.FailureRetries >= p.MaxRetries {
+		if p.GiveUp == sealiface.GiveUpRemove && sector.State != RemoveFailed {
+			log.Warnf("%s(%d): giving up after %d retries, removing sector", sector.State, sector.SectorNumber, sector.FailureRetries)
+			if err := ctx.Send(SectorRemove{}); err != nil {
+				return err
+			}
+		}
+
+		return xerrors.Errorf("%s(%d): not retrying after %d consecutive retries, needs manual action", sector.State, sector.SectorNumber, sector.FailureRetries)
+	}
+
+	if len(sector.Log) == 0 {
+		return nil
+	}
+
+	start := retryStart(p, sector)
+	if !time.Now().After(start) {
+		log.Infof("%s(%d), waiting %s before retrying", sector.State, sector.SectorNumber, time.Until(start))
+		select {
+		case <-time.After(time.Until(start)):
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package sealing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+func TestBackoff(t *testing.T) {
+	p := sealiface.RetryPolicy{
+		MinBackoff:    time.Minute,
+		MaxBackoff:    10 * time.Minute,
+		BackoffFactor: 2,
+	}
+
+	require.Equal(t, time.Minute, backoff(p, 0))
+	require.Equal(t, 2*time.Minute, backoff(p, 1))
+	require.Equal(t, 8*time.Minute, backoff(p, 3))
+	require.Equal(t, 10*time.Minute, backoff(p, 4))
+	require.Equal(t, 10*time.Minute, backoff(p, 1000))
+
+	// no factor means a constant backoff
+	require.Equal(t, minRetryTime, backoff(sealiface.RetryPolicy{}, 5))
+}
+
+func TestCountRetries(t *testing.T) {
+	si := &SectorInfo{State: PreCommit1}
+
+	step := func(to SectorState) {
+		before := si.State
+		si.State = to
+		countRetries(before, si)
+	}
+
+	step(SealPreCommit1Failed)
+	require.Equal(t, uint64(0), si.FailureRetries)
+
+	step(PreCommit1)
+	require.Equal(t, uint64(1), si.FailureRetries)
+
+	step(SealPreCommit1Failed)
+	step(PreCommit1)
+	require.Equal(t, uint64(2), si.FailureRetries)
+
+	// retried steps failing again after a few states are still counted
+	step(PreCommit2)
+	step(PreCommitting)
+	step(PreCommitWait)
+	step(PreCommitFailed)
+	step(PreCommitting)
+	require.Equal(t, uint64(3), si.FailureRetries)
+
+	// making progress resets the count
+	step(PreCommitWait)
+	step(WaitSeed)
+	require.Equal(t, uint64(0), si.FailureRetries)
+
+	// retrying into a progress state isn't progress
+	step(Committing)
+	step(CommitWait)
+	step(FinalizeSector)
+	step(FinalizeFailed)
+	step(FinalizeSector)
+	require.Equal(t, uint64(1), si.FailureRetries)
+
+	step(Proving)
+	require.Equal(t, uint64(0), si.FailureRetries)
+
+	// leaving a failure state other than by retrying isn't a retry
+	step(Committing)
+	step(CommitFailed)
+	step(Removing)
+	require.Equal(t, uint64(0), si.FailureRetries)
+
+	step(RemoveFailed)
+	step(Removing)
+	require.Equal(t, uint64(1), si.FailureRetries)
+}
@@ -1,6 +1,10 @@
 package sealiface
 
-import "time"
+import (
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
 
 // this has to be in a separate package to not make lotus API depend on filecoin-ffi
 
@@ -15,4 +19,71 @@ type Config struct {
 	MaxSealingSectorsForDeals uint64
 
 	WaitDealsDelay time.Duration
+
+	// override WaitDealsDelay, the first matching rule applies
+	WaitDealsDelays []WaitDealsDelayRule
+
+	// deal pieces only share sectors with pieces with the same exclusive
+	// labels
+	ExclusiveLabels []string
+	// deal pieces with a dedicated label get a sector of their own
+	DedicatedLabels []string
+
+	// pieces waiting to be added to a sector, 0 = no limit
+	MaxPendingPieces uint64
+
+	// how long a piece can wait for a sector, 0 = no limit
+	PendingPieceTimeout time.Duration
+
+	// how long space reserved for a piece is held until the piece data is
+	// committed, 0 = default
+	PieceLeaseTimeout time.Duration
+
+	RemoveExpiredSectors bool
+
+	// 0 or 1 = no replication
+	SectorReplicas uint64
+
+	ManageUnsealedCopies bool
+
+	FinalizeEarly bool
+
+	// applies to failure states without an entry in StateRetryPolicies
+	RetryPolicy RetryPolicy
+
+	// keyed by sector state name
+	StateRetryPolicies map[string]RetryPolicy
+}
+
+type WaitDealsDelayRule struct {
+	// 0 = any sector size
+	SectorSize abi.SectorSize
+
+	// share of the sector the first deal piece fills, 0 = the rule also
+	// applies before a deal is added
+	FirstPieceMinFill float64
+
+	Delay time.Duration
+}
+
+const (
+	// GiveUpWait leaves the sector in the failure state until the state is
+	// changed manually
+	GiveUpWait = "wait"
+	// GiveUpRemove removes the sector
+	GiveUpRemove = "remove"
+)
+
+type RetryPolicy struct {
+	// consecutive retries after which the GiveUp action is taken, 0 = no limit
+	MaxRetries uint64
+
+	// wait before the first retry, multiplied by BackoffFactor with each
+	// consecutive retry, up to MaxBackoff (0 = no limit)
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+	BackoffFactor float64
+
+	// GiveUpWait (default) or GiveUpRemove
+	GiveUp string
 }
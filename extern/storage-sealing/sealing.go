@@ -5,6 +5,8 @@ import (
 	"errors"
 	"io"
 	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,7 +17,6 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
-	padreader "github.com/filecoin-project/go-padreader"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/crypto"
@@ -29,6 +30,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
 )
 
 const SectorStorePrefix = "/sectors"
@@ -98,6 +100,9 @@ type Sealing struct {
 	stats SectorStats
 
 	terminator *TerminateBatcher
+	pieceQueue *pieceQueue
+	leases     *pieceLeases // guarded by unsealedInfoMap.lk
+	aborts     *abortTracker
 
 	getConfig GetSealingConfigFunc
 }
@@ -119,6 +124,20 @@ type UnsealedSectorInfo struct {
 	stored     abi.PaddedPieceSize
 	pieceSizes []abi.UnpaddedPieceSize
 	ssize      abi.SectorSize
+	// sectors of different proof types can be open at the same time, e.g.
+	// when the preferred proof type changes in a network upgrade
+	spt abi.RegisteredSealProof
+	// dedicated sectors were created for a single piece, which filled the
+	// sector or had a dedicated label, and start packing as soon as it's added
+	dedicated bool
+	// exclusive labels of the deal pieces in the sector
+	exclusive string
+
+	// the sector starts packing when packTimer fires, after waiting for deals
+	// since waitSince. The wait depends on the size of the first deal piece.
+	waitSince time.Time
+	firstDeal abi.PaddedPieceSize
+	packTimer *time.Timer
 }
 
 func New(api SealingAPI, fc FeeConfig, events Events, maddr address.Address, ds datastore.Batching, sealer sectorstorage.SectorManager, sc SectorIDCounter, verif ffiwrapper.Verifier, pcp PreCommitPolicy, gc GetSealingConfigFunc, notifee SectorStateNotifee, as AddrSel) *Sealing {
@@ -143,6 +162,9 @@ func New(api SealingAPI, fc FeeConfig, events Events, maddr address.Address, ds
 		addrSel: as,
 
 		terminator: NewTerminationBatcher(context.TODO(), maddr, api, as, fc),
+		pieceQueue: newPieceQueue(),
+		leases:     newPieceLeases(),
+		aborts:     newAbortTracker(namespace.Wrap(ds, datastore.NewKey(AbortedSectorStorePrefix))),
 
 		getConfig: gc,
 
@@ -159,11 +181,20 @@ func New(api SealingAPI, fc FeeConfig, events Events, maddr address.Address, ds
 }
 
 func (m *Sealing) Run(ctx context.Context) error {
+	// aborted sectors must not start packing when restarted
+	if err := m.aborts.load(); err != nil {
+		return xerrors.Errorf("loading aborted sectors: %w", err)
+	}
+
 	if err := m.restartSectors(ctx); err != nil {
 		log.Errorf("%+v", err)
 		return xerrors.Errorf("failed load sector states: %w", err)
 	}
 
+	go m.runExpiredSectorCleanup(ctx)
+	go m.runReplicaRepair(ctx)
+	go m.runUnsealedCheck(ctx)
+
 	return nil
 }
 
@@ -180,34 +211,41 @@ func (m *Sealing) Stop(ctx context.Context) error {
 
 func (m *Sealing) AddPieceToAnySector(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d DealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error) {
 	log.Infof("Adding piece for deal %d (publish msg: %s)", d.DealID, d.PublishCid)
-	if (padreader.PaddedSize(uint64(size))) != size {
-		return 0, 0, xerrors.Errorf("cannot allocate unpadded piece")
+	sp, err := m.checkPieceSize(ctx, size)
+	if err != nil {
+		return 0, 0, err
 	}
 
-	sp, err := m.currentSealProof(ctx)
+	ssize, err := sp.SectorSize()
 	if err != nil {
-		return 0, 0, xerrors.Errorf("getting current seal proof type: %w", err)
+		return 0, 0, err
 	}
 
-	ssize, err := sp.SectorSize()
+	waitCtx, done, err := m.admitPiece(ctx, &d)
 	if err != nil {
 		return 0, 0, err
 	}
+	defer done()
 
-	if size > abi.PaddedPieceSize(ssize).Unpadded() {
-		return 0, 0, xerrors.Errorf("piece cannot fit into a sector")
+	if err := m.pieceQueue.acquireSlot(waitCtx); err != nil {
+		m.pieceQueue.timeout()
+		return 0, 0, xerrors.Errorf("waiting for other pieces to be added: %w", err)
 	}
+	defer m.pieceQueue.releaseSlot()
 
 	m.unsealedInfoMap.lk.Lock()
 
-	sid, pads, err := m.getSectorAndPadding(ctx, size)
+	sid, pads, err := m.getSectorAndPadding(waitCtx, sp, size, d.Labels)
 	if err != nil {
 		m.unsealedInfoMap.lk.Unlock()
+		if waitCtx.Err() != nil && ctx.Err() == nil {
+			m.pieceQueue.timeout()
+		}
 		return 0, 0, xerrors.Errorf("getting available sector: %w", err)
 	}
 
 	for _, p := range pads {
-		err = m.addPiece(ctx, sid, p.Unpadded(), NewNullReader(p.Unpadded()), nil)
+		err = m.addPad(ctx, sid, p.Unpadded())
 		if err != nil {
 			m.unsealedInfoMap.lk.Unlock()
 			return 0, 0, xerrors.Errorf("writing pads: %w", err)
@@ -218,11 +256,13 @@ func (m *Sealing) AddPieceToAnySector(ctx context.Context, size abi.UnpaddedPiec
 	err = m.addPiece(ctx, sid, size, r, &d)
 
 	if err != nil {
+		m.pieceFailed(sid)
 		m.unsealedInfoMap.lk.Unlock()
 		return 0, 0, xerrors.Errorf("adding piece to sector: %w", err)
 	}
 
-	startPacking := m.unsealedInfoMap.infos[sid].numDeals >= getDealPerSectorLimit(ssize)
+	ui := m.unsealedInfoMap.infos[sid]
+	startPacking := ui.dedicated || ui.numDeals >= getDealPerSectorLimit(ssize)
 
 	m.unsealedInfoMap.lk.Unlock()
 
@@ -238,39 +278,130 @@ func (m *Sealing) AddPieceToAnySector(ctx context.Context, size abi.UnpaddedPiec
 // Caller should hold m.unsealedInfoMap.lk
 func (m *Sealing) addPiece(ctx context.Context, sectorID abi.SectorNumber, size abi.UnpaddedPieceSize, r io.Reader, di *DealInfo) error {
 	log.Infof("Adding piece to sector %d", sectorID)
-	sp, err := m.currentSealProof(ctx)
+	ui := m.unsealedInfoMap.infos[sectorID]
+
+	ppi, err := m.sealer.AddPiece(sectorstorage.WithPriority(ctx, DealSectorPriority), m.minerSector(ui.spt, sectorID), ui.pieceSizes, size, r)
 	if err != nil {
-		return xerrors.Errorf("getting current seal proof type: %w", err)
+		return xerrors.Errorf("writing piece: %w", err)
 	}
-	ssize, err := sp.SectorSize()
+
+	return m.recordPiece(sectorID, ppi, di)
+}
+
+func (m *Sealing) addPad(ctx context.Context, sectorID abi.SectorNumber, size abi.UnpaddedPieceSize) error {
+	ui := m.unsealedInfoMap.infos[sectorID]
+
+	ppi, err := m.padPiece(sectorstorage.WithPriority(ctx, DealSectorPriority), m.minerSector(ui.spt, sectorID), ui.pieceSizes, size)
 	if err != nil {
 		return err
 	}
 
-	ppi, err := m.sealer.AddPiece(sectorstorage.WithPriority(ctx, DealSectorPriority), m.minerSector(sp, sectorID), m.unsealedInfoMap.infos[sectorID].pieceSizes, size, r)
-	if err != nil {
-		return xerrors.Errorf("writing piece: %w", err)
-	}
+	return m.recordPiece(sectorID, ppi, nil)
+}
+
+// recordPiece adds the piece to the sector info, and to the unsealed sector
+// map. Must be called with unsealedInfoMap.lk held.
+func (m *Sealing) recordPiece(sectorID abi.SectorNumber, ppi abi.PieceInfo, di *DealInfo) error {
+	ui := m.unsealedInfoMap.infos[sectorID]
+
 	piece := Piece{
 		Piece:    ppi,
 		DealInfo: di,
 	}
 
-	err = m.sectors.Send(uint64(sectorID), SectorAddPiece{NewPiece: piece})
+	err := m.sectors.Send(uint64(sectorID), SectorAddPiece{NewPiece: piece})
 	if err != nil {
 		return err
 	}
 
-	ui := m.unsealedInfoMap.infos[sectorID]
-	num := m.unsealedInfoMap.infos[sectorID].numDeals
+	first := false
 	if di != nil {
-		num = num + 1
+		ui.numDeals++
+		if ui.firstDeal == 0 {
+			ui.firstDeal = piece.Piece.Size
+			first = true
+		}
+	}
+	ui.stored += piece.Piece.Size
+	ui.pieceSizes = append(ui.pieceSizes, piece.Piece.Size.Unpadded())
+	m.unsealedInfoMap.infos[sectorID] = ui
+
+	if first {
+		// the first deal can make a different delay apply
+		cfg, err := m.getConfig()
+		if err != nil {
+			return xerrors.Errorf("getting config: %w", err)
+		}
+		m.startWaitDealsTimer(sectorID, cfg)
 	}
-	m.unsealedInfoMap.infos[sectorID] = UnsealedSectorInfo{
-		numDeals:   num,
-		stored:     ui.stored + piece.Piece.Size,
-		pieceSizes: append(ui.pieceSizes, piece.Piece.Size.Unpadded()),
-		ssize:      ssize,
+
+	return nil
+}
+
+// waitDealsDelay returns how long a sector waits for deals before it starts
+// packing. firstDeal is the size of the first deal piece in the sector, 0 if
+// there's none yet.
+func waitDealsDelay(cfg sealiface.Config, ssize abi.SectorSize, firstDeal abi.PaddedPieceSize) time.Duration {
+	for _, r := range cfg.WaitDealsDelays {
+		if r.SectorSize != 0 && r.SectorSize != ssize {
+			continue
+		}
+		if r.FirstPieceMinFill > 0 && float64(firstDeal) < r.FirstPieceMinFill*float64(ssize) {
+			continue
+		}
+		return r.Delay
+	}
+
+	return cfg.WaitDealsDelay
+}
+
+// startWaitDealsTimer (re)sets the timer starting packing of the sector once
+// it waited for deals long enough. Dedicated sectors don't wait, and start
+// packing once their piece is added. Caller should hold m.unsealedInfoMap.lk
+func (m *Sealing) startWaitDealsTimer(sid abi.SectorNumber, cfg sealiface.Config) {
+	ui, ok := m.unsealedInfoMap.infos[sid]
+	if !ok || (ui.dedicated && ui.numDeals == 0) {
+		return
+	}
+
+	if ui.packTimer != nil {
+		ui.packTimer.Stop()
+		ui.packTimer = nil
+	}
+
+	delay := waitDealsDelay(cfg, ui.ssize, ui.firstDeal)
+	if delay > 0 || ui.dedicated {
+		wait := time.Until(ui.waitSince.Add(delay))
+		if ui.dedicated {
+			// the piece was added, but the sector didn't start packing, e.g.
+			// because the node restarted
+			wait = 0
+		}
+
+		ui.packTimer = time.AfterFunc(wait, func() {
+			if err := m.StartPacking(sid); err != nil {
+				log.Errorf("starting sector %d: %+v", sid, err)
+			}
+		})
+	}
+
+	m.unsealedInfoMap.infos[sid] = ui
+}
+
+// UpdateWaitDealsTimers re-arms the timers of the sectors waiting for deals
+// with the current config, so that changed wait deals delays apply to the
+// sectors which are already open
+func (m *Sealing) UpdateWaitDealsTimers() error {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return xerrors.Errorf("getting config: %w", err)
+	}
+
+	m.unsealedInfoMap.lk.Lock()
+	defer m.unsealedInfoMap.lk.Unlock()
+
+	for sid := range m.unsealedInfoMap.infos {
+		m.startWaitDealsTimer(sid, cfg)
 	}
 
 	return nil
@@ -292,6 +423,28 @@ func (m *Sealing) TerminatePending(ctx context.Context) ([]abi.SectorID, error)
 	return m.terminator.Pending(ctx)
 }
 
+// pieceFailed opens a dedicated sector to other pieces when its piece couldn't
+// be added, and starts waiting for deals, so that it isn't left open forever.
+// Caller should hold m.unsealedInfoMap.lk
+func (m *Sealing) pieceFailed(sid abi.SectorNumber) {
+	ui, ok := m.unsealedInfoMap.infos[sid]
+	if !ok || !ui.dedicated || ui.numDeals > 0 {
+		return
+	}
+
+	cfg, err := m.getConfig()
+	if err != nil {
+		log.Errorf("getting config for sector %d: %+v", sid, err)
+		return
+	}
+
+	ui.dedicated = false
+	ui.waitSince = time.Now()
+	m.unsealedInfoMap.infos[sid] = ui
+
+	m.startWaitDealsTimer(sid, cfg)
+}
+
 // Caller should NOT hold m.unsealedInfoMap.lk
 func (m *Sealing) StartPacking(sectorID abi.SectorNumber) error {
 	// locking here ensures that when the SectorStartPacking event is sent, the sector won't be picked up anywhere else
@@ -299,10 +452,16 @@ func (m *Sealing) StartPacking(sectorID abi.SectorNumber) error {
 	defer m.unsealedInfoMap.lk.Unlock()
 
 	// cannot send SectorStartPacking to sectors that have already been packed, otherwise it will cause the state machine to exit
-	if _, ok := m.unsealedInfoMap.infos[sectorID]; !ok {
+	ui, ok := m.unsealedInfoMap.infos[sectorID]
+	if !ok {
 		log.Warnf("call start packing, but sector %v not in unsealedInfoMap.infos, maybe have called", sectorID)
 		return nil
 	}
+	if _, leased := m.leases.bySector[sectorID]; leased {
+		log.Infof("sector %d has space reserved for a piece, will start packing once the lease is released", sectorID)
+		m.leases.packAfter[sectorID] = struct{}{}
+		return nil
+	}
 	log.Infof("Starting packing sector %d", sectorID)
 	err := m.sectors.Send(uint64(sectorID), SectorStartPacking{})
 	if err != nil {
@@ -310,15 +469,34 @@ func (m *Sealing) StartPacking(sectorID abi.SectorNumber) error {
 	}
 	log.Infof("send Starting packing event success sector %d", sectorID)
 
+	if ui.packTimer != nil {
+		ui.packTimer.Stop()
+	}
 	delete(m.unsealedInfoMap.infos, sectorID)
 
 	return nil
 }
 
 // Caller should hold m.unsealedInfoMap.lk
-func (m *Sealing) getSectorAndPadding(ctx context.Context, size abi.UnpaddedPieceSize) (abi.SectorNumber, []abi.PaddedPieceSize, error) {
+func (m *Sealing) getSectorAndPadding(ctx context.Context, spt abi.RegisteredSealProof, size abi.UnpaddedPieceSize, labels []string) (abi.SectorNumber, []abi.PaddedPieceSize, error) {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return 0, nil, xerrors.Errorf("getting config: %w", err)
+	}
+
+	exclusive := exclusiveLabels(cfg, labels)
+	labelDedicated := hasDedicatedLabel(cfg, labels)
+
 	for tries := 0; tries < 100; tries++ {
 		for k, v := range m.unsealedInfoMap.infos {
+			// pieces with a dedicated label never share a sector
+			if labelDedicated || v.spt != spt || v.dedicated || v.exclusive != exclusive {
+				continue
+			}
+			if _, leased := m.leases.bySector[k]; leased {
+				continue
+			}
+
 			pads, padLength := ffiwrapper.GetRequiredPadding(v.stored, size.Padded())
 
 			if v.stored+size.Padded()+padLength <= abi.PaddedPieceSize(v.ssize) {
@@ -330,7 +508,16 @@ func (m *Sealing) getSectorAndPadding(ctx context.Context, size abi.UnpaddedPiec
 			log.Infow("tried to put a piece into an open sector, found none with enough space", "open", len(m.unsealedInfoMap.infos), "size", size, "tries", tries)
 		}
 
-		ns, ssize, err := m.newDealSector(ctx)
+		// pieces filling a sector, or with a dedicated label, get a sector of
+		// their own. Large pieces get a new sector right away instead of
+		// waiting for the open sectors to be packed, which with many small
+		// deals coming in can take a long time.
+		dedicated := labelDedicated || fillsSector(spt, size)
+		if dedicated {
+			log.Infow("creating a dedicated sector for a piece", "size", size, "labels", labels)
+		}
+
+		ns, ssize, err := m.newDealSector(ctx, spt, dedicated || isLargePiece(spt, size))
 		switch err {
 		case nil:
 			m.unsealedInfoMap.infos[ns] = UnsealedSectorInfo{
@@ -338,7 +525,13 @@ func (m *Sealing) getSectorAndPadding(ctx context.Context, size abi.UnpaddedPiec
 				stored:     0,
 				pieceSizes: nil,
 				ssize:      ssize,
+				spt:        spt,
+				dedicated:  dedicated,
+				exclusive:  exclusive,
+				waitSince:  time.Now(),
 			}
+
+			m.startWaitDealsTimer(ns, cfg)
 		case errTooManySealing:
 			m.unsealedInfoMap.lk.Unlock()
 
@@ -363,8 +556,57 @@ func (m *Sealing) getSectorAndPadding(ctx context.Context, size abi.UnpaddedPiec
 
 var errTooManySealing = errors.New("too many sectors sealing")
 
-// newDealSector creates a new sector for deal storage
-func (m *Sealing) newDealSector(ctx context.Context) (abi.SectorNumber, abi.SectorSize, error) {
+// exclusiveLabels returns the labels of a piece which are exclusive in the
+// config, as a key. Pieces only share sectors with pieces with the same key.
+func exclusiveLabels(cfg sealiface.Config, labels []string) string {
+	var out []string
+	for _, l := range labels {
+		for _, e := range cfg.ExclusiveLabels {
+			if l == e {
+				out = append(out, l)
+				break
+			}
+		}
+	}
+
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+func hasDedicatedLabel(cfg sealiface.Config, labels []string) bool {
+	for _, l := range labels {
+		for _, d := range cfg.DedicatedLabels {
+			if l == d {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isLargePiece returns whether the piece takes at least half of a sector, so
+// it doesn't fit in sectors already holding other deals most of the time
+func isLargePiece(spt abi.RegisteredSealProof, size abi.UnpaddedPieceSize) bool {
+	ssize, err := spt.SectorSize()
+	if err != nil {
+		return false
+	}
+	return size.Padded() >= abi.PaddedPieceSize(ssize)/2
+}
+
+// fillsSector returns whether the piece takes a whole sector
+func fillsSector(spt abi.RegisteredSealProof, size abi.UnpaddedPieceSize) bool {
+	ssize, err := spt.SectorSize()
+	if err != nil {
+		return false
+	}
+	return size.Padded() == abi.PaddedPieceSize(ssize)
+}
+
+// newDealSector creates a new sector for deal storage. Sectors created right
+// away, for dedicated and large pieces, don't count against
+// MaxWaitDealsSectors.
+func (m *Sealing) newDealSector(ctx context.Context, spt abi.RegisteredSealProof, immediate bool) (abi.SectorNumber, abi.SectorSize, error) {
 	// First make sure we don't have too many 'open' sectors
 
 	cfg, err := m.getConfig()
@@ -378,7 +620,7 @@ func (m *Sealing) newDealSector(ctx context.Context) (abi.SectorNumber, abi.Sect
 		}
 	}
 
-	if cfg.MaxWaitDealsSectors > 0 && uint64(len(m.unsealedInfoMap.infos)) >= cfg.MaxWaitDealsSectors {
+	if !immediate && cfg.MaxWaitDealsSectors > 0 && uint64(len(m.unsealedInfoMap.infos)) >= cfg.MaxWaitDealsSectors {
 		// Too many sectors are sealing in parallel. Start sealing one, and retry
 		// allocating the piece to a sector (we're dropping the lock here, so in
 		// case other goroutines are also trying to create a sector, we retry in
@@ -408,11 +650,6 @@ func (m *Sealing) newDealSector(ctx context.Context) (abi.SectorNumber, abi.Sect
 		return 0, 0, errTooManySealing // will wait a bit and retry
 	}
 
-	spt, err := m.currentSealProof(ctx)
-	if err != nil {
-		return 0, 0, xerrors.Errorf("getting current seal proof type: %w", err)
-	}
-
 	// Now actually create a new sector
 
 	sid, err := m.sc.Next()
@@ -435,32 +672,12 @@ func (m *Sealing) newDealSector(ctx context.Context) (abi.SectorNumber, abi.Sect
 		return 0, 0, xerrors.Errorf("starting the sector fsm: %w", err)
 	}
 
-	cf, err := m.getConfig()
-	if err != nil {
-		return 0, 0, xerrors.Errorf("getting the sealing delay: %w", err)
-	}
-
-	if cf.WaitDealsDelay > 0 {
-		timer := time.NewTimer(cf.WaitDealsDelay)
-		go func() {
-			<-timer.C
-			if err := m.StartPacking(sid); err != nil {
-				log.Errorf("starting sector %d: %+v", sid, err)
-			}
-		}()
-	}
-
 	ssize, err := spt.SectorSize()
 	return sid, ssize, err
 }
 
 // newSectorCC accepts a slice of pieces with no deal (junk data)
-func (m *Sealing) newSectorCC(ctx context.Context, sid abi.SectorNumber, pieces []Piece) error {
-	spt, err := m.currentSealProof(ctx)
-	if err != nil {
-		return xerrors.Errorf("getting current seal proof type: %w", err)
-	}
-
+func (m *Sealing) newSectorCC(ctx context.Context, spt abi.RegisteredSealProof, sid abi.SectorNumber, pieces []Piece) error {
 	log.Infof("Creating CC sector %d", sid)
 	return m.sectors.Send(uint64(sid), SectorStartCC{
 		ID:         sid,
@@ -0,0 +1,243 @@
+package sealing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+func TestGetSectorAndPaddingMatchesProofType(t *testing.T) {
+	m := &Sealing{
+		unsealedInfoMap: UnsealedSectorMap{
+			infos: map[abi.SectorNumber]UnsealedSectorInfo{
+				// created before the preferred proof type changed
+				1: {ssize: 2048, spt: abi.RegisteredSealProof_StackedDrg2KiBV1},
+				2: {ssize: 2048, spt: abi.RegisteredSealProof_StackedDrg2KiBV1_1},
+			},
+		},
+		leases: newPieceLeases(),
+		getConfig: func() (sealiface.Config, error) {
+			return sealiface.Config{}, nil
+		},
+	}
+
+	size := abi.PaddedPieceSize(1024).Unpadded()
+
+	sid, _, err := m.getSectorAndPadding(context.TODO(), abi.RegisteredSealProof_StackedDrg2KiBV1_1, size, nil)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(2), sid)
+
+	sid, _, err = m.getSectorAndPadding(context.TODO(), abi.RegisteredSealProof_StackedDrg2KiBV1, size, nil)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(1), sid)
+}
+
+func TestGetSectorAndPaddingMatchesLabels(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1_1
+
+	m := &Sealing{
+		unsealedInfoMap: UnsealedSectorMap{
+			infos: map[abi.SectorNumber]UnsealedSectorInfo{
+				1: {ssize: 2048, spt: spt},
+				2: {ssize: 2048, spt: spt, exclusive: "client-a"},
+				3: {ssize: 2048, spt: spt, exclusive: "client-b"},
+			},
+		},
+		leases: newPieceLeases(),
+		getConfig: func() (sealiface.Config, error) {
+			return sealiface.Config{
+				ExclusiveLabels: []string{"client-a", "client-b"},
+			}, nil
+		},
+	}
+
+	size := abi.PaddedPieceSize(512).Unpadded()
+
+	sid, _, err := m.getSectorAndPadding(context.TODO(), spt, size, []string{"verified"})
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(1), sid)
+
+	sid, _, err = m.getSectorAndPadding(context.TODO(), spt, size, []string{"verified", "client-b"})
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(3), sid)
+}
+
+func TestLabels(t *testing.T) {
+	cfg := sealiface.Config{
+		ExclusiveLabels: []string{"client-a", "client-b"},
+		DedicatedLabels: []string{"archive"},
+	}
+
+	require.Equal(t, "", exclusiveLabels(cfg, nil))
+	require.Equal(t, "client-a,client-b", exclusiveLabels(cfg, []string{"client-b", "verified", "client-a"}))
+
+	require.False(t, hasDedicatedLabel(cfg, []string{"client-a"}))
+	require.True(t, hasDedicatedLabel(cfg, []string{"client-a", "archive"}))
+}
+
+func TestIsLargePiece(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1_1
+
+	require.False(t, isLargePiece(spt, abi.PaddedPieceSize(512).Unpadded()))
+	require.True(t, isLargePiece(spt, abi.PaddedPieceSize(1024).Unpadded()))
+	require.True(t, isLargePiece(spt, abi.PaddedPieceSize(2048).Unpadded()))
+
+	require.False(t, fillsSector(spt, abi.PaddedPieceSize(1024).Unpadded()))
+	require.True(t, fillsSector(spt, abi.PaddedPieceSize(2048).Unpadded()))
+}
+
+func TestGetSectorAndPaddingSkipsDedicated(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1_1
+
+	m := &Sealing{
+		unsealedInfoMap: UnsealedSectorMap{
+			infos: map[abi.SectorNumber]UnsealedSectorInfo{
+				// waiting for a piece filling the sector
+				1: {ssize: 2048, spt: spt, dedicated: true},
+				2: {ssize: 2048, spt: spt, stored: 1024, pieceSizes: []abi.UnpaddedPieceSize{abi.PaddedPieceSize(1024).Unpadded()}},
+			},
+		},
+		leases: newPieceLeases(),
+		getConfig: func() (sealiface.Config, error) {
+			return sealiface.Config{}, nil
+		},
+	}
+
+	sid, _, err := m.getSectorAndPadding(context.TODO(), spt, abi.PaddedPieceSize(1024).Unpadded(), nil)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(2), sid)
+}
+
+func TestRestoreUnsealedInfo(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1_1
+	cfg := sealiface.Config{DedicatedLabels: []string{"archive"}}
+
+	sector := func(size abi.PaddedPieceSize, labels ...string) SectorInfo {
+		return SectorInfo{
+			SectorType: spt,
+			Pieces: []Piece{{
+				Piece:    abi.PieceInfo{Size: size},
+				DealInfo: &DealInfo{DealID: 1, Labels: labels},
+			}},
+		}
+	}
+
+	ui, err := restoreUnsealedInfo(cfg, sector(1024))
+	require.NoError(t, err)
+	require.False(t, ui.dedicated)
+	require.Equal(t, abi.PaddedPieceSize(1024), ui.stored)
+	require.Equal(t, uint64(1), ui.numDeals)
+
+	ui, err = restoreUnsealedInfo(cfg, sector(2048))
+	require.NoError(t, err)
+	require.True(t, ui.dedicated)
+
+	ui, err = restoreUnsealedInfo(cfg, sector(512, "archive"))
+	require.NoError(t, err)
+	require.True(t, ui.dedicated)
+}
+
+func TestDedicatedSectorTimers(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1_1
+	cfg := sealiface.Config{WaitDealsDelay: time.Hour}
+
+	m := &Sealing{
+		unsealedInfoMap: UnsealedSectorMap{
+			infos: map[abi.SectorNumber]UnsealedSectorInfo{
+				1: {ssize: 2048, spt: spt, dedicated: true, waitSince: time.Now()},
+				2: {ssize: 2048, spt: spt, dedicated: true, numDeals: 1, stored: 2048, firstDeal: 2048},
+			},
+		},
+		leases: newPieceLeases(),
+		getConfig: func() (sealiface.Config, error) {
+			return cfg, nil
+		},
+	}
+
+	// leased sectors don't start packing until the lease is released
+	m.leases.bySector[2] = 1
+
+	m.unsealedInfoMap.lk.Lock()
+
+	// waiting for its piece
+	m.startWaitDealsTimer(1, cfg)
+	require.Nil(t, m.unsealedInfoMap.infos[1].packTimer)
+
+	// the piece couldn't be added, so the sector waits for other deals
+	m.pieceFailed(1)
+	require.False(t, m.unsealedInfoMap.infos[1].dedicated)
+	require.NotNil(t, m.unsealedInfoMap.infos[1].packTimer)
+	m.unsealedInfoMap.infos[1].packTimer.Stop()
+
+	// restored with its piece, starts packing right away
+	m.startWaitDealsTimer(2, cfg)
+	require.NotNil(t, m.unsealedInfoMap.infos[2].packTimer)
+
+	m.unsealedInfoMap.lk.Unlock()
+
+	require.Eventually(t, func() bool {
+		m.unsealedInfoMap.lk.Lock()
+		defer m.unsealedInfoMap.lk.Unlock()
+
+		_, ok := m.leases.packAfter[2]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestUpdateWaitDealsTimers(t *testing.T) {
+	spt := abi.RegisteredSealProof_StackedDrg2KiBV1_1
+	cfg := sealiface.Config{WaitDealsDelay: 6 * time.Hour}
+
+	m := &Sealing{
+		unsealedInfoMap: UnsealedSectorMap{
+			infos: map[abi.SectorNumber]UnsealedSectorInfo{
+				1: {ssize: 2048, spt: spt, waitSince: time.Now().Add(-2 * time.Hour)},
+			},
+		},
+		leases: newPieceLeases(),
+		getConfig: func() (sealiface.Config, error) {
+			return cfg, nil
+		},
+	}
+
+	// leased sectors don't start packing until the lease is released
+	m.leases.bySector[1] = 1
+
+	m.unsealedInfoMap.lk.Lock()
+	m.startWaitDealsTimer(1, cfg)
+	require.NotNil(t, m.unsealedInfoMap.infos[1].packTimer)
+	m.unsealedInfoMap.lk.Unlock()
+
+	// the sector waited longer than the new delay already
+	cfg.WaitDealsDelay = time.Hour
+	require.NoError(t, m.UpdateWaitDealsTimers())
+
+	require.Eventually(t, func() bool {
+		m.unsealedInfoMap.lk.Lock()
+		defer m.unsealedInfoMap.lk.Unlock()
+
+		_, ok := m.leases.packAfter[1]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWaitDealsDelay(t *testing.T) {
+	cfg := sealiface.Config{
+		WaitDealsDelay: 6 * time.Hour,
+		WaitDealsDelays: []sealiface.WaitDealsDelayRule{
+			{SectorSize: 2048, FirstPieceMinFill: 0.5, Delay: time.Minute},
+			{SectorSize: 2048, Delay: time.Hour},
+		},
+	}
+
+	require.Equal(t, time.Hour, waitDealsDelay(cfg, 2048, 0))
+	require.Equal(t, time.Hour, waitDealsDelay(cfg, 2048, 512))
+	require.Equal(t, time.Minute, waitDealsDelay(cfg, 2048, 1024))
+	require.Equal(t, 6*time.Hour, waitDealsDelay(cfg, 8<<20, 8<<20))
+}
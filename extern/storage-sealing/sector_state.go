@@ -14,6 +14,7 @@ var ExistSectorStateList = map[SectorState]struct{}{
 	WaitSeed:             {},
 	Committing:           {},
 	SubmitCommit:         {},
+	CommitFinalize:       {},
 	CommitWait:           {},
 	FinalizeSector:       {},
 	Proving:              {},
@@ -25,6 +26,7 @@ var ExistSectorStateList = map[SectorState]struct{}{
 	CommitFailed:         {},
 	PackingFailed:        {},
 	FinalizeFailed:       {},
+	CommitFinalizeFailed: {},
 	DealsExpired:         {},
 	RecoverDealIDs:       {},
 	Faulty:               {},
@@ -44,17 +46,18 @@ const (
 
 	// happy path
 	Empty          SectorState = "Empty"
-	WaitDeals      SectorState = "WaitDeals"     // waiting for more pieces (deals) to be added to the sector
-	Packing        SectorState = "Packing"       // sector not in sealStore, and not on chain
-	GetTicket      SectorState = "GetTicket"     // generate ticket
-	PreCommit1     SectorState = "PreCommit1"    // do PreCommit1
-	PreCommit2     SectorState = "PreCommit2"    // do PreCommit2
-	PreCommitting  SectorState = "PreCommitting" // on chain pre-commit
-	PreCommitWait  SectorState = "PreCommitWait" // waiting for precommit to land on chain
-	WaitSeed       SectorState = "WaitSeed"      // waiting for seed
-	Committing     SectorState = "Committing"    // compute PoRep
-	SubmitCommit   SectorState = "SubmitCommit"  // send commit message to the chain
-	CommitWait     SectorState = "CommitWait"    // wait for the commit message to land on chain
+	WaitDeals      SectorState = "WaitDeals"      // waiting for more pieces (deals) to be added to the sector
+	Packing        SectorState = "Packing"        // sector not in sealStore, and not on chain
+	GetTicket      SectorState = "GetTicket"      // generate ticket
+	PreCommit1     SectorState = "PreCommit1"     // do PreCommit1
+	PreCommit2     SectorState = "PreCommit2"     // do PreCommit2
+	PreCommitting  SectorState = "PreCommitting"  // on chain pre-commit
+	PreCommitWait  SectorState = "PreCommitWait"  // waiting for precommit to land on chain
+	WaitSeed       SectorState = "WaitSeed"       // waiting for seed
+	Committing     SectorState = "Committing"     // compute PoRep
+	CommitFinalize SectorState = "CommitFinalize" // cleanup sector metadata before submitting the proof (early finalize)
+	SubmitCommit   SectorState = "SubmitCommit"   // send commit message to the chain
+	CommitWait     SectorState = "CommitWait"     // wait for the commit message to land on chain
 	FinalizeSector SectorState = "FinalizeSector"
 	Proving        SectorState = "Proving"
 	// error modes
@@ -66,6 +69,7 @@ const (
 	CommitFailed         SectorState = "CommitFailed"
 	PackingFailed        SectorState = "PackingFailed" // TODO: deprecated, remove
 	FinalizeFailed       SectorState = "FinalizeFailed"
+	CommitFinalizeFailed SectorState = "CommitFinalizeFailed"
 	DealsExpired         SectorState = "DealsExpired"
 	RecoverDealIDs       SectorState = "RecoverDealIDs"
 
@@ -83,9 +87,55 @@ const (
 	Removed      SectorState = "Removed"
 )
 
+// beforePreCommitStates are the states before the precommit message of a
+// sector is sent
+var beforePreCommitStates = map[SectorState]struct{}{
+	Empty:                {},
+	WaitDeals:            {},
+	Packing:              {},
+	GetTicket:            {},
+	PreCommit1:           {},
+	PreCommit2:           {},
+	SealPreCommit1Failed: {},
+	SealPreCommit2Failed: {},
+}
+
+// preCommittedStates are the states after the precommit message of a sector
+// is sent, and before its proof is
+var preCommittedStates = map[SectorState]struct{}{
+	PreCommitWait:        {},
+	WaitSeed:             {},
+	Committing:           {},
+	CommitFinalize:       {},
+	CommitFinalizeFailed: {},
+	SubmitCommit:         {},
+	ComputeProofFailed:   {},
+	CommitFailed:         {},
+}
+
+// IsBeforePreCommit returns whether the precommit message of a sector in the
+// state wasn't sent yet
+func IsBeforePreCommit(st SectorState) bool {
+	_, ok := beforePreCommitStates[st]
+	return ok
+}
+
+// IsPreCommitted returns whether the precommit message of a sector in the
+// state was sent, but its proof wasn't
+func IsPreCommitted(st SectorState) bool {
+	_, ok := preCommittedStates[st]
+	return ok
+}
+
+// IsBeforeProving returns whether the proof of a sector in the state wasn't
+// sent yet
+func IsBeforeProving(st SectorState) bool {
+	return IsBeforePreCommit(st) || st == PreCommitting || st == PreCommitFailed || IsPreCommitted(st)
+}
+
 func toStatState(st SectorState) statSectorState {
 	switch st {
-	case Empty, WaitDeals, Packing, GetTicket, PreCommit1, PreCommit2, PreCommitting, PreCommitWait, WaitSeed, Committing, SubmitCommit, CommitWait, FinalizeSector:
+	case Empty, WaitDeals, Packing, GetTicket, PreCommit1, PreCommit2, PreCommitting, PreCommitWait, WaitSeed, Committing, CommitFinalize, SubmitCommit, CommitWait, FinalizeSector:
 		return sstSealing
 	case Proving, Removed, Removing, Terminating, TerminateWait, TerminateFinality, TerminateFailed:
 		return sstProving
@@ -0,0 +1,31 @@
+package sealing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSectorStateStages(t *testing.T) {
+	for st := range beforePreCommitStates {
+		require.Contains(t, ExistSectorStateList, st)
+		require.False(t, IsPreCommitted(st), st)
+		require.True(t, IsBeforeProving(st), st)
+	}
+	for st := range preCommittedStates {
+		require.Contains(t, ExistSectorStateList, st)
+		require.True(t, IsBeforeProving(st), st)
+	}
+
+	// every state of the sealing pipeline up to submitting the proof is
+	// before proving
+	for _, st := range []SectorState{Empty, WaitDeals, Packing, GetTicket, PreCommit1, PreCommit2, PreCommitting, PreCommitWait, WaitSeed, Committing, CommitFinalize, CommitFinalizeFailed, SubmitCommit} {
+		require.True(t, IsBeforeProving(st), st)
+	}
+
+	for _, st := range []SectorState{CommitWait, FinalizeSector, Proving, Faulty, FaultReported, Removed, Terminating} {
+		require.False(t, IsBeforeProving(st), st)
+		require.False(t, IsBeforePreCommit(st), st)
+		require.False(t, IsPreCommitted(st), st)
+	}
+}
@@ -2,7 +2,6 @@ package sealing
 
 import (
 	"bytes"
-	"time"
 
 	"golang.org/x/xerrors"
 
@@ -16,24 +15,6 @@ import (
 	"github.com/filecoin-project/go-commp-utils/zerocomm"
 )
 
-const minRetryTime = 1 * time.Minute
-
-func failedCooldown(ctx statemachine.Context, sector SectorInfo) error {
-	// TODO: Exponential backoff when we see consecutive failures
-
-	retryStart := time.Unix(int64(sector.Log[len(sector.Log)-1].Timestamp), 0).Add(minRetryTime)
-	if len(sector.Log) > 0 && !time.Now().After(retryStart) {
-		log.Infof("%s(%d), waiting %s before retrying", sector.State, sector.SectorNumber, time.Until(retryStart))
-		select {
-		case <-time.After(time.Until(retryStart)):
-		case <-ctx.Context().Done():
-			return ctx.Context().Err()
-		}
-	}
-
-	return nil
-}
-
 func (m *Sealing) checkPreCommitted(ctx statemachine.Context, sector SectorInfo) (*miner.SectorPreCommitOnChainInfo, bool) {
 	tok, _, err := m.api.ChainHead(ctx.Context())
 	if err != nil {
@@ -51,7 +32,7 @@ func (m *Sealing) checkPreCommitted(ctx statemachine.Context, sector SectorInfo)
 }
 
 func (m *Sealing) handleSealPrecommit1Failed(ctx statemachine.Context, sector SectorInfo) error {
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -59,7 +40,7 @@ func (m *Sealing) handleSealPrecommit1Failed(ctx statemachine.Context, sector Se
 }
 
 func (m *Sealing) handleSealPrecommit2Failed(ctx statemachine.Context, sector SectorInfo) error {
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -81,7 +62,7 @@ func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorI
 		mw, err := m.api.StateSearchMsg(ctx.Context(), *sector.PreCommitMessage)
 		if err != nil {
 			// API error
-			if err := failedCooldown(ctx, sector); err != nil {
+			if err := m.failedCooldown(ctx, sector); err != nil {
 				return err
 			}
 
@@ -149,7 +130,7 @@ func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorI
 		// TODO: we could compare more things, but I don't think we really need to
 		//  CommR tells us that CommD (and CommPs), and the ticket are all matching
 
-		if err := failedCooldown(ctx, sector); err != nil {
+		if err := m.failedCooldown(ctx, sector); err != nil {
 			return err
 		}
 
@@ -160,7 +141,7 @@ func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorI
 		log.Warn("retrying precommit even though the message failed to apply")
 	}
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -170,7 +151,7 @@ func (m *Sealing) handlePreCommitFailed(ctx statemachine.Context, sector SectorI
 func (m *Sealing) handleComputeProofFailed(ctx statemachine.Context, sector SectorInfo) error {
 	// TODO: Check sector files
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -192,7 +173,7 @@ func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo
 		mw, err := m.api.StateSearchMsg(ctx.Context(), *sector.CommitMessage)
 		if err != nil {
 			// API error
-			if err := failedCooldown(ctx, sector); err != nil {
+			if err := m.failedCooldown(ctx, sector); err != nil {
 				return err
 			}
 
@@ -252,7 +233,7 @@ func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo
 			log.Errorf("seed changed, will retry: %+v", err)
 			return ctx.Send(SectorRetryWaitSeed{})
 		case *ErrInvalidProof:
-			if err := failedCooldown(ctx, sector); err != nil {
+			if err := m.failedCooldown(ctx, sector); err != nil {
 				return err
 			}
 
@@ -272,7 +253,7 @@ func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo
 		case *ErrExpiredDeals:
 			return ctx.Send(SectorDealsExpired{xerrors.Errorf("sector deals expired: %w", err)})
 		case *ErrCommitWaitFailed:
-			if err := failedCooldown(ctx, sector); err != nil {
+			if err := m.failedCooldown(ctx, sector); err != nil {
 				return err
 			}
 
@@ -284,7 +265,7 @@ func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo
 
 	// TODO: Check sector files
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -294,7 +275,7 @@ func (m *Sealing) handleCommitFailed(ctx statemachine.Context, sector SectorInfo
 func (m *Sealing) handleFinalizeFailed(ctx statemachine.Context, sector SectorInfo) error {
 	// TODO: Check sector files
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -302,7 +283,7 @@ func (m *Sealing) handleFinalizeFailed(ctx statemachine.Context, sector SectorIn
 }
 
 func (m *Sealing) handleRemoveFailed(ctx statemachine.Context, sector SectorInfo) error {
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -318,7 +299,7 @@ func (m *Sealing) handleTerminateFailed(ctx statemachine.Context, sector SectorI
 		return nil // pause the fsm, needs manual user action
 	}
 
-	if err := failedCooldown(ctx, sector); err != nil {
+	if err := m.failedCooldown(ctx, sector); err != nil {
 		return err
 	}
 
@@ -124,5 +124,9 @@ func (m *Sealing) handleRemoving(ctx statemachine.Context, sector SectorInfo) er
 		return ctx.Send(SectorRemoveFailed{err})
 	}
 
+	if err := m.aborts.forget(sector.SectorNumber); err != nil {
+		log.Warnw("forgetting removed aborted sector", "sector", sector.SectorNumber, "error", err)
+	}
+
 	return ctx.Send(SectorRemoved{})
 }
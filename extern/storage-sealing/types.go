@@ -34,6 +34,9 @@ type DealInfo struct {
 	DealID       abi.DealID
 	DealSchedule DealSchedule
 	KeepUnsealed bool
+	// Labels constrain which pieces the piece can share a sector with, see
+	// sealiface.Config ExclusiveLabels and DedicatedLabels
+	Labels []string
 }
 
 // DealSchedule communicates the time interval of a storage deal. The deal must
@@ -107,6 +110,9 @@ type SectorInfo struct {
 	TerminateMessage *cid.Cid
 	TerminatedAt     abi.ChainEpoch
 
+	// Failure retries, reset when the sector makes progress
+	FailureRetries uint64
+
 	// Debug
 	LastErr string
 
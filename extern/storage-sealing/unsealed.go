@@ -0,0 +1,108 @@
+package sealing
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// UnsealedCheckInterval is how often the unsealed copies of proving sectors
+// are checked when ManageUnsealedCopies is enabled
+var UnsealedCheckInterval = time.Hour
+
+func (m *Sealing) runUnsealedCheck(ctx context.Context) {
+	t := time.NewTicker(UnsealedCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := m.checkUnsealedCopies(ctx); err != nil {
+				log.Errorf("checking unsealed copies: %+v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkUnsealedCopies drops the unsealed copies of proving sectors none of
+// whose deals keep their data unsealed, and unseals the pieces of deals which
+// do when the sector has no unsealed copy
+func (m *Sealing) checkUnsealedCopies(ctx context.Context) error {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return xerrors.Errorf("getting sealing config: %w", err)
+	}
+
+	if !cfg.ManageUnsealedCopies {
+		return nil
+	}
+
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	return m.applyUnsealedPolicy(ctx, sectors)
+}
+
+// applyUnsealedPolicy removes or restores the unsealed copies of the proving
+// sectors according to the deals they hold
+func (m *Sealing) applyUnsealedPolicy(ctx context.Context, sectors []SectorInfo) error {
+	var failed int
+	for _, sector := range sectors {
+		if sector.State != Proving {
+			continue
+		}
+
+		ref := m.minerSector(sector.SectorType, sector.SectorNumber)
+		keep := sector.keepUnsealedRanges(false)
+
+		has, err := m.sealer.HasUnsealed(ctx, ref)
+		if err != nil {
+			log.Warnw("checking for unsealed copy", "sector", sector.SectorNumber, "error", err)
+			failed++
+			continue
+		}
+
+		switch {
+		case has && len(keep) == 0:
+			log.Infow("removing unsealed copy no deal keeps", "sector", sector.SectorNumber)
+
+			if err := m.sealer.RemoveUnsealed(ctx, ref); err != nil {
+				log.Warnw("removing unsealed copy", "sector", sector.SectorNumber, "error", err)
+				failed++
+			}
+		case !has && len(keep) > 0:
+			if sector.CommD == nil {
+				continue
+			}
+
+			log.Infow("unsealing pieces of deals which keep an unsealed copy", "sector", sector.SectorNumber, "pieces", len(keep))
+
+			for _, r := range keep {
+				err := m.sealer.ReadPiece(ctx, ioutil.Discard, ref, storiface.UnpaddedByteIndex(r.Offset), r.Size, sector.TicketValue, *sector.CommD)
+				if err != nil {
+					log.Warnw("unsealing piece", "sector", sector.SectorNumber, "offset", r.Offset, "error", err)
+					failed++
+					break
+				}
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	if failed > 0 {
+		return xerrors.Errorf("failed to apply the unsealed copy policy to %d sectors", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package sealing
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+type testUnsealedSealer struct {
+	sectorstorage.SectorManager
+
+	unsealed map[abi.SectorNumber]bool
+	broken   map[abi.SectorNumber]bool
+
+	removed []abi.SectorNumber
+	read    map[abi.SectorNumber][]storage.Range
+}
+
+func (s *testUnsealedSealer) HasUnsealed(ctx context.Context, sector storage.SectorRef) (bool, error) {
+	if s.broken[sector.ID.Number] {
+		return false, xerrors.New("storage unreachable")
+	}
+	return s.unsealed[sector.ID.Number], nil
+}
+
+func (s *testUnsealedSealer) RemoveUnsealed(ctx context.Context, sector storage.SectorRef) error {
+	s.removed = append(s.removed, sector.ID.Number)
+	return nil
+}
+
+func (s *testUnsealedSealer) ReadPiece(ctx context.Context, w io.Writer, sector storage.SectorRef, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize, ticket abi.SealRandomness, commD cid.Cid) error {
+	s.read[sector.ID.Number] = append(s.read[sector.ID.Number], storage.Range{Offset: abi.UnpaddedPieceSize(offset), Size: size})
+	return nil
+}
+
+func testUnsealedSealing(t *testing.T, sealer *testUnsealedSealer, manage bool) *Sealing {
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	return &Sealing{
+		maddr:  maddr,
+		sealer: sealer,
+		getConfig: func() (sealiface.Config, error) {
+			return sealiface.Config{ManageUnsealedCopies: manage}, nil
+		},
+	}
+}
+
+// testUnsealedSector returns a proving sector holding a filler piece, and a
+// deal piece which keeps an unsealed copy if keep is set
+func testUnsealedSector(num abi.SectorNumber, keep bool) SectorInfo {
+	commD := cid.Undef
+	return SectorInfo{
+		State:        Proving,
+		SectorNumber: num,
+		SectorType:   abi.RegisteredSealProof_StackedDrg2KiBV1_1,
+		CommD:        &commD,
+		Pieces: []Piece{
+			{Piece: abi.PieceInfo{Size: 1024}},
+			{
+				Piece:    abi.PieceInfo{Size: 1024},
+				DealInfo: &DealInfo{DealID: abi.DealID(num), KeepUnsealed: keep},
+			},
+		},
+	}
+}
+
+func TestApplyUnsealedPolicy(t *testing.T) {
+	sealer := &testUnsealedSealer{
+		unsealed: map[abi.SectorNumber]bool{1: true, 2: true, 5: true},
+		read:     map[abi.SectorNumber][]storage.Range{},
+	}
+	m := testUnsealedSealing(t, sealer, true)
+
+	noCommD := testUnsealedSector(4, true)
+	noCommD.CommD = nil
+
+	unfinished := testUnsealedSector(5, false)
+	unfinished.State = PreCommit1
+
+	err := m.applyUnsealedPolicy(context.Background(), []SectorInfo{
+		testUnsealedSector(1, false), // unsealed copy no deal keeps
+		testUnsealedSector(2, true),  // unsealed copy a deal keeps
+		testUnsealedSector(3, true),  // missing unsealed copy
+		noCommD,                      // can't be unsealed
+		unfinished,                   // not proving yet
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []abi.SectorNumber{1}, sealer.removed)
+
+	// only the deal piece is unsealed
+	dealPiece := abi.PaddedPieceSize(1024).Unpadded()
+	require.Equal(t, map[abi.SectorNumber][]storage.Range{
+		3: {{Offset: dealPiece, Size: dealPiece}},
+	}, sealer.read)
+}
+
+func TestApplyUnsealedPolicyFailures(t *testing.T) {
+	sealer := &testUnsealedSealer{
+		unsealed: map[abi.SectorNumber]bool{2: true},
+		broken:   map[abi.SectorNumber]bool{1: true},
+		read:     map[abi.SectorNumber][]storage.Range{},
+	}
+	m := testUnsealedSealing(t, sealer, true)
+
+	err := m.applyUnsealedPolicy(context.Background(), []SectorInfo{
+		testUnsealedSector(1, false),
+		testUnsealedSector(2, false),
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "1 sectors")
+
+	// the other sectors are still checked
+	require.Equal(t, []abi.SectorNumber{2}, sealer.removed)
+}
+
+func TestCheckUnsealedCopiesDisabled(t *testing.T) {
+	sealer := &testUnsealedSealer{
+		unsealed: map[abi.SectorNumber]bool{1: true},
+		read:     map[abi.SectorNumber][]storage.Range{},
+	}
+
+	// sectors aren't listed when the copies aren't managed
+	require.NoError(t, testUnsealedSealing(t, sealer, false).checkUnsealedCopies(context.Background()))
+	require.Empty(t, sealer.removed)
+}
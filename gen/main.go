@@ -54,6 +54,8 @@ func main() {
 		api.SealedRefs{},
 		api.SealTicket{},
 		api.SealSeed{},
+		api.Deadline{},
+		api.Partition{},
 	)
 	if err != nil {
 		fmt.Println(err)
@@ -0,0 +1,263 @@
+package bandwidth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p-core/metrics"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+var log = logging.Logger("bandwidth")
+
+const (
+	// RecordInterval is how often bandwidth usage is written to the datastore
+	RecordInterval = 10 * time.Minute
+
+	// Retention is how long recorded samples are kept
+	Retention = 30 * 24 * time.Hour
+
+	// TopPeers is the number of peers with the highest usage recorded in each
+	// sample; recording all peers would make samples grow with the peer count
+	TopPeers = 50
+)
+
+var historyPrefix = datastore.NewKey("/bandwidth/history")
+
+// Recorder periodically persists the bandwidth counted by the libp2p
+// reporter, so usage can be attributed to protocols and peers across restarts
+type Recorder struct {
+	reporter metrics.Reporter
+	ds       datastore.Batching
+
+	lk        sync.Mutex
+	lastTime  time.Time
+	lastTotal metrics.Stats
+	lastProto map[protocol.ID]metrics.Stats
+	lastPeer  map[peer.ID]metrics.Stats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRecorder(lc fx.Lifecycle, ds dtypes.MetadataDS, reporter metrics.Reporter) *Recorder {
+	r := &Recorder{
+		reporter: reporter,
+		ds:       namespace.Wrap(ds, historyPrefix),
+
+		lastTime:  time.Now(),
+		lastTotal: reporter.GetBandwidthTotals(),
+		lastProto: reporter.GetBandwidthByProtocol(),
+		lastPeer:  reporter.GetBandwidthByPeer(),
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go r.run()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			close(r.stop)
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			// record the partial interval so usage up to shutdown isn't lost
+			return r.record(time.Now())
+		},
+	})
+
+	return r
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	t := time.NewTicker(RecordInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case now := <-t.C:
+			if err := r.record(now); err != nil {
+				log.Errorf("recording bandwidth usage: %+v", err)
+			}
+			if err := r.prune(now.Add(-Retention)); err != nil {
+				log.Errorf("pruning bandwidth history: %+v", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *Recorder) record(now time.Time) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	total := r.reporter.GetBandwidthTotals()
+	protos := r.reporter.GetBandwidthByProtocol()
+	peers := r.reporter.GetBandwidthByPeer()
+
+	s := api.NetBandwidthSample{
+		Start:     r.lastTime,
+		End:       now,
+		Total:     usage(total, r.lastTotal),
+		Protocols: map[protocol.ID]api.NetBandwidthUsage{},
+		Peers:     map[string]api.NetBandwidthUsage{},
+	}
+
+	for p, st := range protos {
+		if u := usage(st, r.lastProto[p]); u.In > 0 || u.Out > 0 {
+			s.Protocols[p] = u
+		}
+	}
+
+	type peerUsage struct {
+		p peer.ID
+		u api.NetBandwidthUsage
+	}
+	var pu []peerUsage
+	for p, st := range peers {
+		if u := usage(st, r.lastPeer[p]); u.In > 0 || u.Out > 0 {
+			pu = append(pu, peerUsage{p: p, u: u})
+		}
+	}
+	sort.Slice(pu, func(i, j int) bool {
+		return pu[i].u.In+pu[i].u.Out > pu[j].u.In+pu[j].u.Out
+	})
+	if len(pu) > TopPeers {
+		pu = pu[:TopPeers]
+	}
+	for _, u := range pu {
+		s.Peers[u.p.String()] = u.u
+	}
+
+	r.lastTime, r.lastTotal, r.lastProto, r.lastPeer = now, total, protos, peers
+
+	b, err := json.Marshal(&s)
+	if err != nil {
+		return xerrors.Errorf("marshaling bandwidth sample: %w", err)
+	}
+
+	return r.ds.Put(sampleKey(now), b)
+}
+
+// History returns recorded samples which ended after the given time, oldest first
+func (r *Recorder) History(since time.Time) ([]api.NetBandwidthSample, error) {
+	res, err := r.ds.Query(query.Query{})
+	if err != nil {
+		return nil, xerrors.Errorf("querying bandwidth history: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []api.NetBandwidthSample
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, xerrors.Errorf("reading bandwidth history: %w", e.Error)
+		}
+
+		end, err := sampleTime(e.Key)
+		if err != nil {
+			log.Warnf("skipping bandwidth sample: %s", err)
+			continue
+		}
+		if end.Before(since) {
+			continue
+		}
+
+		var s api.NetBandwidthSample
+		if err := json.Unmarshal(e.Value, &s); err != nil {
+			return nil, xerrors.Errorf("unmarshaling bandwidth sample %s: %w", e.Key, err)
+		}
+		out = append(out, s)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].End.Before(out[j].End)
+	})
+
+	return out, nil
+}
+
+func (r *Recorder) prune(before time.Time) error {
+	res, err := r.ds.Query(query.Query{KeysOnly: true})
+	if err != nil {
+		return xerrors.Errorf("querying bandwidth history: %w", err)
+	}
+
+	var old []datastore.Key
+	for e := range res.Next() {
+		if e.Error != nil {
+			_ = res.Close() //nolint:errcheck
+			return xerrors.Errorf("reading bandwidth history: %w", e.Error)
+		}
+
+		end, err := sampleTime(e.Key)
+		if err != nil || end.Before(before) {
+			old = append(old, datastore.NewKey(e.Key))
+		}
+	}
+	if err := res.Close(); err != nil {
+		return err
+	}
+
+	for _, k := range old {
+		if err := r.ds.Delete(k); err != nil {
+			return xerrors.Errorf("deleting bandwidth sample %s: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+func usage(cur, last metrics.Stats) api.NetBandwidthUsage {
+	u := api.NetBandwidthUsage{
+		In:  cur.TotalIn - last.TotalIn,
+		Out: cur.TotalOut - last.TotalOut,
+	}
+
+	// counters for a peer may be dropped and recreated while we are running
+	if u.In < 0 {
+		u.In = cur.TotalIn
+	}
+	if u.Out < 0 {
+		u.Out = cur.TotalOut
+	}
+
+	return u
+}
+
+// sample keys are zero padded so they sort by time
+func sampleKey(t time.Time) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("%020d", t.UnixNano()))
+}
+
+func sampleTime(k string) (time.Time, error) {
+	ns, err := strconv.ParseInt(strings.TrimPrefix(k, "/"), 10, 64)
+	if err != nil {
+		return time.Time{}, xerrors.Errorf("parsing sample key %q: %w", k, err)
+	}
+	return time.Unix(0, ns), nil
+}
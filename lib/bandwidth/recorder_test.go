@@ -0,0 +1,103 @@
+package bandwidth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-core/metrics"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+type fakeReporter struct {
+	metrics.Reporter
+
+	total  metrics.Stats
+	protos map[protocol.ID]metrics.Stats
+	peers  map[peer.ID]metrics.Stats
+}
+
+func (f *fakeReporter) GetBandwidthTotals() metrics.Stats {
+	return f.total
+}
+
+func (f *fakeReporter) GetBandwidthByProtocol() map[protocol.ID]metrics.Stats {
+	out := map[protocol.ID]metrics.Stats{}
+	for k, v := range f.protos {
+		out[k] = v
+	}
+	return out
+}
+
+func (f *fakeReporter) GetBandwidthByPeer() map[peer.ID]metrics.Stats {
+	out := map[peer.ID]metrics.Stats{}
+	for k, v := range f.peers {
+		out[k] = v
+	}
+	return out
+}
+
+func TestRecorderHistory(t *testing.T) {
+	rep := &fakeReporter{
+		total:  metrics.Stats{TotalIn: 100, TotalOut: 10},
+		protos: map[protocol.ID]metrics.Stats{"/fil/hello/1.0.0": {TotalIn: 100, TotalOut: 10}},
+		peers:  map[peer.ID]metrics.Stats{"peerA": {TotalIn: 100, TotalOut: 10}},
+	}
+
+	start := time.Unix(1000, 0)
+	r := &Recorder{
+		reporter: rep,
+		ds:       namespace.Wrap(dssync.MutexWrap(datastore.NewMapDatastore()), historyPrefix),
+
+		lastTime:  start,
+		lastTotal: rep.GetBandwidthTotals(),
+		lastProto: rep.GetBandwidthByProtocol(),
+		lastPeer:  rep.GetBandwidthByPeer(),
+	}
+
+	// usage before the recorder was created isn't attributed to the first sample
+	rep.total = metrics.Stats{TotalIn: 150, TotalOut: 40}
+	rep.protos["/fil/hello/1.0.0"] = metrics.Stats{TotalIn: 120, TotalOut: 10}
+	rep.protos["/meshsub/1.1.0"] = metrics.Stats{TotalIn: 30, TotalOut: 30}
+	rep.peers["peerB"] = metrics.Stats{TotalIn: 50, TotalOut: 30}
+	require.NoError(t, r.record(start.Add(time.Minute)))
+
+	// peerA's counter was reset
+	rep.total = metrics.Stats{TotalIn: 155, TotalOut: 40}
+	rep.peers["peerA"] = metrics.Stats{TotalIn: 5}
+	require.NoError(t, r.record(start.Add(2*time.Minute)))
+
+	h, err := r.History(start)
+	require.NoError(t, err)
+	require.Len(t, h, 2)
+
+	require.Equal(t, api.NetBandwidthUsage{In: 50, Out: 30}, h[0].Total)
+	require.Equal(t, map[protocol.ID]api.NetBandwidthUsage{
+		"/fil/hello/1.0.0": {In: 20},
+		"/meshsub/1.1.0":   {In: 30, Out: 30},
+	}, h[0].Protocols)
+	require.Equal(t, map[string]api.NetBandwidthUsage{
+		peer.ID("peerB").String(): {In: 50, Out: 30},
+	}, h[0].Peers)
+
+	require.Equal(t, api.NetBandwidthUsage{In: 5}, h[1].Total)
+	require.Equal(t, map[string]api.NetBandwidthUsage{
+		peer.ID("peerA").String(): {In: 5},
+	}, h[1].Peers)
+
+	h, err = r.History(start.Add(90 * time.Second))
+	require.NoError(t, err)
+	require.Len(t, h, 1)
+
+	require.NoError(t, r.prune(start.Add(90*time.Second)))
+	h, err = r.History(start)
+	require.NoError(t, err)
+	require.Len(t, h, 1)
+	require.Equal(t, start.Add(2*time.Minute).UnixNano(), h[0].End.UnixNano())
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"sync"
 	"sync/atomic"
 
 	"github.com/dgraph-io/badger/v2"
@@ -134,6 +136,90 @@ func (b *Blockstore) Close() error {
 	return b.DB.Close()
 }
 
+// gcDiscardRatio is the share of a value log file that has to be taken by
+// deleted values for garbage collection to rewrite it
+const gcDiscardRatio = 0.5
+
+// gcFlattenWorkers is the number of compaction workers flattening the LSM
+// tree before garbage collection
+const gcFlattenWorkers = 4
+
+// CollectGarbage rewrites the value log files in which deleted blocks take up
+// space, until there are none left, and returns the size of the store on disk
+// before and after. It can run while the store is in use, but flattening the
+// LSM tree competes with writes, so writes, if not nil, is held while it's
+// flattened; the caller's writes must be paused while it's held.
+func (b *Blockstore) CollectGarbage(ctx context.Context, writes sync.Locker) (before, after int64, err error) {
+	if atomic.LoadInt64(&b.state) != stateOpen {
+		return 0, 0, ErrBlockstoreClosed
+	}
+
+	before, err = b.diskUsage()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// compacting the LSM tree drops the deleted keys from it, which records
+	// the space their values take up in the value log files for the gc to
+	// pick them
+	if err := b.flatten(writes); err != nil {
+		return 0, 0, fmt.Errorf("flattening the LSM tree: %w", err)
+	}
+
+	for ctx.Err() == nil {
+		err := b.DB.RunValueLogGC(gcDiscardRatio)
+		if err == badger.ErrNoRewrite {
+			break
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("running value log gc: %w", err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	after, err = b.diskUsage()
+	if err != nil {
+		return 0, 0, err
+	}
+	return before, after, nil
+}
+
+// flatten compacts the LSM tree into a single level, holding writes
+func (b *Blockstore) flatten(writes sync.Locker) error {
+	if writes != nil {
+		writes.Lock()
+		defer writes.Unlock()
+	}
+	return b.DB.Flatten(gcFlattenWorkers)
+}
+
+// diskUsage returns the size of the files of the store
+func (b *Blockstore) diskUsage() (int64, error) {
+	opts := b.DB.Opts()
+
+	dirs := []string{opts.Dir}
+	if opts.ValueDir != opts.Dir {
+		dirs = append(dirs, opts.ValueDir)
+	}
+
+	var size int64
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return 0, fmt.Errorf("reading store directory: %w", err)
+		}
+		for _, fi := range files {
+			if !fi.IsDir() {
+				size += fi.Size()
+			}
+		}
+	}
+
+	return size, nil
+}
+
 // View implements blockstore.Viewer, which leverages zero-copy read-only
 // access to values.
 func (b *Blockstore) View(cid cid.Cid, fn func([]byte) error) error {
@@ -1,8 +1,11 @@
 package badgerbs
 
 import (
+	"context"
+	"crypto/rand"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 
 	blocks "github.com/ipfs/go-block-format"
@@ -60,6 +63,51 @@ func TestStorageKey(t *testing.T) {
 	require.Equal(t, k3, k2)
 }
 
+func TestCollectGarbage(t *testing.T) {
+	// small value log files, holding values out of the LSM tree
+	opts := func(path string) Options {
+		opts := DefaultOptions(path)
+		opts.ValueLogFileSize = 1 << 20
+		opts.ValueThreshold = 1 << 10
+		return opts
+	}
+
+	bs, _ := newBlockstore(opts)(t)
+	bbs := bs.(*Blockstore)
+	defer bbs.Close() //nolint:errcheck
+
+	// 8MiB of blocks, spread over several value log files
+	var blks []blocks.Block
+	for i := 0; i < 512; i++ {
+		data := make([]byte, 16<<10)
+		_, _ = rand.Read(data)
+		blk := blocks.NewBlock(data)
+		require.NoError(t, bbs.Put(blk))
+		blks = append(blks, blk)
+	}
+
+	// keep one block, which the gc must preserve
+	for _, blk := range blks[1:] {
+		require.NoError(t, bbs.DeleteBlock(blk.Cid()))
+	}
+
+	var writes sync.Mutex
+	before, after, err := bbs.CollectGarbage(context.Background(), &writes)
+	require.NoError(t, err)
+	require.Greater(t, before-after, int64(4<<20), "expected most of the deleted data to be reclaimed (before %d, after %d)", before, after)
+
+	has, err := bbs.Has(blks[0].Cid())
+	require.NoError(t, err)
+	require.True(t, has)
+	got, err := bbs.Get(blks[0].Cid())
+	require.NoError(t, err)
+	require.Equal(t, blks[0].RawData(), got.RawData())
+
+	require.NoError(t, bbs.Close())
+	_, _, err = bbs.CollectGarbage(context.Background(), nil)
+	require.Equal(t, ErrBlockstoreClosed, err)
+}
+
 func newBlockstore(optsSupplier func(path string) Options) func(tb testing.TB) (bs blockstore.Blockstore, path string) {
 	return func(tb testing.TB) (bs blockstore.Blockstore, path string) {
 		tb.Helper()
@@ -0,0 +1,103 @@
+package splitstore
+
+import (
+	"os"
+
+	"github.com/ipfs/go-cid"
+	levelds "github.com/ipfs/go-ds-leveldb"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	ldbopts "github.com/syndtr/goleveldb/leveldb/opt"
+	"golang.org/x/xerrors"
+)
+
+// markSet holds the multihashes of the objects kept in the hot store during a
+// compaction
+type markSet interface {
+	// visit marks the object, it returns false if it was already marked
+	visit(c cid.Cid) (bool, error)
+	has(c cid.Cid) (bool, error)
+	count() int
+	close() error
+}
+
+// newMarkSet opens a mark set on disk at path, which is removed when it's
+// closed. The live state of the chain has too many objects to mark them in
+// memory; with an empty path, they are marked in memory anyway.
+func newMarkSet(path string) (markSet, error) {
+	if path == "" {
+		return memMarkSet{}, nil
+	}
+
+	// left over by an interrupted compaction
+	if err := os.RemoveAll(path); err != nil {
+		return nil, xerrors.Errorf("removing old mark set: %w", err)
+	}
+
+	ds, err := levelds.NewDatastore(path, &levelds.Options{
+		Compression: ldbopts.NoCompression,
+		NoSync:      true,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("opening mark set: %w", err)
+	}
+
+	return &dsMarkSet{ds: ds, path: path}, nil
+}
+
+type memMarkSet map[string]struct{}
+
+func (m memMarkSet) visit(c cid.Cid) (bool, error) {
+	k := string(c.Hash())
+	if _, ok := m[k]; ok {
+		return false, nil
+	}
+	m[k] = struct{}{}
+	return true, nil
+}
+
+func (m memMarkSet) has(c cid.Cid) (bool, error) {
+	_, ok := m[string(c.Hash())]
+	return ok, nil
+}
+
+func (m memMarkSet) count() int {
+	return len(m)
+}
+
+func (m memMarkSet) close() error {
+	return nil
+}
+
+type dsMarkSet struct {
+	ds   *levelds.Datastore
+	path string
+	n    int
+}
+
+func (m *dsMarkSet) visit(c cid.Cid) (bool, error) {
+	k := dshelp.MultihashToDsKey(c.Hash())
+	has, err := m.ds.Has(k)
+	if err != nil || has {
+		return false, err
+	}
+	if err := m.ds.Put(k, []byte{}); err != nil {
+		return false, err
+	}
+	m.n++
+	return true, nil
+}
+
+func (m *dsMarkSet) has(c cid.Cid) (bool, error) {
+	return m.ds.Has(dshelp.MultihashToDsKey(c.Hash()))
+}
+
+func (m *dsMarkSet) count() int {
+	return m.n
+}
+
+func (m *dsMarkSet) close() error {
+	if err := m.ds.Close(); err != nil {
+		return err
+	}
+	return os.RemoveAll(m.path)
+}
@@ -0,0 +1,625 @@
+// Package splitstore implements a chain blockstore split into a hot store,
+// which all writes go to, and a cold store. Compaction periodically moves the
+// objects of the hot store which are no longer part of the recent chain to the
+// cold store, or discards them, so that the hot store stays small.
+package splitstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	logging "github.com/ipfs/go-log/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	bstore "github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+var log = logging.Logger("splitstore")
+
+var (
+	// epochsPrefix prefixes the keys of the epochs objects were last written
+	// to the hot store at
+	epochsPrefix = datastore.NewKey("/splitstore/epochs")
+	// baseEpochKey is the key of the epoch of the last compaction
+	baseEpochKey = datastore.NewKey("/splitstore/baseEpoch")
+)
+
+// compactionBatch is the number of objects moved out of the hot store at once
+const compactionBatch = 1024
+
+// syncGap is how far the head may be behind the current time for compactions
+// to be started automatically; they wait for the end of a chain sync
+const syncGap = 5 * time.Minute
+
+type Config struct {
+	// HotRetention is the number of epochs of chain state and messages
+	// compactions keep in the hot store
+	HotRetention abi.ChainEpoch
+	// CompactionInterval is the number of epochs between automatic
+	// compactions. 0 disables them
+	CompactionInterval abi.ChainEpoch
+	// Discard makes compactions delete the objects leaving the hot store
+	// instead of moving them to the cold store
+	Discard bool
+	// GC, if set, is called after compactions to reclaim the disk space of
+	// the objects removed from the hot store. It must hold writes while it
+	// can't run alongside writes to the hot store, and returns the size of the
+	// hot store before and after.
+	GC func(ctx context.Context, writes sync.Locker) (before, after int64, err error)
+	// MarkSetPath is the directory the objects kept in the hot store are
+	// marked in during compactions; empty to mark them in memory
+	MarkSetPath string
+}
+
+// ChainAccessor is the part of the chain store the split store walks the chain
+// with
+type ChainAccessor interface {
+	GetHeaviestTipSet() *types.TipSet
+	LoadTipSet(types.TipSetKey) (*types.TipSet, error)
+}
+
+// CompactionStats describes the outcome of a compaction
+type CompactionStats struct {
+	Marked    int
+	Moved     int
+	Discarded int
+}
+
+type SplitStore struct {
+	cfg Config
+
+	hot  bstore.Blockstore
+	cold bstore.Blockstore
+	ds   datastore.Batching
+
+	chain    ChainAccessor
+	curEpoch int64 // atomic
+
+	// held for writing while objects are removed from the hot store, so
+	// that writes don't race with the check of their epoch
+	lk sync.RWMutex
+
+	compacting int32 // atomic
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+var _ bstore.Blockstore = (*SplitStore)(nil)
+var _ bstore.Viewer = (*SplitStore)(nil)
+
+// New creates a split store over the hot and cold stores. The epochs objects
+// are written at are tracked in ds. Compactions delete objects from the hot
+// store, so a cache must wrap the hot store, not the split store, or it keeps
+// reporting deleted objects as present and writing them again is skipped.
+func New(hot, cold bstore.Blockstore, ds datastore.Batching, cfg Config) (*SplitStore, error) {
+	if cfg.HotRetention <= 0 {
+		return nil, xerrors.Errorf("hot store retention must be positive, got %d", cfg.HotRetention)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SplitStore{
+		cfg:    cfg,
+		hot:    hot,
+		cold:   cold,
+		ds:     ds,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Used returns whether a split store was started with the metadata datastore.
+// The recent chain is then only in its hot store, so the chain can't be read
+// without the split store anymore.
+func Used(ds datastore.Datastore) (bool, error) {
+	return ds.Has(baseEpochKey)
+}
+
+// Start starts tracking the chain, whose head changes have to be passed to
+// HeadChange
+func (s *SplitStore) Start(chain ChainAccessor) error {
+	s.chain = chain
+
+	head := chain.GetHeaviestTipSet()
+	if head == nil {
+		return nil
+	}
+	atomic.StoreInt64(&s.curEpoch, int64(head.Height()))
+
+	// compactions are counted from the first start
+	if _, err := s.ds.Get(baseEpochKey); err == datastore.ErrNotFound {
+		return s.setBaseEpoch(head.Height())
+	} else if err != nil {
+		return xerrors.Errorf("getting base epoch: %w", err)
+	}
+
+	return nil
+}
+
+// Close waits for a running compaction to stop. The hot and cold stores are
+// not closed.
+func (s *SplitStore) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+// HeadChange tracks the epoch objects are written at, and starts compactions
+// every CompactionInterval epochs
+func (s *SplitStore) HeadChange(_, apply []*types.TipSet) error {
+	if len(apply) == 0 {
+		return nil
+	}
+
+	head := apply[len(apply)-1]
+	atomic.StoreInt64(&s.curEpoch, int64(head.Height()))
+
+	if s.cfg.CompactionInterval <= 0 || head.Height() <= s.cfg.HotRetention {
+		return nil
+	}
+	if time.Since(time.Unix(int64(head.MinTimestamp()), 0)) > syncGap {
+		return nil
+	}
+
+	base, err := s.baseEpoch()
+	if err != nil {
+		return err
+	}
+	if head.Height()-base < s.cfg.CompactionInterval {
+		return nil
+	}
+
+	if !atomic.CompareAndSwapInt32(&s.compacting, 0, 1) {
+		return nil
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer atomic.StoreInt32(&s.compacting, 0)
+
+		if _, err := s.compact(s.ctx); err != nil {
+			log.Errorf("compacting hot store: %s", err)
+		}
+	}()
+
+	return nil
+}
+
+// Compact moves the objects which aren't part of the last HotRetention epochs
+// of the chain out of the hot store
+func (s *SplitStore) Compact(ctx context.Context) (*CompactionStats, error) {
+	if s.chain == nil {
+		return nil, xerrors.New("split store not started")
+	}
+	if !atomic.CompareAndSwapInt32(&s.compacting, 0, 1) {
+		return nil, xerrors.New("compaction already in progress")
+	}
+	defer atomic.StoreInt32(&s.compacting, 0)
+
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return s.compact(ctx)
+}
+
+// CollectGarbage reclaims the disk space of the objects compactions removed
+// from the hot store, pausing writes while the GC requires it, and returns the
+// size of the hot store before and after
+func (s *SplitStore) CollectGarbage(ctx context.Context) (before, after int64, err error) {
+	if s.cfg.GC == nil {
+		return 0, 0, xerrors.New("hot store garbage collection not configured")
+	}
+	if !atomic.CompareAndSwapInt32(&s.compacting, 0, 1) {
+		return 0, 0, xerrors.New("compaction already in progress")
+	}
+	defer atomic.StoreInt32(&s.compacting, 0)
+
+	return s.cfg.GC(ctx, &s.lk)
+}
+
+func (s *SplitStore) compact(ctx context.Context) (*CompactionStats, error) {
+	head := s.chain.GetHeaviestTipSet()
+	boundary := head.Height() - s.cfg.HotRetention
+	if boundary <= 0 {
+		return &CompactionStats{}, nil
+	}
+
+	start := time.Now()
+	log.Infow("compacting hot store", "head", head.Height(), "boundary", boundary, "discard", s.cfg.Discard)
+
+	marked, err := newMarkSet(s.cfg.MarkSetPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := marked.close(); err != nil {
+			log.Errorf("closing mark set: %s", err)
+		}
+	}()
+
+	if err := s.mark(ctx, head, boundary, marked); err != nil {
+		return nil, xerrors.Errorf("marking live objects: %w", err)
+	}
+	stats := &CompactionStats{Marked: marked.count()}
+
+	keys, err := s.hot.AllKeysChan(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("listing hot objects: %w", err)
+	}
+
+	var batch []cid.Cid
+	for c := range keys {
+		live, err := marked.has(c)
+		if err != nil {
+			return nil, xerrors.Errorf("checking mark of %s: %w", c, err)
+		}
+		if live {
+			continue
+		}
+
+		batch = append(batch, c)
+		if len(batch) < compactionBatch {
+			continue
+		}
+
+		if err := s.evict(batch, boundary, stats); err != nil {
+			return nil, err
+		}
+		batch = batch[:0]
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.evict(batch, boundary, stats); err != nil {
+		return nil, err
+	}
+
+	if err := s.setBaseEpoch(head.Height()); err != nil {
+		return nil, err
+	}
+
+	if s.cfg.GC != nil {
+		if _, _, err := s.cfg.GC(ctx, &s.lk); err != nil {
+			return nil, xerrors.Errorf("collecting hot store garbage: %w", err)
+		}
+	}
+
+	log.Infow("compacted hot store", "marked", stats.Marked, "moved", stats.Moved, "discarded", stats.Discarded, "took", time.Since(start))
+	return stats, nil
+}
+
+// mark marks the objects of the chain down to the boundary epoch: block
+// headers, messages, receipts and state trees. When discarding, all block
+// headers and the genesis state are kept as well.
+func (s *SplitStore) mark(ctx context.Context, head *types.TipSet, boundary abi.ChainEpoch, marked markSet) error {
+	ts := head
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for _, b := range ts.Blocks() {
+			if _, err := marked.visit(b.Cid()); err != nil {
+				return err
+			}
+
+			if b.Height < boundary && b.Height > 0 {
+				continue
+			}
+
+			roots := []cid.Cid{b.Messages, b.ParentMessageReceipts, b.ParentStateRoot}
+			if b.Height == 0 {
+				roots = append(roots, b.Parents...)
+			}
+			for _, r := range roots {
+				if err := s.walk(r, marked); err != nil {
+					return xerrors.Errorf("walking block %s at %d: %w", b.Cid(), b.Height, err)
+				}
+			}
+		}
+
+		if ts.Height() == 0 {
+			return nil
+		}
+
+		var err error
+		ts, err = s.chain.LoadTipSet(ts.Parents())
+		if err != nil {
+			return xerrors.Errorf("loading parent tipset: %w", err)
+		}
+
+		if ts.Height() < boundary && !s.cfg.Discard {
+			return nil
+		}
+	}
+}
+
+// walk marks the DAG under root. Objects missing from the store, e.g. old state
+// which isn't part of a snapshot, are skipped.
+func (s *SplitStore) walk(root cid.Cid, marked markSet) error {
+	stack := []cid.Cid{root}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		visited, err := marked.visit(c)
+		if err != nil {
+			return xerrors.Errorf("marking %s: %w", c, err)
+		}
+		if !visited || c.Prefix().Codec != cid.DagCBOR {
+			continue
+		}
+
+		err = s.View(c, func(data []byte) error {
+			return cbg.ScanForLinks(bytes.NewReader(data), func(l cid.Cid) {
+				stack = append(stack, l)
+			})
+		})
+		if err == bstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return xerrors.Errorf("scanning %s: %w", c, err)
+		}
+	}
+
+	return nil
+}
+
+// evict moves the objects written before the boundary epoch out of the hot
+// store
+func (s *SplitStore) evict(batch []cid.Cid, boundary abi.ChainEpoch, stats *CompactionStats) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	var move []blocks.Block
+	var remove []cid.Cid
+	for _, c := range batch {
+		epoch, err := s.writeEpoch(c)
+		if err != nil {
+			return err
+		}
+		if epoch >= boundary {
+			continue
+		}
+
+		if !s.cfg.Discard {
+			blk, err := s.hot.Get(c)
+			if err == bstore.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return xerrors.Errorf("getting hot object %s: %w", c, err)
+			}
+			move = append(move, blk)
+		}
+		remove = append(remove, c)
+	}
+
+	if len(move) > 0 {
+		if err := s.cold.PutMany(move); err != nil {
+			return xerrors.Errorf("moving objects to the cold store: %w", err)
+		}
+	}
+
+	b, err := s.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, c := range remove {
+		if err := s.hot.DeleteBlock(c); err != nil {
+			return xerrors.Errorf("deleting hot object %s: %w", c, err)
+		}
+		if err := b.Delete(epochKey(c)); err != nil {
+			return err
+		}
+	}
+	if err := b.Commit(); err != nil {
+		return xerrors.Errorf("deleting object epochs: %w", err)
+	}
+
+	if s.cfg.Discard {
+		stats.Discarded += len(remove)
+	} else {
+		stats.Moved += len(remove)
+	}
+	return nil
+}
+
+func epochKey(c cid.Cid) datastore.Key {
+	return epochsPrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+}
+
+func encodeEpoch(epoch abi.ChainEpoch) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, uint64(epoch))]
+}
+
+func decodeEpoch(b []byte) (abi.ChainEpoch, error) {
+	epoch, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, xerrors.New("invalid epoch encoding")
+	}
+	return abi.ChainEpoch(epoch), nil
+}
+
+// writeEpoch returns the epoch the object was last written to the hot store
+// at, 0 if it wasn't tracked
+func (s *SplitStore) writeEpoch(c cid.Cid) (abi.ChainEpoch, error) {
+	b, err := s.ds.Get(epochKey(c))
+	if err == datastore.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("getting epoch of %s: %w", c, err)
+	}
+	return decodeEpoch(b)
+}
+
+func (s *SplitStore) baseEpoch() (abi.ChainEpoch, error) {
+	b, err := s.ds.Get(baseEpochKey)
+	if err == datastore.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("getting base epoch: %w", err)
+	}
+	return decodeEpoch(b)
+}
+
+func (s *SplitStore) setBaseEpoch(epoch abi.ChainEpoch) error {
+	if err := s.ds.Put(baseEpochKey, encodeEpoch(epoch)); err != nil {
+		return xerrors.Errorf("setting base epoch: %w", err)
+	}
+	return nil
+}
+
+// Blockstore interface
+
+func (s *SplitStore) DeleteBlock(c cid.Cid) error {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+
+	if err := s.hot.DeleteBlock(c); err != nil {
+		return err
+	}
+	if err := s.ds.Delete(epochKey(c)); err != nil {
+		return err
+	}
+	return s.cold.DeleteBlock(c)
+}
+
+func (s *SplitStore) Has(c cid.Cid) (bool, error) {
+	has, err := s.hot.Has(c)
+	if err != nil || has {
+		return has, err
+	}
+	return s.cold.Has(c)
+}
+
+func (s *SplitStore) Get(c cid.Cid) (blocks.Block, error) {
+	blk, err := s.hot.Get(c)
+	if err == bstore.ErrNotFound {
+		return s.cold.Get(c)
+	}
+	return blk, err
+}
+
+func (s *SplitStore) GetSize(c cid.Cid) (int, error) {
+	size, err := s.hot.GetSize(c)
+	if err == bstore.ErrNotFound {
+		return s.cold.GetSize(c)
+	}
+	return size, err
+}
+
+func (s *SplitStore) View(c cid.Cid, callback func([]byte) error) error {
+	err := view(s.hot, c, callback)
+	if err == bstore.ErrNotFound {
+		return view(s.cold, c, callback)
+	}
+	return err
+}
+
+func view(bs bstore.Blockstore, c cid.Cid, callback func([]byte) error) error {
+	if v, ok := bs.(bstore.Viewer); ok {
+		return v.View(c, callback)
+	}
+
+	blk, err := bs.Get(c)
+	if err != nil {
+		return err
+	}
+	return callback(blk.RawData())
+}
+
+func (s *SplitStore) Put(blk blocks.Block) error {
+	return s.PutMany([]blocks.Block{blk})
+}
+
+func (s *SplitStore) PutMany(blks []blocks.Block) error {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+
+	if err := s.hot.PutMany(blks); err != nil {
+		return err
+	}
+
+	epoch := encodeEpoch(abi.ChainEpoch(atomic.LoadInt64(&s.curEpoch)))
+	b, err := s.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, blk := range blks {
+		if err := b.Put(epochKey(blk.Cid()), epoch); err != nil {
+			return err
+		}
+	}
+	if err := b.Commit(); err != nil {
+		return xerrors.Errorf("tracking object epochs: %w", err)
+	}
+
+	return nil
+}
+
+// AllKeysChan returns the keys of the hot store followed by the keys of the
+// cold store. Objects written to the hot store again after they were moved
+// to the cold store are returned twice.
+func (s *SplitStore) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	hot, err := s.hot.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cold, err := s.cold.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan cid.Cid)
+	go func() {
+		defer close(out)
+		for _, ch := range []<-chan cid.Cid{hot, cold} {
+			for c := range ch {
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *SplitStore) HashOnRead(enabled bool) {
+	s.hot.HashOnRead(enabled)
+	s.cold.HashOnRead(enabled)
+}
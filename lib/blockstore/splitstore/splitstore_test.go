@@ -0,0 +1,196 @@
+package splitstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	bstore "github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+type testChain struct {
+	head    *types.TipSet
+	tipsets map[types.TipSetKey]*types.TipSet
+}
+
+func (c *testChain) GetHeaviestTipSet() *types.TipSet {
+	return c.head
+}
+
+func (c *testChain) LoadTipSet(tsk types.TipSetKey) (*types.TipSet, error) {
+	return c.tipsets[tsk], nil
+}
+
+func mkObject(t *testing.T, v interface{}) blocks.Block {
+	n, err := cbor.WrapObject(v, mh.SHA2_256, -1)
+	require.NoError(t, err)
+	return n
+}
+
+func testCompaction(t *testing.T, discard bool, markSetPath string) {
+	hot := bstore.NewTemporarySync()
+	cold := bstore.NewTemporarySync()
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	ss, err := New(hot, cold, ds, Config{
+		HotRetention: 2,
+		Discard:      discard,
+		MarkSetPath:  markSetPath,
+	})
+	require.NoError(t, err)
+
+	chain := &testChain{tipsets: map[types.TipSetKey]*types.TipSet{}}
+
+	// each epoch has a state root linking to an object of its own
+	var states []cid.Cid
+	var headers []cid.Cid
+	var parent *types.TipSet
+	orphan := mkObject(t, "orphan")
+	for h := 0; h < 6; h++ {
+		leaf := mkObject(t, map[string]interface{}{"epoch": h})
+		state := mkObject(t, map[string]interface{}{"leaf": leaf.Cid()})
+		require.NoError(t, ss.PutMany([]blocks.Block{leaf, state}))
+		states = append(states, state.Cid())
+
+		if h == 1 {
+			require.NoError(t, ss.Put(orphan))
+		}
+
+		blk := mock.MkBlock(parent, 1, uint64(h))
+		blk.ParentStateRoot = state.Cid()
+		sblk, err := blk.ToStorageBlock()
+		require.NoError(t, err)
+		require.NoError(t, ss.Put(sblk))
+		headers = append(headers, blk.Cid())
+
+		ts := mock.TipSet(blk)
+		chain.tipsets[ts.Key()] = ts
+		chain.head = ts
+		parent = ts
+
+		if h == 0 {
+			used, err := Used(ds)
+			require.NoError(t, err)
+			require.False(t, used)
+
+			require.NoError(t, ss.Start(chain))
+
+			used, err = Used(ds)
+			require.NoError(t, err)
+			require.True(t, used)
+		}
+		require.NoError(t, ss.HeadChange(nil, []*types.TipSet{ts}))
+	}
+
+	// objects which aren't part of the chain are only evicted once they are old
+	fresh := mkObject(t, "fresh")
+	require.NoError(t, ss.Put(fresh))
+
+	stats, err := ss.Compact(context.Background())
+	require.NoError(t, err)
+
+	// the boundary is at epoch 3; when discarding, the genesis state and all
+	// headers stay in the hot store
+	inHot := func(c cid.Cid) bool {
+		has, err := hot.Has(c)
+		require.NoError(t, err)
+		return has
+	}
+	inCold := func(c cid.Cid) bool {
+		has, err := cold.Has(c)
+		require.NoError(t, err)
+		return has
+	}
+
+	for h, c := range states {
+		require.Equal(t, h >= 3 || (discard && h == 0), inHot(c), "state %d", h)
+		require.Equal(t, !discard && h < 3, inCold(c), "state %d", h)
+	}
+	for h, c := range headers {
+		require.Equal(t, discard || h >= 3, inHot(c), "header %d", h)
+	}
+
+	require.False(t, inHot(orphan.Cid()))
+	require.Equal(t, !discard, inCold(orphan.Cid()))
+	require.True(t, inHot(fresh.Cid()))
+
+	if discard {
+		require.Equal(t, 5, stats.Discarded)
+		require.Zero(t, stats.Moved)
+	} else {
+		require.Equal(t, 10, stats.Moved)
+		require.Zero(t, stats.Discarded)
+
+		// moved objects are still readable
+		_, err := ss.Get(states[1])
+		require.NoError(t, err)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	t.Run("move", func(t *testing.T) {
+		testCompaction(t, false, "")
+	})
+	t.Run("discard", func(t *testing.T) {
+		testCompaction(t, true, "")
+	})
+	t.Run("mark on disk", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "splitstore-mark")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir) //nolint:errcheck
+
+		path := filepath.Join(dir, "mark")
+		testCompaction(t, true, path)
+
+		_, err = os.Stat(path)
+		require.True(t, os.IsNotExist(err), "mark set must be removed after compacting")
+	})
+}
+
+func TestCollectGarbage(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	ss, err := New(bstore.NewTemporarySync(), bstore.NewTemporarySync(), ds, Config{HotRetention: 2})
+	require.NoError(t, err)
+
+	_, _, err = ss.CollectGarbage(context.Background())
+	require.Error(t, err, "gc must fail when it isn't configured")
+
+	blk := mkObject(t, "paused")
+	put := make(chan error, 1)
+	ss.cfg.GC = func(ctx context.Context, writes sync.Locker) (int64, int64, error) {
+		writes.Lock()
+		go func() {
+			put <- ss.Put(blk)
+		}()
+
+		// writes wait while the lock is held
+		select {
+		case <-put:
+			t.Error("write not paused during gc")
+		case <-time.After(50 * time.Millisecond):
+		}
+		writes.Unlock()
+
+		return 2, 1, nil
+	}
+
+	before, after, err := ss.CollectGarbage(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), before)
+	require.Equal(t, int64(1), after)
+	require.NoError(t, <-put)
+}
@@ -0,0 +1,232 @@
+// Package cborrpc lets machine-to-machine callers of the JSON-RPC API receive
+// large results (sector lists, deadline partitions, mpool dumps) encoded with
+// CBOR, which is much cheaper to encode than JSON.
+//
+// The encoding is negotiated per connection: HTTP calls to the JSON-RPC
+// endpoint which send an Accept header including application/cbor get the
+// result of the call as the CBOR encoded body of the response, when the result
+// type has a CBOR encoding. Other calls, and calls over websockets, are served
+// by the JSON-RPC server as usual. The Content-Type of the response tells
+// which encoding was used.
+package cborrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+
+	logging "github.com/ipfs/go-log/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+var log = logging.Logger("cborrpc")
+
+const (
+	ContentTypeCBOR = "application/cbor"
+	ContentTypeJSON = "application/json"
+)
+
+var (
+	contextType   = reflect.TypeOf(new(context.Context)).Elem()
+	errorType     = reflect.TypeOf(new(error)).Elem()
+	marshalerType = reflect.TypeOf(new(cbg.CBORMarshaler)).Elem()
+)
+
+// Handler serves the calls of callers accepting CBOR to the methods of an API
+// implementation, and passes the other requests to the JSON-RPC server
+type Handler struct {
+	namespace string
+	api       reflect.Value
+	next      http.Handler
+}
+
+// NewHandler returns a handler serving calls to the methods of api registered
+// under the namespace with the JSON-RPC server next. Permissions are enforced
+// by api, usually a permissioned apistruct, so the handler should be wrapped
+// in an auth.Handler.
+func NewHandler(namespace string, api interface{}, next http.Handler) *Handler {
+	return &Handler{
+		namespace: namespace,
+		api:       reflect.ValueOf(api),
+		next:      next,
+	}
+}
+
+// request is a JSON-RPC request
+type request struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	ID      interface{}       `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type respError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// response is a JSON-RPC response
+type response struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	ID      interface{}     `json:"id"`
+	Error   *respError      `json:"error,omitempty"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !acceptsCBOR(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, nil, http.StatusBadRequest, xerrors.Errorf("reading request: %w", err))
+		return
+	}
+
+	// the request is passed on as is when the result isn't encoded here
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	var req request
+	if err := json.Unmarshal(b, &req); err != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	if !strings.HasPrefix(req.Method, h.namespace+".") {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	m := h.api.MethodByName(strings.TrimPrefix(req.Method, h.namespace+"."))
+	if !m.IsValid() || !returnsCBOR(m.Type()) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	params, err := decodeParams(r.Context(), m.Type(), req.Params)
+	if err != nil {
+		writeError(w, req.ID, http.StatusBadRequest, err)
+		return
+	}
+
+	out := m.Call(params)
+	if errv := out[len(out)-1]; !errv.IsNil() {
+		writeError(w, req.ID, http.StatusOK, errv.Interface().(error))
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeCBOR)
+	if err := writeCBOR(w, out[0]); err != nil {
+		// the status was already sent, and the response is cut short
+		log.Errorf("writing cbor result of %s: %+v", req.Method, err)
+	}
+}
+
+// returnsCBOR returns whether the method of type mt takes a context, and
+// returns a result with a CBOR encoding and an error
+func returnsCBOR(mt reflect.Type) bool {
+	if mt.NumIn() == 0 || mt.In(0) != contextType {
+		return false
+	}
+	if mt.NumOut() != 2 || mt.Out(1) != errorType {
+		return false
+	}
+	return hasCBOR(mt.Out(0))
+}
+
+// decodeParams decodes the JSON params of a call to a method of type mt
+func decodeParams(ctx context.Context, mt reflect.Type, raw []json.RawMessage) ([]reflect.Value, error) {
+	if len(raw) != mt.NumIn()-1 {
+		return nil, xerrors.Errorf("expected %d params, got %d", mt.NumIn()-1, len(raw))
+	}
+
+	params := []reflect.Value{reflect.ValueOf(ctx)}
+	for i, p := range raw {
+		v := reflect.New(mt.In(i + 1))
+		if err := json.Unmarshal(p, v.Interface()); err != nil {
+			return nil, xerrors.Errorf("decoding param %d: %w", i, err)
+		}
+		params = append(params, v.Elem())
+	}
+
+	return params, nil
+}
+
+func acceptsCBOR(r *http.Request) bool {
+	for _, a := range r.Header.Values("Accept") {
+		for _, t := range strings.Split(a, ",") {
+			if strings.TrimSpace(strings.Split(t, ";")[0]) == ContentTypeCBOR {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func implementsMarshaler(t reflect.Type) bool {
+	return t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType)
+}
+
+// hasCBOR returns whether the result type has a CBOR encoding: it is a
+// cbor-gen marshaler, or a slice of them
+func hasCBOR(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		return implementsMarshaler(t.Elem())
+	}
+	return implementsMarshaler(t)
+}
+
+// writeCBOR encodes the result. Elements of slices are encoded one at a time,
+// so large lists are streamed to the caller instead of being buffered.
+func writeCBOR(w io.Writer, res reflect.Value) error {
+	if res.Kind() != reflect.Slice {
+		return marshaler(res).MarshalCBOR(w)
+	}
+
+	if res.IsNil() {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	if err := cbg.WriteMajorTypeHeader(w, cbg.MajArray, uint64(res.Len())); err != nil {
+		return err
+	}
+
+	for i := 0; i < res.Len(); i++ {
+		if err := marshaler(res.Index(i)).MarshalCBOR(w); err != nil {
+			return xerrors.Errorf("encoding element %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func marshaler(v reflect.Value) cbg.CBORMarshaler {
+	if m, ok := v.Interface().(cbg.CBORMarshaler); ok {
+		return m
+	}
+
+	// value types with pointer receivers
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface().(cbg.CBORMarshaler)
+}
+
+// writeError writes a JSON-RPC error response, like the JSON-RPC server does
+func writeError(w http.ResponseWriter, id interface{}, status int, err error) {
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response{
+		Jsonrpc: "2.0",
+		ID:      id,
+		Error:   &respError{Code: 1, Message: err.Error()},
+	})
+}
@@ -0,0 +1,113 @@
+package cborrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	tutils "github.com/filecoin-project/specs-actors/v2/support/testing"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type testAPI struct{}
+
+func (testAPI) Receipts(ctx context.Context, n int) ([]types.MessageReceipt, error) {
+	out := make([]types.MessageReceipt, n)
+	for i := range out {
+		out[i] = types.MessageReceipt{ExitCode: exitcode.ExitCode(i), GasUsed: int64(i)}
+	}
+	return out, nil
+}
+
+func (testAPI) Receipt(ctx context.Context) (*types.MessageReceipt, error) {
+	return &types.MessageReceipt{GasUsed: 7}, nil
+}
+
+func (testAPI) Names(ctx context.Context) (map[string]int, error) {
+	return map[string]int{"a": 1}, nil
+}
+
+func (testAPI) Fail(ctx context.Context) error {
+	return xerrors.New("failed")
+}
+
+func (testAPI) Sectors(ctx context.Context) ([]*miner.SectorOnChainInfo, error) {
+	return []*miner.SectorOnChainInfo{
+		{SectorNumber: 1, SealedCID: tutils.MakeCID("sealed", nil), DealIDs: []abi.DealID{3}},
+	}, nil
+}
+
+func TestCall(t *testing.T) {
+	rpcServer := jsonrpc.NewServer()
+	rpcServer.Register("Filecoin", testAPI{})
+
+	srv := httptest.NewServer(NewHandler("Filecoin", testAPI{}, rpcServer))
+	defer srv.Close()
+
+	ctx := context.Background()
+	url := srv.URL + "/rpc/v0"
+
+	var rcpts []types.MessageReceipt
+	require.NoError(t, Call(ctx, url, nil, "Filecoin.Receipts", []interface{}{3}, &rcpts))
+	require.Len(t, rcpts, 3)
+	require.Equal(t, int64(2), rcpts[2].GasUsed)
+	require.Equal(t, exitcode.ExitCode(1), rcpts[1].ExitCode)
+
+	var prcpts []*types.MessageReceipt
+	require.NoError(t, Call(ctx, url, nil, "Filecoin.Receipts", []interface{}{2}, &prcpts))
+	require.Len(t, prcpts, 2)
+	require.Equal(t, int64(1), prcpts[1].GasUsed)
+
+	var rcpt types.MessageReceipt
+	require.NoError(t, Call(ctx, url, nil, "Filecoin.Receipt", nil, &rcpt))
+	require.Equal(t, int64(7), rcpt.GasUsed)
+
+	var sectors []*miner.SectorOnChainInfo
+	require.NoError(t, Call(ctx, url, nil, "Filecoin.Sectors", nil, &sectors))
+	require.Len(t, sectors, 1)
+	require.Equal(t, []abi.DealID{3}, sectors[0].DealIDs)
+
+	// served by the json-rpc server
+	var names map[string]int
+	require.NoError(t, Call(ctx, url, nil, "Filecoin.Names", nil, &names))
+	require.Equal(t, 1, names["a"])
+
+	require.EqualError(t, Call(ctx, url, nil, "Filecoin.Fail", nil, nil), "failed")
+	require.Error(t, Call(ctx, url, nil, "Filecoin.Nope", nil, nil))
+	require.Error(t, Call(ctx, url, nil, "Filecoin.Receipts", nil, &rcpts))
+}
+
+func TestNegotiate(t *testing.T) {
+	rpcServer := jsonrpc.NewServer()
+	rpcServer.Register("Filecoin", testAPI{})
+
+	srv := httptest.NewServer(NewHandler("Filecoin", testAPI{}, rpcServer))
+	defer srv.Close()
+
+	call := func(accept string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"Filecoin.Receipt","params":[]}`))
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp
+	}
+
+	require.Equal(t, ContentTypeCBOR, call("application/cbor;q=0.9, application/json").Header.Get("Content-Type"))
+	require.NotEqual(t, ContentTypeCBOR, call("").Header.Get("Content-Type"))
+	require.NotEqual(t, ContentTypeCBOR, call(ContentTypeJSON).Header.Get("Content-Type"))
+}
@@ -0,0 +1,155 @@
+package cborrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+var unmarshalerType = reflect.TypeOf(new(cbg.CBORUnmarshaler)).Elem()
+
+// Call calls the method, e.g. Filecoin.StateMinerSectors, of the JSON-RPC
+// endpoint at url with the params, decoding the result into out, which must be
+// a pointer. CBOR encoding of the result is requested when out points to a
+// cbor-gen type, or a slice of them.
+func Call(ctx context.Context, url string, header http.Header, method string, params []interface{}, out interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	var raw []json.RawMessage
+	for i, p := range params {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return xerrors.Errorf("encoding param %d: %w", i, err)
+		}
+		raw = append(raw, b)
+	}
+
+	body, err := json.Marshal(request{
+		Jsonrpc: "2.0",
+		ID:      1,
+		Method:  method,
+		Params:  raw,
+	})
+	if err != nil {
+		return xerrors.Errorf("encoding request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return xerrors.Errorf("creating request: %w", err)
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	if out != nil && decodesCBOR(reflect.TypeOf(out)) {
+		req.Header.Set("Accept", ContentTypeCBOR+", "+ContentTypeJSON)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return xerrors.Errorf("calling %s: %w", method, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("Content-Type") == ContentTypeCBOR {
+		if out == nil {
+			return nil
+		}
+		return readCBOR(resp.Body, reflect.ValueOf(out))
+	}
+
+	var res response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return xerrors.Errorf("call failed with status %d", resp.StatusCode)
+	}
+	if res.Error != nil {
+		return xerrors.New(res.Error.Message)
+	}
+
+	if out == nil || len(res.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(res.Result, out)
+}
+
+// decodesCBOR returns whether the pointer type can be decoded from CBOR
+func decodesCBOR(pt reflect.Type) bool {
+	if pt.Kind() != reflect.Ptr {
+		return false
+	}
+
+	if pt.Implements(unmarshalerType) {
+		return true
+	}
+
+	t := pt.Elem()
+	if t.Kind() != reflect.Slice || t.Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+
+	et := t.Elem()
+	if et.Kind() == reflect.Ptr {
+		return et.Implements(unmarshalerType)
+	}
+	return reflect.PtrTo(et).Implements(unmarshalerType)
+}
+
+func readCBOR(r io.Reader, out reflect.Value) error {
+	if u, ok := out.Interface().(cbg.CBORUnmarshaler); ok {
+		return u.UnmarshalCBOR(r)
+	}
+
+	br := cbg.GetPeeker(r)
+
+	pb, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	if pb == cbg.CborNull[0] {
+		out.Elem().Set(reflect.Zero(out.Elem().Type()))
+		return nil
+	}
+	if err := br.UnreadByte(); err != nil {
+		return err
+	}
+
+	maj, n, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return xerrors.Errorf("expected cbor array")
+	}
+
+	st := out.Elem().Type()
+	et := st.Elem()
+
+	res := reflect.MakeSlice(st, int(n), int(n))
+	for i := 0; i < int(n); i++ {
+		var ev reflect.Value
+		if et.Kind() == reflect.Ptr {
+			ev = reflect.New(et.Elem())
+			res.Index(i).Set(ev)
+		} else {
+			ev = res.Index(i).Addr()
+		}
+
+		if err := ev.Interface().(cbg.CBORUnmarshaler).UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("decoding element %d: %w", i, err)
+		}
+	}
+
+	out.Elem().Set(res)
+	return nil
+}
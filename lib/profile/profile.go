@@ -0,0 +1,54 @@
+// Package profile captures runtime profiles of the process, so that they can
+// be requested over the API
+package profile
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const CPU = "cpu"
+
+// Profiles lists the names of the profiles which can be captured
+var Profiles = []string{CPU, "heap", "allocs", "goroutine", "block", "mutex", "threadcreate"}
+
+// Capture returns the named profile in the pprof format. The CPU profile is
+// sampled for the given duration, other profiles are snapshots
+func Capture(ctx context.Context, name string, duration time.Duration) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if name == CPU {
+		if duration <= 0 {
+			return nil, xerrors.Errorf("the cpu profile requires a duration")
+		}
+
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return nil, xerrors.Errorf("starting cpu profile: %w", err)
+		}
+
+		select {
+		case <-time.After(duration):
+		case <-ctx.Done():
+			pprof.StopCPUProfile()
+			return nil, ctx.Err()
+		}
+
+		pprof.StopCPUProfile()
+		return buf.Bytes(), nil
+	}
+
+	p := pprof.Lookup(name)
+	if p == nil {
+		return nil, xerrors.Errorf("unknown profile %q", name)
+	}
+
+	if err := p.WriteTo(&buf, 0); err != nil {
+		return nil, xerrors.Errorf("writing %s profile: %w", name, err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,27 @@
+package profile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := Capture(ctx, "goroutine", 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, b)
+
+	b, err = Capture(ctx, CPU, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.NotEmpty(t, b)
+
+	_, err = Capture(ctx, CPU, 0)
+	require.Error(t, err)
+
+	_, err = Capture(ctx, "nope", 0)
+	require.Error(t, err)
+}
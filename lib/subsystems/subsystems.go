@@ -0,0 +1,240 @@
+package subsystems
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+var log = logging.Logger("subsystems")
+
+var ErrNotFound = xerrors.New("subsystem not found")
+
+// Subsystem is a part of the node which can be stopped and started again
+// while the node is running
+type Subsystem struct {
+	Name        string
+	Description string
+	// DependsOn lists the subsystems which must be running for this one to
+	// run
+	DependsOn []string
+
+	// Start and Stop are called when the subsystem is started and stopped.
+	// Subsystems without them are switches checked by the code they gate.
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+type entry struct {
+	Subsystem
+
+	running bool
+	since   time.Time
+	err     string
+}
+
+// Registry tracks the subsystems of the node, and starts and stops them
+// respecting their dependencies
+type Registry struct {
+	// serializes starting and stopping, which can take a while, so that
+	// Running isn't blocked meanwhile
+	changeLk sync.Mutex
+
+	lk   sync.Mutex
+	subs map[string]*entry
+}
+
+func New() *Registry {
+	return &Registry{
+		subs: map[string]*entry{},
+	}
+}
+
+// Register adds a stopped subsystem to the registry
+func (r *Registry) Register(s Subsystem) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if _, ok := r.subs[s.Name]; ok {
+		return xerrors.Errorf("subsystem %s already registered", s.Name)
+	}
+
+	r.subs[s.Name] = &entry{
+		Subsystem: s,
+		since:     time.Now(),
+	}
+
+	return nil
+}
+
+// Running returns whether the subsystem is registered and running
+func (r *Registry) Running(name string) bool {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	e, ok := r.subs[name]
+	return ok && e.running
+}
+
+// Start starts the subsystem, if all subsystems it depends on are running
+func (r *Registry) Start(ctx context.Context, name string) error {
+	r.changeLk.Lock()
+	defer r.changeLk.Unlock()
+
+	r.lk.Lock()
+	e, ok := r.subs[name]
+	if !ok {
+		r.lk.Unlock()
+		return xerrors.Errorf("%s: %w", name, ErrNotFound)
+	}
+	if e.running {
+		r.lk.Unlock()
+		return nil
+	}
+	for _, dep := range e.DependsOn {
+		if d, ok := r.subs[dep]; !ok || !d.running {
+			r.lk.Unlock()
+			return xerrors.Errorf("%s depends on %s, which isn't running", name, dep)
+		}
+	}
+	r.lk.Unlock()
+
+	var err error
+	if e.Start != nil {
+		err = e.Start(ctx)
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if err != nil {
+		e.err = err.Error()
+		return xerrors.Errorf("starting %s: %w", name, err)
+	}
+
+	e.running = true
+	e.since = time.Now()
+	e.err = ""
+
+	log.Infow("started subsystem", "name", name)
+
+	return nil
+}
+
+// Stop stops the subsystem, if no running subsystem depends on it
+func (r *Registry) Stop(ctx context.Context, name string) error {
+	r.changeLk.Lock()
+	defer r.changeLk.Unlock()
+
+	r.lk.Lock()
+	e, ok := r.subs[name]
+	if !ok {
+		r.lk.Unlock()
+		return xerrors.Errorf("%s: %w", name, ErrNotFound)
+	}
+	if !e.running {
+		r.lk.Unlock()
+		return nil
+	}
+	for _, o := range r.subs {
+		if !o.running {
+			continue
+		}
+		for _, dep := range o.DependsOn {
+			if dep == name {
+				r.lk.Unlock()
+				return xerrors.Errorf("%s depends on %s, stop it first", o.Name, name)
+			}
+		}
+	}
+	r.lk.Unlock()
+
+	var err error
+	if e.Stop != nil {
+		err = e.Stop(ctx)
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if err != nil {
+		e.err = err.Error()
+		return xerrors.Errorf("stopping %s: %w", name, err)
+	}
+
+	e.running = false
+	e.since = time.Now()
+	e.err = ""
+
+	log.Warnw("stopped subsystem", "name", name)
+
+	return nil
+}
+
+// Status returns the status of all subsystems, by name
+func (r *Registry) Status() []api.SubsystemStatus {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	out := make([]api.SubsystemStatus, 0, len(r.subs))
+	for _, e := range r.subs {
+		out = append(out, api.SubsystemStatus{
+			Name:        e.Name,
+			Description: e.Description,
+			DependsOn:   e.DependsOn,
+			Running:     e.running,
+			Since:       e.since,
+			Error:       e.err,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+// Loop returns a subsystem running the function in a goroutine while it's
+// running. The context passed to the function is cancelled when the subsystem
+// is stopped, or when ctx is cancelled.
+func Loop(ctx context.Context, name, description string, dependsOn []string, run func(ctx context.Context)) Subsystem {
+	// only accessed from Start and Stop, which the registry serializes
+	var cancel context.CancelFunc
+	var done chan struct{}
+
+	return Subsystem{
+		Name:        name,
+		Description: description,
+		DependsOn:   dependsOn,
+
+		Start: func(context.Context) error {
+			var lctx context.Context
+			lctx, cancel = context.WithCancel(ctx)
+			done = make(chan struct{})
+
+			go func() {
+				defer close(done)
+				run(lctx)
+			}()
+
+			return nil
+		},
+		Stop: func(sctx context.Context) error {
+			cancel()
+
+			select {
+			case <-done:
+				return nil
+			case <-sctx.Done():
+				return sctx.Err()
+			}
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package subsystems
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	r := New()
+
+	require.NoError(t, r.Register(Subsystem{Name: "input"}))
+	require.NoError(t, r.Register(Subsystem{Name: "deals", DependsOn: []string{"input"}}))
+	require.Error(t, r.Register(Subsystem{Name: "input"}))
+
+	require.Error(t, r.Start(ctx, "deals"))
+	require.False(t, r.Running("deals"))
+
+	require.NoError(t, r.Start(ctx, "input"))
+	require.NoError(t, r.Start(ctx, "deals"))
+	require.True(t, r.Running("deals"))
+
+	require.Error(t, r.Stop(ctx, "input"))
+	require.True(t, r.Running("input"))
+
+	require.NoError(t, r.Stop(ctx, "deals"))
+	require.NoError(t, r.Stop(ctx, "input"))
+	require.False(t, r.Running("input"))
+
+	err := r.Start(ctx, "nope")
+	require.True(t, xerrors.Is(err, ErrNotFound))
+
+	st := r.Status()
+	require.Len(t, st, 2)
+	require.Equal(t, "deals", st[0].Name)
+	require.Equal(t, []string{"input"}, st[0].DependsOn)
+	require.False(t, st[0].Running)
+}
+
+func TestRegistryStartError(t *testing.T) {
+	ctx := context.Background()
+	r := New()
+
+	require.NoError(t, r.Register(Subsystem{
+		Name: "broken",
+		Start: func(context.Context) error {
+			return xerrors.New("no")
+		},
+	}))
+
+	require.Error(t, r.Start(ctx, "broken"))
+	require.False(t, r.Running("broken"))
+	require.Equal(t, "no", r.Status()[0].Error)
+}
+
+func TestLoop(t *testing.T) {
+	ctx := context.Background()
+	r := New()
+
+	runs := make(chan struct{}, 2)
+	require.NoError(t, r.Register(Loop(ctx, "loop", "", nil, func(ctx context.Context) {
+		runs <- struct{}{}
+		<-ctx.Done()
+	})))
+
+	require.NoError(t, r.Start(ctx, "loop"))
+	<-runs
+	require.NoError(t, r.Stop(ctx, "loop"))
+
+	// started again after being stopped
+	require.NoError(t, r.Start(ctx, "loop"))
+	<-runs
+	require.NoError(t, r.Stop(ctx, "loop"))
+}
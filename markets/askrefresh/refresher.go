@@ -0,0 +1,236 @@
+package askrefresh
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+var log = logging.Logger("askrefresh")
+
+// HistorySize is the number of signed asks kept in the history
+var HistorySize = 100
+
+// AskStore holds the current signed storage ask of the provider
+type AskStore interface {
+	GetAsk() *storagemarket.SignedStorageAsk
+	SetAsk(price abi.TokenAmount, verifiedPrice abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error
+}
+
+// PublishFunc publishes a commitment to a signed ask on chain, and returns
+// the CID of the message carrying it
+type PublishFunc func(ctx context.Context, commitment []byte) (cid.Cid, error)
+
+// Refresher re-signs the storage ask with a new expiry before it expires, and
+// keeps a history of the signed asks. When a publish function is set, a
+// commitment to each new ask is published on chain.
+type Refresher struct {
+	asks    AskStore
+	ds      datastore.Batching
+	head    func(ctx context.Context) (abi.ChainEpoch, error)
+	publish PublishFunc
+
+	lk sync.Mutex
+}
+
+func New(asks AskStore, ds datastore.Batching, head func(ctx context.Context) (abi.ChainEpoch, error), publish PublishFunc) *Refresher {
+	return &Refresher{
+		asks:    asks,
+		ds:      ds,
+		head:    head,
+		publish: publish,
+	}
+}
+
+// Commitment returns the commitment to a signed ask published on chain, the
+// SHA256 hash of its CBOR encoding
+func Commitment(ask *storagemarket.SignedStorageAsk) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ask.MarshalCBOR(&buf); err != nil {
+		return nil, xerrors.Errorf("marshaling ask: %w", err)
+	}
+
+	h := sha256.Sum256(buf.Bytes())
+	return h[:], nil
+}
+
+// Run refreshes the ask every interval until the context is cancelled; with
+// a 0 interval, asks are only recorded when Update is called
+func (r *Refresher) Run(ctx context.Context, interval time.Duration) {
+	if err := r.Update(ctx); err != nil {
+		log.Errorf("recording storage ask: %+v", err)
+	}
+
+	if interval == 0 {
+		return
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			if err := r.refresh(ctx); err != nil {
+				log.Errorf("refreshing storage ask: %+v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh signs the ask again with the same terms once half of its duration
+// has passed, and records it
+func (r *Refresher) refresh(ctx context.Context) error {
+	ask := r.asks.GetAsk()
+	if ask == nil || ask.Ask == nil {
+		return nil
+	}
+
+	head, err := r.head(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	duration := ask.Ask.Expiry - ask.Ask.Timestamp
+	if head < ask.Ask.Timestamp+duration/2 {
+		return r.Update(ctx)
+	}
+
+	err = r.asks.SetAsk(ask.Ask.Price, ask.Ask.VerifiedPrice, duration,
+		storagemarket.MinPieceSize(ask.Ask.MinPieceSize),
+		storagemarket.MaxPieceSize(ask.Ask.MaxPieceSize))
+	if err != nil {
+		return xerrors.Errorf("setting ask: %w", err)
+	}
+
+	log.Infow("refreshed storage ask", "expiry", r.asks.GetAsk().Ask.Expiry)
+
+	return r.Update(ctx)
+}
+
+// Update records the current ask in the history if it's new, and publishes
+// its commitment
+func (r *Refresher) Update(ctx context.Context) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	ask := r.asks.GetAsk()
+	if ask == nil || ask.Ask == nil {
+		return nil
+	}
+
+	k := askKey(ask.Ask.SeqNo)
+	has, err := r.ds.Has(k)
+	if err != nil {
+		return xerrors.Errorf("checking ask history: %w", err)
+	}
+	if has {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := ask.MarshalCBOR(&buf); err != nil {
+		return xerrors.Errorf("marshaling ask: %w", err)
+	}
+	if err := r.ds.Put(k, buf.Bytes()); err != nil {
+		return xerrors.Errorf("storing ask: %w", err)
+	}
+
+	if err := r.prune(); err != nil {
+		log.Warnf("pruning ask history: %+v", err)
+	}
+
+	if r.publish == nil {
+		return nil
+	}
+
+	commitment, err := Commitment(ask)
+	if err != nil {
+		return err
+	}
+
+	mcid, err := r.publish(ctx, commitment)
+	if err != nil {
+		return xerrors.Errorf("publishing commitment of ask %d: %w", ask.Ask.SeqNo, err)
+	}
+
+	log.Infow("published storage ask commitment", "seqno", ask.Ask.SeqNo, "message", mcid)
+
+	return nil
+}
+
+// History returns up to limit of the latest signed asks, newest first; 0 for
+// all recorded asks
+func (r *Refresher) History(limit int) ([]*storagemarket.SignedStorageAsk, error) {
+	asks, err := r.list()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(asks) > limit {
+		asks = asks[:limit]
+	}
+
+	return asks, nil
+}
+
+func (r *Refresher) list() ([]*storagemarket.SignedStorageAsk, error) {
+	res, err := r.ds.Query(query.Query{})
+	if err != nil {
+		return nil, xerrors.Errorf("querying ask history: %w", err)
+	}
+	defer res.Close() // nolint:errcheck
+
+	var out []*storagemarket.SignedStorageAsk
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, xerrors.Errorf("reading ask history: %w", e.Error)
+		}
+
+		var ask storagemarket.SignedStorageAsk
+		if err := ask.UnmarshalCBOR(bytes.NewReader(e.Value)); err != nil {
+			return nil, xerrors.Errorf("unmarshaling ask %s: %w", e.Key, err)
+		}
+		out = append(out, &ask)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Ask.SeqNo > out[j].Ask.SeqNo
+	})
+
+	return out, nil
+}
+
+func (r *Refresher) prune() error {
+	asks, err := r.list()
+	if err != nil {
+		return err
+	}
+
+	for i := HistorySize; i < len(asks); i++ {
+		if err := r.ds.Delete(askKey(asks[i].Ask.SeqNo)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func askKey(seqno uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("/%020d", seqno))
+}
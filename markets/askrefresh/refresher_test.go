@@ -0,0 +1,120 @@
+package askrefresh
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+)
+
+type testAsks struct {
+	head *abi.ChainEpoch
+	ask  *storagemarket.SignedStorageAsk
+}
+
+func (a *testAsks) GetAsk() *storagemarket.SignedStorageAsk {
+	return a.ask
+}
+
+func (a *testAsks) SetAsk(price abi.TokenAmount, verifiedPrice abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
+	ask := &storagemarket.StorageAsk{
+		Price:         price,
+		VerifiedPrice: verifiedPrice,
+		Timestamp:     *a.head,
+		Expiry:        *a.head + duration,
+	}
+	if a.ask != nil {
+		ask.SeqNo = a.ask.Ask.SeqNo + 1
+	}
+	for _, o := range options {
+		o(ask)
+	}
+
+	a.ask = &storagemarket.SignedStorageAsk{Ask: ask}
+	return nil
+}
+
+func TestRefresher(t *testing.T) {
+	ctx := context.Background()
+
+	head := abi.ChainEpoch(100)
+	asks := &testAsks{head: &head}
+	require.NoError(t, asks.SetAsk(big.NewInt(5), big.NewInt(1), 1000, storagemarket.MaxPieceSize(2048)))
+
+	var published [][]byte
+	publish := func(ctx context.Context, commitment []byte) (cid.Cid, error) {
+		published = append(published, commitment)
+		return blocks.NewBlock(commitment).Cid(), nil
+	}
+
+	r := New(asks, dssync.MutexWrap(datastore.NewMapDatastore()), func(context.Context) (abi.ChainEpoch, error) {
+		return head, nil
+	}, publish)
+
+	require.NoError(t, r.Update(ctx))
+	require.Len(t, published, 1)
+
+	// the same ask isn't recorded twice
+	require.NoError(t, r.Update(ctx))
+	require.Len(t, published, 1)
+
+	// not refreshed before half of the duration passed
+	head = 599
+	require.NoError(t, r.refresh(ctx))
+	require.Equal(t, uint64(0), asks.ask.Ask.SeqNo)
+
+	head = 600
+	require.NoError(t, r.refresh(ctx))
+	require.Equal(t, uint64(1), asks.ask.Ask.SeqNo)
+	require.Equal(t, abi.ChainEpoch(1600), asks.ask.Ask.Expiry)
+	require.Equal(t, big.NewInt(5), asks.ask.Ask.Price)
+	require.Equal(t, abi.PaddedPieceSize(2048), asks.ask.Ask.MaxPieceSize)
+	require.Len(t, published, 2)
+
+	c, err := Commitment(asks.ask)
+	require.NoError(t, err)
+	require.Equal(t, c, published[1])
+
+	hist, err := r.History(0)
+	require.NoError(t, err)
+	require.Len(t, hist, 2)
+	require.Equal(t, uint64(1), hist[0].Ask.SeqNo)
+	require.Equal(t, uint64(0), hist[1].Ask.SeqNo)
+
+	hist, err = r.History(1)
+	require.NoError(t, err)
+	require.Len(t, hist, 1)
+	require.Equal(t, uint64(1), hist[0].Ask.SeqNo)
+}
+
+func TestRefresherPrune(t *testing.T) {
+	ctx := context.Background()
+
+	defer func(s int) { HistorySize = s }(HistorySize)
+	HistorySize = 3
+
+	head := abi.ChainEpoch(0)
+	asks := &testAsks{head: &head}
+	r := New(asks, dssync.MutexWrap(datastore.NewMapDatastore()), func(context.Context) (abi.ChainEpoch, error) {
+		return head, nil
+	}, nil)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, asks.SetAsk(big.NewInt(int64(i)), big.Zero(), 100))
+		require.NoError(t, r.Update(ctx))
+	}
+
+	hist, err := r.History(0)
+	require.NoError(t, err)
+	require.Len(t, hist, 3)
+	require.Equal(t, uint64(4), hist[0].Ask.SeqNo)
+	require.Equal(t, uint64(2), hist[2].Ask.SeqNo)
+}
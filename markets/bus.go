@@ -0,0 +1,37 @@
+package markets
+
+import (
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+
+	"github.com/filecoin-project/lotus/bus"
+)
+
+// StorageClientBusEmitter publishes storage client deal events on the event
+// bus, for external subscribers of the BusSubscribe API
+func StorageClientBusEmitter(b bus.Bus) func(event storagemarket.ClientEvent, deal storagemarket.ClientDeal) {
+	emit := bus.Emitter(b, new(bus.EvtStorageDeal))
+	return func(event storagemarket.ClientEvent, deal storagemarket.ClientDeal) {
+		emit(bus.EvtStorageDeal{
+			Role:        bus.DealRoleClient,
+			ProposalCid: deal.ProposalCid,
+			Event:       storagemarket.ClientEvents[event],
+			State:       storagemarket.DealStates[deal.State],
+			Message:     deal.Message,
+		})
+	}
+}
+
+// StorageProviderBusEmitter publishes storage provider deal events on the
+// event bus, for external subscribers of the BusSubscribe API
+func StorageProviderBusEmitter(b bus.Bus) func(event storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+	emit := bus.Emitter(b, new(bus.EvtStorageDeal))
+	return func(event storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+		emit(bus.EvtStorageDeal{
+			Role:        bus.DealRoleProvider,
+			ProposalCid: deal.ProposalCid,
+			Event:       storagemarket.ProviderEvents[event],
+			State:       storagemarket.DealStates[deal.State],
+			Message:     deal.Message,
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package clientquota
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+var log = logging.Logger("clientquota")
+
+// ErrQuotaExceeded is returned when a client has too many pieces waiting to be
+// added to a sector
+var ErrQuotaExceeded = xerrors.New("client quota exceeded")
+
+// inactive deal states don't count towards client quotas
+var inactive = map[storagemarket.StorageDealStatus]struct{}{
+	storagemarket.StorageDealProposalRejected: {},
+	storagemarket.StorageDealFailing:          {},
+	storagemarket.StorageDealError:            {},
+	storagemarket.StorageDealSlashed:          {},
+	storagemarket.StorageDealExpired:          {},
+}
+
+// Quotas enforces per-client limits on the deals accepted by the provider
+type Quotas struct {
+	def    config.ClientQuota
+	quotas map[address.Address]config.ClientQuota
+
+	lk      sync.Mutex
+	deals   func() ([]storagemarket.MinerDeal, error)
+	pending map[address.Address]uint64
+}
+
+func New(cfg config.DealmakingConfig) (*Quotas, error) {
+	q := &Quotas{
+		def:     cfg.DefaultClientQuota,
+		quotas:  map[address.Address]config.ClientQuota{},
+		pending: map[address.Address]uint64{},
+	}
+
+	for s, cq := range cfg.ClientQuotas {
+		a, err := address.NewFromString(s)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing client quota address %s: %w", s, err)
+		}
+		q.quotas[a] = cq
+	}
+
+	return q, nil
+}
+
+// SetDealSource sets the function used to list provider deals; it's set after
+// the storage provider is constructed, as the provider depends on the deal
+// filter using the quotas
+func (q *Quotas) SetDealSource(deals func() ([]storagemarket.MinerDeal, error)) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	q.deals = deals
+}
+
+func (q *Quotas) quota(client address.Address) config.ClientQuota {
+	if cq, ok := q.quotas[client]; ok {
+		return cq
+	}
+	return q.def
+}
+
+// CheckDeal returns whether accepting the deal would keep the client within
+// its quota, and if not, the reason for rejecting it
+func (q *Quotas) CheckDeal(deal storagemarket.MinerDeal) (bool, string, error) {
+	client := deal.Proposal.Client
+	cq := q.quota(client)
+	if cq.MaxActiveDeals == 0 && cq.MaxActiveDealBytes == 0 {
+		return true, "", nil
+	}
+
+	usage, err := q.usage()
+	if err != nil {
+		return false, "miner error", err
+	}
+
+	u := usage[client]
+	if u == nil {
+		u = &clientUsage{}
+	}
+
+	// the deal being checked is already tracked by the provider
+	for _, c := range u.dealProposals {
+		if c == deal.ProposalCid {
+			u.ActiveDeals--
+			u.ActiveDealBytes -= uint64(deal.Proposal.PieceSize)
+			break
+		}
+	}
+
+	if cq.MaxActiveDeals > 0 && u.ActiveDeals+1 > cq.MaxActiveDeals {
+		log.Warnw("client reached active deal quota; rejecting storage deal proposal", "client", client, "deals", u.ActiveDeals, "max", cq.MaxActiveDeals)
+		return false, fmt.Sprintf("client %s has reached the limit of %d active deals", client, cq.MaxActiveDeals), nil
+	}
+
+	if cq.MaxActiveDealBytes > 0 && u.ActiveDealBytes+uint64(deal.Proposal.PieceSize) > cq.MaxActiveDealBytes {
+		log.Warnw("client reached active deal bytes quota; rejecting storage deal proposal", "client", client, "bytes", u.ActiveDealBytes, "max", cq.MaxActiveDealBytes)
+		return false, fmt.Sprintf("client %s has reached the limit of %d active deal bytes", client, cq.MaxActiveDealBytes), nil
+	}
+
+	return true, "", nil
+}
+
+// AdmitPiece registers a piece of the client waiting to be added to a sector,
+// returning ErrQuotaExceeded if the client has too many pending pieces
+func (q *Quotas) AdmitPiece(client address.Address) (func(), error) {
+	cq := q.quota(client)
+
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	if cq.MaxPendingPieces > 0 && q.pending[client] >= cq.MaxPendingPieces {
+		return nil, xerrors.Errorf("client %s has %d pending pieces: %w", client, q.pending[client], ErrQuotaExceeded)
+	}
+
+	q.pending[client]++
+
+	return func() {
+		q.lk.Lock()
+		defer q.lk.Unlock()
+
+		q.pending[client]--
+		if q.pending[client] == 0 {
+			delete(q.pending, client)
+		}
+	}, nil
+}
+
+// Usage returns the quota consumption of all clients with active deals or
+// pending pieces
+func (q *Quotas) Usage(ctx context.Context) ([]api.ClientQuotaUsage, error) {
+	usage, err := q.usage()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]api.ClientQuotaUsage, 0, len(usage))
+	for _, u := range usage {
+		out = append(out, u.ClientQuotaUsage)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Client.String() < out[j].Client.String()
+	})
+
+	return out, nil
+}
+
+type clientUsage struct {
+	api.ClientQuotaUsage
+
+	dealProposals []cid.Cid
+}
+
+func (q *Quotas) usage() (map[address.Address]*clientUsage, error) {
+	q.lk.Lock()
+	deals := q.deals
+	pending := make(map[address.Address]uint64, len(q.pending))
+	for c, n := range q.pending {
+		pending[c] = n
+	}
+	q.lk.Unlock()
+
+	out := map[address.Address]*clientUsage{}
+	get := func(client address.Address) *clientUsage {
+		u, ok := out[client]
+		if !ok {
+			u = &clientUsage{}
+			u.Client = client
+			cq := q.quota(client)
+			u.MaxActiveDealBytes = cq.MaxActiveDealBytes
+			u.MaxActiveDeals = cq.MaxActiveDeals
+			u.MaxPendingPieces = cq.MaxPendingPieces
+			out[client] = u
+		}
+		return u
+	}
+
+	if deals != nil {
+		ds, err := deals()
+		if err != nil {
+			return nil, xerrors.Errorf("listing deals: %w", err)
+		}
+
+		for _, d := range ds {
+			if _, ok := inactive[d.State]; ok {
+				continue
+			}
+
+			u := get(d.Proposal.Client)
+			u.ActiveDeals++
+			u.ActiveDealBytes += uint64(d.Proposal.PieceSize)
+			u.dealProposals = append(u.dealProposals, d.ProposalCid)
+		}
+	}
+
+	for c, n := range pending {
+		get(c).PendingPieces = n
+	}
+
+	return out, nil
+}
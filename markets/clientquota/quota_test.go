@@ -0,0 +1,101 @@
+package clientquota
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	market2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/market"
+
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+func mkDeal(client address.Address, prop string, size abi.PaddedPieceSize, state storagemarket.StorageDealStatus) storagemarket.MinerDeal {
+	return storagemarket.MinerDeal{
+		ClientDealProposal: market2.ClientDealProposal{
+			Proposal: market2.DealProposal{
+				Client:    client,
+				PieceSize: size,
+			},
+		},
+		ProposalCid: blocks.NewBlock([]byte(prop)).Cid(),
+		State:       state,
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	limited, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	other, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	q, err := New(config.DealmakingConfig{
+		DefaultClientQuota: config.ClientQuota{MaxActiveDeals: 2},
+		ClientQuotas: map[string]config.ClientQuota{
+			limited.String(): {MaxActiveDealBytes: 4 << 10, MaxPendingPieces: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	deals := []storagemarket.MinerDeal{
+		mkDeal(limited, "deal1", 2<<10, storagemarket.StorageDealActive),
+		mkDeal(limited, "deal2", 2<<10, storagemarket.StorageDealExpired),
+		mkDeal(other, "deal3", 2<<10, storagemarket.StorageDealSealing),
+	}
+	q.SetDealSource(func() ([]storagemarket.MinerDeal, error) {
+		return deals, nil
+	})
+
+	// expired deals don't count towards the quota
+	ok, _, err := q.CheckDeal(mkDeal(limited, "deal4", 2<<10, storagemarket.StorageDealValidating))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, reason, err := q.CheckDeal(mkDeal(limited, "deal4", 4<<10, storagemarket.StorageDealValidating))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Contains(t, reason, "active deal bytes")
+
+	// the deal being checked is already tracked by the provider
+	ok, _, err = q.CheckDeal(deals[2])
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, _, err = q.CheckDeal(mkDeal(other, "deal4", 2<<10, storagemarket.StorageDealValidating))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	deals = append(deals, mkDeal(other, "deal4", 2<<10, storagemarket.StorageDealValidating))
+	ok, reason, err = q.CheckDeal(mkDeal(other, "deal2", 2<<10, storagemarket.StorageDealValidating))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Contains(t, reason, "active deals")
+
+	done, err := q.AdmitPiece(limited)
+	require.NoError(t, err)
+
+	_, err = q.AdmitPiece(limited)
+	require.True(t, xerrors.Is(err, ErrQuotaExceeded))
+
+	usage, err := q.Usage(context.Background())
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	require.Equal(t, limited, usage[0].Client)
+	require.Equal(t, uint64(1), usage[0].ActiveDeals)
+	require.Equal(t, uint64(2<<10), usage[0].ActiveDealBytes)
+	require.Equal(t, uint64(1), usage[0].PendingPieces)
+	require.Equal(t, uint64(1), usage[0].MaxPendingPieces)
+	require.Equal(t, uint64(2), usage[1].ActiveDeals)
+	require.Equal(t, uint64(2), usage[1].MaxActiveDeals)
+
+	done()
+
+	_, err = q.AdmitPiece(limited)
+	require.NoError(t, err)
+}
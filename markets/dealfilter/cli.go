@@ -4,16 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"os/exec"
 
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
-
-	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/go-state-types/abi"
 )
 
-func CliStorageDealFilter(cmd string) dtypes.StorageDealFilter {
-	return func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+// StorageFilter decides whether to accept a storage deal, and returns the
+// overrides of the deal settings for accepted deals
+type StorageFilter func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, Overrides, error)
+
+// CliStorageDealFilter runs the filter command, or POSTs to the filter URL.
+// Accepted deals may have their settings overridden by an Overrides JSON
+// object in the filter output.
+func CliStorageDealFilter(cmd string) StorageFilter {
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, Overrides, error) {
 		d := struct {
 			storagemarket.MinerDeal
 			DealType string
@@ -21,39 +28,72 @@ func CliStorageDealFilter(cmd string) dtypes.StorageDealFilter {
 			MinerDeal: deal,
 			DealType:  "storage",
 		}
-		return runDealFilter(ctx, cmd, d)
+
+		ok, out, err := runDealFilter(ctx, cmd, d)
+		if !ok || err != nil {
+			return ok, out, Overrides{}, err
+		}
+
+		return true, "", parseOverrides(out), nil
 	}
 }
 
-func CliRetrievalDealFilter(cmd string) dtypes.RetrievalDealFilter {
-	return func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error) {
+// RetrievalDealInfo is what the node knows about the data of a retrieval deal,
+// and the retrievals it's serving, when the deal is filtered
+type RetrievalDealInfo struct {
+	// Size of the piece holding the payload, 0 if the piece isn't known
+	PieceSize abi.PaddedPieceSize
+
+	// Retrievals being served, and data transfers of all deals in progress
+	OngoingRetrievals int
+	OngoingTransfers  int
+}
+
+// RetrievalFilter decides whether to accept a retrieval deal
+type RetrievalFilter func(ctx context.Context, deal retrievalmarket.ProviderDealState, info RetrievalDealInfo) (bool, string, error)
+
+func CliRetrievalDealFilter(cmd string) RetrievalFilter {
+	return func(ctx context.Context, deal retrievalmarket.ProviderDealState, info RetrievalDealInfo) (bool, string, error) {
 		d := struct {
 			retrievalmarket.ProviderDealState
+			RetrievalDealInfo
 			DealType string
 		}{
 			ProviderDealState: deal,
+			RetrievalDealInfo: info,
 			DealType:          "retrieval",
 		}
-		return runDealFilter(ctx, cmd, d)
+
+		ok, reason, err := runDealFilter(ctx, cmd, d)
+		if ok {
+			reason = ""
+		}
+		return ok, reason, err
 	}
 }
 
+// runDealFilter returns whether the filter accepted the deal, along with the
+// filter output: the rejection reason, or the output of the accepting filter
 func runDealFilter(ctx context.Context, cmd string, deal interface{}) (bool, string, error) {
 	j, err := json.MarshalIndent(deal, "", "  ")
 	if err != nil {
 		return false, "", err
 	}
 
-	var out bytes.Buffer
+	if isHTTPFilter(cmd) {
+		return runHTTPDealFilter(ctx, cmd, j)
+	}
+
+	var out, stdout bytes.Buffer
 
 	c := exec.Command("sh", "-c", cmd)
 	c.Stdin = bytes.NewReader(j)
-	c.Stdout = &out
+	c.Stdout = io.MultiWriter(&out, &stdout)
 	c.Stderr = &out
 
 	switch err := c.Run().(type) {
 	case nil:
-		return true, "", nil
+		return true, stdout.String(), nil
 	case *exec.ExitError:
 		return false, out.String(), nil
 	default:
@@ -0,0 +1,55 @@
+package dealfilter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// maxReasonLength bounds the rejection reason, or overrides, read from a
+// filter response
+const maxReasonLength = 1 << 10
+
+// filterTimeout bounds how long a filter endpoint takes to decide, so that an
+// unresponsive endpoint doesn't hold up the deal
+const filterTimeout = 30 * time.Second
+
+var filterClient = &http.Client{Timeout: filterTimeout}
+
+func isHTTPFilter(filter string) bool {
+	return strings.HasPrefix(filter, "http://") || strings.HasPrefix(filter, "https://")
+}
+
+func runHTTPDealFilter(ctx context.Context, url string, deal []byte) (bool, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(deal))
+	if err != nil {
+		return false, "filter request error", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := filterClient.Do(req)
+	if err != nil {
+		return false, "filter request error", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	reason, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxReasonLength))
+	if err != nil {
+		return false, "filter request error", xerrors.Errorf("reading filter response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, string(reason), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, strings.TrimSpace(string(reason)), nil
+	default:
+		return false, "filter request error", xerrors.Errorf("filter responded with status %d: %s", resp.StatusCode, reason)
+	}
+}
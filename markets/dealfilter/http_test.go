@@ -0,0 +1,71 @@
+package dealfilter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPDealFilter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var d struct {
+			DealType string
+			Size     int
+		}
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		switch {
+		case d.Size == 42:
+			_, _ = w.Write([]byte(`{"KeepUnsealed":true}`))
+		case d.Size > 100:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("too big\n"))
+		case d.Size < 0:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	ok, reason, err := runDealFilter(ctx, srv.URL, map[string]interface{}{"DealType": "storage", "Size": 10})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, reason)
+
+	ok, out, err := runDealFilter(ctx, srv.URL, map[string]interface{}{"DealType": "storage", "Size": 42})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, true, *parseOverrides(out).KeepUnsealed)
+
+	ok, reason, err = runDealFilter(ctx, srv.URL, map[string]interface{}{"DealType": "storage", "Size": 1000})
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, "too big", reason)
+
+	ok, _, err = runDealFilter(ctx, srv.URL, map[string]interface{}{"DealType": "storage", "Size": -1})
+	require.Error(t, err)
+	require.False(t, ok)
+}
+
+func TestParseOverrides(t *testing.T) {
+	require.Equal(t, Overrides{}, parseOverrides(""))
+	require.Equal(t, Overrides{}, parseOverrides("accepted\n"))
+	require.Equal(t, Overrides{}, parseOverrides(`{"KeepUnsealed":`))
+
+	o := parseOverrides(" {\"KeepUnsealed\": false}\n")
+	require.NotNil(t, o.KeepUnsealed)
+	require.False(t, *o.KeepUnsealed)
+	require.Nil(t, o.Labels)
+
+	o = parseOverrides(`{"Labels": ["encrypted", "region:eu"]}`)
+	require.Nil(t, o.KeepUnsealed)
+	require.Equal(t, []string{"encrypted", "region:eu"}, o.Labels)
+}
@@ -0,0 +1,162 @@
+package dealfilter
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+var log = logging.Logger("dealfilter")
+
+// Overrides of the settings of a deal, returned by the filter accepting it.
+// Unset fields don't override anything.
+type Overrides struct {
+	// Whether to keep an unsealed copy of the deal data after sealing
+	KeepUnsealed *bool `json:",omitempty"`
+	// Labels of the deal piece, in addition to the ones derived from the deal,
+	// which the ExclusiveLabels and DedicatedLabels sealing config refer to
+	Labels []string `json:",omitempty"`
+}
+
+// IsEmpty returns whether the overrides don't override anything
+func (o Overrides) IsEmpty() bool {
+	return o.KeepUnsealed == nil && len(o.Labels) == 0
+}
+
+// parseOverrides reads overrides from the output of a filter. Output which
+// isn't an Overrides JSON object is ignored, so that filters printing other
+// things keep working.
+func parseOverrides(out string) Overrides {
+	var o Overrides
+
+	out = strings.TrimSpace(out)
+	if !strings.HasPrefix(out, "{") {
+		return o
+	}
+	if err := json.Unmarshal([]byte(out), &o); err != nil {
+		return Overrides{}
+	}
+
+	return o
+}
+
+// finished deal states are the states of deals which failed, or whose data was
+// handed off to a sector, so their overrides aren't needed anymore
+var finished = map[storagemarket.StorageDealStatus]struct{}{
+	storagemarket.StorageDealProposalRejected:  {},
+	storagemarket.StorageDealFailing:           {},
+	storagemarket.StorageDealError:             {},
+	storagemarket.StorageDealStaged:            {},
+	storagemarket.StorageDealAwaitingPreCommit: {},
+	storagemarket.StorageDealSealing:           {},
+	storagemarket.StorageDealFinalizing:        {},
+	storagemarket.StorageDealActive:            {},
+	storagemarket.StorageDealExpired:           {},
+	storagemarket.StorageDealSlashed:           {},
+}
+
+// OverrideStore keeps the overrides of accepted deals until the deal data is
+// added to a sector, or the deal fails
+type OverrideStore struct {
+	ds datastore.Batching
+}
+
+func NewOverrideStore(ds datastore.Batching) *OverrideStore {
+	return &OverrideStore{ds: ds}
+}
+
+func (s *OverrideStore) Put(proposal cid.Cid, o Overrides) error {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return xerrors.Errorf("marshaling overrides: %w", err)
+	}
+
+	return s.ds.Put(datastore.NewKey(proposal.String()), b)
+}
+
+// Get returns the overrides of the deal, none if there aren't any
+func (s *OverrideStore) Get(proposal cid.Cid) (Overrides, error) {
+	var o Overrides
+
+	b, err := s.ds.Get(datastore.NewKey(proposal.String()))
+	if err == datastore.ErrNotFound {
+		return o, nil
+	}
+	if err != nil {
+		return o, xerrors.Errorf("getting overrides: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &o); err != nil {
+		return o, xerrors.Errorf("unmarshaling overrides: %w", err)
+	}
+
+	return o, nil
+}
+
+// Delete removes the overrides of the deal, if there are any
+func (s *OverrideStore) Delete(proposal cid.Cid) error {
+	err := s.ds.Delete(datastore.NewKey(proposal.String()))
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// OnDealEvent removes the overrides of deals which finished without their data
+// being added to a sector, it's subscribed to the storage provider events
+func (s *OverrideStore) OnDealEvent(_ storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+	if _, ok := finished[deal.State]; !ok {
+		return
+	}
+
+	if err := s.Delete(deal.ProposalCid); err != nil {
+		log.Warnw("removing deal filter overrides", "proposal", deal.ProposalCid, "error", err)
+	}
+}
+
+// Prune removes the overrides of finished deals, and of deals the provider
+// doesn't know of, which finished while no events were received, e.g. while
+// the node was down
+func (s *OverrideStore) Prune(deals []storagemarket.MinerDeal) error {
+	active := map[string]struct{}{}
+	for _, deal := range deals {
+		if _, ok := finished[deal.State]; !ok {
+			active[datastore.NewKey(deal.ProposalCid.String()).String()] = struct{}{}
+		}
+	}
+
+	res, err := s.ds.Query(query.Query{KeysOnly: true})
+	if err != nil {
+		return xerrors.Errorf("querying overrides: %w", err)
+	}
+
+	ents, err := res.Rest()
+	if err != nil {
+		return xerrors.Errorf("listing overrides: %w", err)
+	}
+
+	var removed int
+	for _, ent := range ents {
+		if _, ok := active[ent.Key]; ok {
+			continue
+		}
+
+		if err := s.ds.Delete(datastore.NewKey(ent.Key)); err != nil {
+			return xerrors.Errorf("removing overrides %s: %w", ent.Key, err)
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		log.Infow("removed overrides of finished deals", "count", removed)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package dealfilter
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	tutils "github.com/filecoin-project/specs-actors/v2/support/testing"
+)
+
+func TestOverrideStoreFinishedDeals(t *testing.T) {
+	s := NewOverrideStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	keep := true
+	o := Overrides{KeepUnsealed: &keep, Labels: []string{"archive"}}
+
+	transferring := tutils.MakeCID("transferring", nil)
+	failed := tutils.MakeCID("failed", nil)
+	unknown := tutils.MakeCID("unknown", nil)
+	for _, proposal := range []cid.Cid{transferring, failed, unknown} {
+		require.NoError(t, s.Put(proposal, o))
+	}
+
+	// overrides are kept until the deal finishes
+	s.OnDealEvent(storagemarket.ProviderEventDataTransferInitiated, storagemarket.MinerDeal{ProposalCid: transferring, State: storagemarket.StorageDealTransferring})
+	got, err := s.Get(transferring)
+	require.NoError(t, err)
+	require.Equal(t, o, got)
+
+	s.OnDealEvent(storagemarket.ProviderEventFailed, storagemarket.MinerDeal{ProposalCid: failed, State: storagemarket.StorageDealError})
+	got, err = s.Get(failed)
+	require.NoError(t, err)
+	require.Equal(t, Overrides{}, got)
+
+	// deals the provider doesn't know of are pruned with finished deals
+	require.NoError(t, s.Prune([]storagemarket.MinerDeal{
+		{ProposalCid: transferring, State: storagemarket.StorageDealTransferring},
+	}))
+
+	got, err = s.Get(transferring)
+	require.NoError(t, err)
+	require.Equal(t, o, got)
+
+	got, err = s.Get(unknown)
+	require.NoError(t, err)
+	require.Equal(t, Overrides{}, got)
+
+	// deleting overrides which are already gone isn't an error
+	require.NoError(t, s.Delete(failed))
+}
@@ -0,0 +1,21 @@
+package delegation
+
+import (
+	"encoding/json"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// AllowanceSigningBytes returns the bytes signed by the client key when
+// creating a deal allowance
+func AllowanceSigningBytes(a *api.DealAllowance) ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// RequestSigningBytes returns the bytes signed by the delegate key when making
+// a delegated deal request; this covers all fields except the signature
+func RequestSigningBytes(r *api.DelegatedDealRequest) ([]byte, error) {
+	unsigned := *r
+	unsigned.Signature = nil
+	return json.Marshal(&unsigned)
+}
@@ -0,0 +1,180 @@
+package delegation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-storedcounter"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// Store tracks deal allowances created by the local client, and how much of
+// each allowance was already spent by the delegate
+type Store struct {
+	lk sync.Mutex
+
+	ds datastore.Batching
+	sc *storedcounter.StoredCounter
+}
+
+func NewStore(ds datastore.Batching) *Store {
+	return &Store{
+		ds: namespace.Wrap(ds, datastore.NewKey("/entries")),
+		sc: storedcounter.New(ds, datastore.NewKey("/counter")),
+	}
+}
+
+func (s *Store) NextID() (uint64, error) {
+	return s.sc.Next()
+}
+
+func (s *Store) Put(info api.DealAllowanceInfo) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return s.put(info)
+}
+
+func (s *Store) Get(id uint64) (*api.DealAllowanceInfo, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return s.get(id)
+}
+
+func (s *Store) List() ([]api.DealAllowanceInfo, error) {
+	res, err := s.ds.Query(query.Query{})
+	if err != nil {
+		return nil, xerrors.Errorf("querying allowances: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []api.DealAllowanceInfo
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating allowances: %w", r.Error)
+		}
+
+		var info api.DealAllowanceInfo
+		if err := json.Unmarshal(r.Value, &info); err != nil {
+			return nil, xerrors.Errorf("unmarshaling allowance %s: %w", r.Key, err)
+		}
+
+		out = append(out, info)
+	}
+
+	return out, nil
+}
+
+func (s *Store) Revoke(id uint64) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	info, err := s.get(id)
+	if err != nil {
+		return err
+	}
+
+	info.Revoked = true
+	return s.put(*info)
+}
+
+// Spend deducts amount from the allowance for a request with the given nonce.
+// The allowance must match the one stored for its ID, the signature is
+// expected to be checked by the caller.
+func (s *Store) Spend(sa api.SignedDealAllowance, nonce uint64, amount abi.TokenAmount) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	info, err := s.get(sa.Allowance.ID)
+	if err != nil {
+		return err
+	}
+
+	if !sameAllowance(info.Allowance, sa.Allowance) {
+		return xerrors.Errorf("allowance %d doesn't match the locally stored allowance", sa.Allowance.ID)
+	}
+
+	if info.Revoked {
+		return xerrors.Errorf("allowance %d was revoked", sa.Allowance.ID)
+	}
+
+	if nonce <= info.LastNonce {
+		return xerrors.Errorf("request nonce %d must be greater than %d", nonce, info.LastNonce)
+	}
+
+	spent := big.Add(info.Spent, amount)
+	if spent.GreaterThan(info.Allowance.Amount) {
+		return xerrors.Errorf("deal price %s exceeds remaining allowance %s", amount, big.Sub(info.Allowance.Amount, info.Spent))
+	}
+
+	info.Spent = spent
+	info.LastNonce = nonce
+
+	return s.put(*info)
+}
+
+// Refund returns amount to the allowance, e.g. when proposing the deal failed
+func (s *Store) Refund(id uint64, amount abi.TokenAmount) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	info, err := s.get(id)
+	if err != nil {
+		return err
+	}
+
+	info.Spent = big.Sub(info.Spent, amount)
+	if info.Spent.LessThan(big.Zero()) {
+		info.Spent = big.Zero()
+	}
+
+	return s.put(*info)
+}
+
+func (s *Store) get(id uint64) (*api.DealAllowanceInfo, error) {
+	b, err := s.ds.Get(allowanceKey(id))
+	if err != nil {
+		if err == datastore.ErrNotFound {
+			return nil, xerrors.Errorf("allowance %d not found", id)
+		}
+		return nil, xerrors.Errorf("getting allowance %d: %w", id, err)
+	}
+
+	var info api.DealAllowanceInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, xerrors.Errorf("unmarshaling allowance %d: %w", id, err)
+	}
+
+	return &info, nil
+}
+
+func (s *Store) put(info api.DealAllowanceInfo) error {
+	b, err := json.Marshal(&info)
+	if err != nil {
+		return xerrors.Errorf("marshaling allowance: %w", err)
+	}
+
+	return s.ds.Put(allowanceKey(info.Allowance.ID), b)
+}
+
+func allowanceKey(id uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("%d", id))
+}
+
+func sameAllowance(a, b api.DealAllowance) bool {
+	return a.ID == b.ID &&
+		a.Client == b.Client &&
+		a.Delegate == b.Delegate &&
+		a.Amount.Equals(b.Amount) &&
+		a.Expiration == b.Expiration
+}
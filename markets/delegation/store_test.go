@@ -0,0 +1,63 @@
+package delegation
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestStoreSpend(t *testing.T) {
+	s := NewStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	id, err := s.NextID()
+	require.NoError(t, err)
+
+	client, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	delegate, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	sa := api.SignedDealAllowance{
+		Allowance: api.DealAllowance{
+			ID:         id,
+			Client:     client,
+			Delegate:   delegate,
+			Amount:     big.NewInt(100),
+			Expiration: 1000,
+		},
+	}
+	require.NoError(t, s.Put(api.DealAllowanceInfo{SignedDealAllowance: sa, Spent: big.Zero()}))
+
+	require.NoError(t, s.Spend(sa, 1, big.NewInt(60)))
+
+	// replayed nonce
+	require.Error(t, s.Spend(sa, 1, big.NewInt(10)))
+
+	// over the allowance
+	require.Error(t, s.Spend(sa, 2, big.NewInt(50)))
+
+	require.NoError(t, s.Refund(id, big.NewInt(60)))
+	require.NoError(t, s.Spend(sa, 3, big.NewInt(100)))
+
+	// allowance not matching the stored one
+	forged := sa
+	forged.Allowance.Amount = big.NewInt(1000)
+	require.Error(t, s.Spend(forged, 4, big.NewInt(1)))
+
+	require.NoError(t, s.Revoke(id))
+	require.NoError(t, s.Refund(id, big.NewInt(100)))
+	require.Error(t, s.Spend(sa, 5, big.NewInt(1)))
+
+	list, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.True(t, list[0].Revoked)
+	require.Equal(t, uint64(3), list[0].LastNonce)
+}
@@ -0,0 +1,303 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+var log = logging.Logger("indexer")
+
+// ResyncInterval is how often all deals are checked for missing
+// advertisements, e.g. after the indexer was unreachable
+var ResyncInterval = time.Hour
+
+const requestTimeout = 30 * time.Second
+
+var (
+	headKey     = datastore.NewKey("/head")
+	dealsPrefix = datastore.NewKey("/deals")
+)
+
+// announced deal states are the states in which the deal data is stored in a
+// sector, and can be retrieved from the provider
+var announced = map[storagemarket.StorageDealStatus]struct{}{
+	storagemarket.StorageDealSealing:    {},
+	storagemarket.StorageDealFinalizing: {},
+	storagemarket.StorageDealActive:     {},
+}
+
+// retracted deal states are the states in which the deal data is no longer
+// (or never will be) stored by the provider
+var retracted = map[storagemarket.StorageDealStatus]struct{}{
+	storagemarket.StorageDealExpired: {},
+	storagemarket.StorageDealSlashed: {},
+	storagemarket.StorageDealFailing: {},
+	storagemarket.StorageDealError:   {},
+}
+
+// Advertisement announces that the provider stores the payload of a deal, or
+// with IsRm set, that it no longer does. Advertisements form a chain through
+// Previous, so that the indexer can detect missed advertisements.
+type Advertisement struct {
+	Seq      uint64
+	Previous uint64
+
+	Provider  peer.ID
+	Miner     address.Address
+	Addresses []string
+
+	// ContextID identifies the deal the advertisement is about; retractions
+	// refer to the advertisement with the same ContextID
+	ContextID cid.Cid
+	PieceCID  cid.Cid
+	Entries   []cid.Cid
+	IsRm      bool
+}
+
+type dealAd struct {
+	Seq  uint64
+	IsRm bool
+}
+
+// Publisher publishes advertisements of deal payload CIDs to an indexer HTTP
+// endpoint
+type Publisher struct {
+	endpoint string
+	provider peer.ID
+	miner    address.Address
+	addrs    func() []string
+	ds       datastore.Batching
+	client   *http.Client
+
+	// serializes publishing, so that advertisements are chained in order
+	publishLk sync.Mutex
+
+	lk      sync.Mutex
+	pending map[cid.Cid]storagemarket.MinerDeal
+	kick    chan struct{}
+}
+
+func New(endpoint string, provider peer.ID, miner address.Address, addrs func() []string, ds datastore.Batching) *Publisher {
+	return &Publisher{
+		endpoint: endpoint,
+		provider: provider,
+		miner:    miner,
+		addrs:    addrs,
+		ds:       ds,
+		client:   &http.Client{Timeout: requestTimeout},
+
+		pending: map[cid.Cid]storagemarket.MinerDeal{},
+		kick:    make(chan struct{}, 1),
+	}
+}
+
+// OnDealEvent is subscribed to storage provider events. It doesn't block the
+// provider; advertisements are published by Run.
+func (p *Publisher) OnDealEvent(_ storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+	if !isIn(announced, deal.State) && !isIn(retracted, deal.State) {
+		return
+	}
+
+	p.lk.Lock()
+	p.pending[deal.ProposalCid] = deal
+	p.lk.Unlock()
+
+	select {
+	case p.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes advertisements for deal events, and periodically for all
+// deals listed by the provider until the context is cancelled
+func (p *Publisher) Run(ctx context.Context, deals func() ([]storagemarket.MinerDeal, error)) {
+	resync := time.NewTicker(ResyncInterval)
+	defer resync.Stop()
+
+	p.resync(ctx, deals)
+
+	for {
+		select {
+		case <-p.kick:
+			p.lk.Lock()
+			pending := p.pending
+			p.pending = map[cid.Cid]storagemarket.MinerDeal{}
+			p.lk.Unlock()
+
+			for _, deal := range pending {
+				if err := p.sync(ctx, deal); err != nil {
+					log.Warnw("publishing deal advertisement", "deal", deal.ProposalCid, "error", err)
+				}
+			}
+		case <-resync.C:
+			p.resync(ctx, deals)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Publisher) resync(ctx context.Context, deals func() ([]storagemarket.MinerDeal, error)) {
+	ds, err := deals()
+	if err != nil {
+		log.Errorf("listing deals for advertisement: %+v", err)
+		return
+	}
+
+	for _, deal := range ds {
+		if err := p.sync(ctx, deal); err != nil {
+			log.Warnw("publishing deal advertisement", "deal", deal.ProposalCid, "error", err)
+		}
+	}
+}
+
+// sync publishes an advertisement or retraction for the deal, if its state
+// changed since the last advertisement about it
+func (p *Publisher) sync(ctx context.Context, deal storagemarket.MinerDeal) error {
+	p.publishLk.Lock()
+	defer p.publishLk.Unlock()
+
+	prev, err := p.dealAd(deal.ProposalCid)
+	if err != nil {
+		return err
+	}
+
+	ad := Advertisement{
+		ContextID: deal.ProposalCid,
+		PieceCID:  deal.Proposal.PieceCID,
+	}
+
+	switch {
+	case isIn(announced, deal.State):
+		if prev != nil && !prev.IsRm {
+			return nil
+		}
+		if deal.Ref == nil {
+			return xerrors.Errorf("deal has no payload reference")
+		}
+
+		ad.Entries = []cid.Cid{deal.Ref.Root}
+	case isIn(retracted, deal.State):
+		if prev == nil || prev.IsRm {
+			// never announced, or already retracted
+			return nil
+		}
+
+		ad.IsRm = true
+	default:
+		return nil
+	}
+
+	return p.publish(ctx, ad)
+}
+
+func (p *Publisher) publish(ctx context.Context, ad Advertisement) error {
+	head, err := p.head()
+	if err != nil {
+		return err
+	}
+
+	ad.Seq = head + 1
+	ad.Previous = head
+	ad.Provider = p.provider
+	ad.Miner = p.miner
+	ad.Addresses = p.addrs()
+
+	b, err := json.Marshal(&ad)
+	if err != nil {
+		return xerrors.Errorf("marshaling advertisement: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", p.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return xerrors.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return xerrors.Errorf("sending advertisement: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode/100 != 2 {
+		return xerrors.Errorf("indexer returned status %d", resp.StatusCode)
+	}
+
+	if err := p.putJSON(dealKey(ad.ContextID), &dealAd{Seq: ad.Seq, IsRm: ad.IsRm}); err != nil {
+		return err
+	}
+	if err := p.putJSON(headKey, ad.Seq); err != nil {
+		return err
+	}
+
+	log.Infow("published advertisement", "seq", ad.Seq, "deal", ad.ContextID, "retract", ad.IsRm)
+
+	return nil
+}
+
+func (p *Publisher) head() (uint64, error) {
+	var head uint64
+	if _, err := p.getJSON(headKey, &head); err != nil {
+		return 0, err
+	}
+	return head, nil
+}
+
+func (p *Publisher) dealAd(proposal cid.Cid) (*dealAd, error) {
+	var da dealAd
+	found, err := p.getJSON(dealKey(proposal), &da)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &da, nil
+}
+
+func (p *Publisher) getJSON(k datastore.Key, out interface{}) (bool, error) {
+	b, err := p.ds.Get(k)
+	if err == datastore.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, xerrors.Errorf("loading %s: %w", k, err)
+	}
+
+	if err := json.Unmarshal(b, out); err != nil {
+		return false, xerrors.Errorf("unmarshaling %s: %w", k, err)
+	}
+	return true, nil
+}
+
+func (p *Publisher) putJSON(k datastore.Key, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return xerrors.Errorf("marshaling %s: %w", k, err)
+	}
+
+	if err := p.ds.Put(k, b); err != nil {
+		return xerrors.Errorf("storing %s: %w", k, err)
+	}
+	return nil
+}
+
+func dealKey(proposal cid.Cid) datastore.Key {
+	return dealsPrefix.ChildString(proposal.String())
+}
+
+func isIn(states map[storagemarket.StorageDealStatus]struct{}, st storagemarket.StorageDealStatus) bool {
+	_, ok := states[st]
+	return ok
+}
@@ -0,0 +1,97 @@
+package indexer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	market2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/market"
+)
+
+func mkDeal(prop string, state storagemarket.StorageDealStatus) storagemarket.MinerDeal {
+	return storagemarket.MinerDeal{
+		ClientDealProposal: market2.ClientDealProposal{
+			Proposal: market2.DealProposal{
+				PieceCID: blocks.NewBlock([]byte(prop + "piece")).Cid(),
+			},
+		},
+		ProposalCid: blocks.NewBlock([]byte(prop)).Cid(),
+		Ref: &storagemarket.DataRef{
+			Root: blocks.NewBlock([]byte(prop + "root")).Cid(),
+		},
+		State: state,
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	ctx := context.Background()
+
+	var lk sync.Mutex
+	var ads []Advertisement
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ad Advertisement
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ad))
+
+		lk.Lock()
+		ads = append(ads, ad)
+		lk.Unlock()
+	}))
+	defer srv.Close()
+
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	_, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	p := New(srv.URL, pid, maddr, func() []string { return nil }, ds)
+
+	sealing := mkDeal("deal1", storagemarket.StorageDealSealing)
+	transferring := mkDeal("deal2", storagemarket.StorageDealTransferring)
+	failed := mkDeal("deal3", storagemarket.StorageDealError)
+
+	for _, d := range []storagemarket.MinerDeal{sealing, transferring, failed} {
+		require.NoError(t, p.sync(ctx, d))
+	}
+
+	require.Len(t, ads, 1)
+	require.Equal(t, uint64(1), ads[0].Seq)
+	require.Equal(t, pid, ads[0].Provider)
+	require.Equal(t, sealing.ProposalCid, ads[0].ContextID)
+	require.Equal(t, sealing.Ref.Root, ads[0].Entries[0])
+	require.False(t, ads[0].IsRm)
+
+	// already announced
+	sealing.State = storagemarket.StorageDealActive
+	require.NoError(t, p.sync(ctx, sealing))
+	require.Len(t, ads, 1)
+
+	sealing.State = storagemarket.StorageDealExpired
+	require.NoError(t, p.sync(ctx, sealing))
+	require.Len(t, ads, 2)
+	require.Equal(t, uint64(2), ads[1].Seq)
+	require.Equal(t, uint64(1), ads[1].Previous)
+	require.Equal(t, sealing.ProposalCid, ads[1].ContextID)
+	require.True(t, ads[1].IsRm)
+
+	// publishing state is persisted
+	p = New(srv.URL, pid, maddr, func() []string { return nil }, ds)
+	require.NoError(t, p.sync(ctx, sealing))
+	require.Len(t, ads, 2)
+}
@@ -0,0 +1,161 @@
+package pieceindex
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/markets/retrievaladapter"
+)
+
+var log = logging.Logger("pieceindex")
+
+// Index locates payload blocks in the sectors of the miner. It resolves block
+// CIDs to the pieces containing them with the block locations the markets
+// piece store records when deals are handed off, and pieces to sectors with
+// the deals of the pieces.
+type Index struct {
+	ps  piecestore.PieceStore
+	rpn retrievalmarket.RetrievalProviderNode
+}
+
+func New(ps piecestore.PieceStore, rpn retrievalmarket.RetrievalProviderNode) *Index {
+	return &Index{ps: ps, rpn: rpn}
+}
+
+// Locate returns the locations of the block in the sectors storing it, none
+// if no piece is known to contain it
+func (idx *Index) Locate(block cid.Cid) ([]api.BlockLocation, error) {
+	ci, err := idx.ps.GetCIDInfo(block)
+	if err != nil {
+		if xerrors.Is(err, retrievalmarket.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("getting block locations: %w", err)
+	}
+
+	var out []api.BlockLocation
+	for _, bl := range ci.PieceBlockLocations {
+		pi, err := idx.ps.GetPieceInfo(bl.PieceCID)
+		if err != nil {
+			if xerrors.Is(err, retrievalmarket.ErrNotFound) {
+				continue
+			}
+			return nil, xerrors.Errorf("getting piece info: %w", err)
+		}
+
+		for _, d := range pi.Deals {
+			out = append(out, api.BlockLocation{
+				PieceCID:    bl.PieceCID,
+				DealID:      d.DealID,
+				Sector:      d.SectorID,
+				PieceOffset: d.Offset,
+				BlockOffset: bl.RelOffset,
+				BlockSize:   bl.BlockSize,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// Rebuild records the locations of all blocks of the piece, read from an
+// unsealed copy of a sector storing it, unsealing it if needed. The piece
+// must be a CAR, like the pieces of deals made with lotus clients.
+func (idx *Index) Rebuild(ctx context.Context, pieceCid cid.Cid) (int, error) {
+	pr, err := retrievaladapter.NewPieceReader(ctx, idx.ps, idx.rpn, pieceCid)
+	if err != nil {
+		return 0, err
+	}
+	defer pr.Close() //nolint:errcheck
+
+	locs, err := carBlockLocations(pr)
+	if err != nil {
+		return 0, xerrors.Errorf("reading blocks of piece %s: %w", pieceCid, err)
+	}
+
+	if err := idx.ps.AddPieceBlockLocations(pieceCid, locs); err != nil {
+		return 0, xerrors.Errorf("recording block locations: %w", err)
+	}
+
+	log.Infow("rebuilt piece index", "piece", pieceCid, "blocks", len(locs))
+
+	return len(locs), nil
+}
+
+type countingReader struct {
+	*bufio.Reader
+	n uint64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.n += uint64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.Reader.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
+
+// carBlockLocations returns the location of the data of each block of a CARv1
+// in the unpadded piece data. The zero padding of the piece ends the CAR.
+func carBlockLocations(r io.Reader) (map[cid.Cid]piecestore.BlockLocation, error) {
+	cr := &countingReader{Reader: bufio.NewReader(r)}
+
+	// skip the header
+	hlen, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return nil, xerrors.Errorf("reading CAR header length: %w", err)
+	}
+	if hlen == 0 {
+		return nil, xerrors.New("empty CAR header")
+	}
+	if _, err := cr.Discard(int(hlen)); err != nil {
+		return nil, xerrors.Errorf("reading CAR header: %w", err)
+	}
+	cr.n += hlen
+
+	locs := map[cid.Cid]piecestore.BlockLocation{}
+	for {
+		l, err := binary.ReadUvarint(cr)
+		if err == io.EOF {
+			return locs, nil
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("reading block length: %w", err)
+		}
+		if l == 0 {
+			return locs, nil
+		}
+
+		start := cr.n
+		section := make([]byte, l)
+		if _, err := io.ReadFull(cr, section); err != nil {
+			return nil, xerrors.Errorf("reading block: %w", err)
+		}
+
+		n, c, err := cid.CidFromBytes(section)
+		if err != nil {
+			return nil, xerrors.Errorf("reading block CID at %d: %w", start, err)
+		}
+
+		locs[c] = piecestore.BlockLocation{
+			RelOffset: start + uint64(n),
+			BlockSize: l - uint64(n),
+		}
+	}
+}
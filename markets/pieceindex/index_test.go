@@ -0,0 +1,41 @@
+package pieceindex
+
+import (
+	"bytes"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarBlockLocations(t *testing.T) {
+	var blks []blocks.Block
+	for _, d := range []string{"one", "two", "three"} {
+		blks = append(blks, blocks.NewBlock([]byte(d)))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{blks[0].Cid()}, Version: 1}, &buf))
+	for _, b := range blks {
+		require.NoError(t, util.LdWrite(&buf, b.Cid().Bytes(), b.RawData()))
+	}
+	data := buf.Bytes()
+
+	// pieces are zero padded after the CAR
+	piece := append(append([]byte{}, data...), make([]byte, 100)...)
+
+	for _, in := range [][]byte{data, piece} {
+		locs, err := carBlockLocations(bytes.NewReader(in))
+		require.NoError(t, err)
+		require.Len(t, locs, len(blks))
+
+		for _, b := range blks {
+			l, ok := locs[b.Cid()]
+			require.True(t, ok)
+			require.Equal(t, b.RawData(), in[l.RelOffset:l.RelOffset+l.BlockSize])
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package providerstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestScores(t *testing.T) {
+	good, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	bad, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	pending, err := address.NewIDAddress(1002)
+	require.NoError(t, err)
+
+	s := NewStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	start := time.Now()
+	require.NoError(t, s.RecordRetrieval(api.RetrievalRecord{Miner: good, Start: start, Duration: 2 * time.Second, BytesReceived: 2000}))
+	require.NoError(t, s.RecordRetrieval(api.RetrievalRecord{Miner: good, Start: start, Duration: 2 * time.Second, BytesReceived: 6000}))
+	require.NoError(t, s.RecordRetrieval(api.RetrievalRecord{Miner: bad, Start: start, Error: "rejected"}))
+
+	retrievals, err := s.Retrievals()
+	require.NoError(t, err)
+	require.Len(t, retrievals, 3)
+
+	deals := []DealRecord{
+		{Provider: good, State: storagemarket.StorageDealActive, Created: start, Activated: start.Add(time.Hour)},
+		{Provider: good, State: storagemarket.StorageDealActive, Created: start, Activated: start.Add(3 * time.Hour)},
+		{Provider: bad, State: storagemarket.StorageDealProposalRejected, Created: start},
+		{Provider: bad, State: storagemarket.StorageDealActive, Created: start},
+		{Provider: pending, State: storagemarket.StorageDealSealing, Created: start},
+	}
+
+	scores := Scores(deals, retrievals)
+	require.Len(t, scores, 3)
+
+	require.Equal(t, good, scores[0].Provider)
+	require.Equal(t, 1.0, scores[0].Score)
+	require.Equal(t, 2*time.Hour, scores[0].AvgTimeToActivation)
+	require.Equal(t, uint64(2000), scores[0].AvgRetrievalSpeed)
+
+	require.Equal(t, pending, scores[1].Provider)
+	require.Equal(t, api.NeutralProviderScore, scores[1].Score)
+
+	require.Equal(t, bad, scores[2].Provider)
+	require.Equal(t, 0.25, scores[2].Score)
+	require.Equal(t, 1, scores[2].RejectedDeals)
+	require.Equal(t, 1, scores[2].FailedRetrievals)
+}
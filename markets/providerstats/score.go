@@ -0,0 +1,119 @@
+package providerstats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// DealRecord is a storage deal made by the client
+type DealRecord struct {
+	Provider address.Address
+	State    storagemarket.StorageDealStatus
+	Created  time.Time
+	// Activated is when the deal sector was activated on chain, zero when
+	// it's not known
+	Activated time.Time
+}
+
+// Scores scores the providers the client made deals or retrievals with,
+// ordered from the best score
+func Scores(deals []DealRecord, retrievals []api.RetrievalRecord) []api.ProviderScore {
+	scores := map[address.Address]*api.ProviderScore{}
+	get := func(p address.Address) *api.ProviderScore {
+		s, ok := scores[p]
+		if !ok {
+			s = &api.ProviderScore{Provider: p}
+			scores[p] = s
+		}
+		return s
+	}
+
+	activation := map[address.Address][]time.Duration{}
+	for _, d := range deals {
+		s := get(d.Provider)
+		s.Deals++
+
+		switch d.State {
+		case storagemarket.StorageDealProposalRejected:
+			s.RejectedDeals++
+		case storagemarket.StorageDealFailing, storagemarket.StorageDealError, storagemarket.StorageDealSlashed:
+			s.FailedDeals++
+		case storagemarket.StorageDealActive, storagemarket.StorageDealExpired:
+			s.ActiveDeals++
+			if !d.Activated.IsZero() && d.Activated.After(d.Created) {
+				activation[d.Provider] = append(activation[d.Provider], d.Activated.Sub(d.Created))
+			}
+		}
+	}
+
+	received := map[address.Address]uint64{}
+	took := map[address.Address]time.Duration{}
+	for _, r := range retrievals {
+		s := get(r.Miner)
+		s.Retrievals++
+
+		if r.Error != "" {
+			s.FailedRetrievals++
+			continue
+		}
+
+		received[r.Miner] += r.BytesReceived
+		took[r.Miner] += r.Duration
+	}
+
+	out := make([]api.ProviderScore, 0, len(scores))
+	for p, s := range scores {
+		if n := len(activation[p]); n > 0 {
+			var total time.Duration
+			for _, d := range activation[p] {
+				total += d
+			}
+			s.AvgTimeToActivation = total / time.Duration(n)
+		}
+
+		if took[p] > 0 {
+			s.AvgRetrievalSpeed = uint64(float64(received[p]) / took[p].Seconds())
+		}
+
+		s.Score = score(s)
+		out = append(out, *s)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Provider.String() < out[j].Provider.String()
+	})
+
+	return out
+}
+
+// score is the share of successful deals and retrievals, weighting storage
+// deals and retrievals equally when the client made both with the provider
+func score(s *api.ProviderScore) float64 {
+	var sum float64
+	var parts int
+
+	if done := s.RejectedDeals + s.FailedDeals + s.ActiveDeals; done > 0 {
+		sum += float64(s.ActiveDeals) / float64(done)
+		parts++
+	}
+
+	if s.Retrievals > 0 {
+		sum += float64(s.Retrievals-s.FailedRetrievals) / float64(s.Retrievals)
+		parts++
+	}
+
+	if parts == 0 {
+		// only pending deals
+		return api.NeutralProviderScore
+	}
+
+	return sum / float64(parts)
+}
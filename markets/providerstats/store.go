@@ -0,0 +1,69 @@
+// Package providerstats keeps the history of retrievals made by the client,
+// and scores storage providers from it and from the local storage deals, so
+// clients can avoid providers which keep failing deals.
+package providerstats
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-storedcounter"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// Store records the outcome of retrievals made by the client
+type Store struct {
+	ds datastore.Batching
+	sc *storedcounter.StoredCounter
+}
+
+func NewStore(ds datastore.Batching) *Store {
+	return &Store{
+		ds: namespace.Wrap(ds, datastore.NewKey("/retrievals")),
+		sc: storedcounter.New(ds, datastore.NewKey("/counter")),
+	}
+}
+
+func (s *Store) RecordRetrieval(r api.RetrievalRecord) error {
+	id, err := s.sc.Next()
+	if err != nil {
+		return xerrors.Errorf("getting record id: %w", err)
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return xerrors.Errorf("marshaling retrieval record: %w", err)
+	}
+
+	return s.ds.Put(datastore.NewKey(fmt.Sprint(id)), b)
+}
+
+func (s *Store) Retrievals() ([]api.RetrievalRecord, error) {
+	res, err := s.ds.Query(query.Query{})
+	if err != nil {
+		return nil, xerrors.Errorf("querying retrieval records: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []api.RetrievalRecord
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating retrieval records: %w", r.Error)
+		}
+
+		var rec api.RetrievalRecord
+		if err := json.Unmarshal(r.Value, &rec); err != nil {
+			return nil, xerrors.Errorf("unmarshaling retrieval record %s: %w", r.Key, err)
+		}
+
+		out = append(out, rec)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,160 @@
+package retrievaladapter
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// seekAlign is the padded alignment of reads from the unsealed piece after a
+// seek. Unsealed data can only be read in power-of-two padded chunks aligned
+// to their size, so up to seekAlign bytes are read and skipped after a seek.
+var seekAlign = abi.PaddedPieceSize(1 << 20)
+
+// PieceReader is a seekable reader of the data of a piece, read from the
+// unsealed copy of a sector storing it. Missing parts of the unsealed copy
+// are unsealed on demand.
+type PieceReader struct {
+	ctx context.Context
+	rpn retrievalmarket.RetrievalProviderNode
+
+	sector abi.SectorNumber
+	offset abi.PaddedPieceSize // of the piece in the sector
+	size   abi.PaddedPieceSize
+
+	pos int64 // unpadded read position in the piece
+
+	r    io.ReadCloser // nil after a seek
+	rend int64         // unpadded position in the piece at which r ends
+}
+
+var _ io.ReadSeeker = &PieceReader{}
+
+// NewPieceReader returns a reader of the piece from the sector it was last
+// added to. Pieces of aborted sectors are added to other sectors again, so
+// earlier locations may be stale.
+func NewPieceReader(ctx context.Context, ps piecestore.PieceStore, rpn retrievalmarket.RetrievalProviderNode, pieceCid cid.Cid) (*PieceReader, error) {
+	pi, err := ps.GetPieceInfo(pieceCid)
+	if err != nil {
+		return nil, xerrors.Errorf("getting piece info: %w", err)
+	}
+	if len(pi.Deals) == 0 {
+		return nil, xerrors.Errorf("no sector stores piece %s: %w", pieceCid, retrievalmarket.ErrNotFound)
+	}
+
+	d := pi.Deals[len(pi.Deals)-1]
+	return &PieceReader{
+		ctx:    ctx,
+		rpn:    rpn,
+		sector: d.SectorID,
+		offset: d.Offset,
+		size:   d.Length,
+	}, nil
+}
+
+// Size returns the unpadded size of the piece
+func (pr *PieceReader) Size() int64 {
+	return int64(pr.size.Unpadded())
+}
+
+func (pr *PieceReader) Read(p []byte) (int, error) {
+	if pr.pos >= pr.Size() {
+		return 0, io.EOF
+	}
+
+	if pr.r == nil || pr.pos >= pr.rend {
+		if err := pr.openChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := pr.r.Read(p)
+	pr.pos += int64(n)
+	if err == io.EOF {
+		if pr.pos < pr.rend {
+			return n, io.ErrUnexpectedEOF
+		}
+		// the next chunk is opened by the next read
+		err = nil
+	}
+
+	return n, err
+}
+
+func (pr *PieceReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += pr.pos
+	case io.SeekEnd:
+		offset += pr.Size()
+	default:
+		return 0, xerrors.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, xerrors.New("seeking to a negative position")
+	}
+
+	if offset != pr.pos && pr.r != nil {
+		if err := pr.r.Close(); err != nil {
+			return 0, xerrors.Errorf("closing piece chunk reader: %w", err)
+		}
+		pr.r = nil
+	}
+	pr.pos = offset
+
+	return offset, nil
+}
+
+func (pr *PieceReader) Close() error {
+	if pr.r == nil {
+		return nil
+	}
+
+	err := pr.r.Close()
+	pr.r = nil
+	return err
+}
+
+// openChunk opens a reader of the largest aligned chunk of the piece starting
+// at the read position, rounded down to seekAlign
+func (pr *PieceReader) openChunk() error {
+	if pr.r != nil {
+		if err := pr.r.Close(); err != nil {
+			return xerrors.Errorf("closing piece chunk reader: %w", err)
+		}
+		pr.r = nil
+	}
+
+	align := seekAlign
+	if align > pr.size {
+		align = pr.size
+	}
+
+	start := abi.PaddedPieceSize(uint64(pr.pos)/uint64(align.Unpadded())) * align
+	chunk := pr.size
+	if start > 0 {
+		chunk = start & -start // lowest set bit, the largest chunk aligned at start
+	}
+
+	r, err := pr.rpn.UnsealSector(pr.ctx, pr.sector, pr.offset.Unpadded()+start.Unpadded(), chunk.Unpadded())
+	if err != nil {
+		return xerrors.Errorf("reading piece from sector %d: %w", pr.sector, err)
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, r, pr.pos-int64(start.Unpadded())); err != nil {
+		_ = r.Close()
+		return xerrors.Errorf("skipping to read position: %w", err)
+	}
+
+	pr.r = r
+	pr.rend = int64((start + chunk).Unpadded())
+	return nil
+}
@@ -0,0 +1,105 @@
+package retrievaladapter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+// testUnsealNode serves unsealed sector data, checking that reads are
+// power-of-two padded chunks aligned to their size, as unsealed sector data
+// can only be read this way
+type testUnsealNode struct {
+	retrievalmarket.RetrievalProviderNode
+
+	t      *testing.T
+	sector []byte
+	reads  int
+}
+
+func (n *testUnsealNode) UnsealSector(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (io.ReadCloser, error) {
+	require.NoError(n.t, length.Validate())
+	require.Zero(n.t, offset.Padded()%length.Padded())
+
+	n.reads++
+	return ioutil.NopCloser(bytes.NewReader(n.sector[offset : offset+length])), nil
+}
+
+func TestPieceReader(t *testing.T) {
+	size := abi.PaddedPieceSize(8 << 20)
+	offset := size // second piece in the sector
+
+	sector := make([]byte, (2 * size).Unpadded())
+	_, _ = rand.New(rand.NewSource(1)).Read(sector)
+	piece := sector[offset.Unpadded():]
+
+	n := &testUnsealNode{t: t, sector: sector}
+	pr := &PieceReader{
+		ctx:    context.Background(),
+		rpn:    n,
+		sector: 1,
+		offset: offset,
+		size:   size,
+	}
+
+	// sequential reads are done in one go
+	data, err := ioutil.ReadAll(pr)
+	require.NoError(t, err)
+	require.Equal(t, piece, data)
+	require.Equal(t, 1, n.reads)
+
+	end, err := pr.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(piece)), end)
+
+	for _, at := range []int64{0, 1, 127, 3 << 20, end - 1000} {
+		_, err := pr.Seek(at, io.SeekStart)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadAll(pr)
+		require.NoError(t, err)
+		require.Equal(t, piece[at:], data, "reading from %d", at)
+	}
+
+	require.NoError(t, pr.Close())
+}
+
+type testPieceStore struct {
+	piecestore.PieceStore
+
+	info piecestore.PieceInfo
+}
+
+func (ps *testPieceStore) GetPieceInfo(pieceCID cid.Cid) (piecestore.PieceInfo, error) {
+	return ps.info, nil
+}
+
+func TestNewPieceReaderLatestLocation(t *testing.T) {
+	ps := &testPieceStore{info: piecestore.PieceInfo{
+		Deals: []piecestore.DealInfo{
+			// the sector the piece was first added to was aborted
+			{DealID: 1, SectorID: 1, Offset: 0, Length: 2048},
+			{DealID: 1, SectorID: 7, Offset: 4096, Length: 2048},
+		},
+	}}
+
+	pr, err := NewPieceReader(context.Background(), ps, nil, cid.Undef)
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(7), pr.sector)
+	require.Equal(t, abi.PaddedPieceSize(4096), pr.offset)
+
+	ps.info.Deals = nil
+	_, err = NewPieceReader(context.Background(), ps, nil, cid.Undef)
+	require.True(t, xerrors.Is(err, retrievalmarket.ErrNotFound))
+}
@@ -2,13 +2,17 @@ package retrievaladapter
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/paych"
 	"github.com/filecoin-project/lotus/chain/types"
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
 	"github.com/filecoin-project/lotus/storage"
 
 	"github.com/filecoin-project/go-address"
@@ -18,18 +22,31 @@ import (
 	specstorage "github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
 )
 
 type retrievalProviderNode struct {
 	miner  *storage.Miner
 	sealer sectorstorage.SectorManager
 	full   api.FullNode
+
+	// pieces are read from the piece endpoint of a sealing node when the
+	// markets subsystem runs in a separate process
+	pieceURL    string
+	pieceHeader http.Header
 }
 
 // NewRetrievalProviderNode returns a new node adapter for a retrieval provider that talks to the
 // Lotus Node
 func NewRetrievalProviderNode(miner *storage.Miner, sealer sectorstorage.SectorManager, full api.FullNode) retrievalmarket.RetrievalProviderNode {
-	return &retrievalProviderNode{miner, sealer, full}
+	return &retrievalProviderNode{miner: miner, sealer: sealer, full: full}
+}
+
+// NewRemoteRetrievalProviderNode returns a node adapter for a retrieval
+// provider which reads unsealed pieces from the piece endpoint of a sealing
+// node at pieceURL
+func NewRemoteRetrievalProviderNode(pieceURL string, pieceHeader http.Header, full api.FullNode) retrievalmarket.RetrievalProviderNode {
+	return &retrievalProviderNode{full: full, pieceURL: pieceURL, pieceHeader: pieceHeader}
 }
 
 func (rpn *retrievalProviderNode) GetMinerWorkerAddress(ctx context.Context, miner address.Address, tok shared.TipSetToken) (address.Address, error) {
@@ -43,10 +60,17 @@ func (rpn *retrievalProviderNode) GetMinerWorkerAddress(ctx context.Context, min
 }
 
 func (rpn *retrievalProviderNode) UnsealSector(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (io.ReadCloser, error) {
+	if rpn.pieceURL != "" {
+		return rpn.unsealRemote(ctx, sectorID, offset, length)
+	}
+
 	si, err := rpn.miner.GetSectorInfo(sectorID)
 	if err != nil {
 		return nil, err
 	}
+	if si.State == sealing.Removed {
+		return nil, xerrors.Errorf("sector %d was removed", sectorID)
+	}
 
 	mid, err := address.IDFromAddress(rpn.miner.Address())
 	if err != nil {
@@ -74,6 +98,29 @@ func (rpn *retrievalProviderNode) UnsealSector(ctx context.Context, sectorID abi
 	return r, nil
 }
 
+func (rpn *retrievalProviderNode) unsealRemote(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%d/%d/%d", rpn.pieceURL, sectorID, offset, length), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("creating piece request: %w", err)
+	}
+	for k, v := range rpn.pieceHeader {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, xerrors.Errorf("requesting piece from the sealing node: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		_ = resp.Body.Close()
+		return nil, xerrors.Errorf("reading piece from the sealing node: %d: %s", resp.StatusCode, string(msg))
+	}
+
+	return resp.Body, nil
+}
+
 func (rpn *retrievalProviderNode) SavePaymentVoucher(ctx context.Context, paymentChannel address.Address, voucher *paych.SignedVoucher, proof []byte, expectedAmount abi.TokenAmount, tok shared.TipSetToken) (abi.TokenAmount, error) {
 	// TODO: respect the provided TipSetToken (a serialized TipSetKey) when
 	// querying the chain
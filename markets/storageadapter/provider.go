@@ -5,6 +5,7 @@ package storageadapter
 import (
 	"context"
 	"io"
+	"path/filepath"
 	"time"
 
 	"github.com/ipfs/go-cid"
@@ -29,9 +30,13 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
 	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/markets/clientquota"
+	"github.com/filecoin-project/lotus/markets/dealfilter"
 	"github.com/filecoin-project/lotus/markets/utils"
 	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/repo"
+	"github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
 )
 
@@ -46,29 +51,52 @@ type ProviderNodeAdapter struct {
 	// this goes away with the data transfer module
 	dag dtypes.StagingDAG
 
-	secb *sectorblocks.SectorBlocks
-	ev   *events.Events
+	secb    *sectorblocks.SectorBlocks
+	staging *PieceStaging
+	quotas  *clientquota.Quotas
+	ev      *events.Events
+
+	overrides    *dealfilter.OverrideStore
+	keepUnsealed string
 
 	publishSpec, addBalanceSpec *api.MessageSendSpec
 	dsMatcher                   *dealStateMatcher
 }
 
-func NewProviderNodeAdapter(fc *config.MinerFeeConfig) func(dag dtypes.StagingDAG, secb *sectorblocks.SectorBlocks, full api.FullNode) storagemarket.StorageProviderNode {
-	return func(dag dtypes.StagingDAG, secb *sectorblocks.SectorBlocks, full api.FullNode) storagemarket.StorageProviderNode {
+func NewProviderNodeAdapter(fc *config.MinerFeeConfig, dc *config.DealmakingConfig) func(r repo.LockedRepo, dag dtypes.StagingDAG, secb *sectorblocks.SectorBlocks, quotas *clientquota.Quotas, overrides *dealfilter.OverrideStore, full api.FullNode) (storagemarket.StorageProviderNode, error) {
+	return func(r repo.LockedRepo, dag dtypes.StagingDAG, secb *sectorblocks.SectorBlocks, quotas *clientquota.Quotas, overrides *dealfilter.OverrideStore, full api.FullNode) (storagemarket.StorageProviderNode, error) {
 		na := &ProviderNodeAdapter{
 			FullNode:   full,
 			apiWrapper: &apiWrapper{api: full},
 
 			dag:       dag,
 			secb:      secb,
+			quotas:    quotas,
 			ev:        events.NewEvents(context.TODO(), full),
 			dsMatcher: newDealStateMatcher(state.NewStatePredicates(state.WrapFastAPI(full))),
+
+			overrides: overrides,
 		}
 		if fc != nil {
 			na.publishSpec = &api.MessageSendSpec{MaxFee: abi.TokenAmount(fc.MaxPublishDealsFee)}
 			na.addBalanceSpec = &api.MessageSendSpec{MaxFee: abi.TokenAmount(fc.MaxMarketBalanceAddFee)}
 		}
-		return na
+		if dc != nil {
+			na.keepUnsealed = dc.KeepUnsealed
+		}
+		if dc != nil && dc.PieceStagingPath != "" {
+			dir := dc.PieceStagingPath
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(r.Path(), dir)
+			}
+
+			staging, err := NewPieceStaging(dir, dc.PieceStagingFormat)
+			if err != nil {
+				return nil, err
+			}
+			na.staging = staging
+		}
+		return na, nil
 	}
 }
 
@@ -102,11 +130,66 @@ func (n *ProviderNodeAdapter) PublishDeals(ctx context.Context, deal storagemark
 	return smsg.Cid(), nil
 }
 
+// dealLabels returns the labels of a market deal, which the ExclusiveLabels
+// and DedicatedLabels sealing config can refer to: "fast-retrieval" and
+// "verified" for such deals, and the labels set by the deal filter
+func dealLabels(deal storagemarket.MinerDeal, o dealfilter.Overrides) []string {
+	var labels []string
+	if deal.FastRetrieval {
+		labels = append(labels, "fast-retrieval")
+	}
+	if deal.Proposal.VerifiedDeal {
+		labels = append(labels, "verified")
+	}
+	return append(labels, o.Labels...)
+}
+
+// dealOverrides returns the deal filter overrides of the deal
+func (n *ProviderNodeAdapter) dealOverrides(deal storagemarket.MinerDeal) (dealfilter.Overrides, error) {
+	if n.overrides == nil {
+		return dealfilter.Overrides{}, nil
+	}
+	return n.overrides.Get(deal.ProposalCid)
+}
+
+// keepUnsealedCopy returns whether an unsealed copy of the deal data is kept
+// after sealing, following the KeepUnsealed policy and the deal filter
+// overrides of the deal
+func (n *ProviderNodeAdapter) keepUnsealedCopy(deal storagemarket.MinerDeal, o dealfilter.Overrides) (bool, error) {
+	var keep bool
+	switch n.keepUnsealed {
+	case "always":
+		keep = true
+	case "never":
+		keep = false
+	case "", "client":
+		keep = deal.FastRetrieval
+	default:
+		return false, xerrors.Errorf("unknown KeepUnsealed policy %q", n.keepUnsealed)
+	}
+
+	if o.KeepUnsealed != nil {
+		keep = *o.KeepUnsealed
+	}
+
+	return keep, nil
+}
+
 func (n *ProviderNodeAdapter) OnDealComplete(ctx context.Context, deal storagemarket.MinerDeal, pieceSize abi.UnpaddedPieceSize, pieceData io.Reader) (*storagemarket.PackingResult, error) {
 	if deal.PublishCid == nil {
 		return nil, xerrors.Errorf("deal.PublishCid can't be nil")
 	}
 
+	o, err := n.dealOverrides(deal)
+	if err != nil {
+		return nil, xerrors.Errorf("getting deal overrides: %w", err)
+	}
+
+	keepUnsealed, err := n.keepUnsealedCopy(deal, o)
+	if err != nil {
+		return nil, xerrors.Errorf("getting unsealed copy policy: %w", err)
+	}
+
 	sdInfo := sealing.DealInfo{
 		DealID:     deal.DealID,
 		PublishCid: deal.PublishCid,
@@ -114,36 +197,116 @@ func (n *ProviderNodeAdapter) OnDealComplete(ctx context.Context, deal storagema
 			StartEpoch: deal.ClientDealProposal.Proposal.StartEpoch,
 			EndEpoch:   deal.ClientDealProposal.Proposal.EndEpoch,
 		},
-		KeepUnsealed: deal.FastRetrieval,
+		KeepUnsealed: keepUnsealed,
+		Labels:       dealLabels(deal, o),
 	}
 
-	p, offset, err := n.secb.AddPiece(ctx, pieceSize, pieceData, sdInfo)
+	var p abi.SectorNumber
+	var offset abi.PaddedPieceSize
+	if n.staging != nil {
+		p, offset, err = n.handOffStaged(ctx, deal, pieceSize, pieceData, sdInfo)
+	} else {
+		err = retryAddPiece(ctx, deal.DealID, func() error {
+			done, err := n.quotas.AdmitPiece(deal.Proposal.Client)
+			if err != nil {
+				return err
+			}
+			defer done()
+
+			p, offset, err = n.secb.AddPiece(ctx, pieceSize, pieceData, sdInfo)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Warnf("New Deal: deal %d", deal.DealID)
+
+	if n.overrides != nil {
+		if err := n.overrides.Delete(deal.ProposalCid); err != nil {
+			log.Warnw("removing deal filter overrides", "proposal", deal.ProposalCid, "error", err)
+		}
+	}
+
+	return &storagemarket.PackingResult{
+		SectorNumber: p,
+		Offset:       offset,
+		Size:         pieceSize.Padded(),
+	}, nil
+}
+
+// retryAddPiece calls add until it succeeds, or fails for another reason than
+// sealing being busy
+func retryAddPiece(ctx context.Context, dealID abi.DealID, add func() error) error {
+	err := add()
 	curTime := time.Now()
 	for time.Since(curTime) < addPieceRetryTimeout {
-		if !xerrors.Is(err, sealing.ErrTooManySectorsSealing) {
+		if !xerrors.Is(err, sealing.ErrTooManySectorsSealing) && !xerrors.Is(err, sealing.ErrTooManyPendingPieces) && !xerrors.Is(err, clientquota.ErrQuotaExceeded) && !xerrors.Is(err, storage.ErrSealingInputPaused) {
 			if err != nil {
-				log.Errorf("failed to addPiece for deal %d, err: %w", deal.DealID, err)
+				log.Errorf("failed to addPiece for deal %d, err: %w", dealID, err)
 			}
 			break
 		}
 		select {
 		case <-time.After(addPieceRetryWait):
-			p, offset, err = n.secb.AddPiece(ctx, pieceSize, pieceData, sdInfo)
+			err = add()
 		case <-ctx.Done():
-			return nil, xerrors.New("context expired while waiting to retry AddPiece")
+			return xerrors.New("context expired while waiting to retry AddPiece")
 		}
 	}
 
 	if err != nil {
-		return nil, xerrors.Errorf("AddPiece failed: %s", err)
+		return xerrors.Errorf("AddPiece failed: %s", err)
 	}
-	log.Warnf("New Deal: deal %d", deal.DealID)
+	return nil
+}
 
-	return &storagemarket.PackingResult{
-		SectorNumber: p,
-		Offset:       offset,
-		Size:         pieceSize.Padded(),
-	}, nil
+// handOffStaged stages the piece data, reserves space for it in a sector, and
+// writes the staged copy into the reserved space. The deal is only handed off
+// once the piece is written, as reservations aren't kept across restarts.
+func (n *ProviderNodeAdapter) handOffStaged(ctx context.Context, deal storagemarket.MinerDeal, pieceSize abi.UnpaddedPieceSize, pieceData io.Reader, sdInfo sealing.DealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error) {
+	staged, err := n.staging.Stage(deal.ProposalCid, pieceSize, pieceData)
+	if err != nil {
+		return 0, 0, xerrors.Errorf("staging piece data: %w", err)
+	}
+
+	var lease api.PieceLease
+	var quotaDone func()
+	err = retryAddPiece(ctx, deal.DealID, func() error {
+		done, err := n.quotas.AdmitPiece(deal.Proposal.Client)
+		if err != nil {
+			return err
+		}
+
+		lease, err = n.secb.ReservePiece(ctx, pieceSize, sdInfo)
+		if err != nil {
+			done()
+			return err
+		}
+
+		quotaDone = done
+		return nil
+	})
+	if err != nil {
+		staged.Remove()
+		return 0, 0, err
+	}
+
+	defer quotaDone()
+	defer staged.Remove()
+
+	r, err := staged.Open()
+	if err != nil {
+		return 0, 0, xerrors.Errorf("opening staged piece: %w", err)
+	}
+	defer r.Close() // nolint
+
+	l, err := n.secb.CommitPiece(ctx, lease.ID, r)
+	if err != nil {
+		return 0, 0, xerrors.Errorf("writing staged piece into sector %d: %w", lease.Sector, err)
+	}
+
+	return l.Sector, l.Offset, nil
 }
 
 func (n *ProviderNodeAdapter) VerifySignature(ctx context.Context, sig crypto.Signature, addr address.Address, input []byte, encodedTs shared.TipSetToken) (bool, error) {
@@ -247,7 +410,7 @@ func (n *ProviderNodeAdapter) LocatePieceForDealWithinSector(ctx context.Context
 	var best api.SealedRef
 	var bestSi sealing.SectorInfo
 	for _, r := range refs {
-		si, err := n.secb.Miner.GetSectorInfo(r.SectorID)
+		si, err := n.secb.GetSectorInfo(r.SectorID)
 		if err != nil {
 			return 0, 0, 0, xerrors.Errorf("getting sector info: %w", err)
 		}
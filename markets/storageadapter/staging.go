@@ -0,0 +1,205 @@
+package storageadapter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/lib/nullreader"
+)
+
+// Formats staged pieces are written in
+const (
+	// StagingRaw writes the piece data as is
+	StagingRaw = "raw"
+	// StagingCAR writes the CAR file of the deal payload, without the zero
+	// padding of the piece
+	StagingCAR = "car"
+)
+
+// PieceStaging spills incoming piece data to a staging directory, so that space
+// in a sector can be reserved before the piece is written, and AddPiece can be
+// retried from the start of the piece.
+type PieceStaging struct {
+	dir    string
+	format string
+}
+
+// NewPieceStaging creates the staging directory, removing pieces left over
+// from a previous run; deals are only handed off once their piece is written
+// into a sector, so interrupted hand offs are restarted by the deal state
+// machine with fresh piece data.
+func NewPieceStaging(dir string, format string) (*PieceStaging, error) {
+	switch format {
+	case "":
+		format = StagingRaw
+	case StagingRaw, StagingCAR:
+	default:
+		return nil, xerrors.Errorf("unknown piece staging format %q", format)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil { // nolint
+		return nil, xerrors.Errorf("creating piece staging dir: %w", err)
+	}
+
+	ents, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, xerrors.Errorf("listing piece staging dir: %w", err)
+	}
+
+	for _, ent := range ents {
+		if ent.IsDir() {
+			continue
+		}
+
+		log.Infow("removing stale staged piece", "file", ent.Name())
+		if err := os.Remove(filepath.Join(dir, ent.Name())); err != nil {
+			log.Errorw("removing stale staged piece", "file", ent.Name(), "error", err)
+		}
+	}
+
+	return &PieceStaging{dir: dir, format: format}, nil
+}
+
+// StagedPiece is piece data written to the staging directory
+type StagedPiece struct {
+	path string
+	size abi.UnpaddedPieceSize
+}
+
+// Stage writes the piece data for the given deal proposal to the staging
+// directory
+func (s *PieceStaging) Stage(proposal cid.Cid, size abi.UnpaddedPieceSize, r io.Reader) (*StagedPiece, error) {
+	path := filepath.Join(s.dir, proposal.String())
+
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644) // nolint
+	if err != nil {
+		return nil, xerrors.Errorf("creating staged piece file: %w", err)
+	}
+
+	var n int64
+	switch s.format {
+	case StagingCAR:
+		n, err = writeCAR(f, r)
+	default:
+		n, err = io.Copy(f, r)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n != int64(size) {
+		err = xerrors.Errorf("piece data size %d doesn't match piece size %d", n, size)
+	}
+	if err != nil {
+		_ = os.Remove(path + ".tmp")
+		return nil, xerrors.Errorf("writing staged piece: %w", err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, xerrors.Errorf("moving staged piece: %w", err)
+	}
+
+	return &StagedPiece{path: path, size: size}, nil
+}
+
+// writeCAR writes the CAR file at the start of the piece data, and checks that
+// the rest of the piece is zero padding. It returns the size of the piece data
+// read.
+func writeCAR(w io.Writer, r io.Reader) (int64, error) {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+
+	var read int64
+	var buf [binary.MaxVarintLen64]byte
+	for {
+		l, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, xerrors.Errorf("reading car section length: %w", err)
+		}
+		if l == 0 {
+			// the CAR ends where the padding of the piece starts
+			if err := br.UnreadByte(); err != nil {
+				return 0, err
+			}
+			break
+		}
+
+		n := binary.PutUvarint(buf[:], l)
+		if _, err := bw.Write(buf[:n]); err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(bw, br, int64(l)); err != nil {
+			return 0, xerrors.Errorf("copying car section: %w", err)
+		}
+		read += int64(n) + int64(l)
+	}
+
+	if read == 0 {
+		return 0, xerrors.Errorf("piece data doesn't start with a car file")
+	}
+
+	padding, err := io.Copy(zeroWriter{}, br)
+	if err != nil {
+		return 0, xerrors.Errorf("reading piece padding: %w", err)
+	}
+
+	return read + padding, bw.Flush()
+}
+
+type zeroWriter struct{}
+
+func (zeroWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b != 0 {
+			return 0, xerrors.Errorf("piece data after the car file isn't zero padding")
+		}
+	}
+	return len(p), nil
+}
+
+type stagedReader struct {
+	io.Reader
+	f *os.File
+}
+
+func (r *stagedReader) Close() error {
+	return r.f.Close()
+}
+
+// Open returns a reader for the staged piece data, starting at the beginning
+// of the piece. Pieces staged as CAR files are padded back to the piece size.
+func (p *StagedPiece) Open() (io.ReadCloser, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &stagedReader{
+		Reader: io.MultiReader(f, io.LimitReader(nullreader.Reader{}, int64(p.size)-st.Size())),
+		f:      f,
+	}, nil
+}
+
+// Remove deletes the staged piece data
+func (p *StagedPiece) Remove() {
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		log.Errorw("removing staged piece", "file", p.path, "error", err)
+	}
+}
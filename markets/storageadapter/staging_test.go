@@ -0,0 +1,190 @@
+package storageadapter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+	"github.com/filecoin-project/lotus/markets/clientquota"
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/storage/sectorblocks"
+)
+
+func TestPieceStaging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lotus-piece-staging-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+
+	// left over from a previous run
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stale"), []byte("stale"), 0644))
+
+	s, err := NewPieceStaging(dir, StagingRaw)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "stale"))
+	require.True(t, os.IsNotExist(err))
+
+	proposal, err := cid.Parse("bafy2bzacea3wsdh6y3a36tb3skempjoxqpuyompjbmfeyf34fi3uy6uue42v4")
+	require.NoError(t, err)
+
+	data := bytes.Repeat([]byte{1}, 127)
+
+	_, err = s.Stage(proposal, abi.UnpaddedPieceSize(254), bytes.NewReader(data))
+	require.Error(t, err, "short piece data")
+
+	staged, err := s.Stage(proposal, abi.UnpaddedPieceSize(127), bytes.NewReader(data))
+	require.NoError(t, err)
+
+	// the piece can be read from the start more than once
+	for i := 0; i < 2; i++ {
+		f, err := staged.Open()
+		require.NoError(t, err)
+
+		read, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.Equal(t, data, read)
+	}
+
+	staged.Remove()
+
+	ents, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, ents)
+}
+
+func TestPieceStagingCAR(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lotus-piece-staging-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+
+	s, err := NewPieceStaging(dir, StagingCAR)
+	require.NoError(t, err)
+
+	proposal, err := cid.Parse("bafy2bzacea3wsdh6y3a36tb3skempjoxqpuyompjbmfeyf34fi3uy6uue42v4")
+	require.NoError(t, err)
+
+	nd := dag.NewRawNode([]byte("piece data"))
+
+	var carData bytes.Buffer
+	require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{nd.Cid()}, Version: 1}, &carData))
+	require.NoError(t, util.LdWrite(&carData, nd.Cid().Bytes(), nd.RawData()))
+
+	size := abi.PaddedPieceSize(256).Unpadded()
+	piece := make([]byte, size)
+	copy(piece, carData.Bytes())
+
+	staged, err := s.Stage(proposal, size, bytes.NewReader(piece))
+	require.NoError(t, err)
+
+	// only the car file is written
+	st, err := os.Stat(staged.path)
+	require.NoError(t, err)
+	require.Equal(t, int64(carData.Len()), st.Size())
+
+	f, err := staged.Open()
+	require.NoError(t, err)
+	read, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, piece, read)
+
+	staged.Remove()
+
+	// data after the car file which isn't padding
+	piece[len(piece)-1] = 1
+	_, err = s.Stage(proposal, size, bytes.NewReader(piece))
+	require.Error(t, err)
+
+	_, err = NewPieceStaging(dir, "zip")
+	require.Error(t, err)
+}
+
+type testSectorBuilder struct {
+	sectorblocks.SectorBuilder
+
+	committed []byte
+	fail      bool
+}
+
+func (sb *testSectorBuilder) ReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d sealing.DealInfo) (api.PieceLease, error) {
+	return api.PieceLease{ID: 1, Sector: 5, Offset: 1024, Size: size, DealID: d.DealID}, nil
+}
+
+func (sb *testSectorBuilder) CommitPiece(ctx context.Context, lease uint64, r io.Reader) (api.PieceLease, error) {
+	if sb.fail {
+		return api.PieceLease{}, xerrors.New("sector gone")
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return api.PieceLease{}, err
+	}
+	sb.committed = data
+
+	return api.PieceLease{ID: lease, Sector: 5, Offset: 1024, Size: abi.UnpaddedPieceSize(len(data)), DealID: 3}, nil
+}
+
+func TestHandOffStaged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lotus-piece-staging-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+
+	staging, err := NewPieceStaging(dir, StagingRaw)
+	require.NoError(t, err)
+
+	quotas, err := clientquota.New(config.DealmakingConfig{})
+	require.NoError(t, err)
+
+	sb := &testSectorBuilder{}
+	n := &ProviderNodeAdapter{
+		secb:    sectorblocks.NewSectorBlocks(sb, dssync.MutexWrap(datastore.NewMapDatastore())),
+		staging: staging,
+		quotas:  quotas,
+	}
+
+	proposal, err := cid.Parse("bafy2bzacea3wsdh6y3a36tb3skempjoxqpuyompjbmfeyf34fi3uy6uue42v4")
+	require.NoError(t, err)
+
+	data := bytes.Repeat([]byte{1}, 127)
+	deal := storagemarket.MinerDeal{ProposalCid: proposal, DealID: 3}
+
+	// handed off once the data is written into the sector
+	sector, offset, err := n.handOffStaged(context.Background(), deal, 127, bytes.NewReader(data), sealing.DealInfo{DealID: 3})
+	require.NoError(t, err)
+	require.Equal(t, abi.SectorNumber(5), sector)
+	require.Equal(t, abi.PaddedPieceSize(1024), offset)
+	require.Equal(t, data, sb.committed)
+
+	// the staged piece is removed once it's written
+	ents, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, ents)
+
+	// the hand off fails if the piece can't be written
+	sb.fail = true
+	_, _, err = n.handOffStaged(context.Background(), deal, 127, bytes.NewReader(data), sealing.DealInfo{DealID: 3})
+	require.Error(t, err)
+
+	ents, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, ents)
+}
@@ -0,0 +1,132 @@
+package transferlimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout is how long the rate limiter of a transfer is kept after the
+// transfer last sent or received data
+const idleTimeout = time.Minute
+
+// Limiter throttles data transfers to a rate of bytes per second for each
+// transfer, and to a rate for all transfers together. A rate of 0 doesn't
+// limit transfers.
+type Limiter struct {
+	lk sync.Mutex
+
+	perTransfer uint64
+	total       uint64
+
+	all       *rate.Limiter
+	transfers map[interface{}]*transfer
+	lastSweep time.Time
+}
+
+type transfer struct {
+	lim      *rate.Limiter
+	lastUsed time.Time
+}
+
+func New(perTransfer, total uint64) *Limiter {
+	l := &Limiter{
+		transfers: map[interface{}]*transfer{},
+	}
+	l.SetLimits(perTransfer, total)
+	return l
+}
+
+// SetLimits changes the limits, including of the ongoing transfers
+func (l *Limiter) SetLimits(perTransfer, total uint64) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	l.perTransfer = perTransfer
+	l.total = total
+
+	l.all = newRateLimiter(total)
+	for _, t := range l.transfers {
+		t.lim = newRateLimiter(perTransfer)
+	}
+}
+
+func (l *Limiter) Limits() (perTransfer, total uint64) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	return l.perTransfer, l.total
+}
+
+// Delay returns how long the transfer identified by key has to wait before
+// sending or receiving n more bytes to stay within the limits
+func (l *Limiter) Delay(key interface{}, n uint64) time.Duration {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	t, ok := l.transfers[key]
+	if !ok {
+		t = &transfer{lim: newRateLimiter(l.perTransfer)}
+		l.transfers[key] = t
+	}
+	t.lastUsed = now
+
+	d := reserve(t.lim, now, n)
+	if ad := reserve(l.all, now, n); ad > d {
+		d = ad
+	}
+
+	return d
+}
+
+// sweep drops the rate limiters of idle transfers
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+
+	for key, t := range l.transfers {
+		if now.Sub(t.lastUsed) > idleTimeout {
+			delete(l.transfers, key)
+		}
+	}
+}
+
+func newRateLimiter(limit uint64) *rate.Limiter {
+	if limit == 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	burst := int(limit)
+	if uint64(burst) != limit || burst < 0 {
+		burst = int(^uint(0) >> 1)
+	}
+	return rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+// reserve reserves n bytes from the limiter, in chunks no larger than its
+// burst, and returns how long to wait until they can be used
+func reserve(lim *rate.Limiter, now time.Time, n uint64) time.Duration {
+	if lim.Limit() == rate.Inf {
+		return 0
+	}
+
+	var d time.Duration
+	for n > 0 {
+		c := n
+		if b := uint64(lim.Burst()); c > b {
+			c = b
+		}
+
+		// reservations queue up, so the delay of the last one is the total
+		d = lim.ReserveN(now, int(c)).DelayFrom(now)
+		n -= c
+	}
+
+	return d
+}
@@ -0,0 +1,34 @@
+package transferlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := New(0, 0)
+	require.Zero(t, l.Delay("a", 1<<30))
+
+	// the burst of the transfer is used up first, the rest takes a second
+	l.SetLimits(1000, 0)
+	require.Zero(t, l.Delay("a", 1000))
+	require.InDelta(t, float64(time.Second), float64(l.Delay("a", 1000)), float64(100*time.Millisecond))
+
+	// other transfers have their own limit
+	require.Zero(t, l.Delay("b", 1000))
+
+	// the total limit applies to all transfers together
+	l.SetLimits(0, 1000)
+	require.Zero(t, l.Delay("a", 600))
+	require.InDelta(t, float64(200*time.Millisecond), float64(l.Delay("b", 600)), float64(100*time.Millisecond))
+
+	// transfers larger than the burst
+	l.SetLimits(1000, 0)
+	require.InDelta(t, float64(2*time.Second), float64(l.Delay("c", 3000)), float64(100*time.Millisecond))
+
+	per, total := l.Limits()
+	require.Equal(t, uint64(1000), per)
+	require.Zero(t, total)
+}
@@ -0,0 +1,365 @@
+package transports
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	_ "github.com/ipfs/go-merkledag" // registers the dag-pb, raw and dag-cbor decoders
+	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin/paych"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/markets/retrievaladapter"
+)
+
+// VoucherHeader is the header carrying the payment channel voucher paying for
+// an HTTP retrieval, base64 encoded like the vouchers of the paych commands
+const VoucherHeader = "X-Payment-Voucher"
+
+// HTTPHandler serves /payload/{root}, the data of the piece containing the
+// payload root, read from an unsealed sector. The piece can be chosen with the
+// piece query parameter. Range requests are supported.
+//
+// Reading the piece may unseal it, so requests must carry a bearer token
+// issued for the HTTP transport by the provider, see SignToken. Each request
+// is a retrieval deal of its own: it goes through the retrieval deal filter,
+// and must carry a voucher paying for the whole piece at the current retrieval
+// ask before any data is read.
+type HTTPHandler struct {
+	ps  piecestore.PieceStore
+	rpn retrievalmarket.RetrievalProviderNode
+
+	// returns false when the provider doesn't serve retrievals
+	enabled func() bool
+	// returns an error when the token doesn't allow retrievals
+	authorize func(token string) error
+	// returns the current retrieval ask
+	ask func() *retrievalmarket.Ask
+	// decides on retrievals like on retrieval deals proposed over libp2p
+	filter func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error)
+
+	router *mux.Router
+}
+
+func NewHTTPHandler(ps piecestore.PieceStore, rpn retrievalmarket.RetrievalProviderNode, enabled func() bool, authorize func(token string) error, ask func() *retrievalmarket.Ask, filter func(context.Context, retrievalmarket.ProviderDealState) (bool, string, error)) *HTTPHandler {
+	h := &HTTPHandler{ps: ps, rpn: rpn, enabled: enabled, authorize: authorize, ask: ask, filter: filter, router: mux.NewRouter()}
+	h.router.HandleFunc("/payload/{root}", h.servePayload).Methods("GET")
+	return h
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *HTTPHandler) servePayload(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled() {
+		http.Error(w, "provider is not serving retrievals at the moment", http.StatusServiceUnavailable)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		http.Error(w, "missing retrieval token", http.StatusUnauthorized)
+		return
+	}
+	if err := h.authorize(token); err != nil {
+		log.Debugw("unauthorized HTTP retrieval", "remote", r.RemoteAddr, "error", err)
+		http.Error(w, "invalid retrieval token", http.StatusUnauthorized)
+		return
+	}
+
+	root, err := cid.Parse(mux.Vars(r)["root"])
+	if err != nil {
+		http.Error(w, "parsing payload CID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var piece *cid.Cid
+	if p := r.URL.Query().Get("piece"); p != "" {
+		c, err := cid.Parse(p)
+		if err != nil {
+			http.Error(w, "parsing piece CID: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		piece = &c
+	}
+
+	pieceCid, err := h.payloadPiece(root, piece)
+	if err != nil {
+		if xerrors.Is(err, retrievalmarket.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pr, err := retrievaladapter.NewPieceReader(r.Context(), h.ps, h.rpn, pieceCid)
+	if err != nil {
+		if xerrors.Is(err, retrievalmarket.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer pr.Close() //nolint:errcheck
+
+	ask := h.ask()
+	deal := retrievalmarket.ProviderDealState{
+		DealProposal: retrievalmarket.DealProposal{
+			PayloadCID: root,
+			Params: retrievalmarket.Params{
+				PieceCID:                &pieceCid,
+				PricePerByte:            ask.PricePerByte,
+				PaymentInterval:         ask.PaymentInterval,
+				PaymentIntervalIncrease: ask.PaymentIntervalIncrease,
+				UnsealPrice:             ask.UnsealPrice,
+			},
+		},
+		Status: retrievalmarket.DealStatusNew,
+	}
+
+	accept, reason, err := h.filter(r.Context(), deal)
+	if err != nil {
+		http.Error(w, "miner error", http.StatusInternalServerError)
+		log.Errorw("filtering HTTP retrieval", "root", root, "piece", pieceCid, "error", err)
+		return
+	}
+	if !accept {
+		http.Error(w, reason, http.StatusForbidden)
+		return
+	}
+
+	price := big.Add(big.Mul(ask.PricePerByte, abi.NewTokenAmount(pr.Size())), ask.UnsealPrice)
+	if err := h.checkPayment(r, price); err != nil {
+		log.Debugw("unpaid HTTP retrieval", "root", root, "piece", pieceCid, "remote", r.RemoteAddr, "error", err)
+		http.Error(w, fmt.Sprintf("retrieving the piece costs %s: %s", types.FIL(price), err), http.StatusPaymentRequired)
+		return
+	}
+
+	log.Infow("serving retrieval over HTTP", "root", root, "piece", pieceCid, "paid", types.FIL(price), "remote", r.RemoteAddr)
+
+	// set, so that ServeContent doesn't sniff the content type
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, pr)
+}
+
+// checkPayment adds the voucher sent with the request, and checks that it pays
+// at least price. Vouchers are only accepted once, as adding a voucher again
+// doesn't increase the amount redeemable from its lane.
+func (h *HTTPHandler) checkPayment(r *http.Request, price abi.TokenAmount) error {
+	if price.IsZero() {
+		return nil
+	}
+
+	enc := r.Header.Get(VoucherHeader)
+	if enc == "" {
+		return xerrors.New("missing payment voucher")
+	}
+
+	sv, err := paych.DecodeSignedVoucher(enc)
+	if err != nil {
+		return xerrors.Errorf("decoding payment voucher: %w", err)
+	}
+
+	tok, _, err := h.rpn.GetChainHead(r.Context())
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	received, err := h.rpn.SavePaymentVoucher(r.Context(), sv.ChannelAddr, sv, nil, price, tok)
+	if err != nil {
+		return xerrors.Errorf("adding payment voucher: %w", err)
+	}
+	if received.LessThan(price) {
+		return xerrors.Errorf("voucher only pays %s", types.FIL(received))
+	}
+
+	return nil
+}
+
+// payloadPiece returns the piece containing the payload root, piece if it's set
+func (h *HTTPHandler) payloadPiece(root cid.Cid, piece *cid.Cid) (cid.Cid, error) {
+	ci, err := h.ps.GetCIDInfo(root)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("getting payload info: %w", err)
+	}
+
+	for _, bl := range ci.PieceBlockLocations {
+		if piece == nil || bl.PieceCID.Equals(*piece) {
+			return bl.PieceCID, nil
+		}
+	}
+
+	return cid.Undef, xerrors.Errorf("no piece containing payload %s: %w", root, retrievalmarket.ErrNotFound)
+}
+
+func encodeVoucher(sv *paych.SignedVoucher) (string, error) {
+	var buf bytes.Buffer
+	if err := sv.MarshalCBOR(&buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += uint64(n)
+	return n, err
+}
+
+// FetchPayload retrieves the piece containing the payload root from the HTTP
+// transport of a provider, passes its nodes to put, and returns the number of
+// bytes received. When piece is set, the payload is retrieved from that piece.
+// The token is issued by the provider, and the voucher pays for the piece; it
+// can be nil when the provider serves the piece for free. It fails unless the
+// piece holds the whole DAG under the root.
+func FetchPayload(ctx context.Context, url string, token string, voucher *paych.SignedVoucher, root cid.Cid, piece *cid.Cid, put func(ipld.Node) error) (uint64, error) {
+	u := fmt.Sprintf("%s/payload/%s", strings.TrimSuffix(url, "/"), root)
+	if piece != nil {
+		u += "?piece=" + piece.String()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, xerrors.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if voucher != nil {
+		enc, err := encodeVoucher(voucher)
+		if err != nil {
+			return 0, xerrors.Errorf("encoding payment voucher: %w", err)
+		}
+		req.Header.Set(VoucherHeader, enc)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, xerrors.Errorf("requesting payload: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return 0, xerrors.Errorf("provider responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	cr := &countingReader{r: resp.Body}
+	links, err := readPieceCar(cr, put)
+	if err != nil {
+		return cr.n, err
+	}
+
+	if err := checkComplete(root, links); err != nil {
+		return cr.n, err
+	}
+
+	return cr.n, nil
+}
+
+// readPieceCar passes the nodes of the CAR the piece data consists of to put,
+// after checking that their data matches their CID. It returns the links of
+// all nodes. The zero padding of the piece ends the CAR.
+func readPieceCar(r io.Reader, put func(ipld.Node) error) (map[cid.Cid][]cid.Cid, error) {
+	br := bufio.NewReader(r)
+
+	if _, err := car.ReadHeader(br); err != nil {
+		return nil, xerrors.Errorf("reading CAR header: %w", err)
+	}
+
+	links := map[cid.Cid][]cid.Cid{}
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return links, nil
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("reading CAR: %w", err)
+		}
+		if b[0] == 0 { // zero length section, start of the piece padding
+			return links, nil
+		}
+
+		c, data, err := util.ReadNode(br)
+		if err != nil {
+			return nil, xerrors.Errorf("reading block: %w", err)
+		}
+
+		sum, err := c.Prefix().Sum(data)
+		if err != nil {
+			return nil, xerrors.Errorf("hashing block %s: %w", c, err)
+		}
+		if !sum.Equals(c) {
+			return nil, xerrors.Errorf("data of block %s doesn't match its CID", c)
+		}
+
+		blk, err := blocks.NewBlockWithCid(data, c)
+		if err != nil {
+			return nil, xerrors.Errorf("checking block %s: %w", c, err)
+		}
+
+		nd, err := ipld.Decode(blk)
+		if err != nil {
+			return nil, xerrors.Errorf("decoding block %s: %w", c, err)
+		}
+
+		for _, l := range nd.Links() {
+			links[c] = append(links[c], l.Cid)
+		}
+		if _, ok := links[c]; !ok {
+			links[c] = nil
+		}
+
+		if err := put(nd); err != nil {
+			return nil, xerrors.Errorf("storing block %s: %w", c, err)
+		}
+	}
+}
+
+// checkComplete checks that all nodes of the DAG under root were received
+func checkComplete(root cid.Cid, links map[cid.Cid][]cid.Cid) error {
+	seen := map[cid.Cid]struct{}{}
+	todo := []cid.Cid{root}
+	for len(todo) > 0 {
+		c := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+
+		ls, ok := links[c]
+		if !ok {
+			return xerrors.Errorf("provider didn't send block %s of the DAG under %s", c, root)
+		}
+		todo = append(todo, ls...)
+	}
+
+	return nil
+}
@@ -0,0 +1,267 @@
+package transports
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/util"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin/paych"
+)
+
+func testDag(t *testing.T) (*dag.ProtoNode, []ipld.Node) {
+	var leaves []ipld.Node
+	root := &dag.ProtoNode{}
+	for _, d := range []string{"one", "two", "three"} {
+		leaf := dag.NewRawNode([]byte(d))
+		require.NoError(t, root.AddNodeLink(d, leaf))
+		leaves = append(leaves, leaf)
+	}
+	return root, leaves
+}
+
+// pieceCar writes the nodes as a CAR, zero padded like the data of a piece
+func pieceCar(t *testing.T, root cid.Cid, nds ...ipld.Node) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{root}, Version: 1}, &buf))
+	for _, nd := range nds {
+		require.NoError(t, util.LdWrite(&buf, nd.Cid().Bytes(), nd.RawData()))
+	}
+	buf.Write(make([]byte, 1000))
+	return buf.Bytes()
+}
+
+func serveBytes(t *testing.T, root cid.Cid, data []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/payload/"+root.String() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		_, _ = w.Write(data)
+	}))
+}
+
+func TestFetchPayload(t *testing.T) {
+	root, leaves := testDag(t)
+	srv := serveBytes(t, root.Cid(), pieceCar(t, root.Cid(), append([]ipld.Node{root}, leaves...)...))
+	defer srv.Close()
+
+	got := map[cid.Cid][]byte{}
+	put := func(nd ipld.Node) error {
+		got[nd.Cid()] = nd.RawData()
+		return nil
+	}
+
+	received, err := FetchPayload(context.Background(), srv.URL+"/", "secret", nil, root.Cid(), nil, put)
+	require.NoError(t, err)
+	require.NotZero(t, received)
+
+	require.Len(t, got, len(leaves)+1)
+	for _, nd := range append(leaves, root) {
+		require.Equal(t, nd.RawData(), got[nd.Cid()])
+	}
+
+	_, err = FetchPayload(context.Background(), srv.URL, "secret", nil, leaves[1].Cid(), nil, put)
+	require.Error(t, err)
+}
+
+func TestFetchPayloadIncomplete(t *testing.T) {
+	root, leaves := testDag(t)
+	srv := serveBytes(t, root.Cid(), pieceCar(t, root.Cid(), root, leaves[0], leaves[2]))
+	defer srv.Close()
+
+	_, err := FetchPayload(context.Background(), srv.URL, "secret", nil, root.Cid(), nil, func(ipld.Node) error { return nil })
+	require.Error(t, err)
+	require.Contains(t, err.Error(), leaves[1].Cid().String())
+}
+
+func TestFetchPayloadBadBlock(t *testing.T) {
+	root, leaves := testDag(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{root.Cid()}, Version: 1}, &buf))
+	require.NoError(t, util.LdWrite(&buf, root.Cid().Bytes(), root.RawData()))
+	// data which doesn't match the CID it's sent under
+	require.NoError(t, util.LdWrite(&buf, leaves[0].Cid().Bytes(), []byte("not one")))
+
+	srv := serveBytes(t, root.Cid(), buf.Bytes())
+	defer srv.Close()
+
+	var stored int
+	_, err := FetchPayload(context.Background(), srv.URL, "secret", nil, root.Cid(), nil, func(ipld.Node) error {
+		stored++
+		return nil
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, stored)
+}
+
+type testPieceStore struct {
+	piecestore.PieceStore
+
+	root  cid.Cid
+	piece cid.Cid
+}
+
+func (ps *testPieceStore) GetCIDInfo(payloadCID cid.Cid) (piecestore.CIDInfo, error) {
+	if !payloadCID.Equals(ps.root) {
+		return piecestore.CIDInfo{}, retrievalmarket.ErrNotFound
+	}
+	return piecestore.CIDInfo{
+		CID:                 ps.root,
+		PieceBlockLocations: []piecestore.PieceBlockLocation{{PieceCID: ps.piece}},
+	}, nil
+}
+
+func (ps *testPieceStore) GetPieceInfo(pieceCID cid.Cid) (piecestore.PieceInfo, error) {
+	return piecestore.PieceInfo{
+		PieceCID: ps.piece,
+		Deals:    []piecestore.DealInfo{{SectorID: 1, Offset: 0, Length: 2048}},
+	}, nil
+}
+
+type testProviderNode struct {
+	retrievalmarket.RetrievalProviderNode
+
+	data     []byte
+	unsealed int
+	redeemed abi.TokenAmount
+}
+
+func (rpn *testProviderNode) UnsealSector(ctx context.Context, sectorID abi.SectorNumber, offset abi.UnpaddedPieceSize, length abi.UnpaddedPieceSize) (io.ReadCloser, error) {
+	rpn.unsealed++
+	return ioutil.NopCloser(bytes.NewReader(rpn.data[offset : offset+length])), nil
+}
+
+func (rpn *testProviderNode) GetChainHead(ctx context.Context) (shared.TipSetToken, abi.ChainEpoch, error) {
+	return nil, 0, nil
+}
+
+// SavePaymentVoucher accepts vouchers on a single lane, like the payment
+// channel manager
+func (rpn *testProviderNode) SavePaymentVoucher(ctx context.Context, paymentChannel address.Address, voucher *paych.SignedVoucher, proof []byte, expectedAmount abi.TokenAmount, tok shared.TipSetToken) (abi.TokenAmount, error) {
+	delta := big.Sub(voucher.Amount, rpn.redeemed)
+	if delta.LessThan(expectedAmount) {
+		return big.Zero(), xerrors.Errorf("voucher pays %s, less than %s", delta, expectedAmount)
+	}
+	rpn.redeemed = voucher.Amount
+	return delta, nil
+}
+
+func testHTTPHandler(rpn *testProviderNode, ps *testPieceStore, ask *retrievalmarket.Ask, accept bool) *HTTPHandler {
+	authorize := func(token string) error {
+		if token != "secret" {
+			return xerrors.New("bad token")
+		}
+		return nil
+	}
+	filter := func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error) {
+		if !accept {
+			return false, "not accepting retrievals of " + deal.PayloadCID.String(), nil
+		}
+		return true, "", nil
+	}
+	return NewHTTPHandler(ps, rpn, func() bool { return true }, authorize, func() *retrievalmarket.Ask { return ask }, filter)
+}
+
+func TestHTTPHandlerAuth(t *testing.T) {
+	root, leaves := testDag(t)
+	data := make([]byte, abi.PaddedPieceSize(2048).Unpadded())
+	copy(data, pieceCar(t, root.Cid(), append([]ipld.Node{root}, leaves...)...))
+
+	ps := &testPieceStore{root: root.Cid(), piece: leaves[0].Cid()}
+	rpn := &testProviderNode{data: data}
+	free := &retrievalmarket.Ask{PricePerByte: big.Zero(), UnsealPrice: big.Zero()}
+
+	srv := httptest.NewServer(testHTTPHandler(rpn, ps, free, true))
+	defer srv.Close()
+
+	for _, token := range []string{"", "wrong"} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/payload/"+root.Cid().String(), nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+	require.Zero(t, rpn.unsealed, "unauthorized requests must not unseal")
+
+	var got int
+	_, err := FetchPayload(context.Background(), srv.URL, "secret", nil, root.Cid(), nil, func(ipld.Node) error {
+		got++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(leaves)+1, got)
+}
+
+func TestHTTPHandlerPayment(t *testing.T) {
+	root, leaves := testDag(t)
+	data := make([]byte, abi.PaddedPieceSize(2048).Unpadded())
+	copy(data, pieceCar(t, root.Cid(), append([]ipld.Node{root}, leaves...)...))
+
+	ps := &testPieceStore{root: root.Cid(), piece: leaves[0].Cid()}
+	rpn := &testProviderNode{data: data, redeemed: big.Zero()}
+	ask := &retrievalmarket.Ask{PricePerByte: abi.NewTokenAmount(1), UnsealPrice: abi.NewTokenAmount(10)}
+
+	ch, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	voucher := func(amount int64) *paych.SignedVoucher {
+		return &paych.SignedVoucher{ChannelAddr: ch, Amount: abi.NewTokenAmount(amount)}
+	}
+
+	fetch := func(srv *httptest.Server, sv *paych.SignedVoucher) error {
+		_, err := FetchPayload(context.Background(), srv.URL, "secret", sv, root.Cid(), nil, func(ipld.Node) error { return nil })
+		return err
+	}
+
+	// retrievals the deal filter rejects aren't paid for
+	rejecting := httptest.NewServer(testHTTPHandler(rpn, ps, ask, false))
+	defer rejecting.Close()
+
+	err = fetch(rejecting, voucher(2042))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not accepting retrievals")
+	require.True(t, rpn.redeemed.IsZero())
+
+	srv := httptest.NewServer(testHTTPHandler(rpn, ps, ask, true))
+	defer srv.Close()
+
+	// the piece is 2032 unpadded bytes, plus the unseal price
+	for _, sv := range []*paych.SignedVoucher{nil, voucher(2041)} {
+		err := fetch(srv, sv)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "402")
+	}
+	require.Zero(t, rpn.unsealed, "unpaid requests must not unseal")
+
+	require.NoError(t, fetch(srv, voucher(2042)))
+
+	// vouchers only pay once
+	err = fetch(srv, voucher(2042))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "402")
+}
@@ -0,0 +1,37 @@
+package transports
+
+import (
+	"github.com/gbrlsnchs/jwt/v3"
+	"golang.org/x/xerrors"
+)
+
+// TokenAudience is the audience of the tokens of the HTTP transport. They're
+// signed with a secret of their own, so they don't give access to the API of
+// the provider, and API tokens aren't accepted by the transport.
+const TokenAudience = "/payload"
+
+// TokenPayload is the payload of the tokens of the HTTP transport
+type TokenPayload struct {
+	Audience string
+}
+
+// SignToken issues a token for the HTTP transport
+func SignToken(alg jwt.Algorithm) (string, error) {
+	tok, err := jwt.Sign(&TokenPayload{Audience: TokenAudience}, alg)
+	if err != nil {
+		return "", err
+	}
+	return string(tok), nil
+}
+
+// VerifyToken checks that the token was issued for the HTTP transport
+func VerifyToken(token string, alg jwt.Algorithm) error {
+	var payload TokenPayload
+	if _, err := jwt.Verify([]byte(token), alg, &payload); err != nil {
+		return xerrors.Errorf("verifying token: %w", err)
+	}
+	if payload.Audience != TokenAudience {
+		return xerrors.Errorf("token audience %q isn't %q", payload.Audience, TokenAudience)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package transports
+
+import (
+	"testing"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyToken(t *testing.T) {
+	alg := jwt.NewHS256([]byte("transport secret"))
+
+	tok, err := SignToken(alg)
+	require.NoError(t, err)
+	require.NoError(t, VerifyToken(tok, alg))
+
+	// tokens signed with another secret, like API tokens
+	require.Error(t, VerifyToken(tok, jwt.NewHS256([]byte("api secret"))))
+
+	// tokens of the same secret for another audience
+	other, err := jwt.Sign(&TokenPayload{Audience: "/rpc"}, alg)
+	require.NoError(t, err)
+	require.Error(t, VerifyToken(string(other), alg))
+}
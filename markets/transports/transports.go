@@ -0,0 +1,124 @@
+package transports
+
+import (
+	"encoding/json"
+	"strings"
+
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	rmnet "github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
+)
+
+var log = logging.Logger("transports")
+
+// Names of the retrieval transports
+const (
+	// Graphsync is the retrieval protocol of the retrieval market, over libp2p
+	Graphsync = "graphsync"
+	// HTTP serves the data of pieces to clients holding a token issued by the
+	// provider, and paying for the piece with a voucher sent with the request
+	HTTP = "http"
+)
+
+// offerPrefix starts the message of retrieval query responses offering
+// transports besides graphsync
+const offerPrefix = "transports:"
+
+// Protocol is a retrieval transport offered by a provider
+type Protocol struct {
+	Name string
+	// URLs the transport is reached at, for HTTP
+	Addresses []string `json:",omitempty"`
+}
+
+// Offer lists the retrieval transports offered by a provider. It's sent in the
+// Message of the responses to retrieval queries, so clients negotiate the
+// transport with the query of the retrieval protocol.
+type Offer struct {
+	Protocols []Protocol
+}
+
+// NewOffer returns the offer of a provider serving HTTP retrievals at httpURL
+// in addition to graphsync, if it's set
+func NewOffer(httpURL string) *Offer {
+	o := &Offer{Protocols: []Protocol{{Name: Graphsync}}}
+	if httpURL != "" {
+		o.Protocols = append(o.Protocols, Protocol{Name: HTTP, Addresses: []string{httpURL}})
+	}
+	return o
+}
+
+// ParseOffer reads the transports offered in the message of a retrieval query
+// response. Providers which don't send an offer only serve graphsync.
+func ParseOffer(msg string) (*Offer, error) {
+	if !strings.HasPrefix(msg, offerPrefix) {
+		return NewOffer(""), nil
+	}
+
+	var o Offer
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(msg, offerPrefix)), &o); err != nil {
+		return nil, xerrors.Errorf("parsing transports offer: %w", err)
+	}
+	return &o, nil
+}
+
+func (o *Offer) message() (string, error) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return "", err
+	}
+	return offerPrefix + string(b), nil
+}
+
+// HTTPURL returns the URL of the HTTP transport, empty if it isn't offered
+func (o *Offer) HTTPURL() string {
+	for _, p := range o.Protocols {
+		if p.Name == HTTP && len(p.Addresses) > 0 {
+			return p.Addresses[0]
+		}
+	}
+	return ""
+}
+
+// NewNetwork wraps the retrieval market network of a provider to send the
+// offer in the responses to queries for available payloads
+func NewNetwork(n rmnet.RetrievalMarketNetwork, offer *Offer) rmnet.RetrievalMarketNetwork {
+	return &offerNetwork{RetrievalMarketNetwork: n, offer: offer}
+}
+
+type offerNetwork struct {
+	rmnet.RetrievalMarketNetwork
+	offer *Offer
+}
+
+func (n *offerNetwork) SetDelegate(r rmnet.RetrievalReceiver) error {
+	return n.RetrievalMarketNetwork.SetDelegate(&offerReceiver{RetrievalReceiver: r, offer: n.offer})
+}
+
+type offerReceiver struct {
+	rmnet.RetrievalReceiver
+	offer *Offer
+}
+
+func (r *offerReceiver) HandleQueryStream(s rmnet.RetrievalQueryStream) {
+	r.RetrievalReceiver.HandleQueryStream(&offerQueryStream{RetrievalQueryStream: s, offer: r.offer})
+}
+
+type offerQueryStream struct {
+	rmnet.RetrievalQueryStream
+	offer *Offer
+}
+
+func (s *offerQueryStream) WriteQueryResponse(resp retrievalmarket.QueryResponse) error {
+	if resp.Status == retrievalmarket.QueryResponseAvailable && resp.Message == "" {
+		msg, err := s.offer.message()
+		if err != nil {
+			return xerrors.Errorf("encoding transports offer: %w", err)
+		}
+		resp.Message = msg
+	}
+
+	return s.RetrievalQueryStream.WriteQueryResponse(resp)
+}
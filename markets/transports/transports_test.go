@@ -0,0 +1,48 @@
+package transports
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	rmnet "github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
+)
+
+type testQueryStream struct {
+	rmnet.RetrievalQueryStream
+
+	written []retrievalmarket.QueryResponse
+}
+
+func (s *testQueryStream) WriteQueryResponse(resp retrievalmarket.QueryResponse) error {
+	s.written = append(s.written, resp)
+	return nil
+}
+
+func TestOfferQueryResponse(t *testing.T) {
+	ts := &testQueryStream{}
+	qs := &offerQueryStream{RetrievalQueryStream: ts, offer: NewOffer("https://example.com/retrieval")}
+
+	require.NoError(t, qs.WriteQueryResponse(retrievalmarket.QueryResponse{Status: retrievalmarket.QueryResponseAvailable}))
+	require.NoError(t, qs.WriteQueryResponse(retrievalmarket.QueryResponse{Status: retrievalmarket.QueryResponseUnavailable}))
+	require.NoError(t, qs.WriteQueryResponse(retrievalmarket.QueryResponse{Status: retrievalmarket.QueryResponseError, Message: "failed"}))
+	require.Len(t, ts.written, 3)
+
+	offer, err := ParseOffer(ts.written[0].Message)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/retrieval", offer.HTTPURL())
+
+	// only responses for available payloads carry the offer
+	require.Empty(t, ts.written[1].Message)
+	require.Equal(t, "failed", ts.written[2].Message)
+
+	// providers without an offer serve graphsync only
+	offer, err = ParseOffer("")
+	require.NoError(t, err)
+	require.Empty(t, offer.HTTPURL())
+	require.Equal(t, []Protocol{{Name: Graphsync}}, offer.Protocols)
+
+	_, err = ParseOffer(offerPrefix + "{")
+	require.Error(t, err)
+}
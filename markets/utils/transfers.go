@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"context"
+	"sort"
+
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+var log = logging.Logger("markets-utils")
+
+// restartStatuses are the states of the data transfer channels which were
+// interrupted by a shutdown, and are restarted
+var restartStatuses = map[datatransfer.Status]bool{
+	datatransfer.Requested:       true,
+	datatransfer.Ongoing:         true,
+	datatransfer.InitiatorPaused: true,
+	datatransfer.ResponderPaused: true,
+	datatransfer.BothPaused:      true,
+}
+
+// TransfersToRestart returns the channels receiving data at self which were
+// interrupted before completing
+func TransfersToRestart(channels map[datatransfer.ChannelID]datatransfer.ChannelState, self peer.ID) []datatransfer.ChannelID {
+	var out []datatransfer.ChannelID
+	for chid, ch := range channels {
+		if ch.Recipient() != self || !restartStatuses[ch.Status()] {
+			continue
+		}
+		out = append(out, chid)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// RestartProviderTransfers restarts the transfers of deal data to the provider
+// which were interrupted by the last shutdown of the node
+func RestartProviderTransfers(ctx context.Context, dt datatransfer.Manager, self peer.ID) {
+	channels, err := dt.InProgressChannels(ctx)
+	if err != nil {
+		log.Errorf("listing data transfers to restart: %s", err)
+		return
+	}
+
+	for _, chid := range TransfersToRestart(channels, self) {
+		ch := channels[chid]
+		log.Infow("restarting data transfer", "channel", chid, "status", datatransfer.Statuses[ch.Status()], "received", ch.Received())
+		if err := dt.RestartDataTransferChannel(ctx, chid); err != nil {
+			log.Warnw("restarting data transfer", "channel", chid, "error", err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+type testChannel struct {
+	datatransfer.ChannelState
+
+	recipient peer.ID
+	status    datatransfer.Status
+}
+
+func (ch *testChannel) Recipient() peer.ID {
+	return ch.recipient
+}
+
+func (ch *testChannel) Status() datatransfer.Status {
+	return ch.status
+}
+
+func TestTransfersToRestart(t *testing.T) {
+	self, other := peer.ID("self"), peer.ID("other")
+
+	channels := map[datatransfer.ChannelID]datatransfer.ChannelState{}
+	add := func(id datatransfer.TransferID, recipient peer.ID, status datatransfer.Status) datatransfer.ChannelID {
+		chid := datatransfer.ChannelID{Initiator: other, Responder: self, ID: id}
+		channels[chid] = &testChannel{recipient: recipient, status: status}
+		return chid
+	}
+
+	requested := add(1, self, datatransfer.Requested)
+	ongoing := add(2, self, datatransfer.Ongoing)
+	paused := add(3, self, datatransfer.ResponderPaused)
+	bothPaused := add(4, self, datatransfer.BothPaused)
+	add(5, self, datatransfer.Completed)
+	add(6, self, datatransfer.Failed)
+	add(7, other, datatransfer.Ongoing)
+
+	require.Equal(t, []datatransfer.ChannelID{requested, ongoing, paused, bothPaused}, TransfersToRestart(channels, self))
+}
@@ -13,6 +13,7 @@ import (
 
 // Distribution
 var defaultMillisecondsDistribution = view.Distribution(0.01, 0.05, 0.1, 0.3, 0.6, 0.8, 1, 2, 3, 4, 5, 6, 8, 10, 13, 16, 20, 25, 30, 40, 50, 65, 80, 100, 130, 160, 200, 250, 300, 400, 500, 650, 800, 1000, 2000, 5000, 10000, 20000, 50000, 100000)
+var sealingSecondsDistribution = view.Distribution(1, 5, 10, 30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 5400, 7200, 10800, 14400, 21600, 28800, 43200, 86400)
 
 // Global Tags
 var (
@@ -28,6 +29,10 @@ var (
 	ReceivedFrom, _ = tag.NewKey("received_from")
 	Endpoint, _     = tag.NewKey("endpoint")
 	APIInterface, _ = tag.NewKey("api") // to distinguish between gateway api and full node api endpoint calls
+	StorageID, _    = tag.NewKey("storage_id")
+	TaskType, _     = tag.NewKey("task_type")
+	WorkerHost, _   = tag.NewKey("worker")
+	SectorState, _  = tag.NewKey("sector_state")
 )
 
 // Measures
@@ -57,6 +62,11 @@ var (
 	APIRequestDuration                  = stats.Float64("api/request_duration_ms", "Duration of API requests", stats.UnitMilliseconds)
 	VMFlushCopyDuration                 = stats.Float64("vm/flush_copy_ms", "Time spent in VM Flush Copy", stats.UnitMilliseconds)
 	VMFlushCopyCount                    = stats.Int64("vm/flush_copy_count", "Number of copied objects", stats.UnitDimensionless)
+	StorageHealthy                      = stats.Int64("storage/healthy", "Whether a storage path passes health checks (1) or not (0)", stats.UnitDimensionless)
+	StorageProbeReadLatency             = stats.Float64("storage/probe_read_ms", "Read latency of storage path health probes", stats.UnitMilliseconds)
+	StorageProbeWriteLatency            = stats.Float64("storage/probe_write_ms", "Write latency of storage path health probes", stats.UnitMilliseconds)
+	SealingTaskDuration                 = stats.Float64("sealing/task_duration_s", "Duration of successful sealing tasks on workers", stats.UnitSeconds)
+	SealingStateDuration                = stats.Float64("sealing/state_duration_s", "Time sectors spent in a sealing state", stats.UnitSeconds)
 )
 
 var (
@@ -176,6 +186,31 @@ var (
 		Measure:     VMFlushCopyCount,
 		Aggregation: view.Sum(),
 	}
+	StorageHealthyView = &view.View{
+		Measure:     StorageHealthy,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{StorageID},
+	}
+	StorageProbeReadLatencyView = &view.View{
+		Measure:     StorageProbeReadLatency,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{StorageID},
+	}
+	StorageProbeWriteLatencyView = &view.View{
+		Measure:     StorageProbeWriteLatency,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{StorageID},
+	}
+	SealingTaskDurationView = &view.View{
+		Measure:     SealingTaskDuration,
+		Aggregation: sealingSecondsDistribution,
+		TagKeys:     []tag.Key{TaskType, WorkerHost},
+	}
+	SealingStateDurationView = &view.View{
+		Measure:     SealingStateDuration,
+		Aggregation: sealingSecondsDistribution,
+		TagKeys:     []tag.Key{SectorState},
+	}
 )
 
 // DefaultViews is an array of OpenCensus views for metric gathering purposes
@@ -204,6 +239,11 @@ var DefaultViews = append([]*view.View{
 	APIRequestDurationView,
 	VMFlushCopyCountView,
 	VMFlushCopyDurationView,
+	StorageHealthyView,
+	StorageProbeReadLatencyView,
+	StorageProbeWriteLatencyView,
+	SealingTaskDurationView,
+	SealingStateDurationView,
 },
 	rpcmetrics.DefaultViews...)
 
@@ -0,0 +1,117 @@
+package miner
+
+import (
+	"context"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apibstore"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/reward"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// number of produced blocks kept for MinedBlocks
+const minedBlockHistory = 100
+
+// BlockCandidate returns the block the miner would produce on the current
+// mining base, if it won the round with a single win and the best possible
+// ticket
+func (m *Miner) BlockCandidate(ctx context.Context) (*api.BlockCandidate, error) {
+	base, err := m.GetBestMiningCandidate(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting mining base: %w", err)
+	}
+
+	weight, err := m.api.ChainTipSetWeight(ctx, base.TipSet.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting base weight: %w", err)
+	}
+
+	msgs, err := m.api.MpoolSelect(ctx, base.TipSet.Key(), 1)
+	if err != nil {
+		return nil, xerrors.Errorf("selecting messages: %w", err)
+	}
+
+	ract, err := m.api.StateGetActor(ctx, reward.Address, base.TipSet.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("loading reward actor: %w", err)
+	}
+
+	rst, err := reward.Load(adt.WrapStore(ctx, cbor.NewCborStore(apibstore.NewAPIBlockstore(m.api))), ract)
+	if err != nil {
+		return nil, xerrors.Errorf("loading reward actor state: %w", err)
+	}
+
+	epochReward, err := rst.ThisEpochReward()
+	if err != nil {
+		return nil, xerrors.Errorf("getting epoch reward: %w", err)
+	}
+
+	// messages in the new block are executed with the base fee computed from
+	// the base tipset; the parent base fee is a close enough estimate of it
+	baseFee := base.TipSet.Blocks()[0].ParentBaseFee
+
+	out := &api.BlockCandidate{
+		Base:         base.TipSet.Key(),
+		Height:       base.TipSet.Height() + base.NullRounds + 1,
+		NullRounds:   base.NullRounds,
+		ParentWeight: weight,
+		Messages:     make([]api.BlockCandidateMessage, len(msgs)),
+		BlockReward:  big.Div(epochReward, big.NewInt(int64(build.BlocksPerEpoch))),
+		GasReward:    big.Zero(),
+	}
+
+	for i, msg := range msgs {
+		premium := big.Min(msg.Message.GasPremium, big.Sub(msg.Message.GasFeeCap, baseFee))
+		if premium.LessThan(big.Zero()) {
+			premium = big.Zero()
+		}
+		gasReward := big.Mul(premium, big.NewInt(msg.Message.GasLimit))
+
+		out.Messages[i] = api.BlockCandidateMessage{
+			Cid:       msg.Cid(),
+			From:      msg.Message.From,
+			Nonce:     msg.Message.Nonce,
+			GasLimit:  msg.Message.GasLimit,
+			GasReward: gasReward,
+		}
+		out.GasLimit += msg.Message.GasLimit
+		out.GasReward = big.Add(out.GasReward, gasReward)
+	}
+
+	return out, nil
+}
+
+func (m *Miner) recordMinedBlock(base *MiningBase, b *types.BlockMsg, timings api.MinedBlockTimings) {
+	m.historyLk.Lock()
+	defer m.historyLk.Unlock()
+
+	m.minedBlocks = append(m.minedBlocks, api.MinedBlock{
+		Cid:        b.Cid(),
+		Height:     b.Header.Height,
+		Parents:    base.TipSet.Key(),
+		NullRounds: base.NullRounds,
+		Messages:   len(b.BlsMessages) + len(b.SecpkMessages),
+		Mined:      build.Clock.Now(),
+		Timings:    timings,
+	})
+
+	if len(m.minedBlocks) > minedBlockHistory {
+		m.minedBlocks = m.minedBlocks[len(m.minedBlocks)-minedBlockHistory:]
+	}
+}
+
+// MinedBlocks returns the most recent blocks produced by the miner, oldest
+// first
+func (m *Miner) MinedBlocks() []api.MinedBlock {
+	m.historyLk.Lock()
+	defer m.historyLk.Unlock()
+
+	return append([]api.MinedBlock{}, m.minedBlocks...)
+}
@@ -97,6 +97,9 @@ type Miner struct {
 	sf                *slashfilter.SlashFilter
 	minedBlockHeights *lru.ARCCache
 
+	historyLk   sync.Mutex
+	minedBlocks []api.MinedBlock
+
 	evtTypes [1]journal.EventType
 	journal  journal.Journal
 }
@@ -446,6 +449,17 @@ func (m *Miner) mineOne(ctx context.Context, base *MiningBase) (*types.BlockMsg,
 		parentMiners[i] = header.Miner
 	}
 	log.Infow("mined new block", "cid", b.Cid(), "height", b.Header.Height, "miner", b.Header.Miner, "parents", parentMiners, "took", dur)
+
+	m.recordMinedBlock(base, b, api.MinedBlockTimings{
+		MinerBaseInfo:  tMBI.Sub(start),
+		Election:       tTicket.Sub(tPowercheck),
+		Seed:           tSeed.Sub(tTicket),
+		WinningPoSt:    tProof.Sub(tSeed),
+		SelectMessages: tPending.Sub(tProof),
+		CreateBlock:    tCreateBlock.Sub(tPending),
+		Total:          dur,
+	})
+
 	if dur > time.Second*time.Duration(build.BlockDelaySecs) {
 		log.Warnw("CAUTION: block production took longer than the block delay. Your computer may not be fast enough to keep up",
 			"tMinerBaseInfo ", tMBI.Sub(start),
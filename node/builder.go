@@ -41,28 +41,42 @@ import (
 	storage2 "github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/bus"
 	"github.com/filecoin-project/lotus/chain/beacon"
+	"github.com/filecoin-project/lotus/chain/epochtasks"
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/gen/slashfilter"
 	"github.com/filecoin-project/lotus/chain/market"
 	"github.com/filecoin-project/lotus/chain/messagepool"
 	"github.com/filecoin-project/lotus/chain/messagesigner"
 	"github.com/filecoin-project/lotus/chain/metrics"
+	"github.com/filecoin-project/lotus/chain/netstats"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/types"
 	ledgerwallet "github.com/filecoin-project/lotus/chain/wallet/ledger"
 	"github.com/filecoin-project/lotus/chain/wallet/remotewallet"
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/extern/sector-storage/mock"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
 	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/lib/bandwidth"
+	"github.com/filecoin-project/lotus/lib/blockstore/splitstore"
 	"github.com/filecoin-project/lotus/lib/peermgr"
 	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
 	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+	"github.com/filecoin-project/lotus/lib/subsystems"
+	"github.com/filecoin-project/lotus/markets/askrefresh"
+	"github.com/filecoin-project/lotus/markets/clientquota"
 	"github.com/filecoin-project/lotus/markets/dealfilter"
+	"github.com/filecoin-project/lotus/markets/delegation"
+	"github.com/filecoin-project/lotus/markets/pieceindex"
+	"github.com/filecoin-project/lotus/markets/providerstats"
+	"github.com/filecoin-project/lotus/markets/retrievaladapter"
 	"github.com/filecoin-project/lotus/markets/storageadapter"
+	"github.com/filecoin-project/lotus/markets/transferlimit"
 	"github.com/filecoin-project/lotus/miner"
 	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/impl"
@@ -78,6 +92,8 @@ import (
 	"github.com/filecoin-project/lotus/paychmgr/settler"
 	"github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
+	"github.com/filecoin-project/lotus/storage/slareport"
+	"github.com/filecoin-project/lotus/storage/workerauth"
 )
 
 //nolint:deadcode,varcheck
@@ -122,6 +138,8 @@ const (
 
 	// filecoin
 	SetGenesisKey
+	CheckMockProofsKey
+	SetMpoolPriorityKey
 
 	RunHelloKey
 	RunChainExchangeKey
@@ -130,6 +148,7 @@ const (
 
 	HandleIncomingBlocksKey
 	HandleIncomingMessagesKey
+	EmitHeadChangesKey
 	HandleMigrateClientFundsKey
 	HandlePaymentChannelManagerKey
 
@@ -138,7 +157,18 @@ const (
 	HandleMigrateProviderFundsKey
 	HandleDealsKey
 	HandleRetrievalKey
+	SetRetrievalPaymentIntervalKey
+	SetClientQuotaDealsKey
+	HandleDealFilterOverridesKey
+	HandleContentAdvertisementsKey
+	HandleRetrievalTransportsKey
+	HandleTransferLimitsKey
 	RunSectorServiceKey
+	StorageHealthAlertsKey
+	SealingTaskMetricsKey
+	RunFundsManagerKey
+	RegisterDealSubsystemsKey
+	RunFeeBumperKey
 
 	// daemon
 	ExtractApiKey
@@ -146,6 +176,7 @@ const (
 	SettlePaymentChannelsKey
 	RunPeerTaggerKey
 	SetupFallbackBlockstoreKey
+	StartSplitstoreKey
 
 	SetApiEndpointKey
 
@@ -177,6 +208,11 @@ func defaults() []Option {
 		Override(new(journal.DisabledEvents), journal.EnvDisabledEvents),
 		Override(new(journal.Journal), modules.OpenFilesystemJournal),
 
+		// event bus between node subsystems
+		Override(new(bus.Bus), bus.New),
+		// subsystems which can be stopped and started over the API
+		Override(new(*subsystems.Registry), subsystems.New),
+
 		Override(new(system.MemoryConstraints), modules.MemoryConstraints),
 		Override(InitMemoryWatchdog, modules.MemoryWatchdog),
 
@@ -214,6 +250,7 @@ func libp2p() Option {
 
 		Override(NatPortMapKey, lp2p.NatPortMap),
 		Override(BandwidthReporterKey, lp2p.BandwidthCounter),
+		Override(new(*bandwidth.Recorder), bandwidth.NewRecorder),
 
 		Override(ConnectionManagerKey, lp2p.ConnectionManager(50, 200, 20*time.Second, nil)),
 		Override(AutoNATSvcKey, lp2p.AutoNATService),
@@ -302,9 +339,12 @@ func Online() Option {
 			Override(new(discovery.PeerResolver), modules.RetrievalResolver),
 
 			Override(new(retrievalmarket.RetrievalClient), modules.RetrievalClient),
+			Override(new(dtypes.ClientRetrievalPaymentConfig), dtypes.ClientRetrievalPaymentConfig{}),
 			Override(new(dtypes.ClientDatastore), modules.NewClientDatastore),
 			Override(new(dtypes.ClientDataTransfer), modules.NewClientGraphsyncDataTransfer),
 			Override(new(storagemarket.StorageClient), modules.StorageClient),
+			Override(new(*delegation.Store), modules.ClientDealAllowances),
+			Override(new(*providerstats.Store), modules.ClientProviderStats),
 			Override(new(storagemarket.StorageClientNode), storageadapter.NewClientNodeAdapter),
 			Override(new(beacon.Schedule), modules.RandomSchedule),
 
@@ -334,12 +374,15 @@ func Online() Option {
 			Override(new(full.MpoolModuleAPI), From(new(full.MpoolModule))),
 			Override(new(full.StateModuleAPI), From(new(full.StateModule))),
 			Override(new(stmgr.StateManagerAPI), From(new(*stmgr.StateManager))),
+			Override(new(*netstats.Recorder), netstats.NewRecorder),
+			Override(new(*epochtasks.Scheduler), modules.EpochTaskScheduler),
 
 			Override(RunHelloKey, modules.RunHello),
 			Override(RunChainExchangeKey, modules.RunChainExchange),
 			Override(RunPeerMgrKey, modules.RunPeerMgr),
 			Override(HandleIncomingMessagesKey, modules.HandleIncomingMessages),
 			Override(HandleIncomingBlocksKey, modules.HandleIncomingBlocks),
+			Override(EmitHeadChangesKey, modules.EmitHeadChanges),
 		),
 
 		// miner
@@ -348,6 +391,7 @@ func Online() Option {
 			Override(new(sectorstorage.StorageAuth), modules.StorageAuth),
 
 			Override(new(*stores.Index), stores.NewIndex),
+			Override(StorageHealthAlertsKey, modules.StorageHealthAlerts),
 			Override(new(stores.SectorIndex), From(new(*stores.Index))),
 			Override(new(dtypes.MinerID), modules.MinerID),
 			Override(new(dtypes.MinerAddress), modules.MinerAddress),
@@ -358,10 +402,14 @@ func Online() Option {
 			Override(new(ffiwrapper.Verifier), ffiwrapper.ProofVerifier),
 
 			Override(new(sectorstorage.SectorManager), From(new(*sectorstorage.Manager))),
+			Override(SealingTaskMetricsKey, modules.SealingTaskMetrics),
 			Override(new(storage2.Prover), From(new(sectorstorage.SectorManager))),
 			Override(new(storiface.WorkerReturn), From(new(sectorstorage.SectorManager))),
 
+			Override(new(sectorblocks.SectorBuilder), From(new(*storage.Miner))),
 			Override(new(*sectorblocks.SectorBlocks), sectorblocks.NewSectorBlocks),
+			Override(new(*workerauth.Authority), workerauth.New),
+			Override(new(*impl.PartitionsCache), impl.NewPartitionsCache),
 			Override(new(*storage.Miner), modules.StorageMiner(config.DefaultStorageMiner().Fees)),
 			Override(new(*storage.AddressSelector), modules.AddressSelector(nil)),
 			Override(new(dtypes.NetworkName), modules.StorageNetworkName),
@@ -370,14 +418,24 @@ func Online() Option {
 			Override(new(dtypes.StagingBlockstore), modules.StagingBlockstore),
 			Override(new(dtypes.StagingDAG), modules.StagingDAG),
 			Override(new(dtypes.StagingGraphsync), modules.StagingGraphsync),
+			Override(new(*transferlimit.Limiter), modules.TransferLimiter(config.DefaultStorageMiner().Dealmaking)),
+			Override(HandleTransferLimitsKey, modules.HandleTransferLimits),
+			Override(new(retrievalmarket.RetrievalProviderNode), retrievaladapter.NewRetrievalProviderNode),
 			Override(new(retrievalmarket.RetrievalProvider), modules.RetrievalProvider),
 			Override(new(dtypes.ProviderDataTransfer), modules.NewProviderDAGServiceDataTransfer),
 			Override(new(dtypes.ProviderPieceStore), modules.NewProviderPieceStore),
+			Override(new(*pieceindex.Index), modules.PieceIndex),
 			Override(new(*storedask.StoredAsk), modules.NewStorageAsk),
+			Override(new(*askrefresh.Refresher), modules.StorageAskRefresher(config.DefaultStorageMiner().Dealmaking, config.DefaultStorageMiner().Fees)),
+			Override(new(*slareport.Reporter), modules.SLAReporter(config.DefaultStorageMiner().SLAReports)),
+			Override(new(*clientquota.Quotas), modules.ClientQuotas(config.DefaultStorageMiner().Dealmaking)),
+			Override(SetClientQuotaDealsKey, modules.SetClientQuotaDeals),
+			Override(new(*dealfilter.OverrideStore), modules.DealFilterOverrides),
+			Override(HandleDealFilterOverridesKey, modules.HandleDealFilterOverrides),
 			Override(new(dtypes.StorageDealFilter), modules.BasicDealFilter(nil)),
 			Override(new(dtypes.RetrievalDealFilter), modules.RetrievalDealFilter(nil)),
 			Override(new(storagemarket.StorageProvider), modules.StorageProvider),
-			Override(new(storagemarket.StorageProviderNode), storageadapter.NewProviderNodeAdapter(nil)),
+			Override(new(storagemarket.StorageProviderNode), storageadapter.NewProviderNodeAdapter(nil, nil)),
 			Override(HandleMigrateProviderFundsKey, modules.HandleMigrateProviderFunds),
 			Override(HandleRetrievalKey, modules.HandleRetrieval),
 			Override(GetParamsKey, modules.GetParams),
@@ -403,6 +461,12 @@ func Online() Option {
 			Override(new(dtypes.GetSealingConfigFunc), modules.NewGetSealConfigFunc),
 			Override(new(dtypes.SetExpectedSealDurationFunc), modules.NewSetExpectedSealDurationFunc),
 			Override(new(dtypes.GetExpectedSealDurationFunc), modules.NewGetExpectedSealDurationFunc),
+			Override(new(dtypes.SetTransferLimitsConfigFunc), modules.NewSetTransferLimitsConfigFunc),
+			Override(new(storage.SetFundsPolicyFunc), modules.NewSetFundsPolicyFunc),
+			Override(new(storage.GetFundsPolicyFunc), modules.NewGetFundsPolicyFunc),
+			Override(RunFundsManagerKey, modules.RunFundsManager(config.DefaultStorageMiner().Fees)),
+			Override(RegisterDealSubsystemsKey, modules.RegisterDealSubsystems),
+			Override(RunFeeBumperKey, modules.RunFeeBumper(config.DefaultStorageMiner().Fees)),
 		),
 	)
 }
@@ -430,6 +494,37 @@ func StorageMiner(out *api.StorageMiner) Option {
 	)
 }
 
+// StorageMarkets runs only the markets subsystem of a miner: deal intake, data
+// transfer and retrieval. Deal data is added to sectors, and pieces are
+// unsealed, by the sealing node configured in MarketsNode.SealerApiInfo. It
+// must be set after the Repo option.
+func StorageMarkets() Option {
+	return Options(
+		Override(new(*modules.SealingNode), modules.ConnectSealingNode),
+		Override(new(sectorblocks.SectorBuilder), modules.RemoteSectorBuilder),
+		Override(new(retrievalmarket.RetrievalProviderNode), modules.RemoteRetrievalProviderNode),
+		Override(RegisterDealSubsystemsKey, modules.RegisterMarketsDealSubsystems),
+
+		// sealing, proving and mining run on the sealing node
+		Unset(new(*stores.Index)),
+		Unset(new(stores.SectorIndex)),
+		Unset(StorageHealthAlertsKey),
+		Unset(new(*sectorstorage.Manager)),
+		Unset(new(sectorstorage.SectorManager)),
+		Unset(SealingTaskMetricsKey),
+		Unset(new(storage2.Prover)),
+		Unset(new(storiface.WorkerReturn)),
+		Unset(new(*storage.Miner)),
+		Unset(new(*workerauth.Authority)),
+		Unset(new(*impl.PartitionsCache)),
+		Unset(new(gen.WinningPoStProver)),
+		Unset(new(*miner.Miner)),
+		Unset(GetParamsKey),
+		Unset(RunFundsManagerKey),
+		Unset(RunFeeBumperKey),
+	)
+}
+
 // Config sets up constructors based on the provided Config
 func ConfigCommon(cfg *config.Common) Option {
 	return Options(
@@ -484,11 +579,21 @@ func ConfigFullNode(c interface{}) Option {
 			),
 		),
 		Override(new(dtypes.Graphsync), modules.Graphsync(cfg.Client.SimultaneousTransfers)),
+		Override(new(dtypes.ClientRetrievalPaymentConfig), dtypes.ClientRetrievalPaymentConfig{
+			MaxPaymentInterval:         cfg.Client.RetrievalPaymentInterval,
+			MaxPaymentIntervalIncrease: cfg.Client.RetrievalPaymentIntervalIncrease,
+		}),
 
 		If(cfg.Metrics.HeadNotifs,
 			Override(HeadMetricsKey, metrics.SendHeadNotifs(cfg.Metrics.Nickname)),
 		),
 
+		If(cfg.Chainstore.EnableSplitstore,
+			Override(new(*splitstore.SplitStore), modules.SplitStore(&cfg.Chainstore)),
+			Override(new(dtypes.ChainRawBlockstore), modules.SplitChainRawBlockstore),
+			Override(StartSplitstoreKey, modules.StartSplitstore),
+		),
+
 		If(cfg.Wallet.RemoteBackend != "",
 			Override(new(*remotewallet.RemoteWallet), remotewallet.SetupRemoteWallet(cfg.Wallet.RemoteBackend)),
 		),
@@ -499,6 +604,15 @@ func ConfigFullNode(c interface{}) Option {
 			Unset(new(*wallet.LocalWallet)),
 			Override(new(wallet.Default), wallet.NilDefault),
 		),
+
+		If(cfg.Proofs.Type == config.ProofsMock,
+			Override(new(ffiwrapper.Verifier), mock.MockVerifier),
+			Override(CheckMockProofsKey, modules.CheckMockProofs),
+		),
+
+		If(len(cfg.Mpool.PriorityAddrs) > 0 || cfg.Mpool.PriorityLocal,
+			Override(SetMpoolPriorityKey, modules.SetMpoolPriority(cfg.Mpool)),
+		),
 	)
 }
 
@@ -519,11 +633,40 @@ func ConfigStorageMiner(c interface{}) Option {
 			Override(new(dtypes.RetrievalDealFilter), modules.RetrievalDealFilter(dealfilter.CliRetrievalDealFilter(cfg.Dealmaking.RetrievalFilter))),
 		),
 
-		Override(new(storagemarket.StorageProviderNode), storageadapter.NewProviderNodeAdapter(&cfg.Fees)),
+		Override(new(storagemarket.StorageProviderNode), storageadapter.NewProviderNodeAdapter(&cfg.Fees, &cfg.Dealmaking)),
+		Override(new(*clientquota.Quotas), modules.ClientQuotas(cfg.Dealmaking)),
+		Override(new(*askrefresh.Refresher), modules.StorageAskRefresher(cfg.Dealmaking, cfg.Fees)),
+		Override(new(*slareport.Reporter), modules.SLAReporter(cfg.SLAReports)),
+
+		If(cfg.Dealmaking.RetrievalPaymentInterval != 0 || cfg.Dealmaking.RetrievalPaymentIntervalIncrease != 0,
+			Override(SetRetrievalPaymentIntervalKey, modules.SetRetrievalPaymentInterval(cfg.Dealmaking)),
+		),
+
+		If(cfg.Dealmaking.IndexerEndpoint != "",
+			Override(HandleContentAdvertisementsKey, modules.HandleContentAdvertisements(cfg.Dealmaking.IndexerEndpoint)),
+		),
+		Override(new(*dtypes.HTTPRetrievalAlg), modules.HTTPRetrievalSecret),
+		Override(HandleRetrievalTransportsKey, modules.HandleRetrievalTransports(cfg.Dealmaking)),
+		Override(new(dtypes.HTTPRetrievalURL), func() dtypes.HTTPRetrievalURL {
+			if cfg.Dealmaking.HTTPRetrievalListen == "" {
+				return ""
+			}
+			return dtypes.HTTPRetrievalURL(cfg.Dealmaking.HTTPRetrievalURL)
+		}),
+		Override(new(*transferlimit.Limiter), modules.TransferLimiter(cfg.Dealmaking)),
 
 		Override(new(sectorstorage.SealerConfig), cfg.Storage),
 		Override(new(*storage.AddressSelector), modules.AddressSelector(&cfg.Addresses)),
 		Override(new(*storage.Miner), modules.StorageMiner(cfg.Fees)),
+		Override(RunFundsManagerKey, modules.RunFundsManager(cfg.Fees)),
+		Override(RunFeeBumperKey, modules.RunFeeBumper(cfg.Fees)),
+
+		If(cfg.Proofs.Type == config.ProofsMock,
+			Override(new(sectorstorage.SectorManager), modules.MockSectorMgr),
+			Override(new(ffiwrapper.Verifier), mock.MockVerifier),
+			Unset(new(*sectorstorage.Manager)),
+			Override(CheckMockProofsKey, modules.CheckMinerMockProofs),
+		),
 	)
 }
 
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/chain/types"
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
@@ -15,6 +16,7 @@ type Common struct {
 	API    API
 	Libp2p Libp2p
 	Pubsub Pubsub
+	Proofs Proofs
 }
 
 // FullNode is a full node config
@@ -24,6 +26,9 @@ type FullNode struct {
 	Metrics Metrics
 	Wallet  Wallet
 	Fees    FeeConfig
+	Mpool   MpoolConfig
+
+	Chainstore Chainstore
 }
 
 // // Common
@@ -37,6 +42,10 @@ type StorageMiner struct {
 	Storage    sectorstorage.SealerConfig
 	Fees       MinerFeeConfig
 	Addresses  MinerAddressConfig
+	Funds      MinerFundsConfig
+	SLAReports MinerSLAReportConfig
+
+	MarketsNode MarketsNodeConfig
 }
 
 type DealmakingConfig struct {
@@ -49,8 +58,83 @@ type DealmakingConfig struct {
 	PieceCidBlocklist              []cid.Cid
 	ExpectedSealDuration           Duration
 
+	// Filters deals are passed through before they're accepted. A command is
+	// run with the deal as JSON on stdin, and the deal is accepted if it exits
+	// with 0; its output is the rejection reason. An http(s) URL is POSTed the
+	// deal, and the deal is accepted on a 2xx response; the body of a 4xx
+	// response is the rejection reason, other responses fail the deal.
+	// Retrieval deals also carry the PieceSize of the requested data, and the
+	// OngoingRetrievals and OngoingTransfers of the node
 	Filter          string
 	RetrievalFilter string
+
+	// Directory piece data is written to before it's added to a sector, so
+	// that space is reserved for it in a sector before the data is written.
+	// Deals are handed off once the data is written into the sector. Relative
+	// paths are relative to the miner repo. Empty = pieces are added straight
+	// from the deal data
+	PieceStagingPath string
+	// Format staged pieces are written in: "raw" writes the piece data as is,
+	// "car" only writes the CAR file of the deal payload, without the zero
+	// padding of the piece. Empty = "raw"
+	PieceStagingFormat string
+
+	// Whether to keep an unsealed copy of the deal data after sealing, for
+	// fast retrieval: "client" keeps it when the client asked for fast
+	// retrieval, "always" and "never" regardless of what the client asked.
+	// The Filter can override it for each deal
+	KeepUnsealed string
+
+	// Maximum number of bytes sent to a retrieval client before a payment is
+	// requested, and how much that interval grows after each payment.
+	// 0 = leave the current retrieval ask unchanged
+	RetrievalPaymentInterval         uint64
+	RetrievalPaymentIntervalIncrease uint64
+
+	// Address the HTTP retrieval transport listens on, and the public URL
+	// offered to clients in retrieval query responses. Pieces are served over
+	// HTTP to clients holding a token of the transport (lotus-miner
+	// retrieval-deals http-token), and paying for the whole piece at the
+	// retrieval ask upfront. Requests go through the retrieval deal filter.
+	// Empty = retrievals are only served over graphsync
+	HTTPRetrievalListen string
+	HTTPRetrievalURL    string
+
+	// Bandwidth limits of data transfers, in bytes per second, for each
+	// transfer and for all transfers together. They can be changed at runtime
+	// with lotus-miner data-transfers limit. 0 = no limit
+	TransferRateLimit      uint64
+	TotalTransferRateLimit uint64
+
+	// Limits applied to each client address, as it appears in the deal
+	// proposal. ClientQuotas overrides DefaultClientQuota for the listed
+	// addresses
+	DefaultClientQuota ClientQuota
+	ClientQuotas       map[string]ClientQuota
+
+	// HTTP endpoint of a content indexer. When set, the payload CIDs of deals
+	// are advertised to the indexer as deal data lands in sectors, and
+	// retracted when deals expire, so that retrieval clients can find which
+	// provider stores which content. Empty = don't publish advertisements
+	IndexerEndpoint string
+
+	// How often the storage ask is checked. Once half of its duration has
+	// passed, the ask is signed again with the same terms and a new expiry,
+	// so that it doesn't expire. 0 = the ask isn't refreshed
+	AskRefreshInterval Duration
+	// Send a message to the miner actor with the hash of each new signed
+	// storage ask, so that aggregators can discover ask changes from the chain
+	PublishAskCommitment bool
+}
+
+// ClientQuota limits the deals accepted from a single client; 0 = no limit
+type ClientQuota struct {
+	// Total piece size of deals which haven't failed, been slashed or expired
+	MaxActiveDealBytes uint64
+	MaxActiveDeals     uint64
+
+	// Pieces waiting to be added to a sector
+	MaxPendingPieces uint64
 }
 
 type SealingConfig struct {
@@ -64,6 +148,87 @@ type SealingConfig struct {
 	MaxSealingSectorsForDeals uint64
 
 	WaitDealsDelay Duration
+
+	// Rules overriding WaitDealsDelay; the first matching rule applies. The
+	// delay is counted from the creation of the sector, e.g. to pack sectors
+	// whose first deal fills most of them sooner:
+	//   [[Sealing.WaitDealsDelays]]
+	//     FirstPieceMinFill = 0.75
+	//     Delay = "30m0s"
+	WaitDealsDelays []WaitDealsDelayRule
+
+	// Labels of deal pieces which constrain packing. A piece with any of the
+	// ExclusiveLabels only shares a sector with pieces with the same
+	// exclusive labels, e.g. to keep "encrypted" or "region:eu" data apart
+	// from other data. A piece with any of the DedicatedLabels gets a sector
+	// of its own, which starts sealing right away. Deals are labeled
+	// "fast-retrieval" and "verified" when they are such deals, and with the
+	// Labels in the overrides returned by the deal filter accepting them
+	ExclusiveLabels []string
+	DedicatedLabels []string
+
+	// Maximum number of pieces waiting to be added to a sector; further
+	// pieces are rejected. 0 = no limit
+	MaxPendingPieces uint64
+
+	// How long a piece can wait to be assigned to a sector before the
+	// AddPiece call fails. 0 = no limit
+	PendingPieceTimeout Duration
+
+	// How long space reserved in a sector with SectorReservePiece is held
+	// before the piece data is committed. Expired leases are released, so
+	// that a crashed caller doesn't block the sector
+	PieceLeaseTimeout Duration
+
+	// Remove sectors which expired on chain (after finality), deleting their
+	// sealed and cache files
+	RemoveExpiredSectors bool
+
+	// Number of storage failure domains sealed and cache files of proving
+	// sectors are kept in, so that losing a single disk or host doesn't fault
+	// them; missing replicas are copied into local storage paths in other
+	// failure domains. 0 or 1 disables replication
+	SectorReplicas uint64
+
+	// Periodically drop unsealed copies of proving sectors none of whose deals
+	// keep their data unsealed, and unseal the pieces of deals which do but
+	// whose unsealed copy is missing
+	ManageUnsealedCopies bool
+
+	// Run FinalizeSector (clearing the sealing cache) as soon as the commit
+	// proof is computed, before the commit message is sent. This frees sealing
+	// scratch space much earlier, at the cost of having to re-seal the sector
+	// if the precommit is reorged out before the commit lands
+	FinalizeEarly bool
+
+	// How sectors in failure states are retried. StateRetryPolicies overrides
+	// RetryPolicy for specific states, e.g. [Sealing.StateRetryPolicies.CommitFailed]
+	RetryPolicy        RetryPolicy
+	StateRetryPolicies map[string]RetryPolicy
+}
+
+type WaitDealsDelayRule struct {
+	// Size of sectors the rule applies to, e.g. "32GiB". Empty = all sizes
+	SectorSize string
+	// Share of the sector the first deal piece has to fill for the rule to
+	// apply. 0 = the rule also applies before a deal is added
+	FirstPieceMinFill float64
+	Delay             Duration
+}
+
+type RetryPolicy struct {
+	// Consecutive retries after which GiveUp is done, 0 = retry forever
+	MaxRetries uint64
+
+	// Wait before the first retry, multiplied by BackoffFactor for each
+	// consecutive retry, up to MaxBackoff (0 = no limit)
+	MinBackoff    Duration
+	MaxBackoff    Duration
+	BackoffFactor float64
+
+	// What to do after MaxRetries: "wait" leaves the sector in the failure
+	// state until it's updated manually, "remove" removes the sector
+	GiveUp string
 }
 
 type MinerFeeConfig struct {
@@ -73,6 +238,49 @@ type MinerFeeConfig struct {
 	MaxWindowPoStGasFee    types.FIL
 	MaxPublishDealsFee     types.FIL
 	MaxMarketBalanceAddFee types.FIL
+	MaxAskCommitmentFee    types.FIL
+	MaxFundsManagementFee  types.FIL
+
+	// How often the mpool is checked for window PoSt, PreCommit and
+	// ProveCommit messages of the miner stuck with a fee cap below the base
+	// fee, which are then replaced with bumped fees. 0 = stuck messages
+	// aren't replaced
+	BumpStuckMessagesInterval Duration
+	// Max fee of a replacement message, as a multiple of the max fee above
+	// for its kind of message
+	BumpMaxFeeMultiplier uint64
+}
+
+// MinerFundsConfig is the policy of the funds manager, which maintains the
+// available balance of the miner actor
+type MinerFundsConfig struct {
+	// How often the balance is checked. 0 = funds aren't managed
+	CheckInterval Duration
+	// Top up the available balance to this amount when it falls below it, for
+	// collateral. 0 = no top-ups
+	TargetBalance types.FIL
+	// Address top-ups are sent from. Empty = the worker address
+	TopUpFrom string
+	// Withdraw the available balance above this amount to the owner. 0 = no
+	// withdrawals
+	WithdrawAbove types.FIL
+	// Address withdrawn funds are forwarded to from the owner. Empty = funds
+	// stay with the owner
+	Beneficiary string
+}
+
+type MinerSLAReportConfig struct {
+	// How often a signed SLA report covering the epochs since the previous
+	// report is generated. 0 = reports are only generated on request
+	Interval Duration
+}
+
+// MarketsNodeConfig configures a miner node running only the markets
+// subsystem, started with lotus-miner run --type=markets
+type MarketsNodeConfig struct {
+	// API info of the sealing node deal data is handed to, in the
+	// token:multiaddr form. The token needs admin permissions.
+	SealerApiInfo string
 }
 
 type MinerAddressConfig struct {
@@ -106,6 +314,37 @@ type Pubsub struct {
 	RemoteTracer string
 }
 
+// Proofs selects the proof implementation used by the node
+type Proofs struct {
+	// Type of proofs generated and verified. Nodes of a network must all use
+	// the same type. Mock proofs are refused on public networks
+	Type ProofsType
+}
+
+// ProofsType is a kind of proofs, set in the config as its name
+type ProofsType string
+
+const (
+	// ProofsReal are the proofs of public networks
+	ProofsReal ProofsType = "real"
+	// ProofsMock are fake proofs, cheap to generate and checked without
+	// proving anything, for private test networks
+	ProofsMock ProofsType = "mock"
+)
+
+var _ encoding.TextUnmarshaler = (*ProofsType)(nil)
+
+// UnmarshalText implements interface for TOML decoding
+func (pt *ProofsType) UnmarshalText(text []byte) error {
+	switch t := ProofsType(text); t {
+	case ProofsReal, ProofsMock:
+		*pt = t
+		return nil
+	default:
+		return xerrors.Errorf("unknown proofs type %q, expected %q or %q", string(text), ProofsReal, ProofsMock)
+	}
+}
+
 // // Full Node
 
 type Metrics struct {
@@ -119,9 +358,18 @@ type Client struct {
 	IpfsMAddr             string
 	IpfsUseForRetrieval   bool
 	SimultaneousTransfers uint64
+
+	// Upper bounds on the payment interval and interval increase proposed in
+	// retrieval deals; smaller values mean more frequent, smaller payments.
+	// 0 = use the values offered by the provider
+	RetrievalPaymentInterval         uint64
+	RetrievalPaymentIntervalIncrease uint64
 }
 
 type Wallet struct {
+	// RemoteBackend is the api info (token:multiaddr) of an external wallet,
+	// like lotus-wallet, which keys are created in and messages are signed
+	// with. Setting DisableLocal too keeps all keys off the node.
 	RemoteBackend string
 	EnableLedger  bool
 	DisableLocal  bool
@@ -131,6 +379,40 @@ type FeeConfig struct {
 	DefaultMaxFee types.FIL
 }
 
+// MpoolConfig sets message selection rules of the mpool, applied on top of
+// the mpool config set with MpoolSetConfig when the node starts
+type MpoolConfig struct {
+	// Messages from these addresses are selected for blocks produced by the
+	// node before all other messages, regardless of their gas reward, e.g.
+	// so that PoSts of a local miner aren't crowded out
+	PriorityAddrs []string
+
+	// Also prioritize messages from all addresses which published messages
+	// through this node
+	PriorityLocal bool
+}
+
+type Chainstore struct {
+	// EnableSplitstore splits the chain blockstore into a hot store, which
+	// holds the recent chain, and the existing blockstore as the cold store.
+	// Compactions periodically move old chain data out of the hot store.
+	// Once enabled, it can't be disabled again, as the recent chain is only
+	// in the hot store; the node refuses to start without it
+	EnableSplitstore bool
+	Splitstore       Splitstore
+}
+
+type Splitstore struct {
+	// Epochs of chain state, messages and receipts kept in the hot store
+	HotStoreRetention uint64
+	// Epochs between compactions of the hot store; 0 = only compact with
+	// lotus chain prune
+	CompactionInterval uint64
+	// Delete the compacted data instead of moving it to the cold store, so
+	// that the disk usage doesn't grow. Queries of old state fail then
+	DiscardColdBlocks bool
+}
+
 func defCommon() Common {
 	return Common{
 		API: API{
@@ -154,6 +436,9 @@ func defCommon() Common {
 			DirectPeers:  nil,
 			RemoteTracer: "/dns4/pubsub-tracer.filecoin.io/tcp/4001/p2p/QmTd6UvR47vUidRNZ1ZKXHrAFhqTJAD27rKL9XYghEKgKX",
 		},
+		Proofs: Proofs{
+			Type: ProofsReal,
+		},
 	}
 
 }
@@ -171,6 +456,12 @@ func DefaultFullNode() *FullNode {
 		Client: Client{
 			SimultaneousTransfers: DefaultSimultaneousTransfers,
 		},
+		Chainstore: Chainstore{
+			Splitstore: Splitstore{
+				HotStoreRetention:  2 * 900, // two finalities
+				CompactionInterval: 900,
+			},
+		},
 	}
 }
 
@@ -183,6 +474,14 @@ func DefaultStorageMiner() *StorageMiner {
 			MaxSealingSectors:         0,
 			MaxSealingSectorsForDeals: 0,
 			WaitDealsDelay:            Duration(time.Hour * 6),
+			PieceLeaseTimeout:         Duration(30 * time.Minute),
+
+			RetryPolicy: RetryPolicy{
+				MinBackoff:    Duration(time.Minute),
+				MaxBackoff:    Duration(time.Hour),
+				BackoffFactor: 2,
+				GiveUp:        "wait",
+			},
 		},
 
 		Storage: sectorstorage.SealerConfig{
@@ -191,10 +490,14 @@ func DefaultStorageMiner() *StorageMiner {
 			AllowPreCommit2: true,
 			AllowCommit:     true,
 			AllowUnseal:     true,
+			AllowRegenCache: true,
 
 			// Default to 10 - tcp should still be able to figure this out, and
 			// it's the ratio between 10gbit / 1gbit
 			ParallelFetchLimit: 10,
+
+			PoStParallelProofs:  1,
+			PoStCheckSampleRate: 0,
 		},
 
 		Dealmaking: DealmakingConfig{
@@ -207,6 +510,10 @@ func DefaultStorageMiner() *StorageMiner {
 			PieceCidBlocklist:              []cid.Cid{},
 			// TODO: It'd be nice to set this based on sector size
 			ExpectedSealDuration: Duration(time.Hour * 24),
+
+			KeepUnsealed: "client",
+
+			AskRefreshInterval: Duration(time.Hour),
 		},
 
 		Fees: MinerFeeConfig{
@@ -216,12 +523,22 @@ func DefaultStorageMiner() *StorageMiner {
 			MaxWindowPoStGasFee:    types.MustParseFIL("5"),
 			MaxPublishDealsFee:     types.MustParseFIL("0.05"),
 			MaxMarketBalanceAddFee: types.MustParseFIL("0.007"),
+			MaxAskCommitmentFee:    types.MustParseFIL("0.007"),
+			MaxFundsManagementFee:  types.MustParseFIL("0.007"),
+
+			BumpStuckMessagesInterval: Duration(time.Minute),
+			BumpMaxFeeMultiplier:      2,
 		},
 
 		Addresses: MinerAddressConfig{
 			PreCommitControl: []string{},
 			CommitControl:    []string{},
 		},
+
+		Funds: MinerFundsConfig{
+			TargetBalance: types.MustParseFIL("0"),
+			WithdrawAbove: types.MustParseFIL("0"),
+		},
 	}
 	cfg.Common.API.ListenAddress = "/ip4/127.0.0.1/tcp/2345/http"
 	cfg.Common.API.RemoteListenAddress = "127.0.0.1:2345"
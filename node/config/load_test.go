@@ -61,3 +61,20 @@ func TestParitalConfig(t *testing.T) {
 			"config from reader should contain changes")
 	}
 }
+
+func TestProofsType(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := FromReader(bytes.NewReader([]byte(`
+		[Proofs]
+		Type = "mock"
+		`)), DefaultFullNode())
+	assert.NoError(err, "error should be nil")
+	assert.Equal(ProofsMock, cfg.(*FullNode).Proofs.Type)
+
+	_, err = FromReader(bytes.NewReader([]byte(`
+		[Proofs]
+		Type = "fake"
+		`)), DefaultFullNode())
+	assert.Error(err, "unknown proofs type should be refused")
+}
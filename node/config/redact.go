@@ -0,0 +1,61 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const redacted = "<redacted>"
+
+// Redacted returns a copy of a node config with credentials removed, so that
+// it can be shared, e.g. in profile metadata. API infos keep their address,
+// and filter URLs their host.
+func Redacted(cfg interface{}) (interface{}, error) {
+	switch c := cfg.(type) {
+	case *FullNode:
+		out := *c
+		out.Wallet.RemoteBackend = redactAPIInfo(out.Wallet.RemoteBackend)
+		return &out, nil
+	case *StorageMiner:
+		out := *c
+		out.Dealmaking.Filter = redactFilter(out.Dealmaking.Filter)
+		out.Dealmaking.RetrievalFilter = redactFilter(out.Dealmaking.RetrievalFilter)
+		out.MarketsNode.SealerApiInfo = redactAPIInfo(out.MarketsNode.SealerApiInfo)
+		return &out, nil
+	case *struct{}:
+		// worker and wallet repos have no config
+		return c, nil
+	default:
+		return nil, xerrors.Errorf("unknown config type %T", cfg)
+	}
+}
+
+// redactAPIInfo replaces the token of a token:multiaddr api info
+func redactAPIInfo(info string) string {
+	sp := strings.SplitN(info, ":", 2)
+	if len(sp) != 2 {
+		return info
+	}
+	return redacted + ":" + sp[1]
+}
+
+// redactFilter removes the password of http(s) filter URLs, and the arguments
+// of filter commands
+func redactFilter(filter string) string {
+	if filter == "" {
+		return filter
+	}
+
+	if u, err := url.Parse(filter); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		u.RawQuery = ""
+		return u.Redacted()
+	}
+
+	args := strings.Fields(filter)
+	if len(args) < 2 {
+		return filter
+	}
+	return args[0] + " " + redacted
+}
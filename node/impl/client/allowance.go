@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/markets/delegation"
+)
+
+func (a *API) ClientCreateDealAllowance(ctx context.Context, client, delegate address.Address, amount types.BigInt, expiration abi.ChainEpoch) (*api.SignedDealAllowance, error) {
+	if delegate.Protocol() != address.SECP256K1 && delegate.Protocol() != address.BLS {
+		return nil, xerrors.Errorf("delegate must be a key address, got %s", delegate)
+	}
+
+	clientKey, err := a.StateAccountKey(ctx, client, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("resolving client address: %w", err)
+	}
+
+	id, err := a.DealAllowances.NextID()
+	if err != nil {
+		return nil, xerrors.Errorf("getting allowance id: %w", err)
+	}
+
+	allowance := api.DealAllowance{
+		ID:         id,
+		Client:     clientKey,
+		Delegate:   delegate,
+		Amount:     amount,
+		Expiration: expiration,
+	}
+
+	sb, err := delegation.AllowanceSigningBytes(&allowance)
+	if err != nil {
+		return nil, xerrors.Errorf("serializing allowance: %w", err)
+	}
+
+	sig, err := a.WalletSign(ctx, clientKey, sb)
+	if err != nil {
+		return nil, xerrors.Errorf("signing allowance: %w", err)
+	}
+
+	sa := api.SignedDealAllowance{
+		Allowance: allowance,
+		Signature: sig,
+	}
+
+	if err := a.DealAllowances.Put(api.DealAllowanceInfo{
+		SignedDealAllowance: sa,
+		Spent:               big.Zero(),
+	}); err != nil {
+		return nil, xerrors.Errorf("storing allowance: %w", err)
+	}
+
+	return &sa, nil
+}
+
+func (a *API) ClientListDealAllowances(ctx context.Context) ([]api.DealAllowanceInfo, error) {
+	return a.DealAllowances.List()
+}
+
+func (a *API) ClientRevokeDealAllowance(ctx context.Context, id uint64) error {
+	return a.DealAllowances.Revoke(id)
+}
+
+func (a *API) ClientStartDelegatedDeal(ctx context.Context, req *api.DelegatedDealRequest) (*cid.Cid, error) {
+	allowance := req.Allowance.Allowance
+
+	if req.Allowance.Signature == nil || req.Signature == nil {
+		return nil, xerrors.Errorf("delegated deal request must be signed")
+	}
+
+	sb, err := delegation.AllowanceSigningBytes(&allowance)
+	if err != nil {
+		return nil, xerrors.Errorf("serializing allowance: %w", err)
+	}
+
+	if err := sigs.Verify(req.Allowance.Signature, allowance.Client, sb); err != nil {
+		return nil, xerrors.Errorf("invalid allowance signature: %w", err)
+	}
+
+	rb, err := delegation.RequestSigningBytes(req)
+	if err != nil {
+		return nil, xerrors.Errorf("serializing request: %w", err)
+	}
+
+	if err := sigs.Verify(req.Signature, allowance.Delegate, rb); err != nil {
+		return nil, xerrors.Errorf("invalid delegate signature: %w", err)
+	}
+
+	head, err := a.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	if head.Height() >= allowance.Expiration {
+		return nil, xerrors.Errorf("allowance %d expired at epoch %d", allowance.ID, allowance.Expiration)
+	}
+
+	params := req.Params
+	if params.Wallet != allowance.Client {
+		walletKey, err := a.StateAccountKey(ctx, params.Wallet, types.EmptyTSK)
+		if err != nil || walletKey != allowance.Client {
+			return nil, xerrors.Errorf("deal wallet %s doesn't match allowance client %s", params.Wallet, allowance.Client)
+		}
+	}
+
+	return a.startDeal(ctx, &params, func(price abi.TokenAmount) (func(), error) {
+		if err := a.DealAllowances.Spend(req.Allowance, req.Nonce, price); err != nil {
+			return nil, err
+		}
+
+		return func() {
+			if err := a.DealAllowances.Refund(allowance.ID, price); err != nil {
+				log.Errorf("refunding deal allowance %d: %+v", allowance.ID, err)
+			}
+		}, nil
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"golang.org/x/xerrors"
 
@@ -19,6 +20,7 @@ import (
 	offline "github.com/ipfs/go-ipfs-exchange-offline"
 	files "github.com/ipfs/go-ipfs-files"
 	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipfs/go-merkledag"
 	unixfile "github.com/ipfs/go-unixfs/file"
 	"github.com/ipfs/go-unixfs/importer/balanced"
@@ -48,19 +50,30 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	lpaych "github.com/filecoin-project/lotus/chain/actors/builtin/paych"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/markets/delegation"
+	"github.com/filecoin-project/lotus/markets/providerstats"
+	"github.com/filecoin-project/lotus/markets/transports"
 	"github.com/filecoin-project/lotus/markets/utils"
 	"github.com/filecoin-project/lotus/node/impl/full"
 	"github.com/filecoin-project/lotus/node/impl/paych"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/repo/importmgr"
+	"github.com/filecoin-project/lotus/node/repo/retrievalstoremgr"
 )
 
+var log = logging.Logger("client")
+
 var DefaultHashFunction = uint64(mh.BLAKE2B_MIN + 31)
 
 const dealStartBufferHours uint64 = 49
 
+// retrievalTransportAuto retrieves over HTTP when the provider offers it and
+// the order has a token, and over graphsync otherwise
+const retrievalTransportAuto = "auto"
+
 type API struct {
 	fx.In
 
@@ -74,10 +87,13 @@ type API struct {
 	Retrieval    rm.RetrievalClient
 	Chain        *store.ChainStore
 
-	Imports dtypes.ClientImportMgr
+	Imports        dtypes.ClientImportMgr
+	DealAllowances *delegation.Store
+	ProviderStats  *providerstats.Store
 
 	CombinedBstore    dtypes.ClientBlockstore // TODO: try to remove
 	RetrievalStoreMgr dtypes.ClientRetrievalStoreManager
+	RetrievalPayment  dtypes.ClientRetrievalPaymentConfig
 	DataTransfer      dtypes.ClientDataTransfer
 	Host              host.Host
 }
@@ -95,6 +111,13 @@ func (a *API) imgr() *importmgr.Mgr {
 }
 
 func (a *API) ClientStartDeal(ctx context.Context, params *api.StartDealParams) (*cid.Cid, error) {
+	return a.startDeal(ctx, params, nil)
+}
+
+// startDeal proposes the deal described by params. When spend is set, it is
+// called with the total price of the deal before the deal is proposed; the
+// returned refund func is called if proposing the deal fails.
+func (a *API) startDeal(ctx context.Context, params *api.StartDealParams, spend func(abi.TokenAmount) (refund func(), err error)) (*cid.Cid, error) {
 	var storeID *multistore.StoreID
 	if params.Data.TransferType == storagemarket.TTGraphsync {
 		importIDs := a.imgr().List()
@@ -157,12 +180,22 @@ func (a *API) ClientStartDeal(ctx context.Context, params *api.StartDealParams)
 		dealStart = ts.Height() + abi.ChainEpoch(dealStartBufferHours*blocksPerHour) // TODO: Get this from storage ask
 	}
 
+	dealEnd := calcDealExpiration(params.MinBlocksDuration, md, dealStart)
+
+	var refund func()
+	if spend != nil {
+		refund, err = spend(big.Mul(params.EpochPrice, big.NewInt(int64(dealEnd-dealStart))))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	result, err := a.SMDealClient.ProposeStorageDeal(ctx, storagemarket.ProposeStorageDealParams{
 		Addr:          params.Wallet,
 		Info:          &providerInfo,
 		Data:          params.Data,
 		StartEpoch:    dealStart,
-		EndEpoch:      calcDealExpiration(params.MinBlocksDuration, md, dealStart),
+		EndEpoch:      dealEnd,
 		Price:         params.EpochPrice,
 		Collateral:    params.ProviderCollateral,
 		Rt:            mi.SealProofType,
@@ -172,6 +205,9 @@ func (a *API) ClientStartDeal(ctx context.Context, params *api.StartDealParams)
 	})
 
 	if err != nil {
+		if refund != nil {
+			refund()
+		}
 		return nil, xerrors.Errorf("failed to start deal: %w", err)
 	}
 
@@ -494,12 +530,15 @@ type retrievalSubscribeEvent struct {
 	state rm.ClientDealState
 }
 
-func readSubscribeEvents(ctx context.Context, dealID retrievalmarket.DealID, subscribeEvents chan retrievalSubscribeEvent, events chan marketevents.RetrievalEvent) error {
+// readSubscribeEvents forwards the events of the retrieval deal until it ends,
+// returning the number of bytes received
+func readSubscribeEvents(ctx context.Context, dealID retrievalmarket.DealID, subscribeEvents chan retrievalSubscribeEvent, events chan marketevents.RetrievalEvent) (uint64, error) {
+	var received uint64
 	for {
 		var subscribeEvent retrievalSubscribeEvent
 		select {
 		case <-ctx.Done():
-			return xerrors.New("Retrieval Timed Out")
+			return received, xerrors.New("Retrieval Timed Out")
 		case subscribeEvent = <-subscribeEvents:
 			if subscribeEvent.state.ID != dealID {
 				// we can't check the deal ID ahead of time because:
@@ -509,9 +548,11 @@ func readSubscribeEvents(ctx context.Context, dealID retrievalmarket.DealID, sub
 			}
 		}
 
+		received = subscribeEvent.state.TotalReceived
+
 		select {
 		case <-ctx.Done():
-			return xerrors.New("Retrieval Timed Out")
+			return received, xerrors.New("Retrieval Timed Out")
 		case events <- marketevents.RetrievalEvent{
 			Event:         subscribeEvent.event,
 			Status:        subscribeEvent.state.Status,
@@ -523,13 +564,13 @@ func readSubscribeEvents(ctx context.Context, dealID retrievalmarket.DealID, sub
 		state := subscribeEvent.state
 		switch state.Status {
 		case rm.DealStatusCompleted:
-			return nil
+			return received, nil
 		case rm.DealStatusRejected:
-			return xerrors.Errorf("Retrieval Proposal Rejected: %s", state.Message)
+			return received, xerrors.Errorf("Retrieval Proposal Rejected: %s: %w", state.Message, errRetrievalRejected)
 		case
 			rm.DealStatusDealNotFound,
 			rm.DealStatusErrored:
-			return xerrors.Errorf("Retrieval Error: %s", state.Message)
+			return received, xerrors.Errorf("Retrieval Error: %s", state.Message)
 		}
 	}
 }
@@ -569,13 +610,7 @@ func (a *API) clientRetrieve(ctx context.Context, order api.RetrievalOrder, ref
 		return err
 	}*/
 
-	ppb := types.BigDiv(order.Total, types.NewInt(order.Size))
-
-	params, err := rm.NewParamsV1(ppb, order.PaymentInterval, order.PaymentIntervalIncrease, shared.AllSelector(), order.Piece, order.UnsealPrice)
-	if err != nil {
-		finish(xerrors.Errorf("Error in retrieval params: %s", err))
-		return
-	}
+	order = limitPayment(order, a.RetrievalPayment)
 
 	store, err := a.RetrievalStoreMgr.NewStore()
 	if err != nil {
@@ -587,42 +622,41 @@ func (a *API) clientRetrieve(ctx context.Context, order api.RetrievalOrder, ref
 		_ = a.RetrievalStoreMgr.ReleaseStore(store)
 	}()
 
-	// Subscribe to events before retrieving to avoid losing events.
-	subscribeEvents := make(chan retrievalSubscribeEvent, 1)
-	subscribeCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	unsubscribe := a.Retrieval.SubscribeToEvents(func(event rm.ClientEvent, state rm.ClientDealState) {
-		// We'll check the deal IDs inside readSubscribeEvents.
-		if state.PayloadCID.Equals(order.Root) {
-			select {
-			case <-subscribeCtx.Done():
-			case subscribeEvents <- retrievalSubscribeEvent{event, state}:
-			}
-		}
-	})
-
-	dealID, err := a.Retrieval.Retrieve(
-		ctx,
-		order.Root,
-		params,
-		order.Total,
-		order.MinerPeer,
-		order.Client,
-		order.Miner,
-		store.StoreID())
-
+	httpURL, payTo, err := a.httpRetrievalURL(ctx, order)
 	if err != nil {
-		unsubscribe()
-		finish(xerrors.Errorf("Retrieve failed: %w", err))
+		finish(err)
 		return
 	}
 
-	err = readSubscribeEvents(ctx, dealID, subscribeEvents, events)
+	retrieved := false
+	if httpURL != "" {
+		err := a.retrieveHTTP(ctx, order, httpURL, payTo, store, events)
+		switch {
+		case err == nil:
+			retrieved = true
+		case order.Transport == transports.HTTP:
+			finish(xerrors.Errorf("HTTP retrieval failed: %w", err))
+			return
+		default:
+			log.Warnw("HTTP retrieval failed, retrieving over graphsync", "miner", order.Miner, "root", order.Root, "error", err)
+		}
+	}
 
-	unsubscribe()
-	if err != nil {
-		finish(xerrors.Errorf("Retrieve: %w", err))
-		return
+	if !retrieved {
+		err := a.retrieveGraphsync(ctx, order, store, events)
+		if xerrors.Is(err, errRetrievalRejected) {
+			// the provider may have lowered its payment intervals since the
+			// order was made; the rejection doesn't tell, so the provider's
+			// current offer is compared with the order
+			log.Warnw("retrieval rejected, renegotiating payment terms", "miner", order.Miner, "root", order.Root, "error", err)
+			if rerr := a.retrieveRenegotiated(ctx, order, store, events); !xerrors.Is(rerr, errSamePaymentTerms) {
+				err = rerr
+			}
+		}
+		if err != nil {
+			finish(err)
+			return
+		}
 	}
 
 	// If ref is nil, it only fetches the data into the configured blockstore.
@@ -662,6 +696,237 @@ func (a *API) clientRetrieve(ctx context.Context, order api.RetrievalOrder, ref
 	return
 }
 
+// httpRetrievalURL returns the URL of the HTTP transport of the provider, and
+// the address retrievals are paid to, when the order is retrieved over HTTP.
+// The URL is empty when it's retrieved over graphsync.
+func (a *API) httpRetrievalURL(ctx context.Context, order api.RetrievalOrder) (string, address.Address, error) {
+	switch order.Transport {
+	case "", transports.Graphsync:
+		return "", address.Undef, nil
+	case transports.HTTP:
+		if order.HTTPToken == "" {
+			return "", address.Undef, xerrors.New("retrieving over HTTP needs a token issued by the provider")
+		}
+	case retrievalTransportAuto:
+		if order.HTTPToken == "" {
+			return "", address.Undef, nil
+		}
+	default:
+		return "", address.Undef, xerrors.Errorf("unknown retrieval transport %q", order.Transport)
+	}
+
+	// the transports offered by the provider are sent in the response to the
+	// retrieval query
+	qp := rm.QueryParams{PieceCID: order.Piece}
+	resp, err := a.Retrieval.Query(ctx, order.MinerPeer, order.Root, qp)
+	var offer *transports.Offer
+	if err == nil {
+		offer, err = transports.ParseOffer(resp.Message)
+	}
+	if err == nil && offer.HTTPURL() == "" {
+		err = xerrors.New("provider doesn't offer HTTP retrieval")
+	}
+	if err != nil {
+		if order.Transport == transports.HTTP {
+			return "", address.Undef, xerrors.Errorf("querying retrieval transports: %w", err)
+		}
+		log.Debugw("not retrieving over HTTP", "miner", order.Miner, "error", err)
+		return "", address.Undef, nil
+	}
+
+	return offer.HTTPURL(), resp.PaymentAddress, nil
+}
+
+func (a *API) retrieveHTTP(ctx context.Context, order api.RetrievalOrder, url string, payTo address.Address, store retrievalstoremgr.RetrievalStore, events chan marketevents.RetrievalEvent) error {
+	rdag := store.DAGService()
+	put := func(nd ipld.Node) error {
+		return rdag.Add(ctx, nd)
+	}
+
+	// the provider only serves the piece once the voucher paying for it is
+	// added, so the whole order is paid upfront
+	spent := big.Zero()
+	var voucher *lpaych.SignedVoucher
+	if !order.Total.Nil() && !order.Total.IsZero() {
+		var err error
+		voucher, err = a.httpPaymentVoucher(ctx, order, payTo)
+		if err != nil {
+			return xerrors.Errorf("paying for HTTP retrieval: %w", err)
+		}
+		spent = order.Total
+	}
+
+	start := time.Now()
+	received, err := transports.FetchPayload(ctx, url, order.HTTPToken, voucher, order.Root, order.Piece, put)
+	a.recordRetrieval(order, start, received, err)
+	if err != nil {
+		return err
+	}
+
+	events <- marketevents.RetrievalEvent{
+		Event:         rm.ClientEventAllBlocksReceived,
+		Status:        rm.DealStatusCompleted,
+		BytesReceived: received,
+		FundsSpent:    spent,
+	}
+
+	return nil
+}
+
+// httpPaymentVoucher creates a voucher paying the order total to the provider
+// on a new lane of the payment channel to it, adding funds to the channel if
+// needed
+func (a *API) httpPaymentVoucher(ctx context.Context, order api.RetrievalOrder, payTo address.Address) (*lpaych.SignedVoucher, error) {
+	ci, err := a.PaychGet(ctx, order.Client, payTo, order.Total)
+	if err != nil {
+		return nil, xerrors.Errorf("getting payment channel: %w", err)
+	}
+
+	ch := ci.Channel
+	if ci.WaitSentinel != cid.Undef {
+		ch, err = a.PaychGetWaitReady(ctx, ci.WaitSentinel)
+		if err != nil {
+			return nil, xerrors.Errorf("waiting for payment channel: %w", err)
+		}
+	}
+
+	lane, err := a.PaychAllocateLane(ctx, ch)
+	if err != nil {
+		return nil, xerrors.Errorf("allocating payment channel lane: %w", err)
+	}
+
+	res, err := a.PaychVoucherCreate(ctx, ch, order.Total, lane)
+	if err != nil {
+		return nil, xerrors.Errorf("creating voucher: %w", err)
+	}
+	if res.Voucher == nil {
+		return nil, xerrors.Errorf("payment channel %s is short of %s", ch, types.FIL(res.Shortfall))
+	}
+
+	return res.Voucher, nil
+}
+
+// errRetrievalRejected is returned when the provider rejects a retrieval deal,
+// e.g. because its payment interval, or interval increase, are larger than the
+// provider's current ask
+var errRetrievalRejected = xerrors.New("retrieval deal rejected by the provider")
+
+// errSamePaymentTerms is returned when renegotiating payment, if the provider
+// doesn't offer shorter payment intervals than the order's, so they weren't
+// the reason of a rejection
+var errSamePaymentTerms = xerrors.New("provider offers the same payment intervals")
+
+// limitPayment caps the payment interval and interval increase of the order at
+// the configured maximums. The provider accepts any interval up to the one it
+// offered, so capping only makes payments smaller and more frequent.
+func limitPayment(order api.RetrievalOrder, cfg dtypes.ClientRetrievalPaymentConfig) api.RetrievalOrder {
+	if max := cfg.MaxPaymentInterval; max != 0 && order.PaymentInterval > max {
+		order.PaymentInterval = max
+	}
+	if max := cfg.MaxPaymentIntervalIncrease; max != 0 && order.PaymentIntervalIncrease > max {
+		order.PaymentIntervalIncrease = max
+	}
+	return order
+}
+
+// renegotiatePayment returns the order with the payment intervals of a new
+// offer of the provider, as long as the price didn't go up
+func renegotiatePayment(order api.RetrievalOrder, offer api.QueryOffer, cfg dtypes.ClientRetrievalPaymentConfig) (api.RetrievalOrder, error) {
+	if offer.Err != "" {
+		return order, xerrors.Errorf("querying the provider: %s", offer.Err)
+	}
+
+	unsealPrice := order.UnsealPrice
+	if unsealPrice.Nil() {
+		unsealPrice = big.Zero()
+	}
+	if offer.MinPrice.GreaterThan(order.Total) || offer.UnsealPrice.GreaterThan(unsealPrice) {
+		return order, xerrors.Errorf("provider raised its price to %s (unseal %s)", types.FIL(offer.MinPrice), types.FIL(offer.UnsealPrice))
+	}
+
+	if offer.PaymentInterval >= order.PaymentInterval && offer.PaymentIntervalIncrease >= order.PaymentIntervalIncrease {
+		return order, errSamePaymentTerms
+	}
+
+	if offer.PaymentInterval < order.PaymentInterval {
+		order.PaymentInterval = offer.PaymentInterval
+	}
+	if offer.PaymentIntervalIncrease < order.PaymentIntervalIncrease {
+		order.PaymentIntervalIncrease = offer.PaymentIntervalIncrease
+	}
+
+	return limitPayment(order, cfg), nil
+}
+
+// retrieveRenegotiated retries a rejected retrieval with the payment terms
+// currently offered by the provider
+func (a *API) retrieveRenegotiated(ctx context.Context, order api.RetrievalOrder, store retrievalstoremgr.RetrievalStore, events chan marketevents.RetrievalEvent) error {
+	offer, err := a.ClientMinerQueryOffer(ctx, order.Miner, order.Root, order.Piece)
+	if err != nil {
+		return xerrors.Errorf("querying the provider to renegotiate payment: %w", err)
+	}
+
+	order, err = renegotiatePayment(order, offer, a.RetrievalPayment)
+	if err != nil {
+		return xerrors.Errorf("renegotiating payment: %w", err)
+	}
+
+	log.Infow("retrying retrieval with renegotiated payment intervals", "miner", order.Miner, "root", order.Root,
+		"interval", order.PaymentInterval, "increase", order.PaymentIntervalIncrease)
+
+	return a.retrieveGraphsync(ctx, order, store, events)
+}
+
+func (a *API) retrieveGraphsync(ctx context.Context, order api.RetrievalOrder, store retrievalstoremgr.RetrievalStore, events chan marketevents.RetrievalEvent) error {
+	ppb := types.BigDiv(order.Total, types.NewInt(order.Size))
+
+	params, err := rm.NewParamsV1(ppb, order.PaymentInterval, order.PaymentIntervalIncrease, shared.AllSelector(), order.Piece, order.UnsealPrice)
+	if err != nil {
+		return xerrors.Errorf("Error in retrieval params: %s", err)
+	}
+
+	// Subscribe to events before retrieving to avoid losing events.
+	subscribeEvents := make(chan retrievalSubscribeEvent, 1)
+	subscribeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	unsubscribe := a.Retrieval.SubscribeToEvents(func(event rm.ClientEvent, state rm.ClientDealState) {
+		// We'll check the deal IDs inside readSubscribeEvents.
+		if state.PayloadCID.Equals(order.Root) {
+			select {
+			case <-subscribeCtx.Done():
+			case subscribeEvents <- retrievalSubscribeEvent{event, state}:
+			}
+		}
+	})
+
+	start := time.Now()
+	dealID, err := a.Retrieval.Retrieve(
+		ctx,
+		order.Root,
+		params,
+		order.Total,
+		order.MinerPeer,
+		order.Client,
+		order.Miner,
+		store.StoreID())
+
+	if err != nil {
+		unsubscribe()
+		a.recordRetrieval(order, start, 0, err)
+		return xerrors.Errorf("Retrieve failed: %w", err)
+	}
+
+	received, err := readSubscribeEvents(ctx, dealID, subscribeEvents, events)
+	a.recordRetrieval(order, start, received, err)
+
+	unsubscribe()
+	if err != nil {
+		return xerrors.Errorf("Retrieve: %w", err)
+	}
+
+	return nil
+}
+
 func (a *API) ClientQueryAsk(ctx context.Context, p peer.ID, miner address.Address) (*storagemarket.StorageAsk, error) {
 	mi, err := a.StateMinerInfo(ctx, miner, types.EmptyTSK)
 	if err != nil {
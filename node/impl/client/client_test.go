@@ -1 +1,71 @@
 package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+func TestLimitPayment(t *testing.T) {
+	order := api.RetrievalOrder{PaymentInterval: 1 << 20, PaymentIntervalIncrease: 1 << 20}
+
+	require.Equal(t, order, limitPayment(order, dtypes.ClientRetrievalPaymentConfig{}))
+
+	limited := limitPayment(order, dtypes.ClientRetrievalPaymentConfig{MaxPaymentInterval: 1 << 10, MaxPaymentIntervalIncrease: 1 << 30})
+	require.Equal(t, uint64(1<<10), limited.PaymentInterval)
+	require.Equal(t, uint64(1<<20), limited.PaymentIntervalIncrease)
+}
+
+func TestRenegotiatePayment(t *testing.T) {
+	order := api.RetrievalOrder{
+		Total:                   big.NewInt(1000),
+		UnsealPrice:             big.NewInt(10),
+		PaymentInterval:         1 << 20,
+		PaymentIntervalIncrease: 1 << 20,
+	}
+	offer := api.QueryOffer{
+		MinPrice:                big.NewInt(1000),
+		UnsealPrice:             big.NewInt(10),
+		PaymentInterval:         1 << 19,
+		PaymentIntervalIncrease: 1 << 21,
+	}
+	cfg := dtypes.ClientRetrievalPaymentConfig{}
+
+	renegotiated, err := renegotiatePayment(order, offer, cfg)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1<<19), renegotiated.PaymentInterval)
+	require.Equal(t, uint64(1<<20), renegotiated.PaymentIntervalIncrease)
+
+	// still capped by the config
+	renegotiated, err = renegotiatePayment(order, offer, dtypes.ClientRetrievalPaymentConfig{MaxPaymentInterval: 1 << 10})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1<<10), renegotiated.PaymentInterval)
+
+	// the terms didn't change, retrying would be rejected again
+	_, err = renegotiatePayment(order, api.QueryOffer{
+		MinPrice:                big.NewInt(1000),
+		UnsealPrice:             big.NewInt(10),
+		PaymentInterval:         1 << 20,
+		PaymentIntervalIncrease: 1 << 20,
+	}, cfg)
+	require.True(t, xerrors.Is(err, errSamePaymentTerms))
+
+	raised := offer
+	raised.MinPrice = big.NewInt(2000)
+	_, err = renegotiatePayment(order, raised, cfg)
+	require.Error(t, err)
+
+	raised = offer
+	raised.UnsealPrice = big.NewInt(20)
+	_, err = renegotiatePayment(order, raised, cfg)
+	require.Error(t, err)
+
+	_, err = renegotiatePayment(order, api.QueryOffer{Err: "not found"}, cfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/markets/providerstats"
+)
+
+func (a *API) ClientProviderScores(ctx context.Context) ([]api.ProviderScore, error) {
+	deals, err := a.SMDealClient.ListLocalDeals(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("listing deals: %w", err)
+	}
+
+	retrievals, err := a.ProviderStats.Retrievals()
+	if err != nil {
+		return nil, xerrors.Errorf("listing retrievals: %w", err)
+	}
+
+	genesis, err := a.ChainGetGenesis(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting genesis: %w", err)
+	}
+
+	records := make([]providerstats.DealRecord, len(deals))
+	for i, d := range deals {
+		records[i] = providerstats.DealRecord{
+			Provider: d.Proposal.Provider,
+			State:    d.State,
+			Created:  d.CreationTime.Time(),
+		}
+
+		if d.State != storagemarket.StorageDealActive || d.DealID == 0 {
+			continue
+		}
+
+		md, err := a.StateMarketStorageDeal(ctx, d.DealID, types.EmptyTSK)
+		if err != nil {
+			log.Warnf("getting state of deal %d: %s", d.DealID, err)
+			continue
+		}
+		if md.State.SectorStartEpoch <= 0 {
+			continue
+		}
+
+		activated := genesis.MinTimestamp() + uint64(md.State.SectorStartEpoch)*build.BlockDelaySecs
+		records[i].Activated = time.Unix(int64(activated), 0)
+	}
+
+	return providerstats.Scores(records, retrievals), nil
+}
+
+func (a *API) recordRetrieval(order api.RetrievalOrder, start time.Time, received uint64, rerr error) {
+	r := api.RetrievalRecord{
+		Miner:         order.Miner,
+		Root:          order.Root,
+		Start:         start,
+		Duration:      time.Since(start),
+		BytesReceived: received,
+	}
+	if rerr != nil {
+		r.Error = rerr.Error()
+	}
+
+	if err := a.ProviderStats.RecordRetrieval(r); err != nil {
+		log.Errorf("recording retrieval from %s: %s", order.Miner, err)
+	}
+}
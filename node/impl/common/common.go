@@ -1,13 +1,18 @@
 package common
 
 import (
+	"bytes"
 	"context"
+	"runtime"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/gbrlsnchs/jwt/v3"
 	"github.com/google/uuid"
 	logging "github.com/ipfs/go-log/v2"
+	eventbus "github.com/libp2p/go-eventbus"
 	"github.com/libp2p/go-libp2p-core/host"
 	metrics "github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/network"
@@ -24,12 +29,20 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/bus"
+	"github.com/filecoin-project/lotus/lib/bandwidth"
+	"github.com/filecoin-project/lotus/lib/profile"
+	"github.com/filecoin-project/lotus/lib/subsystems"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/lp2p"
+	"github.com/filecoin-project/lotus/node/repo"
 )
 
 var session = uuid.New()
 
+var log = logging.Logger("common")
+
 type CommonAPI struct {
 	fx.In
 
@@ -39,8 +52,12 @@ type CommonAPI struct {
 	Router       lp2p.BaseIpfsRouting
 	ConnGater    *conngater.BasicConnectionGater
 	Reporter     metrics.Reporter
+	BwRecorder   *bandwidth.Recorder
 	Sk           *dtypes.ScoreKeeper
 	ShutdownChan dtypes.ShutdownChan
+	Bus          bus.Bus
+	Repo         repo.LockedRepo
+	Subsystems   *subsystems.Registry
 }
 
 type jwtPayload struct {
@@ -175,6 +192,10 @@ func (a *CommonAPI) NetBandwidthStatsByProtocol(ctx context.Context) (map[protoc
 	return a.Reporter.GetBandwidthByProtocol(), nil
 }
 
+func (a *CommonAPI) NetBandwidthHistory(ctx context.Context, since time.Time) ([]api.NetBandwidthSample, error) {
+	return a.BwRecorder.History(since)
+}
+
 func (a *CommonAPI) ID(context.Context) (peer.ID, error) {
 	return a.Host.ID(), nil
 }
@@ -206,6 +227,50 @@ func (a *CommonAPI) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+func (a *CommonAPI) ProfileCapture(ctx context.Context, name string, duration time.Duration) ([]byte, error) {
+	return profile.Capture(ctx, name, duration)
+}
+
+func (a *CommonAPI) ProfileInfo(ctx context.Context) (api.ProfileInfo, error) {
+	cfg, err := a.Repo.Config()
+	if err != nil {
+		return api.ProfileInfo{}, xerrors.Errorf("getting config: %w", err)
+	}
+
+	// profiles are shared for debugging, so credentials are left out
+	cfg, err = config.Redacted(cfg)
+	if err != nil {
+		return api.ProfileInfo{}, xerrors.Errorf("redacting config: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return api.ProfileInfo{}, xerrors.Errorf("encoding config: %w", err)
+	}
+
+	return api.ProfileInfo{
+		Version:   build.UserVersion(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		NumCPU:    runtime.NumCPU(),
+
+		Config: buf.String(),
+	}, nil
+}
+
+func (a *CommonAPI) SubsystemList(ctx context.Context) ([]api.SubsystemStatus, error) {
+	return a.Subsystems.Status(), nil
+}
+
+func (a *CommonAPI) SubsystemStart(ctx context.Context, name string) error {
+	return a.Subsystems.Start(ctx, name)
+}
+
+func (a *CommonAPI) SubsystemStop(ctx context.Context, name string) error {
+	return a.Subsystems.Stop(ctx, name)
+}
+
 func (a *CommonAPI) Session(ctx context.Context) (uuid.UUID, error) {
 	return session, nil
 }
@@ -214,4 +279,45 @@ func (a *CommonAPI) Closing(ctx context.Context) (<-chan struct{}, error) {
 	return make(chan struct{}), nil // relies on jsonrpc closing
 }
 
+func (a *CommonAPI) BusTopics(ctx context.Context) ([]string, error) {
+	return bus.Topics(), nil
+}
+
+func (a *CommonAPI) BusSubscribe(ctx context.Context, topics []string) (<-chan api.BusEvent, error) {
+	sub, err := bus.Subscribe(a.Bus, topics, eventbus.BufSize(256))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan api.BusEvent, 256)
+
+	go func() {
+		defer close(out)
+		defer sub.Close() //nolint:errcheck
+
+		for {
+			select {
+			case evt, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+
+				topic, _ := bus.TopicOf(evt)
+
+				// don't block the subsystems emitting events on a slow
+				// subscriber
+				select {
+				case out <- api.BusEvent{Topic: topic, Time: time.Now(), Event: evt}:
+				default:
+					log.Warnw("bus subscriber not keeping up, dropping event", "topic", topic)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
 var _ api.Common = &CommonAPI{}
@@ -4,8 +4,11 @@ import (
 	"context"
 
 	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/epochtasks"
+	"github.com/filecoin-project/lotus/chain/exchange"
 	"github.com/filecoin-project/lotus/node/impl/client"
 	"github.com/filecoin-project/lotus/node/impl/common"
 	"github.com/filecoin-project/lotus/node/impl/full"
@@ -30,11 +33,39 @@ type FullNodeAPI struct {
 	full.SyncAPI
 	full.BeaconAPI
 
-	DS dtypes.MetadataDS
+	DS         dtypes.MetadataDS
+	EpochTasks *epochtasks.Scheduler `optional:"true"`
+	Bootstrap  dtypes.BootstrapPeers
+	Exchange   exchange.Client
 }
 
+// errNoEpochTasks is returned by the epoch task calls of lite nodes, which
+// don't run the scheduler
+var errNoEpochTasks = xerrors.New("epoch tasks are not run by lite nodes")
+
 func (n *FullNodeAPI) CreateBackup(ctx context.Context, fpath string) error {
 	return backup(n.DS, fpath)
 }
 
+func (n *FullNodeAPI) EpochTaskAdd(ctx context.Context, t api.EpochTask) (uint64, error) {
+	if n.EpochTasks == nil {
+		return 0, errNoEpochTasks
+	}
+	return n.EpochTasks.Add(ctx, t)
+}
+
+func (n *FullNodeAPI) EpochTaskList(context.Context) ([]api.EpochTask, error) {
+	if n.EpochTasks == nil {
+		return nil, errNoEpochTasks
+	}
+	return n.EpochTasks.List(), nil
+}
+
+func (n *FullNodeAPI) EpochTaskRemove(ctx context.Context, id uint64) error {
+	if n.EpochTasks == nil {
+		return errNoEpochTasks
+	}
+	return n.EpochTasks.Remove(id)
+}
+
 var _ api.FullNode = &FullNodeAPI{}
@@ -31,14 +31,23 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/util/adt"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain"
+	"github.com/filecoin-project/lotus/chain/netstats"
+	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
 	"github.com/filecoin-project/lotus/lib/blockstore"
+	"github.com/filecoin-project/lotus/lib/blockstore/splitstore"
+	"github.com/filecoin-project/lotus/node/repo"
 )
 
 var log = logging.Logger("fullnode")
 
+// streamChunkSize is the number of items sent in each chunk of streamed API
+// responses
+const streamChunkSize = 1000
+
 type ChainModuleAPI interface {
 	ChainNotify(context.Context) (<-chan []*api.HeadChange, error)
 	ChainGetBlockMessages(context.Context, cid.Cid) (*api.BlockMessages, error)
@@ -67,7 +76,11 @@ type ChainAPI struct {
 	WalletAPI
 	ChainModuleAPI
 
-	Chain *store.ChainStore
+	Chain        *store.ChainStore
+	StateManager *stmgr.StateManager
+	Syncer       *chain.Syncer
+	NetStats     *netstats.Recorder     `optional:"true"`
+	SplitStore   *splitstore.SplitStore `optional:"true"`
 }
 
 func (m *ChainModule) ChainNotify(ctx context.Context) (<-chan []*api.HeadChange, error) {
@@ -169,6 +182,39 @@ func (a *ChainAPI) ChainGetParentMessages(ctx context.Context, bcid cid.Cid) ([]
 	return out, nil
 }
 
+func (a *ChainAPI) ChainGetParentMessagesStream(ctx context.Context, bcid cid.Cid, cursor uint64) (<-chan api.ParentMessagesChunk, error) {
+	msgs, err := a.ChainGetParentMessages(ctx, bcid)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor > uint64(len(msgs)) {
+		return nil, xerrors.Errorf("cursor %d is past the %d parent messages", cursor, len(msgs))
+	}
+
+	out := make(chan api.ParentMessagesChunk)
+	go func() {
+		defer close(out)
+
+		for cursor < uint64(len(msgs)) {
+			end := cursor + streamChunkSize
+			if end > uint64(len(msgs)) {
+				end = uint64(len(msgs))
+			}
+
+			select {
+			case out <- api.ParentMessagesChunk{Messages: msgs[cursor:end], Cursor: end}:
+			case <-ctx.Done():
+				return
+			}
+
+			cursor = end
+		}
+	}()
+
+	return out, nil
+}
+
 func (a *ChainAPI) ChainGetParentReceipts(ctx context.Context, bcid cid.Cid) ([]*types.MessageReceipt, error) {
 	b, err := a.Chain.GetBlock(bcid)
 	if err != nil {
@@ -203,6 +249,194 @@ func (a *ChainAPI) ChainGetParentReceipts(ctx context.Context, bcid cid.Cid) ([]
 	return out, nil
 }
 
+func (a *ChainAPI) ChainGetTipSetAtHeight(ctx context.Context, h abi.ChainEpoch, anchor api.HeightAnchor, tsk types.TipSetKey) (*api.TipSetAtHeight, error) {
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	// the tipset at the epoch, or the first one after it
+	next, err := a.Chain.GetTipsetByHeight(ctx, h, ts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if next.Height() == h {
+		return &api.TipSetAtHeight{TipSet: next}, nil
+	}
+
+	switch anchor {
+	case api.HeightAnchorPrev:
+		prev, err := a.Chain.LoadTipSet(next.Parents())
+		if err != nil {
+			return nil, xerrors.Errorf("loading parent tipset: %w", err)
+		}
+		return &api.TipSetAtHeight{TipSet: prev, NullRound: true}, nil
+	case api.HeightAnchorNext:
+		return &api.TipSetAtHeight{TipSet: next, NullRound: true}, nil
+	case api.HeightAnchorExact:
+		return nil, xerrors.Errorf("epoch %d is a null round", h)
+	default:
+		return nil, xerrors.Errorf("unknown height anchor %q", anchor)
+	}
+}
+
+func (a *ChainAPI) ChainNotifyMessages(ctx context.Context, filter api.MessageFilter) (<-chan []api.MessageEvent, error) {
+	hcs := a.Chain.SubHeadChanges(ctx)
+
+	out := make(chan []api.MessageEvent, 16)
+	go func() {
+		defer close(out)
+
+		for changes := range hcs {
+			var evts []api.MessageEvent
+			for _, hc := range changes {
+				if hc.Type == store.HCCurrent {
+					continue
+				}
+
+				matched, err := a.executedMessages(ctx, hc.Type, hc.Val, filter)
+				if err != nil {
+					log.Errorf("loading messages executed in the parent of %s: %+v", hc.Val.Key(), err)
+					continue
+				}
+				evts = append(evts, matched...)
+			}
+
+			if len(evts) == 0 {
+				continue
+			}
+
+			select {
+			case out <- evts:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// executedMessages returns the messages executed in the parent of ts which
+// match the filter, with their receipts
+func (a *ChainAPI) executedMessages(ctx context.Context, typ string, ts *types.TipSet, filter api.MessageFilter) ([]api.MessageEvent, error) {
+	if ts.Height() == 0 {
+		return nil, nil
+	}
+
+	pts, err := a.Chain.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, xerrors.Errorf("loading parent tipset: %w", err)
+	}
+
+	msgs, err := a.Chain.MessagesForTipset(pts)
+	if err != nil {
+		return nil, xerrors.Errorf("loading messages: %w", err)
+	}
+
+	rcpts, err := a.Chain.ReadReceipts(ctx, ts.Blocks()[0].ParentMessageReceipts)
+	if err != nil {
+		return nil, xerrors.Errorf("loading receipts: %w", err)
+	}
+
+	if len(rcpts) != len(msgs) {
+		return nil, xerrors.Errorf("got %d receipts for %d messages", len(rcpts), len(msgs))
+	}
+
+	// recipients may be given as ID or robust addresses, so both sides are
+	// compared as ID addresses in the state the messages were executed in
+	ids := map[address.Address]address.Address{}
+	lookupID := func(addr address.Address) address.Address {
+		if addr.Protocol() == address.ID {
+			return addr
+		}
+		if id, ok := ids[addr]; ok {
+			return id
+		}
+
+		id, err := a.StateManager.LookupID(ctx, addr, ts)
+		if err != nil {
+			// actors which don't exist only match their own address
+			id = addr
+		}
+		ids[addr] = id
+		return id
+	}
+
+	if filter.To != address.Undef {
+		filter.To = lookupID(filter.To)
+	}
+
+	var out []api.MessageEvent
+	for i, m := range msgs {
+		msg := m.VMMessage()
+
+		to := msg.To
+		if filter.To != address.Undef {
+			to = lookupID(to)
+		}
+		if !matchMessage(filter, to, msg, rcpts[i]) {
+			continue
+		}
+
+		out = append(out, api.MessageEvent{
+			Type:    typ,
+			TipSet:  ts.Key(),
+			Height:  ts.Height(),
+			Cid:     m.Cid(),
+			Message: msg,
+			Receipt: rcpts[i],
+		})
+	}
+
+	return out, nil
+}
+
+// matchMessage returns whether the message, sent to the to ID address, matches
+// the filter, whose recipient is resolved to an ID address
+func matchMessage(filter api.MessageFilter, to address.Address, msg *types.Message, rcpt types.MessageReceipt) bool {
+	if filter.To != address.Undef && filter.To != to {
+		return false
+	}
+
+	if len(filter.Methods) > 0 {
+		found := false
+		for _, m := range filter.Methods {
+			if m == msg.Method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(filter.ExitCodes) > 0 {
+		found := false
+		for _, c := range filter.ExitCodes {
+			if c == rcpt.ExitCode {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *ChainAPI) ChainGetNetworkStats(ctx context.Context, from, to abi.ChainEpoch) (*api.NetworkStats, error) {
+	if a.NetStats == nil {
+		return nil, xerrors.Errorf("network stats are not recorded by this node")
+	}
+
+	return a.NetStats.Stats(ctx, from, to)
+}
+
 func (m *ChainModule) ChainGetTipSetByHeight(ctx context.Context, h abi.ChainEpoch, tsk types.TipSetKey) (*types.TipSet, error) {
 	ts, err := m.Chain.GetTipSetFromKey(tsk)
 	if err != nil {
@@ -282,6 +516,14 @@ func (a *ChainAPI) ChainSetHead(ctx context.Context, tsk types.TipSetKey) error
 		return xerrors.Errorf("loading tipset %s: %w", tsk, err)
 	}
 
+	keeps, err := a.Syncer.KeepsCheckpoint(newHeadTs)
+	if err != nil {
+		return xerrors.Errorf("checking the new head against the checkpoint: %w", err)
+	}
+	if !keeps {
+		return xerrors.Errorf("refusing to set the head to %s: %w", tsk, chain.ErrForkCheckpoint)
+	}
+
 	currentTs, err := a.ChainHead(ctx)
 	if err != nil {
 		return xerrors.Errorf("getting head: %w", err)
@@ -592,3 +834,31 @@ func (a *ChainAPI) ChainExport(ctx context.Context, nroots abi.ChainEpoch, skipo
 
 	return out, nil
 }
+
+func (a *ChainAPI) ChainPrune(ctx context.Context) error {
+	if a.SplitStore == nil {
+		return xerrors.New("the splitstore is not enabled, set Chainstore.EnableSplitstore in the config")
+	}
+
+	_, err := a.SplitStore.Compact(ctx)
+	return err
+}
+
+func (a *ChainAPI) ChainGCBlockstore(ctx context.Context) ([]api.BlockstoreGCStats, error) {
+	// blocks are only ever deleted from the hot store, by compactions of the
+	// splitstore, which also pauses writes while the gc requires it
+	if a.SplitStore == nil {
+		return nil, xerrors.New("blockstore gc requires the splitstore, set Chainstore.EnableSplitstore in the config")
+	}
+
+	before, after, err := a.SplitStore.CollectGarbage(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("collecting garbage of the %s blockstore: %w", repo.BlockstoreHot, err)
+	}
+
+	return []api.BlockstoreGCStats{{
+		Store:      string(repo.BlockstoreHot),
+		SizeBefore: before,
+		SizeAfter:  after,
+	}}, nil
+}
@@ -0,0 +1,47 @@
+package full
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	tutils "github.com/filecoin-project/specs-actors/v2/support/testing"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestMatchMessage(t *testing.T) {
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	other, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	msg := &types.Message{To: maddr, Method: miner.Methods.SubmitWindowedPoSt}
+	ok := types.MessageReceipt{ExitCode: exitcode.Ok}
+	failed := types.MessageReceipt{ExitCode: exitcode.ErrIllegalArgument}
+
+	require.True(t, matchMessage(api.MessageFilter{}, msg.To, msg, ok))
+
+	require.True(t, matchMessage(api.MessageFilter{To: maddr}, msg.To, msg, ok))
+	require.False(t, matchMessage(api.MessageFilter{To: other}, msg.To, msg, ok))
+
+	// messages sent to the robust address match by the resolved recipient
+	robust := &types.Message{To: tutils.NewActorAddr(t, "miner"), Method: miner.Methods.SubmitWindowedPoSt}
+	require.True(t, matchMessage(api.MessageFilter{To: maddr}, maddr, robust, ok))
+
+	posts := api.MessageFilter{
+		To:      maddr,
+		Methods: []abi.MethodNum{miner.Methods.DeclareFaults, miner.Methods.SubmitWindowedPoSt},
+	}
+	require.True(t, matchMessage(posts, msg.To, msg, ok))
+	require.False(t, matchMessage(api.MessageFilter{Methods: []abi.MethodNum{miner.Methods.DeclareFaults}}, msg.To, msg, ok))
+
+	posts.ExitCodes = []exitcode.ExitCode{exitcode.Ok}
+	require.True(t, matchMessage(posts, msg.To, msg, ok))
+	require.False(t, matchMessage(posts, msg.To, msg, failed))
+}
@@ -100,6 +100,63 @@ func (a *StateAPI) StateMinerSectors(ctx context.Context, addr address.Address,
 	return stmgr.GetMinerSectorSet(ctx, a.StateManager, ts, addr, sectorNos)
 }
 
+func (a *StateAPI) StateMinerSectorsStream(ctx context.Context, addr address.Address, sectorNos *bitfield.BitField, cursor abi.SectorNumber, tsk types.TipSetKey) (<-chan api.MinerSectorsChunk, error) {
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	act, err := a.StateManager.LoadActor(ctx, addr, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load miner actor: %w", err)
+	}
+
+	mas, err := miner.Load(a.StateManager.ChainStore().Store(ctx), act)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load miner actor state: %w", err)
+	}
+
+	out := make(chan api.MinerSectorsChunk)
+	go func() {
+		defer close(out)
+
+		chunk := api.MinerSectorsChunk{Cursor: cursor}
+		send := func() error {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			chunk = api.MinerSectorsChunk{Cursor: chunk.Cursor}
+			return nil
+		}
+
+		err := miner.ForEachSector(mas, sectorNos, cursor, func(si *miner.SectorOnChainInfo) error {
+			chunk.Sectors = append(chunk.Sectors, si)
+			chunk.Cursor = si.SectorNumber + 1
+
+			if len(chunk.Sectors) < streamChunkSize {
+				return nil
+			}
+			return send()
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warnw("streaming miner sectors", "miner", addr, "cursor", chunk.Cursor, "error", err)
+			chunk.Err = err.Error()
+		}
+
+		if len(chunk.Sectors) > 0 || chunk.Err != "" {
+			_ = send()
+		}
+	}()
+
+	return out, nil
+}
+
 func (a *StateAPI) StateMinerActiveSectors(ctx context.Context, maddr address.Address, tsk types.TipSetKey) ([]*miner.SectorOnChainInfo, error) { // TODO: only used in cli
 	ts, err := a.Chain.GetTipSetFromKey(tsk)
 	if err != nil {
@@ -265,6 +322,127 @@ func (m *StateModule) StateMinerProvingDeadline(ctx context.Context, addr addres
 	return di.NextNotElapsed(), nil
 }
 
+func (a *StateAPI) StateMinerDeadlineNotify(ctx context.Context, maddr address.Address, offset abi.ChainEpoch, deadlines []uint64) (<-chan *api.DeadlineEvent, error) {
+	want := map[uint64]struct{}{}
+	for _, dl := range deadlines {
+		want[dl] = struct{}{}
+	}
+
+	notifs := a.Chain.SubHeadChanges(ctx)
+	out := make(chan *api.DeadlineEvent)
+
+	go func() {
+		defer close(out)
+
+		dt := newDeadlineTracker()
+
+		for changes := range notifs {
+			var head *types.TipSet
+			for _, change := range changes {
+				if change.Type == store.HCApply || change.Type == store.HCCurrent {
+					head = change.Val
+				}
+			}
+			if head == nil {
+				continue
+			}
+
+			cur, err := a.StateMinerProvingDeadline(ctx, maddr, head.Key())
+			if err != nil {
+				log.Errorf("deadline notify: getting proving deadline of %s at %d: %+v", maddr, head.Height(), err)
+				continue
+			}
+
+			for _, dl := range dt.due(cur, head.Height()-offset) {
+				if _, ok := want[dl.Index]; len(want) > 0 && !ok {
+					continue
+				}
+
+				select {
+				case out <- &api.DeadlineEvent{
+					Epoch:    head.Height(),
+					TipSet:   head.Key(),
+					Deadline: dl,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+type deadlineKey struct {
+	index       uint64
+	periodStart abi.ChainEpoch
+}
+
+// deadlineTracker finds the deadlines to notify about as the chain advances.
+// Deadlines are identified by their index and proving period, so that null
+// rounds don't skip deadlines, and reorgs don't notify about a deadline twice.
+type deadlineTracker struct {
+	notified   map[deadlineKey]struct{}
+	lastTarget abi.ChainEpoch
+}
+
+func newDeadlineTracker() *deadlineTracker {
+	return &deadlineTracker{
+		notified:   map[deadlineKey]struct{}{},
+		lastTarget: -1,
+	}
+}
+
+// due returns the deadlines opened since the last target epoch, up to the
+// given one. The first call only returns a deadline opening exactly at the
+// target, so new subscribers aren't notified about deadlines opened in the
+// past.
+func (dt *deadlineTracker) due(cur *dline.Info, target abi.ChainEpoch) []*dline.Info {
+	from := target
+	if dt.lastTarget >= 0 && dt.lastTarget < target {
+		from = dt.lastTarget + 1
+	}
+	if target-from >= cur.WPoStProvingPeriod {
+		from = target - cur.WPoStProvingPeriod + 1
+	}
+	dt.lastTarget = target
+
+	var out []*dline.Info
+	for dl := deadlineAt(cur, from); dl.Open <= target; dl = deadlineAt(cur, dl.Open+cur.WPoStChallengeWindow) {
+		if dl.Open < from {
+			continue
+		}
+
+		key := deadlineKey{index: dl.Index, periodStart: dl.PeriodStart}
+		if _, ok := dt.notified[key]; ok {
+			continue
+		}
+		dt.notified[key] = struct{}{}
+		out = append(out, dl)
+	}
+
+	// reorgs don't go back further than a few proving periods
+	for key := range dt.notified {
+		if key.periodStart < target-2*cur.WPoStProvingPeriod {
+			delete(dt.notified, key)
+		}
+	}
+
+	return out
+}
+
+// deadlineAt returns the deadline containing the given epoch, which may be in
+// a different proving period than the current deadline
+func deadlineAt(cur *dline.Info, epoch abi.ChainEpoch) *dline.Info {
+	off := (epoch - cur.PeriodStart) % cur.WPoStProvingPeriod
+	if off < 0 {
+		off += cur.WPoStProvingPeriod
+	}
+
+	return dline.NewInfo(epoch-off, uint64(off/cur.WPoStChallengeWindow), cur.CurrentEpoch, cur.WPoStPeriodDeadlines, cur.WPoStProvingPeriod, cur.WPoStChallengeWindow, cur.WPoStChallengeLookback, cur.FaultDeclarationCutoff)
+}
+
 func (a *StateAPI) StateMinerFaults(ctx context.Context, addr address.Address, tsk types.TipSetKey) (bitfield.BitField, error) {
 	act, err := a.StateManager.LoadActorTsk(ctx, addr, tsk)
 	if err != nil {
@@ -372,6 +550,24 @@ func (a *StateAPI) StateCall(ctx context.Context, msg *types.Message, tsk types.
 	return res, err
 }
 
+func (a *StateAPI) StateCallMany(ctx context.Context, msgs []*types.Message, tsk types.TipSetKey) (res []*api.InvocResult, err error) {
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+	for {
+		res, err = a.StateManager.CallMany(ctx, msgs, ts)
+		if err != stmgr.ErrExpensiveFork {
+			break
+		}
+		ts, err = a.Chain.GetTipSetFromKey(ts.Parents())
+		if err != nil {
+			return nil, xerrors.Errorf("getting parent tipset: %w", err)
+		}
+	}
+	return res, err
+}
+
 func (a *StateAPI) StateReplay(ctx context.Context, tsk types.TipSetKey, mc cid.Cid) (*api.InvocResult, error) {
 	msgToReplay := mc
 	var ts *types.TipSet
@@ -621,6 +817,42 @@ func (m *StateModule) StateGetReceipt(ctx context.Context, msg cid.Cid, tsk type
 	return m.StateManager.GetReceipt(ctx, msg, ts)
 }
 
+func (a *StateAPI) StateGetReceipts(ctx context.Context, tsk types.TipSetKey) ([]api.MsgReceipt, error) {
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	msgs, err := a.Chain.MessagesForTipset(ts)
+	if err != nil {
+		return nil, xerrors.Errorf("loading messages: %w", err)
+	}
+
+	_, rcptRoot, err := a.StateManager.TipSetState(ctx, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("computing tipset state: %w", err)
+	}
+
+	rcpts, err := a.Chain.ReadReceipts(ctx, rcptRoot)
+	if err != nil {
+		return nil, xerrors.Errorf("loading receipts: %w", err)
+	}
+
+	if len(rcpts) != len(msgs) {
+		return nil, xerrors.Errorf("got %d receipts for %d messages", len(rcpts), len(msgs))
+	}
+
+	out := make([]api.MsgReceipt, len(msgs))
+	for i, m := range msgs {
+		out[i] = api.MsgReceipt{
+			Message: m.Cid(),
+			Receipt: rcpts[i],
+		}
+	}
+
+	return out, nil
+}
+
 func (m *StateModule) StateListMiners(ctx context.Context, tsk types.TipSetKey) ([]address.Address, error) {
 	ts, err := m.Chain.GetTipSetFromKey(tsk)
 	if err != nil {
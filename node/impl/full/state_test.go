@@ -0,0 +1,71 @@
+package full
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+)
+
+func TestDeadlineAt(t *testing.T) {
+	// 4 deadlines of 10 epochs, current proving period starts at 100
+	cur := dline.NewInfo(100, 1, 115, 4, 40, 10, 5, 3)
+
+	dl := deadlineAt(cur, 115)
+	require.Equal(t, uint64(1), dl.Index)
+	require.Equal(t, abi.ChainEpoch(110), dl.Open)
+
+	// later deadline in the next proving period
+	dl = deadlineAt(cur, 165)
+	require.Equal(t, uint64(2), dl.Index)
+	require.Equal(t, abi.ChainEpoch(160), dl.Open)
+	require.Equal(t, abi.ChainEpoch(140), dl.PeriodStart)
+
+	// before the current proving period
+	dl = deadlineAt(cur, 95)
+	require.Equal(t, uint64(3), dl.Index)
+	require.Equal(t, abi.ChainEpoch(90), dl.Open)
+
+	// deadline opening exactly at the epoch
+	dl = deadlineAt(cur, 150)
+	require.Equal(t, uint64(1), dl.Index)
+	require.Equal(t, abi.ChainEpoch(150), dl.Open)
+	require.Equal(t, abi.ChainEpoch(145), dl.Challenge)
+}
+
+func TestDeadlineTracker(t *testing.T) {
+	// 4 deadlines of 10 epochs, current proving period starts at 100
+	cur := dline.NewInfo(100, 1, 115, 4, 40, 10, 5, 3)
+
+	opens := func(dls []*dline.Info) []abi.ChainEpoch {
+		var out []abi.ChainEpoch
+		for _, dl := range dls {
+			out = append(out, dl.Open)
+		}
+		return out
+	}
+
+	dt := newDeadlineTracker()
+
+	// deadlines opened before subscribing aren't notified
+	require.Empty(t, dt.due(cur, 115))
+	require.Empty(t, dt.due(cur, 119))
+	require.Equal(t, []abi.ChainEpoch{120}, opens(dt.due(cur, 120)))
+
+	// null rounds don't skip deadlines
+	require.Equal(t, []abi.ChainEpoch{130, 140}, opens(dt.due(cur, 145)))
+
+	// reorgs don't notify about the same deadline twice
+	require.Empty(t, dt.due(cur, 138))
+	require.Empty(t, dt.due(cur, 141))
+	require.Equal(t, []abi.ChainEpoch{150}, opens(dt.due(cur, 150)))
+
+	// a new subscriber is notified at the exact epoch
+	dt = newDeadlineTracker()
+	dls := dt.due(cur, 160)
+	require.Equal(t, []abi.ChainEpoch{160}, opens(dls))
+	require.Equal(t, uint64(2), dls[0].Index)
+	require.Equal(t, abi.ChainEpoch(140), dls[0].PeriodStart)
+}
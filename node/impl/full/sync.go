@@ -5,6 +5,7 @@ import (
 	"sync/atomic"
 
 	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
@@ -12,6 +13,7 @@ import (
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain"
+	"github.com/filecoin-project/lotus/chain/exchange"
 	"github.com/filecoin-project/lotus/chain/gen/slashfilter"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
@@ -23,6 +25,7 @@ type SyncAPI struct {
 
 	SlashFilter *slashfilter.SlashFilter
 	Syncer      *chain.Syncer
+	Exchange    exchange.Client
 	PubSub      *pubsub.PubSub
 	NetName     dtypes.NetworkName
 }
@@ -107,6 +110,40 @@ func (a *SyncAPI) SyncCheckpoint(ctx context.Context, tsk types.TipSetKey) error
 	return a.Syncer.SetCheckpoint(tsk)
 }
 
+func (a *SyncAPI) SyncGetCheckpoint(ctx context.Context) (types.TipSetKey, error) {
+	return a.Syncer.GetCheckpoint(), nil
+}
+
+func (a *SyncAPI) SyncPeers(ctx context.Context) ([]api.SyncPeer, error) {
+	peers := a.Exchange.Peers()
+
+	out := make([]api.SyncPeer, len(peers))
+	for i, p := range peers {
+		out[i] = api.SyncPeer{
+			ID:               p.ID,
+			Successes:        p.Successes,
+			Failures:         p.Failures,
+			InvalidResponses: p.InvalidResponses,
+			SlowResponses:    p.SlowResponses,
+			BadBlocks:        p.BadBlocks,
+			AverageTime:      p.AverageTime,
+			Score:            p.Score,
+			BannedUntil:      p.BannedUntil,
+		}
+	}
+
+	return out, nil
+}
+
+func (a *SyncAPI) SyncUnbanPeer(ctx context.Context, p peer.ID) error {
+	if !a.Exchange.UnbanPeer(p) {
+		return xerrors.Errorf("peer %s isn't banned", p)
+	}
+
+	log.Warnf("Unbanned sync peer %s", p)
+	return nil
+}
+
 func (a *SyncAPI) SyncMarkBad(ctx context.Context, bcid cid.Cid) error {
 	log.Warnf("Marking block %s as bad", bcid)
 	a.Syncer.MarkBad(bcid)
@@ -0,0 +1,86 @@
+package impl
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+)
+
+// netDiagTimeout bounds each dial and fetch done by NetDiag
+const netDiagTimeout = 15 * time.Second
+
+const (
+	netDiagFetches = 3
+	netDiagTipsets = 10
+)
+
+func (n *FullNodeAPI) NetDiag(ctx context.Context) (api.NetDiagnostics, error) {
+	out := api.NetDiagnostics{
+		Time:  build.Clock.Now(),
+		Peers: len(n.CommonAPI.Host.Network().Peers()),
+	}
+
+	var err error
+	if out.Self, err = n.NetAddrsListen(ctx); err != nil {
+		return api.NetDiagnostics{}, xerrors.Errorf("getting listen addresses: %w", err)
+	}
+	if out.Nat, err = n.NetAutoNatStatus(ctx); err != nil {
+		return api.NetDiagnostics{}, xerrors.Errorf("getting NAT status: %w", err)
+	}
+	if out.Bandwidth, err = n.NetBandwidthStats(ctx); err != nil {
+		return api.NetDiagnostics{}, xerrors.Errorf("getting bandwidth stats: %w", err)
+	}
+	if out.Scores, err = n.NetPubsubScores(ctx); err != nil {
+		return api.NetDiagnostics{}, xerrors.Errorf("getting pubsub scores: %w", err)
+	}
+
+	for _, pi := range n.Bootstrap {
+		d := api.NetDiagDial{
+			Peer:         pi.ID,
+			WasConnected: n.CommonAPI.Host.Network().Connectedness(pi.ID) == network.Connected,
+		}
+
+		dctx, cancel := context.WithTimeout(ctx, netDiagTimeout)
+		start := build.Clock.Now()
+		err := n.NetConnect(dctx, pi)
+		d.Took = build.Clock.Since(start)
+		cancel()
+
+		if err != nil {
+			d.Error = err.Error()
+		}
+		out.Bootstrap = append(out.Bootstrap, d)
+	}
+
+	for _, topic := range []string{build.BlocksTopic(n.SyncAPI.NetName), build.MessagesTopic(n.SyncAPI.NetName)} {
+		out.Topics = append(out.Topics, api.NetDiagTopic{
+			Topic: topic,
+			Peers: len(n.SyncAPI.PubSub.ListPeers(topic)),
+		})
+	}
+
+	head := n.ChainAPI.Chain.GetHeaviestTipSet()
+	for i := 0; i < netDiagFetches; i++ {
+		f := api.NetDiagFetch{
+			Tipsets: netDiagTipsets,
+		}
+
+		fctx, cancel := context.WithTimeout(ctx, netDiagTimeout)
+		start := build.Clock.Now()
+		_, err := n.Exchange.GetBlocks(fctx, head.Parents(), netDiagTipsets)
+		f.Took = build.Clock.Since(start)
+		cancel()
+
+		if err != nil {
+			f.Error = err.Error()
+		}
+		out.ChainExchange = append(out.ChainExchange, f)
+	}
+
+	return out, nil
+}
@@ -3,12 +3,17 @@ package impl
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/gbrlsnchs/jwt/v3"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -25,18 +30,29 @@ import (
 
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/apistruct"
+	lminer "github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/markets/askrefresh"
+	"github.com/filecoin-project/lotus/markets/clientquota"
+	"github.com/filecoin-project/lotus/markets/pieceindex"
+	"github.com/filecoin-project/lotus/markets/retrievaladapter"
+	"github.com/filecoin-project/lotus/markets/transferlimit"
+	"github.com/filecoin-project/lotus/markets/transports"
 	"github.com/filecoin-project/lotus/miner"
 	"github.com/filecoin-project/lotus/node/impl/common"
+	"github.com/filecoin-project/lotus/node/modules"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
+	"github.com/filecoin-project/lotus/storage/slareport"
+	"github.com/filecoin-project/lotus/storage/workerauth"
 	sto "github.com/filecoin-project/specs-storage/storage"
 )
 
@@ -48,16 +64,34 @@ type StorageMinerAPI struct {
 	PieceStore        dtypes.ProviderPieceStore
 	StorageProvider   storagemarket.StorageProvider
 	RetrievalProvider retrievalmarket.RetrievalProvider
-	Miner             *storage.Miner
-	BlockMiner        *miner.Miner
+	RetrievalNode     retrievalmarket.RetrievalProviderNode
+	HTTPRetrievalURL  dtypes.HTTPRetrievalURL
+	HTTPRetrievalAlg  *dtypes.HTTPRetrievalAlg
+	PieceIndex        *pieceindex.Index
 	Full              api.FullNode
-	StorageMgr        *sectorstorage.Manager `optional:"true"`
-	IStorageMgr       sectorstorage.SectorManager
-	*stores.Index
-	storiface.WorkerReturn
-	DataTransfer dtypes.ProviderDataTransfer
-	Host         host.Host
-	AddrSel      *storage.AddressSelector
+	Maddr             dtypes.MinerAddress
+
+	// only set on nodes running only the markets subsystem, sector calls are
+	// proxied to it
+	SealingNode *modules.SealingNode `optional:"true"`
+
+	// not set on nodes running only the markets subsystem
+	Miner                  *storage.Miner              `optional:"true"`
+	BlockMiner             *miner.Miner                `optional:"true"`
+	StorageMgr             *sectorstorage.Manager      `optional:"true"`
+	IStorageMgr            sectorstorage.SectorManager `optional:"true"`
+	*stores.Index          `optional:"true"`
+	storiface.WorkerReturn `optional:"true"`
+	WorkerAuth             *workerauth.Authority `optional:"true"`
+	PartitionsCache        *PartitionsCache      `optional:"true"`
+
+	DataTransfer    dtypes.ProviderDataTransfer
+	TransferLimiter *transferlimit.Limiter
+	ClientQuotas    *clientquota.Quotas
+	AskRefresher    *askrefresh.Refresher
+	SLAReporter     *slareport.Reporter
+	Host            host.Host
+	AddrSel         *storage.AddressSelector
 
 	DS dtypes.MetadataDS
 
@@ -79,31 +113,211 @@ type StorageMinerAPI struct {
 	GetSealingConfigFunc                        dtypes.GetSealingConfigFunc
 	GetExpectedSealDurationFunc                 dtypes.GetExpectedSealDurationFunc
 	SetExpectedSealDurationFunc                 dtypes.SetExpectedSealDurationFunc
+	SetTransferLimitsConfigFunc                 dtypes.SetTransferLimitsConfigFunc
+	GetFundsPolicyFunc                          storage.GetFundsPolicyFunc
+	SetFundsPolicyFunc                          storage.SetFundsPolicyFunc
 }
 
 func (sm *StorageMinerAPI) ServeRemote(w http.ResponseWriter, r *http.Request) {
-	if !auth.HasPerm(r.Context(), nil, apistruct.PermAdmin) {
+	if !auth.HasPerm(r.Context(), nil, apistruct.PermWorker) {
 		w.WriteHeader(401)
-		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing write permission"})
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing worker permission"})
+		return
+	}
+
+	if sm.StorageMgr == nil {
+		http.Error(w, errMarketsNode.Error(), http.StatusNotImplemented)
 		return
 	}
 
 	sm.StorageMgr.ServeHTTP(w, r)
 }
 
+// ServePiece serves /piece/{sector}/{offset}/{size}, streaming unsealed piece
+// data to miner nodes running only the markets subsystem
+func (sm *StorageMinerAPI) ServePiece(w http.ResponseWriter, r *http.Request) {
+	if !auth.HasPerm(r.Context(), nil, apistruct.PermAdmin) {
+		w.WriteHeader(401)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing admin permission"})
+		return
+	}
+
+	if sm.Miner == nil {
+		http.Error(w, errMarketsNode.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	vars := mux.Vars(r)
+	sector, err := strconv.ParseUint(vars["sector"], 10, 64)
+	if err != nil {
+		http.Error(w, "parsing sector: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.ParseUint(vars["offset"], 10, 64)
+	if err != nil {
+		http.Error(w, "parsing offset: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, err := strconv.ParseUint(vars["size"], 10, 64)
+	if err != nil {
+		http.Error(w, "parsing size: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rpn := retrievaladapter.NewRetrievalProviderNode(sm.Miner, sm.IStorageMgr, sm.Full)
+	rd, err := rpn.UnsealSector(r.Context(), abi.SectorNumber(sector), abi.UnpaddedPieceSize(offset), abi.UnpaddedPieceSize(size))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rd.Close() //nolint:errcheck
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, rd); err != nil {
+		log.Errorw("serving piece", "sector", sector, "offset", offset, "size", size, "error", err)
+		// make sure the reader sees a truncated response
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// ServePieceData serves /pieces/{cid}, the data of a piece read from an
+// unsealed sector, so that retrieval services other than graphsync can serve
+// deal data. Range requests are supported.
+func (sm *StorageMinerAPI) ServePieceData(w http.ResponseWriter, r *http.Request) {
+	if !auth.HasPerm(r.Context(), nil, apistruct.PermAdmin) {
+		w.WriteHeader(401)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing admin permission"})
+		return
+	}
+
+	pieceCid, err := cid.Parse(mux.Vars(r)["cid"])
+	if err != nil {
+		http.Error(w, "parsing piece CID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pr, err := retrievaladapter.NewPieceReader(r.Context(), sm.PieceStore, sm.RetrievalNode, pieceCid)
+	if err != nil {
+		if xerrors.Is(err, retrievalmarket.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer pr.Close() //nolint:errcheck
+
+	// set, so that ServeContent doesn't sniff the content type
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, pr)
+}
+
 func (sm *StorageMinerAPI) WorkerStats(context.Context) (map[uuid.UUID]storiface.WorkerStats, error) {
+	if sm.StorageMgr == nil {
+		return nil, errMarketsNode
+	}
+
 	return sm.StorageMgr.WorkerStats(), nil
 }
 
+func (sm *StorageMinerAPI) WorkerDrain(ctx context.Context, worker uuid.UUID, drain bool) error {
+	if sm.StorageMgr == nil {
+		return errMarketsNode
+	}
+
+	return sm.StorageMgr.WorkerDrain(worker, drain)
+}
+
+func (sm *StorageMinerAPI) WorkerJoinTokenCreate(ctx context.Context, ttl time.Duration) (string, error) {
+	if sm.WorkerAuth == nil {
+		return "", errMarketsNode
+	}
+
+	return sm.WorkerAuth.CreateJoinToken(ttl)
+}
+
+func (sm *StorageMinerAPI) WorkerJoin(ctx context.Context, token string, name string) (api.WorkerCredential, error) {
+	if sm.WorkerAuth == nil {
+		return api.WorkerCredential{}, errMarketsNode
+	}
+
+	return sm.WorkerAuth.Join(token, name)
+}
+
+func (sm *StorageMinerAPI) WorkerRenew(ctx context.Context, certificate string) (time.Time, error) {
+	if sm.WorkerAuth == nil {
+		return time.Time{}, errMarketsNode
+	}
+
+	return sm.WorkerAuth.Renew(certificate)
+}
+
+func (sm *StorageMinerAPI) WorkerIdentities(ctx context.Context) ([]api.WorkerIdentity, error) {
+	if sm.WorkerAuth == nil {
+		return nil, errMarketsNode
+	}
+
+	return sm.WorkerAuth.Identities()
+}
+
+func (sm *StorageMinerAPI) WorkerIdentityRevoke(ctx context.Context, id uuid.UUID) error {
+	if sm.WorkerAuth == nil {
+		return errMarketsNode
+	}
+
+	return sm.WorkerAuth.Revoke(id)
+}
+
+func (sm *StorageMinerAPI) WorkerProfileCapture(ctx context.Context, worker uuid.UUID, name string, duration time.Duration) ([]byte, error) {
+	if sm.StorageMgr == nil {
+		return nil, errMarketsNode
+	}
+
+	return sm.StorageMgr.WorkerProfileCapture(ctx, worker, name, duration)
+}
+
+// AuthVerify additionally rejects certificates of revoked or expired worker
+// identities. Admin tokens are granted the worker permission.
+func (sm *StorageMinerAPI) AuthVerify(ctx context.Context, token string) ([]auth.Permission, error) {
+	perms, err := sm.CommonAPI.AuthVerify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// worker certificates are only issued by nodes running the sealing
+	// subsystem
+	if sm.WorkerAuth != nil {
+		if err := sm.WorkerAuth.Verify(token); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range perms {
+		if p == apistruct.PermAdmin {
+			return append(perms, apistruct.PermWorker), nil
+		}
+	}
+
+	return perms, nil
+}
+
 func (sm *StorageMinerAPI) WorkerJobs(ctx context.Context) (map[uuid.UUID][]storiface.WorkerJob, error) {
+	if sm.StorageMgr == nil {
+		return nil, errMarketsNode
+	}
+
 	return sm.StorageMgr.WorkerJobs(), nil
 }
 
 func (sm *StorageMinerAPI) ActorAddress(context.Context) (address.Address, error) {
-	return sm.Miner.Address(), nil
+	return address.Address(sm.Maddr), nil
 }
 
 func (sm *StorageMinerAPI) MiningBase(ctx context.Context) (*types.TipSet, error) {
+	if sm.BlockMiner == nil {
+		return nil, errMarketsNode
+	}
+
 	mb, err := sm.BlockMiner.GetBestMiningCandidate(ctx)
 	if err != nil {
 		return nil, err
@@ -111,6 +325,22 @@ func (sm *StorageMinerAPI) MiningBase(ctx context.Context) (*types.TipSet, error
 	return mb.TipSet, nil
 }
 
+func (sm *StorageMinerAPI) MiningBlockCandidate(ctx context.Context) (*api.BlockCandidate, error) {
+	if sm.BlockMiner == nil {
+		return nil, errMarketsNode
+	}
+
+	return sm.BlockMiner.BlockCandidate(ctx)
+}
+
+func (sm *StorageMinerAPI) MiningBlockHistory(ctx context.Context) ([]api.MinedBlock, error) {
+	if sm.BlockMiner == nil {
+		return nil, errMarketsNode
+	}
+
+	return sm.BlockMiner.MinedBlocks(), nil
+}
+
 func (sm *StorageMinerAPI) ActorSectorSize(ctx context.Context, addr address.Address) (abi.SectorSize, error) {
 	mi, err := sm.Full.StateMinerInfo(ctx, addr, types.EmptyTSK)
 	if err != nil {
@@ -120,10 +350,18 @@ func (sm *StorageMinerAPI) ActorSectorSize(ctx context.Context, addr address.Add
 }
 
 func (sm *StorageMinerAPI) PledgeSector(ctx context.Context) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.PledgeSector(ctx)
+	}
+
 	return sm.Miner.PledgeSector()
 }
 
 func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorsStatus(ctx, sid, showOnChainInfo)
+	}
+
 	info, err := sm.Miner.GetSectorInfo(sid)
 	if err != nil {
 		return api.SectorInfo{}, err
@@ -180,15 +418,39 @@ func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid abi.SectorNumb
 		Early:              0,
 	}
 
+	sInfo.FailureRetries = info.FailureRetries
+	next, err := sm.Miner.SectorNextRetry(info)
+	if err != nil {
+		return api.SectorInfo{}, err
+	}
+	if !next.IsZero() {
+		sInfo.NextRetry = uint64(next.Unix())
+	}
+
 	if !showOnChainInfo {
 		return sInfo, nil
 	}
 
-	onChainInfo, err := sm.Full.StateSectorGetInfo(ctx, sm.Miner.Address(), sid, types.EmptyTSK)
+	// all chain state is read at the same tipset
+	head, err := sm.Full.ChainHead(ctx)
+	if err != nil {
+		return sInfo, xerrors.Errorf("getting chain head: %w", err)
+	}
+	tsk := head.Key()
+
+	onChainInfo, err := sm.Full.StateSectorGetInfo(ctx, address.Address(sm.Maddr), sid, tsk)
 	if err != nil {
 		return sInfo, err
 	}
 	if onChainInfo == nil {
+		var pci *lminer.SectorPreCommitOnChainInfo
+		// the API doesn't tell a missing precommit apart from a failure
+		if p, err := sm.Full.StateSectorPreCommitInfo(ctx, address.Address(sm.Maddr), sid, tsk); err == nil {
+			pci = &p
+			sInfo.PreCommitEpoch = pci.PreCommitEpoch
+		}
+
+		sInfo.Discrepancies = precommitDiscrepancies(info, pci)
 		return sInfo, nil
 	}
 	sInfo.SealProof = onChainInfo.SealProof
@@ -198,18 +460,160 @@ func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid abi.SectorNumb
 	sInfo.VerifiedDealWeight = onChainInfo.VerifiedDealWeight
 	sInfo.InitialPledge = onChainInfo.InitialPledge
 
-	ex, err := sm.Full.StateSectorExpiration(ctx, sm.Miner.Address(), sid, types.EmptyTSK)
+	var discrepancies []string
+
+	// the expiration isn't found for sectors which aren't in any partition,
+	// which the rest of the checks still apply to
+	ex, err := sm.Full.StateSectorExpiration(ctx, address.Address(sm.Maddr), sid, tsk)
 	if err != nil {
-		return sInfo, nil
+		discrepancies = append(discrepancies, fmt.Sprintf("getting the on chain expiration failed: %s", err))
+	} else {
+		sInfo.OnTime = ex.OnTime
+		sInfo.Early = ex.Early
+	}
+
+	loc, err := sm.Full.StateSectorPartition(ctx, address.Address(sm.Maddr), sid, tsk)
+	if err != nil {
+		return sInfo, xerrors.Errorf("finding sector partition: %w", err)
 	}
-	sInfo.OnTime = ex.OnTime
-	sInfo.Early = ex.Early
+	if loc != nil {
+		sInfo.Deadline = &loc.Deadline
+		sInfo.Partition = &loc.Partition
+
+		parts, err := sm.deadlinePartitions(ctx, loc.Deadline, tsk)
+		if err != nil {
+			return sInfo, xerrors.Errorf("getting deadline partitions: %w", err)
+		}
+		if loc.Partition < uint64(len(parts)) {
+			part := parts[loc.Partition]
+			if sInfo.Faulty, err = part.FaultySectors.IsSet(uint64(sid)); err != nil {
+				return sInfo, xerrors.Errorf("checking faults: %w", err)
+			}
+			if sInfo.Recovering, err = part.RecoveringSectors.IsSet(uint64(sid)); err != nil {
+				return sInfo, xerrors.Errorf("checking recoveries: %w", err)
+			}
+		}
+	}
+
+	sInfo.Discrepancies = append(discrepancies, provenDiscrepancies(info, onChainInfo, sInfo.Faulty)...)
 
 	return sInfo, nil
 }
 
+type partitionsKey struct {
+	maddr    address.Address
+	deadline uint64
+	tsk      types.TipSetKey
+}
+
+// PartitionsCache holds the partitions of recently queried deadlines, so that
+// getting the status of all sectors loads the partitions of each deadline once
+// per tipset, instead of once per sector
+type PartitionsCache struct {
+	cache *lru.Cache
+}
+
+func NewPartitionsCache() (*PartitionsCache, error) {
+	cache, err := lru.New(2 * int(lminer.WPoStPeriodDeadlines))
+	if err != nil {
+		return nil, xerrors.Errorf("creating partitions cache: %w", err)
+	}
+
+	return &PartitionsCache{cache: cache}, nil
+}
+
+func (sm *StorageMinerAPI) deadlinePartitions(ctx context.Context, dl uint64, tsk types.TipSetKey) ([]api.Partition, error) {
+	key := partitionsKey{maddr: address.Address(sm.Maddr), deadline: dl, tsk: tsk}
+	if parts, ok := sm.PartitionsCache.cache.Get(key); ok {
+		return parts.([]api.Partition), nil
+	}
+
+	parts, err := sm.Full.StateMinerPartitions(ctx, address.Address(sm.Maddr), dl, tsk)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.PartitionsCache.cache.Add(key, parts)
+	return parts, nil
+}
+
+// precommitDiscrepancies compares the state of a sector which isn't proven on
+// chain with its precommit, nil if there is none
+func precommitDiscrepancies(info sealing.SectorInfo, pci *lminer.SectorPreCommitOnChainInfo) []string {
+	var out []string
+
+	switch {
+	case sealing.IsBeforePreCommit(info.State):
+		if pci != nil {
+			out = append(out, "sector is precommitted on chain, but the local state is before precommit")
+		}
+	case sealing.IsPreCommitted(info.State) && info.State != sealing.PreCommitWait:
+		// the precommit message may not have landed yet in PreCommitWait
+		if pci == nil {
+			out = append(out, "precommit not found on chain, it may have expired")
+		}
+	case info.State == sealing.FinalizeSector, info.State == sealing.Proving, info.State == sealing.Faulty, info.State == sealing.FaultReported:
+		out = append(out, "sector isn't proven on chain")
+	}
+
+	if pci != nil {
+		out = append(out, sealedDiscrepancies(info, pci.Info.SealedCID, pci.Info.DealIDs)...)
+	}
+
+	return out
+}
+
+// provenDiscrepancies compares the state of a sector with its on-chain info
+func provenDiscrepancies(info sealing.SectorInfo, onChain *lminer.SectorOnChainInfo, faulty bool) []string {
+	var out []string
+
+	if sealing.IsBeforeProving(info.State) {
+		out = append(out, "sector is proven on chain, but the local state is before proving")
+	}
+
+	switch info.State {
+	case sealing.Removed:
+		out = append(out, "sector is removed locally, but still active on chain")
+	case sealing.Faulty, sealing.FaultReported:
+		if !faulty {
+			out = append(out, "sector is faulty locally, but not on chain")
+		}
+	}
+
+	return append(out, sealedDiscrepancies(info, onChain.SealedCID, onChain.DealIDs)...)
+}
+
+func sealedDiscrepancies(info sealing.SectorInfo, sealedCID cid.Cid, deals []abi.DealID) []string {
+	var out []string
+
+	if info.CommR != nil && *info.CommR != sealedCID {
+		out = append(out, fmt.Sprintf("local CommR %s doesn't match the sealed CID %s on chain", info.CommR, sealedCID))
+	}
+
+	var local []abi.DealID
+	for _, p := range info.Pieces {
+		if p.DealInfo != nil {
+			local = append(local, p.DealInfo.DealID)
+		}
+	}
+
+	same := len(local) == len(deals)
+	for i := 0; same && i < len(local); i++ {
+		same = local[i] == deals[i]
+	}
+	if !same {
+		out = append(out, fmt.Sprintf("local deals %v don't match the deals %v on chain", local, deals))
+	}
+
+	return out
+}
+
 // List all staged sectors
-func (sm *StorageMinerAPI) SectorsList(context.Context) ([]abi.SectorNumber, error) {
+func (sm *StorageMinerAPI) SectorsList(ctx context.Context) ([]abi.SectorNumber, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorsList(ctx)
+	}
+
 	sectors, err := sm.Miner.ListSectors()
 	if err != nil {
 		return nil, err
@@ -223,6 +627,10 @@ func (sm *StorageMinerAPI) SectorsList(context.Context) ([]abi.SectorNumber, err
 }
 
 func (sm *StorageMinerAPI) SectorsListInStates(ctx context.Context, states []api.SectorState) ([]abi.SectorNumber, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorsListInStates(ctx, states)
+	}
+
 	filterStates := make(map[sealing.SectorState]struct{})
 	for _, state := range states {
 		st := sealing.SectorState(state)
@@ -251,6 +659,10 @@ func (sm *StorageMinerAPI) SectorsListInStates(ctx context.Context, states []api
 }
 
 func (sm *StorageMinerAPI) SectorsSummary(ctx context.Context) (map[api.SectorState]int, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorsSummary(ctx)
+	}
+
 	sectors, err := sm.Miner.ListSectors()
 	if err != nil {
 		return nil, err
@@ -265,7 +677,19 @@ func (sm *StorageMinerAPI) SectorsSummary(ctx context.Context) (map[api.SectorSt
 	return out, nil
 }
 
+func (sm *StorageMinerAPI) SectorsStateSummary(ctx context.Context) (map[api.SectorState]api.SectorStateSummary, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorsStateSummary(ctx)
+	}
+
+	return sm.Miner.SectorsStateSummary()
+}
+
 func (sm *StorageMinerAPI) StorageLocal(ctx context.Context) (map[stores.ID]string, error) {
+	if sm.StorageMgr == nil {
+		return nil, errMarketsNode
+	}
+
 	return sm.StorageMgr.StorageLocal(ctx)
 }
 
@@ -285,15 +709,43 @@ func (sm *StorageMinerAPI) SectorsRefs(context.Context) (map[string][]api.Sealed
 	return out, nil
 }
 
+func (sm *StorageMinerAPI) SectorsHistory(ctx context.Context, sid abi.SectorNumber) ([]api.SectorStateTransition, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorsHistory(ctx, sid)
+	}
+
+	return sm.Miner.SectorHistory(sid)
+}
+
 func (sm *StorageMinerAPI) StorageStat(ctx context.Context, id stores.ID) (fsutil.FsStat, error) {
+	if sm.StorageMgr == nil {
+		return fsutil.FsStat{}, errMarketsNode
+	}
+
 	return sm.StorageMgr.FsStat(ctx, id)
 }
 
+func (sm *StorageMinerAPI) StorageTransfers(ctx context.Context) ([]stores.TransferInfo, error) {
+	if sm.StorageMgr == nil {
+		return nil, errMarketsNode
+	}
+
+	return sm.StorageMgr.Transfers(), nil
+}
+
 func (sm *StorageMinerAPI) SectorStartSealing(ctx context.Context, number abi.SectorNumber) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorStartSealing(ctx, number)
+	}
+
 	return sm.Miner.StartPackingSector(number)
 }
 
 func (sm *StorageMinerAPI) SectorSetSealDelay(ctx context.Context, delay time.Duration) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorSetSealDelay(ctx, delay)
+	}
+
 	cfg, err := sm.GetSealingConfigFunc()
 	if err != nil {
 		return xerrors.Errorf("get config: %w", err)
@@ -301,7 +753,12 @@ func (sm *StorageMinerAPI) SectorSetSealDelay(ctx context.Context, delay time.Du
 
 	cfg.WaitDealsDelay = delay
 
-	return sm.SetSealingConfigFunc(cfg)
+	if err := sm.SetSealingConfigFunc(cfg); err != nil {
+		return err
+	}
+
+	// sectors already waiting for deals wait for the new delay
+	return sm.Miner.UpdateWaitDealsTimers()
 }
 
 func (sm *StorageMinerAPI) SectorGetSealDelay(ctx context.Context) (time.Duration, error) {
@@ -321,30 +778,131 @@ func (sm *StorageMinerAPI) SectorGetExpectedSealDuration(ctx context.Context) (t
 }
 
 func (sm *StorageMinerAPI) SectorsUpdate(ctx context.Context, id abi.SectorNumber, state api.SectorState) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorsUpdate(ctx, id, state)
+	}
+
 	return sm.Miner.ForceSectorState(ctx, id, sealing.SectorState(state))
 }
 
+func (sm *StorageMinerAPI) SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r sto.Data, d api.PieceDealInfo) (api.SectorOffset, error) {
+	sn, offset, err := sm.SectorBlocks.AddPiece(ctx, size, r, toDealInfo(d))
+	if err != nil {
+		return api.SectorOffset{}, err
+	}
+
+	return api.SectorOffset{Sector: sn, Offset: offset}, nil
+}
+
+func (sm *StorageMinerAPI) SectorReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d api.PieceDealInfo) (api.PieceLease, error) {
+	return sm.SectorBlocks.ReservePiece(ctx, size, toDealInfo(d))
+}
+
+func (sm *StorageMinerAPI) SectorCommitPiece(ctx context.Context, lease uint64, r sto.Data) (api.SectorOffset, error) {
+	l, err := sm.SectorBlocks.CommitPiece(ctx, lease, r)
+	if err != nil {
+		return api.SectorOffset{}, err
+	}
+
+	return api.SectorOffset{Sector: l.Sector, Offset: l.Offset}, nil
+}
+
+func toDealInfo(d api.PieceDealInfo) sealing.DealInfo {
+	return sealing.DealInfo{
+		PublishCid: d.PublishCid,
+		DealID:     d.DealID,
+		DealSchedule: sealing.DealSchedule{
+			StartEpoch: d.StartEpoch,
+			EndEpoch:   d.EndEpoch,
+		},
+		KeepUnsealed: d.KeepUnsealed,
+		Labels:       d.Labels,
+	}
+}
+
 func (sm *StorageMinerAPI) SectorRemove(ctx context.Context, id abi.SectorNumber) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorRemove(ctx, id)
+	}
+
 	return sm.Miner.RemoveSector(ctx, id)
 }
 
+func (sm *StorageMinerAPI) SectorAbort(ctx context.Context, id abi.SectorNumber) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorAbort(ctx, id)
+	}
+
+	moved, err := sm.Miner.AbortSector(ctx, id)
+	for _, mp := range moved {
+		// retrievals and deal status lookups find the piece at its new location
+		if perr := sm.PieceStore.AddDealForPiece(mp.PieceCID, piecestore.DealInfo{
+			DealID:   mp.DealID,
+			SectorID: mp.Sector,
+			Offset:   mp.Offset,
+			Length:   mp.Size,
+		}); perr != nil {
+			log.Errorw("recording moved piece in the piece store", "deal", mp.DealID, "sector", mp.Sector, "error", perr)
+			if err == nil {
+				err = xerrors.Errorf("recording moved piece of deal %d: %w", mp.DealID, perr)
+			}
+		}
+		if perr := sm.SectorBlocks.AddRef(mp.DealID, mp.Sector, mp.Offset, mp.Size.Unpadded()); perr != nil {
+			log.Errorw("recording moved piece reference", "deal", mp.DealID, "sector", mp.Sector, "error", perr)
+			if err == nil {
+				err = xerrors.Errorf("recording moved piece of deal %d: %w", mp.DealID, perr)
+			}
+		}
+	}
+
+	return err
+}
+
+func (sm *StorageMinerAPI) SectorRegenerateCache(ctx context.Context, id abi.SectorNumber) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorRegenerateCache(ctx, id)
+	}
+
+	return sm.Miner.RegenerateSectorCache(ctx, id)
+}
+
 func (sm *StorageMinerAPI) SectorTerminate(ctx context.Context, id abi.SectorNumber) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorTerminate(ctx, id)
+	}
+
 	return sm.Miner.TerminateSector(ctx, id)
 }
 
 func (sm *StorageMinerAPI) SectorTerminateFlush(ctx context.Context) (*cid.Cid, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorTerminateFlush(ctx)
+	}
+
 	return sm.Miner.TerminateFlush(ctx)
 }
 
 func (sm *StorageMinerAPI) SectorTerminatePending(ctx context.Context) ([]abi.SectorID, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorTerminatePending(ctx)
+	}
+
 	return sm.Miner.TerminatePending(ctx)
 }
 
 func (sm *StorageMinerAPI) SectorMarkForUpgrade(ctx context.Context, id abi.SectorNumber) error {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SectorMarkForUpgrade(ctx, id)
+	}
+
 	return sm.Miner.MarkForUpgrade(id)
 }
 
 func (sm *StorageMinerAPI) WorkerConnect(ctx context.Context, url string) error {
+	if sm.StorageMgr == nil {
+		return errMarketsNode
+	}
+
 	w, err := connectRemoteWorker(ctx, sm, url)
 	if err != nil {
 		return xerrors.Errorf("connecting remote storage failed: %w", err)
@@ -356,10 +914,43 @@ func (sm *StorageMinerAPI) WorkerConnect(ctx context.Context, url string) error
 }
 
 func (sm *StorageMinerAPI) SealingSchedDiag(ctx context.Context, doSched bool) (interface{}, error) {
+	if sm.StorageMgr == nil {
+		return nil, errMarketsNode
+	}
+
 	return sm.StorageMgr.SchedDiag(ctx, doSched)
 }
 
+func (sm *StorageMinerAPI) SealingSetTaskPriority(ctx context.Context, tt sealtasks.TaskType, priority int) error {
+	if sm.StorageMgr == nil {
+		return errMarketsNode
+	}
+
+	sm.StorageMgr.SetTaskPriority(tt, priority)
+	return nil
+}
+
+func (sm *StorageMinerAPI) SealingTaskPriorities(ctx context.Context) (map[sealtasks.TaskType]int, error) {
+	if sm.StorageMgr == nil {
+		return nil, errMarketsNode
+	}
+
+	return sm.StorageMgr.TaskPriorities(), nil
+}
+
+func (sm *StorageMinerAPI) SealingAddPieceQueue(ctx context.Context) (api.AddPieceQueueInfo, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.SealingAddPieceQueue(ctx)
+	}
+
+	return sm.Miner.AddPieceQueue()
+}
+
 func (sm *StorageMinerAPI) SealingAbort(ctx context.Context, call storiface.CallID) error {
+	if sm.StorageMgr == nil {
+		return errMarketsNode
+	}
+
 	return sm.StorageMgr.Abort(ctx, call)
 }
 
@@ -387,7 +978,7 @@ func (sm *StorageMinerAPI) listDeals(ctx context.Context) ([]api.MarketDeal, err
 	var out []api.MarketDeal
 
 	for _, deal := range allDeals {
-		if deal.Proposal.Provider == sm.Miner.Address() {
+		if deal.Proposal.Provider == address.Address(sm.Maddr) {
 			out = append(out, deal)
 		}
 	}
@@ -430,19 +1021,35 @@ func (sm *StorageMinerAPI) MarketListIncompleteDeals(ctx context.Context) ([]sto
 	return sm.StorageProvider.ListLocalDeals()
 }
 
+func (sm *StorageMinerAPI) MarketClientQuotas(ctx context.Context) ([]api.ClientQuotaUsage, error) {
+	return sm.ClientQuotas.Usage(ctx)
+}
+
 func (sm *StorageMinerAPI) MarketSetAsk(ctx context.Context, price types.BigInt, verifiedPrice types.BigInt, duration abi.ChainEpoch, minPieceSize abi.PaddedPieceSize, maxPieceSize abi.PaddedPieceSize) error {
 	options := []storagemarket.StorageAskOption{
 		storagemarket.MinPieceSize(minPieceSize),
 		storagemarket.MaxPieceSize(maxPieceSize),
 	}
 
-	return sm.StorageProvider.SetAsk(price, verifiedPrice, duration, options...)
+	if err := sm.StorageProvider.SetAsk(price, verifiedPrice, duration, options...); err != nil {
+		return err
+	}
+
+	if err := sm.AskRefresher.Update(ctx); err != nil {
+		return xerrors.Errorf("ask was set, recording it failed: %w", err)
+	}
+
+	return nil
 }
 
 func (sm *StorageMinerAPI) MarketGetAsk(ctx context.Context) (*storagemarket.SignedStorageAsk, error) {
 	return sm.StorageProvider.GetAsk(), nil
 }
 
+func (sm *StorageMinerAPI) MarketGetAskHistory(ctx context.Context, limit int) ([]*storagemarket.SignedStorageAsk, error) {
+	return sm.AskRefresher.History(limit)
+}
+
 func (sm *StorageMinerAPI) MarketSetRetrievalAsk(ctx context.Context, rask *retrievalmarket.Ask) error {
 	sm.RetrievalProvider.SetAsk(rask)
 	return nil
@@ -452,6 +1059,13 @@ func (sm *StorageMinerAPI) MarketGetRetrievalAsk(ctx context.Context) (*retrieva
 	return sm.RetrievalProvider.GetAsk(), nil
 }
 
+func (sm *StorageMinerAPI) MarketHTTPRetrievalToken(ctx context.Context) (string, error) {
+	if sm.HTTPRetrievalURL == "" {
+		return "", xerrors.New("the HTTP retrieval transport isn't enabled, see Dealmaking.HTTPRetrievalListen")
+	}
+	return transports.SignToken((*jwt.HMACSHA)(sm.HTTPRetrievalAlg))
+}
+
 func (sm *StorageMinerAPI) MarketListDataTransfers(ctx context.Context) ([]api.DataTransferChannel, error) {
 	inProgressChannels, err := sm.DataTransfer.InProgressChannels(ctx)
 	if err != nil {
@@ -482,6 +1096,20 @@ func (sm *StorageMinerAPI) MarketCancelDataTransfer(ctx context.Context, transfe
 	return sm.DataTransfer.CloseDataTransferChannel(ctx, datatransfer.ChannelID{Initiator: otherPeer, Responder: selfPeer, ID: transferID})
 }
 
+func (sm *StorageMinerAPI) MarketGetTransferLimits(ctx context.Context) (api.TransferLimits, error) {
+	perTransfer, total := sm.TransferLimiter.Limits()
+	return api.TransferLimits{PerTransfer: perTransfer, Total: total}, nil
+}
+
+func (sm *StorageMinerAPI) MarketSetTransferLimits(ctx context.Context, limits api.TransferLimits) error {
+	if err := sm.SetTransferLimitsConfigFunc(limits.PerTransfer, limits.Total); err != nil {
+		return xerrors.Errorf("saving transfer limits: %w", err)
+	}
+
+	sm.TransferLimiter.SetLimits(limits.PerTransfer, limits.Total)
+	return nil
+}
+
 func (sm *StorageMinerAPI) MarketDataTransferUpdates(ctx context.Context) (<-chan api.DataTransferChannel, error) {
 	channels := make(chan api.DataTransferChannel)
 
@@ -505,6 +1133,14 @@ func (sm *StorageMinerAPI) DealsList(ctx context.Context) ([]api.MarketDeal, err
 	return sm.listDeals(ctx)
 }
 
+func (sm *StorageMinerAPI) DealsCommitEstimates(ctx context.Context) ([]api.DealCommitEstimate, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.DealsCommitEstimates(ctx)
+	}
+
+	return sm.Miner.DealCommitEstimates()
+}
+
 func (sm *StorageMinerAPI) RetrievalDealsList(ctx context.Context) (map[retrievalmarket.ProviderDealIdentifier]retrievalmarket.ProviderDealState, error) {
 	return sm.RetrievalProvider.ListDeals(), nil
 }
@@ -572,7 +1208,48 @@ func (sm *StorageMinerAPI) DealsImportData(ctx context.Context, deal cid.Cid, fn
 	}
 	defer fi.Close() //nolint:errcheck
 
-	return sm.StorageProvider.ImportDataForDeal(ctx, deal, fi)
+	deals, err := sm.StorageProvider.ListLocalDeals()
+	if err != nil {
+		return xerrors.Errorf("listing deals: %w", err)
+	}
+
+	st, err := fi.Stat()
+	if err != nil {
+		return xerrors.Errorf("getting file size: %w", err)
+	}
+
+	if err := checkDealData(deals, deal, st.Size()); err != nil {
+		return err
+	}
+
+	// the provider computes the piece commitment of the data while importing
+	// it, and rejects data which doesn't match the deal proposal
+	if err := sm.StorageProvider.ImportDataForDeal(ctx, deal, fi); err != nil {
+		return xerrors.Errorf("importing data for deal %s: %w", deal, err)
+	}
+
+	return nil
+}
+
+// checkDealData checks that the deal is known, and that a file of the size fits
+// in its piece, before the data is imported
+func checkDealData(deals []storagemarket.MinerDeal, propCid cid.Cid, size int64) error {
+	var deal *storagemarket.MinerDeal
+	for i := range deals {
+		if deals[i].ProposalCid == propCid {
+			deal = &deals[i]
+			break
+		}
+	}
+	if deal == nil {
+		return xerrors.Errorf("deal %s not found", propCid)
+	}
+
+	if max := deal.Proposal.PieceSize.Unpadded(); uint64(size) > uint64(max) {
+		return xerrors.Errorf("file size %d is larger than the deal piece size %d", size, max)
+	}
+
+	return nil
 }
 
 func (sm *StorageMinerAPI) DealsPieceCidBlocklist(ctx context.Context) ([]cid.Cid, error) {
@@ -584,6 +1261,10 @@ func (sm *StorageMinerAPI) DealsSetPieceCidBlocklist(ctx context.Context, cids [
 }
 
 func (sm *StorageMinerAPI) StorageAddLocal(ctx context.Context, path string) error {
+	if sm.StorageMgr == nil {
+		return errMarketsNode
+	}
+
 	if sm.StorageMgr == nil {
 		return xerrors.Errorf("no storage manager")
 	}
@@ -616,27 +1297,49 @@ func (sm *StorageMinerAPI) PiecesGetCIDInfo(ctx context.Context, payloadCid cid.
 	return &ci, nil
 }
 
+func (sm *StorageMinerAPI) PiecesLocateBlock(ctx context.Context, block cid.Cid) ([]api.BlockLocation, error) {
+	return sm.PieceIndex.Locate(block)
+}
+
+func (sm *StorageMinerAPI) PiecesRebuildIndex(ctx context.Context, pieceCid cid.Cid) (int, error) {
+	return sm.PieceIndex.Rebuild(ctx, pieceCid)
+}
+
 func (sm *StorageMinerAPI) CreateBackup(ctx context.Context, fpath string) error {
 	return backup(sm.DS, fpath)
 }
 
 func (sm *StorageMinerAPI) CheckProvable(ctx context.Context, pp abi.RegisteredPoStProof, sectors []sto.SectorRef, expensive bool) (map[abi.SectorNumber]string, error) {
-	var rg storiface.RGetter
+	if sm.SealingNode != nil {
+		return sm.SealingNode.CheckProvable(ctx, pp, sectors, expensive)
+	}
+
+	var sampleRate float64
 	if expensive {
-		rg = func(ctx context.Context, id abi.SectorID) (cid.Cid, error) {
-			si, err := sm.Miner.GetSectorInfo(id.Number)
-			if err != nil {
-				return cid.Undef, err
-			}
-			if si.CommR == nil {
-				return cid.Undef, xerrors.Errorf("commr is nil")
-			}
+		sampleRate = 1
+	}
+
+	return sm.CheckProvableSampled(ctx, pp, sectors, sampleRate)
+}
 
-			return *si.CommR, nil
+func (sm *StorageMinerAPI) CheckProvableSampled(ctx context.Context, pp abi.RegisteredPoStProof, sectors []sto.SectorRef, sampleRate float64) (map[abi.SectorNumber]string, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.CheckProvableSampled(ctx, pp, sectors, sampleRate)
+	}
+
+	rg := func(ctx context.Context, id abi.SectorID) (cid.Cid, error) {
+		si, err := sm.Miner.GetSectorInfo(id.Number)
+		if err != nil {
+			return cid.Undef, err
+		}
+		if si.CommR == nil {
+			return cid.Undef, xerrors.Errorf("commr is nil")
 		}
+
+		return *si.CommR, nil
 	}
 
-	bad, err := sm.StorageMgr.CheckProvable(ctx, pp, sectors, rg)
+	bad, err := sectorstorage.CheckProvableSampled(ctx, sm.StorageMgr, pp, sectors, sampleRate, rg)
 	if err != nil {
 		return nil, err
 	}
@@ -649,8 +1352,43 @@ func (sm *StorageMinerAPI) CheckProvable(ctx context.Context, pp abi.RegisteredP
 	return out, nil
 }
 
+func (sm *StorageMinerAPI) ProvingPrechecks(ctx context.Context) ([]api.PoStPrecheck, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.ProvingPrechecks(ctx)
+	}
+
+	return sm.Miner.PoStPrechecks(), nil
+}
+
+func (sm *StorageMinerAPI) ProvingRecoveries(ctx context.Context) ([]api.PoStRecovery, error) {
+	if sm.SealingNode != nil {
+		return sm.SealingNode.ProvingRecoveries(ctx)
+	}
+
+	return sm.Miner.PoStRecoveries(), nil
+}
+
+func (sm *StorageMinerAPI) SLAReportGenerate(ctx context.Context, from, to abi.ChainEpoch) (*api.SignedSLAReport, error) {
+	return sm.SLAReporter.Generate(ctx, from, to)
+}
+
+func (sm *StorageMinerAPI) SLAReportList(ctx context.Context, limit int) ([]*api.SignedSLAReport, error) {
+	return sm.SLAReporter.History(limit)
+}
+
 func (sm *StorageMinerAPI) ActorAddressConfig(ctx context.Context) (api.AddressConfig, error) {
 	return sm.AddrSel.AddressConfig, nil
 }
 
+func (sm *StorageMinerAPI) ActorFundsPolicy(ctx context.Context) (api.FundsPolicy, error) {
+	return sm.GetFundsPolicyFunc()
+}
+
+func (sm *StorageMinerAPI) ActorSetFundsPolicy(ctx context.Context, p api.FundsPolicy) error {
+	if err := storage.CheckFundsPolicy(p); err != nil {
+		return xerrors.Errorf("invalid funds policy: %w", err)
+	}
+	return sm.SetFundsPolicyFunc(p)
+}
+
 var _ api.StorageMiner = &StorageMinerAPI{}
@@ -0,0 +1,197 @@
+package impl
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// errMarketsNode is returned by the calls which need the sealing subsystem on
+// nodes running only the markets subsystem
+var errMarketsNode = xerrors.New("not available on a markets node")
+
+// The sector index and the worker returns are embedded in StorageMinerAPI,
+// they aren't set on markets nodes.
+
+func (sm *StorageMinerAPI) StorageList(ctx context.Context) (map[stores.ID][]stores.Decl, error) {
+	if sm.Index == nil {
+		return nil, errMarketsNode
+	}
+	return sm.Index.StorageList(ctx)
+}
+
+func (sm *StorageMinerAPI) StorageHealth(ctx context.Context, id stores.ID) (stores.HealthState, error) {
+	if sm.Index == nil {
+		return stores.HealthState{}, errMarketsNode
+	}
+	return sm.Index.StorageHealth(ctx, id)
+}
+
+func (sm *StorageMinerAPI) StorageAttach(ctx context.Context, si stores.StorageInfo, st fsutil.FsStat) error {
+	if sm.Index == nil {
+		return errMarketsNode
+	}
+	return sm.Index.StorageAttach(ctx, si, st)
+}
+
+func (sm *StorageMinerAPI) StorageInfo(ctx context.Context, id stores.ID) (stores.StorageInfo, error) {
+	if sm.Index == nil {
+		return stores.StorageInfo{}, errMarketsNode
+	}
+	return sm.Index.StorageInfo(ctx, id)
+}
+
+func (sm *StorageMinerAPI) StorageReportHealth(ctx context.Context, id stores.ID, report stores.HealthReport) error {
+	if sm.Index == nil {
+		return errMarketsNode
+	}
+	return sm.Index.StorageReportHealth(ctx, id, report)
+}
+
+func (sm *StorageMinerAPI) StorageDeclareSector(ctx context.Context, storageID stores.ID, s abi.SectorID, ft storiface.SectorFileType, primary bool) error {
+	if sm.Index == nil {
+		return errMarketsNode
+	}
+	return sm.Index.StorageDeclareSector(ctx, storageID, s, ft, primary)
+}
+
+func (sm *StorageMinerAPI) StorageDropSector(ctx context.Context, storageID stores.ID, s abi.SectorID, ft storiface.SectorFileType) error {
+	if sm.Index == nil {
+		return errMarketsNode
+	}
+	return sm.Index.StorageDropSector(ctx, storageID, s, ft)
+}
+
+func (sm *StorageMinerAPI) StorageFindSector(ctx context.Context, s abi.SectorID, ft storiface.SectorFileType, ssize abi.SectorSize, allowFetch bool) ([]stores.SectorStorageInfo, error) {
+	if sm.Index == nil {
+		return nil, errMarketsNode
+	}
+	return sm.Index.StorageFindSector(ctx, s, ft, ssize, allowFetch)
+}
+
+func (sm *StorageMinerAPI) StorageBestAlloc(ctx context.Context, allocate storiface.SectorFileType, ssize abi.SectorSize, pathType storiface.PathType) ([]stores.StorageInfo, error) {
+	if sm.Index == nil {
+		return nil, errMarketsNode
+	}
+	return sm.Index.StorageBestAlloc(ctx, allocate, ssize, pathType)
+}
+
+func (sm *StorageMinerAPI) StorageLock(ctx context.Context, sector abi.SectorID, read storiface.SectorFileType, write storiface.SectorFileType) error {
+	if sm.Index == nil {
+		return errMarketsNode
+	}
+	return sm.Index.StorageLock(ctx, sector, read, write)
+}
+
+func (sm *StorageMinerAPI) StorageTryLock(ctx context.Context, sector abi.SectorID, read storiface.SectorFileType, write storiface.SectorFileType) (bool, error) {
+	if sm.Index == nil {
+		return false, errMarketsNode
+	}
+	return sm.Index.StorageTryLock(ctx, sector, read, write)
+}
+
+func (sm *StorageMinerAPI) ReturnAddPiece(ctx context.Context, callID storiface.CallID, pi abi.PieceInfo, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnAddPiece(ctx, callID, pi, err)
+}
+
+func (sm *StorageMinerAPI) ReturnSealPreCommit1(ctx context.Context, callID storiface.CallID, p1o storage.PreCommit1Out, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnSealPreCommit1(ctx, callID, p1o, err)
+}
+
+func (sm *StorageMinerAPI) ReturnSealPreCommit2(ctx context.Context, callID storiface.CallID, sealed storage.SectorCids, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnSealPreCommit2(ctx, callID, sealed, err)
+}
+
+func (sm *StorageMinerAPI) ReturnSealCommit1(ctx context.Context, callID storiface.CallID, out storage.Commit1Out, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnSealCommit1(ctx, callID, out, err)
+}
+
+func (sm *StorageMinerAPI) ReturnSealCommit2(ctx context.Context, callID storiface.CallID, proof storage.Proof, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnSealCommit2(ctx, callID, proof, err)
+}
+
+func (sm *StorageMinerAPI) ReturnFinalizeSector(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnFinalizeSector(ctx, callID, err)
+}
+
+func (sm *StorageMinerAPI) ReturnReleaseUnsealed(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnReleaseUnsealed(ctx, callID, err)
+}
+
+func (sm *StorageMinerAPI) ReturnMoveStorage(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnMoveStorage(ctx, callID, err)
+}
+
+func (sm *StorageMinerAPI) ReturnUnsealPiece(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnUnsealPiece(ctx, callID, err)
+}
+
+func (sm *StorageMinerAPI) ReturnReadPiece(ctx context.Context, callID storiface.CallID, ok bool, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnReadPiece(ctx, callID, ok, err)
+}
+
+func (sm *StorageMinerAPI) ReturnFetch(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnFetch(ctx, callID, err)
+}
+
+func (sm *StorageMinerAPI) ReturnRegenerateCache(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnRegenerateCache(ctx, callID, err)
+}
+
+func (sm *StorageMinerAPI) ReturnGenerateWinningPoSt(ctx context.Context, callID storiface.CallID, proofs []proof2.PoStProof, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnGenerateWinningPoSt(ctx, callID, proofs, err)
+}
+
+func (sm *StorageMinerAPI) ReturnGenerateWindowPoSt(ctx context.Context, callID storiface.CallID, res storiface.WindowPoStResult, err *storiface.CallError) error {
+	if sm.WorkerReturn == nil {
+		return errMarketsNode
+	}
+	return sm.WorkerReturn.ReturnGenerateWindowPoSt(ctx, callID, res, err)
+}
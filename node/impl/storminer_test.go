@@ -0,0 +1,85 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	tutils "github.com/filecoin-project/specs-actors/v2/support/testing"
+
+	lminer "github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+func testSectorInfo(state sealing.SectorState, commR string, deals ...abi.DealID) sealing.SectorInfo {
+	si := sealing.SectorInfo{State: state, SectorNumber: 1}
+	if commR != "" {
+		c := tutils.MakeCID(commR, nil)
+		si.CommR = &c
+	}
+	for _, d := range deals {
+		si.Pieces = append(si.Pieces, sealing.Piece{DealInfo: &sealing.DealInfo{DealID: d}})
+	}
+	return si
+}
+
+func TestPrecommitDiscrepancies(t *testing.T) {
+	pci := &lminer.SectorPreCommitOnChainInfo{
+		Info: lminer.SectorPreCommitInfo{
+			SealedCID: tutils.MakeCID("sealed", nil),
+			DealIDs:   []abi.DealID{1, 2},
+		},
+	}
+
+	// consistent states
+	require.Empty(t, precommitDiscrepancies(testSectorInfo(sealing.PreCommit1, "", 1, 2), nil))
+	require.Empty(t, precommitDiscrepancies(testSectorInfo(sealing.PreCommitWait, "sealed", 1, 2), nil))
+	require.Empty(t, precommitDiscrepancies(testSectorInfo(sealing.WaitSeed, "sealed", 1, 2), pci))
+	require.Empty(t, precommitDiscrepancies(testSectorInfo(sealing.CommitFinalizeFailed, "sealed", 1, 2), pci))
+
+	require.Len(t, precommitDiscrepancies(testSectorInfo(sealing.PreCommit2, "", 1, 2), pci), 1)
+	require.Len(t, precommitDiscrepancies(testSectorInfo(sealing.Committing, "sealed", 1, 2), nil), 1)
+	require.Len(t, precommitDiscrepancies(testSectorInfo(sealing.CommitFinalizeFailed, "sealed", 1, 2), nil), 1)
+	require.Len(t, precommitDiscrepancies(testSectorInfo(sealing.Proving, "sealed", 1, 2), nil), 1)
+
+	// mismatching sealed CID and deals
+	require.Len(t, precommitDiscrepancies(testSectorInfo(sealing.WaitSeed, "other", 1, 2), pci), 1)
+	require.Len(t, precommitDiscrepancies(testSectorInfo(sealing.WaitSeed, "sealed", 2, 1), pci), 1)
+	require.Len(t, precommitDiscrepancies(testSectorInfo(sealing.WaitSeed, "other", 1), pci), 2)
+}
+
+func TestProvenDiscrepancies(t *testing.T) {
+	onChain := &lminer.SectorOnChainInfo{
+		SealedCID: tutils.MakeCID("sealed", nil),
+		DealIDs:   []abi.DealID{3},
+	}
+
+	require.Empty(t, provenDiscrepancies(testSectorInfo(sealing.Proving, "sealed", 3), onChain, false))
+	require.Empty(t, provenDiscrepancies(testSectorInfo(sealing.CommitWait, "sealed", 3), onChain, false))
+	require.Empty(t, provenDiscrepancies(testSectorInfo(sealing.Faulty, "sealed", 3), onChain, true))
+
+	for _, st := range []sealing.SectorState{sealing.WaitSeed, sealing.CommitFinalize, sealing.CommitFinalizeFailed, sealing.SubmitCommit} {
+		require.Len(t, provenDiscrepancies(testSectorInfo(st, "sealed", 3), onChain, false), 1, st)
+	}
+
+	require.Len(t, provenDiscrepancies(testSectorInfo(sealing.Removed, "sealed", 3), onChain, false), 1)
+	require.Len(t, provenDiscrepancies(testSectorInfo(sealing.FaultReported, "sealed", 3), onChain, false), 1)
+	require.Len(t, provenDiscrepancies(testSectorInfo(sealing.Proving, "other"), onChain, false), 2)
+}
+
+func TestCheckDealData(t *testing.T) {
+	deals := []storagemarket.MinerDeal{
+		{ProposalCid: tutils.MakeCID("one", nil)},
+		{ProposalCid: tutils.MakeCID("two", nil)},
+	}
+	deals[1].Proposal.PieceSize = 2048
+
+	max := int64(abi.PaddedPieceSize(2048).Unpadded())
+
+	require.NoError(t, checkDealData(deals, deals[1].ProposalCid, max))
+	require.NoError(t, checkDealData(deals, deals[1].ProposalCid, 10))
+	require.Error(t, checkDealData(deals, deals[1].ProposalCid, max+1))
+	require.Error(t, checkDealData(deals, tutils.MakeCID("three", nil), 10))
+}
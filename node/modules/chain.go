@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/ipfs/go-bitswap"
 	"github.com/ipfs/go-bitswap/network"
 	"github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
 	"github.com/ipld/go-car"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/routing"
@@ -17,12 +20,16 @@ import (
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/lotus/journal"
 
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/beacon"
+	"github.com/filecoin-project/lotus/chain/epochtasks"
 	"github.com/filecoin-project/lotus/chain/exchange"
 	"github.com/filecoin-project/lotus/chain/gen/slashfilter"
 	"github.com/filecoin-project/lotus/chain/messagepool"
@@ -31,8 +38,12 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
 	"github.com/filecoin-project/lotus/lib/blockstore"
+	"github.com/filecoin-project/lotus/lib/blockstore/splitstore"
 	"github.com/filecoin-project/lotus/lib/bufbstore"
+	"github.com/filecoin-project/lotus/lib/subsystems"
 	"github.com/filecoin-project/lotus/lib/timedbs"
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/impl/full"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -62,13 +73,33 @@ func ChainBitswap(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, rt r
 	return exch
 }
 
-func MessagePool(lc fx.Lifecycle, sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS, nn dtypes.NetworkName, j journal.Journal) (*messagepool.MessagePool, error) {
+func MessagePool(lc fx.Lifecycle, sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS, nn dtypes.NetworkName, j journal.Journal, subs *subsystems.Registry) (*messagepool.MessagePool, error) {
 	mpp := messagepool.NewProvider(sm, ps)
 	mp, err := messagepool.New(mpp, ds, nn, j)
 	if err != nil {
 		return nil, xerrors.Errorf("constructing mpool: %w", err)
 	}
+
+	err = subs.Register(subsystems.Subsystem{
+		Name:        "mpool-publish",
+		Description: "publishing local messages to the network; messages pushed while it's stopped are published once it's started",
+		Start: func(context.Context) error {
+			mp.SetPublishing(true)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			mp.SetPublishing(false)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return subs.Start(ctx, "mpool-publish")
+		},
 		OnStop: func(_ context.Context) error {
 			return mp.Close()
 		},
@@ -76,7 +107,35 @@ func MessagePool(lc fx.Lifecycle, sm *stmgr.StateManager, ps *pubsub.PubSub, ds
 	return mp, nil
 }
 
-func ChainRawBlockstore(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedRepo) (dtypes.ChainRawBlockstore, error) {
+// SetMpoolPriority prioritizes the addresses from the node config on top of
+// the mpool config, without persisting them, so they're dropped again when
+// they're removed from the node config
+func SetMpoolPriority(mc config.MpoolConfig) func(mp *messagepool.MessagePool) error {
+	return func(mp *messagepool.MessagePool) error {
+		addrs := make([]address.Address, 0, len(mc.PriorityAddrs))
+		for _, s := range mc.PriorityAddrs {
+			addr, err := address.NewFromString(s)
+			if err != nil {
+				return xerrors.Errorf("parsing mpool priority address %q: %w", s, err)
+			}
+			addrs = append(addrs, addr)
+		}
+
+		mp.SetPriorityOverride(addrs, mc.PriorityLocal)
+		return nil
+	}
+}
+
+func ChainRawBlockstore(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedRepo, ds dtypes.MetadataDS) (dtypes.ChainRawBlockstore, error) {
+	// the recent chain is only in the hot store of the splitstore
+	used, err := splitstore.Used(ds)
+	if err != nil {
+		return nil, xerrors.Errorf("checking splitstore use: %w", err)
+	}
+	if used {
+		return nil, xerrors.New("the splitstore was enabled, the recent chain is only in its hot store; Chainstore.EnableSplitstore can't be disabled again")
+	}
+
 	bs, err := r.Blockstore(repo.BlockstoreChain)
 	if err != nil {
 		return nil, err
@@ -91,6 +150,70 @@ func ChainRawBlockstore(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedR
 	return cbs, nil
 }
 
+// SplitStore creates the splitstore over the hot blockstore and the chain
+// blockstore, which becomes its cold store
+func SplitStore(cfg *config.Chainstore) func(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedRepo, ds dtypes.MetadataDS) (*splitstore.SplitStore, error) {
+	return func(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedRepo, ds dtypes.MetadataDS) (*splitstore.SplitStore, error) {
+		cold, err := r.Blockstore(repo.BlockstoreChain)
+		if err != nil {
+			return nil, err
+		}
+
+		hotbs, err := r.Blockstore(repo.BlockstoreHot)
+		if err != nil {
+			return nil, xerrors.Errorf("opening hot blockstore: %w", err)
+		}
+
+		// the cache is under the splitstore, so that objects which
+		// compactions delete from the hot store are deleted from the cache
+		// too, and are stored again when they are written again
+		hot, err := blockstore.CachedBlockstore(helpers.LifecycleCtx(mctx, lc), hotbs, blockstore.DefaultCacheOpts())
+		if err != nil {
+			return nil, err
+		}
+
+		ss, err := splitstore.New(hot, cold, ds, splitstore.Config{
+			HotRetention:       abi.ChainEpoch(cfg.Splitstore.HotStoreRetention),
+			CompactionInterval: abi.ChainEpoch(cfg.Splitstore.CompactionInterval),
+			Discard:            cfg.Splitstore.DiscardColdBlocks,
+			GC: func(ctx context.Context, writes sync.Locker) (int64, int64, error) {
+				return r.CollectBlockstoreGarbage(ctx, repo.BlockstoreHot, writes)
+			},
+			MarkSetPath: filepath.Join(r.Path(), "datastore", "splitstore-mark"),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				return ss.Close()
+			},
+		})
+
+		return ss, nil
+	}
+}
+
+func SplitChainRawBlockstore(ss *splitstore.SplitStore) dtypes.ChainRawBlockstore {
+	return ss
+}
+
+// StartSplitstore starts the compactions of the splitstore, which follows the
+// head of the chain
+func StartSplitstore(lc fx.Lifecycle, cs *store.ChainStore, ss *splitstore.SplitStore) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			if err := ss.Start(cs); err != nil {
+				return xerrors.Errorf("starting splitstore: %w", err)
+			}
+
+			cs.SubscribeHeadChanges(ss.HeadChange)
+			return nil
+		},
+	})
+}
+
 func ChainBlockService(bs dtypes.ChainRawBlockstore, rem dtypes.ChainBitswap) dtypes.ChainBlockService {
 	return blockservice.New(bs, rem)
 }
@@ -247,3 +370,24 @@ func NewSyncer(params SyncerParams) (*chain.Syncer, error) {
 func NewSlashFilter(ds dtypes.MetadataDS) *slashfilter.SlashFilter {
 	return slashfilter.New(ds)
 }
+
+// epochTaskAPI provides the epoch task scheduler with the state and mpool
+// methods of the full node API
+type epochTaskAPI struct {
+	full.StateModuleAPI
+	*full.MpoolAPI
+}
+
+func EpochTaskScheduler(mctx helpers.MetricsCtx, lc fx.Lifecycle, ds dtypes.MetadataDS, cs *store.ChainStore, state full.StateModuleAPI, mpool full.MpoolAPI, subs *subsystems.Registry) (*epochtasks.Scheduler, error) {
+	s, err := epochtasks.New(&epochTaskAPI{StateModuleAPI: state, MpoolAPI: &mpool}, namespace.Wrap(ds, datastore.NewKey("/epoch-tasks")))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := helpers.LifecycleCtx(mctx, lc)
+	err = runSubsystemLoop(ctx, lc, subs, "epoch-tasks", "running tasks scheduled at chain epochs", func(ctx context.Context) {
+		s.Run(ctx, cs.SubHeadChanges(ctx))
+	})
+
+	return s, err
+}
@@ -30,11 +30,14 @@ import (
 	"github.com/ipfs/go-datastore/namespace"
 	"github.com/libp2p/go-libp2p-core/host"
 
+	"github.com/filecoin-project/lotus/bus"
 	"github.com/filecoin-project/lotus/chain/market"
 	"github.com/filecoin-project/lotus/journal"
 	"github.com/filecoin-project/lotus/lib/blockstore"
 	"github.com/filecoin-project/lotus/markets"
+	"github.com/filecoin-project/lotus/markets/delegation"
 	marketevents "github.com/filecoin-project/lotus/markets/loggers"
+	"github.com/filecoin-project/lotus/markets/providerstats"
 	"github.com/filecoin-project/lotus/markets/retrievaladapter"
 	"github.com/filecoin-project/lotus/node/impl/full"
 	payapi "github.com/filecoin-project/lotus/node/impl/paych"
@@ -153,12 +156,24 @@ func NewClientGraphsyncDataTransfer(lc fx.Lifecycle, h host.Host, gs dtypes.Grap
 	return dt, nil
 }
 
+// ClientDealAllowances creates the store tracking deal allowances given to
+// delegate keys by the client
+func ClientDealAllowances(ds dtypes.MetadataDS) *delegation.Store {
+	return delegation.NewStore(namespace.Wrap(ds, datastore.NewKey("/client/allowances")))
+}
+
+// ClientProviderStats creates the store of retrievals used to score storage
+// providers
+func ClientProviderStats(ds dtypes.MetadataDS) *providerstats.Store {
+	return providerstats.NewStore(namespace.Wrap(ds, datastore.NewKey("/client/providerstats")))
+}
+
 // NewClientDatastore creates a datastore for the client to store its deals
 func NewClientDatastore(ds dtypes.MetadataDS) dtypes.ClientDatastore {
 	return namespace.Wrap(ds, datastore.NewKey("/deals/client"))
 }
 
-func StorageClient(lc fx.Lifecycle, h host.Host, ibs dtypes.ClientBlockstore, mds dtypes.ClientMultiDstore, r repo.LockedRepo, dataTransfer dtypes.ClientDataTransfer, discovery *discoveryimpl.Local, deals dtypes.ClientDatastore, scn storagemarket.StorageClientNode, j journal.Journal) (storagemarket.StorageClient, error) {
+func StorageClient(lc fx.Lifecycle, h host.Host, ibs dtypes.ClientBlockstore, mds dtypes.ClientMultiDstore, r repo.LockedRepo, dataTransfer dtypes.ClientDataTransfer, discovery *discoveryimpl.Local, deals dtypes.ClientDatastore, scn storagemarket.StorageClientNode, j journal.Journal, b bus.Bus) (storagemarket.StorageClient, error) {
 	// go-fil-markets protocol retries:
 	// 1s, 5s, 25s, 2m5s, 5m x 11 ~= 1 hour
 	marketsRetryParams := smnet.RetryParameters(time.Second, 5*time.Minute, 15, 5)
@@ -175,6 +190,7 @@ func StorageClient(lc fx.Lifecycle, h host.Host, ibs dtypes.ClientBlockstore, md
 
 			evtType := j.RegisterEventType("markets/storage/client", "state_change")
 			c.SubscribeToEvents(markets.StorageClientJournaler(j, evtType))
+			c.SubscribeToEvents(markets.StorageClientBusEmitter(b))
 
 			return c.Start(ctx)
 		},
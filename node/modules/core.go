@@ -42,6 +42,8 @@ const (
 const (
 	JWTSecretName   = "auth-jwt-private" //nolint:gosec
 	KTJwtHmacSecret = "jwt-hmac-secret"  //nolint:gosec
+
+	HTTPRetrievalSecretName = "http-retrieval-jwt-private" //nolint:gosec
 )
 
 var (
@@ -7,4 +7,7 @@ import (
 
 type APIAlg jwt.HMACSHA
 
+// HTTPRetrievalAlg signs the tokens of the HTTP retrieval transport
+type HTTPRetrievalAlg jwt.HMACSHA
+
 type APIEndpoint multiaddr.Multiaddr
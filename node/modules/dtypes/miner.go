@@ -17,6 +17,10 @@ import (
 type MinerAddress address.Address
 type MinerID abi.ActorID
 
+// HTTPRetrievalURL is the public URL of the HTTP retrieval transport, offered
+// to clients in retrieval query responses. Empty when it isn't served.
+type HTTPRetrievalURL string
+
 // ConsiderOnlineStorageDealsConfigFunc is a function which reads from miner
 // config to determine if the user has disabled storage deals (or not).
 type ConsiderOnlineStorageDealsConfigFunc func() (bool, error)
@@ -88,5 +92,9 @@ type SetExpectedSealDurationFunc func(time.Duration) error
 // too determine how long sealing is expected to take
 type GetExpectedSealDurationFunc func() (time.Duration, error)
 
+// SetTransferLimitsConfigFunc saves the bandwidth limits of data transfers,
+// in bytes per second, to the config
+type SetTransferLimitsConfigFunc func(perTransfer, total uint64) error
+
 type StorageDealFilter func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error)
 type RetrievalDealFilter func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error)
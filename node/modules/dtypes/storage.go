@@ -37,6 +37,13 @@ type ClientRequestValidator *requestvalidation.UnifiedRequestValidator
 type ClientDatastore datastore.Batching
 type ClientRetrievalStoreManager retrievalstoremgr.RetrievalStoreManager
 
+// ClientRetrievalPaymentConfig caps the payment interval and interval increase
+// the client proposes in retrieval deals. Zero values leave the order unchanged.
+type ClientRetrievalPaymentConfig struct {
+	MaxPaymentInterval         uint64
+	MaxPaymentIntervalIncrease uint64
+}
+
 type Graphsync graphsync.GraphExchange
 
 // ClientDataTransfer is a data transfer manager for the client
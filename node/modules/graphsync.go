@@ -1,6 +1,11 @@
 package modules
 
 import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/lotus/markets/transferlimit"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -45,3 +50,68 @@ func Graphsync(parallelTransfers uint64) func(mctx helpers.MetricsCtx, lc fx.Lif
 		return gs, nil
 	}
 }
+
+// TransferLimiter creates the limiter throttling the data transfers of the
+// storage and retrieval markets, with the limits in the config
+func TransferLimiter(cfg config.DealmakingConfig) func() *transferlimit.Limiter {
+	return func() *transferlimit.Limiter {
+		return transferlimit.New(cfg.TransferRateLimit, cfg.TotalTransferRateLimit)
+	}
+}
+
+// transferKey identifies a graphsync request in the transfer limiter
+type transferKey struct {
+	p        peer.ID
+	id       graphsync.RequestID
+	incoming bool
+}
+
+// HandleTransferLimits pauses the requests and responses of the staging
+// graphsync, used for deal and retrieval data, for as long as they have to wait
+// to stay within the limits. The hooks run on the graphsync message loops, so
+// they must not block.
+func HandleTransferLimits(lc fx.Lifecycle, gs dtypes.StagingGraphsync, l *transferlimit.Limiter) {
+	var unregister []graphsync.UnregisterHookFunc
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			unregister = append(unregister,
+				gs.RegisterIncomingBlockHook(func(p peer.ID, responseData graphsync.ResponseData, blockData graphsync.BlockData, hookActions graphsync.IncomingBlockHookActions) {
+					id := responseData.RequestID()
+					d := l.Delay(transferKey{p: p, id: id, incoming: true}, blockData.BlockSizeOnWire())
+					if d <= 0 {
+						return
+					}
+
+					hookActions.PauseRequest()
+					time.AfterFunc(d, func() {
+						if err := gs.UnpauseRequest(id); err != nil {
+							log.Debugw("resuming throttled graphsync request", "peer", p, "request", id, "error", err)
+						}
+					})
+				}),
+				gs.RegisterOutgoingBlockHook(func(p peer.ID, requestData graphsync.RequestData, blockData graphsync.BlockData, hookActions graphsync.OutgoingBlockHookActions) {
+					id := requestData.ID()
+					d := l.Delay(transferKey{p: p, id: id}, blockData.BlockSizeOnWire())
+					if d <= 0 {
+						return
+					}
+
+					hookActions.PauseResponse()
+					time.AfterFunc(d, func() {
+						if err := gs.UnpauseResponse(p, id); err != nil {
+							log.Debugw("resuming throttled graphsync response", "peer", p, "request", id, "error", err)
+						}
+					})
+				}),
+			)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			for _, u := range unregister {
+				u()
+			}
+			return nil
+		},
+	})
+}
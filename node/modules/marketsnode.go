@@ -0,0 +1,79 @@
+package modules
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/client"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"github.com/filecoin-project/lotus/markets/retrievaladapter"
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+	"github.com/filecoin-project/lotus/node/repo"
+	"github.com/filecoin-project/lotus/storage/sectorblocks"
+)
+
+// SealingNode is the sealing node a miner node running only the markets
+// subsystem hands deal data to
+type SealingNode struct {
+	lapi.StorageMiner
+
+	// unsealed pieces are read from PieceURL/{sector}/{offset}/{size}
+	PieceURL    string
+	PieceHeader http.Header
+}
+
+// ConnectSealingNode connects to the sealing node configured in
+// MarketsNode.SealerApiInfo
+func ConnectSealingNode(mctx helpers.MetricsCtx, lc fx.Lifecycle, r repo.LockedRepo) (*SealingNode, error) {
+	var apiInfo string
+	if err := readCfg(r, func(cfg *config.StorageMiner) {
+		apiInfo = cfg.MarketsNode.SealerApiInfo
+	}); err != nil {
+		return nil, xerrors.Errorf("reading config: %w", err)
+	}
+	if apiInfo == "" {
+		return nil, xerrors.New("MarketsNode.SealerApiInfo must be set to run the markets subsystem separately")
+	}
+
+	info := cliutil.ParseApiInfo(apiInfo)
+	addr, err := info.DialArgs()
+	if err != nil {
+		return nil, xerrors.Errorf("parsing sealing node API info: %w", err)
+	}
+	host, err := info.Host()
+	if err != nil {
+		return nil, xerrors.Errorf("parsing sealing node API info: %w", err)
+	}
+
+	sealer, closer, err := client.NewStorageMinerRPC(helpers.LifecycleCtx(mctx, lc), addr, info.AuthHeader())
+	if err != nil {
+		return nil, xerrors.Errorf("connecting to the sealing node: %w", err)
+	}
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			closer()
+			return nil
+		},
+	})
+
+	return &SealingNode{
+		StorageMiner: sealer,
+		PieceURL:     "http://" + host + "/piece",
+		PieceHeader:  info.AuthHeader(),
+	}, nil
+}
+
+func RemoteSectorBuilder(sn *SealingNode) sectorblocks.SectorBuilder {
+	return sectorblocks.NewRemoteBuilder(sn)
+}
+
+func RemoteRetrievalProviderNode(sn *SealingNode, full lapi.FullNode) retrievalmarket.RetrievalProviderNode {
+	return retrievaladapter.NewRemoteRetrievalProviderNode(sn.PieceURL, sn.PieceHeader, full)
+}
@@ -0,0 +1,125 @@
+package modules
+
+import (
+	"bytes"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
+	"github.com/filecoin-project/lotus/extern/sector-storage/mock"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+)
+
+// publicNetworks are the names of public networks, which must never accept
+// mock proofs
+var publicNetworks = map[dtypes.NetworkName]struct{}{
+	"testnetnet":     {},
+	"calibrationnet": {},
+	"nerpanet":       {},
+	"butterflynet":   {},
+}
+
+// publicGenesis are the genesis blocks of public networks
+var publicGenesis = map[string]struct{}{
+	// mainnet
+	"bafy2bzacecnamqgqmifpluoeldx7zzglxcljo6oja4vrmtj7432rphldpdmm2": {},
+}
+
+// checkMockProofsNetwork refuses to use mock proofs on a chain with the
+// genesis block, or the name, of a public network. The genesis built into the
+// binary is always a public network genesis.
+func checkMockProofsNetwork(genesis cid.Cid, netName dtypes.NetworkName) error {
+	_, public := publicGenesis[genesis.String()]
+	if !public {
+		if gb := build.MaybeGenesis(); gb != nil {
+			cr, err := car.NewCarReader(bytes.NewReader(gb))
+			if err != nil {
+				return xerrors.Errorf("reading built-in genesis: %w", err)
+			}
+			public = len(cr.Header.Roots) == 1 && cr.Header.Roots[0] == genesis
+		}
+	}
+
+	if _, ok := publicNetworks[netName]; ok || public {
+		return xerrors.Errorf("mock proofs are configured, but the chain (network %s, genesis %s) is a public network, refusing to start", netName, genesis)
+	}
+
+	log.Warnw("USING MOCK PROOFS, proofs are not generated nor verified, only use this on private networks", "network", netName, "genesis", genesis)
+	return nil
+}
+
+// CheckMockProofs makes sure the chain of a full node using mock proofs isn't
+// a public network
+func CheckMockProofs(mctx helpers.MetricsCtx, lc fx.Lifecycle, cs *store.ChainStore, sm *stmgr.StateManager, _ dtypes.AfterGenesisSet) error {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+
+	gen, err := cs.GetGenesis()
+	if err != nil {
+		return xerrors.Errorf("getting genesis block: %w", err)
+	}
+
+	netName, err := stmgr.GetNetworkName(ctx, sm, cs.GetHeaviestTipSet().ParentState())
+	if err != nil {
+		return xerrors.Errorf("getting network name: %w", err)
+	}
+
+	return checkMockProofsNetwork(gen.Cid(), netName)
+}
+
+// CheckMinerMockProofs makes sure the chain of a miner using mock proofs isn't
+// a public network
+func CheckMinerMockProofs(mctx helpers.MetricsCtx, lc fx.Lifecycle, fapi lapi.FullNode) error {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+
+	gen, err := fapi.ChainGetGenesis(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting genesis block: %w", err)
+	}
+
+	netName, err := fapi.StateNetworkName(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting network name: %w", err)
+	}
+
+	return checkMockProofsNetwork(gen.Cids()[0], netName)
+}
+
+// MockSectorMgr creates a sector manager which seals sectors and generates
+// proofs without doing any work. Sectors of the miner on chain are known to it,
+// so that they can still be proven after a restart; sectors which were being
+// sealed are lost on restart.
+func MockSectorMgr(mctx helpers.MetricsCtx, lc fx.Lifecycle, fapi lapi.FullNode, maddr dtypes.MinerAddress) (sectorstorage.SectorManager, error) {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+
+	mid, err := address.IDFromAddress(address.Address(maddr))
+	if err != nil {
+		return nil, err
+	}
+
+	onChain, err := fapi.StateMinerSectors(ctx, address.Address(maddr), nil, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner sectors: %w", err)
+	}
+
+	sectors := make([]abi.SectorID, len(onChain))
+	for i, s := range onChain {
+		sectors[i] = abi.SectorID{
+			Miner:  abi.ActorID(mid),
+			Number: s.SectorNumber,
+		}
+	}
+
+	return mock.NewMockSectorMgr(sectors), nil
+}
@@ -20,6 +20,7 @@ import (
 	discoveryimpl "github.com/filecoin-project/go-fil-markets/discovery/impl"
 
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/bus"
 	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/beacon/drand"
@@ -188,6 +189,21 @@ func HandleIncomingMessages(mctx helpers.MetricsCtx, lc fx.Lifecycle, ps *pubsub
 	waitForSync(stmgr, pubsubMsgsSyncEpochs, subscribe)
 }
 
+// EmitHeadChanges publishes head changes of the chain on the event bus
+func EmitHeadChanges(cs *store.ChainStore, b bus.Bus) {
+	emit := bus.Emitter(b, new(bus.EvtHeadChange))
+
+	cs.SubscribeHeadChanges(func(rev, app []*types.TipSet) error {
+		for _, ts := range rev {
+			emit(bus.EvtHeadChange{Type: store.HCRevert, Height: ts.Height(), TipSet: ts.Key()})
+		}
+		for _, ts := range app {
+			emit(bus.EvtHeadChange{Type: store.HCApply, Height: ts.Height(), TipSet: ts.Key()})
+		}
+		return nil
+	})
+}
+
 func NewLocalDiscovery(lc fx.Lifecycle, ds dtypes.MetadataDS) (*discoveryimpl.Local, error) {
 	local, err := discoveryimpl.NewLocal(namespace.Wrap(ds, datastore.NewKey("/deals/local")))
 	if err != nil {
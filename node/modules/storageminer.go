@@ -3,13 +3,19 @@ package modules
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/docker/go-units"
+	"github.com/gbrlsnchs/jwt/v3"
 	"go.uber.org/fx"
 	"go.uber.org/multierr"
 	"golang.org/x/xerrors"
@@ -26,12 +32,16 @@ import (
 	"github.com/ipfs/go-merkledag"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/routing"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 
 	"github.com/filecoin-project/go-address"
+	datatransfer "github.com/filecoin-project/go-data-transfer"
 	dtimpl "github.com/filecoin-project/go-data-transfer/impl"
 	dtnet "github.com/filecoin-project/go-data-transfer/network"
 	dtgstransport "github.com/filecoin-project/go-data-transfer/transport/graphsync"
 	piecefilestore "github.com/filecoin-project/go-fil-markets/filestore"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
 	piecestoreimpl "github.com/filecoin-project/go-fil-markets/piecestore/impl"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	retrievalimpl "github.com/filecoin-project/go-fil-markets/retrievalmarket/impl"
@@ -45,6 +55,7 @@ import (
 	"github.com/filecoin-project/go-multistore"
 	paramfetch "github.com/filecoin-project/go-paramfetch"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-statestore"
 	"github.com/filecoin-project/go-storedcounter"
 
@@ -52,26 +63,37 @@ import (
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
 	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
 
 	lapi "github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/bus"
 	"github.com/filecoin-project/lotus/chain/actors/builtin"
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/gen/slashfilter"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/journal"
 	"github.com/filecoin-project/lotus/lib/blockstore"
+	"github.com/filecoin-project/lotus/lib/subsystems"
 	"github.com/filecoin-project/lotus/markets"
+	"github.com/filecoin-project/lotus/markets/askrefresh"
+	"github.com/filecoin-project/lotus/markets/clientquota"
+	"github.com/filecoin-project/lotus/markets/dealfilter"
+	"github.com/filecoin-project/lotus/markets/indexer"
 	marketevents "github.com/filecoin-project/lotus/markets/loggers"
-	"github.com/filecoin-project/lotus/markets/retrievaladapter"
+	"github.com/filecoin-project/lotus/markets/pieceindex"
+	"github.com/filecoin-project/lotus/markets/transports"
+	"github.com/filecoin-project/lotus/markets/utils"
+	"github.com/filecoin-project/lotus/metrics"
 	"github.com/filecoin-project/lotus/miner"
 	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
 	"github.com/filecoin-project/lotus/storage"
+	"github.com/filecoin-project/lotus/storage/slareport"
 )
 
 var StorageCounterDSPrefix = "/storage/nextid"
@@ -183,13 +205,23 @@ type StorageMinerParams struct {
 	Host               host.Host
 	MetadataDS         dtypes.MetadataDS
 	Sealer             sectorstorage.SectorManager
+	SealerConfig       sectorstorage.SealerConfig
 	SectorIDCounter    sealing.SectorIDCounter
 	Verifier           ffiwrapper.Verifier
 	GetSealingConfigFn dtypes.GetSealingConfigFunc
 	Journal            journal.Journal
+	Bus                bus.Bus
 	AddrSel            *storage.AddressSelector
+	Subsystems         *subsystems.Registry
 }
 
+// Subsystems of the miner which can be stopped and started over the API
+const (
+	SubsystemSealingInput   = "sealing-input"
+	SubsystemStorageDeals   = "storage-deals"
+	SubsystemRetrievalDeals = "retrieval-deals"
+)
+
 func StorageMiner(fc config.MinerFeeConfig) func(params StorageMinerParams) (*storage.Miner, error) {
 	return func(params StorageMinerParams) (*storage.Miner, error) {
 		var (
@@ -218,15 +250,54 @@ func StorageMiner(fc config.MinerFeeConfig) func(params StorageMinerParams) (*st
 			return nil, err
 		}
 
-		sm, err := storage.NewMiner(api, maddr, h, ds, sealer, sc, verif, gsd, fc, j, as)
+		if lead := params.SealerConfig.PoStIODeferEpochs; lead > 0 {
+			if gate, ok := sealer.(storage.PoStIOGate); ok {
+				fps.DeferSealingIO(gate, lead)
+			}
+		}
+
+		fps.SetProvingLimits(params.SealerConfig.PoStMaxPartitionsPerMessage, params.SealerConfig.PoStParallelProofs, params.SealerConfig.PoStProofRetries)
+
+		if lead := params.SealerConfig.PoStPrecheckEpochs; lead > 0 {
+			fps.PrecheckDeadlines(lead)
+		}
+
+		if err := fps.SetCheckSampleRate(params.SealerConfig.PoStCheckSampleRate); err != nil {
+			return nil, err
+		}
+
+		if params.SealerConfig.PoStDisableRecoveryDeclarations {
+			fps.DisableRecoveryDeclarations()
+		}
+
+		sm, err := storage.NewMiner(api, maddr, h, ds, sealer, sc, verif, gsd, fc, j, params.Bus, as, fps)
+		if err != nil {
+			return nil, err
+		}
+
+		err = params.Subsystems.Register(subsystems.Subsystem{
+			Name:        SubsystemSealingInput,
+			Description: "accepting new pieces and pledges into sectors; deals wait for it to be started again",
+			Start: func(context.Context) error {
+				sm.SetSealingInput(true)
+				return nil
+			},
+			Stop: func(context.Context) error {
+				sm.SetSealingInput(false)
+				return nil
+			},
+		})
 		if err != nil {
 			return nil, err
 		}
 
 		lc.Append(fx.Hook{
-			OnStart: func(context.Context) error {
+			OnStart: func(sctx context.Context) error {
 				go fps.Run(ctx)
-				return sm.Run(ctx)
+				if err := sm.Run(ctx); err != nil {
+					return err
+				}
+				return params.Subsystems.Start(sctx, SubsystemSealingInput)
 			},
 			OnStop: sm.Stop,
 		})
@@ -253,7 +324,226 @@ func HandleRetrieval(host host.Host, lc fx.Lifecycle, m retrievalmarket.Retrieva
 	})
 }
 
-func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h storagemarket.StorageProvider, j journal.Journal) {
+// SetRetrievalPaymentInterval applies the retrieval payment interval settings
+// from the dealmaking config to the retrieval ask. Settings left at zero keep
+// the value currently in the ask.
+func SetRetrievalPaymentInterval(cfg config.DealmakingConfig) func(m retrievalmarket.RetrievalProvider) {
+	return func(m retrievalmarket.RetrievalProvider) {
+		ask := m.GetAsk()
+		if ask == nil {
+			ask = &retrievalmarket.Ask{
+				PricePerByte:            retrievalmarket.DefaultPricePerByte,
+				UnsealPrice:             retrievalmarket.DefaultUnsealPrice,
+				PaymentInterval:         retrievalmarket.DefaultPaymentInterval,
+				PaymentIntervalIncrease: retrievalmarket.DefaultPaymentIntervalIncrease,
+			}
+		}
+
+		if cfg.RetrievalPaymentInterval != 0 {
+			ask.PaymentInterval = cfg.RetrievalPaymentInterval
+		}
+		if cfg.RetrievalPaymentIntervalIncrease != 0 {
+			ask.PaymentIntervalIncrease = cfg.RetrievalPaymentIntervalIncrease
+		}
+
+		m.SetAsk(ask)
+	}
+}
+
+func ClientQuotas(cfg config.DealmakingConfig) func() (*clientquota.Quotas, error) {
+	return func() (*clientquota.Quotas, error) {
+		return clientquota.New(cfg)
+	}
+}
+
+// SetClientQuotaDeals lets client quotas count the deals tracked by the storage
+// provider, which can't be passed in at construction as the provider depends
+// on the deal filter enforcing the quotas
+func SetClientQuotaDeals(q *clientquota.Quotas, h storagemarket.StorageProvider) {
+	q.SetDealSource(h.ListLocalDeals)
+}
+
+// HandleContentAdvertisements publishes advertisements of the payload CIDs of
+// deals stored by the provider to a content indexer
+func HandleContentAdvertisements(endpoint string) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host, maddr dtypes.MinerAddress, ds dtypes.MetadataDS, sp storagemarket.StorageProvider, subs *subsystems.Registry) error {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host, maddr dtypes.MinerAddress, ds dtypes.MetadataDS, sp storagemarket.StorageProvider, subs *subsystems.Registry) error {
+		addrs := func() []string {
+			var out []string
+			for _, a := range h.Addrs() {
+				out = append(out, a.String())
+			}
+			return out
+		}
+
+		p := indexer.New(endpoint, h.ID(), address.Address(maddr), addrs, namespace.Wrap(ds, datastore.NewKey("/indexer")))
+		sp.SubscribeToEvents(p.OnDealEvent)
+
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		return runSubsystemLoop(ctx, lc, subs, "content-advertisements", "publishing deal payload advertisements to the content indexer", func(ctx context.Context) {
+			p.Run(ctx, sp.ListLocalDeals)
+		})
+	}
+}
+
+// HTTPRetrievalSecret returns the secret the tokens of the HTTP retrieval
+// transport are signed with, generating it on first use. It's separate from
+// the API secret, so that transport tokens can't be used with the API.
+func HTTPRetrievalSecret(keystore types.KeyStore) (*dtypes.HTTPRetrievalAlg, error) {
+	key, err := keystore.Get(HTTPRetrievalSecretName)
+	if errors.Is(err, types.ErrKeyInfoNotFound) {
+		log.Info("generating new HTTP retrieval token secret")
+
+		sk, err := ioutil.ReadAll(io.LimitReader(rand.Reader, 32))
+		if err != nil {
+			return nil, err
+		}
+
+		key = types.KeyInfo{
+			Type:       KTJwtHmacSecret,
+			PrivateKey: sk,
+		}
+
+		if err := keystore.Put(HTTPRetrievalSecretName, key); err != nil {
+			return nil, xerrors.Errorf("writing HTTP retrieval token secret: %w", err)
+		}
+	} else if err != nil {
+		return nil, xerrors.Errorf("getting HTTP retrieval token secret: %w", err)
+	}
+
+	return (*dtypes.HTTPRetrievalAlg)(jwt.NewHS256(key.PrivateKey)), nil
+}
+
+// HandleRetrievalTransports serves the HTTP retrieval transport when
+// HTTPRetrievalListen is set. It's offered to clients by the retrieval
+// provider, see RetrievalProvider.
+func HandleRetrievalTransports(cfg config.DealmakingConfig) func(lc fx.Lifecycle, ps dtypes.ProviderPieceStore, rpn retrievalmarket.RetrievalProviderNode, rp retrievalmarket.RetrievalProvider, filter dtypes.RetrievalDealFilter, subs *subsystems.Registry, alg *dtypes.HTTPRetrievalAlg) error {
+	return func(lc fx.Lifecycle, ps dtypes.ProviderPieceStore, rpn retrievalmarket.RetrievalProviderNode, rp retrievalmarket.RetrievalProvider, filter dtypes.RetrievalDealFilter, subs *subsystems.Registry, alg *dtypes.HTTPRetrievalAlg) error {
+		if cfg.HTTPRetrievalListen == "" {
+			return nil
+		}
+		if cfg.HTTPRetrievalURL == "" {
+			return xerrors.New("HTTPRetrievalURL must be set to offer the HTTP retrieval transport")
+		}
+
+		enabled := func() bool {
+			return subs.Running(SubsystemRetrievalDeals)
+		}
+		authorize := func(token string) error {
+			return transports.VerifyToken(token, (*jwt.HMACSHA)(alg))
+		}
+
+		srv := &http.Server{
+			Handler: transports.NewHTTPHandler(ps, rpn, enabled, authorize, rp.GetAsk, filter),
+		}
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				lst, err := net.Listen("tcp", cfg.HTTPRetrievalListen)
+				if err != nil {
+					return xerrors.Errorf("listening for HTTP retrievals: %w", err)
+				}
+
+				go func() {
+					if err := srv.Serve(lst); err != http.ErrServerClosed {
+						log.Errorf("serving HTTP retrievals: %s", err)
+					}
+				}()
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				return srv.Shutdown(ctx)
+			},
+		})
+
+		return nil
+	}
+}
+
+// runSubsystemLoop registers the loop as a subsystem which can be stopped and
+// started over the API, and starts it with the node
+func runSubsystemLoop(ctx context.Context, lc fx.Lifecycle, subs *subsystems.Registry, name, description string, run func(ctx context.Context)) error {
+	if err := subs.Register(subsystems.Loop(ctx, name, description, nil, run)); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(sctx context.Context) error {
+			return subs.Start(sctx, name)
+		},
+	})
+
+	return nil
+}
+
+// StoragePathHealthEvt is the journal event recorded when a storage path
+// becomes unhealthy, or recovers
+type StoragePathHealthEvt struct {
+	ID      stores.ID
+	Healthy bool
+	Error   string
+}
+
+// StorageHealthAlerts records storage path health reports as metrics, and
+// health changes in the journal
+func StorageHealthAlerts(mctx helpers.MetricsCtx, j journal.Journal, idx *stores.Index) {
+	evtType := j.RegisterEventType("storage", "path_health")
+
+	idx.AddHealthNotifee(func(id stores.ID, report stores.HealthReport, changed bool) {
+		ctx, err := tag.New(mctx, tag.Upsert(metrics.StorageID, string(id)))
+		if err != nil {
+			log.Errorf("creating storage health metrics context: %+v", err)
+			return
+		}
+
+		var healthy int64
+		if report.Err == "" {
+			healthy = 1
+		}
+
+		stats.Record(ctx,
+			metrics.StorageHealthy.M(healthy),
+			metrics.StorageProbeReadLatency.M(float64(report.ReadLatency)/float64(time.Millisecond)),
+			metrics.StorageProbeWriteLatency.M(float64(report.WriteLatency)/float64(time.Millisecond)))
+
+		if changed {
+			j.RecordEvent(evtType, func() interface{} {
+				return &StoragePathHealthEvt{
+					ID:      id,
+					Healthy: report.Err == "",
+					Error:   report.Err,
+				}
+			})
+		}
+	})
+}
+
+// SealingTaskMetrics records the durations of sealing tasks finished on
+// workers as metrics
+func SealingTaskMetrics(mctx helpers.MetricsCtx, sm sectorstorage.SectorManager) {
+	m, ok := sm.(*sectorstorage.Manager)
+	if !ok {
+		// mock sector manager
+		return
+	}
+
+	m.AddTaskDoneNotifee(func(job storiface.WorkerJob, hostname string, took time.Duration, failed bool) {
+		if failed {
+			return
+		}
+
+		ctx, err := tag.New(mctx,
+			tag.Upsert(metrics.TaskType, string(job.Task)),
+			tag.Upsert(metrics.WorkerHost, hostname))
+		if err != nil {
+			log.Errorf("creating sealing task metrics context: %+v", err)
+			return
+		}
+
+		stats.Record(ctx, metrics.SealingTaskDuration.M(took.Seconds()))
+	})
+}
+
+func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h storagemarket.StorageProvider, j journal.Journal, b bus.Bus) {
 	ctx := helpers.LifecycleCtx(mctx, lc)
 	h.OnReady(marketevents.ReadyLogger("storage provider"))
 	lc.Append(fx.Hook{
@@ -262,6 +552,7 @@ func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h sto
 
 			evtType := j.RegisterEventType("markets/storage/provider", "state_change")
 			h.SubscribeToEvents(markets.StorageProviderJournaler(j, evtType))
+			h.SubscribeToEvents(markets.StorageProviderBusEmitter(b))
 
 			return h.Start(ctx)
 		},
@@ -312,7 +603,7 @@ func HandleMigrateProviderFunds(lc fx.Lifecycle, ds dtypes.MetadataDS, node api.
 
 // NewProviderDAGServiceDataTransfer returns a data transfer manager that just
 // uses the provider's Staging DAG service for transfers
-func NewProviderDAGServiceDataTransfer(lc fx.Lifecycle, h host.Host, gs dtypes.StagingGraphsync, ds dtypes.MetadataDS, r repo.LockedRepo) (dtypes.ProviderDataTransfer, error) {
+func NewProviderDAGServiceDataTransfer(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host, gs dtypes.StagingGraphsync, ds dtypes.MetadataDS, r repo.LockedRepo) (dtypes.ProviderDataTransfer, error) {
 	sc := storedcounter.New(ds, datastore.NewKey("/datatransfer/provider/counter"))
 	net := dtnet.NewFromLibp2pHost(h)
 
@@ -329,6 +620,12 @@ func NewProviderDAGServiceDataTransfer(lc fx.Lifecycle, h host.Host, gs dtypes.S
 	}
 
 	dt.OnReady(marketevents.ReadyLogger("provider data transfer"))
+	dt.OnReady(func(err error) {
+		if err != nil {
+			return
+		}
+		go utils.RestartProviderTransfers(helpers.LifecycleCtx(mctx, lc), dt, h.ID())
+	})
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			dt.SubscribeToEvents(marketevents.DataTransferLogger)
@@ -357,6 +654,10 @@ func NewProviderPieceStore(lc fx.Lifecycle, ds dtypes.MetadataDS) (dtypes.Provid
 	return ps, nil
 }
 
+func PieceIndex(ps dtypes.ProviderPieceStore, rpn retrievalmarket.RetrievalProviderNode) *pieceindex.Index {
+	return pieceindex.New(ps, rpn)
+}
+
 func StagingMultiDatastore(lc fx.Lifecycle, r repo.LockedRepo) (dtypes.StagingMultiDstore, error) {
 	ds, err := r.Datastore("/staging")
 	if err != nil {
@@ -459,22 +760,155 @@ func NewStorageAsk(ctx helpers.MetricsCtx, fapi lapi.FullNode, ds dtypes.Metadat
 		storagemarket.MaxPieceSize(abi.PaddedPieceSize(mi.SectorSize)))
 }
 
-func BasicDealFilter(user dtypes.StorageDealFilter) func(onlineOk dtypes.ConsiderOnlineStorageDealsConfigFunc,
+// StorageAskRefresher keeps the storage ask from expiring, records the history
+// of signed asks, and publishes commitments to new asks when enabled
+func StorageAskRefresher(cfg config.DealmakingConfig, fees config.MinerFeeConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, ds dtypes.MetadataDS, fapi lapi.FullNode, maddr dtypes.MinerAddress, sa *storedask.StoredAsk, subs *subsystems.Registry) (*askrefresh.Refresher, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, ds dtypes.MetadataDS, fapi lapi.FullNode, maddr dtypes.MinerAddress, sa *storedask.StoredAsk, subs *subsystems.Registry) (*askrefresh.Refresher, error) {
+		head := func(ctx context.Context) (abi.ChainEpoch, error) {
+			ts, err := fapi.ChainHead(ctx)
+			if err != nil {
+				return 0, err
+			}
+			return ts.Height(), nil
+		}
+
+		var publish askrefresh.PublishFunc
+		if cfg.PublishAskCommitment {
+			publish = func(ctx context.Context, commitment []byte) (cid.Cid, error) {
+				mi, err := fapi.StateMinerInfo(ctx, address.Address(maddr), types.EmptyTSK)
+				if err != nil {
+					return cid.Undef, xerrors.Errorf("getting miner info: %w", err)
+				}
+
+				// a plain send to the miner actor, the commitment in the params is
+				// only recorded on chain
+				sm, err := fapi.MpoolPushMessage(ctx, &types.Message{
+					To:     address.Address(maddr),
+					From:   mi.Worker,
+					Value:  big.Zero(),
+					Method: builtin.MethodSend,
+					Params: commitment,
+				}, &lapi.MessageSendSpec{MaxFee: abi.TokenAmount(fees.MaxAskCommitmentFee)})
+				if err != nil {
+					return cid.Undef, err
+				}
+
+				return sm.Cid(), nil
+			}
+		}
+
+		r := askrefresh.New(sa, namespace.Wrap(ds, datastore.NewKey("/deals/provider/storage-ask/history")), head, publish)
+
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		err := runSubsystemLoop(ctx, lc, subs, "ask-refresh", "refreshing the storage ask before it expires", func(ctx context.Context) {
+			r.Run(ctx, time.Duration(cfg.AskRefreshInterval))
+		})
+
+		return r, err
+	}
+}
+
+// RegisterDealSubsystems registers the subsystems gating new storage and
+// retrieval deals. The miner is required so that sealing input, which storage
+// deals depend on, is started first.
+func RegisterDealSubsystems(lc fx.Lifecycle, subs *subsystems.Registry, _ *storage.Miner) error {
+	return registerDealSubsystems(lc, subs, []string{SubsystemSealingInput})
+}
+
+// RegisterMarketsDealSubsystems registers the deal subsystems of a node
+// running only the markets subsystem, where sealing input is controlled on
+// the sealing node
+func RegisterMarketsDealSubsystems(lc fx.Lifecycle, subs *subsystems.Registry) error {
+	return registerDealSubsystems(lc, subs, nil)
+}
+
+func registerDealSubsystems(lc fx.Lifecycle, subs *subsystems.Registry, storageDeps []string) error {
+	deals := []subsystems.Subsystem{
+		{
+			Name:        SubsystemStorageDeals,
+			Description: "accepting new storage deal proposals",
+			DependsOn:   storageDeps,
+		},
+		{
+			Name:        SubsystemRetrievalDeals,
+			Description: "accepting new retrieval deal proposals",
+		},
+	}
+
+	for _, s := range deals {
+		if err := subs.Register(s); err != nil {
+			return err
+		}
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			for _, s := range deals {
+				if err := subs.Start(ctx, s.Name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// SLAReporter generates signed SLA reports periodically, and keeps their
+// history
+func SLAReporter(cfg config.MinerSLAReportConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, ds dtypes.MetadataDS, fapi lapi.FullNode, maddr dtypes.MinerAddress, sp storagemarket.StorageProvider, rp retrievalmarket.RetrievalProvider, subs *subsystems.Registry) (*slareport.Reporter, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, ds dtypes.MetadataDS, fapi lapi.FullNode, maddr dtypes.MinerAddress, sp storagemarket.StorageProvider, rp retrievalmarket.RetrievalProvider, subs *subsystems.Registry) (*slareport.Reporter, error) {
+		retrievals := func() []retrievalmarket.ProviderDealState {
+			var out []retrievalmarket.ProviderDealState
+			for _, d := range rp.ListDeals() {
+				out = append(out, d)
+			}
+			return out
+		}
+
+		r := slareport.New(fapi, address.Address(maddr), namespace.Wrap(ds, datastore.NewKey("/sla-reports")), sp.ListLocalDeals, retrievals)
+
+		if cfg.Interval == 0 {
+			return r, nil
+		}
+
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		err := runSubsystemLoop(ctx, lc, subs, "sla-reports", "generating periodic SLA reports", func(ctx context.Context) {
+			r.Run(ctx, time.Duration(cfg.Interval))
+		})
+
+		return r, err
+	}
+}
+
+func BasicDealFilter(user dealfilter.StorageFilter) func(onlineOk dtypes.ConsiderOnlineStorageDealsConfigFunc,
 	offlineOk dtypes.ConsiderOfflineStorageDealsConfigFunc,
 	verifiedOk dtypes.ConsiderVerifiedStorageDealsConfigFunc,
 	unverifiedOk dtypes.ConsiderUnverifiedStorageDealsConfigFunc,
 	blocklistFunc dtypes.StorageDealPieceCidBlocklistConfigFunc,
 	expectedSealTimeFunc dtypes.GetExpectedSealDurationFunc,
-	spn storagemarket.StorageProviderNode) dtypes.StorageDealFilter {
+	quotas *clientquota.Quotas,
+	spn storagemarket.StorageProviderNode,
+	overrides *dealfilter.OverrideStore,
+	subs *subsystems.Registry) dtypes.StorageDealFilter {
 	return func(onlineOk dtypes.ConsiderOnlineStorageDealsConfigFunc,
 		offlineOk dtypes.ConsiderOfflineStorageDealsConfigFunc,
 		verifiedOk dtypes.ConsiderVerifiedStorageDealsConfigFunc,
 		unverifiedOk dtypes.ConsiderUnverifiedStorageDealsConfigFunc,
 		blocklistFunc dtypes.StorageDealPieceCidBlocklistConfigFunc,
 		expectedSealTimeFunc dtypes.GetExpectedSealDurationFunc,
-		spn storagemarket.StorageProviderNode) dtypes.StorageDealFilter {
+		quotas *clientquota.Quotas,
+		spn storagemarket.StorageProviderNode,
+		overrides *dealfilter.OverrideStore,
+		subs *subsystems.Registry) dtypes.StorageDealFilter {
 
 		return func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+			if !subs.Running(SubsystemStorageDeals) {
+				log.Warnf("storage deals subsystem stopped; rejecting storage deal proposal from client: %s", deal.Client.String())
+				return false, "miner is not accepting storage deals at the moment", nil
+			}
+
 			b, err := onlineOk()
 			if err != nil {
 				return false, "miner error", err
@@ -550,8 +984,23 @@ func BasicDealFilter(user dtypes.StorageDealFilter) func(onlineOk dtypes.Conside
 				return false, fmt.Sprintf("deal start epoch is too far in the future: %s > %s", deal.Proposal.StartEpoch, maxStartEpoch), nil
 			}
 
+			if ok, reason, err := quotas.CheckDeal(deal); !ok || err != nil {
+				return ok, reason, err
+			}
+
 			if user != nil {
-				return user(ctx, deal)
+				ok, reason, o, err := user(ctx, deal)
+				if !ok || err != nil {
+					return ok, reason, err
+				}
+
+				if !o.IsEmpty() {
+					if err := overrides.Put(deal.ProposalCid, o); err != nil {
+						return false, "miner error", xerrors.Errorf("storing deal filter overrides: %w", err)
+					}
+				}
+
+				return true, "", nil
 			}
 
 			return true, "", nil
@@ -559,6 +1008,29 @@ func BasicDealFilter(user dtypes.StorageDealFilter) func(onlineOk dtypes.Conside
 	}
 }
 
+// DealFilterOverrides creates the store of the overrides returned by the deal
+// filter for accepted deals
+func DealFilterOverrides(ds dtypes.MetadataDS) *dealfilter.OverrideStore {
+	return dealfilter.NewOverrideStore(namespace.Wrap(ds, datastore.NewKey("/deals/provider/overrides")))
+}
+
+// HandleDealFilterOverrides removes the overrides of deals which finish before
+// their data is added to a sector, which OnDealComplete never sees
+func HandleDealFilterOverrides(lc fx.Lifecycle, overrides *dealfilter.OverrideStore, sp storagemarket.StorageProvider) {
+	sp.SubscribeToEvents(overrides.OnDealEvent)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			deals, err := sp.ListLocalDeals()
+			if err != nil {
+				return xerrors.Errorf("listing deals: %w", err)
+			}
+
+			return overrides.Prune(deals)
+		},
+	})
+}
+
 func StorageProvider(minerAddress dtypes.MinerAddress,
 	storedAsk *storedask.StoredAsk,
 	h host.Host, ds dtypes.MetadataDS,
@@ -580,11 +1052,18 @@ func StorageProvider(minerAddress dtypes.MinerAddress,
 	return storageimpl.NewProvider(net, namespace.Wrap(ds, datastore.NewKey("/deals/provider")), store, mds, pieceStore, dataTransfer, spn, address.Address(minerAddress), storedAsk, opt)
 }
 
-func RetrievalDealFilter(userFilter dtypes.RetrievalDealFilter) func(onlineOk dtypes.ConsiderOnlineRetrievalDealsConfigFunc,
-	offlineOk dtypes.ConsiderOfflineRetrievalDealsConfigFunc) dtypes.RetrievalDealFilter {
+func RetrievalDealFilter(userFilter dealfilter.RetrievalFilter) func(onlineOk dtypes.ConsiderOnlineRetrievalDealsConfigFunc,
+	offlineOk dtypes.ConsiderOfflineRetrievalDealsConfigFunc, subs *subsystems.Registry,
+	pieceStore dtypes.ProviderPieceStore, dt dtypes.ProviderDataTransfer) dtypes.RetrievalDealFilter {
 	return func(onlineOk dtypes.ConsiderOnlineRetrievalDealsConfigFunc,
-		offlineOk dtypes.ConsiderOfflineRetrievalDealsConfigFunc) dtypes.RetrievalDealFilter {
+		offlineOk dtypes.ConsiderOfflineRetrievalDealsConfigFunc, subs *subsystems.Registry,
+		pieceStore dtypes.ProviderPieceStore, dt dtypes.ProviderDataTransfer) dtypes.RetrievalDealFilter {
 		return func(ctx context.Context, state retrievalmarket.ProviderDealState) (bool, string, error) {
+			if !subs.Running(SubsystemRetrievalDeals) {
+				log.Warn("retrieval deals subsystem stopped; rejecting retrieval deal proposal from client")
+				return false, "miner is not accepting retrieval deals at the moment", nil
+			}
+
 			b, err := onlineOk()
 			if err != nil {
 				return false, "miner error", err
@@ -605,7 +1084,11 @@ func RetrievalDealFilter(userFilter dtypes.RetrievalDealFilter) func(onlineOk dt
 			}
 
 			if userFilter != nil {
-				return userFilter(ctx, state)
+				info, err := retrievalDealInfo(ctx, state, pieceStore, dt)
+				if err != nil {
+					return false, "miner error", err
+				}
+				return userFilter(ctx, state, info)
 			}
 
 			return true, "", nil
@@ -613,11 +1096,50 @@ func RetrievalDealFilter(userFilter dtypes.RetrievalDealFilter) func(onlineOk dt
 	}
 }
 
+func retrievalDealInfo(ctx context.Context, state retrievalmarket.ProviderDealState, pieceStore piecestore.PieceStore, dt datatransfer.Manager) (dealfilter.RetrievalDealInfo, error) {
+	var info dealfilter.RetrievalDealInfo
+
+	pieceCid := state.PieceCID
+	if pieceCid == nil {
+		ci, err := pieceStore.GetCIDInfo(state.PayloadCID)
+		if err != nil && !xerrors.Is(err, retrievalmarket.ErrNotFound) {
+			return info, xerrors.Errorf("getting payload info: %w", err)
+		}
+		if err == nil && len(ci.PieceBlockLocations) > 0 {
+			pieceCid = &ci.PieceBlockLocations[0].PieceCID
+		}
+	}
+
+	if pieceCid != nil {
+		pi, err := pieceStore.GetPieceInfo(*pieceCid)
+		if err != nil && !xerrors.Is(err, retrievalmarket.ErrNotFound) {
+			return info, xerrors.Errorf("getting piece info: %w", err)
+		}
+		if err == nil && len(pi.Deals) > 0 {
+			info.PieceSize = pi.Deals[0].Length
+		}
+	}
+
+	channels, err := dt.InProgressChannels(ctx)
+	if err != nil {
+		return info, xerrors.Errorf("listing data transfers: %w", err)
+	}
+
+	for _, ch := range channels {
+		info.OngoingTransfers++
+		// the provider side of a retrieval is a pull request from the client
+		if ch.IsPull() {
+			info.OngoingRetrievals++
+		}
+	}
+
+	return info, nil
+}
+
 // RetrievalProvider creates a new retrieval provider attached to the provider blockstore
 func RetrievalProvider(h host.Host,
-	miner *storage.Miner,
-	sealer sectorstorage.SectorManager,
-	full lapi.FullNode,
+	adapter retrievalmarket.RetrievalProviderNode,
+	maddr dtypes.MinerAddress,
 	ds dtypes.MetadataDS,
 	pieceStore dtypes.ProviderPieceStore,
 	mds dtypes.StagingMultiDstore,
@@ -625,18 +1147,13 @@ func RetrievalProvider(h host.Host,
 	onlineOk dtypes.ConsiderOnlineRetrievalDealsConfigFunc,
 	offlineOk dtypes.ConsiderOfflineRetrievalDealsConfigFunc,
 	userFilter dtypes.RetrievalDealFilter,
+	httpURL dtypes.HTTPRetrievalURL,
 ) (retrievalmarket.RetrievalProvider, error) {
-	adapter := retrievaladapter.NewRetrievalProviderNode(miner, sealer, full)
-
-	maddr, err := minerAddrFromDS(ds)
-	if err != nil {
-		return nil, err
-	}
-
-	netwk := rmnet.NewFromLibp2pHost(h)
+	// the transports besides graphsync are offered in query responses
+	netwk := transports.NewNetwork(rmnet.NewFromLibp2pHost(h), transports.NewOffer(string(httpURL)))
 	opt := retrievalimpl.DealDeciderOpt(retrievalimpl.DealDecider(userFilter))
 
-	return retrievalimpl.NewProvider(maddr, adapter, netwk, pieceStore, mds, dt, namespace.Wrap(ds, datastore.NewKey("/retrievals/provider")), opt)
+	return retrievalimpl.NewProvider(address.Address(maddr), adapter, netwk, pieceStore, mds, dt, namespace.Wrap(ds, datastore.NewKey("/retrievals/provider")), opt)
 }
 
 var WorkerCallsPrefix = datastore.NewKey("/worker/calls")
@@ -805,6 +1322,24 @@ func NewSetSealConfigFunc(r repo.LockedRepo) (dtypes.SetSealingConfigFunc, error
 				MaxSealingSectors:         cfg.MaxSealingSectors,
 				MaxSealingSectorsForDeals: cfg.MaxSealingSectorsForDeals,
 				WaitDealsDelay:            config.Duration(cfg.WaitDealsDelay),
+				MaxPendingPieces:          cfg.MaxPendingPieces,
+				PendingPieceTimeout:       config.Duration(cfg.PendingPieceTimeout),
+				PieceLeaseTimeout:         config.Duration(cfg.PieceLeaseTimeout),
+				RemoveExpiredSectors:      cfg.RemoveExpiredSectors,
+				SectorReplicas:            cfg.SectorReplicas,
+				ManageUnsealedCopies:      cfg.ManageUnsealedCopies,
+				FinalizeEarly:             cfg.FinalizeEarly,
+				ExclusiveLabels:           cfg.ExclusiveLabels,
+				DedicatedLabels:           cfg.DedicatedLabels,
+
+				RetryPolicy:        toRetryPolicyConfig(cfg.RetryPolicy),
+				StateRetryPolicies: map[string]config.RetryPolicy{},
+			}
+			for _, rule := range cfg.WaitDealsDelays {
+				c.Sealing.WaitDealsDelays = append(c.Sealing.WaitDealsDelays, toWaitDealsDelayConfig(rule))
+			}
+			for st, p := range cfg.StateRetryPolicies {
+				c.Sealing.StateRetryPolicies[st] = toRetryPolicyConfig(p)
 			}
 		})
 		return
@@ -813,18 +1348,91 @@ func NewSetSealConfigFunc(r repo.LockedRepo) (dtypes.SetSealingConfigFunc, error
 
 func NewGetSealConfigFunc(r repo.LockedRepo) (dtypes.GetSealingConfigFunc, error) {
 	return func() (out sealiface.Config, err error) {
+		var ruleErr error
 		err = readCfg(r, func(cfg *config.StorageMiner) {
 			out = sealiface.Config{
 				MaxWaitDealsSectors:       cfg.Sealing.MaxWaitDealsSectors,
 				MaxSealingSectors:         cfg.Sealing.MaxSealingSectors,
 				MaxSealingSectorsForDeals: cfg.Sealing.MaxSealingSectorsForDeals,
 				WaitDealsDelay:            time.Duration(cfg.Sealing.WaitDealsDelay),
+				MaxPendingPieces:          cfg.Sealing.MaxPendingPieces,
+				PendingPieceTimeout:       time.Duration(cfg.Sealing.PendingPieceTimeout),
+				PieceLeaseTimeout:         time.Duration(cfg.Sealing.PieceLeaseTimeout),
+				RemoveExpiredSectors:      cfg.Sealing.RemoveExpiredSectors,
+				SectorReplicas:            cfg.Sealing.SectorReplicas,
+				ManageUnsealedCopies:      cfg.Sealing.ManageUnsealedCopies,
+				FinalizeEarly:             cfg.Sealing.FinalizeEarly,
+				ExclusiveLabels:           cfg.Sealing.ExclusiveLabels,
+				DedicatedLabels:           cfg.Sealing.DedicatedLabels,
+
+				RetryPolicy:        fromRetryPolicyConfig(cfg.Sealing.RetryPolicy),
+				StateRetryPolicies: map[string]sealiface.RetryPolicy{},
+			}
+			for st, p := range cfg.Sealing.StateRetryPolicies {
+				out.StateRetryPolicies[st] = fromRetryPolicyConfig(p)
+			}
+			for _, rc := range cfg.Sealing.WaitDealsDelays {
+				rule, err := fromWaitDealsDelayConfig(rc)
+				if err != nil {
+					ruleErr = err
+					return
+				}
+				out.WaitDealsDelays = append(out.WaitDealsDelays, rule)
 			}
 		})
+		if err == nil {
+			err = ruleErr
+		}
 		return
 	}, nil
 }
 
+func toWaitDealsDelayConfig(r sealiface.WaitDealsDelayRule) config.WaitDealsDelayRule {
+	out := config.WaitDealsDelayRule{
+		FirstPieceMinFill: r.FirstPieceMinFill,
+		Delay:             config.Duration(r.Delay),
+	}
+	if r.SectorSize != 0 {
+		out.SectorSize = units.BytesSize(float64(r.SectorSize))
+	}
+	return out
+}
+
+func fromWaitDealsDelayConfig(r config.WaitDealsDelayRule) (sealiface.WaitDealsDelayRule, error) {
+	out := sealiface.WaitDealsDelayRule{
+		FirstPieceMinFill: r.FirstPieceMinFill,
+		Delay:             time.Duration(r.Delay),
+	}
+	if r.SectorSize != "" {
+		ssize, err := units.RAMInBytes(r.SectorSize)
+		if err != nil {
+			return sealiface.WaitDealsDelayRule{}, xerrors.Errorf("parsing WaitDealsDelays sector size %q: %w", r.SectorSize, err)
+		}
+		out.SectorSize = abi.SectorSize(ssize)
+	}
+	return out, nil
+}
+
+func toRetryPolicyConfig(p sealiface.RetryPolicy) config.RetryPolicy {
+	return config.RetryPolicy{
+		MaxRetries:    p.MaxRetries,
+		MinBackoff:    config.Duration(p.MinBackoff),
+		MaxBackoff:    config.Duration(p.MaxBackoff),
+		BackoffFactor: p.BackoffFactor,
+		GiveUp:        p.GiveUp,
+	}
+}
+
+func fromRetryPolicyConfig(p config.RetryPolicy) sealiface.RetryPolicy {
+	return sealiface.RetryPolicy{
+		MaxRetries:    p.MaxRetries,
+		MinBackoff:    time.Duration(p.MinBackoff),
+		MaxBackoff:    time.Duration(p.MaxBackoff),
+		BackoffFactor: p.BackoffFactor,
+		GiveUp:        p.GiveUp,
+	}
+}
+
 func NewSetExpectedSealDurationFunc(r repo.LockedRepo) (dtypes.SetExpectedSealDurationFunc, error) {
 	return func(delay time.Duration) (err error) {
 		err = mutateCfg(r, func(cfg *config.StorageMiner) {
@@ -843,6 +1451,108 @@ func NewGetExpectedSealDurationFunc(r repo.LockedRepo) (dtypes.GetExpectedSealDu
 	}, nil
 }
 
+func NewSetTransferLimitsConfigFunc(r repo.LockedRepo) (dtypes.SetTransferLimitsConfigFunc, error) {
+	return func(perTransfer, total uint64) (err error) {
+		err = mutateCfg(r, func(cfg *config.StorageMiner) {
+			cfg.Dealmaking.TransferRateLimit = perTransfer
+			cfg.Dealmaking.TotalTransferRateLimit = total
+		})
+		return
+	}, nil
+}
+
+func NewSetFundsPolicyFunc(r repo.LockedRepo) (storage.SetFundsPolicyFunc, error) {
+	return func(p lapi.FundsPolicy) (err error) {
+		err = mutateCfg(r, func(c *config.StorageMiner) {
+			c.Funds = config.MinerFundsConfig{
+				CheckInterval: config.Duration(p.CheckInterval),
+				TargetBalance: types.FIL(big.Zero()),
+				WithdrawAbove: types.FIL(big.Zero()),
+			}
+			if p.TargetBalance.Int != nil {
+				c.Funds.TargetBalance = types.FIL(p.TargetBalance)
+			}
+			if p.WithdrawAbove.Int != nil {
+				c.Funds.WithdrawAbove = types.FIL(p.WithdrawAbove)
+			}
+			if p.TopUpFrom != address.Undef {
+				c.Funds.TopUpFrom = p.TopUpFrom.String()
+			}
+			if p.Beneficiary != address.Undef {
+				c.Funds.Beneficiary = p.Beneficiary.String()
+			}
+		})
+		return
+	}, nil
+}
+
+func NewGetFundsPolicyFunc(r repo.LockedRepo) (storage.GetFundsPolicyFunc, error) {
+	return func() (out lapi.FundsPolicy, err error) {
+		var cfg config.MinerFundsConfig
+		if err := readCfg(r, func(c *config.StorageMiner) {
+			cfg = c.Funds
+		}); err != nil {
+			return lapi.FundsPolicy{}, err
+		}
+
+		out = lapi.FundsPolicy{
+			CheckInterval: time.Duration(cfg.CheckInterval),
+			TargetBalance: big.Zero(),
+			WithdrawAbove: big.Zero(),
+		}
+		if cfg.TargetBalance.Int != nil {
+			out.TargetBalance = abi.TokenAmount(cfg.TargetBalance)
+		}
+		if cfg.WithdrawAbove.Int != nil {
+			out.WithdrawAbove = abi.TokenAmount(cfg.WithdrawAbove)
+		}
+		if cfg.TopUpFrom != "" {
+			if out.TopUpFrom, err = address.NewFromString(cfg.TopUpFrom); err != nil {
+				return lapi.FundsPolicy{}, xerrors.Errorf("parsing top-up address: %w", err)
+			}
+		}
+		if cfg.Beneficiary != "" {
+			if out.Beneficiary, err = address.NewFromString(cfg.Beneficiary); err != nil {
+				return lapi.FundsPolicy{}, xerrors.Errorf("parsing beneficiary address: %w", err)
+			}
+		}
+
+		return out, nil
+	}, nil
+}
+
+// RunFundsManager starts the funds manager, which maintains the balance of the
+// miner actor as set by the funds policy
+func RunFundsManager(fc config.MinerFeeConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, fapi lapi.FullNode, maddr dtypes.MinerAddress, policy storage.GetFundsPolicyFunc, subs *subsystems.Registry) error {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, fapi lapi.FullNode, maddr dtypes.MinerAddress, policy storage.GetFundsPolicyFunc, subs *subsystems.Registry) error {
+		fm := storage.NewFundsManager(fapi, address.Address(maddr), abi.TokenAmount(fc.MaxFundsManagementFee), policy)
+
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		return runSubsystemLoop(ctx, lc, subs, "funds-manager", "maintaining the miner actor balance as set by the funds policy", fm.Run)
+	}
+}
+
+// RunFeeBumper starts replacing critical messages of the miner stuck in the
+// mpool with messages paying higher fees
+func RunFeeBumper(fc config.MinerFeeConfig) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, fapi lapi.FullNode, maddr dtypes.MinerAddress, subs *subsystems.Registry) error {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, fapi lapi.FullNode, maddr dtypes.MinerAddress, subs *subsystems.Registry) error {
+		if fc.BumpStuckMessagesInterval == 0 {
+			return nil
+		}
+
+		mult := big.NewIntUnsigned(fc.BumpMaxFeeMultiplier)
+		fb := storage.NewFeeBumper(fapi, address.Address(maddr),
+			big.Mul(abi.TokenAmount(fc.MaxWindowPoStGasFee), mult),
+			big.Mul(abi.TokenAmount(fc.MaxPreCommitGasFee), mult),
+			big.Mul(abi.TokenAmount(fc.MaxCommitGasFee), mult))
+
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		return runSubsystemLoop(ctx, lc, subs, "fee-bumper", "replacing stuck miner messages with messages paying higher fees", func(ctx context.Context) {
+			fb.Run(ctx, time.Duration(fc.BumpStuckMessagesInterval))
+		})
+	}
+}
+
 func readCfg(r repo.LockedRepo, accessor func(*config.StorageMiner)) error {
 	raw, err := r.Config()
 	if err != nil {
@@ -164,6 +164,20 @@ func TestWindowedPost(t *testing.T) {
 	test.TestWindowPost(t, builder.MockSbBuilder, 2*time.Millisecond, 10)
 }
 
+func TestWindowPostFaults(t *testing.T) {
+	if os.Getenv("LOTUS_TEST_WINDOW_POST") != "1" {
+		t.Skip("this takes a few minutes, set LOTUS_TEST_WINDOW_POST=1 to run")
+	}
+
+	logging.SetLogLevel("miner", "ERROR")
+	logging.SetLogLevel("chainstore", "ERROR")
+	logging.SetLogLevel("chain", "ERROR")
+	logging.SetLogLevel("sub", "ERROR")
+	logging.SetLogLevel("storageminer", "ERROR")
+
+	test.TestWindowPostFaults(t, builder.MockSbBuilder, 2*time.Millisecond, 4)
+}
+
 func TestTerminate(t *testing.T) {
 	if os.Getenv("LOTUS_TEST_WINDOW_POST") != "1" {
 		t.Skip("this takes a few minutes, set LOTUS_TEST_WINDOW_POST=1 to run")
@@ -5,7 +5,7 @@ import badgerbs "github.com/filecoin-project/lotus/lib/blockstore/badger"
 // BadgerBlockstoreOptions returns the badger options to apply for the provided
 // domain.
 func BadgerBlockstoreOptions(domain BlockstoreDomain, path string, readonly bool) (badgerbs.Options, error) {
-	if domain != BlockstoreChain {
+	if domain != BlockstoreChain && domain != BlockstoreHot {
 		return badgerbs.Options{}, ErrInvalidBlockstoreDomain
 	}
 
@@ -42,7 +42,8 @@ func BadgerBlockstoreOptions(domain BlockstoreDomain, path string, readonly bool
 	opts.MaxTableSize = 64 << 20
 
 	// NOTE: The chain blockstore doesn't require any GC (blocks are never
-	// deleted). This will change if we move to a tiered blockstore.
+	// deleted), unless it's the hot store of the splitstore, which blocks are
+	// removed from by compactions.
 
 	opts.ReadOnly = readonly
 
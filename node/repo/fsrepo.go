@@ -2,6 +2,7 @@ package repo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -261,9 +262,15 @@ type fsLockedRepo struct {
 	dsOnce sync.Once
 
 	bs     blockstore.Blockstore
+	bsRaw  *badgerbs.Blockstore
 	bsErr  error
 	bsOnce sync.Once
 
+	hotbs     blockstore.Blockstore
+	hotbsRaw  *badgerbs.Blockstore
+	hotbsErr  error
+	hotbsOnce sync.Once
+
 	storageLk sync.Mutex
 	configLk  sync.Mutex
 }
@@ -292,6 +299,11 @@ func (fsr *fsLockedRepo) Close() error {
 			return xerrors.Errorf("could not close blockstore: %w", err)
 		}
 	}
+	if c, ok := fsr.hotbs.(io.Closer); ok && c != nil {
+		if err := c.Close(); err != nil {
+			return xerrors.Errorf("could not close hot blockstore: %w", err)
+		}
+	}
 
 	err = fsr.closer.Close()
 	fsr.closer = nil
@@ -300,34 +312,54 @@ func (fsr *fsLockedRepo) Close() error {
 
 // Blockstore returns a blockstore for the provided data domain.
 func (fsr *fsLockedRepo) Blockstore(domain BlockstoreDomain) (blockstore.Blockstore, error) {
-	if domain != BlockstoreChain {
+	switch domain {
+	case BlockstoreChain:
+		fsr.bsOnce.Do(func() {
+			fsr.bsRaw, fsr.bsErr = fsr.openBlockstore(domain, filepath.Join(fsDatastore, "chain"))
+			if fsr.bsErr == nil {
+				fsr.bs = lblockstore.WrapIDStore(fsr.bsRaw)
+			}
+		})
+		return fsr.bs, fsr.bsErr
+	case BlockstoreHot:
+		fsr.hotbsOnce.Do(func() {
+			fsr.hotbsRaw, fsr.hotbsErr = fsr.openBlockstore(domain, filepath.Join(fsDatastore, "splitstore"))
+			if fsr.hotbsErr == nil {
+				fsr.hotbs = lblockstore.WrapIDStore(fsr.hotbsRaw)
+			}
+		})
+		return fsr.hotbs, fsr.hotbsErr
+	default:
 		return nil, ErrInvalidBlockstoreDomain
 	}
+}
 
-	fsr.bsOnce.Do(func() {
-		path := fsr.join(filepath.Join(fsDatastore, "chain"))
-		readonly := fsr.readonly
+func (fsr *fsLockedRepo) openBlockstore(domain BlockstoreDomain, dir string) (*badgerbs.Blockstore, error) {
+	path := fsr.join(dir)
+	readonly := fsr.readonly
 
-		if err := os.MkdirAll(path, 0755); err != nil {
-			fsr.bsErr = err
-			return
-		}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
 
-		opts, err := BadgerBlockstoreOptions(domain, path, readonly)
-		if err != nil {
-			fsr.bsErr = err
-			return
-		}
+	opts, err := BadgerBlockstoreOptions(domain, path, readonly)
+	if err != nil {
+		return nil, err
+	}
 
-		bs, err := badgerbs.Open(opts)
-		if err != nil {
-			fsr.bsErr = err
-			return
-		}
-		fsr.bs = lblockstore.WrapIDStore(bs)
-	})
+	return badgerbs.Open(opts)
+}
 
-	return fsr.bs, fsr.bsErr
+func (fsr *fsLockedRepo) CollectBlockstoreGarbage(ctx context.Context, domain BlockstoreDomain, writes sync.Locker) (before, after int64, err error) {
+	if _, err := fsr.Blockstore(domain); err != nil {
+		return 0, 0, err
+	}
+
+	bs := fsr.bsRaw
+	if domain == BlockstoreHot {
+		bs = fsr.hotbsRaw
+	}
+	return bs.CollectGarbage(ctx, writes)
 }
 
 // join joins path elements with fsr.path
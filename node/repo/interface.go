@@ -1,7 +1,9 @@
 package repo
 
 import (
+	"context"
 	"errors"
+	"sync"
 
 	"github.com/filecoin-project/lotus/lib/blockstore"
 	"github.com/ipfs/go-datastore"
@@ -22,6 +24,11 @@ const (
 	// well as state. In the future, they may get segregated into different
 	// domains.
 	BlockstoreChain = BlockstoreDomain("chain")
+
+	// BlockstoreHot represents the blockstore domain for the hot store of the
+	// splitstore, which holds the recent part of the chain data while
+	// BlockstoreChain holds the rest.
+	BlockstoreHot = BlockstoreDomain("hot")
 )
 
 var (
@@ -33,6 +40,11 @@ var (
 	// ErrInvalidBlockstoreDomain is returned by LockedRepo#Blockstore() when
 	// an unrecognized domain is requested.
 	ErrInvalidBlockstoreDomain = errors.New("invalid blockstore domain")
+
+	// ErrBlockstoreGCUnsupported is returned by
+	// LockedRepo#CollectBlockstoreGarbage() when the blockstore can't be
+	// garbage collected.
+	ErrBlockstoreGCUnsupported = errors.New("blockstore garbage collection not supported")
 )
 
 type Repo interface {
@@ -56,6 +68,12 @@ type LockedRepo interface {
 	// Blockstore returns an IPLD blockstore for the requested domain.
 	Blockstore(domain BlockstoreDomain) (blockstore.Blockstore, error)
 
+	// CollectBlockstoreGarbage reclaims the disk space taken by the blocks
+	// deleted from the blockstore of the domain, and returns its size before
+	// and after. writes, if not nil, is held while the collection can't run
+	// alongside writes to the blockstore.
+	CollectBlockstoreGarbage(ctx context.Context, domain BlockstoreDomain, writes sync.Locker) (before, after int64, err error)
+
 	// Returns config in this repo
 	Config() (interface{}, error)
 	SetConfig(func(interface{})) error
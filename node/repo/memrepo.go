@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -251,6 +252,10 @@ func (lmem *lockedMemRepo) Blockstore(domain BlockstoreDomain) (blockstore.Block
 	return lmem.mem.blockstore, nil
 }
 
+func (lmem *lockedMemRepo) CollectBlockstoreGarbage(ctx context.Context, domain BlockstoreDomain, writes sync.Locker) (before, after int64, err error) {
+	return 0, 0, ErrBlockstoreGCUnsupported
+}
+
 func (lmem *lockedMemRepo) ListDatastores(ns string) ([]int64, error) {
 	return nil, nil
 }
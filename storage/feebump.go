@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type feeBumperAPI interface {
+	ChainHead(context.Context) (*types.TipSet, error)
+	MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error)
+	MpoolPush(context.Context, *types.SignedMessage) (cid.Cid, error)
+	GasEstimateMessageGas(context.Context, *types.Message, *api.MessageSendSpec, types.TipSetKey) (*types.Message, error)
+	WalletSignMessage(context.Context, address.Address, *types.Message) (*types.SignedMessage, error)
+}
+
+// FeeBumper replaces critical messages of the miner (window PoSts, PreCommits
+// and ProveCommits) which are stuck in the mpool because their fee cap is
+// below the base fee, with messages paying higher fees, up to a max fee for
+// each kind of message.
+type FeeBumper struct {
+	api   feeBumperAPI
+	maddr address.Address
+	// max fee of replacement messages, by method of the miner actor; other
+	// messages aren't replaced
+	maxFees map[abi.MethodNum]abi.TokenAmount
+}
+
+func NewFeeBumper(api feeBumperAPI, maddr address.Address, maxPoStFee, maxPreCommitFee, maxCommitFee abi.TokenAmount) *FeeBumper {
+	return &FeeBumper{
+		api:   api,
+		maddr: maddr,
+		maxFees: map[abi.MethodNum]abi.TokenAmount{
+			miner.Methods.SubmitWindowedPoSt: maxPoStFee,
+			miner.Methods.PreCommitSector:    maxPreCommitFee,
+			miner.Methods.ProveCommitSector:  maxCommitFee,
+		},
+	}
+}
+
+// Run checks the mpool for stuck messages every interval until the context is
+// cancelled
+func (fb *FeeBumper) Run(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			if err := fb.check(ctx); err != nil {
+				log.Errorf("checking for stuck messages: %+v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (fb *FeeBumper) check(ctx context.Context) error {
+	ts, err := fb.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+	baseFee := ts.Blocks()[0].ParentBaseFee
+
+	pending, err := fb.api.MpoolPending(ctx, ts.Key())
+	if err != nil {
+		return xerrors.Errorf("getting pending messages: %w", err)
+	}
+
+	for _, sm := range pending {
+		msg := sm.Message
+		if msg.To != fb.maddr {
+			continue
+		}
+		maxFee, ok := fb.maxFees[msg.Method]
+		if !ok || !msg.GasFeeCap.LessThan(baseFee) {
+			continue
+		}
+
+		if err := fb.replace(ctx, sm, baseFee, maxFee); err != nil {
+			log.Errorw("replacing stuck message", "cid", sm.Cid(), "from", msg.From, "nonce", msg.Nonce, "method", msg.Method, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (fb *FeeBumper) replace(ctx context.Context, sm *types.SignedMessage, baseFee, maxFee abi.TokenAmount) error {
+	msg := sm.Message
+	spec := &api.MessageSendSpec{MaxFee: maxFee}
+
+	minRBF := messagepool.ComputeMinRBF(msg.GasPremium)
+
+	msg.GasFeeCap = big.Zero()
+	msg.GasPremium = big.Zero()
+	est, err := fb.api.GasEstimateMessageGas(ctx, &msg, spec, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("estimating gas: %w", err)
+	}
+
+	msg.GasPremium = big.Max(est.GasPremium, minRBF)
+	msg.GasFeeCap = big.Max(est.GasFeeCap, msg.GasPremium)
+
+	messagepool.CapGasFee(func() (abi.TokenAmount, error) {
+		return maxFee, nil
+	}, &msg, spec)
+
+	if msg.GasPremium.LessThan(minRBF) || !msg.GasFeeCap.GreaterThan(sm.Message.GasFeeCap) {
+		return xerrors.Errorf("can't bump fees, max fee %s reached", types.FIL(maxFee))
+	}
+
+	if msg.GasFeeCap.LessThan(baseFee) {
+		log.Warnw("replacing stuck message with fee cap still below the base fee, max fee reached", "cid", sm.Cid(), "feecap", msg.GasFeeCap, "basefee", baseFee)
+	}
+
+	smsg, err := fb.api.WalletSignMessage(ctx, msg.From, &msg)
+	if err != nil {
+		return xerrors.Errorf("signing replacement: %w", err)
+	}
+
+	if _, err := fb.api.MpoolPush(ctx, smsg); err != nil {
+		return xerrors.Errorf("pushing replacement: %w", err)
+	}
+
+	log.Warnw("replaced stuck message with bumped fees", "old", sm.Cid(), "new", smsg.Cid(), "nonce", msg.Nonce, "method", msg.Method,
+		"premium", msg.GasPremium, "feecap", msg.GasFeeCap, "basefee", baseFee)
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	tutils "github.com/filecoin-project/specs-actors/v2/support/testing"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type mockFeeBumperAPI struct {
+	ts      *types.TipSet
+	pending []*types.SignedMessage
+	pushed  []*types.SignedMessage
+}
+
+func (m *mockFeeBumperAPI) ChainHead(context.Context) (*types.TipSet, error) {
+	return m.ts, nil
+}
+
+func (m *mockFeeBumperAPI) MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error) {
+	return m.pending, nil
+}
+
+func (m *mockFeeBumperAPI) MpoolPush(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+	m.pushed = append(m.pushed, sm)
+	return sm.Cid(), nil
+}
+
+func (m *mockFeeBumperAPI) GasEstimateMessageGas(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec, tsk types.TipSetKey) (*types.Message, error) {
+	out := *msg
+	out.GasPremium = big.NewInt(100)
+	out.GasFeeCap = big.NewInt(2000)
+	return &out, nil
+}
+
+func (m *mockFeeBumperAPI) WalletSignMessage(ctx context.Context, a address.Address, msg *types.Message) (*types.SignedMessage, error) {
+	return &types.SignedMessage{
+		Message:   *msg,
+		Signature: crypto.Signature{Type: crypto.SigTypeBLS},
+	}, nil
+}
+
+func TestFeeBumper(t *testing.T) {
+	ctx := context.Background()
+
+	maddr := tutils.NewIDAddr(t, 1000)
+	from := tutils.NewIDAddr(t, 101)
+
+	ts := mockTipSet(t)
+	ts.Blocks()[0].ParentBaseFee = big.NewInt(1000)
+
+	msg := func(method abi.MethodNum, feeCap int64) *types.SignedMessage {
+		return &types.SignedMessage{
+			Message: types.Message{
+				To:         maddr,
+				From:       from,
+				Method:     method,
+				GasLimit:   1000,
+				GasFeeCap:  big.NewInt(feeCap),
+				GasPremium: big.NewInt(90),
+				Value:      big.Zero(),
+			},
+		}
+	}
+
+	fapi := &mockFeeBumperAPI{
+		ts: ts,
+		pending: []*types.SignedMessage{
+			msg(miner.Methods.SubmitWindowedPoSt, 500),
+			// fee cap above the base fee, not stuck
+			msg(miner.Methods.PreCommitSector, 1500),
+			// not a critical message
+			msg(miner.Methods.DeclareFaults, 500),
+			// max fee doesn't allow bumping the fee cap
+			msg(miner.Methods.ProveCommitSector, 500),
+		},
+	}
+
+	fb := NewFeeBumper(fapi, maddr, big.NewInt(10_000_000), big.NewInt(10_000_000), big.NewInt(500_000))
+	require.NoError(t, fb.check(ctx))
+
+	require.Len(t, fapi.pushed, 1)
+	rep := fapi.pushed[0].Message
+	require.Equal(t, miner.Methods.SubmitWindowedPoSt, rep.Method)
+	require.Equal(t, big.NewInt(2000), rep.GasFeeCap)
+	// at least the min replace-by-fee premium
+	require.Equal(t, big.NewInt(113), rep.GasPremium)
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// fundsIdleInterval is how often the funds policy is read while the funds
+// manager is disabled
+var fundsIdleInterval = time.Minute
+
+// GetFundsPolicyFunc returns the current funds policy
+type GetFundsPolicyFunc func() (api.FundsPolicy, error)
+
+// SetFundsPolicyFunc persists a new funds policy
+type SetFundsPolicyFunc func(api.FundsPolicy) error
+
+type fundsManagerAPI interface {
+	StateMinerInfo(context.Context, address.Address, types.TipSetKey) (miner.MinerInfo, error)
+	StateMinerAvailableBalance(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)
+	StateWaitMsg(ctx context.Context, cid cid.Cid, confidence uint64) (*api.MsgLookup, error)
+	MpoolPushMessage(context.Context, *types.Message, *api.MessageSendSpec) (*types.SignedMessage, error)
+}
+
+// FundsManager keeps the available balance of the miner actor above a target
+// for collateral, and withdraws earnings above a threshold, as set by the
+// funds policy. The policy is read before each check, so that changes apply
+// without a restart.
+type FundsManager struct {
+	api    fundsManagerAPI
+	maddr  address.Address
+	maxFee abi.TokenAmount
+	policy GetFundsPolicyFunc
+}
+
+func NewFundsManager(api fundsManagerAPI, maddr address.Address, maxFee abi.TokenAmount, policy GetFundsPolicyFunc) *FundsManager {
+	return &FundsManager{
+		api:    api,
+		maddr:  maddr,
+		maxFee: maxFee,
+		policy: policy,
+	}
+}
+
+// CheckFundsPolicy returns an error if the policy can't be applied
+func CheckFundsPolicy(p api.FundsPolicy) error {
+	if p.CheckInterval < 0 {
+		return xerrors.Errorf("negative check interval")
+	}
+	if p.TargetBalance.Int != nil && p.TargetBalance.Sign() < 0 {
+		return xerrors.Errorf("negative target balance")
+	}
+	if p.WithdrawAbove.Int != nil && p.WithdrawAbove.Sign() < 0 {
+		return xerrors.Errorf("negative withdrawal threshold")
+	}
+	if isSet(p.TargetBalance) && isSet(p.WithdrawAbove) && p.WithdrawAbove.LessThan(p.TargetBalance) {
+		return xerrors.Errorf("withdrawal threshold %s is below the target balance %s", types.FIL(p.WithdrawAbove), types.FIL(p.TargetBalance))
+	}
+	if p.Beneficiary != address.Undef && !isSet(p.WithdrawAbove) {
+		return xerrors.Errorf("beneficiary set, but withdrawals are disabled")
+	}
+	return nil
+}
+
+func isSet(amt abi.TokenAmount) bool {
+	return amt.Int != nil && amt.Sign() > 0
+}
+
+// Run checks the balance of the miner actor with the interval of the policy
+// until the context is cancelled
+func (fm *FundsManager) Run(ctx context.Context) {
+	for {
+		wait := fundsIdleInterval
+
+		p, err := fm.policy()
+		if err != nil {
+			log.Errorf("getting funds policy: %+v", err)
+		} else if p.CheckInterval > 0 {
+			wait = p.CheckInterval
+			if err := fm.check(ctx, p); err != nil {
+				log.Errorf("managing miner funds: %+v", err)
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (fm *FundsManager) check(ctx context.Context, p api.FundsPolicy) error {
+	available, err := fm.api.StateMinerAvailableBalance(ctx, fm.maddr, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("getting available balance: %w", err)
+	}
+
+	switch {
+	case isSet(p.TargetBalance) && available.LessThan(p.TargetBalance):
+		return fm.topUp(ctx, p, big.Sub(p.TargetBalance, available))
+	case isSet(p.WithdrawAbove) && available.GreaterThan(p.WithdrawAbove):
+		return fm.withdraw(ctx, p, big.Sub(available, p.WithdrawAbove))
+	}
+
+	return nil
+}
+
+func (fm *FundsManager) topUp(ctx context.Context, p api.FundsPolicy, amt abi.TokenAmount) error {
+	from := p.TopUpFrom
+	if from == address.Undef {
+		mi, err := fm.api.StateMinerInfo(ctx, fm.maddr, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("getting miner info: %w", err)
+		}
+		from = mi.Worker
+	}
+
+	log.Infow("available miner balance below target, topping up", "amount", types.FIL(amt), "from", from)
+
+	return fm.push(ctx, &types.Message{
+		To:     fm.maddr,
+		From:   from,
+		Value:  amt,
+		Method: builtin.MethodSend,
+	})
+}
+
+func (fm *FundsManager) withdraw(ctx context.Context, p api.FundsPolicy, amt abi.TokenAmount) error {
+	mi, err := fm.api.StateMinerInfo(ctx, fm.maddr, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("getting miner info: %w", err)
+	}
+
+	params, err := actors.SerializeParams(&miner2.WithdrawBalanceParams{
+		AmountRequested: amt,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infow("available miner balance above threshold, withdrawing", "amount", types.FIL(amt))
+
+	// withdrawn funds are sent to the owner
+	if err := fm.push(ctx, &types.Message{
+		To:     fm.maddr,
+		From:   mi.Owner,
+		Value:  big.Zero(),
+		Method: miner.Methods.WithdrawBalance,
+		Params: params,
+	}); err != nil {
+		return xerrors.Errorf("withdrawing: %w", err)
+	}
+
+	if p.Beneficiary == address.Undef || p.Beneficiary == mi.Owner {
+		return nil
+	}
+
+	log.Infow("forwarding withdrawn funds", "amount", types.FIL(amt), "to", p.Beneficiary)
+
+	if err := fm.push(ctx, &types.Message{
+		To:     p.Beneficiary,
+		From:   mi.Owner,
+		Value:  amt,
+		Method: builtin.MethodSend,
+	}); err != nil {
+		return xerrors.Errorf("forwarding withdrawn funds to beneficiary: %w", err)
+	}
+
+	return nil
+}
+
+// push sends the message and waits for it to be executed successfully, so
+// that the next check sees the new balance
+func (fm *FundsManager) push(ctx context.Context, msg *types.Message) error {
+	sm, err := fm.api.MpoolPushMessage(ctx, msg, &api.MessageSendSpec{MaxFee: fm.maxFee})
+	if err != nil {
+		return xerrors.Errorf("pushing message: %w", err)
+	}
+
+	rec, err := fm.api.StateWaitMsg(ctx, sm.Cid(), build.MessageConfidence)
+	if err != nil {
+		return xerrors.Errorf("waiting for message %s: %w", sm.Cid(), err)
+	}
+
+	if rec.Receipt.ExitCode != 0 {
+		return xerrors.Errorf("message %s failed with exit code %d", sm.Cid(), rec.Receipt.ExitCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+	tutils "github.com/filecoin-project/specs-actors/v2/support/testing"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type mockFundsAPI struct {
+	owner, worker address.Address
+	available     types.BigInt
+	pushed        []*types.Message
+}
+
+func (m *mockFundsAPI) StateMinerInfo(context.Context, address.Address, types.TipSetKey) (miner.MinerInfo, error) {
+	return miner.MinerInfo{Owner: m.owner, Worker: m.worker}, nil
+}
+
+func (m *mockFundsAPI) StateMinerAvailableBalance(context.Context, address.Address, types.TipSetKey) (types.BigInt, error) {
+	return m.available, nil
+}
+
+func (m *mockFundsAPI) StateWaitMsg(context.Context, cid.Cid, uint64) (*api.MsgLookup, error) {
+	return &api.MsgLookup{Receipt: types.MessageReceipt{ExitCode: 0}}, nil
+}
+
+func (m *mockFundsAPI) MpoolPushMessage(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec) (*types.SignedMessage, error) {
+	m.pushed = append(m.pushed, msg)
+	return &types.SignedMessage{Message: *msg}, nil
+}
+
+func TestFundsManager(t *testing.T) {
+	ctx := context.Background()
+
+	maddr := tutils.NewIDAddr(t, 1000)
+	fapi := &mockFundsAPI{
+		owner:  tutils.NewIDAddr(t, 100),
+		worker: tutils.NewIDAddr(t, 101),
+	}
+	beneficiary := tutils.NewIDAddr(t, 102)
+
+	policy := api.FundsPolicy{
+		CheckInterval: time.Minute,
+		TargetBalance: types.FromFil(10),
+		WithdrawAbove: types.FromFil(50),
+		Beneficiary:   beneficiary,
+	}
+	require.NoError(t, CheckFundsPolicy(policy))
+
+	fm := NewFundsManager(fapi, maddr, big.Zero(), func() (api.FundsPolicy, error) {
+		return policy, nil
+	})
+
+	// between the target and the threshold, nothing to do
+	fapi.available = types.FromFil(20)
+	require.NoError(t, fm.check(ctx, policy))
+	require.Empty(t, fapi.pushed)
+
+	// below the target, topped up from the worker
+	fapi.available = types.FromFil(4)
+	require.NoError(t, fm.check(ctx, policy))
+	require.Len(t, fapi.pushed, 1)
+	require.Equal(t, fapi.worker, fapi.pushed[0].From)
+	require.Equal(t, maddr, fapi.pushed[0].To)
+	require.Equal(t, builtin.MethodSend, fapi.pushed[0].Method)
+	require.Equal(t, types.FromFil(6), fapi.pushed[0].Value)
+
+	// above the threshold, withdrawn and forwarded to the beneficiary
+	fapi.pushed = nil
+	fapi.available = types.FromFil(80)
+	require.NoError(t, fm.check(ctx, policy))
+	require.Len(t, fapi.pushed, 2)
+
+	require.Equal(t, fapi.owner, fapi.pushed[0].From)
+	require.Equal(t, miner.Methods.WithdrawBalance, fapi.pushed[0].Method)
+	var params miner2.WithdrawBalanceParams
+	require.NoError(t, params.UnmarshalCBOR(bytes.NewReader(fapi.pushed[0].Params)))
+	require.Equal(t, types.FromFil(30), params.AmountRequested)
+
+	require.Equal(t, fapi.owner, fapi.pushed[1].From)
+	require.Equal(t, beneficiary, fapi.pushed[1].To)
+	require.Equal(t, types.FromFil(30), fapi.pushed[1].Value)
+}
+
+func TestCheckFundsPolicy(t *testing.T) {
+	require.NoError(t, CheckFundsPolicy(api.FundsPolicy{}))
+
+	require.Error(t, CheckFundsPolicy(api.FundsPolicy{
+		TargetBalance: types.FromFil(10),
+		WithdrawAbove: types.FromFil(5),
+	}))
+
+	require.Error(t, CheckFundsPolicy(api.FundsPolicy{
+		Beneficiary: tutils.NewIDAddr(t, 102),
+	}))
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p-core/host"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
@@ -26,6 +28,7 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/bus"
 	"github.com/filecoin-project/lotus/chain/actors/builtin"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/actors/policy"
@@ -34,6 +37,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
 	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/metrics"
 	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
@@ -54,10 +58,19 @@ type Miner struct {
 
 	getSealConfig dtypes.GetSealingConfigFunc
 	sealing       *sealing.Sealing
+	wdpost        *WindowPoStScheduler
 
 	sealingEvtType journal.EventType
 
 	journal journal.Journal
+	// publishes sector state changes on the event bus
+	emitSectorState func(interface{})
+
+	// set while new pieces and pledges aren't accepted into sectors
+	inputPaused int32
+
+	stateTimes    sectorStateTimes
+	recentCommits recentCommits
 }
 
 // SealingStateEvt is a journal event that records a sector state transition.
@@ -66,7 +79,11 @@ type SealingStateEvt struct {
 	SectorType   abi.RegisteredSealProof
 	From         sealing.SectorState
 	After        sealing.SectorState
-	Error        string
+	// Trigger is the event which caused the transition
+	Trigger string
+	// Duration is the time the sector spent in the From state, zero if unknown
+	Duration time.Duration
+	Error    string
 }
 
 type storageMinerApi interface {
@@ -115,7 +132,7 @@ type storageMinerApi interface {
 	WalletHas(context.Context, address.Address) (bool, error)
 }
 
-func NewMiner(api storageMinerApi, maddr address.Address, h host.Host, ds datastore.Batching, sealer sectorstorage.SectorManager, sc sealing.SectorIDCounter, verif ffiwrapper.Verifier, gsd dtypes.GetSealingConfigFunc, feeCfg config.MinerFeeConfig, journal journal.Journal, as *AddressSelector) (*Miner, error) {
+func NewMiner(api storageMinerApi, maddr address.Address, h host.Host, ds datastore.Batching, sealer sectorstorage.SectorManager, sc sealing.SectorIDCounter, verif ffiwrapper.Verifier, gsd dtypes.GetSealingConfigFunc, feeCfg config.MinerFeeConfig, journal journal.Journal, b bus.Bus, as *AddressSelector, wdpost *WindowPoStScheduler) (*Miner, error) {
 	m := &Miner{
 		api:     api,
 		feeCfg:  feeCfg,
@@ -128,8 +145,11 @@ func NewMiner(api storageMinerApi, maddr address.Address, h host.Host, ds datast
 
 		maddr:          maddr,
 		getSealConfig:  gsd,
+		wdpost:         wdpost,
 		journal:        journal,
 		sealingEvtType: journal.RegisterEventType("storage", "sealing_states"),
+
+		emitSectorState: bus.Emitter(b, new(bus.EvtSectorState)),
 	}
 
 	return m, nil
@@ -168,15 +188,36 @@ func (m *Miner) Run(ctx context.Context) error {
 }
 
 func (m *Miner) handleSealingNotifications(before, after sealing.SectorInfo) {
+	tr := m.recordSectorTransition(before, after)
+
+	if tr.Duration > 0 {
+		ctx, err := tag.New(context.TODO(), tag.Upsert(metrics.SectorState, string(before.State)))
+		if err != nil {
+			log.Errorf("creating sealing state metrics context: %+v", err)
+		} else {
+			stats.Record(ctx, metrics.SealingStateDuration.M(tr.Duration.Seconds()))
+		}
+	}
+
 	m.journal.RecordEvent(m.sealingEvtType, func() interface{} {
 		return SealingStateEvt{
 			SectorNumber: before.SectorNumber,
 			SectorType:   before.SectorType,
 			From:         before.State,
 			After:        after.State,
+			Trigger:      tr.Trigger,
+			Duration:     tr.Duration,
 			Error:        after.LastErr,
 		}
 	})
+
+	m.emitSectorState(bus.EvtSectorState{
+		SectorNumber: before.SectorNumber,
+		SectorType:   before.SectorType,
+		From:         string(before.State),
+		After:        string(after.State),
+		Error:        after.LastErr,
+	})
 }
 
 func (m *Miner) Stop(ctx context.Context) error {
@@ -3,25 +3,65 @@ package storage
 import (
 	"context"
 	"io"
+	"sync/atomic"
+	"time"
 
 	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 
+	"github.com/filecoin-project/lotus/api"
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
 )
 
+// ErrSealingInputPaused is returned when adding pieces or pledging sectors
+// while sealing input is paused
+var ErrSealingInputPaused = xerrors.New("sealing input is paused")
+
 // TODO: refactor this to be direct somehow
 
 func (m *Miner) Address() address.Address {
 	return m.sealing.Address()
 }
 
+// SetSealingInput pauses or resumes accepting new pieces and pledges into
+// sectors. Sectors which already started sealing aren't affected.
+func (m *Miner) SetSealingInput(enabled bool) {
+	var paused int32
+	if !enabled {
+		paused = 1
+	}
+	atomic.StoreInt32(&m.inputPaused, paused)
+}
+
+func (m *Miner) sealingInputPaused() bool {
+	return atomic.LoadInt32(&m.inputPaused) == 1
+}
+
 func (m *Miner) AddPieceToAnySector(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d sealing.DealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error) {
+	if m.sealingInputPaused() {
+		return 0, 0, ErrSealingInputPaused
+	}
 	return m.sealing.AddPieceToAnySector(ctx, size, r, d)
 }
 
+func (m *Miner) ReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d sealing.DealInfo) (api.PieceLease, error) {
+	if m.sealingInputPaused() {
+		return api.PieceLease{}, ErrSealingInputPaused
+	}
+	return m.sealing.ReservePiece(ctx, size, d)
+}
+
+func (m *Miner) CommitPiece(ctx context.Context, lease uint64, r io.Reader) (api.PieceLease, error) {
+	return m.sealing.CommitPiece(ctx, lease, r)
+}
+
+func (m *Miner) AddPieceQueue() (api.AddPieceQueueInfo, error) {
+	return m.sealing.AddPieceQueue()
+}
+
 func (m *Miner) StartPackingSector(sectorNum abi.SectorNumber) error {
 	return m.sealing.StartPacking(sectorNum)
 }
@@ -34,10 +74,21 @@ func (m *Miner) GetSectorInfo(sid abi.SectorNumber) (sealing.SectorInfo, error)
 	return m.sealing.GetSectorInfo(sid)
 }
 
+func (m *Miner) SectorNextRetry(si sealing.SectorInfo) (time.Time, error) {
+	return m.sealing.NextRetry(si)
+}
+
 func (m *Miner) PledgeSector() error {
+	if m.sealingInputPaused() {
+		return ErrSealingInputPaused
+	}
 	return m.sealing.PledgeSector()
 }
 
+func (m *Miner) UpdateWaitDealsTimers() error {
+	return m.sealing.UpdateWaitDealsTimers()
+}
+
 func (m *Miner) ForceSectorState(ctx context.Context, id abi.SectorNumber, state sealing.SectorState) error {
 	return m.sealing.ForceSectorState(ctx, id, state)
 }
@@ -46,6 +97,14 @@ func (m *Miner) RemoveSector(ctx context.Context, id abi.SectorNumber) error {
 	return m.sealing.Remove(ctx, id)
 }
 
+func (m *Miner) AbortSector(ctx context.Context, id abi.SectorNumber) ([]sealing.MovedPiece, error) {
+	return m.sealing.AbortSector(ctx, id)
+}
+
+func (m *Miner) RegenerateSectorCache(ctx context.Context, id abi.SectorNumber) error {
+	return m.sealing.RegenerateCache(ctx, id)
+}
+
 func (m *Miner) TerminateSector(ctx context.Context, id abi.SectorNumber) error {
 	return m.sealing.Terminate(ctx, id)
 }
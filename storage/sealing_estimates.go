@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+// estimateSampleSectors is the number of most recently committed sectors the
+// stage durations are averaged over, so that estimates follow changes in
+// sealing throughput
+const estimateSampleSectors = 100
+
+// sealingPipeline lists the states a deal sector goes through before it's
+// committed, in order
+var sealingPipeline = []api.SectorState{
+	api.SectorState(sealing.WaitDeals),
+	api.SectorState(sealing.Packing),
+	api.SectorState(sealing.GetTicket),
+	api.SectorState(sealing.PreCommit1),
+	api.SectorState(sealing.PreCommit2),
+	api.SectorState(sealing.PreCommitting),
+	api.SectorState(sealing.PreCommitWait),
+	api.SectorState(sealing.WaitSeed),
+	api.SectorState(sealing.Committing),
+	api.SectorState(sealing.CommitFinalize),
+	api.SectorState(sealing.SubmitCommit),
+	api.SectorState(sealing.CommitWait),
+	api.SectorState(sealing.FinalizeSector),
+}
+
+// recentCommits keeps the histories of the most recently committed sectors,
+// which estimates are based on. They are loaded once, then sectors are added
+// as they start proving.
+type recentCommits struct {
+	lk        sync.Mutex
+	loaded    bool
+	histories [][]api.SectorStateTransition // most recent first
+}
+
+// add records the history of a sector which started proving, if the recent
+// commits were loaded, otherwise it's found when loading them
+func (rc *recentCommits) add(history []api.SectorStateTransition) {
+	rc.lk.Lock()
+	defer rc.lk.Unlock()
+
+	if !rc.loaded {
+		return
+	}
+
+	rc.histories = append([][]api.SectorStateTransition{history}, rc.histories...)
+	if len(rc.histories) > estimateSampleSectors {
+		rc.histories = rc.histories[:estimateSampleSectors]
+	}
+}
+
+// recentStageDurations returns the average stage durations of the recently committed
+// sectors, loading their histories on the first call
+func (m *Miner) recentStageDurations(sectors []sealing.SectorInfo) (map[api.SectorState]time.Duration, error) {
+	rc := &m.recentCommits
+
+	rc.lk.Lock()
+	defer rc.lk.Unlock()
+
+	if !rc.loaded {
+		var committed [][]api.SectorStateTransition
+		for _, sector := range sectors {
+			if sector.State != sealing.Proving {
+				continue
+			}
+
+			history, err := m.SectorHistory(sector.SectorNumber)
+			if err != nil {
+				return nil, err
+			}
+			if committedAt(history).IsZero() {
+				continue
+			}
+			committed = append(committed, history)
+		}
+
+		sort.Slice(committed, func(i, j int) bool {
+			return committedAt(committed[i]).After(committedAt(committed[j]))
+		})
+		if len(committed) > estimateSampleSectors {
+			committed = committed[:estimateSampleSectors]
+		}
+
+		rc.histories = committed
+		rc.loaded = true
+	}
+
+	return stageDurations(rc.histories), nil
+}
+
+// DealCommitEstimates returns when the sectors holding deals which aren't
+// committed yet are expected to be proving, based on the time recently
+// committed sectors spent in each sealing state. Deals waiting to be added to
+// a sector are expected to go through the whole sealing pipeline.
+func (m *Miner) DealCommitEstimates() ([]api.DealCommitEstimate, error) {
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return nil, xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	durations, err := m.recentStageDurations(sectors)
+	if err != nil {
+		return nil, xerrors.Errorf("getting recent sealing durations: %w", err)
+	}
+
+	now := build.Clock.Now()
+
+	out := []api.DealCommitEstimate{}
+	for _, sector := range sectors {
+		if sector.State == sealing.Proving || !hasDeals(sector) {
+			continue
+		}
+
+		since, err := m.stateEntered(sector)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, sectorDealEstimates(now, sector, since, durations)...)
+	}
+
+	if m.sealing != nil {
+		for _, deal := range m.sealing.QueuedDeals() {
+			out = append(out, api.DealCommitEstimate{
+				DealID:   deal,
+				Estimate: estimateCommit(now, sealingPipeline[0], time.Time{}, durations),
+			})
+		}
+	}
+
+	return out, nil
+}
+
+func hasDeals(sector sealing.SectorInfo) bool {
+	for _, p := range sector.Pieces {
+		if p.DealInfo != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// sectorDealEstimates returns the estimates of the deals in a sector which
+// entered its state at since
+func sectorDealEstimates(now time.Time, sector sealing.SectorInfo, since time.Time, durations map[api.SectorState]time.Duration) []api.DealCommitEstimate {
+	state := api.SectorState(sector.State)
+	estimate := estimateCommit(now, state, since, durations)
+
+	var out []api.DealCommitEstimate
+	for _, p := range sector.Pieces {
+		if p.DealInfo == nil {
+			continue
+		}
+
+		out = append(out, api.DealCommitEstimate{
+			DealID:   p.DealInfo.DealID,
+			Sector:   sector.SectorNumber,
+			State:    state,
+			Estimate: estimate,
+		})
+	}
+	return out
+}
+
+// committedAt returns when the sector history shows the sector started
+// proving, zero if it doesn't
+func committedAt(history []api.SectorStateTransition) time.Time {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].To == api.SectorState(sealing.Proving) {
+			return history[i].Time
+		}
+	}
+	return time.Time{}
+}
+
+// stageDurations returns the average time the sectors spent in each pipeline
+// state. Retries count towards the state, and states some sectors skip only
+// partially count. The time spent in the state a history starts from isn't
+// known, so those sectors don't count towards that state.
+func stageDurations(histories [][]api.SectorStateTransition) map[api.SectorState]time.Duration {
+	out := map[api.SectorState]time.Duration{}
+	if len(histories) == 0 {
+		return out
+	}
+
+	unknown := map[api.SectorState]int{}
+	for _, history := range histories {
+		for i, tr := range history {
+			if i == 0 && tr.Duration == 0 {
+				unknown[tr.From]++
+				continue
+			}
+			out[tr.From] += tr.Duration
+		}
+	}
+
+	for st, d := range out {
+		if n := len(histories) - unknown[st]; n > 0 {
+			out[st] = d / time.Duration(n)
+		}
+	}
+
+	return out
+}
+
+// estimateCommit returns when a sector which entered the state at since is
+// expected to be committed, zero if the state isn't on the sealing pipeline or
+// there are no durations to estimate from
+func estimateCommit(now time.Time, state api.SectorState, since time.Time, durations map[api.SectorState]time.Duration) time.Time {
+	if len(durations) == 0 {
+		return time.Time{}
+	}
+
+	at := -1
+	for i, st := range sealingPipeline {
+		if st == state {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return time.Time{}
+	}
+
+	// time left in the current state, none if it's taking longer than usual
+	remaining := durations[state]
+	if !since.IsZero() {
+		remaining -= now.Sub(since)
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+
+	for _, st := range sealingPipeline[at+1:] {
+		remaining += durations[st]
+	}
+
+	return now.Add(remaining)
+}
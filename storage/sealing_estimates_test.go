@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+func TestEstimateCommit(t *testing.T) {
+	st := func(s sealing.SectorState) api.SectorState {
+		return api.SectorState(s)
+	}
+
+	durations := stageDurations([][]api.SectorStateTransition{
+		{
+			{From: st(sealing.PreCommit1), To: st(sealing.PreCommit2), Duration: 4 * time.Hour},
+			{From: st(sealing.PreCommit2), To: st(sealing.PreCommitting), Duration: time.Hour},
+			{From: st(sealing.PreCommitting), To: st(sealing.Proving), Duration: time.Hour},
+		},
+		{
+			{From: st(sealing.PreCommit1), To: st(sealing.SealPreCommit1Failed), Duration: time.Hour},
+			{From: st(sealing.SealPreCommit1Failed), To: st(sealing.PreCommit1), Duration: time.Hour},
+			{From: st(sealing.PreCommit1), To: st(sealing.PreCommit2), Duration: 3 * time.Hour},
+			{From: st(sealing.PreCommit2), To: st(sealing.PreCommitting), Duration: time.Hour},
+			{From: st(sealing.PreCommitting), To: st(sealing.Proving), Duration: time.Hour},
+		},
+	})
+	require.Equal(t, 4*time.Hour, durations[st(sealing.PreCommit1)])
+	require.Equal(t, time.Hour, durations[st(sealing.PreCommit2)])
+
+	now := time.Now()
+
+	require.Equal(t, now.Add(6*time.Hour), estimateCommit(now, st(sealing.PreCommit1), time.Time{}, durations))
+	require.Equal(t, now.Add(5*time.Hour), estimateCommit(now, st(sealing.PreCommit1), now.Add(-time.Hour), durations))
+
+	// taking longer than usual
+	require.Equal(t, now.Add(2*time.Hour), estimateCommit(now, st(sealing.PreCommit1), now.Add(-10*time.Hour), durations))
+
+	require.True(t, estimateCommit(now, st(sealing.SealPreCommit1Failed), time.Time{}, durations).IsZero())
+	require.True(t, estimateCommit(now, st(sealing.PreCommit1), time.Time{}, stageDurations(nil)).IsZero())
+}
+
+func TestStageDurationsUnknownStart(t *testing.T) {
+	st := func(s sealing.SectorState) api.SectorState {
+		return api.SectorState(s)
+	}
+
+	// the first sector's history starts after it entered PreCommit1, so the
+	// time it spent there isn't known
+	durations := stageDurations([][]api.SectorStateTransition{
+		{
+			{From: st(sealing.PreCommit1), To: st(sealing.PreCommit2)},
+			{From: st(sealing.PreCommit2), To: st(sealing.Proving), Duration: time.Hour},
+		},
+		{
+			{From: st(sealing.PreCommit1), To: st(sealing.PreCommit2), Duration: 4 * time.Hour},
+			{From: st(sealing.PreCommit2), To: st(sealing.Proving), Duration: 3 * time.Hour},
+		},
+	})
+	require.Equal(t, 4*time.Hour, durations[st(sealing.PreCommit1)])
+	require.Equal(t, 2*time.Hour, durations[st(sealing.PreCommit2)])
+}
+
+func TestSectorDealEstimates(t *testing.T) {
+	now := time.Now()
+	durations := map[api.SectorState]time.Duration{
+		api.SectorState(sealing.PreCommit2): time.Hour,
+	}
+
+	sector := sealing.SectorInfo{
+		State:        sealing.PreCommit2,
+		SectorNumber: 3,
+		Pieces: []sealing.Piece{
+			{DealInfo: &sealing.DealInfo{DealID: 5}},
+			{},
+			{DealInfo: &sealing.DealInfo{DealID: 6}},
+		},
+	}
+
+	out := sectorDealEstimates(now, sector, now.Add(-time.Hour/2), durations)
+	require.Len(t, out, 2)
+	for i, deal := range []abi.DealID{5, 6} {
+		require.Equal(t, deal, out[i].DealID)
+		require.Equal(t, abi.SectorNumber(3), out[i].Sector)
+		require.Equal(t, now.Add(time.Hour/2), out[i].Estimate)
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+// maxSectorHistory bounds the transitions kept for a sector, sectors retrying
+// a failing step can go back and forth between two states for a long time
+const maxSectorHistory = 1000
+
+var sectorHistoryPrefix = datastore.NewKey("/sector-history")
+
+func sectorHistoryKey(sid abi.SectorNumber) datastore.Key {
+	return sectorHistoryPrefix.ChildString(fmt.Sprint(uint64(sid)))
+}
+
+// SectorHistory returns the recorded state transitions of the sector, oldest
+// first. Transitions from before the node recorded them aren't listed.
+func (m *Miner) SectorHistory(sid abi.SectorNumber) ([]api.SectorStateTransition, error) {
+	b, err := m.ds.Get(sectorHistoryKey(sid))
+	if err == datastore.ErrNotFound {
+		return []api.SectorStateTransition{}, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("getting history of sector %d: %w", sid, err)
+	}
+
+	var out []api.SectorStateTransition
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, xerrors.Errorf("decoding history of sector %d: %w", sid, err)
+	}
+
+	return out, nil
+}
+
+// recordSectorTransition appends the transition to the history of the sector,
+// if the sector changed state. The state machine processes the events of a
+// sector one at a time, so updates of one sector's history don't race.
+func (m *Miner) recordSectorTransition(before, after sealing.SectorInfo) api.SectorStateTransition {
+	tr := api.SectorStateTransition{
+		From:    api.SectorState(before.State),
+		To:      api.SectorState(after.State),
+		Trigger: transitionTrigger(after),
+		Time:    build.Clock.Now(),
+		Error:   after.LastErr,
+	}
+
+	if before.State == after.State {
+		return tr
+	}
+
+	m.stateTimes.set(after.SectorNumber, after.State, tr.Time)
+
+	history, err := m.SectorHistory(after.SectorNumber)
+	if err != nil {
+		log.Warnw("recording sector state transition", "sector", after.SectorNumber, "error", err)
+		return tr
+	}
+
+	if len(history) > 0 {
+		tr.Duration = tr.Time.Sub(history[len(history)-1].Time)
+	}
+
+	history = append(history, tr)
+	if len(history) > maxSectorHistory {
+		history = history[len(history)-maxSectorHistory:]
+	}
+
+	b, err := json.Marshal(history)
+	if err != nil {
+		log.Warnw("encoding sector history", "sector", after.SectorNumber, "error", err)
+		return tr
+	}
+
+	if err := m.ds.Put(sectorHistoryKey(after.SectorNumber), b); err != nil {
+		log.Warnw("storing sector history", "sector", after.SectorNumber, "error", err)
+	}
+
+	if after.State == sealing.Proving {
+		m.recentCommits.add(history)
+	}
+
+	return tr
+}
+
+// transitionTrigger returns the name of the last event in the sector log,
+// which is the event that was processed when the sector changed state
+func transitionTrigger(si sealing.SectorInfo) string {
+	for i := len(si.Log) - 1; i >= 0; i-- {
+		kind := si.Log[i].Kind
+		if !strings.HasPrefix(kind, "event;") {
+			continue
+		}
+
+		kind = strings.TrimPrefix(kind, "event;")
+		return kind[strings.LastIndex(kind, ".")+1:]
+	}
+
+	return ""
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/api"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+func TestSectorHistory(t *testing.T) {
+	m := &Miner{ds: datastore.NewMapDatastore()}
+
+	history, err := m.SectorHistory(1)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	si := sealing.SectorInfo{
+		SectorNumber: 1,
+		State:        sealing.Packing,
+		Log: []sealing.Log{
+			{Kind: "event;sealing.SectorStartPacking"},
+			{Kind: "truncate"},
+		},
+	}
+	next := si
+	next.State = sealing.PreCommit1
+	m.recordSectorTransition(si, next)
+
+	// no state change, not recorded
+	m.recordSectorTransition(next, next)
+
+	failed := next
+	failed.State = sealing.SealPreCommit1Failed
+	failed.LastErr = "seal failed"
+	failed.Log = append(next.Log, sealing.Log{Kind: "event;sealing.SectorSealPreCommit1Failed"})
+	m.recordSectorTransition(next, failed)
+
+	history, err = m.SectorHistory(1)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	require.Equal(t, api.SectorState(sealing.Packing), history[0].From)
+	require.Equal(t, api.SectorState(sealing.PreCommit1), history[0].To)
+	require.Equal(t, "SectorStartPacking", history[0].Trigger)
+	require.Zero(t, history[0].Duration)
+
+	require.Equal(t, api.SectorState(sealing.SealPreCommit1Failed), history[1].To)
+	require.Equal(t, "SectorSealPreCommit1Failed", history[1].Trigger)
+	require.Equal(t, "seal failed", history[1].Error)
+	require.Equal(t, history[1].Time.Sub(history[0].Time), history[1].Duration)
+
+	// other sectors have their own history
+	history, err = m.SectorHistory(2)
+	require.NoError(t, err)
+	require.Empty(t, history)
+}
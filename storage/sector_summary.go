@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+// finalStates are the states sectors stay in for the most part of their life.
+// When they entered them is taken from the sector log, so that summaries
+// don't decode the history of every sector.
+var finalStates = map[sealing.SectorState]struct{}{
+	sealing.Proving:             {},
+	sealing.Removed:             {},
+	sealing.FaultedFinal:        {},
+	sealing.FailedUnrecoverable: {},
+}
+
+type stateEntry struct {
+	state sealing.SectorState
+	at    time.Time
+}
+
+// sectorStateTimes keeps when sectors entered their current state. It's
+// updated on state transitions, and sectors which didn't change state since
+// the node started are looked up once.
+type sectorStateTimes struct {
+	lk      sync.Mutex
+	entered map[abi.SectorNumber]stateEntry
+}
+
+func (st *sectorStateTimes) set(sid abi.SectorNumber, state sealing.SectorState, at time.Time) {
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	if st.entered == nil {
+		st.entered = map[abi.SectorNumber]stateEntry{}
+	}
+	st.entered[sid] = stateEntry{state: state, at: at}
+}
+
+func (st *sectorStateTimes) get(sid abi.SectorNumber, state sealing.SectorState) (time.Time, bool) {
+	st.lk.Lock()
+	defer st.lk.Unlock()
+
+	e, ok := st.entered[sid]
+	if !ok || e.state != state {
+		return time.Time{}, false
+	}
+	return e.at, true
+}
+
+// stateEntered returns when the sector entered its current state, from its
+// recorded transitions, or for sectors in a final state or older than the
+// history from the last logged event. It's zero if unknown.
+func (m *Miner) stateEntered(si sealing.SectorInfo) (time.Time, error) {
+	if at, ok := m.stateTimes.get(si.SectorNumber, si.State); ok {
+		return at, nil
+	}
+
+	var since time.Time
+	if _, final := finalStates[si.State]; !final {
+		history, err := m.SectorHistory(si.SectorNumber)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if n := len(history); n > 0 && history[n-1].To == api.SectorState(si.State) {
+			since = history[n-1].Time
+		}
+	}
+	if n := len(si.Log); since.IsZero() && n > 0 {
+		since = time.Unix(int64(si.Log[n-1].Timestamp), 0)
+	}
+
+	m.stateTimes.set(si.SectorNumber, si.State, since)
+	return since, nil
+}
+
+// SectorsStateSummary aggregates the sectors by sealing state
+func (m *Miner) SectorsStateSummary() (map[api.SectorState]api.SectorStateSummary, error) {
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[api.SectorState]api.SectorStateSummary)
+	for _, sector := range sectors {
+		since, err := m.stateEntered(sector)
+		if err != nil {
+			return nil, err
+		}
+
+		addToSummary(out, sector, since)
+	}
+
+	return out, nil
+}
+
+func addToSummary(out map[api.SectorState]api.SectorStateSummary, sector sealing.SectorInfo, since time.Time) {
+	state := api.SectorState(sector.State)
+	s := out[state]
+	s.Count++
+
+	for _, p := range sector.Pieces {
+		if p.DealInfo != nil {
+			s.DealBytes += p.Piece.Size
+		}
+	}
+
+	if !since.IsZero() && (s.OldestSince.IsZero() || since.Before(s.OldestSince)) {
+		s.OldestSince = since
+	}
+
+	out[state] = s
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+func TestStateEntered(t *testing.T) {
+	m := &Miner{ds: datastore.NewMapDatastore()}
+
+	logged := time.Unix(1000, 0)
+	si := sealing.SectorInfo{
+		SectorNumber: 1,
+		State:        sealing.Packing,
+		Log:          []sealing.Log{{Kind: "event;sealing.SectorStartPacking", Timestamp: uint64(logged.Unix())}},
+	}
+
+	// no history, the last logged event is used
+	since, err := m.stateEntered(si)
+	require.NoError(t, err)
+	require.Equal(t, logged, since)
+
+	next := si
+	next.State = sealing.PreCommit1
+	tr := m.recordSectorTransition(si, next)
+
+	since, err = m.stateEntered(next)
+	require.NoError(t, err)
+	require.Equal(t, tr.Time, since)
+
+	// transitions of other nodes are found in the history
+	m.stateTimes = sectorStateTimes{}
+	since, err = m.stateEntered(next)
+	require.NoError(t, err)
+	require.True(t, tr.Time.Equal(since))
+
+	// the history of sectors in final states isn't decoded
+	require.NoError(t, m.ds.Put(sectorHistoryKey(2), []byte("not json")))
+	proving := sealing.SectorInfo{SectorNumber: 2, State: sealing.Proving, Log: si.Log}
+	since, err = m.stateEntered(proving)
+	require.NoError(t, err)
+	require.Equal(t, logged, since)
+
+	_, err = m.stateEntered(sealing.SectorInfo{SectorNumber: 2, State: sealing.WaitSeed})
+	require.Error(t, err)
+}
+
+func TestAddToSummary(t *testing.T) {
+	out := map[api.SectorState]api.SectorStateSummary{}
+
+	deal := sealing.Piece{Piece: abi.PieceInfo{Size: 1024}, DealInfo: &sealing.DealInfo{DealID: 1}}
+	filler := sealing.Piece{Piece: abi.PieceInfo{Size: 1024}}
+
+	older, newer := time.Unix(1000, 0), time.Unix(2000, 0)
+	addToSummary(out, sealing.SectorInfo{State: sealing.WaitDeals, Pieces: []sealing.Piece{deal, filler}}, newer)
+	addToSummary(out, sealing.SectorInfo{State: sealing.WaitDeals, Pieces: []sealing.Piece{deal}}, older)
+	addToSummary(out, sealing.SectorInfo{State: sealing.WaitDeals}, time.Time{})
+	addToSummary(out, sealing.SectorInfo{State: sealing.Proving, Pieces: []sealing.Piece{filler}}, time.Time{})
+
+	require.Equal(t, api.SectorStateSummary{Count: 3, OldestSince: older, DealBytes: 2048}, out[api.SectorState(sealing.WaitDeals)])
+	require.Equal(t, api.SectorStateSummary{Count: 1}, out[api.SectorState(sealing.Proving)])
+}
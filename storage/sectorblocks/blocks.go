@@ -48,22 +48,40 @@ func DsKeyToDealID(key datastore.Key) (uint64, error) {
 	return dealID, nil
 }
 
+// SectorBuilder puts deal pieces into sectors. It's implemented by the
+// storage.Miner, and by RemoteBuilder when the markets subsystem runs in a
+// separate process from sealing.
+type SectorBuilder interface {
+	AddPieceToAnySector(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d sealing.DealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error)
+	ReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d sealing.DealInfo) (api.PieceLease, error)
+	CommitPiece(ctx context.Context, lease uint64, r io.Reader) (api.PieceLease, error)
+	GetSectorInfo(sid abi.SectorNumber) (sealing.SectorInfo, error)
+}
+
+var _ SectorBuilder = &storage.Miner{}
+
 type SectorBlocks struct {
-	*storage.Miner
+	SectorBuilder
 
 	keys  datastore.Batching
 	keyLk sync.Mutex
 }
 
-func NewSectorBlocks(miner *storage.Miner, ds dtypes.MetadataDS) *SectorBlocks {
+func NewSectorBlocks(sb SectorBuilder, ds dtypes.MetadataDS) *SectorBlocks {
 	sbc := &SectorBlocks{
-		Miner: miner,
-		keys:  namespace.Wrap(ds, dsPrefix),
+		SectorBuilder: sb,
+		keys:          namespace.Wrap(ds, dsPrefix),
 	}
 
 	return sbc
 }
 
+// AddRef records that the piece of the deal is stored in the sector at offset,
+// e.g. after it was moved from an aborted sector
+func (st *SectorBlocks) AddRef(dealID abi.DealID, sectorID abi.SectorNumber, offset abi.PaddedPieceSize, size abi.UnpaddedPieceSize) error {
+	return st.writeRef(dealID, sectorID, offset, size)
+}
+
 func (st *SectorBlocks) writeRef(dealID abi.DealID, sectorID abi.SectorNumber, offset abi.PaddedPieceSize, size abi.UnpaddedPieceSize) error {
 	st.keyLk.Lock() // TODO: make this multithreaded
 	defer st.keyLk.Unlock()
@@ -97,7 +115,7 @@ func (st *SectorBlocks) writeRef(dealID abi.DealID, sectorID abi.SectorNumber, o
 }
 
 func (st *SectorBlocks) AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d sealing.DealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error) {
-	sn, offset, err := st.Miner.AddPieceToAnySector(ctx, size, r, d)
+	sn, offset, err := st.SectorBuilder.AddPieceToAnySector(ctx, size, r, d)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -111,6 +129,22 @@ func (st *SectorBlocks) AddPiece(ctx context.Context, size abi.UnpaddedPieceSize
 	return sn, offset, nil
 }
 
+// CommitPiece writes the data of a piece reserved with ReservePiece, and
+// records the deal ref once the piece is in the sector
+func (st *SectorBlocks) CommitPiece(ctx context.Context, lease uint64, r io.Reader) (api.PieceLease, error) {
+	l, err := st.SectorBuilder.CommitPiece(ctx, lease, r)
+	if err != nil {
+		return api.PieceLease{}, err
+	}
+
+	err = st.writeRef(l.DealID, l.Sector, l.Offset, l.Size)
+	if err != nil {
+		return api.PieceLease{}, xerrors.Errorf("writeRef: %w", err)
+	}
+
+	return l, nil
+}
+
 func (st *SectorBlocks) List() (map[uint64][]api.SealedRef, error) {
 	res, err := st.keys.Query(query.Query{})
 	if err != nil {
@@ -0,0 +1,136 @@
+package sectorblocks
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+	"github.com/filecoin-project/lotus/storage"
+)
+
+// remoteErrors are the sealing errors deal processing checks for with
+// xerrors.Is. Errors only cross the API as strings, so they're matched by
+// message and wrapped again.
+var remoteErrors = []error{
+	sealing.ErrTooManySectorsSealing,
+	sealing.ErrTooManyPendingPieces,
+	storage.ErrSealingInputPaused,
+}
+
+func remoteError(err error) error {
+	for _, sentinel := range remoteErrors {
+		if strings.Contains(err.Error(), sentinel.Error()) {
+			return xerrors.Errorf("%s: %w", err.Error(), sentinel)
+		}
+	}
+
+	return err
+}
+
+// RemoteBuilder adds pieces to sectors through the API of a sealing node. It's
+// used by miner nodes running only the markets subsystem.
+type RemoteBuilder struct {
+	sealer api.StorageMiner
+
+	// leases reserved through this builder, the sealing node only returns the
+	// sector and offset when a piece is committed
+	leaseLk sync.Mutex
+	leases  map[uint64]api.PieceLease
+}
+
+var _ SectorBuilder = &RemoteBuilder{}
+
+func NewRemoteBuilder(sealer api.StorageMiner) *RemoteBuilder {
+	return &RemoteBuilder{
+		sealer: sealer,
+		leases: map[uint64]api.PieceLease{},
+	}
+}
+
+func (rb *RemoteBuilder) AddPieceToAnySector(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d sealing.DealInfo) (abi.SectorNumber, abi.PaddedPieceSize, error) {
+	so, err := rb.sealer.SectorAddPieceToAny(ctx, size, r, toPieceDealInfo(d))
+	if err != nil {
+		return 0, 0, xerrors.Errorf("adding piece on the sealing node: %w", remoteError(err))
+	}
+
+	return so.Sector, so.Offset, nil
+}
+
+func (rb *RemoteBuilder) ReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d sealing.DealInfo) (api.PieceLease, error) {
+	l, err := rb.sealer.SectorReservePiece(ctx, size, toPieceDealInfo(d))
+	if err != nil {
+		return api.PieceLease{}, xerrors.Errorf("reserving piece on the sealing node: %w", remoteError(err))
+	}
+
+	rb.leaseLk.Lock()
+	defer rb.leaseLk.Unlock()
+
+	now := time.Now()
+	for id, ol := range rb.leases {
+		if ol.Expires.Before(now) {
+			delete(rb.leases, id)
+		}
+	}
+	rb.leases[l.ID] = l
+
+	return l, nil
+}
+
+func (rb *RemoteBuilder) CommitPiece(ctx context.Context, lease uint64, r io.Reader) (api.PieceLease, error) {
+	rb.leaseLk.Lock()
+	l, ok := rb.leases[lease]
+	rb.leaseLk.Unlock()
+	if !ok {
+		return api.PieceLease{}, xerrors.Errorf("piece lease %d wasn't reserved through this node", lease)
+	}
+
+	so, err := rb.sealer.SectorCommitPiece(ctx, lease, r)
+	if err != nil {
+		return api.PieceLease{}, xerrors.Errorf("committing piece on the sealing node: %w", remoteError(err))
+	}
+
+	rb.leaseLk.Lock()
+	delete(rb.leases, lease)
+	rb.leaseLk.Unlock()
+
+	l.Sector = so.Sector
+	l.Offset = so.Offset
+	return l, nil
+}
+
+// GetSectorInfo returns the state, commitments and ticket of the sector on the
+// sealing node. Other fields are left empty.
+func (rb *RemoteBuilder) GetSectorInfo(sid abi.SectorNumber) (sealing.SectorInfo, error) {
+	si, err := rb.sealer.SectorsStatus(context.TODO(), sid, false)
+	if err != nil {
+		return sealing.SectorInfo{}, xerrors.Errorf("getting sector status from the sealing node: %w", err)
+	}
+
+	return sealing.SectorInfo{
+		State:        sealing.SectorState(si.State),
+		SectorNumber: sid,
+		CommD:        si.CommD,
+		CommR:        si.CommR,
+		TicketValue:  si.Ticket.Value,
+		TicketEpoch:  si.Ticket.Epoch,
+	}, nil
+}
+
+func toPieceDealInfo(d sealing.DealInfo) api.PieceDealInfo {
+	return api.PieceDealInfo{
+		PublishCid:   d.PublishCid,
+		DealID:       d.DealID,
+		StartEpoch:   d.DealSchedule.StartEpoch,
+		EndEpoch:     d.DealSchedule.EndEpoch,
+		KeepUnsealed: d.KeepUnsealed,
+		Labels:       d.Labels,
+	}
+}
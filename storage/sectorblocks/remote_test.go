@@ -0,0 +1,96 @@
+package sectorblocks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/api"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+	lstorage "github.com/filecoin-project/lotus/storage"
+)
+
+type fakeSealer struct {
+	api.StorageMiner
+
+	addErr error
+	leases map[uint64]api.PieceLease
+}
+
+func (f *fakeSealer) SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, r storage.Data, d api.PieceDealInfo) (api.SectorOffset, error) {
+	if f.addErr != nil {
+		return api.SectorOffset{}, f.addErr
+	}
+	return api.SectorOffset{Sector: 1, Offset: 0}, nil
+}
+
+func (f *fakeSealer) SectorReservePiece(ctx context.Context, size abi.UnpaddedPieceSize, d api.PieceDealInfo) (api.PieceLease, error) {
+	l := api.PieceLease{
+		ID:      uint64(len(f.leases) + 1),
+		Sector:  2,
+		Size:    size,
+		DealID:  d.DealID,
+		Expires: time.Now().Add(time.Hour),
+	}
+	f.leases[l.ID] = l
+	return l, nil
+}
+
+func (f *fakeSealer) SectorCommitPiece(ctx context.Context, lease uint64, r storage.Data) (api.SectorOffset, error) {
+	l, ok := f.leases[lease]
+	if !ok {
+		return api.SectorOffset{}, xerrors.New("lease not found")
+	}
+	return api.SectorOffset{Sector: l.Sector, Offset: 2048}, nil
+}
+
+func TestRemoteBuilderErrors(t *testing.T) {
+	for _, sentinel := range []error{
+		sealing.ErrTooManySectorsSealing,
+		sealing.ErrTooManyPendingPieces,
+		lstorage.ErrSealingInputPaused,
+	} {
+		// errors lose their type when crossing the API
+		fs := &fakeSealer{addErr: xerrors.New("RPC error: adding piece: " + sentinel.Error())}
+		rb := NewRemoteBuilder(fs)
+
+		_, _, err := rb.AddPieceToAnySector(context.Background(), 1016, nil, sealing.DealInfo{})
+		require.Error(t, err)
+		require.True(t, xerrors.Is(err, sentinel), "expected %q to match %q", err, sentinel)
+	}
+
+	fs := &fakeSealer{addErr: xerrors.New("some other error")}
+	rb := NewRemoteBuilder(fs)
+
+	_, _, err := rb.AddPieceToAnySector(context.Background(), 1016, nil, sealing.DealInfo{})
+	require.Error(t, err)
+	for _, sentinel := range remoteErrors {
+		require.False(t, xerrors.Is(err, sentinel))
+	}
+}
+
+func TestRemoteBuilderCommitPiece(t *testing.T) {
+	fs := &fakeSealer{leases: map[uint64]api.PieceLease{}}
+	rb := NewRemoteBuilder(fs)
+	ctx := context.Background()
+
+	l, err := rb.ReservePiece(ctx, 1016, sealing.DealInfo{DealID: 5})
+	require.NoError(t, err)
+
+	cl, err := rb.CommitPiece(ctx, l.ID, nil)
+	require.NoError(t, err)
+	require.Equal(t, abi.DealID(5), cl.DealID)
+	require.Equal(t, abi.UnpaddedPieceSize(1016), cl.Size)
+	require.Equal(t, abi.SectorNumber(2), cl.Sector)
+	require.Equal(t, abi.PaddedPieceSize(2048), cl.Offset)
+
+	// leases are forgotten once committed
+	_, err = rb.CommitPiece(ctx, l.ID, nil)
+	require.Error(t, err)
+}
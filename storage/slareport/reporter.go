@@ -0,0 +1,446 @@
+package slareport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/dline"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/storage"
+)
+
+var log = logging.Logger("slareport")
+
+// HistorySize is the number of reports kept in the history
+var HistorySize = 100
+
+// VerifyAPI is the subset of the full node API needed to verify reports
+type VerifyAPI interface {
+	ChainHead(context.Context) (*types.TipSet, error)
+	ChainGetTipSetByHeight(context.Context, abi.ChainEpoch, types.TipSetKey) (*types.TipSet, error)
+	StateMinerInfo(context.Context, address.Address, types.TipSetKey) (miner.MinerInfo, error)
+	StateAccountKey(context.Context, address.Address, types.TipSetKey) (address.Address, error)
+}
+
+// ReportAPI is the subset of the full node API needed to generate reports
+type ReportAPI interface {
+	VerifyAPI
+	storage.WdPoStSubmissionsAPI
+
+	ChainGetGenesis(context.Context) (*types.TipSet, error)
+	StateMinerProvingDeadline(context.Context, address.Address, types.TipSetKey) (*dline.Info, error)
+	StateMinerPartitions(context.Context, address.Address, uint64, types.TipSetKey) ([]api.Partition, error)
+	StateMarketStorageDeal(context.Context, abi.DealID, types.TipSetKey) (*api.MarketDeal, error)
+	WalletSign(context.Context, address.Address, []byte) (*crypto.Signature, error)
+}
+
+// DealsFunc lists the storage deals tracked by the provider
+type DealsFunc func() ([]storagemarket.MinerDeal, error)
+
+// RetrievalsFunc lists the retrieval deals tracked by the provider
+type RetrievalsFunc func() []retrievalmarket.ProviderDealState
+
+// Reporter generates signed SLA reports, and keeps a history of them
+type Reporter struct {
+	api        ReportAPI
+	maddr      address.Address
+	ds         datastore.Batching
+	deals      DealsFunc
+	retrievals RetrievalsFunc
+
+	lk sync.Mutex
+}
+
+func New(a ReportAPI, maddr address.Address, ds datastore.Batching, deals DealsFunc, retrievals RetrievalsFunc) *Reporter {
+	return &Reporter{
+		api:        a,
+		maddr:      maddr,
+		ds:         ds,
+		deals:      deals,
+		retrievals: retrievals,
+	}
+}
+
+// SigningBytes returns the bytes of a report signed by the worker key
+func SigningBytes(r *api.SLAReport) ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Verify checks that the report is signed by the worker key the miner had at
+// the end of the report range
+func Verify(ctx context.Context, a VerifyAPI, sr *api.SignedSLAReport) error {
+	if sr.Signature == nil {
+		return xerrors.Errorf("report isn't signed")
+	}
+
+	head, err := a.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	ts, err := a.ChainGetTipSetByHeight(ctx, sr.Report.To, head.Key())
+	if err != nil {
+		return xerrors.Errorf("getting tipset at report end: %w", err)
+	}
+
+	mi, err := a.StateMinerInfo(ctx, sr.Report.Miner, ts.Key())
+	if err != nil {
+		return xerrors.Errorf("getting miner info: %w", err)
+	}
+
+	worker, err := a.StateAccountKey(ctx, mi.Worker, ts.Key())
+	if err != nil {
+		return xerrors.Errorf("resolving worker key: %w", err)
+	}
+
+	if worker != sr.Report.Worker {
+		return xerrors.Errorf("report signed by %s, miner worker key is %s", sr.Report.Worker, worker)
+	}
+
+	sb, err := SigningBytes(&sr.Report)
+	if err != nil {
+		return xerrors.Errorf("serializing report: %w", err)
+	}
+
+	if err := sigs.Verify(sr.Signature, worker, sb); err != nil {
+		return xerrors.Errorf("invalid report signature: %w", err)
+	}
+
+	return nil
+}
+
+// Run generates a report every interval, covering the epochs since the last
+// report, until the context is cancelled; with a 0 interval, reports are only
+// generated when Generate is called
+func (r *Reporter) Run(ctx context.Context, interval time.Duration) {
+	if interval == 0 {
+		return
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			if err := r.generateNext(ctx, interval); err != nil {
+				log.Errorf("generating sla report: %+v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Reporter) generateNext(ctx context.Context, interval time.Duration) error {
+	head, err := r.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	to := head.Height()
+	from := to - abi.ChainEpoch(interval/(time.Duration(build.BlockDelaySecs)*time.Second))
+
+	last, err := r.History(1)
+	if err != nil {
+		return err
+	}
+	if len(last) > 0 && last[0].Report.To > from {
+		from = last[0].Report.To
+	}
+
+	if from >= to {
+		return nil
+	}
+
+	sr, err := r.Generate(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	log.Infow("generated sla report", "from", sr.Report.From, "to", sr.Report.To,
+		"deadlinesMissed", sr.Report.Proving.DeadlinesMissed, "dealsActivated", sr.Report.Deals.Activated)
+
+	return nil
+}
+
+// Generate generates a signed report over the epoch range, and records it in
+// the history
+func (r *Reporter) Generate(ctx context.Context, from, to abi.ChainEpoch) (*api.SignedSLAReport, error) {
+	if from >= to {
+		return nil, xerrors.Errorf("report range start %d must be before its end %d", from, to)
+	}
+
+	head, err := r.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+	if to > head.Height() {
+		return nil, xerrors.Errorf("report range end %d is after the chain head %d", to, head.Height())
+	}
+
+	toTs, err := r.api.ChainGetTipSetByHeight(ctx, to, head.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting tipset at report end: %w", err)
+	}
+
+	mi, err := r.api.StateMinerInfo(ctx, r.maddr, toTs.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner info: %w", err)
+	}
+
+	worker, err := r.api.StateAccountKey(ctx, mi.Worker, toTs.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("resolving worker key: %w", err)
+	}
+
+	proving, err := r.provingReport(ctx, from, toTs)
+	if err != nil {
+		return nil, xerrors.Errorf("computing proving stats: %w", err)
+	}
+
+	deals, err := r.dealReport(ctx, from, to, toTs)
+	if err != nil {
+		return nil, xerrors.Errorf("computing deal stats: %w", err)
+	}
+
+	rep := api.SLAReport{
+		Miner:     r.maddr,
+		Worker:    worker,
+		From:      from,
+		To:        to,
+		Generated: time.Now().UTC().Truncate(time.Second),
+
+		Proving:    proving,
+		Deals:      deals,
+		Retrievals: retrievalStats(r.retrievals()),
+	}
+
+	sb, err := SigningBytes(&rep)
+	if err != nil {
+		return nil, xerrors.Errorf("serializing report: %w", err)
+	}
+
+	sig, err := r.api.WalletSign(ctx, worker, sb)
+	if err != nil {
+		return nil, xerrors.Errorf("signing report: %w", err)
+	}
+
+	sr := &api.SignedSLAReport{
+		Report:    rep,
+		Signature: sig,
+	}
+
+	if err := r.record(sr); err != nil {
+		return nil, err
+	}
+
+	return sr, nil
+}
+
+func (r *Reporter) provingReport(ctx context.Context, from abi.ChainEpoch, toTs *types.TipSet) (api.SLAProvingStats, error) {
+	di, err := r.api.StateMinerProvingDeadline(ctx, r.maddr, toTs.Key())
+	if err != nil {
+		return api.SLAProvingStats{}, xerrors.Errorf("getting proving deadline: %w", err)
+	}
+
+	fromTs, err := r.api.ChainGetTipSetByHeight(ctx, from, toTs.Key())
+	if err != nil {
+		return api.SLAProvingStats{}, xerrors.Errorf("getting tipset at report start: %w", err)
+	}
+
+	baseline := map[uint64][]api.Partition{}
+	for dl := uint64(0); dl < di.WPoStPeriodDeadlines; dl++ {
+		parts, err := r.api.StateMinerPartitions(ctx, r.maddr, dl, fromTs.Key())
+		if err != nil {
+			return api.SLAProvingStats{}, xerrors.Errorf("getting partitions of deadline %d: %w", dl, err)
+		}
+		baseline[dl] = parts
+	}
+
+	// deadlines before the current one, which closed before the end of the
+	// range, newest first
+	var windows []deadlineWindow
+	idx := di.Index
+	for open := di.Open - di.WPoStChallengeWindow; open >= from && open > 0; open -= di.WPoStChallengeWindow {
+		idx = (idx + di.WPoStPeriodDeadlines - 1) % di.WPoStPeriodDeadlines
+		end := open + di.WPoStChallengeWindow
+
+		ts, err := r.api.ChainGetTipSetByHeight(ctx, end, toTs.Key())
+		if err != nil {
+			return api.SLAProvingStats{}, xerrors.Errorf("getting tipset at deadline close: %w", err)
+		}
+
+		parts, err := r.api.StateMinerPartitions(ctx, r.maddr, idx, ts.Key())
+		if err != nil {
+			return api.SLAProvingStats{}, xerrors.Errorf("getting partitions of deadline %d: %w", idx, err)
+		}
+
+		windows = append(windows, deadlineWindow{
+			Index:      idx,
+			Open:       open,
+			Close:      end,
+			Partitions: parts,
+		})
+	}
+
+	for i, j := 0, len(windows)-1; i < j; i, j = i+1, j-1 {
+		windows[i], windows[j] = windows[j], windows[i]
+	}
+
+	posts, err := storage.FindWdPoStSubmissions(ctx, r.api, r.maddr, toTs, from)
+	if err != nil {
+		return api.SLAProvingStats{}, xerrors.Errorf("finding window post submissions: %w", err)
+	}
+
+	subs := make([]submission, len(posts))
+	for i, p := range posts {
+		subs[i] = submission{
+			Deadline: p.Params.Deadline,
+			Height:   p.TipSet.Height(),
+		}
+	}
+
+	return provingStats(baseline, windows, subs)
+}
+
+func (r *Reporter) dealReport(ctx context.Context, from, to abi.ChainEpoch, toTs *types.TipSet) (api.SLADealStats, error) {
+	deals, err := r.deals()
+	if err != nil {
+		return api.SLADealStats{}, xerrors.Errorf("listing deals: %w", err)
+	}
+
+	gen, err := r.api.ChainGetGenesis(ctx)
+	if err != nil {
+		return api.SLADealStats{}, xerrors.Errorf("getting genesis: %w", err)
+	}
+	genTime := time.Unix(int64(gen.MinTimestamp()), 0)
+
+	var latencies []time.Duration
+	for _, d := range deals {
+		// deals are activated before their start epoch
+		if d.DealID == 0 || d.Proposal.StartEpoch < from {
+			continue
+		}
+
+		md, err := r.api.StateMarketStorageDeal(ctx, d.DealID, toTs.Key())
+		if err != nil {
+			log.Debugw("getting market deal", "deal", d.DealID, "error", err)
+			continue
+		}
+
+		act := md.State.SectorStartEpoch
+		if act < from || act > to {
+			continue
+		}
+
+		activated := genTime.Add(time.Duration(uint64(act)*build.BlockDelaySecs) * time.Second)
+		lat := activated.Sub(d.CreationTime.Time())
+		if lat < 0 {
+			lat = 0
+		}
+		latencies = append(latencies, lat)
+	}
+
+	return dealStats(latencies), nil
+}
+
+func (r *Reporter) record(sr *api.SignedSLAReport) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	b, err := json.Marshal(sr)
+	if err != nil {
+		return xerrors.Errorf("marshaling report: %w", err)
+	}
+
+	if err := r.ds.Put(reportKey(sr.Report.To), b); err != nil {
+		return xerrors.Errorf("storing report: %w", err)
+	}
+
+	if err := r.prune(); err != nil {
+		log.Warnf("pruning report history: %+v", err)
+	}
+
+	return nil
+}
+
+// History returns up to limit of the latest reports, newest first; 0 for all
+// recorded reports
+func (r *Reporter) History(limit int) ([]*api.SignedSLAReport, error) {
+	reports, err := r.list()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(reports) > limit {
+		reports = reports[:limit]
+	}
+
+	return reports, nil
+}
+
+func (r *Reporter) list() ([]*api.SignedSLAReport, error) {
+	res, err := r.ds.Query(query.Query{})
+	if err != nil {
+		return nil, xerrors.Errorf("querying report history: %w", err)
+	}
+	defer res.Close() // nolint:errcheck
+
+	var out []*api.SignedSLAReport
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, xerrors.Errorf("reading report history: %w", e.Error)
+		}
+
+		var sr api.SignedSLAReport
+		if err := json.Unmarshal(e.Value, &sr); err != nil {
+			return nil, xerrors.Errorf("unmarshaling report %s: %w", e.Key, err)
+		}
+		out = append(out, &sr)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Report.To > out[j].Report.To
+	})
+
+	return out, nil
+}
+
+func (r *Reporter) prune() error {
+	reports, err := r.list()
+	if err != nil {
+		return err
+	}
+
+	for i := HistorySize; i < len(reports); i++ {
+		if err := r.ds.Delete(reportKey(reports[i].Report.To)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func reportKey(to abi.ChainEpoch) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("/%020d", to))
+}
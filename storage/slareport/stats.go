@@ -0,0 +1,188 @@
+package slareport
+
+import (
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// deadlineWindow is a window PoSt deadline which opened and closed within the
+// report range, with its partitions at the end of the deadline
+type deadlineWindow struct {
+	Index       uint64
+	Open, Close abi.ChainEpoch
+	Partitions  []api.Partition
+}
+
+// submission is a window PoSt which executed successfully on chain
+type submission struct {
+	Deadline uint64
+	Height   abi.ChainEpoch
+}
+
+// provingStats computes proving stats from the partitions of each deadline at
+// the start of the range, and the deadline windows in the range, oldest first.
+// Sectors faulty at the start of the range don't count as new faults, and
+// aren't included in recovery times.
+func provingStats(baseline map[uint64][]api.Partition, windows []deadlineWindow, subs []submission) (api.SLAProvingStats, error) {
+	var out api.SLAProvingStats
+
+	// epoch each faulty sector became faulty at by deadline, -1 when it was
+	// faulty at the start of the range
+	faultSince := map[uint64]map[uint64]abi.ChainEpoch{}
+	for dl, parts := range baseline {
+		faultSince[dl] = map[uint64]abi.ChainEpoch{}
+		for _, p := range parts {
+			if err := p.FaultySectors.ForEach(func(s uint64) error {
+				faultSince[dl][s] = -1
+				return nil
+			}); err != nil {
+				return api.SLAProvingStats{}, err
+			}
+		}
+	}
+
+	var totalRecovery abi.ChainEpoch
+	var timedRecoveries int64
+
+	for _, w := range windows {
+		live := map[uint64]bool{}
+		faulty := map[uint64]bool{}
+		for _, p := range w.Partitions {
+			if err := p.LiveSectors.ForEach(func(s uint64) error {
+				live[s] = true
+				return nil
+			}); err != nil {
+				return api.SLAProvingStats{}, err
+			}
+			if err := p.FaultySectors.ForEach(func(s uint64) error {
+				faulty[s] = true
+				return nil
+			}); err != nil {
+				return api.SLAProvingStats{}, err
+			}
+		}
+
+		if len(live) > 0 {
+			out.DeadlinesDue++
+			if proven(subs, w) {
+				out.DeadlinesMet++
+			} else {
+				out.DeadlinesMissed++
+			}
+		}
+
+		since, ok := faultSince[w.Index]
+		if !ok {
+			since = map[uint64]abi.ChainEpoch{}
+			faultSince[w.Index] = since
+		}
+
+		for s, at := range since {
+			if faulty[s] {
+				continue
+			}
+			delete(since, s)
+
+			// terminated or expired, not recovered
+			if !live[s] {
+				continue
+			}
+
+			out.RecoveredSectors++
+			if at < 0 {
+				continue
+			}
+
+			d := w.Close - at
+			totalRecovery += d
+			timedRecoveries++
+			if d > out.MaxRecoveryEpochs {
+				out.MaxRecoveryEpochs = d
+			}
+		}
+
+		for s := range faulty {
+			if _, ok := since[s]; ok {
+				continue
+			}
+			since[s] = w.Close
+			out.NewFaults++
+		}
+	}
+
+	if timedRecoveries > 0 {
+		out.MeanRecoveryEpochs = totalRecovery / abi.ChainEpoch(timedRecoveries)
+	}
+
+	for _, since := range faultSince {
+		out.FaultySectors += uint64(len(since))
+	}
+
+	return out, nil
+}
+
+func proven(subs []submission, w deadlineWindow) bool {
+	for _, s := range subs {
+		if s.Deadline == w.Index && s.Height >= w.Open && s.Height < w.Close {
+			return true
+		}
+	}
+	return false
+}
+
+// dealStats computes deal stats from the activation latencies of the deals
+// activated within the range
+func dealStats(latencies []time.Duration) api.SLADealStats {
+	out := api.SLADealStats{
+		Activated: uint64(len(latencies)),
+	}
+	if len(latencies) == 0 {
+		return out
+	}
+
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+
+	out.MeanActivationLatency = total / time.Duration(len(sorted))
+	out.MedianActivationLatency = sorted[len(sorted)/2]
+	out.MaxActivationLatency = sorted[len(sorted)-1]
+
+	return out
+}
+
+func retrievalStats(deals []retrievalmarket.ProviderDealState) api.SLARetrievalStats {
+	out := api.SLARetrievalStats{
+		FundsReceived: big.Zero(),
+	}
+
+	for _, d := range deals {
+		switch d.Status {
+		case retrievalmarket.DealStatusCompleted:
+			out.Completed++
+		case retrievalmarket.DealStatusErrored, retrievalmarket.DealStatusRejected, retrievalmarket.DealStatusCancelled:
+			out.Failed++
+		default:
+			out.Ongoing++
+		}
+
+		out.BytesSent += d.TotalSent
+		if d.FundsReceived.Int != nil {
+			out.FundsReceived = big.Add(out.FundsReceived, d.FundsReceived)
+		}
+	}
+
+	return out
+}
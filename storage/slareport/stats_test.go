@@ -0,0 +1,87 @@
+package slareport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func partition(live, faulty []uint64) api.Partition {
+	return api.Partition{
+		LiveSectors:   bitfield.NewFromSet(live),
+		FaultySectors: bitfield.NewFromSet(faulty),
+	}
+}
+
+func TestProvingStats(t *testing.T) {
+	baseline := map[uint64][]api.Partition{
+		0: {partition([]uint64{1, 2, 3}, []uint64{3})},
+		1: {partition(nil, nil)},
+	}
+
+	windows := []deadlineWindow{
+		// sector 2 faults, 3 was faulty already
+		{Index: 0, Open: 100, Close: 160, Partitions: []api.Partition{partition([]uint64{1, 2, 3}, []uint64{2, 3})}},
+		// nothing to prove
+		{Index: 1, Open: 160, Close: 220, Partitions: []api.Partition{partition(nil, nil)}},
+		// both recover
+		{Index: 0, Open: 200, Close: 260, Partitions: []api.Partition{partition([]uint64{1, 2, 3}, nil)}},
+		// missed, all faulty
+		{Index: 0, Open: 300, Close: 360, Partitions: []api.Partition{partition([]uint64{1, 2, 3}, []uint64{1, 2, 3})}},
+	}
+
+	subs := []submission{
+		{Deadline: 0, Height: 120},
+		{Deadline: 0, Height: 210},
+		// landed after the deadline closed
+		{Deadline: 0, Height: 370},
+	}
+
+	st, err := provingStats(baseline, windows, subs)
+	require.NoError(t, err)
+
+	require.Equal(t, api.SLAProvingStats{
+		DeadlinesDue:       3,
+		DeadlinesMet:       2,
+		DeadlinesMissed:    1,
+		NewFaults:          4,
+		RecoveredSectors:   2,
+		FaultySectors:      3,
+		MeanRecoveryEpochs: 100,
+		MaxRecoveryEpochs:  100,
+	}, st)
+}
+
+func TestDealStats(t *testing.T) {
+	require.Equal(t, api.SLADealStats{}, dealStats(nil))
+
+	st := dealStats([]time.Duration{3 * time.Hour, time.Hour, 2 * time.Hour, 10 * time.Hour})
+	require.Equal(t, api.SLADealStats{
+		Activated:               4,
+		MeanActivationLatency:   4 * time.Hour,
+		MedianActivationLatency: 3 * time.Hour,
+		MaxActivationLatency:    10 * time.Hour,
+	}, st)
+}
+
+func TestRetrievalStats(t *testing.T) {
+	st := retrievalStats([]retrievalmarket.ProviderDealState{
+		{Status: retrievalmarket.DealStatusCompleted, TotalSent: 100, FundsReceived: abi.NewTokenAmount(10)},
+		{Status: retrievalmarket.DealStatusErrored, TotalSent: 20, FundsReceived: abi.NewTokenAmount(2)},
+		{Status: retrievalmarket.DealStatusOngoing, TotalSent: 5},
+	})
+
+	require.Equal(t, uint64(1), st.Completed)
+	require.Equal(t, uint64(1), st.Failed)
+	require.Equal(t, uint64(1), st.Ongoing)
+	require.Equal(t, uint64(125), st.BytesSent)
+	require.True(t, big.NewInt(12).Equals(st.FundsReceived))
+}
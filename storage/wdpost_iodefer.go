@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// PoStIOGate holds back non time critical sealing I/O on storage paths
+// holding the given sectors. Implemented by the sector manager.
+type PoStIOGate interface {
+	DeferIO(ctx context.Context, sectors []abi.SectorID) error
+	ResumeIO()
+}
+
+type ioDeferral struct {
+	gate PoStIOGate
+	lead abi.ChainEpoch
+
+	lk sync.Mutex
+	// open epoch of the deadline for which I/O is deferred, -1 if none
+	deferring abi.ChainEpoch
+	// open epoch of the last deadline for which proofs were submitted
+	submitted abi.ChainEpoch
+}
+
+// DeferSealingIO makes the scheduler hold back sealing I/O on storage paths
+// with sectors of a deadline, from lead epochs before the deadline opens until
+// proofs for it are submitted, or the deadline closes.
+func (s *WindowPoStScheduler) DeferSealingIO(gate PoStIOGate, lead abi.ChainEpoch) {
+	s.iod = &ioDeferral{
+		gate:      gate,
+		lead:      lead,
+		deferring: -1,
+		submitted: -1,
+	}
+}
+
+func (s *WindowPoStScheduler) updateIODeferral(ctx context.Context, ts *types.TipSet) error {
+	if s.iod == nil {
+		return nil
+	}
+
+	di, err := s.api.StateMinerProvingDeadline(ctx, s.actor, ts.Key())
+	if err != nil {
+		return xerrors.Errorf("getting proving deadline: %w", err)
+	}
+
+	s.iod.lk.Lock()
+	submitted := s.iod.submitted
+	deferring := s.iod.deferring
+	s.iod.lk.Unlock()
+
+	var target *dline.Info
+	if di.Open != submitted {
+		target = di
+	} else if next := nextDeadline(di); ts.Height() >= next.Open-s.iod.lead {
+		target = next
+	}
+
+	if target == nil {
+		if deferring != -1 {
+			s.resumeIO(deferring)
+		}
+		return nil
+	}
+
+	if target.Open == deferring {
+		return nil
+	}
+
+	sectors, err := s.deadlineSectors(ctx, target, ts.Key())
+	if err != nil {
+		return err
+	}
+
+	if len(sectors) == 0 {
+		if deferring != -1 {
+			s.resumeIO(deferring)
+		}
+		return nil
+	}
+
+	if err := s.iod.gate.DeferIO(ctx, sectors); err != nil {
+		return xerrors.Errorf("deferring sealing I/O: %w", err)
+	}
+
+	s.iod.lk.Lock()
+	s.iod.deferring = target.Open
+	s.iod.lk.Unlock()
+
+	return nil
+}
+
+func (s *WindowPoStScheduler) deadlineSectors(ctx context.Context, di *dline.Info, tsk types.TipSetKey) ([]abi.SectorID, error) {
+	mid, err := address.IDFromAddress(s.actor)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, err := s.api.StateMinerPartitions(ctx, s.actor, di.Index, tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("getting partitions: %w", err)
+	}
+
+	var out []abi.SectorID
+	for _, partition := range partitions {
+		if err := partition.LiveSectors.ForEach(func(sno uint64) error {
+			out = append(out, abi.SectorID{
+				Miner:  abi.ActorID(mid),
+				Number: abi.SectorNumber(sno),
+			})
+			return nil
+		}); err != nil {
+			return nil, xerrors.Errorf("iterating live sectors: %w", err)
+		}
+	}
+
+	return out, nil
+}
+
+// ioSubmitted is called after proofs for the deadline were submitted
+func (s *WindowPoStScheduler) ioSubmitted(deadline *dline.Info) {
+	if s.iod == nil {
+		return
+	}
+
+	s.iod.lk.Lock()
+	s.iod.submitted = deadline.Open
+	s.iod.lk.Unlock()
+
+	s.resumeIO(deadline.Open)
+}
+
+// resumeIO resumes sealing I/O if it's still deferred for the deadline opening
+// at the given epoch
+func (s *WindowPoStScheduler) resumeIO(open abi.ChainEpoch) {
+	s.iod.lk.Lock()
+	if s.iod.deferring != open {
+		s.iod.lk.Unlock()
+		return
+	}
+	s.iod.deferring = -1
+	s.iod.lk.Unlock()
+
+	s.iod.gate.ResumeIO()
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type deadlinePrecheck struct {
+	lead abi.ChainEpoch
+
+	lk      sync.Mutex
+	running bool
+	// open epoch of the last deadline which was checked
+	checked abi.ChainEpoch
+	results map[uint64]api.PoStPrecheck
+}
+
+// PrecheckDeadlines makes the scheduler check sectors of each deadline lead
+// epochs before it opens, and declare faults for sectors which fail the check,
+// so that they are skipped instead of failing the proof of their partition.
+func (s *WindowPoStScheduler) PrecheckDeadlines(lead abi.ChainEpoch) {
+	s.precheck = &deadlinePrecheck{
+		lead:    lead,
+		checked: -1,
+		results: map[uint64]api.PoStPrecheck{},
+	}
+}
+
+// Prechecks returns the last check result of each deadline
+func (s *WindowPoStScheduler) Prechecks() []api.PoStPrecheck {
+	if s.precheck == nil {
+		return nil
+	}
+
+	s.precheck.lk.Lock()
+	defer s.precheck.lk.Unlock()
+
+	out := make([]api.PoStPrecheck, 0, len(s.precheck.results))
+	for _, r := range s.precheck.results {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Deadline < out[j].Deadline
+	})
+
+	return out
+}
+
+func (s *WindowPoStScheduler) updatePrecheck(ctx context.Context, ts *types.TipSet) error {
+	if s.precheck == nil {
+		return nil
+	}
+
+	s.precheck.lk.Lock()
+	defer s.precheck.lk.Unlock()
+
+	if s.precheck.running {
+		// deadlines which came into range in the meantime are checked once the
+		// running check is done
+		return nil
+	}
+
+	di, err := s.api.StateMinerProvingDeadline(ctx, s.actor, ts.Key())
+	if err != nil {
+		return xerrors.Errorf("getting proving deadline: %w", err)
+	}
+
+	// the first deadline in range which wasn't checked yet
+	var target *dline.Info
+	for next := nextDeadline(di); ts.Height() >= next.Open-s.precheck.lead; next = nextDeadline(next) {
+		if next.Open > s.precheck.checked {
+			target = next
+			break
+		}
+	}
+
+	if target == nil {
+		return nil
+	}
+
+	s.precheck.running = true
+	go func() {
+		res := s.runPrecheck(ctx, target, ts)
+
+		s.precheck.lk.Lock()
+		s.precheck.running = false
+		s.precheck.checked = target.Open
+		s.precheck.results[target.Index] = res
+		s.precheck.lk.Unlock()
+	}()
+
+	return nil
+}
+
+func (s *WindowPoStScheduler) runPrecheck(ctx context.Context, di *dline.Info, ts *types.TipSet) api.PoStPrecheck {
+	res := api.PoStPrecheck{
+		Deadline: di.Index,
+		Open:     di.Open,
+		Height:   ts.Height(),
+	}
+
+	params, err := s.precheckPartitions(ctx, di, ts.Key(), &res)
+	if err != nil {
+		log.Errorw("pre-checking deadline sectors", "deadline", di.Index, "open", di.Open, "error", err)
+		res.Error = err.Error()
+		return res
+	}
+
+	if len(params.Faults) == 0 {
+		return res
+	}
+
+	head, err := s.api.ChainHead(ctx)
+	if err != nil {
+		res.Error = xerrors.Errorf("getting chain head: %w", err).Error()
+		return res
+	}
+	if head.Height() >= di.FaultCutoff {
+		log.Errorw("sectors failed pre-check after the fault cutoff", "deadline", di.Index, "cutoff", di.FaultCutoff)
+		res.Error = xerrors.Errorf("too late to declare faults, fault cutoff was at epoch %d", di.FaultCutoff).Error()
+		return res
+	}
+
+	log.Errorw("DETECTED FAULTY SECTORS of upcoming deadline, declaring faults", "deadline", di.Index, "partitions", len(params.Faults))
+
+	sm, err := s.declareFaults(ctx, params)
+	if sm != nil {
+		c := sm.Cid()
+		res.DeclareMessage = &c
+	}
+	if err != nil {
+		res.Error = xerrors.Errorf("declaring faults: %w", err).Error()
+	}
+
+	s.journal.RecordEvent(s.evtTypes[evtTypeWdPoStFaults], func() interface{} {
+		j := WdPoStFaultsProcessedEvt{
+			evtCommon:    s.getEvtCommon(err),
+			Declarations: params.Faults,
+		}
+		if sm != nil {
+			j.MessageCID = sm.Cid()
+		}
+		return j
+	})
+
+	return res
+}
+
+// precheckPartitions checks the non-faulty sectors of all partitions of the
+// deadline, and returns fault declarations for sectors which failed the check
+func (s *WindowPoStScheduler) precheckPartitions(ctx context.Context, di *dline.Info, tsk types.TipSetKey, res *api.PoStPrecheck) (*miner.DeclareFaultsParams, error) {
+	partitions, err := s.api.StateMinerPartitions(ctx, s.actor, di.Index, tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("getting partitions: %w", err)
+	}
+
+	params := &miner.DeclareFaultsParams{
+		Faults: []miner.FaultDeclaration{},
+	}
+
+	for partIdx, partition := range partitions {
+		nonFaulty, err := bitfield.SubtractBitField(partition.LiveSectors, partition.FaultySectors)
+		if err != nil {
+			return nil, xerrors.Errorf("determining non faulty sectors: %w", err)
+		}
+
+		checked, err := nonFaulty.Count()
+		if err != nil {
+			return nil, xerrors.Errorf("counting sectors: %w", err)
+		}
+
+		if checked == 0 {
+			continue
+		}
+
+		good, err := s.checkSectors(ctx, nonFaulty, tsk)
+		if err != nil {
+			return nil, xerrors.Errorf("checking sectors: %w", err)
+		}
+
+		faulty, err := bitfield.SubtractBitField(nonFaulty, good)
+		if err != nil {
+			return nil, xerrors.Errorf("calculating faulty sector set: %w", err)
+		}
+
+		res.Partitions = append(res.Partitions, api.PoStPrecheckPartition{
+			Index:   uint64(partIdx),
+			Checked: checked,
+			Faulty:  faulty,
+		})
+
+		c, err := faulty.Count()
+		if err != nil {
+			return nil, xerrors.Errorf("counting faulty sectors: %w", err)
+		}
+
+		if c == 0 {
+			continue
+		}
+
+		params.Faults = append(params.Faults, miner.FaultDeclaration{
+			Deadline:  di.Index,
+			Partition: uint64(partIdx),
+			Sectors:   faulty,
+		})
+	}
+
+	return params, nil
+}
+
+// PoStPrechecks returns the results of the last pre-checks of window PoSt
+// deadlines
+func (m *Miner) PoStPrechecks() []api.PoStPrecheck {
+	if m.wdpost == nil {
+		return nil
+	}
+	return m.wdpost.Prechecks()
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// recoveryTracker keeps the result of the last recovery check of each
+// deadline. Recoveries of faulty sectors of a deadline are checked, and
+// declared, when proving the deadline two deadlines before it.
+type recoveryTracker struct {
+	lk      sync.Mutex
+	results map[uint64]api.PoStRecovery
+}
+
+// DisableRecoveryDeclarations stops the scheduler from declaring recoveries of
+// faulty sectors which can be proven again. Faulty sectors are still checked,
+// and the results are listed by Recoveries.
+func (s *WindowPoStScheduler) DisableRecoveryDeclarations() {
+	s.noRecoveries = true
+}
+
+// Recoveries returns the last recovery check result of each deadline with
+// faulty sectors
+func (s *WindowPoStScheduler) Recoveries() []api.PoStRecovery {
+	s.recoveries.lk.Lock()
+	defer s.recoveries.lk.Unlock()
+
+	out := make([]api.PoStRecovery, 0, len(s.recoveries.results))
+	for _, r := range s.recoveries.results {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Deadline < out[j].Deadline
+	})
+
+	return out
+}
+
+func (s *WindowPoStScheduler) recordRecoveries(res api.PoStRecovery, sm *types.SignedMessage, err error) {
+	if sm != nil {
+		c := sm.Cid()
+		res.DeclareMessage = &c
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	res.Declared = sm != nil && err == nil
+
+	s.recoveries.lk.Lock()
+	defer s.recoveries.lk.Unlock()
+
+	if s.recoveries.results == nil {
+		s.recoveries.results = map[uint64]api.PoStRecovery{}
+	}
+
+	if len(res.Partitions) == 0 && res.Error == "" {
+		// no faulty sectors left
+		delete(s.recoveries.results, res.Deadline)
+		return
+	}
+
+	s.recoveries.results[res.Deadline] = res
+}
+
+// PoStRecoveries returns the results of the last recovery checks of faulty
+// sectors of window PoSt deadlines
+func (m *Miner) PoStRecoveries() []api.PoStRecovery {
+	if m.wdpost == nil {
+		return nil
+	}
+	return m.wdpost.Recoveries()
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	"sync"
 	"time"
 
 	"github.com/filecoin-project/go-bitfield"
@@ -28,6 +29,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/actors/policy"
 	"github.com/filecoin-project/lotus/chain/messagepool"
 	"github.com/filecoin-project/lotus/chain/types"
+	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 )
 
 func (s *WindowPoStScheduler) failPost(err error, ts *types.TipSet, deadline *dline.Info) {
@@ -126,6 +128,8 @@ func (s *WindowPoStScheduler) startSubmitPoST(
 
 		err := s.runSubmitPoST(ctx, ts, deadline, posts)
 		if err == nil {
+			s.ioSubmitted(deadline)
+
 			s.journal.RecordEvent(s.evtTypes[evtTypeWdPoStScheduler], func() interface{} {
 				return WdPoStSchedulerEvt{
 					evtCommon: s.getEvtCommon(nil),
@@ -202,9 +206,11 @@ func (s *WindowPoStScheduler) checkSectors(ctx context.Context, check bitfield.B
 	}
 
 	sectors := make(map[abi.SectorNumber]struct{})
+	commRs := make(map[abi.SectorNumber]cid.Cid)
 	var tocheck []storage.SectorRef
 	for _, info := range sectorInfos {
 		sectors[info.SectorNumber] = struct{}{}
+		commRs[info.SectorNumber] = info.SealedCID
 		tocheck = append(tocheck, storage.SectorRef{
 			ProofType: info.SealProof,
 			ID: abi.SectorID{
@@ -214,7 +220,17 @@ func (s *WindowPoStScheduler) checkSectors(ctx context.Context, check bitfield.B
 		})
 	}
 
-	bad, err := s.faultTracker.CheckProvable(ctx, s.proofType, tocheck, nil)
+	// a sample of the sectors have their sealed data read, to find corrupt
+	// data before the proof fails
+	rg := func(ctx context.Context, id abi.SectorID) (cid.Cid, error) {
+		commR, ok := commRs[id.Number]
+		if !ok {
+			return cid.Undef, xerrors.Errorf("sector %d not checked", id.Number)
+		}
+		return commR, nil
+	}
+
+	bad, err := sectorstorage.CheckProvableSampled(ctx, s.faultTracker, s.proofType, tocheck, s.checkSampleRate, rg)
 	if err != nil {
 		return bitfield.BitField{}, xerrors.Errorf("checking provable sectors: %w", err)
 	}
@@ -232,7 +248,7 @@ func (s *WindowPoStScheduler) checkSectors(ctx context.Context, check bitfield.B
 	return sbf, nil
 }
 
-func (s *WindowPoStScheduler) checkNextRecoveries(ctx context.Context, dlIdx uint64, partitions []api.Partition, tsk types.TipSetKey) ([]miner.RecoveryDeclaration, *types.SignedMessage, error) {
+func (s *WindowPoStScheduler) checkNextRecoveries(ctx context.Context, dlIdx uint64, partitions []api.Partition, tsk types.TipSetKey, res *api.PoStRecovery) ([]miner.RecoveryDeclaration, *types.SignedMessage, error) {
 	ctx, span := trace.StartSpan(ctx, "storage.checkNextRecoveries")
 	defer span.End()
 
@@ -263,6 +279,12 @@ func (s *WindowPoStScheduler) checkNextRecoveries(ctx context.Context, dlIdx uin
 			return nil, nil, xerrors.Errorf("checking unrecovered sectors: %w", err)
 		}
 
+		res.Partitions = append(res.Partitions, api.PoStRecoveryPartition{
+			Index:     uint64(partIdx),
+			Faulty:    unrecovered,
+			Recovered: recovered,
+		})
+
 		// if all sectors failed to recover, don't declare recoveries
 		recoveredCount, err := recovered.Count()
 		if err != nil {
@@ -289,9 +311,19 @@ func (s *WindowPoStScheduler) checkNextRecoveries(ctx context.Context, dlIdx uin
 		return recoveries, nil, nil
 	}
 
+	if s.noRecoveries {
+		log.Warnw("Faulty sectors can be proven again, not declaring recoveries as declarations are disabled", "deadline", dlIdx, "partitions", len(recoveries))
+		return nil, nil, nil
+	}
+
+	sm, err := s.declareRecoveries(ctx, params)
+	return recoveries, sm, err
+}
+
+func (s *WindowPoStScheduler) declareRecoveries(ctx context.Context, params *miner.DeclareFaultsRecoveredParams) (*types.SignedMessage, error) {
 	enc, aerr := actors.SerializeParams(params)
 	if aerr != nil {
-		return recoveries, nil, xerrors.Errorf("could not serialize declare recoveries parameters: %w", aerr)
+		return nil, xerrors.Errorf("could not serialize declare recoveries parameters: %w", aerr)
 	}
 
 	msg := &types.Message{
@@ -302,26 +334,26 @@ func (s *WindowPoStScheduler) checkNextRecoveries(ctx context.Context, dlIdx uin
 	}
 	spec := &api.MessageSendSpec{MaxFee: abi.TokenAmount(s.feeCfg.MaxWindowPoStGasFee)}
 	if err := s.setSender(ctx, msg, spec); err != nil {
-		return recoveries, nil, err
+		return nil, err
 	}
 
 	sm, err := s.api.MpoolPushMessage(ctx, msg, &api.MessageSendSpec{MaxFee: abi.TokenAmount(s.feeCfg.MaxWindowPoStGasFee)})
 	if err != nil {
-		return recoveries, sm, xerrors.Errorf("pushing message to mpool: %w", err)
+		return sm, xerrors.Errorf("pushing message to mpool: %w", err)
 	}
 
 	log.Warnw("declare faults recovered Message CID", "cid", sm.Cid())
 
 	rec, err := s.api.StateWaitMsg(context.TODO(), sm.Cid(), build.MessageConfidence)
 	if err != nil {
-		return recoveries, sm, xerrors.Errorf("declare faults recovered wait error: %w", err)
+		return sm, xerrors.Errorf("declare faults recovered wait error: %w", err)
 	}
 
 	if rec.Receipt.ExitCode != 0 {
-		return recoveries, sm, xerrors.Errorf("declare faults recovered wait non-0 exit code: %d", rec.Receipt.ExitCode)
+		return sm, xerrors.Errorf("declare faults recovered wait non-0 exit code: %d", rec.Receipt.ExitCode)
 	}
 
-	return recoveries, sm, nil
+	return sm, nil
 }
 
 func (s *WindowPoStScheduler) checkNextFaults(ctx context.Context, dlIdx uint64, partitions []api.Partition, tsk types.TipSetKey) ([]miner.FaultDeclaration, *types.SignedMessage, error) {
@@ -374,9 +406,14 @@ func (s *WindowPoStScheduler) checkNextFaults(ctx context.Context, dlIdx uint64,
 
 	log.Errorw("DETECTED FAULTY SECTORS, declaring faults", "count", bad)
 
+	sm, err := s.declareFaults(ctx, params)
+	return faults, sm, err
+}
+
+func (s *WindowPoStScheduler) declareFaults(ctx context.Context, params *miner.DeclareFaultsParams) (*types.SignedMessage, error) {
 	enc, aerr := actors.SerializeParams(params)
 	if aerr != nil {
-		return faults, nil, xerrors.Errorf("could not serialize declare faults parameters: %w", aerr)
+		return nil, xerrors.Errorf("could not serialize declare faults parameters: %w", aerr)
 	}
 
 	msg := &types.Message{
@@ -387,26 +424,26 @@ func (s *WindowPoStScheduler) checkNextFaults(ctx context.Context, dlIdx uint64,
 	}
 	spec := &api.MessageSendSpec{MaxFee: abi.TokenAmount(s.feeCfg.MaxWindowPoStGasFee)}
 	if err := s.setSender(ctx, msg, spec); err != nil {
-		return faults, nil, err
+		return nil, err
 	}
 
 	sm, err := s.api.MpoolPushMessage(ctx, msg, spec)
 	if err != nil {
-		return faults, sm, xerrors.Errorf("pushing message to mpool: %w", err)
+		return sm, xerrors.Errorf("pushing message to mpool: %w", err)
 	}
 
 	log.Warnw("declare faults Message CID", "cid", sm.Cid())
 
 	rec, err := s.api.StateWaitMsg(context.TODO(), sm.Cid(), build.MessageConfidence)
 	if err != nil {
-		return faults, sm, xerrors.Errorf("declare faults wait error: %w", err)
+		return sm, xerrors.Errorf("declare faults wait error: %w", err)
 	}
 
 	if rec.Receipt.ExitCode != 0 {
-		return faults, sm, xerrors.Errorf("declare faults wait non-0 exit code: %d", rec.Receipt.ExitCode)
+		return sm, xerrors.Errorf("declare faults wait non-0 exit code: %d", rec.Receipt.ExitCode)
 	}
 
-	return faults, sm, nil
+	return sm, nil
 }
 
 func (s *WindowPoStScheduler) runPost(ctx context.Context, di dline.Info, ts *types.TipSet) ([]miner.SubmitWindowedPoStParams, error) {
@@ -442,10 +479,15 @@ func (s *WindowPoStScheduler) runPost(ctx context.Context, di dline.Info, ts *ty
 			}
 		)
 
-		if recoveries, sigmsg, err = s.checkNextRecoveries(context.TODO(), declDeadline, partitions, ts.Key()); err != nil {
+		res := api.PoStRecovery{
+			Deadline: declDeadline,
+			Height:   ts.Height(),
+		}
+		if recoveries, sigmsg, err = s.checkNextRecoveries(context.TODO(), declDeadline, partitions, ts.Key(), &res); err != nil {
 			// TODO: This is potentially quite bad, but not even trying to post when this fails is objectively worse
 			log.Errorf("checking sector recoveries: %v", err)
 		}
+		s.recordRecoveries(res, sigmsg, err)
 
 		s.journal.RecordEvent(s.evtTypes[evtTypeWdPoStRecoveries], func() interface{} {
 			j := WdPoStRecoveriesProcessedEvt{
@@ -498,148 +540,231 @@ func (s *WindowPoStScheduler) runPost(ctx context.Context, di dline.Info, ts *ty
 		return nil, err
 	}
 
-	// Generate proofs in batches
-	posts := make([]miner.SubmitWindowedPoStParams, 0, len(partitionBatches))
+	// Generate proofs of batches in parallel, up to the configured limit
+	results := make([]*miner.SubmitWindowedPoStParams, len(partitionBatches))
+	errs := make([]error, len(partitionBatches))
+	parallel := s.parallelProofs
+	if parallel < 1 {
+		parallel = 1
+	}
+	throttle := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	batchPartitionStartIdx := 0
 	for batchIdx, batch := range partitionBatches {
-		batchPartitionStartIdx := 0
-		for _, batch := range partitionBatches[:batchIdx] {
-			batchPartitionStartIdx += len(batch)
-		}
-
-		params := miner.SubmitWindowedPoStParams{
-			Deadline:   di.Index,
-			Partitions: make([]miner.PoStPartition, 0, len(batch)),
-			Proofs:     nil,
-		}
-
-		skipCount := uint64(0)
-		postSkipped := bitfield.New()
-		somethingToProve := false
-
-		// Retry until we run out of sectors to prove.
-		for retries := 0; ; retries++ {
-			var partitions []miner.PoStPartition
-			var sinfos []proof2.SectorInfo
-			for partIdx, partition := range batch {
-				// TODO: Can do this in parallel
-				toProve, err := bitfield.SubtractBitField(partition.LiveSectors, partition.FaultySectors)
-				if err != nil {
-					return nil, xerrors.Errorf("removing faults from set of sectors to prove: %w", err)
-				}
-				toProve, err = bitfield.MergeBitFields(toProve, partition.RecoveringSectors)
-				if err != nil {
-					return nil, xerrors.Errorf("adding recoveries to set of sectors to prove: %w", err)
-				}
+		wg.Add(1)
+		go func(batchIdx, startIdx int, batch []api.Partition) {
+			defer wg.Done()
+
+			select {
+			case throttle <- struct{}{}:
+			case <-ctx.Done():
+				errs[batchIdx] = ctx.Err()
+				return
+			}
+			defer func() {
+				<-throttle
+			}()
 
-				good, err := s.checkSectors(ctx, toProve, ts.Key())
-				if err != nil {
-					return nil, xerrors.Errorf("checking sectors to skip: %w", err)
-				}
+			results[batchIdx], errs[batchIdx] = s.proveBatch(ctx, di, ts, rand, batchIdx, startIdx, batch)
+		}(batchIdx, batchPartitionStartIdx, batch)
 
-				good, err = bitfield.SubtractBitField(good, postSkipped)
-				if err != nil {
-					return nil, xerrors.Errorf("toProve - postSkipped: %w", err)
-				}
+		batchPartitionStartIdx += len(batch)
+	}
+	wg.Wait()
 
-				skipped, err := bitfield.SubtractBitField(toProve, good)
-				if err != nil {
-					return nil, xerrors.Errorf("toProve - good: %w", err)
-				}
+	if ctx.Err() != nil {
+		log.Warnw("aborting PoSt due to context cancellation", "error", ctx.Err(), "deadline", di.Index)
+		return nil, ctx.Err()
+	}
 
-				sc, err := skipped.Count()
-				if err != nil {
-					return nil, xerrors.Errorf("getting skipped sector count: %w", err)
-				}
+	// Submit proofs of the batches which were proven, the partitions of failed
+	// batches will be marked faulty
+	posts := make([]miner.SubmitWindowedPoStParams, 0, len(partitionBatches))
+	var failed error
+	for batchIdx, params := range results {
+		if err := errs[batchIdx]; err != nil {
+			log.Errorw("generating window post for partition batch failed", "deadline", di.Index, "batch", batchIdx, "error", err)
+			failed = err
+			continue
+		}
 
-				skipCount += sc
+		// Nothing to prove for this batch
+		if params == nil {
+			continue
+		}
 
-				ssi, err := s.sectorsForProof(ctx, good, partition.AllSectors, ts)
-				if err != nil {
-					return nil, xerrors.Errorf("getting sorted sector info: %w", err)
-				}
+		posts = append(posts, *params)
+	}
 
-				if len(ssi) == 0 {
-					continue
-				}
+	if len(posts) == 0 && failed != nil {
+		return nil, failed
+	}
+
+	return posts, nil
+}
+
+// ProofRetryBackoff is how long generating a window PoSt proof waits before
+// it's retried after failing for reasons other than unprovable sectors. The
+// wait doubles with each failure, up to MaxProofRetryBackoff.
+var (
+	ProofRetryBackoff    = 10 * time.Second
+	MaxProofRetryBackoff = 2 * time.Minute
+)
 
-				sinfos = append(sinfos, ssi...)
-				partitions = append(partitions, miner.PoStPartition{
-					Index:   uint64(batchPartitionStartIdx + partIdx),
-					Skipped: skipped,
-				})
+// proofRetryBackoff returns how long to wait before retrying a proof after the
+// given number of failures
+func proofRetryBackoff(failures int) time.Duration {
+	d := ProofRetryBackoff
+	for i := 1; i < failures && d < MaxProofRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > MaxProofRetryBackoff {
+		d = MaxProofRetryBackoff
+	}
+	return d
+}
+
+// proveBatch generates the proof of a batch of partitions submitted in a
+// single message, retrying without sectors which failed to be proven. It
+// returns nil if there is nothing to prove.
+func (s *WindowPoStScheduler) proveBatch(ctx context.Context, di dline.Info, ts *types.TipSet, rand abi.Randomness, batchIdx, batchPartitionStartIdx int, batch []api.Partition) (*miner.SubmitWindowedPoStParams, error) {
+	params := &miner.SubmitWindowedPoStParams{
+		Deadline:   di.Index,
+		Partitions: make([]miner.PoStPartition, 0, len(batch)),
+		Proofs:     nil,
+	}
+
+	skipCount := uint64(0)
+	postSkipped := bitfield.New()
+	failures := 0
+
+	// Retry until we run out of sectors to prove.
+	for retries := 0; ; retries++ {
+		var partitions []miner.PoStPartition
+		var sinfos []proof2.SectorInfo
+		for partIdx, partition := range batch {
+			toProve, err := bitfield.SubtractBitField(partition.LiveSectors, partition.FaultySectors)
+			if err != nil {
+				return nil, xerrors.Errorf("removing faults from set of sectors to prove: %w", err)
+			}
+			toProve, err = bitfield.MergeBitFields(toProve, partition.RecoveringSectors)
+			if err != nil {
+				return nil, xerrors.Errorf("adding recoveries to set of sectors to prove: %w", err)
+			}
+
+			good, err := s.checkSectors(ctx, toProve, ts.Key())
+			if err != nil {
+				return nil, xerrors.Errorf("checking sectors to skip: %w", err)
+			}
+
+			good, err = bitfield.SubtractBitField(good, postSkipped)
+			if err != nil {
+				return nil, xerrors.Errorf("toProve - postSkipped: %w", err)
 			}
 
-			if len(sinfos) == 0 {
-				// nothing to prove for this batch
-				break
+			skipped, err := bitfield.SubtractBitField(toProve, good)
+			if err != nil {
+				return nil, xerrors.Errorf("toProve - good: %w", err)
 			}
 
-			// Generate proof
-			log.Infow("running window post",
-				"chain-random", rand,
-				"deadline", di,
-				"height", ts.Height(),
-				"skipped", skipCount)
+			sc, err := skipped.Count()
+			if err != nil {
+				return nil, xerrors.Errorf("getting skipped sector count: %w", err)
+			}
 
-			tsStart := build.Clock.Now()
+			skipCount += sc
 
-			mid, err := address.IDFromAddress(s.actor)
+			ssi, err := s.sectorsForProof(ctx, good, partition.AllSectors, ts)
 			if err != nil {
-				return nil, err
+				return nil, xerrors.Errorf("getting sorted sector info: %w", err)
 			}
 
-			postOut, ps, err := s.prover.GenerateWindowPoSt(ctx, abi.ActorID(mid), sinfos, abi.PoStRandomness(rand))
-			elapsed := time.Since(tsStart)
+			if len(ssi) == 0 {
+				continue
+			}
 
-			log.Infow("computing window post", "batch", batchIdx, "elapsed", elapsed)
+			sinfos = append(sinfos, ssi...)
+			partitions = append(partitions, miner.PoStPartition{
+				Index:   uint64(batchPartitionStartIdx + partIdx),
+				Skipped: skipped,
+			})
+		}
 
-			if err == nil {
-				if len(postOut) == 0 {
-					return nil, xerrors.Errorf("received no proofs back from generate window post")
-				}
+		if len(sinfos) == 0 {
+			// nothing to prove for this batch
+			return nil, nil
+		}
+
+		// Generate proof
+		log.Infow("running window post",
+			"chain-random", rand,
+			"deadline", di,
+			"batch", batchIdx,
+			"height", ts.Height(),
+			"skipped", skipCount)
 
-				// Proof generation successful, stop retrying
-				somethingToProve = true
-				params.Partitions = partitions
-				params.Proofs = postOut
-				break
+		tsStart := build.Clock.Now()
+
+		mid, err := address.IDFromAddress(s.actor)
+		if err != nil {
+			return nil, err
+		}
+
+		postOut, ps, err := s.prover.GenerateWindowPoSt(ctx, abi.ActorID(mid), sinfos, abi.PoStRandomness(rand))
+		elapsed := time.Since(tsStart)
+
+		log.Infow("computing window post", "batch", batchIdx, "elapsed", elapsed)
+
+		if err == nil {
+			if len(postOut) == 0 {
+				return nil, xerrors.Errorf("received no proofs back from generate window post")
 			}
 
-			// Proof generation failed, so retry
+			// Proof generation successful, stop retrying
+			params.Partitions = partitions
+			params.Proofs = postOut
+			return params, nil
+		}
+
+		// Proof generation failed, so retry
+
+		// Explicitly make sure we haven't aborted this PoSt
+		// (GenerateWindowPoSt may or may not check this).
+		// Otherwise, we could try to continue proving a
+		// deadline after the deadline has ended.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 
-			if len(ps) == 0 {
-				// If we didn't skip any new sectors, we failed
-				// for some other reason and we need to abort.
+		if len(ps) == 0 {
+			// If we didn't skip any new sectors, we failed for some other
+			// reason, e.g. the prover being unavailable; retry the same
+			// sectors as long as retries are left
+			failures++
+			if failures > s.proofRetries {
 				return nil, xerrors.Errorf("running window post failed: %w", err)
 			}
-			// TODO: maybe mark these as faulty somewhere?
 
-			log.Warnw("generate window post skipped sectors", "sectors", ps, "error", err, "try", retries)
+			backoff := proofRetryBackoff(failures)
+			log.Warnw("generate window post failed, retrying", "batch", batchIdx, "error", err, "try", retries, "backoff", backoff)
 
-			// Explicitly make sure we haven't aborted this PoSt
-			// (GenerateWindowPoSt may or may not check this).
-			// Otherwise, we could try to continue proving a
-			// deadline after the deadline has ended.
-			if ctx.Err() != nil {
-				log.Warnw("aborting PoSt due to context cancellation", "error", ctx.Err(), "deadline", di.Index)
+			select {
+			case <-build.Clock.After(backoff):
+			case <-ctx.Done():
 				return nil, ctx.Err()
 			}
-
-			skipCount += uint64(len(ps))
-			for _, sector := range ps {
-				postSkipped.Set(uint64(sector.Number))
-			}
-		}
-
-		// Nothing to prove for this batch, try the next batch
-		if !somethingToProve {
 			continue
 		}
+		// TODO: maybe mark these as faulty somewhere?
 
-		posts = append(posts, params)
-	}
+		log.Warnw("generate window post skipped sectors", "batch", batchIdx, "sectors", ps, "error", err, "try", retries)
 
-	return posts, nil
+		skipCount += uint64(len(ps))
+		for _, sector := range ps {
+			postSkipped.Set(uint64(sector.Number))
+		}
+	}
 }
 
 func (s *WindowPoStScheduler) batchPartitions(partitions []api.Partition) ([][]api.Partition, error) {
@@ -658,6 +783,12 @@ func (s *WindowPoStScheduler) batchPartitions(partitions []api.Partition) ([][]a
 		return nil, xerrors.Errorf("getting sectors per partition: %w", err)
 	}
 
+	// Smaller batches can be proven in parallel, at the cost of sending more
+	// messages
+	if s.maxPartitionsPerMsg > 0 && s.maxPartitionsPerMsg < partitionsPerMsg {
+		partitionsPerMsg = s.maxPartitionsPerMsg
+	}
+
 	// The number of messages will be:
 	// ceiling(number of partitions / partitions per message)
 	batchCount := len(partitions) / partitionsPerMsg
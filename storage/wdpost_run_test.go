@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -214,6 +215,168 @@ func TestWDPostDoPost(t *testing.T) {
 	}
 }
 
+// TestWDPostParallelBatches verifies that with a limit of partitions per
+// message, proofs of all partitions are generated in parallel and submitted
+func TestWDPostParallelBatches(t *testing.T) {
+	ctx := context.Background()
+
+	proofType := abi.RegisteredPoStProof_StackedDrgWindow2KiBV1
+	postAct := tutils.NewIDAddr(t, 100)
+
+	mockStgMinerAPI := newMockStorageMinerAPI()
+
+	sectorsPerPartition, err := builtin2.PoStProofWindowPoStPartitionSectors(proofType)
+	require.NoError(t, err)
+
+	partitionCount := 5
+	var partitions []api.Partition
+	for p := 0; p < partitionCount; p++ {
+		sectors := bitfield.New()
+		for s := uint64(0); s < sectorsPerPartition; s++ {
+			sectors.Set(s)
+		}
+		partitions = append(partitions, api.Partition{
+			AllSectors:        sectors,
+			FaultySectors:     bitfield.New(),
+			RecoveringSectors: bitfield.New(),
+			LiveSectors:       sectors,
+			ActiveSectors:     sectors,
+		})
+	}
+	mockStgMinerAPI.setPartitions(partitions)
+
+	scheduler := &WindowPoStScheduler{
+		api:          mockStgMinerAPI,
+		prover:       &mockProver{},
+		faultTracker: &mockFaultTracker{},
+		proofType:    proofType,
+		actor:        postAct,
+		journal:      journal.NilJournal(),
+		addrSel:      &AddressSelector{},
+	}
+	scheduler.SetProvingLimits(2, 3, 0)
+
+	di := &dline.Info{
+		WPoStPeriodDeadlines:   miner2.WPoStPeriodDeadlines,
+		WPoStProvingPeriod:     miner2.WPoStProvingPeriod,
+		WPoStChallengeWindow:   miner2.WPoStChallengeWindow,
+		WPoStChallengeLookback: miner2.WPoStChallengeLookback,
+		FaultDeclarationCutoff: miner2.FaultDeclarationCutoff,
+	}
+	ts := mockTipSet(t)
+
+	scheduler.startGeneratePoST(ctx, ts, di, func(posts []miner.SubmitWindowedPoStParams, err error) {
+		scheduler.startSubmitPoST(ctx, ts, di, posts, func(err error) {})
+	})
+
+	// Messages are submitted in partition order
+	expected := [][]uint64{{0, 1}, {2, 3}, {4}}
+	for i := range expected {
+		msg := <-mockStgMinerAPI.pushedMessages
+		require.Equal(t, miner.Methods.SubmitWindowedPoSt, msg.Method)
+		var params miner.SubmitWindowedPoStParams
+		err := params.UnmarshalCBOR(bytes.NewReader(msg.Params))
+		require.NoError(t, err)
+
+		var idxs []uint64
+		for _, p := range params.Partitions {
+			idxs = append(idxs, p.Index)
+		}
+		require.Equal(t, expected[i], idxs)
+	}
+}
+
+// TestWDPostRecoveries verifies that recoveries of faulty sectors which can be
+// proven again are declared and recorded, unless declarations are disabled
+func TestProofRetryBackoff(t *testing.T) {
+	require.Equal(t, 10*time.Second, proofRetryBackoff(1))
+	require.Equal(t, 20*time.Second, proofRetryBackoff(2))
+	require.Equal(t, 80*time.Second, proofRetryBackoff(4))
+	require.Equal(t, 2*time.Minute, proofRetryBackoff(5))
+	require.Equal(t, 2*time.Minute, proofRetryBackoff(100))
+}
+
+func TestWDPostRecoveries(t *testing.T) {
+	ctx := context.Background()
+
+	proofType := abi.RegisteredPoStProof_StackedDrgWindow2KiBV1
+	postAct := tutils.NewIDAddr(t, 100)
+
+	sectors := bitfield.NewFromSet([]uint64{0, 1, 2, 3})
+	faulty := bitfield.NewFromSet([]uint64{1, 2})
+	partitions := []api.Partition{{
+		AllSectors:        sectors,
+		FaultySectors:     faulty,
+		RecoveringSectors: bitfield.New(),
+		LiveSectors:       sectors,
+		ActiveSectors:     sectors,
+	}}
+
+	newScheduler := func() (*WindowPoStScheduler, *mockStorageMinerAPI) {
+		mockStgMinerAPI := newMockStorageMinerAPI()
+		return &WindowPoStScheduler{
+			api:          mockStgMinerAPI,
+			prover:       &mockProver{},
+			faultTracker: &mockFaultTracker{},
+			proofType:    proofType,
+			actor:        postAct,
+			journal:      journal.NilJournal(),
+			addrSel:      &AddressSelector{},
+		}, mockStgMinerAPI
+	}
+
+	ts := mockTipSet(t)
+
+	t.Run("declared", func(t *testing.T) {
+		scheduler, mockStgMinerAPI := newScheduler()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			res := api.PoStRecovery{Deadline: 3, Height: ts.Height()}
+			recoveries, sm, err := scheduler.checkNextRecoveries(ctx, 3, partitions, ts.Key(), &res)
+			require.NoError(t, err)
+			require.Len(t, recoveries, 1)
+			scheduler.recordRecoveries(res, sm, err)
+		}()
+
+		msg := <-mockStgMinerAPI.pushedMessages
+		require.Equal(t, miner.Methods.DeclareFaultsRecovered, msg.Method)
+		<-done
+
+		recs := scheduler.Recoveries()
+		require.Len(t, recs, 1)
+		require.Equal(t, uint64(3), recs[0].Deadline)
+		require.True(t, recs[0].Declared)
+		require.NotNil(t, recs[0].DeclareMessage)
+		require.Len(t, recs[0].Partitions, 1)
+
+		recovered, err := recs[0].Partitions[0].Recovered.Count()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), recovered)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		scheduler, _ := newScheduler()
+		scheduler.DisableRecoveryDeclarations()
+
+		// nothing is pushed, the mock API would block on a pushed message
+		res := api.PoStRecovery{Deadline: 3, Height: ts.Height()}
+		recoveries, sm, err := scheduler.checkNextRecoveries(ctx, 3, partitions, ts.Key(), &res)
+		require.NoError(t, err)
+		require.Empty(t, recoveries)
+		require.Nil(t, sm)
+		scheduler.recordRecoveries(res, sm, err)
+
+		recs := scheduler.Recoveries()
+		require.Len(t, recs, 1)
+		require.False(t, recs[0].Declared)
+		require.Nil(t, recs[0].DeclareMessage)
+		require.Len(t, recs[0].Partitions, 1)
+	})
+}
+
 func mockTipSet(t *testing.T) *types.TipSet {
 	minerAct := tutils.NewActorAddr(t, "miner")
 	c, err := cid.Decode("QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH")
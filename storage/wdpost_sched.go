@@ -31,6 +31,16 @@ type WindowPoStScheduler struct {
 	proofType        abi.RegisteredPoStProof
 	partitionSectors uint64
 	ch               *changeHandler
+	iod              *ioDeferral
+	precheck         *deadlinePrecheck
+	recoveries       recoveryTracker
+	noRecoveries     bool
+	checkSampleRate  float64
+
+	// proving limits, see SetProvingLimits
+	maxPartitionsPerMsg int
+	parallelProofs      int
+	proofRetries        int
 
 	actor address.Address
 
@@ -72,6 +82,26 @@ func NewWindowedPoStScheduler(api storageMinerApi, fc config.MinerFeeConfig, as
 	}, nil
 }
 
+// SetCheckSampleRate sets the fraction (0 to 1) of the sectors whose sealed
+// data is read at random challenges when sectors are checked before proving
+func (s *WindowPoStScheduler) SetCheckSampleRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return xerrors.Errorf("sample rate must be between 0 and 1, got %f", rate)
+	}
+	s.checkSampleRate = rate
+	return nil
+}
+
+// SetProvingLimits sets the maximum number of partitions proven in a single
+// message (0 for the network limit), the number of messages of a deadline
+// for which proofs are generated in parallel, and how many times generating a
+// proof is retried when it fails for reasons other than unprovable sectors.
+func (s *WindowPoStScheduler) SetProvingLimits(maxPartitionsPerMsg, parallelProofs, proofRetries int) {
+	s.maxPartitionsPerMsg = maxPartitionsPerMsg
+	s.parallelProofs = parallelProofs
+	s.proofRetries = proofRetries
+}
+
 type changeHandlerAPIImpl struct {
 	storageMinerApi
 	*WindowPoStScheduler
@@ -164,6 +194,14 @@ func (s *WindowPoStScheduler) update(ctx context.Context, revert, apply *types.T
 	if err != nil {
 		log.Errorf("handling head updates in window post sched: %+v", err)
 	}
+
+	if err := s.updateIODeferral(ctx, apply); err != nil {
+		log.Errorf("updating sealing I/O deferral: %+v", err)
+	}
+
+	if err := s.updatePrecheck(ctx, apply); err != nil {
+		log.Errorf("pre-checking upcoming deadlines: %+v", err)
+	}
 }
 
 // onAbort is called when generating proofs or submitting proofs is aborted
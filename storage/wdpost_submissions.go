@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// WdPoStSubmissionsAPI is the subset of the full node API needed to find
+// window PoSt messages which landed on chain
+type WdPoStSubmissionsAPI interface {
+	ChainGetTipSet(context.Context, types.TipSetKey) (*types.TipSet, error)
+	ChainGetParentMessages(ctx context.Context, blockCid cid.Cid) ([]api.Message, error)
+	ChainGetParentReceipts(ctx context.Context, blockCid cid.Cid) ([]*types.MessageReceipt, error)
+}
+
+// WdPoStSubmission is a SubmitWindowedPoSt message sent to a miner actor
+// which executed successfully on chain. The miner actor verifies window PoSts
+// when they're submitted, so the partitions of a submission were proven.
+type WdPoStSubmission struct {
+	Message cid.Cid
+	Params  miner.SubmitWindowedPoStParams
+
+	// TipSet the message was included in; chain state at this tipset is the
+	// state the message was applied on top of
+	TipSet *types.TipSet
+}
+
+// FindWdPoStSubmissions walks the chain back from head to (and excluding) the
+// stop epoch, returning all successfully executed window PoSt messages sent to
+// the given miner, newest first
+func FindWdPoStSubmissions(ctx context.Context, a WdPoStSubmissionsAPI, maddr address.Address, head *types.TipSet, stop abi.ChainEpoch) ([]WdPoStSubmission, error) {
+	var out []WdPoStSubmission
+
+	ts := head
+	for ts.Height() > stop && ts.Height() > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		pts, err := a.ChainGetTipSet(ctx, ts.Parents())
+		if err != nil {
+			return nil, xerrors.Errorf("getting parent tipset: %w", err)
+		}
+
+		msgs, err := a.ChainGetParentMessages(ctx, ts.Cids()[0])
+		if err != nil {
+			return nil, xerrors.Errorf("getting messages included in %s: %w", pts.Key(), err)
+		}
+
+		rcpts, err := a.ChainGetParentReceipts(ctx, ts.Cids()[0])
+		if err != nil {
+			return nil, xerrors.Errorf("getting receipts for messages included in %s: %w", pts.Key(), err)
+		}
+
+		if len(msgs) != len(rcpts) {
+			return nil, xerrors.Errorf("message and receipt count mismatch in %s (%d != %d)", pts.Key(), len(msgs), len(rcpts))
+		}
+
+		for i, m := range msgs {
+			if m.Message.To != maddr || m.Message.Method != miner.Methods.SubmitWindowedPoSt {
+				continue
+			}
+			if rcpts[i].ExitCode != exitcode.Ok {
+				continue
+			}
+
+			var params miner.SubmitWindowedPoStParams
+			if err := params.UnmarshalCBOR(bytes.NewReader(m.Message.Params)); err != nil {
+				return nil, xerrors.Errorf("decoding window post params (msg %s): %w", m.Cid, err)
+			}
+
+			out = append(out, WdPoStSubmission{
+				Message: m.Cid,
+				Params:  params,
+				TipSet:  pts,
+			})
+		}
+
+		ts = pts
+	}
+
+	return out, nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	tutils "github.com/filecoin-project/specs-actors/v2/support/testing"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type mockSubmissionsAPI struct {
+	tipsets  map[types.TipSetKey]*types.TipSet
+	msgs     map[cid.Cid][]api.Message
+	receipts map[cid.Cid][]*types.MessageReceipt
+}
+
+func (m *mockSubmissionsAPI) ChainGetTipSet(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
+	return m.tipsets[tsk], nil
+}
+
+func (m *mockSubmissionsAPI) ChainGetParentMessages(ctx context.Context, blockCid cid.Cid) ([]api.Message, error) {
+	return m.msgs[blockCid], nil
+}
+
+func (m *mockSubmissionsAPI) ChainGetParentReceipts(ctx context.Context, blockCid cid.Cid) ([]*types.MessageReceipt, error) {
+	return m.receipts[blockCid], nil
+}
+
+func TestFindWdPoStSubmissions(t *testing.T) {
+	maddr := tutils.NewIDAddr(t, 1000)
+	other := tutils.NewIDAddr(t, 1001)
+
+	m := &mockSubmissionsAPI{
+		tipsets:  map[types.TipSetKey]*types.TipSet{},
+		msgs:     map[cid.Cid][]api.Message{},
+		receipts: map[cid.Cid][]*types.MessageReceipt{},
+	}
+
+	var chain []*types.TipSet
+	var parents []cid.Cid
+	for h := abi.ChainEpoch(10); h <= 13; h++ {
+		ts, err := types.NewTipSet([]*types.BlockHeader{{
+			Miner:                 tutils.NewActorAddr(t, "miner"),
+			Height:                h,
+			Parents:               parents,
+			ParentStateRoot:       dummyCid,
+			Messages:              dummyCid,
+			ParentMessageReceipts: dummyCid,
+			BlockSig:              &crypto.Signature{Type: crypto.SigTypeBLS},
+			BLSAggregate:          &crypto.Signature{Type: crypto.SigTypeBLS},
+		}})
+		require.NoError(t, err)
+
+		m.tipsets[ts.Key()] = ts
+		chain = append(chain, ts)
+		parents = ts.Cids()
+	}
+
+	post := func(to address.Address, dl uint64) *types.Message {
+		var params bytes.Buffer
+		require.NoError(t, (&miner.SubmitWindowedPoStParams{Deadline: dl}).MarshalCBOR(&params))
+		return &types.Message{To: to, From: other, Method: miner.Methods.SubmitWindowedPoSt, Params: params.Bytes(), Nonce: dl}
+	}
+
+	// messages included in a tipset are executed in its child
+	include := func(child *types.TipSet, msgs []*types.Message, codes []exitcode.ExitCode) {
+		for i, msg := range msgs {
+			m.msgs[child.Cids()[0]] = append(m.msgs[child.Cids()[0]], api.Message{Cid: msg.Cid(), Message: msg})
+			m.receipts[child.Cids()[0]] = append(m.receipts[child.Cids()[0]], &types.MessageReceipt{ExitCode: codes[i]})
+		}
+	}
+
+	include(chain[1], []*types.Message{post(maddr, 1)}, []exitcode.ExitCode{exitcode.Ok})
+	include(chain[2], []*types.Message{post(other, 2), post(maddr, 3)}, []exitcode.ExitCode{exitcode.Ok, exitcode.ErrIllegalArgument})
+	include(chain[3], []*types.Message{post(maddr, 4)}, []exitcode.ExitCode{exitcode.Ok})
+
+	subs, err := FindWdPoStSubmissions(context.Background(), m, maddr, chain[3], 10)
+	require.NoError(t, err)
+
+	// posts to other miners and failed posts are left out, newest first
+	require.Len(t, subs, 2)
+	require.Equal(t, uint64(4), subs[0].Params.Deadline)
+	require.Equal(t, chain[2].Key(), subs[0].TipSet.Key())
+	require.Equal(t, uint64(1), subs[1].Params.Deadline)
+	require.Equal(t, chain[0].Key(), subs[1].TipSet.Key())
+
+	// the walk stops at the stop epoch
+	subs, err = FindWdPoStSubmissions(context.Background(), m, maddr, chain[3], 11)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+}
@@ -0,0 +1,362 @@
+package workerauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+var log = logging.Logger("workerauth")
+
+var (
+	joinPrefix     = datastore.NewKey("/worker-auth/join")
+	identityPrefix = datastore.NewKey("/worker-auth/identity")
+)
+
+// CertificateTTL is how long worker certificates stay valid without being
+// renewed with Renew
+const CertificateTTL = 7 * 24 * time.Hour
+
+// WorkerPermissions are the API permissions of worker certificates
+var WorkerPermissions = []auth.Permission{apistruct.PermRead, apistruct.PermWorker}
+
+// joinToken is the persisted state of an unused join token. Only the hash of
+// the token is stored.
+type joinToken struct {
+	Expires time.Time
+}
+
+// certPayload is the JWT payload of worker identity certificates. The Allow
+// field makes certificates valid API tokens.
+type certPayload struct {
+	Allow  []auth.Permission
+	Worker *uuid.UUID `json:",omitempty"`
+}
+
+// Authority issues short-lived, single-use join tokens, which workers exchange
+// for identity certificates. Certificates are used by workers to authenticate
+// to the miner API, with the WorkerPermissions only. They expire unless the
+// worker renews them, and can be revoked per worker.
+type Authority struct {
+	ds     datastore.Batching
+	secret *jwt.HMACSHA
+	ttl    time.Duration
+
+	lk      sync.Mutex
+	revoked map[uuid.UUID]struct{}
+	expires map[uuid.UUID]time.Time
+}
+
+func New(ds dtypes.MetadataDS, secret *dtypes.APIAlg) (*Authority, error) {
+	a := &Authority{
+		ds:      ds,
+		secret:  (*jwt.HMACSHA)(secret),
+		ttl:     CertificateTTL,
+		revoked: map[uuid.UUID]struct{}{},
+		expires: map[uuid.UUID]time.Time{},
+	}
+
+	ids, err := a.Identities()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if id.Revoked {
+			a.revoked[id.ID] = struct{}{}
+		}
+		a.expires[id.ID] = id.Expires
+	}
+
+	return a, nil
+}
+
+// CreateJoinToken returns a new join token valid for the given duration
+func (a *Authority) CreateJoinToken(ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		return "", xerrors.Errorf("join token validity must be positive")
+	}
+
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	if err := a.pruneJoinTokens(); err != nil {
+		log.Warnf("pruning expired join tokens: %+v", err)
+	}
+
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", xerrors.Errorf("generating join token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw[:])
+
+	b, err := json.Marshal(&joinToken{Expires: build.Clock.Now().Add(ttl)})
+	if err != nil {
+		return "", xerrors.Errorf("marshaling join token: %w", err)
+	}
+
+	if err := a.ds.Put(joinKey(token), b); err != nil {
+		return "", xerrors.Errorf("storing join token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Join consumes the join token, and issues an identity certificate for a new
+// worker
+func (a *Authority) Join(token string, name string) (api.WorkerCredential, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	b, err := a.ds.Get(joinKey(token))
+	if err == datastore.ErrNotFound {
+		return api.WorkerCredential{}, xerrors.Errorf("invalid or already used join token")
+	}
+	if err != nil {
+		return api.WorkerCredential{}, xerrors.Errorf("loading join token: %w", err)
+	}
+
+	// tokens are single-use, even if joining fails below
+	if err := a.ds.Delete(joinKey(token)); err != nil {
+		return api.WorkerCredential{}, xerrors.Errorf("deleting join token: %w", err)
+	}
+
+	var jt joinToken
+	if err := json.Unmarshal(b, &jt); err != nil {
+		return api.WorkerCredential{}, xerrors.Errorf("unmarshaling join token: %w", err)
+	}
+
+	if build.Clock.Now().After(jt.Expires) {
+		return api.WorkerCredential{}, xerrors.Errorf("join token expired at %s", jt.Expires)
+	}
+
+	now := build.Clock.Now()
+	id := api.WorkerIdentity{
+		ID:      uuid.New(),
+		Name:    name,
+		Joined:  now,
+		Expires: now.Add(a.ttl),
+	}
+
+	if err := a.putIdentity(id); err != nil {
+		return api.WorkerCredential{}, err
+	}
+	a.expires[id.ID] = id.Expires
+
+	cert, err := jwt.Sign(&certPayload{
+		Allow:  WorkerPermissions,
+		Worker: &id.ID,
+	}, a.secret)
+	if err != nil {
+		return api.WorkerCredential{}, xerrors.Errorf("signing worker certificate: %w", err)
+	}
+
+	log.Infow("worker joined", "identity", id.ID, "name", name)
+
+	return api.WorkerCredential{
+		ID:          id.ID,
+		Certificate: string(cert),
+	}, nil
+}
+
+// Renew extends the validity of a worker certificate which hasn't expired
+// yet, returning when it expires now
+func (a *Authority) Renew(cert string) (time.Time, error) {
+	var payload certPayload
+	if _, err := jwt.Verify([]byte(cert), a.secret, &payload); err != nil {
+		return time.Time{}, xerrors.Errorf("JWT Verification failed: %w", err)
+	}
+	if payload.Worker == nil {
+		return time.Time{}, xerrors.Errorf("not a worker certificate")
+	}
+
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	if err := a.checkIdentity(*payload.Worker); err != nil {
+		return time.Time{}, err
+	}
+
+	b, err := a.ds.Get(identityKey(*payload.Worker))
+	if err != nil {
+		return time.Time{}, xerrors.Errorf("loading worker identity: %w", err)
+	}
+
+	var wi api.WorkerIdentity
+	if err := json.Unmarshal(b, &wi); err != nil {
+		return time.Time{}, xerrors.Errorf("unmarshaling worker identity: %w", err)
+	}
+
+	wi.Expires = build.Clock.Now().Add(a.ttl)
+	if err := a.putIdentity(wi); err != nil {
+		return time.Time{}, err
+	}
+	a.expires[wi.ID] = wi.Expires
+
+	return wi.Expires, nil
+}
+
+// Identities lists the identities of all workers which joined, oldest first
+func (a *Authority) Identities() ([]api.WorkerIdentity, error) {
+	res, err := a.ds.Query(query.Query{Prefix: identityPrefix.String()})
+	if err != nil {
+		return nil, xerrors.Errorf("querying worker identities: %w", err)
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []api.WorkerIdentity
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("iterating worker identities: %w", r.Error)
+		}
+
+		var id api.WorkerIdentity
+		if err := json.Unmarshal(r.Value, &id); err != nil {
+			return nil, xerrors.Errorf("unmarshaling worker identity %s: %w", r.Key, err)
+		}
+
+		out = append(out, id)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Joined.Before(out[j].Joined)
+	})
+
+	return out, nil
+}
+
+// Revoke revokes the certificate of the worker identity. Revoked workers can't
+// authenticate to the miner API anymore, and need to join again.
+func (a *Authority) Revoke(id uuid.UUID) error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	b, err := a.ds.Get(identityKey(id))
+	if err == datastore.ErrNotFound {
+		return xerrors.Errorf("worker identity %s not found", id)
+	}
+	if err != nil {
+		return xerrors.Errorf("loading worker identity: %w", err)
+	}
+
+	var wi api.WorkerIdentity
+	if err := json.Unmarshal(b, &wi); err != nil {
+		return xerrors.Errorf("unmarshaling worker identity: %w", err)
+	}
+
+	wi.Revoked = true
+	if err := a.putIdentity(wi); err != nil {
+		return err
+	}
+
+	a.revoked[id] = struct{}{}
+
+	log.Infow("worker identity revoked", "identity", id, "name", wi.Name)
+
+	return nil
+}
+
+// Verify checks that the API token is valid, and isn't a certificate of a
+// revoked or expired worker identity
+func (a *Authority) Verify(token string) error {
+	var payload certPayload
+	if _, err := jwt.Verify([]byte(token), a.secret, &payload); err != nil {
+		return xerrors.Errorf("JWT Verification failed: %w", err)
+	}
+
+	if payload.Worker == nil {
+		return nil
+	}
+
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	return a.checkIdentity(*payload.Worker)
+}
+
+// must be called with a.lk held
+func (a *Authority) checkIdentity(id uuid.UUID) error {
+	if _, revoked := a.revoked[id]; revoked {
+		return xerrors.Errorf("worker identity %s has been revoked", id)
+	}
+
+	expires, ok := a.expires[id]
+	if !ok {
+		return xerrors.Errorf("unknown worker identity %s", id)
+	}
+	if build.Clock.Now().After(expires) {
+		return xerrors.Errorf("worker certificate of %s expired at %s", id, expires)
+	}
+
+	return nil
+}
+
+func (a *Authority) putIdentity(id api.WorkerIdentity) error {
+	b, err := json.Marshal(&id)
+	if err != nil {
+		return xerrors.Errorf("marshaling worker identity: %w", err)
+	}
+
+	if err := a.ds.Put(identityKey(id.ID), b); err != nil {
+		return xerrors.Errorf("storing worker identity: %w", err)
+	}
+
+	return nil
+}
+
+// must be called with a.lk held
+func (a *Authority) pruneJoinTokens() error {
+	res, err := a.ds.Query(query.Query{Prefix: joinPrefix.String()})
+	if err != nil {
+		return xerrors.Errorf("querying join tokens: %w", err)
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return xerrors.Errorf("iterating join tokens: %w", err)
+	}
+
+	now := build.Clock.Now()
+	for _, e := range entries {
+		var jt joinToken
+		if err := json.Unmarshal(e.Value, &jt); err != nil {
+			return xerrors.Errorf("unmarshaling join token: %w", err)
+		}
+
+		if now.After(jt.Expires) {
+			if err := a.ds.Delete(datastore.NewKey(e.Key)); err != nil {
+				return xerrors.Errorf("deleting expired join token: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func joinKey(token string) datastore.Key {
+	h := sha256.Sum256([]byte(token))
+	return joinPrefix.ChildString(hex.EncodeToString(h[:]))
+}
+
+func identityKey(id uuid.UUID) datastore.Key {
+	return identityPrefix.ChildString(id.String())
+}
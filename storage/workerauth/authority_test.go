@@ -0,0 +1,104 @@
+package workerauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+func TestJoinAndRevoke(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	secret := (*dtypes.APIAlg)(jwt.NewHS256([]byte("secret")))
+
+	a, err := New(ds, secret)
+	require.NoError(t, err)
+
+	token, err := a.CreateJoinToken(time.Hour)
+	require.NoError(t, err)
+
+	cred, err := a.Join(token, "worker-1")
+	require.NoError(t, err)
+	require.NoError(t, a.Verify(cred.Certificate))
+
+	// certificates only grant the worker permissions
+	var payload certPayload
+	_, err = jwt.Verify([]byte(cred.Certificate), (*jwt.HMACSHA)(secret), &payload)
+	require.NoError(t, err)
+	require.Equal(t, WorkerPermissions, payload.Allow)
+
+	// join tokens are single-use
+	_, err = a.Join(token, "worker-2")
+	require.Error(t, err)
+
+	ids, err := a.Identities()
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+	require.Equal(t, cred.ID, ids[0].ID)
+	require.Equal(t, "worker-1", ids[0].Name)
+
+	require.NoError(t, a.Revoke(cred.ID))
+	require.Error(t, a.Verify(cred.Certificate))
+	_, err = a.Renew(cred.Certificate)
+	require.Error(t, err)
+
+	// revocations persist
+	a, err = New(ds, secret)
+	require.NoError(t, err)
+	require.Error(t, a.Verify(cred.Certificate))
+}
+
+func TestJoinTokenExpiry(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	a, err := New(ds, (*dtypes.APIAlg)(jwt.NewHS256([]byte("secret"))))
+	require.NoError(t, err)
+
+	token, err := a.CreateJoinToken(time.Millisecond)
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = a.Join(token, "worker")
+	require.Error(t, err)
+}
+
+func TestCertificateExpiry(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	secret := (*dtypes.APIAlg)(jwt.NewHS256([]byte("secret")))
+
+	a, err := New(ds, secret)
+	require.NoError(t, err)
+	a.ttl = 50 * time.Millisecond
+
+	token, err := a.CreateJoinToken(time.Hour)
+	require.NoError(t, err)
+
+	cred, err := a.Join(token, "worker")
+	require.NoError(t, err)
+
+	// renewing keeps the certificate valid past its initial expiry
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+
+		_, err := a.Renew(cred.Certificate)
+		require.NoError(t, err)
+		require.NoError(t, a.Verify(cred.Certificate))
+	}
+
+	// expiry persists
+	a, err = New(ds, secret)
+	require.NoError(t, err)
+	require.NoError(t, a.Verify(cred.Certificate))
+
+	time.Sleep(60 * time.Millisecond)
+	require.Error(t, a.Verify(cred.Certificate))
+
+	// expired certificates can't be renewed
+	_, err = a.Renew(cred.Certificate)
+	require.Error(t, err)
+}